@@ -0,0 +1,138 @@
+// Command reviewerctl runs maintenance tasks of the reviewer service.
+//
+// Usage:
+//
+//	reviewerctl verify [-fix] [-url URL] [-token TOKEN] [-secret SECRET]
+//	reviewerctl migrate [-phase pre|post]
+//
+// verify reports inconsistent data through the admin API of a running service
+// and, with -fix, repairs it. It exits with status 1 when issues remain
+// unfixed.
+//
+// migrate applies the schema migrations of a phase to the database configured
+// by the DB_* variables, as the service does on startup: pre before a new
+// release replaces the old one, post once the old one is gone.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"Avito2025/internal/config"
+	"Avito2025/internal/storage/postgres"
+	"Avito2025/pkg/client"
+)
+
+const defaultURL = "http://localhost:8080"
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "verify":
+		os.Exit(verify(os.Args[2:]))
+	case "migrate":
+		os.Exit(migrate(os.Args[2:]))
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: reviewerctl verify [-fix] [-url URL] [-token TOKEN] [-secret SECRET]")
+	fmt.Fprintln(os.Stderr, "       reviewerctl migrate [-phase pre|post]")
+}
+
+func verify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	baseURL := fs.String("url", getenv("REVIEWER_URL", defaultURL), "service root URL")
+	token := fs.String("token", os.Getenv("ADMIN_TOKEN"), "admin token")
+	secret := fs.String("secret", os.Getenv("HTTP_SIGNING_SECRET"), "request signing secret, used instead of a token")
+	fix := fs.Bool("fix", false, "repair the issues found")
+	timeout := fs.Duration("timeout", 30*time.Second, "request timeout")
+	_ = fs.Parse(args)
+
+	c := client.New(client.Config{
+		BaseURL:       *baseURL,
+		AdminToken:    *token,
+		SigningSecret: *secret,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	report, err := c.VerifyIntegrity(ctx, *fix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "verify: %v\n", err)
+		return 2
+	}
+
+	checks := make([]string, 0, len(report.Checks))
+	for check := range report.Checks {
+		checks = append(checks, check)
+	}
+	sort.Strings(checks)
+	for _, check := range checks {
+		fmt.Printf("%-20s %d\n", check, report.Checks[check])
+	}
+	for _, issue := range report.Details {
+		status := "found"
+		if issue.Fixed {
+			status = "fixed"
+		}
+		fmt.Printf("%s\t%s\tpr=%s user=%s team=%s\n",
+			status, issue.Check, issue.PullRequestID, issue.UserID, issue.TeamName)
+	}
+	fmt.Printf("%d issues, %d fixed\n", report.Issues, report.Fixed)
+
+	if report.Issues > report.Fixed {
+		return 1
+	}
+	return 0
+}
+
+func migrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	phase := fs.String("phase", postgres.PhasePre, "migrations to apply: pre or post, which includes pre")
+	timeout := fs.Duration("timeout", 10*time.Minute, "migration timeout")
+	_ = fs.Parse(args)
+
+	if *phase != postgres.PhasePre && *phase != postgres.PhasePost {
+		fmt.Fprintf(os.Stderr, "migrate: unsupported phase %q\n", *phase)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+	applied, err := postgres.Migrate(ctx, cfg.Storage.Postgres, *phase)
+	for _, name := range applied {
+		fmt.Printf("applied %s\n", name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+	fmt.Printf("%d migrations applied\n", len(applied))
+	return 0
+}
+
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
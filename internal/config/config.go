@@ -2,8 +2,12 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -16,20 +20,341 @@ const (
 	defaultDBName      = "reviewer"
 	defaultDBSSLMode   = "disable"
 	defaultDBMaxConns  = 4
+
+	defaultStorageDecorators       = "metrics,retry"
+	defaultStorageCacheTTL         = 5 * time.Second
+	defaultStorageRetryMaxAttempts = 3
+	defaultStorageRetryBackoff     = 50 * time.Millisecond
+
+	defaultDBStatementTimeout = 2 * time.Second
+	defaultDBQueryTimeout     = 3 * time.Second
+	defaultMigratePhase       = "post"
+
+	defaultHTTPReadTimeout  = 2 * time.Second
+	defaultHTTPWriteTimeout = 5 * time.Second
+	defaultHTTPMaxBodyBytes = 1 << 20
+	defaultHTTPMaxInFlight  = 64
+	defaultHTTPCompression  = 5
+	defaultHealthCacheTTL   = time.Second
+	defaultSignatureMaxAge  = 5 * time.Minute
+
+	defaultReminderInterval = time.Minute
+	defaultArchiveInterval  = time.Hour
+	defaultArchiveAfterDays = 90
+
+	defaultUnderassignedInterval = time.Minute
+	defaultDeferredInterval      = time.Minute
+	defaultPendingInterval       = time.Minute
+	defaultAcceptanceInterval    = time.Minute
+	defaultDailyStatsInterval    = time.Hour
+	defaultDigestInterval        = time.Minute
+	defaultOrphanInterval        = 10 * time.Minute
+
+	defaultDirectoryType     = "none"
+	defaultLDAPUserAttribute = "uid"
+	defaultLDAPTimeout       = 5 * time.Second
+
+	defaultWebhookMaxAttempts  = 3
+	defaultWebhookRetryBackoff = time.Second
+	defaultWebhookTimeout      = 5 * time.Second
+	defaultWebhookWorkers      = 4
+	defaultWebhookPollInterval = 5 * time.Second
+	defaultWebhookLease        = 30 * time.Second
+
+	defaultNotifyTimeout = 5 * time.Second
+	defaultNotifyWorkers = 4
+
+	defaultExportRegion    = "us-east-1"
+	defaultExportPrefix    = "events/"
+	defaultExportInterval  = time.Hour
+	defaultExportMinAge    = 7 * 24 * time.Hour
+	defaultExportBatchSize = 10000
+	defaultExportTimeout   = 30 * time.Second
+
+	defaultWriteQueueMaxEntries     = 10000
+	defaultWriteQueueWindow         = 15 * time.Minute
+	defaultWriteQueueReplayInterval = 5 * time.Second
 )
 
 type Config struct {
 	HTTP    HTTPConfig
 	Storage StorageConfig
+	// SeedFile, when set, points at a JSON fixture loaded on startup.
+	SeedFile string
+	// RandomSeed, when set, makes reviewer selection deterministic.
+	RandomSeed *int64
+	Scheduler  SchedulerConfig
+	Directory  DirectoryConfig
+	Webhook    WebhookConfig
+	Notify     NotifyConfig
+	Export     ExportConfig
+	WriteQueue WriteQueueConfig
+	// BotTeams maps authors unknown to the service, such as bots, to the team
+	// reviewing their pull requests.
+	BotTeams map[string]string
+	Quotas   QuotaConfig
+}
+
+// QuotaConfig limits the size of teams, the unmerged pull requests of an
+// author and the reviewers of a pull request. Going over a soft limit is
+// allowed with a warning, going over a hard one is refused. Zero disables a
+// limit.
+type QuotaConfig struct {
+	TeamMembersSoft      int
+	TeamMembersHard      int
+	OpenPullRequestsSoft int
+	OpenPullRequestsHard int
+	ReviewersSoft        int
+	ReviewersHard        int
 }
 
+type WebhookConfig struct {
+	// MaxAttempts is how many times a delivery is tried before it is given up.
+	MaxAttempts int
+	// RetryBackoff is the wait before the first retry; it doubles with every
+	// further attempt.
+	RetryBackoff time.Duration
+	// Timeout bounds each delivery request. Zero disables the limit.
+	Timeout time.Duration
+	// Workers caps the number of concurrent deliveries.
+	Workers int
+	// PollInterval is how often the change feed is read for events to
+	// deliver when no new event is announced in the meantime.
+	PollInterval time.Duration
+	// Lease is how long an instance holds on to webhook dispatch without
+	// renewing it before another instance may take over.
+	Lease time.Duration
+	// BaseURL is the public root of the service used for links in payloads.
+	// When empty, deliveries carry no links.
+	BaseURL string
+}
+
+// NotifyConfig configures the channels authors can pick for notifications
+// about their pull requests. A channel without settings is not delivered to.
+type NotifyConfig struct {
+	// SlackWebhookURL is the incoming webhook messages are posted to.
+	SlackWebhookURL string
+	// SMTPAddr is the host:port of the mail relay; SMTPFrom is the sender
+	// address. SMTPUsername and SMTPPassword enable PLAIN auth when set.
+	SMTPAddr     string
+	SMTPFrom     string
+	SMTPUsername string
+	SMTPPassword string
+	// Timeout bounds each delivery. Zero disables the limit.
+	Timeout time.Duration
+	// Workers caps the number of concurrent deliveries.
+	Workers int
+}
+
+// ExportConfig configures shipping old events to an S3-compatible bucket.
+// The exporter is off while Endpoint or Bucket is empty.
+type ExportConfig struct {
+	// Endpoint is the base URL of the S3 API, such as
+	// https://s3.eu-central-1.amazonaws.com or http://minio:9000.
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	// Prefix is prepended to every object key.
+	Prefix string
+	// Interval is how often events are exported. Zero disables the exporter.
+	Interval time.Duration
+	// MinAge keeps recent events in the database so that readers of the
+	// change feed have time to catch up before the events are pruned.
+	MinAge time.Duration
+	// BatchSize caps the number of events per object.
+	BatchSize int
+	// Timeout bounds each upload. Zero disables the limit.
+	Timeout time.Duration
+}
+
+// WriteQueueConfig configures queueing of writes on local disk while the
+// database is down, to be replayed once it is back. The queue is off while
+// Path is empty.
+type WriteQueueConfig struct {
+	// Path is the journal file. It survives restarts, so it belongs on a
+	// persistent volume.
+	Path string
+	// MaxEntries caps the queue; further writes are refused with 503. Zero
+	// disables the limit.
+	MaxEntries int
+	// Window is how long a write may wait for replay before it is dropped.
+	// Zero disables the limit.
+	Window time.Duration
+	// ReplayInterval is how often the database is checked for queued writes
+	// to be replayed.
+	ReplayInterval time.Duration
+}
+
+type DirectoryConfig struct {
+	// Type selects the directory that user IDs are checked against when teams
+	// are added: "none" or "ldap".
+	Type string
+	LDAP LDAPConfig
+}
+
+type LDAPConfig struct {
+	URL          string
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserAttribute holds the user ID in directory entries, e.g. uid or
+	// sAMAccountName.
+	UserAttribute string
+	// Timeout bounds each lookup. Zero disables the limit.
+	Timeout time.Duration
+}
+
+type SchedulerConfig struct {
+	// ReminderInterval is how often reminder policies are evaluated. Zero
+	// disables the job.
+	ReminderInterval time.Duration
+	// ArchiveInterval is how often old merged pull requests are archived.
+	// Zero disables the job.
+	ArchiveInterval time.Duration
+	// ArchiveAfterDays is how long a merged pull request stays in the hot
+	// tables before it is archived. Non-positive values disable the job.
+	ArchiveAfterDays int
+	// UnderassignedInterval is how often the under-assigned pull requests
+	// gauge is refreshed. Zero disables the job.
+	UnderassignedInterval time.Duration
+	// DeferOffHours postpones automatic assignment of pull requests created
+	// outside their team's working hours to the next working day.
+	DeferOffHours bool
+	// DeferredInterval is how often postponed assignments are dispatched.
+	// Zero disables the job.
+	DeferredInterval time.Duration
+	// PendingInterval is how often open reviewer slots of under-assigned
+	// pull requests are filled. Zero disables the job.
+	PendingInterval time.Duration
+	// AcceptanceInterval is how often reviewers who did not accept their
+	// review in time are replaced. Zero disables the job.
+	AcceptanceInterval time.Duration
+	// DailyStatsInterval is how often the daily review aggregates of
+	// yesterday and today are rebuilt. Zero disables the job.
+	DailyStatsInterval time.Duration
+	// DigestInterval is how often due notification digests are sent. Zero
+	// disables the job.
+	DigestInterval time.Duration
+	// OrphanInterval is how often reviewer state left behind by removed
+	// reviewers is counted. Zero disables the job.
+	OrphanInterval time.Duration
+	// OrphanRepair makes the job delete the orphaned rows it finds; by
+	// default they are only counted.
+	OrphanRepair bool
+}
+
+// JSON field namings of HTTP responses. Legacy keeps the camelCase keys, such
+// as createdAt, that some payloads have had since the first API version;
+// snake renames them to snake_case like every other key.
+const (
+	JSONNamingLegacy = "legacy"
+	JSONNamingSnake  = "snake"
+)
+
 type HTTPConfig struct {
 	Addr string
+	// ReadTimeout and WriteTimeout bound the handling of safe (GET/HEAD) and
+	// mutating requests respectively. Zero disables the limit.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// AdminToken guards administrative endpoints. When empty they are disabled.
+	AdminToken string
+	// MaxBodyBytes caps the size of request bodies. Zero disables the limit.
+	MaxBodyBytes int64
+	// MaxInFlight caps the requests handled at once; requests beyond it are
+	// refused with 503 instead of queueing for a database connection.
+	// Streams and long polls are not counted. Zero disables the limit.
+	MaxInFlight int
+	// CompressionLevel compresses JSON and CSV responses with zstd, gzip or
+	// deflate, as negotiated through Accept-Encoding. It is a gzip level
+	// from 1 to 9, mapped onto the nearest zstd level; zero disables
+	// compression.
+	CompressionLevel int
+	// SigningSecret enables HMAC signatures of mutating requests for
+	// server-to-server callers. A correctly signed request is trusted like
+	// one carrying AdminToken; a wrongly signed one is refused.
+	SigningSecret string
+	// SignatureMaxAge bounds the clock skew of signed requests and how long
+	// their nonces are remembered against replays.
+	SignatureMaxAge time.Duration
+	// RequireSignature refuses unsigned mutating requests when SigningSecret
+	// is set.
+	RequireSignature bool
+	// HealthCacheTTL is how long /health reuses the result of its last
+	// database ping. Zero pings on every probe.
+	HealthCacheTTL time.Duration
+	// RequireTeamTokens refuses requests creating pull requests unless they
+	// carry the admin token or a team token. A team token is checked
+	// whenever it is sent.
+	RequireTeamTokens bool
+	// EnforceTeamLeads restricts changes to a team's configuration, forced
+	// reassignments and forced merges to admins and leads of the team, who
+	// authenticate with a team token issued to them.
+	EnforceTeamLeads bool
+	// DisableLegacyRoutes drops the unversioned aliases of the /v1 API.
+	DisableLegacyRoutes bool
+	// EnableTestEndpoints exposes /test routes that wipe and seed the data.
+	// Never enable it outside test environments.
+	EnableTestEndpoints bool
+	// StrictJSON refuses request bodies with fields the endpoint does not
+	// know instead of ignoring them.
+	StrictJSON bool
+	// JSONNaming is JSONNamingLegacy or JSONNamingSnake; other values mean
+	// JSONNamingLegacy.
+	JSONNaming string
+	// BaseURL is the public root of the service, such as
+	// https://reviews.example.com, used for links in payloads. When empty,
+	// links are built from X-Forwarded-Proto and X-Forwarded-Host or the
+	// request itself.
+	BaseURL string
+	// Chaos injects faults into requests for resilience drills.
+	Chaos ChaosConfig
+}
+
+// ChaosConfig makes requests slow or fail on purpose, so that clients and
+// their retries can be tried against a staging instance. Failed requests
+// never reach the storage. Never enable it in production.
+type ChaosConfig struct {
+	Enabled bool
+	// Latency and ErrorRate, a probability from 0 to 1 of answering 503,
+	// apply to every request but /health and /metrics.
+	Latency   time.Duration
+	ErrorRate float64
+	// Routes overrides Latency and ErrorRate by path, given without the
+	// /v1 or /v2 prefix, such as /pullRequest/create.
+	Routes map[string]ChaosRule
+}
+
+type ChaosRule struct {
+	Latency   time.Duration
+	ErrorRate float64
 }
 
 type StorageConfig struct {
 	Type     string
 	Postgres PostgresConfig
+	// Decorators lists the wrappers put around the store, outermost first:
+	// "metrics", "cache" and "retry".
+	Decorators []string
+	Cache      StorageCacheConfig
+	Retry      StorageRetryConfig
+}
+
+type StorageCacheConfig struct {
+	// TTL bounds how long a cached read may lag behind writes made by other
+	// instances.
+	TTL time.Duration
+}
+
+type StorageRetryConfig struct {
+	// MaxAttempts is how many times a call is tried before its error is
+	// returned.
+	MaxAttempts int
+	// Backoff is the wait before the first retry; it doubles with every
+	// further attempt.
+	Backoff time.Duration
 }
 
 type PostgresConfig struct {
@@ -40,13 +365,48 @@ type PostgresConfig struct {
 	DBName   string
 	SSLMode  string
 	MaxConns int32
+	// Pool tuning; zero values keep the pgxpool defaults.
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	// StatementTimeout is set as the server-side statement_timeout of every
+	// connection; QueryTimeout bounds each query on the client, including the
+	// wait for a free connection. Zero disables the limit.
+	StatementTimeout time.Duration
+	QueryTimeout     time.Duration
+	// MigratePhase selects the migrations applied on startup: "post" all of
+	// them, "pre" only those compatible with the previous release, "none"
+	// nothing, leaving them to reviewerctl migrate. Empty means "post".
+	MigratePhase string
 }
 
+// DSN returns the connection URL. The credentials are escaped, so secrets
+// read from files or Vault may hold any characters.
 func (p PostgresConfig) DSN() string {
-	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", p.User, p.Password, p.Host, p.Port, p.DBName, p.SSLMode)
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(p.User, p.Password),
+		Host:     net.JoinHostPort(p.Host, p.Port),
+		Path:     "/" + p.DBName,
+		RawQuery: url.Values{"sslmode": {p.SSLMode}}.Encode(),
+	}
+	return dsn.String()
 }
 
-func Load() Config {
+// Load reads the configuration from the environment. Secrets may also come
+// from files or Vault, see secretSource; Load fails when one of them cannot
+// be read rather than falling back to a default.
+func Load() (Config, error) {
+	secrets := &secretSource{}
+	if vault := loadVaultConfig(); vault.enabled() {
+		values, err := readVaultSecrets(vault)
+		if err != nil {
+			return Config{}, err
+		}
+		secrets.vault = values
+	}
+
 	port := getenvDefault("HTTP_PORT", defaultHTTPPort)
 
 	storageType := getenvDefault("STORAGE_TYPE", defaultStorageType)
@@ -54,21 +414,136 @@ func Load() Config {
 		Host:     getenvDefault("DB_HOST", defaultDBHost),
 		Port:     getenvDefault("DB_PORT", defaultDBPort),
 		User:     getenvDefault("DB_USER", defaultDBUser),
-		Password: getenvDefault("DB_PASSWORD", defaultDBPassword),
+		Password: secrets.get("DB_PASSWORD", defaultDBPassword),
 		DBName:   getenvDefault("DB_NAME", defaultDBName),
 		SSLMode:  getenvDefault("DB_SSL_MODE", defaultDBSSLMode),
 		MaxConns: int32(getenvInt("DB_MAX_CONNS", defaultDBMaxConns)),
+
+		MinConns:          int32(getenvInt("DB_MIN_CONNS", 0)),
+		MaxConnLifetime:   getenvDuration("DB_MAX_CONN_LIFETIME", 0),
+		MaxConnIdleTime:   getenvDuration("DB_MAX_CONN_IDLE_TIME", 0),
+		HealthCheckPeriod: getenvDuration("DB_HEALTH_CHECK_PERIOD", 0),
+		StatementTimeout:  getenvDuration("DB_STATEMENT_TIMEOUT", defaultDBStatementTimeout),
+		QueryTimeout:      getenvDuration("DB_QUERY_TIMEOUT", defaultDBQueryTimeout),
+		MigratePhase:      getenvDefault("MIGRATE_PHASE", defaultMigratePhase),
 	}
 
-	return Config{
+	cfg := Config{
 		HTTP: HTTPConfig{
-			Addr: fmt.Sprintf(":%s", port),
+			Addr:                fmt.Sprintf(":%s", port),
+			ReadTimeout:         getenvDuration("HTTP_READ_TIMEOUT", defaultHTTPReadTimeout),
+			WriteTimeout:        getenvDuration("HTTP_WRITE_TIMEOUT", defaultHTTPWriteTimeout),
+			AdminToken:          secrets.get("ADMIN_TOKEN", ""),
+			MaxBodyBytes:        int64(getenvInt("HTTP_MAX_BODY_BYTES", defaultHTTPMaxBodyBytes)),
+			MaxInFlight:         getenvInt("HTTP_MAX_IN_FLIGHT", defaultHTTPMaxInFlight),
+			CompressionLevel:    getenvInt("HTTP_COMPRESSION_LEVEL", defaultHTTPCompression),
+			HealthCacheTTL:      getenvDuration("HTTP_HEALTH_CACHE_TTL", defaultHealthCacheTTL),
+			SigningSecret:       secrets.get("HTTP_SIGNING_SECRET", ""),
+			SignatureMaxAge:     getenvDuration("HTTP_SIGNATURE_MAX_AGE", defaultSignatureMaxAge),
+			RequireSignature:    getenvBool("HTTP_REQUIRE_SIGNATURE", false),
+			RequireTeamTokens:   getenvBool("HTTP_REQUIRE_TEAM_TOKENS", false),
+			EnforceTeamLeads:    getenvBool("HTTP_ENFORCE_TEAM_LEADS", false),
+			DisableLegacyRoutes: getenvBool("HTTP_DISABLE_LEGACY_ROUTES", false),
+			EnableTestEndpoints: getenvBool("ENABLE_TEST_ENDPOINTS", false),
+			StrictJSON:          getenvBool("HTTP_STRICT_JSON", false),
+			JSONNaming:          getenvDefault("HTTP_JSON_NAMING", JSONNamingLegacy),
+			BaseURL:             os.Getenv("BASE_URL"),
+			Chaos: ChaosConfig{
+				Enabled:   getenvBool("HTTP_CHAOS_ENABLED", false),
+				Latency:   getenvDuration("HTTP_CHAOS_LATENCY", 0),
+				ErrorRate: getenvFloat("HTTP_CHAOS_ERROR_RATE", 0),
+				Routes:    getenvChaosRoutes("HTTP_CHAOS_ROUTES"),
+			},
 		},
 		Storage: StorageConfig{
-			Type:     storageType,
-			Postgres: pg,
+			Type:       storageType,
+			Postgres:   pg,
+			Decorators: getenvList("STORAGE_DECORATORS", defaultStorageDecorators),
+			Cache: StorageCacheConfig{
+				TTL: getenvDuration("STORAGE_CACHE_TTL", defaultStorageCacheTTL),
+			},
+			Retry: StorageRetryConfig{
+				MaxAttempts: getenvInt("STORAGE_RETRY_MAX_ATTEMPTS", defaultStorageRetryMaxAttempts),
+				Backoff:     getenvDuration("STORAGE_RETRY_BACKOFF", defaultStorageRetryBackoff),
+			},
+		},
+		SeedFile:   os.Getenv("SEED_FILE"),
+		RandomSeed: getenvInt64Ptr("SEED"),
+		Scheduler: SchedulerConfig{
+			ReminderInterval:      getenvDuration("REMINDER_INTERVAL", defaultReminderInterval),
+			ArchiveInterval:       getenvDuration("ARCHIVE_INTERVAL", defaultArchiveInterval),
+			ArchiveAfterDays:      getenvInt("ARCHIVE_AFTER_DAYS", defaultArchiveAfterDays),
+			UnderassignedInterval: getenvDuration("UNDERASSIGNED_INTERVAL", defaultUnderassignedInterval),
+			DeferOffHours:         getenvBool("DEFER_OFF_HOURS_ASSIGNMENTS", false),
+			DeferredInterval:      getenvDuration("DEFERRED_ASSIGNMENT_INTERVAL", defaultDeferredInterval),
+			PendingInterval:       getenvDuration("PENDING_ASSIGNMENT_INTERVAL", defaultPendingInterval),
+			AcceptanceInterval:    getenvDuration("ACCEPTANCE_INTERVAL", defaultAcceptanceInterval),
+			DailyStatsInterval:    getenvDuration("DAILY_STATS_INTERVAL", defaultDailyStatsInterval),
+			DigestInterval:        getenvDuration("NOTIFY_DIGEST_INTERVAL", defaultDigestInterval),
+			OrphanInterval:        getenvDuration("ORPHAN_CLEANUP_INTERVAL", defaultOrphanInterval),
+			OrphanRepair:          getenvBool("ORPHAN_CLEANUP_REPAIR", false),
+		},
+		Directory: DirectoryConfig{
+			Type: getenvDefault("DIRECTORY_TYPE", defaultDirectoryType),
+			LDAP: LDAPConfig{
+				URL:           os.Getenv("LDAP_URL"),
+				BindDN:        os.Getenv("LDAP_BIND_DN"),
+				BindPassword:  secrets.get("LDAP_BIND_PASSWORD", ""),
+				BaseDN:        os.Getenv("LDAP_BASE_DN"),
+				UserAttribute: getenvDefault("LDAP_USER_ATTRIBUTE", defaultLDAPUserAttribute),
+				Timeout:       getenvDuration("LDAP_TIMEOUT", defaultLDAPTimeout),
+			},
+		},
+		Webhook: WebhookConfig{
+			MaxAttempts:  getenvInt("WEBHOOK_MAX_ATTEMPTS", defaultWebhookMaxAttempts),
+			RetryBackoff: getenvDuration("WEBHOOK_RETRY_BACKOFF", defaultWebhookRetryBackoff),
+			Timeout:      getenvDuration("WEBHOOK_TIMEOUT", defaultWebhookTimeout),
+			Workers:      getenvInt("WEBHOOK_WORKERS", defaultWebhookWorkers),
+			PollInterval: getenvDuration("WEBHOOK_POLL_INTERVAL", defaultWebhookPollInterval),
+			Lease:        getenvDuration("WEBHOOK_LEASE", defaultWebhookLease),
+			BaseURL:      os.Getenv("BASE_URL"),
+		},
+		Notify: NotifyConfig{
+			SlackWebhookURL: secrets.get("NOTIFY_SLACK_WEBHOOK_URL", ""),
+			SMTPAddr:        os.Getenv("NOTIFY_SMTP_ADDR"),
+			SMTPFrom:        os.Getenv("NOTIFY_SMTP_FROM"),
+			SMTPUsername:    os.Getenv("NOTIFY_SMTP_USERNAME"),
+			SMTPPassword:    secrets.get("NOTIFY_SMTP_PASSWORD", ""),
+			Timeout:         getenvDuration("NOTIFY_TIMEOUT", defaultNotifyTimeout),
+			Workers:         getenvInt("NOTIFY_WORKERS", defaultNotifyWorkers),
+		},
+		Export: ExportConfig{
+			Endpoint:  os.Getenv("EXPORT_S3_ENDPOINT"),
+			Bucket:    os.Getenv("EXPORT_S3_BUCKET"),
+			Region:    getenvDefault("EXPORT_S3_REGION", defaultExportRegion),
+			AccessKey: secrets.get("EXPORT_S3_ACCESS_KEY", ""),
+			SecretKey: secrets.get("EXPORT_S3_SECRET_KEY", ""),
+			Prefix:    getenvDefault("EXPORT_S3_PREFIX", defaultExportPrefix),
+			Interval:  getenvDuration("EXPORT_INTERVAL", defaultExportInterval),
+			MinAge:    getenvDuration("EXPORT_MIN_AGE", defaultExportMinAge),
+			BatchSize: getenvInt("EXPORT_BATCH_SIZE", defaultExportBatchSize),
+			Timeout:   getenvDuration("EXPORT_TIMEOUT", defaultExportTimeout),
+		},
+		WriteQueue: WriteQueueConfig{
+			Path:           os.Getenv("WRITE_QUEUE_PATH"),
+			MaxEntries:     getenvInt("WRITE_QUEUE_MAX_ENTRIES", defaultWriteQueueMaxEntries),
+			Window:         getenvDuration("WRITE_QUEUE_WINDOW", defaultWriteQueueWindow),
+			ReplayInterval: getenvDuration("WRITE_QUEUE_REPLAY_INTERVAL", defaultWriteQueueReplayInterval),
+		},
+		BotTeams: getenvMap("BOT_AUTHOR_TEAMS"),
+		Quotas: QuotaConfig{
+			TeamMembersSoft:      getenvInt("QUOTA_TEAM_MEMBERS_SOFT", 0),
+			TeamMembersHard:      getenvInt("QUOTA_TEAM_MEMBERS_HARD", 0),
+			OpenPullRequestsSoft: getenvInt("QUOTA_OPEN_PULL_REQUESTS_SOFT", 0),
+			OpenPullRequestsHard: getenvInt("QUOTA_OPEN_PULL_REQUESTS_HARD", 0),
+			ReviewersSoft:        getenvInt("QUOTA_REVIEWERS_SOFT", 0),
+			ReviewersHard:        getenvInt("QUOTA_REVIEWERS_HARD", 0),
 		},
 	}
+	if secrets.err != nil {
+		return Config{}, secrets.err
+	}
+	return cfg, nil
 }
 
 func getenvDefault(key, def string) string {
@@ -89,3 +564,96 @@ func getenvInt(key string, def int) int {
 	}
 	return i
 }
+
+// getenvInt64Ptr returns nil when the variable is unset or malformed.
+func getenvInt64Ptr(key string) *int64 {
+	i, err := strconv.ParseInt(os.Getenv(key), 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &i
+}
+
+func getenvFloat(key string, def float64) float64 {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
+
+func getenvBool(key string, def bool) bool {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// getenvMap parses comma-separated key=value pairs, dropping items without a
+// key or a value.
+func getenvMap(key string) map[string]string {
+	pairs := make(map[string]string)
+	for _, item := range getenvList(key, "") {
+		k, v, ok := strings.Cut(item, "=")
+		if k, v = strings.TrimSpace(k), strings.TrimSpace(v); ok && k != "" && v != "" {
+			pairs[k] = v
+		}
+	}
+	return pairs
+}
+
+// getenvChaosRoutes parses comma-separated path=latency:error_rate rules,
+// such as /pullRequest/create=200ms:0.1. Either part may be left out, as in
+// /team/get=1s or /users/getReview=:0.5; malformed rules are dropped.
+func getenvChaosRoutes(key string) map[string]ChaosRule {
+	rules := make(map[string]ChaosRule)
+	for path, spec := range getenvMap(key) {
+		latency, rate, _ := strings.Cut(spec, ":")
+		var rule ChaosRule
+		var err error
+		if latency != "" {
+			if rule.Latency, err = time.ParseDuration(latency); err != nil {
+				continue
+			}
+		}
+		if rate != "" {
+			if rule.ErrorRate, err = strconv.ParseFloat(rate, 64); err != nil {
+				continue
+			}
+		}
+		rules[path] = rule
+	}
+	return rules
+}
+
+// getenvList splits a comma-separated value, dropping blank items.
+func getenvList(key, def string) []string {
+	var items []string
+	for _, item := range strings.Split(getenvDefault(key, def), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getenvDuration(key string, def time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadReadsPoolTuning(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("DB_MAX_CONNS", "12")
+	t.Setenv("DB_MIN_CONNS", "3")
+	t.Setenv("DB_MAX_CONN_LIFETIME", "1h")
+	t.Setenv("DB_MAX_CONN_IDLE_TIME", "30m")
+	// A malformed duration falls back to the pgxpool default.
+	t.Setenv("DB_HEALTH_CHECK_PERIOD", "often")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	pg := cfg.Storage.Postgres
+	if pg.MaxConns != 12 || pg.MinConns != 3 {
+		t.Errorf("expected 3 to 12 connections, got %d to %d", pg.MinConns, pg.MaxConns)
+	}
+	if pg.MaxConnLifetime != time.Hour || pg.MaxConnIdleTime != 30*time.Minute {
+		t.Errorf("expected lifetime 1h and idle time 30m, got %v and %v", pg.MaxConnLifetime, pg.MaxConnIdleTime)
+	}
+	if pg.HealthCheckPeriod != 0 {
+		t.Errorf("expected no health check period, got %v", pg.HealthCheckPeriod)
+	}
+}
+
+func TestLoadLeavesOrphanRepairOff(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("ORPHAN_CLEANUP_INTERVAL", "")
+	t.Setenv("ORPHAN_CLEANUP_REPAIR", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Scheduler.OrphanRepair {
+		t.Error("expected orphan repair to be opt-in")
+	}
+	if cfg.Scheduler.OrphanInterval == 0 {
+		t.Error("expected the orphan count to run by default")
+	}
+}
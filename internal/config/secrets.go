@@ -0,0 +1,162 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultVaultAuthPath  = "kubernetes"
+	defaultVaultJWTFile   = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	defaultVaultTimeout   = 5 * time.Second
+	maxVaultResponseBytes = 1 << 20
+)
+
+// secretSource reads secrets such as DB_PASSWORD. A secret is taken, in this
+// order, from its variable, from the file named by the variable with a _FILE
+// suffix, as mounted by Docker and Kubernetes secrets, and from Vault. Errors
+// are collected rather than returned one by one so that Load reports every
+// broken secret at once; they never include the secret values.
+type secretSource struct {
+	vault map[string]string
+	err   error
+}
+
+func (s *secretSource) get(key, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	if path := os.Getenv(key + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			s.err = errors.Join(s.err, fmt.Errorf("read %s_FILE: %w", key, err))
+			return ""
+		}
+		// Files written by editors and echo end with a newline that is not
+		// part of the secret.
+		return strings.TrimRight(string(data), "\r\n")
+	}
+	if val, ok := s.vault[key]; ok {
+		return val
+	}
+	return def
+}
+
+// vaultConfig points at the Vault KV secret holding the service's secrets,
+// keyed by their variable names, e.g. DB_PASSWORD. Vault is not used while
+// Addr or SecretPath is empty.
+type vaultConfig struct {
+	Addr string
+	// SecretPath is the API path of the secret under /v1, such as
+	// secret/data/reviewer for a KV version 2 engine mounted at secret.
+	SecretPath string
+	// Token authenticates directly. Without it the service logs in with the
+	// Kubernetes service account token from JWTFile under Role, through the
+	// auth method mounted at AuthPath.
+	Token    string
+	Role     string
+	AuthPath string
+	JWTFile  string
+	Timeout  time.Duration
+}
+
+func loadVaultConfig() vaultConfig {
+	return vaultConfig{
+		Addr:       strings.TrimRight(os.Getenv("VAULT_ADDR"), "/"),
+		SecretPath: strings.Trim(os.Getenv("VAULT_SECRET_PATH"), "/"),
+		Token:      os.Getenv("VAULT_TOKEN"),
+		Role:       os.Getenv("VAULT_ROLE"),
+		AuthPath:   strings.Trim(getenvDefault("VAULT_AUTH_PATH", defaultVaultAuthPath), "/"),
+		JWTFile:    getenvDefault("VAULT_JWT_FILE", defaultVaultJWTFile),
+		Timeout:    getenvDuration("VAULT_TIMEOUT", defaultVaultTimeout),
+	}
+}
+
+func (v vaultConfig) enabled() bool {
+	return v.Addr != "" && v.SecretPath != ""
+}
+
+// readVaultSecrets logs in to Vault when needed and reads the string values
+// of the configured secret.
+func readVaultSecrets(cfg vaultConfig) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	client := &http.Client{}
+
+	token := cfg.Token
+	if token == "" {
+		if cfg.Role == "" {
+			return nil, errors.New("vault: VAULT_TOKEN or VAULT_ROLE is required")
+		}
+		jwt, err := os.ReadFile(cfg.JWTFile)
+		if err != nil {
+			return nil, fmt.Errorf("vault: read service account token: %w", err)
+		}
+		body, err := json.Marshal(map[string]string{"role": cfg.Role, "jwt": strings.TrimSpace(string(jwt))})
+		if err != nil {
+			return nil, err
+		}
+		var login struct {
+			Auth struct {
+				ClientToken string `json:"client_token"`
+			} `json:"auth"`
+		}
+		url := cfg.Addr + "/v1/auth/" + cfg.AuthPath + "/login"
+		if err := vaultRequest(ctx, client, http.MethodPost, url, "", body, &login); err != nil {
+			return nil, fmt.Errorf("vault: login as %s: %w", cfg.Role, err)
+		}
+		token = login.Auth.ClientToken
+	}
+
+	// KV version 2 nests the values under data.data, version 1 under data.
+	var secret struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := vaultRequest(ctx, client, http.MethodGet, cfg.Addr+"/v1/"+cfg.SecretPath, token, nil, &secret); err != nil {
+		return nil, fmt.Errorf("vault: read %s: %w", cfg.SecretPath, err)
+	}
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]any); ok {
+		data = nested
+	}
+	values := make(map[string]string, len(data))
+	for key, value := range data {
+		if s, ok := value.(string); ok {
+			values[key] = s
+		}
+	}
+	return values, nil
+}
+
+// vaultRequest calls the Vault API and decodes its answer into out. Failures
+// report the status only: Vault's error bodies are of no use at startup and
+// the request may carry credentials.
+func vaultRequest(ctx context.Context, client *http.Client, method, url, token string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxVaultResponseBytes)).Decode(out)
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecretSourceLookupOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("from-file\r\n"), 0o600); err != nil {
+		t.Fatalf("write secret file: %v", err)
+	}
+	vault := map[string]string{"TEST_SECRET": "from-vault"}
+
+	tests := []struct {
+		name  string
+		env   string
+		file  string
+		vault map[string]string
+		want  string
+	}{
+		{name: "default", want: "fallback"},
+		{name: "vault", vault: vault, want: "from-vault"},
+		{name: "file before vault", file: path, vault: vault, want: "from-file"},
+		{name: "variable before file", env: "from-env", file: path, vault: vault, want: "from-env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_SECRET", tt.env)
+			t.Setenv("TEST_SECRET_FILE", tt.file)
+
+			source := &secretSource{vault: tt.vault}
+			if got := source.get("TEST_SECRET", "fallback"); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+			if source.err != nil {
+				t.Errorf("unexpected error: %v", source.err)
+			}
+		})
+	}
+}
+
+func TestSecretSourceCollectsUnreadableFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("FIRST_SECRET", "")
+	t.Setenv("FIRST_SECRET_FILE", filepath.Join(dir, "missing-first"))
+	t.Setenv("SECOND_SECRET", "")
+	t.Setenv("SECOND_SECRET_FILE", filepath.Join(dir, "missing-second"))
+
+	// A broken file is not papered over by Vault or the default.
+	source := &secretSource{vault: map[string]string{"FIRST_SECRET": "from-vault"}}
+	if got := source.get("FIRST_SECRET", "fallback"); got != "" {
+		t.Errorf("expected no value for an unreadable file, got %q", got)
+	}
+	source.get("SECOND_SECRET", "fallback")
+
+	if source.err == nil {
+		t.Fatal("expected the unreadable files to be reported")
+	}
+	for _, key := range []string{"FIRST_SECRET_FILE", "SECOND_SECRET_FILE"} {
+		if !strings.Contains(source.err.Error(), key) {
+			t.Errorf("expected the error to name %s, got %v", key, source.err)
+		}
+	}
+}
+
+// fakeVault serves the KV secrets at their API paths and a Kubernetes login
+// for role "reviewer" with JWT "service-account", answering 403 to anything
+// else.
+func fakeVault(t *testing.T, secrets map[string]any) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/v1/auth/kubernetes/login" {
+			var login struct {
+				Role string `json:"role"`
+				JWT  string `json:"jwt"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&login); err != nil || login.Role != "reviewer" || login.JWT != "service-account" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{"client_token": "login-token"}})
+			return
+		}
+		secret, ok := secrets[r.URL.Path]
+		token := r.Header.Get("X-Vault-Token")
+		if r.Method != http.MethodGet || !ok || (token != "root-token" && token != "login-token") {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(secret)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestReadVaultSecretsParsesKVVersions(t *testing.T) {
+	server := fakeVault(t, map[string]any{
+		"/v1/kv/reviewer": map[string]any{
+			"data": map[string]any{"DB_PASSWORD": "v1-password", "DB_MAX_CONNS": 10},
+		},
+		"/v1/secret/data/reviewer": map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"DB_PASSWORD": "v2-password", "ENABLED": true},
+				"metadata": map[string]any{"version": 3},
+			},
+		},
+	})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "kv/reviewer", want: "v1-password"},
+		{path: "secret/data/reviewer", want: "v2-password"},
+	}
+	for _, tt := range tests {
+		values, err := readVaultSecrets(vaultConfig{Addr: server.URL, SecretPath: tt.path, Token: "root-token", Timeout: time.Second})
+		if err != nil {
+			t.Fatalf("read %s: %v", tt.path, err)
+		}
+		// Values that are not strings are left out.
+		if len(values) != 1 || values["DB_PASSWORD"] != tt.want {
+			t.Errorf("read %s: expected only DB_PASSWORD=%q, got %v", tt.path, tt.want, values)
+		}
+	}
+}
+
+func TestReadVaultSecretsLogsInWithServiceAccount(t *testing.T) {
+	server := fakeVault(t, map[string]any{
+		"/v1/secret/data/reviewer": map[string]any{
+			"data": map[string]any{"data": map[string]any{"ADMIN_TOKEN": "admin"}},
+		},
+	})
+	jwtFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(jwtFile, []byte("service-account\n"), 0o600); err != nil {
+		t.Fatalf("write jwt: %v", err)
+	}
+
+	cfg := vaultConfig{
+		Addr:       server.URL,
+		SecretPath: "secret/data/reviewer",
+		Role:       "reviewer",
+		AuthPath:   defaultVaultAuthPath,
+		JWTFile:    jwtFile,
+		Timeout:    time.Second,
+	}
+	values, err := readVaultSecrets(cfg)
+	if err != nil {
+		t.Fatalf("read secrets: %v", err)
+	}
+	if values["ADMIN_TOKEN"] != "admin" {
+		t.Errorf("expected ADMIN_TOKEN from the secret, got %v", values)
+	}
+
+	cfg.Role = "intruder"
+	if _, err := readVaultSecrets(cfg); err == nil || !strings.Contains(err.Error(), "login as intruder") {
+		t.Errorf("expected a refused login, got %v", err)
+	}
+}
+
+func TestReadVaultSecretsHidesErrorBodies(t *testing.T) {
+	server := fakeVault(t, nil)
+
+	_, err := readVaultSecrets(vaultConfig{Addr: server.URL, SecretPath: "secret/data/reviewer", Token: "root-token", Timeout: time.Second})
+	if err == nil {
+		t.Fatal("expected a missing secret to fail")
+	}
+	if !strings.Contains(err.Error(), "403") || strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("expected only the status in the error, got %v", err)
+	}
+}
+
+func TestLoadTakesSecretsFromVault(t *testing.T) {
+	server := fakeVault(t, map[string]any{
+		"/v1/secret/data/reviewer": map[string]any{
+			"data": map[string]any{"data": map[string]any{"DB_PASSWORD": "vault-password", "ADMIN_TOKEN": "vault-admin"}},
+		},
+	})
+	t.Setenv("VAULT_ADDR", server.URL+"/")
+	t.Setenv("VAULT_SECRET_PATH", "/secret/data/reviewer")
+	t.Setenv("VAULT_TOKEN", "root-token")
+	t.Setenv("DB_PASSWORD", "")
+	t.Setenv("DB_PASSWORD_FILE", "")
+	t.Setenv("ADMIN_TOKEN", "env-admin")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Storage.Postgres.Password != "vault-password" {
+		t.Errorf("expected the password from Vault, got %q", cfg.Storage.Postgres.Password)
+	}
+	if cfg.HTTP.AdminToken != "env-admin" {
+		t.Errorf("expected the variable to win over Vault, got %q", cfg.HTTP.AdminToken)
+	}
+}
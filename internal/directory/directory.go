@@ -0,0 +1,17 @@
+package directory
+
+import "context"
+
+// Directory is an external source of truth for user accounts. It is consulted
+// when teams are added so that mistyped IDs do not create phantom reviewers.
+type Directory interface {
+	// Missing returns the user IDs that are unknown to the directory.
+	Missing(ctx context.Context, userIDs []string) ([]string, error)
+}
+
+// Nop accepts every user ID. It is used when no directory is configured.
+type Nop struct{}
+
+func (Nop) Missing(context.Context, []string) ([]string, error) {
+	return nil, nil
+}
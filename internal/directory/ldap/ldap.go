@@ -0,0 +1,113 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"Avito2025/internal/config"
+	"Avito2025/internal/directory"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+var _ directory.Directory = (*Directory)(nil)
+
+// searchBatch bounds the number of user IDs matched by a single search filter.
+const searchBatch = 100
+
+// Directory looks users up in an LDAP server. A connection is opened per call,
+// since teams are added rarely.
+type Directory struct {
+	cfg config.LDAPConfig
+}
+
+func New(cfg config.LDAPConfig) (*Directory, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("ldap url is required")
+	}
+	if cfg.BaseDN == "" {
+		return nil, fmt.Errorf("ldap base dn is required")
+	}
+	if cfg.UserAttribute == "" {
+		return nil, fmt.Errorf("ldap user attribute is required")
+	}
+	return &Directory{cfg: cfg}, nil
+}
+
+func (d *Directory) Missing(ctx context.Context, userIDs []string) ([]string, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	timeout := d.cfg.Timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); timeout <= 0 || remaining < timeout {
+			timeout = remaining
+		}
+	}
+	conn, err := goldap.DialURL(d.cfg.URL, goldap.DialWithDialer(&net.Dialer{Timeout: timeout}))
+	if err != nil {
+		return nil, fmt.Errorf("connect ldap: %w", err)
+	}
+	defer conn.Close()
+	if timeout > 0 {
+		conn.SetTimeout(timeout)
+	}
+
+	if d.cfg.BindDN != "" {
+		if err := conn.Bind(d.cfg.BindDN, d.cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("bind ldap: %w", err)
+		}
+	}
+
+	found := make(map[string]bool, len(userIDs))
+	for start := 0; start < len(userIDs); start += searchBatch {
+		end := min(start+searchBatch, len(userIDs))
+		if err := d.search(conn, userIDs[start:end], found); err != nil {
+			return nil, err
+		}
+	}
+
+	var missing []string
+	for _, userID := range userIDs {
+		if !found[strings.ToLower(userID)] {
+			missing = append(missing, userID)
+		}
+	}
+	return missing, nil
+}
+
+// search marks the given user IDs present in the directory in found, keyed in
+// lower case since LDAP attribute matching is usually case-insensitive.
+func (d *Directory) search(conn *goldap.Conn, userIDs []string, found map[string]bool) error {
+	var filter strings.Builder
+	filter.WriteString("(|")
+	for _, userID := range userIDs {
+		fmt.Fprintf(&filter, "(%s=%s)", d.cfg.UserAttribute, goldap.EscapeFilter(userID))
+	}
+	filter.WriteString(")")
+
+	result, err := conn.Search(goldap.NewSearchRequest(
+		d.cfg.BaseDN,
+		goldap.ScopeWholeSubtree,
+		goldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		filter.String(),
+		[]string{d.cfg.UserAttribute},
+		nil,
+	))
+	if err != nil {
+		return fmt.Errorf("search ldap: %w", err)
+	}
+	for _, entry := range result.Entries {
+		for _, value := range entry.GetAttributeValues(d.cfg.UserAttribute) {
+			found[strings.ToLower(value)] = true
+		}
+	}
+	return nil
+}
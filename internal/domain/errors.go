@@ -1,14 +1,138 @@
 package domain
 
-import "errors"
+import "fmt"
+
+// Kind classifies a domain error. Transports map kinds onto their own status
+// codes, e.g. KindNotFound onto HTTP 404.
+type Kind int
+
+const (
+	KindInvalid Kind = iota + 1
+	KindNotFound
+	KindConflict
+	KindUnavailable
+	KindForbidden
+	// KindUnprocessable rejects a well-formed request the service refuses to
+	// carry out, such as one going over a hard quota.
+	KindUnprocessable
+)
+
+// Error is a domain failure with a stable machine-readable code and a message
+// safe to show to clients. Errors keep their identity when wrapped, so callers
+// match them with errors.Is against the values below or errors.As for the
+// code and kind.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	// Details carries structured data about the failure, e.g. the quota that
+	// was exceeded. Nil for most errors.
+	Details map[string]any
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func NewInvalid(code, message string) *Error {
+	return &Error{Kind: KindInvalid, Code: code, Message: message}
+}
+
+func NewNotFound(message string) *Error {
+	return &Error{Kind: KindNotFound, Code: "NOT_FOUND", Message: message}
+}
+
+func NewConflict(code, message string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+func NewUnavailable(code, message string) *Error {
+	return &Error{Kind: KindUnavailable, Code: code, Message: message}
+}
+
+func NewForbidden(code, message string) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+// NewQuotaExceeded reports that a request would take count over the hard
+// limit of the named quota.
+func NewQuotaExceeded(quota string, limit, count int) *Error {
+	return &Error{
+		Kind:    KindUnprocessable,
+		Code:    "QUOTA_EXCEEDED",
+		Message: fmt.Sprintf("%s quota exceeded: %d is over the limit of %d", quota, count, limit),
+		Details: map[string]any{"quota": quota, "limit": limit, "count": count},
+	}
+}
+
+// NewPRBlocked reports that a pull request cannot be merged before its
+// blockers.
+func NewPRBlocked(blockers []string) *Error {
+	return &Error{
+		Kind:    KindConflict,
+		Code:    "PR_BLOCKED",
+		Message: "pull request is blocked by unmerged pull requests",
+		Details: map[string]any{"blocked_by": blockers},
+	}
+}
+
+// NewInvalidSnapshot reports every problem that keeps a snapshot from being
+// imported.
+func NewInvalidSnapshot(problems []string) *Error {
+	return &Error{
+		Kind:    KindInvalid,
+		Code:    "INVALID_SNAPSHOT",
+		Message: "snapshot is inconsistent",
+		Details: map[string]any{"problems": problems},
+	}
+}
 
 var (
-	ErrTeamExists          = errors.New("team already exists")
-	ErrPRExists            = errors.New("pull request already exists")
-	ErrPRMerged            = errors.New("pull request already merged")
-	ErrReviewerNotFound    = errors.New("reviewer is not assigned to this PR")
-	ErrNoReplacement       = errors.New("no replacement candidate available")
-	ErrTeamNotFound        = errors.New("team not found")
-	ErrUserNotFound        = errors.New("user not found")
-	ErrPullRequestNotFound = errors.New("pull request not found")
+	ErrTeamExists          = NewInvalid("TEAM_EXISTS", "team_name already exists")
+	ErrPRExists            = NewConflict("PR_EXISTS", "pull request already exists")
+	ErrPRMerged            = NewConflict("PR_MERGED", "cannot modify merged pull request")
+	ErrPRDraft             = NewConflict("PR_DRAFT", "pull request is a draft")
+	ErrReviewerNotFound    = NewConflict("NOT_ASSIGNED", "reviewer is not assigned to this pull request")
+	ErrNoReplacement       = NewConflict("NO_CANDIDATE", "no active replacement candidate in team")
+	ErrReassignConflict    = NewConflict("REASSIGN_CONFLICT", "pull request changed concurrently, retry the reassignment")
+	ErrRotationConflict    = NewConflict("ROTATION_CONFLICT", "team rotation moved concurrently, retry the request")
+	ErrAlreadyAssigned     = NewConflict("ALREADY_ASSIGNED", "user is already a reviewer of this pull request")
+	ErrTeamNotFound        = NewNotFound("team not found")
+	ErrUserNotFound        = NewNotFound("user not found")
+	ErrPullRequestNotFound = NewNotFound("pull request not found")
+	ErrIdentityExists      = NewConflict("IDENTITY_EXISTS", "external identity is already mapped")
+	ErrIdentityNotFound    = NewNotFound("identity not found")
+	ErrInvalidIdentity     = NewInvalid("INVALID_IDENTITY", "identity needs a provider of github, gitlab or email and a non-blank external_id")
+	ErrRotationNotFound    = NewNotFound("rotation not found")
+	ErrInvalidRotation     = NewInvalid("INVALID_ROTATION", "rotation members must belong to the team")
+	ErrInvalidOwnership    = NewInvalid("INVALID_OWNERSHIP", "ownership rule members must belong to the team")
+	ErrInvalidLead         = NewInvalid("INVALID_LEAD", "team lead must be a member of the team")
+	ErrForbidden           = NewForbidden("FORBIDDEN", "only leads of the team and admins may do this")
+	ErrInvalidReviewer     = NewInvalid("INVALID_REVIEWER", "reviewer must be an active user other than the author")
+	ErrTooManyReviewers    = NewInvalid("TOO_MANY_REVIEWERS", "too many reviewers for a pull request")
+	ErrNotEnoughReviewers  = NewConflict("NOT_ENOUGH_REVIEWERS", "not enough active reviewer candidates in team")
+	ErrInvalidSettings     = NewInvalid("INVALID_SETTINGS", "invalid team settings")
+	ErrInvalidPullRequest  = NewInvalid("INVALID_PULL_REQUEST", "invalid pull request fields")
+	ErrUnknownUser         = NewInvalid("UNKNOWN_USER", "user is not found in the directory")
+	ErrInvalidAuthorTeam   = NewInvalid("INVALID_AUTHOR_TEAM", "team_name must be the author's team")
+	ErrUserInOtherTeam     = NewConflict("USER_IN_OTHER_TEAM", "user already belongs to another team")
+	ErrStorageTimeout      = NewUnavailable("STORAGE_TIMEOUT", "storage did not respond in time")
+	ErrWebhookNotFound     = NewNotFound("webhook not found")
+	ErrInvalidTeamMerge    = NewInvalid("INVALID_TEAM_MERGE", "source and target teams must differ")
+	ErrInvalidTeamSplit    = NewInvalid("INVALID_TEAM_SPLIT", "split members must be distinct members of the source team")
+	ErrInvalidSnooze       = NewInvalid("INVALID_SNOOZE", "snooze duration must be between 0 and 30 days")
+	ErrInvalidWebhook      = NewInvalid("INVALID_WEBHOOK", "webhook needs an http(s) url and known events")
+	ErrInvalidNotification = NewInvalid("INVALID_NOTIFICATION", "channel must be none, slack or email; email needs an address")
+	ErrExtensionNotFound   = NewNotFound("deadline extension not found")
+	ErrInvalidExtension    = NewInvalid("INVALID_EXTENSION", "extension must end after now and the current deadline, within 30 days")
+	ErrExtensionDecided    = NewConflict("EXTENSION_DECIDED", "extension request is already decided")
+	ErrTeamTokenNotFound   = NewNotFound("team token not found")
+	ErrInvalidTeamToken    = NewInvalid("INVALID_TEAM_TOKEN", "team token needs a name of at most 100 bytes")
+	ErrInvalidTokenUser    = NewInvalid("INVALID_TOKEN_USER", "team token may only be issued to a member of the team")
+	ErrTokenTeamMismatch   = NewForbidden("TOKEN_TEAM_MISMATCH", "team token may only create pull requests of its team's members")
+	ErrInvalidForceMerge   = NewInvalid("INVALID_FORCE_MERGE", "force merge needs the user ID of who forces it and a reason of at most 500 bytes")
+	ErrInvalidLink         = NewInvalid("INVALID_LINK", "a pull request cannot block itself")
+	ErrDependencyCycle     = NewConflict("DEPENDENCY_CYCLE", "link would make the pull requests block each other")
+	ErrMaintenance         = NewUnavailable("MAINTENANCE", "service is in read-only maintenance mode")
+	ErrNotEmpty            = NewConflict("NOT_EMPTY", "snapshots are imported only into an instance without data")
 )
@@ -1,24 +1,163 @@
 package domain
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type PRStatus string
 
 const (
+	// StatusDraft marks a pull request that is not ready for review: it has
+	// no reviewers until it is marked ready and becomes OPEN.
+	StatusDraft  PRStatus = "DRAFT"
 	StatusOpen   PRStatus = "OPEN"
 	StatusMerged PRStatus = "MERGED"
+	StatusClosed PRStatus = "CLOSED"
+)
+
+func (s PRStatus) Valid() bool {
+	switch s {
+	case StatusDraft, StatusOpen, StatusMerged, StatusClosed:
+		return true
+	default:
+		return false
+	}
+}
+
+type Priority string
+
+const (
+	PriorityLow    Priority = "LOW"
+	PriorityNormal Priority = "NORMAL"
+	PriorityHigh   Priority = "HIGH"
 )
 
+func (p Priority) Valid() bool {
+	switch p {
+	case PriorityLow, PriorityNormal, PriorityHigh:
+		return true
+	default:
+		return false
+	}
+}
+
+type ReviewSort string
+
+const (
+	SortByCreatedAt ReviewSort = "createdAt"
+	SortByMergedAt  ReviewSort = "mergedAt"
+)
+
+// ReviewFilter narrows and orders a reviewer's pull requests. An empty Status
+// matches every status; an empty SortBy means SortByCreatedAt.
+type ReviewFilter struct {
+	Status     PRStatus
+	SortBy     ReviewSort
+	Descending bool
+	// NeedsMoreReviewers keeps only pull requests with NeedsMoreReviewers.
+	NeedsMoreReviewers bool
+	// ExcludeCompleted drops the pull requests whose review the user
+	// completed, leaving the actionable ones.
+	ExcludeCompleted bool
+	// Blocked keeps only the pull requests with unmerged blockers when true,
+	// or only those without when false. Nil keeps both.
+	Blocked *bool
+}
+
 type Team struct {
 	Name    string
 	Members []User
 }
 
+// TeamSummary is a team in the team listing. Open pull requests are counted
+// for the team their author belongs to primarily.
+type TeamSummary struct {
+	Name             string
+	Members          int
+	ActiveMembers    int
+	OpenPullRequests int
+}
+
+// User is a team member. A user may belong to several teams: TeamName is the
+// primary team, the one the user joined first, and Teams lists every team the
+// user is a member of, sorted by name.
 type User struct {
 	ID       string
 	Username string
 	TeamName string
+	Teams    []string
 	IsActive bool
+	// SnoozedUntil pauses automatic assignments without deactivating the
+	// user; nil when the user is not snoozed.
+	SnoozedUntil *time.Time
+}
+
+// UserFilter narrows a user listing. Zero fields match every user.
+type UserFilter struct {
+	// TeamName matches the members of the team, whether it is their primary
+	// team or not.
+	TeamName       string
+	IsActive       *bool
+	UsernamePrefix string
+}
+
+// UserSummary is a user in a listing together with the number of open pull
+// requests they are assigned to review.
+type UserSummary struct {
+	User
+	OpenReviews int
+}
+
+// MemberConflict is how a new team treats members who already belong to
+// another team.
+type MemberConflict int
+
+const (
+	// ConflictReject refuses the team with ErrUserInOtherTeam.
+	ConflictReject MemberConflict = iota
+	// ConflictTransfer moves the members out of their primary team into the
+	// new one, handing their open reviews for the old team over to its other
+	// members.
+	ConflictTransfer
+	// ConflictJoin adds the new team to the ones the members belong to.
+	ConflictJoin
+)
+
+// UserUpdate is a partial edit of a user. Nil fields are left unchanged; a
+// TeamName transfers the user from their primary team to the given one.
+type UserUpdate struct {
+	Username *string
+	TeamName *string
+}
+
+// ReviewHandover is an open review taken away from a user leaving a team.
+// ReplacedBy is empty when nobody could take it over and the user kept it.
+type ReviewHandover struct {
+	PullRequestID string
+	ReplacedBy    string
+}
+
+// UserResult carries the outcome of a single user in a bulk status change.
+// Reassigned lists the reviews handed over when the user was deactivated.
+type UserResult struct {
+	UserID     string
+	User       User
+	Reassigned []ReviewHandover
+	Err        error
+}
+
+// TeamSplit moves UserIDs from SourceTeam into NewTeam, a team created by the
+// split with the source team's settings.
+type TeamSplit struct {
+	SourceTeam string
+	NewTeam    string
+	UserIDs    []string
+}
+
+// Snoozed reports whether the user's snooze is still in effect at now.
+func (u User) Snoozed(now time.Time) bool {
+	return u.SnoozedUntil != nil && now.Before(*u.SnoozedUntil)
 }
 
 type PullRequest struct {
@@ -27,6 +166,953 @@ type PullRequest struct {
 	AuthorID          string
 	Status            PRStatus
 	AssignedReviewers []string
+	Labels            []string
+	URL               string
+	Priority          Priority
+	// Description is the optional free-form body of the pull request.
+	Description string
+	// ReviewersCount is the number of reviewers requested at creation; zero
+	// means the team's RequiredReviewers.
+	ReviewersCount int
+	// ShadowReviewer is a trainee from the team's shadow pool who follows
+	// the review without blocking it. Empty when the team has no pool.
+	ShadowReviewer string
+	// Files are the paths the pull request changes. They route reviews
+	// through the team's ownership rules when reviewers are picked.
+	Files []string
+	// CoAuthors are the users who helped the author write the pull request.
+	// Like the author, they are never picked as its reviewers. They are
+	// recorded once, at creation.
+	CoAuthors []string
+	// Reassignments counts how many times a reviewer of the pull request
+	// was replaced.
+	Reassignments int
+	// NeedsMoreReviewers reports an open pull request with fewer reviewers
+	// than requested at creation or, failing that, than its team requires,
+	// e.g. because the team had too few candidates. Stores set it on reads.
+	NeedsMoreReviewers bool
+	// Acceptance holds the state of the reviewers assigned while the
+	// author's team had an acceptance step; reviewers missing from it need
+	// not accept. Stores set it on reads.
+	Acceptance map[string]AcceptanceState
+	// CompletedReviewers are the assigned reviewers, sorted by ID, who
+	// marked their review done. Completing a review neither approves nor
+	// merges the pull request. Stores set it on reads.
+	CompletedReviewers []string
+	// Reviewers lists the AssignedReviewers, which are sorted by ID, in the
+	// order of their places, followed by the shadow reviewer. Reviewers keep
+	// their places until removed and a replacement takes the place of the
+	// reviewer it replaces. Stores set it on reads.
+	Reviewers []Reviewer
+	// BlockedBy are the pull requests, sorted by ID, linked as blockers of
+	// this one and not merged yet. Stores set it on reads.
+	BlockedBy []string
+	// Deadline is the latest deadline granted to the reviewers by an
+	// approved extension; escalation waits for it. Nil when no extension
+	// was approved. Stores set it on reads.
+	Deadline *time.Time
+	// TeamName, given on creation, is the team whose members review a pull
+	// request of an author unknown to the service, such as a bot. It is not
+	// stored: the author is registered as an inactive member of the team.
+	TeamName  string
+	CreatedAt time.Time
+	MergedAt  *time.Time
+}
+
+// ReviewerRole is the part a reviewer plays on a pull request. The required
+// reviewer in the first place is the primary one and the others are
+// secondary. Shadow reviewers do not count towards the required reviewers
+// and their reviews do not count as review activity.
+type ReviewerRole string
+
+const (
+	RolePrimary   ReviewerRole = "primary"
+	RoleSecondary ReviewerRole = "secondary"
+	RoleShadow    ReviewerRole = "shadow"
+)
+
+// Reviewer is a reviewer of a pull request with its role.
+type Reviewer struct {
+	UserID     string
+	Role       ReviewerRole
+	AssignedAt time.Time
+}
+
+// Authors returns the author of the pull request followed by its co-authors.
+func (pr PullRequest) Authors() []string {
+	return append([]string{pr.AuthorID}, pr.CoAuthors...)
+}
+
+// RankReviewers sets the roles of the required reviewers, given in the order
+// of their places, and appends the shadow reviewer unless its UserID is
+// empty.
+func RankReviewers(required []Reviewer, shadow Reviewer) []Reviewer {
+	reviewers := make([]Reviewer, 0, len(required)+1)
+	for i, reviewer := range required {
+		reviewer.Role = RoleSecondary
+		if i == 0 {
+			reviewer.Role = RolePrimary
+		}
+		reviewers = append(reviewers, reviewer)
+	}
+	if shadow.UserID != "" {
+		shadow.Role = RoleShadow
+		reviewers = append(reviewers, shadow)
+	}
+	return reviewers
+}
+
+// OrderedReviewers returns pr.Reviewers or, for a pull request the stores
+// have not read, such as one about to be created, the assigned reviewers in
+// their given order followed by the shadow reviewer, all assigned at
+// creation.
+func (pr PullRequest) OrderedReviewers() []Reviewer {
+	if len(pr.Reviewers) > 0 {
+		return pr.Reviewers
+	}
+	required := make([]Reviewer, 0, len(pr.AssignedReviewers))
+	for _, reviewer := range pr.AssignedReviewers {
+		required = append(required, Reviewer{UserID: reviewer, AssignedAt: pr.CreatedAt})
+	}
+	shadow := Reviewer{UserID: pr.ShadowReviewer, AssignedAt: pr.CreatedAt}
+	return RankReviewers(required, shadow)
+}
+
+// RoleOf returns the role of the user on the pull request, or an empty role
+// when they are not one of its reviewers.
+func (pr PullRequest) RoleOf(userID string) ReviewerRole {
+	for _, reviewer := range pr.OrderedReviewers() {
+		if reviewer.UserID == userID {
+			return reviewer.Role
+		}
+	}
+	return ""
+}
+
+// AcceptanceState tells whether a reviewer took the review on. A reviewer
+// whose acceptance expired stays assigned until a replacement is found.
+type AcceptanceState string
+
+const (
+	AcceptancePending  AcceptanceState = "PENDING"
+	AcceptanceAccepted AcceptanceState = "ACCEPTED"
+	AcceptanceExpired  AcceptanceState = "EXPIRED"
+)
+
+// PullRequestUpdate is a partial edit of a pull request's descriptive fields.
+// Nil fields are left unchanged.
+type PullRequestUpdate struct {
+	Name        *string
+	Labels      *[]string
+	URL         *string
+	Priority    *Priority
+	Description *string
+}
+
+// PullRequestResult carries the outcome of a single item in a batch operation.
+type PullRequestResult struct {
+	PullRequest PullRequest
+	Err         error
+}
+
+type EventType string
+
+const (
+	EventTeamCreated        EventType = "TEAM_CREATED"
+	EventTeamMerged         EventType = "TEAM_MERGED"
+	EventTeamSplit          EventType = "TEAM_SPLIT"
+	EventPRCreated          EventType = "PR_CREATED"
+	EventPRReady            EventType = "PR_READY"
+	EventReviewerReassigned EventType = "REVIEWER_REASSIGNED"
+	EventReviewersAssigned  EventType = "REVIEWERS_ASSIGNED"
+	EventPRUpdated          EventType = "PR_UPDATED"
+	EventPRMerged           EventType = "PR_MERGED"
+	EventReviewSubmitted    EventType = "REVIEW_SUBMITTED"
+	EventReviewReminder     EventType = "REVIEW_REMINDER"
+	EventReviewEscalated    EventType = "REVIEW_ESCALATED"
+	EventReviewAccepted     EventType = "REVIEW_ACCEPTED"
+	EventReviewCompleted    EventType = "REVIEW_COMPLETED"
+	// Deadline extension requests waiting for a lead, approved and
+	// rejected ones.
+	EventExtensionRequested EventType = "EXTENSION_REQUESTED"
+	EventDeadlineExtended   EventType = "DEADLINE_EXTENDED"
+	EventExtensionRejected  EventType = "EXTENSION_REJECTED"
+	// EventPRForceMerged follows PR_MERGED when an admin merged the pull
+	// request past the merge guards, naming who did it and why.
+	EventPRForceMerged EventType = "PR_FORCE_MERGED"
+)
+
+type Event struct {
+	Seq       int64
+	Type      EventType
+	TeamName  string
+	EntityID  string
+	Payload   map[string]any
+	CreatedAt time.Time
+}
+
+// WebhookEvent is the public name of an event type webhooks subscribe to.
+type WebhookEvent string
+
+const (
+	WebhookPRCreated          WebhookEvent = "pr.created"
+	WebhookPRMerged           WebhookEvent = "pr.merged"
+	WebhookReviewerReassigned WebhookEvent = "reviewer.reassigned"
+)
+
+var webhookEvents = map[EventType]WebhookEvent{
+	EventPRCreated:          WebhookPRCreated,
+	EventPRMerged:           WebhookPRMerged,
+	EventReviewerReassigned: WebhookReviewerReassigned,
+}
+
+func (e WebhookEvent) Valid() bool {
+	for _, known := range webhookEvents {
+		if e == known {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEventFor returns the webhook name of an event type; the flag is false
+// for event types that are not exposed to webhooks.
+func WebhookEventFor(eventType EventType) (WebhookEvent, bool) {
+	event, ok := webhookEvents[eventType]
+	return event, ok
+}
+
+// Webhook is a callback URL receiving the subscribed events. Payloads are
+// signed with Secret.
+type Webhook struct {
+	ID        int64
+	URL       string
+	Secret    string
+	Events    []WebhookEvent
+	CreatedAt time.Time
+}
+
+// Subscribed reports whether the webhook wants the event.
+func (w Webhook) Subscribed(event WebhookEvent) bool {
+	for _, subscribed := range w.Events {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is a single attempt to deliver an event to a webhook.
+type WebhookDelivery struct {
+	ID         int64
+	WebhookID  int64
+	EventSeq   int64
+	Event      WebhookEvent
+	Attempt    int
+	StatusCode int
+	Error      string
+	Delivered  bool
+	CreatedAt  time.Time
+}
+
+// Maintenance is the read-only mode switch shared by every replica. While it
+// is enabled, requests that change data are refused.
+type Maintenance struct {
+	Enabled   bool
+	UpdatedAt time.Time
+}
+
+// Integrity checks run against the stored data.
+const (
+	// IntegrityUnknownReviewer is a reviewer missing from the users.
+	IntegrityUnknownReviewer = "unknown_reviewer"
+	// IntegrityAuthorReviewer is the author reviewing an open pull request
+	// of a team that does not allow author reviews.
+	IntegrityAuthorReviewer = "author_reviewer"
+	// IntegrityFutureMerge is a merge time later than the check.
+	IntegrityFutureMerge = "future_merged_at"
+	// IntegrityMissingTeam is a user whose primary team does not exist.
+	IntegrityMissingTeam = "missing_team"
+	// IntegrityOrphanedCompletion is a completed review of a reviewer no
+	// longer assigned to the pull request.
+	IntegrityOrphanedCompletion = "orphaned_completion"
+	// IntegrityOrphanedAcceptance is a pending acceptance of a reviewer no
+	// longer assigned to the pull request.
+	IntegrityOrphanedAcceptance = "orphaned_acceptance"
+)
+
+// OrphanChecks are the integrity checks for the reviewer state left behind
+// when a reviewer is removed but the cleanup that follows does not run.
+var OrphanChecks = []string{IntegrityOrphanedCompletion, IntegrityOrphanedAcceptance}
+
+// IntegrityIssue is one inconsistency found by an integrity check. Only the
+// fields relevant to the check are set.
+type IntegrityIssue struct {
+	Check         string
+	PullRequestID string
+	UserID        string
+	TeamName      string
+	// Fixed reports that the issue was repaired by the same run.
+	Fixed bool
+}
+
+// IntegrityReport is the outcome of an integrity check run.
+type IntegrityReport struct {
+	CheckedAt time.Time
+	Issues    []IntegrityIssue
+}
+
+// Snapshot is the state export and import move between instances: the teams,
+// the users with their memberships, the settings teams saved and the pull
+// requests not archived yet with their reviewers. History, events and review
+// progress stay behind.
+type Snapshot struct {
+	ExportedAt time.Time
+	Teams      []string
+	Users      []User
+	// Settings holds only the teams that saved settings; the others keep
+	// the defaults.
+	Settings     []TeamSettings
+	PullRequests []PullRequest
+}
+
+// ImportResult counts what an import created or, in a dry run, would create.
+type ImportResult struct {
+	DryRun       bool
+	Teams        int
+	Users        int
+	PullRequests int
+}
+
+type IdentityProvider string
+
+const (
+	ProviderGitHub IdentityProvider = "github"
+	ProviderGitLab IdentityProvider = "gitlab"
+	ProviderEmail  IdentityProvider = "email"
+)
+
+func (p IdentityProvider) Valid() bool {
+	switch p {
+	case ProviderGitHub, ProviderGitLab, ProviderEmail:
+		return true
+	default:
+		return false
+	}
+}
+
+type Identity struct {
+	Provider   IdentityProvider
+	ExternalID string
+	UserID     string
+	CreatedAt  time.Time
+}
+
+// NotifyChannel is how a user wants to hear about their own pull requests.
+type NotifyChannel string
+
+const (
+	NotifyNone  NotifyChannel = "none"
+	NotifySlack NotifyChannel = "slack"
+	NotifyEmail NotifyChannel = "email"
+)
+
+func (c NotifyChannel) Valid() bool {
+	switch c {
+	case NotifyNone, NotifySlack, NotifyEmail:
+		return true
+	default:
+		return false
+	}
+}
+
+// NotificationPreference tells where notifications for a PR author go.
+// Address is the Slack member ID or the email address; for Slack it may be
+// empty, in which case the message names the user instead of mentioning them.
+//
+// A positive Digest folds the user's notifications into one summary sent at
+// most that often. DigestSeq is the last event a digest covered and
+// DigestSentAt when the last one was sent; both are kept by the storage.
+type NotificationPreference struct {
+	UserID       string
+	Channel      NotifyChannel
+	Address      string
+	Digest       time.Duration
+	DigestSeq    int64
+	DigestSentAt time.Time
+}
+
+type AssignmentCount struct {
+	UserID   string
+	Username string
+	IsActive bool
+	// ActiveInPeriod reports whether the member was an active member of the
+	// team for any part of the report's period.
+	ActiveInPeriod bool
+	Count          int
+}
+
+type AssignmentBucket struct {
+	Start time.Time
+	Count int
+}
+
+type FairnessReport struct {
+	TeamName    string
+	Since       time.Time
+	Total       int
+	Members     []AssignmentCount
+	Buckets     []AssignmentBucket
+	Gini        float64
+	MaxMinRatio *float64
+}
+
+// UnderassignedPullRequest is an open pull request with fewer reviewers than
+// its team requires.
+type UnderassignedPullRequest struct {
+	PullRequestID string
+	TeamName      string
+	Assigned      int
+	Required      int
+	CreatedAt     time.Time
+}
+
+// HotPullRequest is an open pull request whose reviewers keep getting
+// replaced, which usually means nobody on the team wants to review it.
+type HotPullRequest struct {
+	PullRequestID     string
+	Name              string
+	AuthorID          string
+	TeamName          string
+	Reassignments     int
+	AssignedReviewers []string
 	CreatedAt         time.Time
-	MergedAt          *time.Time
+}
+
+// ReviewKind is the kind of review activity recorded against a pull request.
+type ReviewKind string
+
+const (
+	ReviewComment  ReviewKind = "COMMENT"
+	ReviewApproval ReviewKind = "APPROVAL"
+)
+
+func (k ReviewKind) Valid() bool {
+	switch k {
+	case ReviewComment, ReviewApproval:
+		return true
+	default:
+		return false
+	}
+}
+
+// ReviewLatency is the time from a pull request's creation to its first
+// review activity of any kind.
+type ReviewLatency struct {
+	PullRequestID string
+	CreatedAt     time.Time
+	FirstReviewAt time.Time
+}
+
+type TimeToReviewWeek struct {
+	Start  time.Time
+	Count  int
+	Median time.Duration
+	P90    time.Duration
+}
+
+// DailyReviewStats aggregates a user's review activity over one UTC day. Rows
+// are filed under the user's primary team at the time they were built.
+type DailyReviewStats struct {
+	Day      time.Time
+	TeamName string
+	UserID   string
+	// Created and Merged count pull requests authored by the user.
+	Created int
+	Merged  int
+	// Reassignments counts reviews taken away from the user by a
+	// reassignment.
+	Reassignments int
+	// AvgTimeToMerge is averaged over Merged; zero when nothing was merged.
+	AvgTimeToMerge time.Duration
+}
+
+type TimeToReviewReport struct {
+	TeamName string
+	Since    time.Time
+	Weeks    []TimeToReviewWeek
+}
+
+// WeekCount counts events in the ISO week starting at Start, midnight UTC of
+// a Monday.
+type WeekCount struct {
+	Start time.Time
+	Count int
+}
+
+// WeekStart returns midnight UTC of the Monday starting t's week.
+func WeekStart(t time.Time) time.Time {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -offset)
+}
+
+// TeamStats sums up the pull requests of a team's members. OpenPullRequests
+// counts those open now; PullRequests those created since Since, drafts
+// aside, over which AvgReviewers and ReassignmentRate, the share reassigned
+// at least once, are taken. BusiestReviewer is the member assigned to the
+// most pull requests created since Since, ties going to the lowest ID; it is
+// empty when nobody was.
+type TeamStats struct {
+	TeamName                   string
+	Since                      time.Time
+	OpenPullRequests           int
+	PullRequests               int
+	AvgReviewers               float64
+	Reassigned                 int
+	ReassignmentRate           float64
+	BusiestReviewer            string
+	BusiestReviewerAssignments int
+	MergesPerWeek              []WeekCount
+}
+
+// Rotation is an ordered list of a team's reviewers. Position is the index of
+// the member who is next in line.
+type Rotation struct {
+	TeamName string
+	UserIDs  []string
+	Position int
+}
+
+// Ownership is a team's ordered list of ownership rules, in the spirit of a
+// CODEOWNERS file.
+type Ownership struct {
+	TeamName string
+	Rules    []OwnershipRule
+}
+
+// OwnershipRule asks for one reviewer out of UserIDs whenever a pull request
+// changes a file under PathPrefix.
+type OwnershipRule struct {
+	PathPrefix string
+	UserIDs    []string
+}
+
+// Matches reports whether any of files lies under the rule's prefix.
+func (r OwnershipRule) Matches(files []string) bool {
+	for _, file := range files {
+		if strings.HasPrefix(file, r.PathPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type ReviewerAction string
+
+const (
+	ReviewerAssigned   ReviewerAction = "ASSIGNED"
+	ReviewerUnassigned ReviewerAction = "UNASSIGNED"
+)
+
+// Reasons recorded in reviewer history.
+const (
+	ReasonAuto     = "auto"
+	ReasonReassign = "reassign"
+	ReasonManual   = "manual"
+	// ReasonExtra marks a reviewer added on top of the requested count.
+	ReasonExtra = "extra"
+	// ReasonPending marks a reviewer who filled a slot left open at
+	// assignment time.
+	ReasonPending = "pending"
+)
+
+// ReviewerChange is a single entry of a pull request's reviewer history.
+type ReviewerChange struct {
+	PullRequestID string
+	ReviewerID    string
+	Action        ReviewerAction
+	Reason        string
+	CreatedAt     time.Time
+}
+
+// AssignmentHistoryFilter narrows a reviewer's history to the changes made
+// from From (inclusive) to To (exclusive). A zero bound leaves that side open.
+type AssignmentHistoryFilter struct {
+	ReviewerID string
+	From       time.Time
+	To         time.Time
+}
+
+type MembershipChangeKind string
+
+const (
+	MembershipJoined      MembershipChangeKind = "joined"
+	MembershipLeft        MembershipChangeKind = "left"
+	MembershipActivated   MembershipChangeKind = "activated"
+	MembershipDeactivated MembershipChangeKind = "deactivated"
+)
+
+// MembershipChange is a single entry of a team's membership history. IsActive
+// is the member's status after the change.
+type MembershipChange struct {
+	TeamName  string
+	UserID    string
+	Kind      MembershipChangeKind
+	IsActive  bool
+	ChangedAt time.Time
+}
+
+// Strategies recorded in assignment decisions in addition to the team's
+// SelectionStrategy values.
+const (
+	DecisionRotation = "rotation"
+	DecisionManual   = "manual"
+	// DecisionOwnership records that ownership rules filled every slot.
+	DecisionOwnership = "ownership"
+	// DecisionDeferred records that the pick was postponed to the team's
+	// next working hours.
+	DecisionDeferred = "deferred"
+)
+
+// Filters that exclude team members from an assignment decision.
+const (
+	FilterAuthor          = "author"
+	FilterInactive        = "inactive"
+	FilterMaxOpenReviews  = "max_open_reviews"
+	FilterReplaced        = "replaced"
+	FilterAlreadyAssigned = "already_assigned"
+	FilterSnoozed         = "snoozed"
+	FilterCooldown        = "cooldown"
+	FilterPrevious        = "previous_reviewers"
+	FilterCoAuthor        = "co_author"
+)
+
+// AssignmentDecision is the context in which reviewers were picked for a pull
+// request, kept to explain assignments after the fact.
+type AssignmentDecision struct {
+	PullRequestID string
+	// Reason is one of the reviewer history reasons.
+	Reason   string
+	TeamName string
+	Strategy string
+	// Seed initialised the random source used for the pick; zero when the
+	// pick involved no randomness.
+	Seed int64
+	// Filters lists the filters applied to the team, in order.
+	Filters []string
+	// Candidates snapshots the team at decision time.
+	Candidates []DecisionCandidate
+	Selected   []string
+	// Owners lists the selected reviewers picked to satisfy the team's
+	// ownership rules; the rest of Selected was picked by Strategy.
+	Owners []string
+	// Mandatory is the team's mandatory reviewer when it was added to
+	// Selected.
+	Mandatory string
+	// AuthorFallback reports that the author filled a slot nobody else could.
+	AuthorFallback bool
+	CreatedAt      time.Time
+}
+
+// DecisionCandidate is a team member considered by an assignment decision.
+type DecisionCandidate struct {
+	UserID string
+	// ExcludedBy names the first filter that dropped the member; empty when
+	// the member was eligible.
+	ExcludedBy string
+}
+
+// TeamSettings holds per-team assignment policy.
+type TeamSettings struct {
+	TeamName          string
+	RequiredReviewers int
+	// AllowSingleReviewer permits assigning fewer than RequiredReviewers when
+	// the candidate pool is too small; otherwise creation fails.
+	AllowSingleReviewer bool
+	// AllowAuthorReview lets the author fill a slot when the pool is too small.
+	AllowAuthorReview bool
+	// Reminder policy for open pull requests, measured from creation. Zero
+	// disables the corresponding step.
+	RemindAfter   time.Duration
+	EscalateAfter time.Duration
+	ReassignAfter time.Duration
+	// Strategy chooses reviewers when the team has no rotation.
+	Strategy SelectionStrategy
+	// MaxOpenReviews keeps members with this many open reviews out of
+	// automatic assignment. Zero means no limit.
+	MaxOpenReviews int
+	// ReviewCooldown deprioritizes, for an author's next pull requests, the
+	// members who reviewed that author's pull requests merged within this
+	// window. They are picked only when nobody else can fill a slot. Zero
+	// disables the rule.
+	ReviewCooldown time.Duration
+	// AvoidPreviousReviewers deprioritizes, like ReviewCooldown, the reviewers
+	// of the author's last merged pull request so that knowledge spreads
+	// across the team.
+	AvoidPreviousReviewers bool
+	// MandatoryReviewer is a member, typically the tech lead, assigned to
+	// every new pull request on top of the picked reviewers. It is skipped on
+	// the member's own pull requests and while the member is unavailable.
+	MandatoryReviewer string
+	// AcceptTimeout makes automatically and manually assigned reviewers
+	// PENDING until they accept the review; a reviewer who does not accept
+	// within it is replaced. Zero disables the step.
+	AcceptTimeout time.Duration
+	// AutoApproveExtension approves without a lead the deadline extensions
+	// reaching at most this far from the moment they are requested. Zero
+	// leaves every extension to the team's leads.
+	AutoApproveExtension time.Duration
+	// TimeZone is the IANA name of the zone working hours are given in.
+	TimeZone string
+	// WorkStart and WorkEnd bound the working day as offsets from local
+	// midnight. Equal values mean the team has no working hours.
+	WorkStart time.Duration
+	WorkEnd   time.Duration
+	// WorkDays lists the working days of the week.
+	WorkDays []time.Weekday
+	// ShadowPool lists the trainees, members of the team, one of whom is
+	// added to every new pull request as a shadow reviewer.
+	ShadowPool []string
+	// BlockMergeOnDependencies refuses to merge the team's pull requests
+	// while any of their blockers is not merged. Force merges bypass it.
+	BlockMergeOnDependencies bool
+}
+
+// HasWorkingHours reports whether the team restricts its working time.
+func (s TeamSettings) HasWorkingHours() bool {
+	return s.WorkStart != s.WorkEnd
+}
+
+// WorkingAt reports whether t falls within the team's working hours. Teams
+// without working hours are always at work.
+func (s TeamSettings) WorkingAt(t time.Time) bool {
+	if !s.HasWorkingHours() {
+		return true
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+
+	working := false
+	for _, day := range s.WorkDays {
+		if day == local.Weekday() {
+			working = true
+			break
+		}
+	}
+	if !working {
+		return false
+	}
+
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	offset := local.Sub(midnight)
+	return offset >= s.WorkStart && offset < s.WorkEnd
+}
+
+const DefaultRequiredReviewers = 2
+
+// DefaultWorkDays are the working days of teams that never set them.
+var DefaultWorkDays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+// SelectionStrategy is how reviewers are picked from the candidate pool.
+type SelectionStrategy string
+
+const (
+	// StrategyRandom picks candidates uniformly at random.
+	StrategyRandom SelectionStrategy = "random"
+	// StrategyAffinity prefers candidates who reviewed or authored pull
+	// requests with overlapping names and labels.
+	StrategyAffinity SelectionStrategy = "affinity"
+)
+
+func (s SelectionStrategy) Valid() bool {
+	switch s {
+	case StrategyRandom, StrategyAffinity:
+		return true
+	default:
+		return false
+	}
+}
+
+// DefaultTeamSettings returns the policy applied to teams that never changed
+// their settings.
+func DefaultTeamSettings(teamName string) TeamSettings {
+	return TeamSettings{
+		TeamName:            teamName,
+		RequiredReviewers:   DefaultRequiredReviewers,
+		AllowSingleReviewer: true,
+		Strategy:            StrategyRandom,
+		TimeZone:            "UTC",
+		WorkDays:            append([]time.Weekday(nil), DefaultWorkDays...),
+	}
+}
+
+// DeferredAssignment is a pull request created outside its team's working
+// hours whose reviewers are picked when the next working day starts.
+type DeferredAssignment struct {
+	PullRequestID string
+	TeamName      string
+	CreatedAt     time.Time
+}
+
+// PendingAssignment is an open pull request that got fewer reviewers than it
+// needs; its open slots are filled as members become available.
+type PendingAssignment struct {
+	PullRequestID string
+	CreatedAt     time.Time
+}
+
+// ReviewAcceptance is the acceptance a reviewer owes for a pull request:
+// requested on assignment, due by AcceptBy. AcceptedAt and ExpiredAt are nil
+// while it is pending.
+type ReviewAcceptance struct {
+	PullRequestID string
+	ReviewerID    string
+	RequestedAt   time.Time
+	AcceptBy      time.Time
+	AcceptedAt    *time.Time
+	ExpiredAt     *time.Time
+}
+
+// State returns the acceptance state, an acceptance given after expiry
+// winning over the expiry.
+func (a ReviewAcceptance) State() AcceptanceState {
+	switch {
+	case a.AcceptedAt != nil:
+		return AcceptanceAccepted
+	case a.ExpiredAt != nil:
+		return AcceptanceExpired
+	}
+	return AcceptancePending
+}
+
+// AcceptanceReport summarises the acceptances requested from reviewers of the
+// team's pull requests within the period. Median and P90 cover the accepted
+// ones.
+type AcceptanceReport struct {
+	TeamName  string
+	Since     time.Time
+	Requested int
+	Accepted  int
+	Expired   int
+	Pending   int
+	Median    time.Duration
+	P90       time.Duration
+}
+
+// ExtensionStatus is the state of a deadline extension request.
+type ExtensionStatus string
+
+const (
+	ExtensionPending  ExtensionStatus = "PENDING"
+	ExtensionApproved ExtensionStatus = "APPROVED"
+	ExtensionRejected ExtensionStatus = "REJECTED"
+)
+
+// DeadlineExtension is a reviewer's request for time to review a pull request
+// until Until, holding back its escalation once approved. DecidedBy names the
+// caller who decided; it is empty for requests approved automatically by the
+// team's policy.
+type DeadlineExtension struct {
+	ID            int64
+	PullRequestID string
+	ReviewerID    string
+	Until         time.Time
+	Reason        string
+	Status        ExtensionStatus
+	RequestedAt   time.Time
+	DecidedBy     string
+	DecidedAt     *time.Time
+}
+
+// TeamToken lets a team's CI create pull requests of the team's members.
+// A token issued to a member, named by UserID, also identifies its bearer as
+// that member, e.g. a team lead changing the team. Only the SHA-256 hash of
+// the token is stored; Token holds the token itself just after it is created.
+type TeamToken struct {
+	ID        int64
+	TeamName  string
+	UserID    string
+	Name      string
+	Token     string
+	Hash      string
+	CreatedAt time.Time
+}
+
+// LoadForecast estimates the review assignments the team's members can
+// expect between From and To, from the pull requests its members created
+// since Since and the members available to review them.
+type LoadForecast struct {
+	TeamName string
+	Since    time.Time
+	From     time.Time
+	To       time.Time
+	// PullRequests is the number of pull requests the members are expected
+	// to create.
+	PullRequests float64
+	// Unfilled is the expected number of reviewer slots nobody is available
+	// to fill.
+	Unfilled float64
+	Members  []MemberForecast
+}
+
+// MemberForecast is one member's share of a LoadForecast.
+type MemberForecast struct {
+	UserID   string
+	Username string
+	// Availability is the part of the forecast period the member can be
+	// assigned reviews: zero while inactive, less than one when snoozed
+	// into the period.
+	Availability float64
+	Assignments  float64
+}
+
+// ReminderStage is the last reminder step taken for an open pull request.
+type ReminderStage int
+
+const (
+	ReminderNone ReminderStage = iota
+	ReminderSent
+	ReminderEscalated
+	ReminderReassigned
+)
+
+// ReminderCandidate is an open pull request together with its reminder state.
+type ReminderCandidate struct {
+	PullRequestID string
+	TeamName      string
+	CreatedAt     time.Time
+	Stage         ReminderStage
+	// Deadline is the deadline granted by an approved extension, if any.
+	Deadline *time.Time
+}
+
+// Quota bounds a count such as the members of a team. Going over Soft is
+// allowed with a Warning; going over Hard is refused. Zero disables a bound.
+type Quota struct {
+	Soft int
+	Hard int
+}
+
+// Quotas guard against accidental misuse at scale, e.g. a script opening
+// pull requests in a loop.
+type Quotas struct {
+	TeamMembers Quota
+	// OpenPullRequests counts the unmerged pull requests, drafts included,
+	// of one author.
+	OpenPullRequests Quota
+	// Reviewers counts the reviewers of one pull request.
+	Reviewers Quota
+}
+
+// Warning is a remark on a request that succeeded anyway, such as a soft
+// quota being exceeded.
+type Warning struct {
+	Code    string
+	Message string
+	Details map[string]any
 }
@@ -0,0 +1,226 @@
+package e2e_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"Avito2025/internal/config"
+	"Avito2025/internal/service"
+	"Avito2025/internal/storage/storagetest"
+	httptransport "Avito2025/internal/transport/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/go-chi/chi/v5"
+)
+
+// specPath is openapi.yml at the repository root.
+const specPath = "../../openapi.yml"
+
+// TestContract drives the endpoints described in openapi.yml through the
+// handler backed by the in-memory store and checks every answer, errors
+// included, against the document.
+func TestContract(t *testing.T) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromFile(specPath)
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		t.Fatalf("invalid spec: %v", err)
+	}
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := &http.Client{Transport: &contractTransport{t: t, doc: doc, next: server.Client().Transport}}
+	baseURL := server.URL + "/v1"
+
+	createTeam(t, client, baseURL)
+	resp := doRequest(t, client, http.MethodPost, baseURL+"/team/add", map[string]any{
+		"team_name": "backend",
+		"members":   []map[string]any{{"user_id": "u1", "username": "Alice", "is_active": true}},
+	})
+	resp.Body.Close()
+
+	resp = doRequest(t, client, http.MethodPost, baseURL+"/team/add", map[string]any{
+		"team_name": "frontend",
+		"members":   []map[string]any{{"user_id": "u4", "username": "Dan", "is_active": true}},
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 for a member of another team, got %d", resp.StatusCode)
+	}
+
+	resp, err = client.Get(baseURL + "/team/get?team_name=backend")
+	if err != nil {
+		t.Fatalf("get team: %v", err)
+	}
+	resp.Body.Close()
+	resp, err = client.Get(baseURL + "/team/get?team_name=missing")
+	if err != nil {
+		t.Fatalf("get missing team: %v", err)
+	}
+	resp.Body.Close()
+
+	resp = doRequest(t, client, http.MethodPost, baseURL+"/users/setIsActive", map[string]any{
+		"user_id":   "u4",
+		"is_active": false,
+	})
+	resp.Body.Close()
+
+	pr := createPR(t, client, baseURL, "pr-contract", "Contract", "u1")
+	resp = doRequest(t, client, http.MethodPost, baseURL+"/pullRequest/create", map[string]any{
+		"pull_request_id":   pr.ID,
+		"pull_request_name": "Contract",
+		"author_id":         "u1",
+	})
+	resp.Body.Close()
+
+	// A team of one gets a pull request without reviewers.
+	resp = doRequest(t, client, http.MethodPost, baseURL+"/team/add", map[string]any{
+		"team_name": "solo",
+		"members":   []map[string]any{{"user_id": "s1", "username": "Sam", "is_active": true}},
+	})
+	resp.Body.Close()
+	createPR(t, client, baseURL, "pr-solo", "Alone", "s1")
+
+	replaced := reassign(t, client, baseURL, pr.ID, pr.AssignedReviewers[0])
+	resp = doRequest(t, client, http.MethodPost, baseURL+"/pullRequest/reassign", map[string]any{
+		"pull_request_id": pr.ID,
+		"old_user_id":     "u1",
+	})
+	resp.Body.Close()
+
+	merge(t, client, baseURL, pr.ID)
+	merge(t, client, baseURL, pr.ID)
+
+	resp = doRequest(t, client, http.MethodPost, baseURL+"/pullRequest/create", map[string]any{
+		"pull_request_id":   "pr-draft",
+		"pull_request_name": "Draft",
+		"author_id":         "u1",
+		"draft":             true,
+	})
+	resp.Body.Close()
+	resp = doRequest(t, client, http.MethodPost, baseURL+"/pullRequest/merge", map[string]any{"pull_request_id": "pr-draft"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 for merging a draft, got %d", resp.StatusCode)
+	}
+	for i := 0; i < 2; i++ {
+		resp = doRequest(t, client, http.MethodPost, baseURL+"/pullRequest/markReady", map[string]any{"pull_request_id": "pr-draft"})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected markReady to succeed, got %d", resp.StatusCode)
+		}
+	}
+
+	resp = doRequest(t, client, http.MethodPost, baseURL+"/team/add", map[string]any{
+		"team_name": "frontend",
+		"members":   []map[string]any{{"user_id": "u4", "username": "Dan", "is_active": true}},
+		"transfer":  true,
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected the transfer to create the team, got %d", resp.StatusCode)
+	}
+
+	for _, query := range []string{"user_id=" + replaced.ReplacedBy, "user_id=u2&status=OPEN&sort=mergedAt&order=asc", "user_id=u2&status=PENDING"} {
+		resp, err = client.Get(baseURL + "/users/getReview?" + query)
+		if err != nil {
+			t.Fatalf("get reviews: %v", err)
+		}
+		resp.Body.Close()
+	}
+}
+
+// TestContractCoversRoutes fails for every v1 route, test endpoints
+// included, that openapi.yml does not describe, so that a new endpoint cannot
+// ship undocumented.
+func TestContractCoversRoutes(t *testing.T) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromFile(specPath)
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+
+	handler := httptransport.NewHandler(service.New(storagetest.New(t)), config.HTTPConfig{EnableTestEndpoints: true})
+	router, ok := handler.Router().(chi.Routes)
+	if !ok {
+		t.Fatal("router does not expose its routes")
+	}
+
+	routes := 0
+	err = chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		path, ok := strings.CutPrefix(route, "/v1/")
+		if !ok {
+			return nil
+		}
+		path = "/" + strings.TrimSuffix(path, "/")
+		routes++
+		item := doc.Paths.Find(path)
+		if item == nil || item.GetOperation(method) == nil {
+			t.Errorf("%s %s is not described in the spec", method, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk routes: %v", err)
+	}
+	if routes == 0 {
+		t.Fatal("found no v1 routes")
+	}
+}
+
+// contractTransport validates every response it passes on against doc.
+type contractTransport struct {
+	t    *testing.T
+	doc  *openapi3.T
+	next http.RoundTripper
+}
+
+func (c *contractTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	path := strings.TrimPrefix(req.URL.Path, "/v1")
+	item := c.doc.Paths.Find(path)
+	if item == nil || item.GetOperation(req.Method) == nil {
+		c.t.Errorf("%s %s is not described in the spec", req.Method, path)
+		return resp, nil
+	}
+
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request: req,
+			Route: &routers.Route{
+				Spec:      c.doc,
+				Path:      path,
+				PathItem:  item,
+				Method:    req.Method,
+				Operation: item.GetOperation(req.Method),
+			},
+		},
+		Status:  resp.StatusCode,
+		Header:  resp.Header,
+		Body:    io.NopCloser(bytes.NewReader(body)),
+		Options: &openapi3filter.Options{IncludeResponseStatus: true},
+	}
+	if err := openapi3filter.ValidateResponse(context.Background(), input); err != nil {
+		c.t.Errorf("%s %s answered %d against the spec: %v\n%s", req.Method, path, resp.StatusCode, err, body)
+	}
+	return resp, nil
+}
@@ -2,21 +2,36 @@ package e2e_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"Avito2025/internal/config"
 	"Avito2025/internal/domain"
 	"Avito2025/internal/service"
-	"Avito2025/internal/storage/postgres"
+	"Avito2025/internal/signature"
+	"Avito2025/internal/storage"
+	"Avito2025/internal/storage/retry"
+	"Avito2025/internal/storage/storagetest"
 	httptransport "Avito2025/internal/transport/http"
+	"Avito2025/internal/writequeue"
+	reviewerclient "Avito2025/pkg/client"
 
-	"github.com/testcontainers/testcontainers-go"
-	"github.com/testcontainers/testcontainers-go/wait"
+	"github.com/gorilla/websocket"
+	"github.com/klauspost/compress/zstd"
 )
 
 func TestE2EFlow(t *testing.T) {
@@ -57,6 +72,1852 @@ func TestE2EFlow(t *testing.T) {
 		assertUserReviews(t, client, server.URL, reassignResp.ReplacedBy)
 	})
 
+	t.Run("conditional get", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+
+		createTeam(t, client, server.URL)
+
+		resp, err := client.Get(server.URL + "/team/get?team_name=backend")
+		if err != nil {
+			t.Fatalf("get team: %v", err)
+		}
+		resp.Body.Close()
+
+		etag := resp.Header.Get("ETag")
+		if etag == "" {
+			t.Fatalf("expected ETag header")
+		}
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/team/get?team_name=backend", nil)
+		if err != nil {
+			t.Fatalf("build request: %v", err)
+		}
+		req.Header.Set("If-None-Match", etag)
+		resp, err = client.Do(req)
+		if err != nil {
+			t.Fatalf("conditional get team: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected 304, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("event stream", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?team_name=backend"
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err != nil {
+			t.Fatalf("dial websocket: %v", err)
+		}
+		defer conn.Close()
+
+		createTeam(t, server.Client(), server.URL)
+
+		if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatalf("set read deadline: %v", err)
+		}
+		var event struct {
+			Type     string `json:"type"`
+			TeamName string `json:"team_name"`
+		}
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		if event.Type != string(domain.EventTeamCreated) || event.TeamName != "backend" {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	})
+
+	t.Run("request body limits", func(t *testing.T) {
+		server := newTestServerWithConfig(t, config.HTTPConfig{MaxBodyBytes: 64})
+		defer server.Close()
+
+		client := server.Client()
+
+		resp, err := client.Post(server.URL+"/team/add", "text/plain", strings.NewReader(`{"team_name":"backend"}`))
+		if err != nil {
+			t.Fatalf("post text body: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			t.Fatalf("expected 415, got %d", resp.StatusCode)
+		}
+
+		body := `{"team_name":"backend","members":[{"user_id":"` + strings.Repeat("u", 128) + `"}]}`
+		resp, err = client.Post(server.URL+"/team/add", "application/json; charset=utf-8", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("post large body: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Fatalf("expected 413, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("versioned routes", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+
+		createTeam(t, client, server.URL+"/v1")
+
+		resp, err := client.Get(server.URL + "/v1/team/get?team_name=backend")
+		if err != nil {
+			t.Fatalf("get team: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Deprecation") != "" {
+			t.Fatalf("unexpected v1 response: %d %v", resp.StatusCode, resp.Header)
+		}
+
+		resp, err = client.Get(server.URL + "/team/get?team_name=backend")
+		if err != nil {
+			t.Fatalf("get team legacy: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Deprecation") != "true" {
+			t.Fatalf("expected deprecated legacy alias, got %d %v", resp.StatusCode, resp.Header)
+		}
+		if link := resp.Header.Get("Link"); link != `</v1/team/get>; rel="successor-version"` {
+			t.Fatalf("unexpected Link header %q", link)
+		}
+
+		legacyOff := newTestServerWithConfig(t, config.HTTPConfig{DisableLegacyRoutes: true})
+		defer legacyOff.Close()
+
+		resp, err = legacyOff.Client().Get(legacyOff.URL + "/team/get?team_name=backend")
+		if err != nil {
+			t.Fatalf("get team without legacy routes: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("links", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+		createPR(t, client, server.URL, "pr-1", "Add login", "u1")
+
+		type linked struct {
+			Links   map[string]string `json:"links"`
+			Members []struct {
+				Links map[string]string `json:"links"`
+			} `json:"members"`
+			Data struct {
+				PR struct {
+					Links map[string]string `json:"links"`
+				} `json:"pr"`
+			} `json:"data"`
+		}
+		get := func(server *httptest.Server, path string, forwarded bool) linked {
+			t.Helper()
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+path, nil)
+			if err != nil {
+				t.Fatalf("build request: %v", err)
+			}
+			if forwarded {
+				req.Header.Set("X-Forwarded-Proto", "https")
+				req.Header.Set("X-Forwarded-Host", "reviews.example.com, proxy.internal")
+			}
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatalf("get %s: %v", path, err)
+			}
+			defer resp.Body.Close()
+			var body linked
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode %s: %v", path, err)
+			}
+			return body
+		}
+
+		team := get(server, "/v1/team/get?team_name=backend", false)
+		if got := team.Links["self"]; got != server.URL+"/v1/team/get?team_name=backend" {
+			t.Fatalf("unexpected team self link %q", got)
+		}
+		if got := team.Members[0].Links["reviews"]; got != server.URL+"/v1/users/getReview?user_id=u1" {
+			t.Fatalf("unexpected member reviews link %q", got)
+		}
+
+		pr := get(server, "/v2/pullRequest/get?pull_request_id=pr-1", true)
+		if got := pr.Data.PR.Links["self"]; got != "https://reviews.example.com/v2/pullRequest/get?pull_request_id=pr-1" {
+			t.Fatalf("expected a link through the forwarded host, got %q", got)
+		}
+
+		configured := newTestServerWithConfig(t, config.HTTPConfig{BaseURL: "https://api.example.com/"})
+		defer configured.Close()
+		createTeam(t, configured.Client(), configured.URL)
+
+		reviews := get(configured, "/users/getReview?user_id=u2", true)
+		if got := reviews.Links["self"]; got != "https://api.example.com/v1/users/getReview?user_id=u2" {
+			t.Fatalf("expected a link under BASE_URL, got %q", got)
+		}
+	})
+
+	t.Run("strict decoding and snake_case naming", func(t *testing.T) {
+		server := newTestServerWithConfig(t, config.HTTPConfig{StrictJSON: true, JSONNaming: config.JSONNamingSnake})
+		defer server.Close()
+
+		client := server.Client()
+		ctx := context.Background()
+		sdk := reviewerclient.New(reviewerclient.Config{BaseURL: server.URL, HTTPClient: client})
+		if _, err := sdk.CreateTeam(ctx, reviewerclient.Team{
+			Name: "backend",
+			Members: []reviewerclient.Member{
+				{UserID: "u1", Username: "Alice", IsActive: true},
+				{UserID: "u2", Username: "Bob", IsActive: true},
+			},
+		}); err != nil {
+			t.Fatalf("CreateTeam: %v", err)
+		}
+		pr, err := sdk.CreatePR(ctx, reviewerclient.NewPullRequest{ID: "pr-1", Name: "Add login", AuthorID: "u1"})
+		if err != nil {
+			t.Fatalf("CreatePR: %v", err)
+		}
+		if pr.CreatedAt == nil {
+			t.Fatalf("expected the client to read created_at")
+		}
+		if _, err := sdk.Merge(ctx, "pr-1"); err != nil {
+			t.Fatalf("Merge: %v", err)
+		}
+
+		resp, err := client.Get(server.URL + "/v1/pullRequest/get?pull_request_id=pr-1")
+		if err != nil {
+			t.Fatalf("get pr: %v", err)
+		}
+		var raw struct {
+			PR map[string]any `json:"pr"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&raw)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("decode pr: %v", err)
+		}
+		if _, ok := raw.PR["merged_at"]; !ok {
+			t.Fatalf("expected merged_at, got %v", raw.PR)
+		}
+		if _, ok := raw.PR["mergedAt"]; ok {
+			t.Fatalf("expected no mergedAt, got %v", raw.PR)
+		}
+
+		resp, err = client.Get(server.URL + "/v1/users/getReview?user_id=u2&sort=merged_at")
+		if err != nil {
+			t.Fatalf("get reviews: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected merged_at to be accepted as a sort key, got %d", resp.StatusCode)
+		}
+
+		resp = doRequest(t, client, http.MethodPost, server.URL+"/v1/pullRequest/merge", map[string]any{
+			"pull_request_id": "pr-1",
+			"squash":          true,
+		})
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&failure)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if resp.StatusCode != http.StatusBadRequest || failure.Error.Message != `unknown field "squash"` {
+			t.Fatalf("expected 400 naming the unknown field, got %d %q", resp.StatusCode, failure.Error.Message)
+		}
+	})
+
+	t.Run("team stats", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+		createPR(t, client, server.URL, "pr-1", "Add search", "u1")
+		merge(t, client, server.URL, "pr-1")
+		createPR(t, client, server.URL, "pr-2", "Add cache", "u2")
+
+		resp := doRequest(t, client, http.MethodGet, server.URL+"/stats/team?team_name=backend&period=2w", nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var stats struct {
+			OpenPullRequests int     `json:"open_pull_requests"`
+			PullRequests     int     `json:"pull_requests"`
+			AvgReviewers     float64 `json:"avg_reviewers"`
+			BusiestReviewer  *struct {
+				UserID string `json:"user_id"`
+			} `json:"busiest_reviewer"`
+			MergesPerWeek []struct {
+				Count int `json:"count"`
+			} `json:"merges_per_week"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		merges := 0
+		for _, week := range stats.MergesPerWeek {
+			merges += week.Count
+		}
+		if stats.OpenPullRequests != 1 || stats.PullRequests != 2 || stats.AvgReviewers != 2 || merges != 1 || stats.BusiestReviewer == nil {
+			t.Fatalf("unexpected team stats: %+v", stats)
+		}
+
+		for path, status := range map[string]int{
+			"/stats/team":                   http.StatusBadRequest,
+			"/stats/team?team_name=missing": http.StatusNotFound,
+		} {
+			resp := doRequest(t, client, http.MethodGet, server.URL+path, nil)
+			resp.Body.Close()
+			if resp.StatusCode != status {
+				t.Fatalf("%s: expected %d, got %d", path, status, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("quotas", func(t *testing.T) {
+		svc := service.New(storagetest.New(t))
+		svc.SetQuotas(domain.Quotas{OpenPullRequests: domain.Quota{Soft: 1, Hard: 2}})
+		server := httptest.NewServer(httptransport.NewHandler(svc, config.HTTPConfig{}).Router())
+		defer server.Close()
+
+		client := server.Client()
+		baseURL := server.URL + "/v2"
+		createTeam(t, client, baseURL)
+
+		type quotaDetails struct {
+			Quota string `json:"quota"`
+			Limit int    `json:"limit"`
+			Count int    `json:"count"`
+		}
+		var created struct {
+			Meta struct {
+				Warnings []struct {
+					Code    string       `json:"code"`
+					Details quotaDetails `json:"details"`
+				} `json:"warnings"`
+			} `json:"meta"`
+		}
+		for _, id := range []string{"pr-1", "pr-2"} {
+			resp := doRequest(t, client, http.MethodPost, baseURL+"/pullRequest/create", map[string]string{
+				"pull_request_id":   id,
+				"pull_request_name": "Add search",
+				"author_id":         "u1",
+			})
+			if resp.StatusCode != http.StatusCreated {
+				t.Fatalf("create %s: expected 201, got %d", id, resp.StatusCode)
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			resp.Body.Close()
+		}
+		warning := created.Meta.Warnings
+		if len(warning) != 1 || warning[0].Code != "SOFT_QUOTA_EXCEEDED" || warning[0].Details != (quotaDetails{Quota: "open_pull_requests", Limit: 1, Count: 2}) {
+			t.Fatalf("unexpected warnings: %+v", warning)
+		}
+
+		resp := doRequest(t, client, http.MethodPost, server.URL+"/pullRequest/create", map[string]string{
+			"pull_request_id":   "pr-3",
+			"pull_request_name": "Add cache",
+			"author_id":         "u1",
+		})
+		defer resp.Body.Close()
+		var failure struct {
+			Error struct {
+				Code    string       `json:"code"`
+				Details quotaDetails `json:"details"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&failure); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.StatusCode != http.StatusUnprocessableEntity || failure.Error.Code != "QUOTA_EXCEEDED" || failure.Error.Details != (quotaDetails{Quota: "open_pull_requests", Limit: 2, Count: 3}) {
+			t.Fatalf("unexpected hard quota response %d: %+v", resp.StatusCode, failure)
+		}
+	})
+
+	t.Run("co-authors", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+
+		resp := doRequest(t, client, http.MethodPost, server.URL+"/pullRequest/create", map[string]any{
+			"pull_request_id": "pr-pair", "pull_request_name": "Pairing", "author_id": "u1", "co_author_ids": []string{"u1"},
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for the author as a co-author, got %d", resp.StatusCode)
+		}
+
+		resp = doRequest(t, client, http.MethodPost, server.URL+"/pullRequest/create", map[string]any{
+			"pull_request_id": "pr-pair", "pull_request_name": "Pairing", "author_id": "u1", "co_author_ids": []string{"u2"},
+		})
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create status: %d", resp.StatusCode)
+		}
+		var created struct {
+			PR struct {
+				AssignedReviewers []string `json:"assigned_reviewers"`
+				CoAuthorIDs       []string `json:"co_author_ids"`
+			} `json:"pr"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !slices.Equal(created.PR.AssignedReviewers, []string{"u3", "u4"}) || !slices.Equal(created.PR.CoAuthorIDs, []string{"u2"}) {
+			t.Fatalf("expected u3 and u4 to review a pull request co-written by u2, got %+v", created.PR)
+		}
+	})
+
+	t.Run("force merge", func(t *testing.T) {
+		server := newTestServerWithConfig(t, config.HTTPConfig{AdminToken: "secret"})
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+		resp := doRequest(t, client, http.MethodPost, server.URL+"/pullRequest/create", map[string]any{
+			"pull_request_id": "pr-hotfix", "pull_request_name": "Hotfix", "author_id": "u1", "draft": true,
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create draft status: %d", resp.StatusCode)
+		}
+
+		merge := func(payload map[string]any, headers map[string]string) int {
+			t.Helper()
+			body, _ := json.Marshal(payload)
+			req, err := http.NewRequest(http.MethodPost, server.URL+"/pullRequest/merge", bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("build request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			for name, value := range headers {
+				req.Header.Set(name, value)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("merge: %v", err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		admin := map[string]string{"Authorization": "Bearer secret", "X-User-ID": "ops"}
+		forced := map[string]any{"pull_request_id": "pr-hotfix", "force": true, "reason": "production outage"}
+
+		if status := merge(map[string]any{"pull_request_id": "pr-hotfix"}, nil); status != http.StatusConflict {
+			t.Fatalf("expected 409 for a draft, got %d", status)
+		}
+		if status := merge(map[string]any{"pull_request_id": "pr-hotfix", "force": true}, admin); status != http.StatusBadRequest {
+			t.Fatalf("expected 400 without a reason, got %d", status)
+		}
+		if status := merge(forced, nil); status != http.StatusUnauthorized {
+			t.Fatalf("expected 401 without the admin token, got %d", status)
+		}
+		if status := merge(forced, map[string]string{"Authorization": "Bearer secret"}); status != http.StatusUnauthorized {
+			t.Fatalf("expected 401 without X-User-ID, got %d", status)
+		}
+		if status := merge(forced, admin); status != http.StatusOK {
+			t.Fatalf("expected 200 for a forced merge, got %d", status)
+		}
+
+		resp = doRequest(t, client, http.MethodGet, server.URL+"/changes?limit=100", nil)
+		defer resp.Body.Close()
+		var changes struct {
+			Events []struct {
+				Type     string         `json:"type"`
+				EntityID string         `json:"entity_id"`
+				Payload  map[string]any `json:"payload"`
+			} `json:"events"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&changes); err != nil {
+			t.Fatalf("decode changes: %v", err)
+		}
+		found := false
+		for _, event := range changes.Events {
+			if event.Type == "PR_FORCE_MERGED" && event.EntityID == "pr-hotfix" {
+				found = event.Payload["forced_by"] == "ops" && event.Payload["reason"] == "production outage"
+			}
+		}
+		if !found {
+			t.Fatalf("expected a PR_FORCE_MERGED event naming ops, got %+v", changes.Events)
+		}
+	})
+
+	t.Run("maintenance mode", func(t *testing.T) {
+		server := newTestServerWithConfig(t, config.HTTPConfig{AdminToken: "secret"})
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+
+		setMaintenance := func(enabled bool) {
+			t.Helper()
+			body, _ := json.Marshal(map[string]bool{"enabled": enabled})
+			req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/admin/maintenance", bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("build maintenance request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer secret")
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("set maintenance: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("set maintenance status: %d", resp.StatusCode)
+			}
+		}
+
+		setMaintenance(true)
+
+		resp := doRequest(t, client, http.MethodPost, server.URL+"/pullRequest/create", map[string]string{
+			"pull_request_id":   "pr-1",
+			"pull_request_name": "Add search",
+			"author_id":         "u1",
+		})
+		var errBody struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errBody); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable || errBody.Error.Code != "MAINTENANCE" {
+			t.Fatalf("expected 503 MAINTENANCE, got %d %q", resp.StatusCode, errBody.Error.Code)
+		}
+
+		resp, err := client.Get(server.URL + "/team/get?team_name=backend")
+		if err != nil {
+			t.Fatalf("get team: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected reads during maintenance, got %d", resp.StatusCode)
+		}
+
+		setMaintenance(false)
+		createPR(t, client, server.URL, "pr-1", "Add search", "u1")
+	})
+
+	t.Run("review long poll", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+
+		resp, err := client.Get(server.URL + "/users/getReview?user_id=u2&status=OPEN")
+		if err != nil {
+			t.Fatalf("get reviews: %v", err)
+		}
+		resp.Body.Close()
+		etag := resp.Header.Get("ETag")
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/users/getReview/wait?user_id=u2&timeout=50ms", nil)
+		if err != nil {
+			t.Fatalf("build wait request: %v", err)
+		}
+		req.Header.Set("If-None-Match", etag)
+		resp, err = client.Do(req)
+		if err != nil {
+			t.Fatalf("wait without changes: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected 304 when nothing changed, got %d", resp.StatusCode)
+		}
+
+		type waitResult struct {
+			status int
+			prs    []pullRequestPayload
+		}
+		results := make(chan waitResult, 1)
+		go func() {
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/users/getReview/wait?user_id=u2&timeout=5s", nil)
+			if err != nil {
+				results <- waitResult{}
+				return
+			}
+			req.Header.Set("If-None-Match", etag)
+			resp, err := client.Do(req)
+			if err != nil {
+				results <- waitResult{}
+				return
+			}
+			defer resp.Body.Close()
+			var body struct {
+				PullRequests []pullRequestPayload `json:"pull_requests"`
+			}
+			_ = json.NewDecoder(resp.Body).Decode(&body)
+			results <- waitResult{status: resp.StatusCode, prs: body.PullRequests}
+		}()
+
+		// Keep creating pull requests until one lands on u2.
+		for i := 1; i <= 20; i++ {
+			pr := createPR(t, client, server.URL, fmt.Sprintf("pr-%d", i), "Poll", "u1")
+			if slices.Contains(pr.AssignedReviewers, "u2") {
+				break
+			}
+		}
+
+		select {
+		case result := <-results:
+			if result.status != http.StatusOK || len(result.prs) == 0 {
+				t.Fatalf("expected the new review, got %d %+v", result.status, result.prs)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("long poll did not return")
+		}
+	})
+
+	t.Run("test endpoints", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		resp, err := server.Client().Post(server.URL+"/test/reset", "application/json", nil)
+		if err != nil {
+			t.Fatalf("reset without test endpoints: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404 without test endpoints, got %d", resp.StatusCode)
+		}
+
+		testServer := newTestServerWithConfig(t, config.HTTPConfig{EnableTestEndpoints: true})
+		defer testServer.Close()
+
+		client := testServer.Client()
+		resp = doRequest(t, client, http.MethodPost, testServer.URL+"/test/seed", map[string]any{
+			"teams": []map[string]any{{
+				"team_name": "backend",
+				"members": []map[string]any{
+					{"user_id": "u1", "username": "Alice", "is_active": true},
+					{"user_id": "u2", "username": "Bob", "is_active": true},
+				},
+			}},
+			"pull_requests": []map[string]any{
+				{"pull_request_id": "pr-1", "pull_request_name": "Seeded", "author_id": "u1"},
+			},
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("seed status: %d", resp.StatusCode)
+		}
+
+		resp, err = client.Get(testServer.URL + "/pullRequest/get?pull_request_id=pr-1")
+		if err != nil {
+			t.Fatalf("get seeded pr: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected seeded pr, got %d", resp.StatusCode)
+		}
+
+		resp, err = client.Post(testServer.URL+"/test/reset", "application/json", nil)
+		if err != nil {
+			t.Fatalf("reset: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("reset status: %d", resp.StatusCode)
+		}
+
+		resp, err = client.Get(testServer.URL + "/team/get?team_name=backend")
+		if err != nil {
+			t.Fatalf("get team after reset: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404 after reset, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("v2 envelope", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+		baseURL := server.URL + "/v2"
+		createTeam(t, client, baseURL)
+
+		var team struct {
+			Data struct {
+				Team struct {
+					TeamName string `json:"team_name"`
+				} `json:"team"`
+			} `json:"data"`
+			Meta struct {
+				RequestID string `json:"request_id"`
+			} `json:"meta"`
+		}
+		resp := doRequest(t, client, http.MethodGet, baseURL+"/team/get?team_name=backend", nil)
+		if err := json.NewDecoder(resp.Body).Decode(&team); err != nil {
+			t.Fatalf("decode team: %v", err)
+		}
+		resp.Body.Close()
+		if team.Data.Team.TeamName != "backend" || team.Meta.RequestID == "" {
+			t.Fatalf("unexpected v2 team response: %+v", team)
+		}
+
+		var failure struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+			Meta struct {
+				RequestID string `json:"request_id"`
+			} `json:"meta"`
+		}
+		resp = doRequest(t, client, http.MethodGet, baseURL+"/team/get?team_name=missing", nil)
+		if err := json.NewDecoder(resp.Body).Decode(&failure); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound || failure.Error.Code == "" || failure.Meta.RequestID == "" {
+			t.Fatalf("unexpected v2 error response %d: %+v", resp.StatusCode, failure)
+		}
+
+		var changes struct {
+			Data map[string]json.RawMessage `json:"data"`
+			Meta struct {
+				Pagination struct {
+					Limit      int    `json:"limit"`
+					NextCursor *int64 `json:"next_cursor"`
+				} `json:"pagination"`
+			} `json:"meta"`
+		}
+		resp = doRequest(t, client, http.MethodGet, baseURL+"/changes?limit=10", nil)
+		if err := json.NewDecoder(resp.Body).Decode(&changes); err != nil {
+			t.Fatalf("decode changes: %v", err)
+		}
+		resp.Body.Close()
+		if _, ok := changes.Data["next_cursor"]; ok {
+			t.Fatalf("expected the cursor to move into meta")
+		}
+		if changes.Meta.Pagination.Limit != 10 || changes.Meta.Pagination.NextCursor == nil || *changes.Meta.Pagination.NextCursor == 0 {
+			t.Fatalf("unexpected pagination: %+v", changes.Meta.Pagination)
+		}
+
+		// v1 keeps its payloads as they were.
+		var bare struct {
+			TeamName string `json:"team_name"`
+		}
+		resp = doRequest(t, client, http.MethodGet, server.URL+"/v1/team/get?team_name=backend", nil)
+		if err := json.NewDecoder(resp.Body).Decode(&bare); err != nil {
+			t.Fatalf("decode v1 team: %v", err)
+		}
+		resp.Body.Close()
+		if bare.TeamName != "backend" {
+			t.Fatalf("expected the bare team on v1, got %+v", bare)
+		}
+	})
+
+	t.Run("go client", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		// The first merge attempt fails as if the service were briefly
+		// unavailable, which the client retries.
+		transport := &flakyTransport{next: server.Client().Transport, failPath: "/v1/pullRequest/merge"}
+		sdk := reviewerclient.New(reviewerclient.Config{
+			BaseURL:    server.URL,
+			HTTPClient: &http.Client{Transport: transport},
+			Backoff:    time.Millisecond,
+		})
+		ctx := context.Background()
+
+		team, err := sdk.CreateTeam(ctx, reviewerclient.Team{
+			Name: "backend",
+			Members: []reviewerclient.Member{
+				{UserID: "u1", Username: "Alice", IsActive: true},
+				{UserID: "u2", Username: "Bob", IsActive: true},
+				{UserID: "u3", Username: "Cathy", IsActive: true},
+			},
+		})
+		if err != nil || len(team.Members) != 3 {
+			t.Fatalf("CreateTeam: %+v, %v", team, err)
+		}
+		if _, err := sdk.CreateTeam(ctx, team); !errors.Is(err, reviewerclient.ErrTeamExists) {
+			t.Fatalf("expected ErrTeamExists, got %v", err)
+		}
+
+		pr, err := sdk.CreatePR(ctx, reviewerclient.NewPullRequest{ID: "pr-sdk", Name: "Client", AuthorID: "u1"})
+		if err != nil || len(pr.AssignedReviewers) == 0 {
+			t.Fatalf("CreatePR: %+v, %v", pr, err)
+		}
+
+		old := pr.AssignedReviewers[0]
+		reassigned, replacedBy, err := sdk.Reassign(ctx, pr.ID, old)
+		if err != nil || replacedBy == old || slices.Contains(reassigned.AssignedReviewers, old) {
+			t.Fatalf("Reassign: %+v, %q, %v", reassigned, replacedBy, err)
+		}
+
+		merged, err := sdk.Merge(ctx, pr.ID)
+		if err != nil || merged.Status != reviewerclient.StatusMerged {
+			t.Fatalf("Merge: %+v, %v", merged, err)
+		}
+		if transport.failures != 1 {
+			t.Fatalf("expected one failed merge attempt, got %d", transport.failures)
+		}
+
+		reviews, err := sdk.ListReviews(ctx, replacedBy, reviewerclient.ReviewFilter{Status: reviewerclient.StatusMerged})
+		if err != nil || len(reviews) != 1 || reviews[0].ID != pr.ID {
+			t.Fatalf("ListReviews: %+v, %v", reviews, err)
+		}
+
+		var apiErr *reviewerclient.Error
+		_, _, err = sdk.Reassign(ctx, "missing", "u2")
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || !errors.Is(err, reviewerclient.ErrNotFound) {
+			t.Fatalf("expected a not found error, got %v", err)
+		}
+	})
+
+	t.Run("error stats", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+
+		createTeam(t, client, server.URL)
+		resp := doRequest(t, client, http.MethodPost, server.URL+"/team/add", map[string]any{
+			"team_name": "backend",
+			"members":   []map[string]any{{"user_id": "u1", "username": "Alice", "is_active": true}},
+		})
+		resp.Body.Close()
+
+		resp, err := client.Get(server.URL + "/stats/errors?window=5m")
+		if err != nil {
+			t.Fatalf("get error stats: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("error stats status: %d", resp.StatusCode)
+		}
+
+		// Counts are kept per process, so other tests may have added to them.
+		type errorCount struct {
+			Code   string `json:"code"`
+			Status int    `json:"status"`
+			Class  string `json:"class"`
+			Count  int    `json:"count"`
+		}
+		var stats struct {
+			WindowSeconds  int          `json:"window_seconds"`
+			Requests       int          `json:"requests"`
+			BusinessErrors int          `json:"business_errors"`
+			Codes          []errorCount `json:"codes"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+			t.Fatalf("decode error stats: %v", err)
+		}
+		if stats.WindowSeconds != 300 || stats.Requests < 2 || stats.BusinessErrors < 1 {
+			t.Fatalf("unexpected error stats: %+v", stats)
+		}
+		found := slices.ContainsFunc(stats.Codes, func(c errorCount) bool {
+			return c.Code == "TEAM_EXISTS" && c.Status == http.StatusBadRequest && c.Class == "business"
+		})
+		if !found {
+			t.Fatalf("expected TEAM_EXISTS among the codes, got %+v", stats.Codes)
+		}
+
+		resp, err = client.Get(server.URL + "/stats/errors?window=2h")
+		if err != nil {
+			t.Fatalf("get error stats: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for a window over an hour, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("response compression", func(t *testing.T) {
+		server := newTestServerWithConfig(t, config.HTTPConfig{CompressionLevel: 5})
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+
+		fetch := func(acceptEncoding string) teamPayload {
+			t.Helper()
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/team/get?team_name=backend", nil)
+			if err != nil {
+				t.Fatalf("build request: %v", err)
+			}
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("get team: %v", err)
+			}
+			defer resp.Body.Close()
+
+			want := acceptEncoding
+			if want == "identity" {
+				want = ""
+			} else if strings.Contains(want, "zstd") {
+				want = "zstd"
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != want {
+				t.Fatalf("Accept-Encoding %q: expected Content-Encoding %q, got %q", acceptEncoding, want, got)
+			}
+
+			var body io.Reader = resp.Body
+			switch want {
+			case "gzip":
+				gz, err := gzip.NewReader(resp.Body)
+				if err != nil {
+					t.Fatalf("gzip reader: %v", err)
+				}
+				body = gz
+			case "zstd":
+				zr, err := zstd.NewReader(resp.Body)
+				if err != nil {
+					t.Fatalf("zstd reader: %v", err)
+				}
+				defer zr.Close()
+				body = zr
+			}
+			var team teamPayload
+			if err := json.NewDecoder(body).Decode(&team); err != nil {
+				t.Fatalf("decode %q response: %v", acceptEncoding, err)
+			}
+			return team
+		}
+
+		for _, encoding := range []string{"identity", "gzip", "gzip, zstd"} {
+			if team := fetch(encoding); team.TeamName != "backend" || len(team.Members) != 4 {
+				t.Fatalf("Accept-Encoding %q: unexpected team %+v", encoding, team)
+			}
+		}
+	})
+
+	t.Run("team list", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+		for _, team := range []map[string]any{
+			{"team_name": "backoffice", "members": []map[string]any{{"user_id": "u9", "username": "Ivan", "is_active": true}}},
+			{"team_name": "frontend", "members": []map[string]any{{"user_id": "u8", "username": "Hank", "is_active": true}}},
+		} {
+			resp := doRequest(t, client, http.MethodPost, server.URL+"/team/add", team)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusCreated {
+				t.Fatalf("create team %v: status %d", team["team_name"], resp.StatusCode)
+			}
+		}
+		resp := doRequest(t, client, http.MethodPost, server.URL+"/users/setIsActive", map[string]any{"user_id": "u4", "is_active": false})
+		resp.Body.Close()
+		createPR(t, client, server.URL, "pr-list", "Listed", "u1")
+
+		type listPayload struct {
+			Teams []struct {
+				TeamName         string `json:"team_name"`
+				Members          int    `json:"members"`
+				ActiveMembers    int    `json:"active_members"`
+				OpenPullRequests int    `json:"open_pull_requests"`
+			} `json:"teams"`
+			NextCursor *int64 `json:"next_cursor"`
+		}
+		list := func(query string) listPayload {
+			t.Helper()
+			resp := doRequest(t, client, http.MethodGet, server.URL+"/team/list?"+query, nil)
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("list %q: status %d", query, resp.StatusCode)
+			}
+			var page listPayload
+			if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			return page
+		}
+
+		first := list("prefix=back&limit=1")
+		if len(first.Teams) != 1 || first.NextCursor == nil || *first.NextCursor != 1 {
+			t.Fatalf("unexpected first page: %+v", first)
+		}
+		if team := first.Teams[0]; team.TeamName != "backend" || team.Members != 4 || team.ActiveMembers != 3 || team.OpenPullRequests != 1 {
+			t.Fatalf("unexpected backend summary: %+v", team)
+		}
+		second := list("prefix=back&limit=1&cursor=1")
+		if len(second.Teams) != 1 || second.Teams[0].TeamName != "backoffice" || second.NextCursor != nil {
+			t.Fatalf("unexpected last page: %+v", second)
+		}
+		if all := list(""); len(all.Teams) != 3 {
+			t.Fatalf("expected 3 teams, got %+v", all.Teams)
+		}
+
+		bad := doRequest(t, client, http.MethodGet, server.URL+"/team/list?limit=0", nil)
+		bad.Body.Close()
+		if bad.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for limit=0, got %d", bad.StatusCode)
+		}
+	})
+
+	t.Run("user list", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+		resp := doRequest(t, client, http.MethodPost, server.URL+"/team/add", map[string]any{
+			"team_name": "frontend",
+			"members":   []map[string]any{{"user_id": "u8", "username": "Bella", "is_active": true}},
+		})
+		resp.Body.Close()
+		resp = doRequest(t, client, http.MethodPost, server.URL+"/users/setIsActive", map[string]any{"user_id": "u4", "is_active": false})
+		resp.Body.Close()
+		pr := createPR(t, client, server.URL, "pr-list", "Listed", "u1")
+
+		type listPayload struct {
+			Users []struct {
+				UserID      string `json:"user_id"`
+				TeamName    string `json:"team_name"`
+				IsActive    bool   `json:"is_active"`
+				OpenReviews int    `json:"open_reviews"`
+			} `json:"users"`
+			NextCursor *int64 `json:"next_cursor"`
+		}
+		list := func(query string) listPayload {
+			t.Helper()
+			resp := doRequest(t, client, http.MethodGet, server.URL+"/users/list?"+query, nil)
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("list %q: status %d", query, resp.StatusCode)
+			}
+			var page listPayload
+			if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			return page
+		}
+
+		first := list("team_name=backend&is_active=true&limit=2")
+		if len(first.Users) != 2 || first.Users[0].UserID != "u1" || first.Users[1].UserID != "u2" || first.NextCursor == nil || *first.NextCursor != 2 {
+			t.Fatalf("unexpected first page: %+v", first)
+		}
+		second := list("team_name=backend&is_active=true&limit=2&cursor=2")
+		if len(second.Users) != 1 || second.Users[0].UserID != "u3" || second.NextCursor != nil {
+			t.Fatalf("unexpected last page: %+v", second)
+		}
+		for _, user := range append(first.Users, second.Users...) {
+			want := 0
+			if slices.Contains(pr.AssignedReviewers, user.UserID) {
+				want = 1
+			}
+			if user.OpenReviews != want {
+				t.Fatalf("expected %d open reviews for %s, got %d", want, user.UserID, user.OpenReviews)
+			}
+		}
+		if page := list("username_prefix=B"); len(page.Users) != 2 || page.Users[0].UserID != "u2" || page.Users[1].TeamName != "frontend" {
+			t.Fatalf("unexpected prefix page: %+v", page)
+		}
+		if page := list("is_active=false"); len(page.Users) != 1 || page.Users[0].UserID != "u4" {
+			t.Fatalf("unexpected inactive page: %+v", page)
+		}
+
+		for query, status := range map[string]int{
+			"is_active=maybe":   http.StatusBadRequest,
+			"limit=0":           http.StatusBadRequest,
+			"team_name=missing": http.StatusNotFound,
+		} {
+			resp := doRequest(t, client, http.MethodGet, server.URL+"/users/list?"+query, nil)
+			resp.Body.Close()
+			if resp.StatusCode != status {
+				t.Fatalf("%s: expected %d, got %d", query, status, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("complete review", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+		pr := createPR(t, client, server.URL, "pr-done", "Done", "u1")
+		reviewer := pr.AssignedReviewers[0]
+
+		resp := doRequest(t, client, http.MethodPost, server.URL+"/pullRequest/completeReview", map[string]string{
+			"pull_request_id": "pr-done",
+			"user_id":         reviewer,
+		})
+		var completed struct {
+			PR struct {
+				Reviewers []struct {
+					UserID    string `json:"user_id"`
+					Completed bool   `json:"completed"`
+				} `json:"reviewers"`
+			} `json:"pr"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&completed); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		for _, r := range completed.PR.Reviewers {
+			if r.Completed != (r.UserID == reviewer) {
+				t.Fatalf("unexpected completion flags: %+v", completed.PR.Reviewers)
+			}
+		}
+
+		reviews := func(query string) int {
+			t.Helper()
+			resp := doRequest(t, client, http.MethodGet, server.URL+"/users/getReview?user_id="+reviewer+query, nil)
+			defer resp.Body.Close()
+			var payload struct {
+				PullRequests []json.RawMessage `json:"pull_requests"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			return len(payload.PullRequests)
+		}
+		if all, todo := reviews(""), reviews("&exclude_completed=true"); all != 1 || todo != 0 {
+			t.Fatalf("expected the completed review listed only without the filter, got %d and %d", all, todo)
+		}
+
+		resp = doRequest(t, client, http.MethodPost, server.URL+"/pullRequest/completeReview", map[string]string{
+			"pull_request_id": "pr-done",
+			"user_id":         "u1",
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected 409 for a non-reviewer, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("assignment history", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+		pr := createPR(t, client, server.URL, "pr-history", "History", "u1")
+		reviewer := pr.AssignedReviewers[0]
+		reassign(t, client, server.URL, pr.ID, reviewer)
+
+		type historyPayload struct {
+			UserID  string `json:"user_id"`
+			History []struct {
+				PullRequestID string    `json:"pull_request_id"`
+				Action        string    `json:"action"`
+				Reason        string    `json:"reason"`
+				CreatedAt     time.Time `json:"created_at"`
+			} `json:"history"`
+			NextCursor *int64 `json:"next_cursor"`
+		}
+		history := func(query string) historyPayload {
+			t.Helper()
+			resp := doRequest(t, client, http.MethodGet, server.URL+"/users/assignmentHistory?user_id="+reviewer+query, nil)
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("history %q: status %d", query, resp.StatusCode)
+			}
+			var page historyPayload
+			if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			return page
+		}
+
+		all := history("")
+		if all.UserID != reviewer || len(all.History) != 2 || all.NextCursor != nil {
+			t.Fatalf("unexpected history: %+v", all)
+		}
+		if got := all.History[0]; got.PullRequestID != pr.ID || got.Action != "ASSIGNED" || got.Reason != "auto" {
+			t.Fatalf("unexpected assignment: %+v", got)
+		}
+		if got := all.History[1]; got.Action != "UNASSIGNED" || got.Reason != "reassign" || got.CreatedAt.Before(all.History[0].CreatedAt) {
+			t.Fatalf("unexpected removal: %+v", got)
+		}
+		if page := history("&limit=1"); len(page.History) != 1 || page.History[0].Action != "ASSIGNED" || page.NextCursor == nil || *page.NextCursor != 1 {
+			t.Fatalf("unexpected first page: %+v", page)
+		}
+		if page := history("&from=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339)); len(page.History) != 0 {
+			t.Fatalf("expected no history in the future, got %+v", page)
+		}
+
+		for query, status := range map[string]int{
+			"":                          http.StatusBadRequest,
+			"user_id=u2&from=yesterday": http.StatusBadRequest,
+			"user_id=u2&from=2025-01-02T00:00:00Z&to=2025-01-01T00:00:00Z": http.StatusBadRequest,
+			"user_id=missing": http.StatusNotFound,
+		} {
+			resp := doRequest(t, client, http.MethodGet, server.URL+"/users/assignmentHistory?"+query, nil)
+			resp.Body.Close()
+			if resp.StatusCode != status {
+				t.Fatalf("%s: expected %d, got %d", query, status, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("fault injection", func(t *testing.T) {
+		server := newTestServerWithConfig(t, config.HTTPConfig{
+			Chaos: config.ChaosConfig{
+				Enabled:   true,
+				ErrorRate: 1,
+				Routes: map[string]config.ChaosRule{
+					"/team/add": {Latency: 50 * time.Millisecond},
+					"/team/get": {},
+				},
+			},
+		})
+		defer server.Close()
+
+		client := server.Client()
+		start := time.Now()
+		createTeam(t, client, server.URL)
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Fatalf("expected injected latency, took %s", elapsed)
+		}
+
+		resp, err := client.Get(server.URL + "/v1/team/get?team_name=backend")
+		if err != nil {
+			t.Fatalf("get team: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected the route override to spare /team/get, got %d", resp.StatusCode)
+		}
+
+		resp = doRequest(t, client, http.MethodGet, server.URL+"/users/list", nil)
+		var errBody struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errBody); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable || errBody.Error.Code != "FAULT_INJECTED" {
+			t.Fatalf("expected 503 FAULT_INJECTED, got %d %q", resp.StatusCode, errBody.Error.Code)
+		}
+
+		resp, err = client.Get(server.URL + "/health")
+		if err != nil {
+			t.Fatalf("health: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected /health to be spared, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("pull request links", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+		blocked := createPR(t, client, server.URL, "pr-api", "API", "u1")
+		createPR(t, client, server.URL, "pr-schema", "Schema", "u1")
+
+		link := func(path string) []string {
+			t.Helper()
+			resp := doRequest(t, client, http.MethodPost, server.URL+path, map[string]string{
+				"pull_request_id": "pr-api",
+				"blocked_by":      "pr-schema",
+			})
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("%s: status %d", path, resp.StatusCode)
+			}
+			var body struct {
+				PR struct {
+					BlockedBy []string `json:"blocked_by"`
+				} `json:"pr"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			return body.PR.BlockedBy
+		}
+		if got := link("/pullRequest/link"); !slices.Equal(got, []string{"pr-schema"}) {
+			t.Fatalf("expected pr-api blocked by pr-schema, got %v", got)
+		}
+
+		resp := doRequest(t, client, http.MethodGet, server.URL+"/users/getReview?blocked=true&user_id="+blocked.AssignedReviewers[0], nil)
+		var reviews struct {
+			PullRequests []pullRequestPayload `json:"pull_requests"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&reviews); err != nil {
+			t.Fatalf("decode reviews: %v", err)
+		}
+		resp.Body.Close()
+		if len(reviews.PullRequests) != 1 || reviews.PullRequests[0].ID != "pr-api" {
+			t.Fatalf("expected only pr-api listed as blocked, got %+v", reviews.PullRequests)
+		}
+
+		resp = doRequest(t, client, http.MethodPut, server.URL+"/team/settings", map[string]any{
+			"team_name":                   "backend",
+			"block_merge_on_dependencies": true,
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("update settings: status %d", resp.StatusCode)
+		}
+
+		resp = doRequest(t, client, http.MethodPost, server.URL+"/pullRequest/merge", map[string]string{"pull_request_id": "pr-api"})
+		var errBody struct {
+			Error struct {
+				Code    string `json:"code"`
+				Details struct {
+					BlockedBy []string `json:"blocked_by"`
+				} `json:"details"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errBody); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict || errBody.Error.Code != "PR_BLOCKED" || !slices.Equal(errBody.Error.Details.BlockedBy, []string{"pr-schema"}) {
+			t.Fatalf("expected 409 PR_BLOCKED by pr-schema, got %d %+v", resp.StatusCode, errBody.Error)
+		}
+
+		if got := link("/pullRequest/unlink"); got != nil {
+			t.Fatalf("expected no blockers after unlinking, got %v", got)
+		}
+		if merged := merge(t, client, server.URL, "pr-api"); merged.Status != "MERGED" {
+			t.Fatalf("expected pr-api merged, got %s", merged.Status)
+		}
+	})
+
+	t.Run("notification preferences", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+
+		resp := doRequest(t, client, http.MethodPost, server.URL+"/users/setNotifications", map[string]any{
+			"user_id": "u1", "channel": "pager",
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for an unknown channel, got %d", resp.StatusCode)
+		}
+
+		resp = doRequest(t, client, http.MethodPost, server.URL+"/users/setNotifications", map[string]any{
+			"user_id": "u1", "channel": "email",
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for email without an address, got %d", resp.StatusCode)
+		}
+
+		resp = doRequest(t, client, http.MethodPost, server.URL+"/users/setNotifications", map[string]any{
+			"user_id": "u1", "channel": "email", "address": "alice@example.com", "digest_hours": -1,
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for a negative digest period, got %d", resp.StatusCode)
+		}
+
+		resp = doRequest(t, client, http.MethodPost, server.URL+"/users/setNotifications", map[string]any{
+			"user_id": "u1", "channel": "email", "address": "alice@example.com", "digest_hours": 1,
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+
+		resp = doRequest(t, client, http.MethodGet, server.URL+"/users/getNotifications?user_id=u1", nil)
+		defer resp.Body.Close()
+		var body struct {
+			Notifications struct {
+				Channel     string `json:"channel"`
+				Address     string `json:"address"`
+				DigestHours int    `json:"digest_hours"`
+			} `json:"notifications"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if body.Notifications.Channel != "email" || body.Notifications.Address != "alice@example.com" || body.Notifications.DigestHours != 1 {
+			t.Fatalf("unexpected preference: %+v", body.Notifications)
+		}
+
+		missing := doRequest(t, client, http.MethodGet, server.URL+"/users/getNotifications?user_id=ghost", nil)
+		missing.Body.Close()
+		if missing.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404 for an unknown user, got %d", missing.StatusCode)
+		}
+	})
+
+	t.Run("team tokens", func(t *testing.T) {
+		server := newTestServerWithConfig(t, config.HTTPConfig{AdminToken: "secret", RequireTeamTokens: true})
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+		resp := doRequest(t, client, http.MethodPost, server.URL+"/team/add", map[string]any{
+			"team_name": "frontend",
+			"members":   []map[string]any{{"user_id": "u9", "username": "Eve", "is_active": true}},
+		})
+		resp.Body.Close()
+
+		send := func(method, path, token string, payload any) *http.Response {
+			t.Helper()
+			body, _ := json.Marshal(payload)
+			req, err := http.NewRequest(method, server.URL+path, bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("build request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+			return resp
+		}
+		status := func(method, path, token string, payload any) int {
+			t.Helper()
+			resp := send(method, path, token, payload)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		newPR := func(id, author string) map[string]any {
+			return map[string]any{"pull_request_id": id, "pull_request_name": "Build", "author_id": author}
+		}
+
+		if got := status(http.MethodPost, "/admin/tokens", "", map[string]any{"team_name": "backend", "name": "ci"}); got != http.StatusUnauthorized {
+			t.Fatalf("expected only admins to issue tokens, got %d", got)
+		}
+		resp = send(http.MethodPost, "/admin/tokens", "secret", map[string]any{"team_name": "backend", "name": "ci"})
+		var created struct {
+			Token struct {
+				ID    int64  `json:"id"`
+				Token string `json:"token"`
+			} `json:"token"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+			t.Fatalf("decode token: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated || !strings.HasPrefix(created.Token.Token, "rvt_") {
+			t.Fatalf("expected a new token, got %d %+v", resp.StatusCode, created)
+		}
+		token := created.Token.Token
+
+		if got := status(http.MethodPost, "/pullRequest/create", "", newPR("pr-1", "u1")); got != http.StatusUnauthorized {
+			t.Fatalf("expected 401 without a token, got %d", got)
+		}
+		if got := status(http.MethodPost, "/pullRequest/create", "rvt_unknown", newPR("pr-1", "u1")); got != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for an unknown token, got %d", got)
+		}
+		if got := status(http.MethodPost, "/pullRequest/create", token, newPR("pr-1", "u1")); got != http.StatusCreated {
+			t.Fatalf("expected the team's token to create its member's pull request, got %d", got)
+		}
+		if got := status(http.MethodPost, "/pullRequest/create", token, newPR("pr-2", "u9")); got != http.StatusForbidden {
+			t.Fatalf("expected 403 for another team's author, got %d", got)
+		}
+		bulk := map[string]any{"pull_requests": []map[string]any{newPR("pr-3", "u2"), newPR("pr-4", "u9")}}
+		if got := status(http.MethodPost, "/pullRequest/bulkCreate", token, bulk); got != http.StatusForbidden {
+			t.Fatalf("expected 403 for a bulk with another team's author, got %d", got)
+		}
+		if got := status(http.MethodPost, "/pullRequest/create", "secret", newPR("pr-2", "u9")); got != http.StatusCreated {
+			t.Fatalf("expected the admin to create any pull request, got %d", got)
+		}
+
+		resp = send(http.MethodGet, "/admin/tokens?team_name=backend", "secret", nil)
+		listed, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || strings.Contains(string(listed), token) {
+			t.Fatalf("expected tokens listed without their secret, got %d %s", resp.StatusCode, listed)
+		}
+		if got := status(http.MethodDelete, "/admin/tokens?id="+strconv.FormatInt(created.Token.ID, 10), "secret", nil); got != http.StatusNoContent {
+			t.Fatalf("delete token status: %d", got)
+		}
+		if got := status(http.MethodPost, "/pullRequest/create", token, newPR("pr-5", "u1")); got != http.StatusUnauthorized {
+			t.Fatalf("expected a revoked token refused, got %d", got)
+		}
+	})
+
+	t.Run("team leads", func(t *testing.T) {
+		server := newTestServerWithConfig(t, config.HTTPConfig{AdminToken: "secret", EnforceTeamLeads: true})
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+		resp := doRequest(t, client, http.MethodPost, server.URL+"/team/add", map[string]any{
+			"team_name": "frontend",
+			"members":   []map[string]any{{"user_id": "u9", "username": "Ivan", "is_active": true}},
+		})
+		resp.Body.Close()
+
+		send := func(method, path, caller, token string, payload any) int {
+			t.Helper()
+			body, _ := json.Marshal(payload)
+			req, err := http.NewRequest(method, server.URL+path, bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("build request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			if caller != "" {
+				req.Header.Set("X-User-ID", caller)
+			}
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		issue := func(userID string) string {
+			t.Helper()
+			body, _ := json.Marshal(map[string]any{"team_name": "backend", "name": userID, "user_id": userID})
+			req, _ := http.NewRequest(http.MethodPost, server.URL+"/admin/tokens", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer secret")
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("issue token: %v", err)
+			}
+			defer resp.Body.Close()
+			var created struct {
+				Token struct {
+					Token string `json:"token"`
+				} `json:"token"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&created); err != nil || resp.StatusCode != http.StatusCreated {
+				t.Fatalf("issue token: %d %v", resp.StatusCode, err)
+			}
+			return created.Token.Token
+		}
+		settings := map[string]any{"team_name": "backend", "required_reviewers": 1}
+		leadToken, memberToken := issue("u2"), issue("u3")
+
+		if status := send(http.MethodPut, "/team/settings", "", "", settings); status != http.StatusUnauthorized {
+			t.Fatalf("expected 401 without a credential, got %d", status)
+		}
+		lead := map[string]any{"team_name": "backend", "user_id": "u2", "is_lead": true}
+		if status := send(http.MethodPost, "/team/setLead", "u2", "", lead); status != http.StatusUnauthorized {
+			t.Fatalf("expected only admins to grant the lead role, got %d", status)
+		}
+		if status := send(http.MethodPost, "/team/setLead", "", "secret", lead); status != http.StatusOK {
+			t.Fatalf("set lead status: %d", status)
+		}
+		if status := send(http.MethodPut, "/team/settings", "u2", "", settings); status != http.StatusUnauthorized {
+			t.Fatalf("expected the X-User-ID header alone not to identify a lead, got %d", status)
+		}
+		if status := send(http.MethodPut, "/team/settings", "u2", memberToken, settings); status != http.StatusForbidden {
+			t.Fatalf("expected 403 for a member who is not a lead naming the lead, got %d", status)
+		}
+		if status := send(http.MethodPut, "/team/settings", "", leadToken, settings); status != http.StatusOK {
+			t.Fatalf("expected the lead to update settings, got %d", status)
+		}
+		if status := send(http.MethodPut, "/team/settings", "", "secret", settings); status != http.StatusOK {
+			t.Fatalf("expected the admin to update settings, got %d", status)
+		}
+
+		pr := createPR(t, client, server.URL, "pr-1", "Add search", "u1")
+		reassign := map[string]string{"pull_request_id": pr.ID, "old_user_id": pr.AssignedReviewers[0]}
+		if status := send(http.MethodPost, "/pullRequest/reassign", "", memberToken, reassign); status != http.StatusForbidden {
+			t.Fatalf("expected 403 on a forced reassignment by a non-lead, got %d", status)
+		}
+		if status := send(http.MethodPost, "/pullRequest/reassign", "", leadToken, reassign); status != http.StatusOK {
+			t.Fatalf("expected the lead to reassign, got %d", status)
+		}
+
+		forced := map[string]any{"pull_request_id": pr.ID, "force": true, "reason": "release blocker"}
+		if status := send(http.MethodPost, "/pullRequest/merge", "", memberToken, forced); status != http.StatusForbidden {
+			t.Fatalf("expected 403 on a forced merge by a non-lead, got %d", status)
+		}
+		if status := send(http.MethodPost, "/pullRequest/merge", "", leadToken, forced); status != http.StatusOK {
+			t.Fatalf("expected the lead to force a merge, got %d", status)
+		}
+
+		split := map[string]any{"team_name": "backend", "new_team_name": "search", "user_ids": []string{"u4"}}
+		if status := send(http.MethodPost, "/team/split", "u2", "", split); status != http.StatusUnauthorized {
+			t.Fatalf("expected 401 on a split without a credential, got %d", status)
+		}
+		if status := send(http.MethodPost, "/team/split", "", memberToken, split); status != http.StatusForbidden {
+			t.Fatalf("expected 403 on a split by a non-lead, got %d", status)
+		}
+		if status := send(http.MethodPost, "/team/split", "", leadToken, split); status != http.StatusCreated {
+			t.Fatalf("expected the lead to split the team, got %d", status)
+		}
+		merge := map[string]any{"source_team": "frontend", "target_team": "backend"}
+		if status := send(http.MethodPost, "/team/merge", "", leadToken, merge); status != http.StatusForbidden {
+			t.Fatalf("expected 403 on a merge of a team the caller does not lead, got %d", status)
+		}
+		if status := send(http.MethodPost, "/team/merge", "", "secret", merge); status != http.StatusOK {
+			t.Fatalf("expected the admin to merge the teams, got %d", status)
+		}
+	})
+
+	t.Run("deadline extensions", func(t *testing.T) {
+		server := newTestServerWithConfig(t, config.HTTPConfig{AdminToken: "secret", EnforceTeamLeads: true})
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+		sdk := reviewerclient.New(reviewerclient.Config{BaseURL: server.URL, HTTPClient: client})
+		ctx := context.Background()
+
+		lead, _ := json.Marshal(map[string]any{"team_name": "backend", "user_id": "u4", "is_lead": true})
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/team/setLead", bytes.NewReader(lead))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("set lead: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("set lead status: %d", resp.StatusCode)
+		}
+
+		pr := createPR(t, client, server.URL, "pr-ext", "Add export", "u1")
+		until := time.Now().UTC().Add(48 * time.Hour).Truncate(time.Second)
+		extension, err := sdk.ExtendDeadline(ctx, pr.ID, pr.AssignedReviewers[0], until, "on call this week")
+		if err != nil {
+			t.Fatalf("ExtendDeadline: %v", err)
+		}
+		if extension.Status != reviewerclient.ExtensionPending {
+			t.Fatalf("expected a pending extension, got %+v", extension)
+		}
+
+		decide := func(caller string) int {
+			t.Helper()
+			issue, _ := json.Marshal(map[string]any{"team_name": "backend", "name": caller, "user_id": caller})
+			req, _ := http.NewRequest(http.MethodPost, server.URL+"/admin/tokens", bytes.NewReader(issue))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer secret")
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("issue token: %v", err)
+			}
+			var created struct {
+				Token struct {
+					Token string `json:"token"`
+				} `json:"token"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+				t.Fatalf("decode token: %v", err)
+			}
+			resp.Body.Close()
+
+			body, _ := json.Marshal(map[string]any{"extension_id": extension.ID, "approve": true})
+			req, _ = http.NewRequest(http.MethodPost, server.URL+"/pullRequest/decideExtension", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+created.Token.Token)
+			resp, err = client.Do(req)
+			if err != nil {
+				t.Fatalf("decide extension: %v", err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		if status := decide("u1"); status != http.StatusForbidden {
+			t.Fatalf("expected 403 for a member who is not a lead, got %d", status)
+		}
+		if status := decide("u4"); status != http.StatusOK {
+			t.Fatalf("expected the lead to approve, got %d", status)
+		}
+		if status := decide("u4"); status != http.StatusConflict {
+			t.Fatalf("expected 409 on a decided extension, got %d", status)
+		}
+
+		resp = doRequest(t, client, http.MethodGet, server.URL+"/pullRequest/get?pull_request_id=pr-ext", nil)
+		var got struct {
+			PR struct {
+				Deadline *time.Time `json:"deadline"`
+			} `json:"pr"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatalf("decode pull request: %v", err)
+		}
+		resp.Body.Close()
+		if got.PR.Deadline == nil || !got.PR.Deadline.Equal(until) {
+			t.Fatalf("expected deadline %v, got %v", until, got.PR.Deadline)
+		}
+
+		extensions, err := sdk.ListExtensions(ctx, pr.ID)
+		if err != nil {
+			t.Fatalf("ListExtensions: %v", err)
+		}
+		if len(extensions) != 1 || extensions[0].Status != reviewerclient.ExtensionApproved || extensions[0].DecidedBy != "u4" {
+			t.Fatalf("unexpected extensions: %+v", extensions)
+		}
+	})
+
+	t.Run("request signatures", func(t *testing.T) {
+		server := newTestServerWithConfig(t, config.HTTPConfig{
+			AdminToken:       "secret",
+			SigningSecret:    "shared",
+			SignatureMaxAge:  time.Minute,
+			RequireSignature: true,
+		})
+		defer server.Close()
+
+		client := server.Client()
+		ctx := context.Background()
+
+		resp := doRequest(t, client, http.MethodPost, server.URL+"/team/add", map[string]any{"team_name": "backend"})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for an unsigned request, got %d", resp.StatusCode)
+		}
+
+		sdk := reviewerclient.New(reviewerclient.Config{BaseURL: server.URL, HTTPClient: client, SigningSecret: "shared"})
+		if _, err := sdk.CreateTeam(ctx, reviewerclient.Team{
+			Name:    "backend",
+			Members: []reviewerclient.Member{{UserID: "u1", Username: "Alice", IsActive: true}},
+		}); err != nil {
+			t.Fatalf("signed CreateTeam: %v", err)
+		}
+
+		body := []byte(`{"enabled":false}`)
+		signed, err := http.NewRequest(http.MethodPost, server.URL+"/v1/admin/maintenance", nil)
+		if err != nil {
+			t.Fatalf("build request: %v", err)
+		}
+		if err := signature.Apply(signed, "shared", body, time.Now()); err != nil {
+			t.Fatalf("sign request: %v", err)
+		}
+		send := func(secret string) int {
+			t.Helper()
+			req, _ := http.NewRequest(http.MethodPost, signed.URL.String(), bytes.NewReader(body))
+			req.Header = signed.Header.Clone()
+			req.Header.Set("Content-Type", "application/json")
+			if secret != "" {
+				req.Header.Set(signature.HeaderSignature, signature.Sign(secret, time.Now().Unix(), "other", req.Method, req.URL.RequestURI(), body))
+				req.Header.Set(signature.HeaderTimestamp, strconv.FormatInt(time.Now().Unix(), 10))
+				req.Header.Set(signature.HeaderNonce, "other")
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("send signed request: %v", err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		if status := send(""); status != http.StatusOK {
+			t.Fatalf("expected a signed request to pass the admin check, got %d", status)
+		}
+		if status := send(""); status != http.StatusUnauthorized {
+			t.Fatalf("expected a replayed request to be refused, got %d", status)
+		}
+		if status := send("wrong"); status != http.StatusUnauthorized {
+			t.Fatalf("expected a request signed with another secret to be refused, got %d", status)
+		}
+	})
+
+	t.Run("write queue", func(t *testing.T) {
+		repo := &downableRepository{Repository: storagetest.New(t)}
+		handler := httptransport.NewHandler(service.New(repo), config.HTTPConfig{})
+		writes, err := writequeue.Open(filepath.Join(t.TempDir(), "writes.jsonl"), 2, time.Hour)
+		if err != nil {
+			t.Fatalf("open write queue: %v", err)
+		}
+		defer writes.Close()
+		handler.SetWriteQueue(writes)
+		router := handler.Router()
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+
+		ready := func() (int, map[string]any) {
+			t.Helper()
+			resp, err := client.Get(server.URL + "/health/ready")
+			if err != nil {
+				t.Fatalf("ready request: %v", err)
+			}
+			defer resp.Body.Close()
+			var body map[string]any
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode ready: %v", err)
+			}
+			return resp.StatusCode, body
+		}
+		if status, body := ready(); status != http.StatusOK || body["status"] != "ok" {
+			t.Fatalf("expected ready while the storage is up, got %d %v", status, body)
+		}
+
+		repo.down.Store(true)
+		for _, id := range []string{"pr-queued-1", "pr-queued-2"} {
+			resp := doRequest(t, client, http.MethodPost, server.URL+"/pullRequest/create", map[string]any{
+				"pull_request_id":   id,
+				"pull_request_name": "Queued",
+				"author_id":         "u1",
+			})
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusAccepted {
+				t.Fatalf("expected 202 for a write while the storage is down, got %d", resp.StatusCode)
+			}
+		}
+		if status, _ := ready(); status != http.StatusServiceUnavailable {
+			t.Fatalf("expected unready with a full queue, got %d", status)
+		}
+		resp := doRequest(t, client, http.MethodPost, server.URL+"/pullRequest/merge", map[string]any{
+			"pull_request_id": "pr-queued-1",
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected 503 with a full queue, got %d", resp.StatusCode)
+		}
+
+		result, err := handler.ReplayWrites(context.Background(), router)
+		if err != nil || result.Replayed != 0 {
+			t.Fatalf("expected no replay while the storage is down, got %+v, %v", result, err)
+		}
+
+		repo.down.Store(false)
+		result, err = handler.ReplayWrites(context.Background(), router)
+		if err != nil {
+			t.Fatalf("replay writes: %v", err)
+		}
+		if result.Replayed != 2 || writes.Len() != 0 {
+			t.Fatalf("expected both writes replayed, got %+v with %d left", result, writes.Len())
+		}
+		resp, err = client.Get(server.URL + "/pullRequest/get?pull_request_id=pr-queued-2")
+		if err != nil {
+			t.Fatalf("get pull request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected the replayed pull request to exist, got %d", resp.StatusCode)
+		}
+
+		// Once drained, writes go straight to the storage again.
+		merge(t, client, server.URL, "pr-queued-1")
+	})
+
 	t.Run("health", func(t *testing.T) {
 		server := newTestServer(t)
 		defer server.Close()
@@ -70,72 +1931,475 @@ func TestE2EFlow(t *testing.T) {
 		if resp.StatusCode != http.StatusOK {
 			t.Fatalf("health status: %d", resp.StatusCode)
 		}
+		if resp.Header.Get("X-Request-Id") == "" {
+			t.Fatalf("expected a request id on the response")
+		}
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/health", nil)
+		if err != nil {
+			t.Fatalf("build request: %v", err)
+		}
+		req.Header.Set("X-Request-Id", "trace-42")
+		resp, err = client.Do(req)
+		if err != nil {
+			t.Fatalf("health request: %v", err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("X-Request-Id"); got != "trace-42" {
+			t.Fatalf("expected the client's request id to be echoed, got %q", got)
+		}
+
+		resp, err = client.Head(server.URL + "/health?deep=false")
+		if err != nil {
+			t.Fatalf("health head request: %v", err)
+		}
+		resp.Body.Close()
+		etag := resp.Header.Get("ETag")
+		if resp.StatusCode != http.StatusOK || etag == "" {
+			t.Fatalf("expected 200 with an ETag on HEAD, got %d %q", resp.StatusCode, etag)
+		}
+
+		req, err = http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/health", nil)
+		if err != nil {
+			t.Fatalf("build request: %v", err)
+		}
+		req.Header.Set("If-None-Match", etag)
+		resp, err = client.Do(req)
+		if err != nil {
+			t.Fatalf("conditional health request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected 304 for a matching ETag, got %d", resp.StatusCode)
+		}
+
+		resp, err = client.Get(server.URL + "/health?deep=maybe")
+		if err != nil {
+			t.Fatalf("health request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for a malformed deep flag, got %d", resp.StatusCode)
+		}
 	})
-}
 
-// Helpers
+	t.Run("snapshot export and import", func(t *testing.T) {
+		source := newTestServerWithConfig(t, config.HTTPConfig{AdminToken: "secret"})
+		defer source.Close()
+		target := newTestServerWithConfig(t, config.HTTPConfig{AdminToken: "secret"})
+		defer target.Close()
+
+		client := source.Client()
+		createTeam(t, client, source.URL)
+		pr := createPR(t, client, source.URL, "pr-snap", "Snapshot", "u1")
+		resp := doRequest(t, client, http.MethodPut, source.URL+"/team/settings", map[string]any{
+			"team_name":                   "backend",
+			"block_merge_on_dependencies": true,
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("update settings: status %d", resp.StatusCode)
+		}
 
-func newTestServer(t *testing.T) *httptest.Server {
-	t.Helper()
+		admin := func(baseURL, path string, body []byte) (int, []byte) {
+			t.Helper()
+			req, err := http.NewRequest(http.MethodPost, baseURL+path, bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("build request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer secret")
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+			defer resp.Body.Close()
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("read %s: %v", path, err)
+			}
+			return resp.StatusCode, data
+		}
+		type snapshot struct {
+			Teams        []json.RawMessage `json:"teams"`
+			Users        []json.RawMessage `json:"users"`
+			PullRequests []json.RawMessage `json:"pull_requests"`
+		}
+		export := func(baseURL string) (snapshot, []byte) {
+			t.Helper()
+			status, data := admin(baseURL, "/admin/export", nil)
+			if status != http.StatusOK {
+				t.Fatalf("export status: %d %s", status, data)
+			}
+			var decoded snapshot
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("decode snapshot: %v", err)
+			}
+			return decoded, data
+		}
 
-	ctx := context.Background()
+		exported, raw := export(source.URL)
+		if len(exported.Teams) != 1 || len(exported.Users) != 4 || len(exported.PullRequests) != 1 {
+			t.Fatalf("unexpected snapshot: %s", raw)
+		}
+		if !bytes.Contains(exported.Teams[0], []byte(`"block_merge_on_dependencies":true`)) {
+			t.Fatalf("expected the team's settings in the snapshot, got %s", exported.Teams[0])
+		}
 
-	postgresContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
-		ContainerRequest: testcontainers.ContainerRequest{
-			Image:        "postgres:15-alpine",
-			ExposedPorts: []string{"5432/tcp"},
-			Env: map[string]string{
-				"POSTGRES_USER":     "test",
-				"POSTGRES_PASSWORD": "test",
-				"POSTGRES_DB":       "test",
-			},
-			WaitingFor: wait.ForLog("database system is ready to accept connections").
-				WithOccurrence(2).
-				WithStartupTimeout(30 * time.Second),
-		},
-		Started: true,
+		status, data := admin(target.URL, "/admin/import?dry_run=true", raw)
+		var result struct {
+			DryRun       bool `json:"dry_run"`
+			Teams        int  `json:"teams"`
+			Users        int  `json:"users"`
+			PullRequests int  `json:"pull_requests"`
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatalf("decode dry run: %v", err)
+		}
+		if status != http.StatusOK || !result.DryRun || result.Teams != 1 || result.Users != 4 || result.PullRequests != 1 {
+			t.Fatalf("unexpected dry run %d: %s", status, data)
+		}
+		if restored, _ := export(target.URL); len(restored.Teams) != 0 {
+			t.Fatalf("expected a dry run to write nothing, got %d teams", len(restored.Teams))
+		}
+
+		if status, data := admin(target.URL, "/admin/import", raw); status != http.StatusCreated {
+			t.Fatalf("import status: %d %s", status, data)
+		}
+		assertGetTeam(t, client, target.URL)
+		restored, _ := export(target.URL)
+		want, _ := json.Marshal(exported)
+		if got, _ := json.Marshal(restored); !bytes.Equal(got, want) {
+			t.Fatalf("expected the imported state to match the export\nwant %s\ngot  %s", want, got)
+		}
+		resp, err := client.Get(target.URL + "/pullRequest/get?pull_request_id=pr-snap")
+		if err != nil {
+			t.Fatalf("get pr: %v", err)
+		}
+		var got prResponse
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatalf("decode pr: %v", err)
+		}
+		resp.Body.Close()
+		if !slices.Equal(got.PR.AssignedReviewers, pr.AssignedReviewers) {
+			t.Fatalf("expected reviewers %v after import, got %v", pr.AssignedReviewers, got.PR.AssignedReviewers)
+		}
+
+		var failure struct {
+			Error struct {
+				Code    string `json:"code"`
+				Details struct {
+					Problems []string `json:"problems"`
+				} `json:"details"`
+			} `json:"error"`
+		}
+		status, data = admin(target.URL, "/admin/import", raw)
+		if err := json.Unmarshal(data, &failure); err != nil {
+			t.Fatalf("decode failure: %v", err)
+		}
+		if status != http.StatusConflict || failure.Error.Code != "NOT_EMPTY" {
+			t.Fatalf("expected 409 NOT_EMPTY for a second import, got %d %s", status, data)
+		}
+
+		broken := []byte(`{"teams":[{"team_name":"backend"}],"users":[],"pull_requests":[` +
+			`{"pull_request_id":"pr-x","pull_request_name":"X","author_id":"ghost","status":"OPEN","created_at":"2025-01-01T00:00:00Z"}]}`)
+		status, data = admin(target.URL, "/admin/import?dry_run=true", broken)
+		failure.Error.Details.Problems = nil
+		if err := json.Unmarshal(data, &failure); err != nil {
+			t.Fatalf("decode failure: %v", err)
+		}
+		if status != http.StatusBadRequest || failure.Error.Code != "INVALID_SNAPSHOT" || len(failure.Error.Details.Problems) != 1 {
+			t.Fatalf("expected 400 INVALID_SNAPSHOT with one problem, got %d %s", status, data)
+		}
 	})
-	if err != nil {
-		t.Fatalf("failed to start postgres container: %v", err)
-	}
 
-	t.Cleanup(func() {
-		if err := postgresContainer.Terminate(ctx); err != nil {
-			t.Logf("failed to terminate postgres container: %v", err)
+	t.Run("migrations", func(t *testing.T) {
+		server := newTestServerWithConfig(t, config.HTTPConfig{AdminToken: "secret"})
+		defer server.Close()
+
+		resp := doRequest(t, server.Client(), http.MethodGet, server.URL+"/admin/migrations", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected 401 without the admin token, got %d", resp.StatusCode)
+		}
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/admin/migrations", nil)
+		if err != nil {
+			t.Fatalf("build request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err = server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("migrations request: %v", err)
+		}
+		var failure struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&failure)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("decode failure: %v", err)
+		}
+		// The memory storage has no schema to migrate.
+		if resp.StatusCode != http.StatusNotImplemented || failure.Error.Code != "NOT_SUPPORTED" {
+			t.Fatalf("expected 501 NOT_SUPPORTED, got %d %+v", resp.StatusCode, failure)
 		}
 	})
 
-	host, err := postgresContainer.Host(ctx)
-	if err != nil {
-		t.Fatalf("failed to get postgres host: %v", err)
-	}
+	t.Run("identities", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
 
-	port, err := postgresContainer.MappedPort(ctx, "5432")
-	if err != nil {
-		t.Fatalf("failed to get postgres port: %v", err)
+		client := server.Client()
+		createTeam(t, client, server.URL)
+
+		add := func(externalID string) int {
+			t.Helper()
+			resp := doRequest(t, client, http.MethodPost, server.URL+"/users/addIdentity", map[string]string{
+				"user_id":     "u1",
+				"provider":    "github",
+				"external_id": externalID,
+			})
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		if status := add("   "); status != http.StatusBadRequest {
+			t.Fatalf("expected 400 for a blank external_id, got %d", status)
+		}
+		if status := add(" Alice "); status != http.StatusCreated {
+			t.Fatalf("expected the identity to be added, got %d", status)
+		}
+
+		resp := doRequest(t, client, http.MethodGet, server.URL+"/users/resolveIdentity?provider=github&external_id=%20%09", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for resolving a blank external_id, got %d", resp.StatusCode)
+		}
+
+		resp = doRequest(t, client, http.MethodGet, server.URL+"/users/resolveIdentity?provider=github&external_id=ALICE", nil)
+		defer resp.Body.Close()
+		var resolved struct {
+			User struct {
+				UserID string `json:"user_id"`
+			} `json:"user"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&resolved); err != nil {
+			t.Fatalf("decode resolved user: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || resolved.User.UserID != "u1" {
+			t.Fatalf("expected u1 for the trimmed login, got %d %+v", resp.StatusCode, resolved)
+		}
+	})
+
+	t.Run("changes tail", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		client := server.Client()
+		createTeam(t, client, server.URL)
+		createPR(t, client, server.URL, "pr-1", "First", "u1")
+		createPR(t, client, server.URL, "pr-2", "Second", "u1")
+		merge(t, client, server.URL, "pr-1")
+
+		type changesPage struct {
+			Events []struct {
+				Seq      int64  `json:"seq"`
+				Type     string `json:"type"`
+				EntityID string `json:"entity_id"`
+			} `json:"events"`
+			NextCursor int64 `json:"next_cursor"`
+		}
+		list := func(query string) changesPage {
+			t.Helper()
+			resp := doRequest(t, client, http.MethodGet, server.URL+"/changes?"+query, nil)
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("changes?%s: status %d", query, resp.StatusCode)
+			}
+			var page changesPage
+			if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+				t.Fatalf("decode changes: %v", err)
+			}
+			return page
+		}
+
+		all := list("limit=100")
+		tail := list("tail=true&limit=2")
+		if len(tail.Events) != 2 || len(all.Events) < 3 {
+			t.Fatalf("expected the last 2 of %d events, got %+v", len(all.Events), tail.Events)
+		}
+		last := all.Events[len(all.Events)-1]
+		if tail.Events[0].Seq != all.Events[len(all.Events)-2].Seq || tail.Events[1].Seq != last.Seq || tail.NextCursor != last.Seq {
+			t.Fatalf("expected the tail to end at seq %d oldest first, got %+v", last.Seq, tail)
+		}
+		if tail.Events[1].Type != "PR_MERGED" || tail.Events[1].EntityID != "pr-1" {
+			t.Fatalf("expected the merge last, got %+v", tail.Events[1])
+		}
+
+		// The cursor of the tail follows the feed from there on.
+		merge(t, client, server.URL, "pr-2")
+		next := list(fmt.Sprintf("since=%d", tail.NextCursor))
+		if len(next.Events) != 1 || next.Events[0].EntityID != "pr-2" {
+			t.Fatalf("expected only the new merge after the tail, got %+v", next.Events)
+		}
+
+		resp := doRequest(t, client, http.MethodGet, server.URL+"/changes?tail=true&since=1", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for tail with since, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("dashboard", func(t *testing.T) {
+		server := newTestServer(t)
+		defer server.Close()
+
+		resp, err := server.Client().Get(server.URL + "/ui")
+		if err != nil {
+			t.Fatalf("dashboard request: %v", err)
+		}
+		page, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("read dashboard: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+			t.Fatalf("expected 200 text/html, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		// The page reads its data from the v1 API.
+		if !bytes.Contains(page, []byte(`const api = "/v1";`)) {
+			t.Fatalf("expected the dashboard to read the v1 API, got %s", page)
+		}
+	})
+	t.Run("pool stats", func(t *testing.T) {
+		memory := newTestServerWithConfig(t, config.HTTPConfig{AdminToken: "secret"})
+		defer memory.Close()
+
+		// The pool is found behind the decorators wrapping the store.
+		repo := retry.New(&pooledRepository{Repository: storagetest.New(t)}, config.StorageRetryConfig{})
+		pooled := httptest.NewServer(httptransport.NewHandler(service.New(repo), config.HTTPConfig{AdminToken: "secret"}).Router())
+		defer pooled.Close()
+
+		get := func(server *httptest.Server, token string) *http.Response {
+			t.Helper()
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/admin/dbstats", nil)
+			if err != nil {
+				t.Fatalf("build request: %v", err)
+			}
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatalf("get dbstats: %v", err)
+			}
+			return resp
+		}
+
+		resp := get(pooled, "")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected 401 without the admin token, got %d", resp.StatusCode)
+		}
+		resp = get(memory, "secret")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotImplemented {
+			t.Fatalf("expected 501 for a store without a pool, got %d", resp.StatusCode)
+		}
+
+		resp = get(pooled, "secret")
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var stats map[string]int64
+		if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		want := map[string]int64{
+			"acquired_conns":         3,
+			"idle_conns":             1,
+			"total_conns":            4,
+			"max_conns":              8,
+			"acquire_count":          120,
+			"acquire_duration_ms":    1500,
+			"empty_acquire_count":    7,
+			"canceled_acquire_count": 2,
+		}
+		if !maps.Equal(stats, want) {
+			t.Fatalf("expected %v, got %v", want, stats)
+		}
+	})
+}
+
+// Helpers
+
+// downableRepository fails health pings while down is set, as a store whose
+// database went away would.
+type downableRepository struct {
+	storage.Repository
+	down atomic.Bool
+}
+
+func (r *downableRepository) Health(ctx context.Context) error {
+	if r.down.Load() {
+		return errors.New("database is down")
 	}
+	return r.Repository.Health(ctx)
+}
+
+// pooledRepository reports fixed connection pool statistics, as a store
+// backed by a pool would.
+type pooledRepository struct {
+	storage.Repository
+}
 
-	pgConfig := config.PostgresConfig{
-		Host:     host,
-		Port:     port.Port(),
-		User:     "test",
-		Password: "test",
-		DBName:   "test",
-		SSLMode:  "disable",
-		MaxConns: 4,
+func (r *pooledRepository) PoolStats() storage.PoolStats {
+	return storage.PoolStats{
+		AcquiredConns:        3,
+		IdleConns:            1,
+		TotalConns:           4,
+		MaxConns:             8,
+		AcquireCount:         120,
+		AcquireDuration:      1500 * time.Millisecond,
+		EmptyAcquireCount:    7,
+		CanceledAcquireCount: 2,
 	}
+}
 
-	store, err := postgres.New(ctx, pgConfig)
-	if err != nil {
-		t.Fatalf("failed to create postgres store: %v", err)
+// flakyTransport answers the first request to failPath with 503 without
+// passing it on.
+type flakyTransport struct {
+	next     http.RoundTripper
+	failPath string
+	failures int
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == f.failPath && f.failures == 0 {
+		f.failures++
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"error":{"code":"MAINTENANCE","message":"busy"}}`)),
+			Request:    req,
+		}, nil
 	}
+	return f.next.RoundTrip(req)
+}
 
-	t.Cleanup(func() {
-		store.Close()
-	})
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return newTestServerWithConfig(t, config.HTTPConfig{})
+}
+
+func newTestServerWithConfig(t *testing.T, cfg config.HTTPConfig) *httptest.Server {
+	t.Helper()
 
-	svc := service.New(store)
-	handler := httptransport.NewHandler(svc)
+	svc := service.New(storagetest.New(t))
+	handler := httptransport.NewHandler(svc, cfg)
 
 	return httptest.NewServer(handler.Router())
 }
@@ -0,0 +1,68 @@
+// Package eventbus fans domain events out to in-process subscribers such as
+// streaming endpoints.
+package eventbus
+
+import (
+	"sync"
+
+	"Avito2025/internal/domain"
+)
+
+// Filter reports whether a subscriber wants an event. A nil filter accepts all.
+type Filter func(domain.Event) bool
+
+type subscriber struct {
+	ch     chan domain.Event
+	filter Filter
+}
+
+// Bus delivers published events to subscribers without ever blocking the
+// publisher: a subscriber whose buffer is full misses the event and is
+// expected to catch up through the persisted change feed.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*subscriber]struct{}
+}
+
+func New() *Bus {
+	return &Bus{subscribers: make(map[*subscriber]struct{})}
+}
+
+// Subscribe registers a subscriber and returns its channel together with a
+// function that unregisters it and closes the channel.
+func (b *Bus) Subscribe(buffer int, filter Filter) (<-chan domain.Event, func()) {
+	sub := &subscriber{
+		ch:     make(chan domain.Event, buffer),
+		filter: filter,
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, sub)
+			b.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+	return sub.ch, cancel
+}
+
+func (b *Bus) Publish(event domain.Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+}
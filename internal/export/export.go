@@ -0,0 +1,136 @@
+// Package export ships old events to an S3-compatible bucket as gzipped JSON
+// Lines and prunes them from the database, which keeps the events table
+// small without losing the history.
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"Avito2025/internal/config"
+	"Avito2025/internal/domain"
+	"Avito2025/internal/metrics"
+)
+
+// Store reads events oldest first and prunes the exported ones.
+type Store interface {
+	ListChanges(ctx context.Context, since int64, limit int) ([]domain.Event, error)
+	PruneEvents(ctx context.Context, throughSeq int64) (int, error)
+}
+
+// Uploader stores one object under key.
+type Uploader interface {
+	Put(ctx context.Context, key string, body []byte) error
+}
+
+// Exporter moves events older than MinAge to the bucket, BatchSize events per
+// object. Objects are named after the sequence numbers they hold, so a batch
+// that was uploaded but not pruned is uploaded again under the same key on
+// the next run.
+type Exporter struct {
+	store     Store
+	uploader  Uploader
+	prefix    string
+	minAge    time.Duration
+	batchSize int
+}
+
+// New builds an exporter that uploads to the bucket configured in cfg.
+func New(store Store, cfg config.ExportConfig) *Exporter {
+	return NewWithUploader(store, newS3Uploader(cfg), cfg)
+}
+
+// NewWithUploader builds an exporter that uploads with uploader.
+func NewWithUploader(store Store, uploader Uploader, cfg config.ExportConfig) *Exporter {
+	batchSize := cfg.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &Exporter{
+		store:     store,
+		uploader:  uploader,
+		prefix:    cfg.Prefix,
+		minAge:    cfg.MinAge,
+		batchSize: batchSize,
+	}
+}
+
+// exportedEvent is the JSON form of an event in an exported object.
+type exportedEvent struct {
+	Seq       int64          `json:"seq"`
+	Type      string         `json:"type"`
+	TeamName  string         `json:"team_name,omitempty"`
+	EntityID  string         `json:"entity_id"`
+	Payload   map[string]any `json:"payload"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Export uploads and prunes the events created before now minus MinAge and
+// returns how many were exported.
+func (e *Exporter) Export(ctx context.Context, now time.Time) (int, error) {
+	cutoff := now.Add(-e.minAge)
+	exported := 0
+	for {
+		events, err := e.store.ListChanges(ctx, 0, e.batchSize)
+		if err != nil {
+			return exported, err
+		}
+		due := len(events)
+		for i, event := range events {
+			if !event.CreatedAt.Before(cutoff) {
+				due = i
+				break
+			}
+		}
+		if due == 0 {
+			return exported, nil
+		}
+		batch := events[:due]
+
+		body, err := encode(batch)
+		if err != nil {
+			return exported, err
+		}
+		first, last := batch[0], batch[len(batch)-1]
+		key := fmt.Sprintf("%s%s/events-%020d-%020d.jsonl.gz", e.prefix, first.CreatedAt.UTC().Format("2006/01/02"), first.Seq, last.Seq)
+		if err := e.uploader.Put(ctx, key, body); err != nil {
+			metrics.ObserveExport(0, err)
+			return exported, fmt.Errorf("upload %s: %w", key, err)
+		}
+		if _, err := e.store.PruneEvents(ctx, last.Seq); err != nil {
+			return exported, err
+		}
+		metrics.ObserveExport(len(batch), nil)
+		exported += len(batch)
+
+		if due < len(events) || len(events) < e.batchSize {
+			return exported, nil
+		}
+	}
+}
+
+func encode(events []domain.Event) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, event := range events {
+		if err := enc.Encode(exportedEvent{
+			Seq:       event.Seq,
+			Type:      string(event.Type),
+			TeamName:  event.TeamName,
+			EntityID:  event.EntityID,
+			Payload:   event.Payload,
+			CreatedAt: event.CreatedAt,
+		}); err != nil {
+			return nil, fmt.Errorf("encode event %d: %w", event.Seq, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,145 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"Avito2025/internal/config"
+)
+
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat    = "20060102T150405Z"
+)
+
+// s3Uploader puts objects into a bucket of an S3-compatible store, addressing
+// it path-style so that MinIO and the like work without DNS set up for the
+// bucket. Requests are signed with AWS Signature Version 4.
+type s3Uploader struct {
+	endpoint  string
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	now       func() time.Time
+}
+
+func newS3Uploader(cfg config.ExportConfig) *s3Uploader {
+	return &s3Uploader{
+		endpoint:  strings.TrimRight(cfg.Endpoint, "/"),
+		bucket:    cfg.Bucket,
+		region:    cfg.Region,
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		client:    &http.Client{Timeout: cfg.Timeout},
+		now:       time.Now,
+	}
+}
+
+func (u *s3Uploader) Put(ctx context.Context, key string, body []byte) error {
+	path := "/" + uriEncode(u.bucket, false) + "/" + uriEncode(key, true)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	u.sign(req, path, body)
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("put %s: status %d: %s", key, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// sign adds the x-amz-* headers and the Authorization header. Host, the
+// payload hash, the date and the content type are signed.
+func (u *s3Uploader) sign(req *http.Request, path string, body []byte) {
+	now := u.now().UTC()
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	signature, signedHeaders := signV4(u.secretKey, u.region, req.Method, path, req.URL.RawQuery, headers, payloadHash, now)
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", now.Format("20060102"), u.region)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, u.accessKey, scope, signedHeaders, signature))
+}
+
+// signV4 returns the Signature Version 4 signature of a request to S3 and the
+// list of headers it covers. path and query must already be URI-encoded.
+func signV4(secretKey, region, method, path, query string, headers map[string]string, payloadHash string, now time.Time) (string, string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method, path, query, canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+
+	day := now.Format("20060102")
+	scope := day + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		signingAlgorithm, now.Format(amzDateFormat), scope, sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign)), signedHeaders
+}
+
+// uriEncode escapes everything but the unreserved characters, as Signature
+// Version 4 requires; slashes are kept when keepSlash is set.
+func uriEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
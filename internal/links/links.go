@@ -0,0 +1,42 @@
+// Package links builds the canonical URLs of API resources, so that HTTP
+// payloads and webhook deliveries can point clients at them instead of making
+// them hard-code routes.
+package links
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Builder builds links under the public root of one API version, such as
+// https://reviews.example.com/v1. The zero Builder builds no links.
+type Builder struct {
+	base string
+}
+
+// New returns a Builder of links under base. Trailing slashes are dropped.
+func New(base string) Builder {
+	return Builder{base: strings.TrimRight(base, "/")}
+}
+
+// PullRequest links to the pull request.
+func (b Builder) PullRequest(id string) string {
+	return b.link("/pullRequest/get", "pull_request_id", id)
+}
+
+// Team links to the team and its members.
+func (b Builder) Team(name string) string {
+	return b.link("/team/get", "team_name", name)
+}
+
+// Reviews links to the pull requests the user reviews.
+func (b Builder) Reviews(userID string) string {
+	return b.link("/users/getReview", "user_id", userID)
+}
+
+func (b Builder) link(path, param, value string) string {
+	if b.base == "" || value == "" {
+		return ""
+	}
+	return b.base + path + "?" + url.Values{param: {value}}.Encode()
+}
@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Classes of error responses. Client errors are business-level rejections
+// such as NO_CANDIDATE or TEAM_EXISTS; server errors, timeouts and
+// unavailability included, point at the infrastructure.
+const (
+	ClassBusiness       = "business"
+	ClassInfrastructure = "infrastructure"
+)
+
+// MaxErrorWindow is the longest period RecentErrors can summarize.
+const MaxErrorWindow = time.Hour
+
+var httpRequests = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "http",
+	Name:      "requests_total",
+	Help:      "HTTP requests received.",
+})
+
+var httpErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "http",
+	Name:      "errors_total",
+	Help:      "HTTP error responses by error code and class.",
+}, []string{"code", "class"})
+
+func init() {
+	prometheus.MustRegister(httpRequests, httpErrors)
+}
+
+// ErrorClass classifies an error response by its status code.
+func ErrorClass(status int) string {
+	if status >= http.StatusInternalServerError {
+		return ClassInfrastructure
+	}
+	return ClassBusiness
+}
+
+// ObserveRequest counts a request towards the error rates.
+func ObserveRequest() {
+	httpRequests.Inc()
+	recentErrors.add(time.Now(), func(b *errorBucket) { b.requests++ })
+}
+
+// ObserveError counts an error response with the given status and code.
+func ObserveError(status int, code string) {
+	httpErrors.WithLabelValues(code, ErrorClass(status)).Inc()
+	recentErrors.add(time.Now(), func(b *errorBucket) {
+		if b.errors == nil {
+			b.errors = make(map[errorKey]int)
+		}
+		b.errors[errorKey{code: code, status: status}]++
+	})
+}
+
+// ErrorSummary counts the requests and error responses seen within a window.
+type ErrorSummary struct {
+	Window   time.Duration
+	Requests int
+	// Codes lists the error codes seen, most frequent first.
+	Codes []ErrorCount
+}
+
+type ErrorCount struct {
+	Code   string
+	Status int
+	Class  string
+	Count  int
+}
+
+// RecentErrors summarizes the last window, rounded up to whole minutes and
+// capped at MaxErrorWindow. Counts are kept per process.
+func RecentErrors(window time.Duration) ErrorSummary {
+	if window > MaxErrorWindow {
+		window = MaxErrorWindow
+	}
+	return recentErrors.summary(time.Now(), window)
+}
+
+var recentErrors errorHistory
+
+type errorKey struct {
+	code   string
+	status int
+}
+
+// errorBucket holds the counts of one minute.
+type errorBucket struct {
+	minute   time.Time
+	requests int
+	errors   map[errorKey]int
+}
+
+// errorHistory is a ring of per-minute buckets covering MaxErrorWindow.
+type errorHistory struct {
+	mu      sync.Mutex
+	buckets [int(MaxErrorWindow / time.Minute)]errorBucket
+}
+
+func (h *errorHistory) add(now time.Time, update func(*errorBucket)) {
+	minute := now.Truncate(time.Minute)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	bucket := &h.buckets[int(minute.Unix()/60)%len(h.buckets)]
+	if !bucket.minute.Equal(minute) {
+		*bucket = errorBucket{minute: minute}
+	}
+	update(bucket)
+}
+
+func (h *errorHistory) summary(now time.Time, window time.Duration) ErrorSummary {
+	minutes := int((window + time.Minute - 1) / time.Minute)
+	oldest := now.Truncate(time.Minute).Add(-time.Duration(minutes-1) * time.Minute)
+
+	summary := ErrorSummary{Window: time.Duration(minutes) * time.Minute}
+	counts := make(map[errorKey]int)
+	h.mu.Lock()
+	for _, bucket := range h.buckets {
+		if bucket.minute.Before(oldest) {
+			continue
+		}
+		summary.Requests += bucket.requests
+		for key, count := range bucket.errors {
+			counts[key] += count
+		}
+	}
+	h.mu.Unlock()
+
+	for key, count := range counts {
+		summary.Codes = append(summary.Codes, ErrorCount{
+			Code:   key.code,
+			Status: key.status,
+			Class:  ErrorClass(key.status),
+			Count:  count,
+		})
+	}
+	sort.Slice(summary.Codes, func(i, j int) bool {
+		if summary.Codes[i].Count != summary.Codes[j].Count {
+			return summary.Codes[i].Count > summary.Codes[j].Count
+		}
+		if summary.Codes[i].Code != summary.Codes[j].Code {
+			return summary.Codes[i].Code < summary.Codes[j].Code
+		}
+		return summary.Codes[i].Status < summary.Codes[j].Status
+	})
+	return summary
+}
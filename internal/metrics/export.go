@@ -0,0 +1,31 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	exportedEvents = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "export",
+		Name:      "events_total",
+		Help:      "Events uploaded to the export bucket and pruned from the database.",
+	})
+	exportFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "export",
+		Name:      "failures_total",
+		Help:      "Failed uploads to the export bucket.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(exportedEvents, exportFailures)
+}
+
+// ObserveExport counts an exported batch of events or a failed upload.
+func ObserveExport(events int, err error) {
+	if err != nil {
+		exportFailures.Inc()
+		return
+	}
+	exportedEvents.Add(float64(events))
+}
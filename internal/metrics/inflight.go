@@ -0,0 +1,28 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var httpInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: "http",
+	Name:      "in_flight_requests",
+	Help:      "Requests currently being handled; drains to zero on shutdown.",
+})
+
+var httpRejected = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "http",
+	Name:      "rejected_requests_total",
+	Help:      "Requests refused because too many were already in flight.",
+})
+
+func init() {
+	prometheus.MustRegister(httpInFlight, httpRejected)
+}
+
+// RequestStarted and RequestFinished track a request in the in-flight gauge.
+func RequestStarted()  { httpInFlight.Inc() }
+func RequestFinished() { httpInFlight.Dec() }
+
+// ObserveRejected counts a request refused by the in-flight limit.
+func ObserveRejected() { httpRejected.Inc() }
@@ -0,0 +1,152 @@
+// Package metrics owns the Prometheus collectors exported on /metrics.
+package metrics
+
+import (
+	"net/http"
+
+	"Avito2025/internal/domain"
+	"Avito2025/internal/storage"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "reviewer"
+
+// Handler serves the default registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterPoolStats exports the repository's connection pool statistics.
+func RegisterPoolStats(provider storage.StatsProvider) {
+	gauge := func(name, help string, value func(storage.PoolStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "db_pool",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value(provider.PoolStats()) })
+	}
+	counter := func(name, help string, value func(storage.PoolStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "db_pool",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value(provider.PoolStats()) })
+	}
+
+	prometheus.MustRegister(
+		gauge("acquired_conns", "Connections currently acquired from the pool.",
+			func(s storage.PoolStats) float64 { return float64(s.AcquiredConns) }),
+		gauge("idle_conns", "Idle connections in the pool.",
+			func(s storage.PoolStats) float64 { return float64(s.IdleConns) }),
+		gauge("total_conns", "Total connections in the pool.",
+			func(s storage.PoolStats) float64 { return float64(s.TotalConns) }),
+		gauge("max_conns", "Maximum size of the pool.",
+			func(s storage.PoolStats) float64 { return float64(s.MaxConns) }),
+		counter("acquires_total", "Successful connection acquires.",
+			func(s storage.PoolStats) float64 { return float64(s.AcquireCount) }),
+		counter("acquire_wait_seconds_total", "Total time spent acquiring connections.",
+			func(s storage.PoolStats) float64 { return s.AcquireDuration.Seconds() }),
+		counter("empty_acquires_total", "Acquires that had to wait for a connection.",
+			func(s storage.PoolStats) float64 { return float64(s.EmptyAcquireCount) }),
+		counter("canceled_acquires_total", "Acquires canceled by their context.",
+			func(s storage.PoolStats) float64 { return float64(s.CanceledAcquireCount) }),
+	)
+}
+
+var timeToFirstReview = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "time_to_first_review_seconds",
+	Help:      "Time from pull request creation to its first review activity.",
+	Buckets:   []float64{300, 900, 1800, 3600, 2 * 3600, 4 * 3600, 8 * 3600, 24 * 3600, 2 * 24 * 3600, 7 * 24 * 3600},
+}, []string{"team"})
+
+var underassigned = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "underassigned_pull_requests",
+	Help:      "Open pull requests with fewer reviewers than their team requires.",
+}, []string{"team"})
+
+var reassignments = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "reassignments_total",
+	Help:      "Reviewers replaced on open pull requests.",
+}, []string{"team"})
+
+var reassignmentsPerPR = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "pull_request_reassignments",
+	Help:      "Reassignments a pull request went through before it was merged.",
+	Buckets:   []float64{0, 1, 2, 3, 5, 8, 13},
+}, []string{"team"})
+
+var orphans = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "orphaned_rows",
+	Help:      "Reviewer state of reviewers no longer assigned, as found by the last orphan check.",
+}, []string{"check"})
+
+var partialAssignments = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "partial_assignments_total",
+	Help:      "Pull requests opened with fewer reviewers than they need.",
+}, []string{"team"})
+
+func init() {
+	prometheus.MustRegister(timeToFirstReview, underassigned, reassignments, reassignmentsPerPR, partialAssignments, orphans, storageDuration)
+}
+
+// SetUnderassigned replaces the per-team counts of under-assigned pull
+// requests; teams missing from prs are reset to zero.
+func SetUnderassigned(prs []domain.UnderassignedPullRequest) {
+	counts := make(map[string]int)
+	for _, pr := range prs {
+		counts[pr.TeamName]++
+	}
+	underassigned.Reset()
+	for team, count := range counts {
+		underassigned.WithLabelValues(team).Set(float64(count))
+	}
+}
+
+// SetOrphans replaces the per-check counts of orphaned rows with the issues
+// of an orphan check; checks without issues are set to zero.
+func SetOrphans(issues []domain.IntegrityIssue) {
+	counts := make(map[string]int)
+	for _, issue := range issues {
+		counts[issue.Check]++
+	}
+	for _, check := range domain.OrphanChecks {
+		orphans.WithLabelValues(check).Set(float64(counts[check]))
+	}
+}
+
+// ObserveReviews feeds review events into the time-to-first-review histogram,
+// the reassignment and the partial assignment metrics until events is closed.
+func ObserveReviews(events <-chan domain.Event) {
+	for event := range events {
+		switch event.Type {
+		case domain.EventReviewSubmitted:
+			seconds, ok := event.Payload["time_to_review_seconds"].(float64)
+			if !ok {
+				continue
+			}
+			timeToFirstReview.WithLabelValues(event.TeamName).Observe(seconds)
+		case domain.EventReviewerReassigned:
+			reassignments.WithLabelValues(event.TeamName).Inc()
+		case domain.EventPRCreated, domain.EventPRReady:
+			if pending, _ := event.Payload["assignment_pending"].(bool); pending {
+				partialAssignments.WithLabelValues(event.TeamName).Inc()
+			}
+		case domain.EventPRMerged:
+			count, ok := event.Payload["reassignments"].(int)
+			if !ok {
+				continue
+			}
+			reassignmentsPerPR.WithLabelValues(event.TeamName).Observe(float64(count))
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var notifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "notify",
+	Name:      "notifications_total",
+	Help:      "Notifications to pull request authors by channel and result (sent, failed, unconfigured).",
+}, []string{"channel", "result"})
+
+func init() {
+	prometheus.MustRegister(notifications)
+}
+
+// ObserveNotification counts a notification attempt.
+func ObserveNotification(channel, result string) {
+	notifications.WithLabelValues(channel, result).Inc()
+}
@@ -0,0 +1,585 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Avito2025/internal/domain"
+	"Avito2025/internal/storage"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var storageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: "storage",
+	Name:      "operation_duration_seconds",
+	Help:      "Duration of repository calls by operation and outcome.",
+	Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 8),
+}, []string{"operation", "outcome"})
+
+// Outcomes of a repository call. Domain errors such as a missing team are
+// answers rather than failures and are counted as rejected; unavailability,
+// e.g. a storage timeout, is still an error.
+const (
+	outcomeOK       = "ok"
+	outcomeRejected = "rejected"
+	outcomeError    = "error"
+)
+
+// InstrumentRepository is a storage.Decorator that times every repository
+// call.
+func InstrumentRepository(next storage.Repository) storage.Repository {
+	return &instrumentedRepository{Repository: next}
+}
+
+type instrumentedRepository struct {
+	storage.Repository
+}
+
+func (r *instrumentedRepository) Unwrap() storage.Repository {
+	return r.Repository
+}
+
+func (r *instrumentedRepository) observe(operation string, start time.Time, err *error) {
+	outcome := outcomeOK
+	var domainErr *domain.Error
+	switch {
+	case errors.As(*err, &domainErr) && domainErr.Kind != domain.KindUnavailable:
+		outcome = outcomeRejected
+	case *err != nil:
+		outcome = outcomeError
+	}
+	storageDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+func (r *instrumentedRepository) CreateTeam(ctx context.Context, team domain.Team) (result domain.Team, err error) {
+	defer r.observe("CreateTeam", time.Now(), &err)
+	return r.Repository.CreateTeam(ctx, team)
+}
+
+func (r *instrumentedRepository) GetTeam(ctx context.Context, name string) (result domain.Team, err error) {
+	defer r.observe("GetTeam", time.Now(), &err)
+	return r.Repository.GetTeam(ctx, name)
+}
+
+func (r *instrumentedRepository) ListUsers(ctx context.Context, filter domain.UserFilter, offset, limit int) (result []domain.UserSummary, err error) {
+	defer r.observe("ListUsers", time.Now(), &err)
+	return r.Repository.ListUsers(ctx, filter, offset, limit)
+}
+
+func (r *instrumentedRepository) ListTeams(ctx context.Context, prefix string, offset, limit int) (result []domain.TeamSummary, err error) {
+	defer r.observe("ListTeams", time.Now(), &err)
+	return r.Repository.ListTeams(ctx, prefix, offset, limit)
+}
+
+func (r *instrumentedRepository) GetUser(ctx context.Context, userID string) (result domain.User, err error) {
+	defer r.observe("GetUser", time.Now(), &err)
+	return r.Repository.GetUser(ctx, userID)
+}
+
+func (r *instrumentedRepository) EnsureUser(ctx context.Context, user domain.User) (result domain.User, created bool, err error) {
+	defer r.observe("EnsureUser", time.Now(), &err)
+	return r.Repository.EnsureUser(ctx, user)
+}
+
+func (r *instrumentedRepository) SetUserActive(ctx context.Context, userID string, isActive bool) (result domain.User, err error) {
+	defer r.observe("SetUserActive", time.Now(), &err)
+	return r.Repository.SetUserActive(ctx, userID, isActive)
+}
+
+func (r *instrumentedRepository) SetUsersActive(ctx context.Context, userIDs []string, isActive bool) (result []domain.UserResult, err error) {
+	defer r.observe("SetUsersActive", time.Now(), &err)
+	return r.Repository.SetUsersActive(ctx, userIDs, isActive)
+}
+
+func (r *instrumentedRepository) SnoozeUser(ctx context.Context, userID string, until *time.Time) (result domain.User, err error) {
+	defer r.observe("SnoozeUser", time.Now(), &err)
+	return r.Repository.SnoozeUser(ctx, userID, until)
+}
+
+func (r *instrumentedRepository) UpdateUser(ctx context.Context, userID string, update domain.UserUpdate) (result domain.User, err error) {
+	defer r.observe("UpdateUser", time.Now(), &err)
+	return r.Repository.UpdateUser(ctx, userID, update)
+}
+
+func (r *instrumentedRepository) ListUsersByTeam(ctx context.Context, teamName string) (result []domain.User, err error) {
+	defer r.observe("ListUsersByTeam", time.Now(), &err)
+	return r.Repository.ListUsersByTeam(ctx, teamName)
+}
+
+func (r *instrumentedRepository) MergeTeams(ctx context.Context, source, target string) (result domain.Team, err error) {
+	defer r.observe("MergeTeams", time.Now(), &err)
+	return r.Repository.MergeTeams(ctx, source, target)
+}
+
+func (r *instrumentedRepository) SplitTeam(ctx context.Context, split domain.TeamSplit) (result domain.Team, err error) {
+	defer r.observe("SplitTeam", time.Now(), &err)
+	return r.Repository.SplitTeam(ctx, split)
+}
+
+func (r *instrumentedRepository) AppendMembershipChanges(ctx context.Context, changes []domain.MembershipChange) (err error) {
+	defer r.observe("AppendMembershipChanges", time.Now(), &err)
+	return r.Repository.AppendMembershipChanges(ctx, changes)
+}
+
+func (r *instrumentedRepository) ListMembershipHistory(ctx context.Context, teamName string) (result []domain.MembershipChange, err error) {
+	defer r.observe("ListMembershipHistory", time.Now(), &err)
+	return r.Repository.ListMembershipHistory(ctx, teamName)
+}
+
+func (r *instrumentedRepository) GetTeamSettings(ctx context.Context, teamName string) (result domain.TeamSettings, err error) {
+	defer r.observe("GetTeamSettings", time.Now(), &err)
+	return r.Repository.GetTeamSettings(ctx, teamName)
+}
+
+func (r *instrumentedRepository) SaveTeamSettings(ctx context.Context, settings domain.TeamSettings) (result domain.TeamSettings, err error) {
+	defer r.observe("SaveTeamSettings", time.Now(), &err)
+	return r.Repository.SaveTeamSettings(ctx, settings)
+}
+
+func (r *instrumentedRepository) SetRotation(ctx context.Context, rotation domain.Rotation) (result domain.Rotation, err error) {
+	defer r.observe("SetRotation", time.Now(), &err)
+	return r.Repository.SetRotation(ctx, rotation)
+}
+
+func (r *instrumentedRepository) GetRotation(ctx context.Context, teamName string) (result domain.Rotation, err error) {
+	defer r.observe("GetRotation", time.Now(), &err)
+	return r.Repository.GetRotation(ctx, teamName)
+}
+
+func (r *instrumentedRepository) AdvanceRotationPosition(ctx context.Context, teamName string, from, to int) (err error) {
+	defer r.observe("AdvanceRotationPosition", time.Now(), &err)
+	return r.Repository.AdvanceRotationPosition(ctx, teamName, from, to)
+}
+
+func (r *instrumentedRepository) SetOwnership(ctx context.Context, ownership domain.Ownership) (result domain.Ownership, err error) {
+	defer r.observe("SetOwnership", time.Now(), &err)
+	return r.Repository.SetOwnership(ctx, ownership)
+}
+
+func (r *instrumentedRepository) GetOwnership(ctx context.Context, teamName string) (result domain.Ownership, err error) {
+	defer r.observe("GetOwnership", time.Now(), &err)
+	return r.Repository.GetOwnership(ctx, teamName)
+}
+
+func (r *instrumentedRepository) BuildDailyReviewStats(ctx context.Context, day time.Time) (err error) {
+	defer r.observe("BuildDailyReviewStats", time.Now(), &err)
+	return r.Repository.BuildDailyReviewStats(ctx, day)
+}
+
+func (r *instrumentedRepository) LastDailyReviewStatsDay(ctx context.Context) (result time.Time, err error) {
+	defer r.observe("LastDailyReviewStatsDay", time.Now(), &err)
+	return r.Repository.LastDailyReviewStatsDay(ctx)
+}
+
+func (r *instrumentedRepository) ListDailyReviewStats(ctx context.Context, teamName string, from, to time.Time) (result []domain.DailyReviewStats, err error) {
+	defer r.observe("ListDailyReviewStats", time.Now(), &err)
+	return r.Repository.ListDailyReviewStats(ctx, teamName, from, to)
+}
+
+func (r *instrumentedRepository) SetTeamLead(ctx context.Context, teamName, userID string, isLead bool) (err error) {
+	defer r.observe("SetTeamLead", time.Now(), &err)
+	return r.Repository.SetTeamLead(ctx, teamName, userID, isLead)
+}
+
+func (r *instrumentedRepository) ListTeamLeads(ctx context.Context, teamName string) (result []string, err error) {
+	defer r.observe("ListTeamLeads", time.Now(), &err)
+	return r.Repository.ListTeamLeads(ctx, teamName)
+}
+
+func (r *instrumentedRepository) SetNotificationPreference(ctx context.Context, pref domain.NotificationPreference) (result domain.NotificationPreference, err error) {
+	defer r.observe("SetNotificationPreference", time.Now(), &err)
+	return r.Repository.SetNotificationPreference(ctx, pref)
+}
+
+func (r *instrumentedRepository) GetNotificationPreference(ctx context.Context, userID string) (result domain.NotificationPreference, err error) {
+	defer r.observe("GetNotificationPreference", time.Now(), &err)
+	return r.Repository.GetNotificationPreference(ctx, userID)
+}
+
+func (r *instrumentedRepository) ListDueDigests(ctx context.Context, now time.Time) (result []domain.NotificationPreference, err error) {
+	defer r.observe("ListDueDigests", time.Now(), &err)
+	return r.Repository.ListDueDigests(ctx, now)
+}
+
+func (r *instrumentedRepository) MarkDigestSent(ctx context.Context, userID string, seq int64, at time.Time) (err error) {
+	defer r.observe("MarkDigestSent", time.Now(), &err)
+	return r.Repository.MarkDigestSent(ctx, userID, seq, at)
+}
+
+func (r *instrumentedRepository) AddIdentity(ctx context.Context, identity domain.Identity) (result domain.Identity, err error) {
+	defer r.observe("AddIdentity", time.Now(), &err)
+	return r.Repository.AddIdentity(ctx, identity)
+}
+
+func (r *instrumentedRepository) ListIdentities(ctx context.Context, userID string) (result []domain.Identity, err error) {
+	defer r.observe("ListIdentities", time.Now(), &err)
+	return r.Repository.ListIdentities(ctx, userID)
+}
+
+func (r *instrumentedRepository) ResolveIdentity(ctx context.Context, provider domain.IdentityProvider, externalID string) (result domain.User, err error) {
+	defer r.observe("ResolveIdentity", time.Now(), &err)
+	return r.Repository.ResolveIdentity(ctx, provider, externalID)
+}
+
+func (r *instrumentedRepository) CreatePullRequest(ctx context.Context, pr domain.PullRequest) (result domain.PullRequest, err error) {
+	defer r.observe("CreatePullRequest", time.Now(), &err)
+	return r.Repository.CreatePullRequest(ctx, pr)
+}
+
+func (r *instrumentedRepository) AddReviewers(ctx context.Context, prID string, reviewerIDs []string, limit int) (result domain.PullRequest, added []string, err error) {
+	defer r.observe("AddReviewers", time.Now(), &err)
+	return r.Repository.AddReviewers(ctx, prID, reviewerIDs, limit)
+}
+
+func (r *instrumentedRepository) ReassignReviewer(ctx context.Context, prID, oldReviewerID, newReviewerID string) (result domain.PullRequest, err error) {
+	defer r.observe("ReassignReviewer", time.Now(), &err)
+	return r.Repository.ReassignReviewer(ctx, prID, oldReviewerID, newReviewerID)
+}
+
+func (r *instrumentedRepository) UpdatePullRequest(ctx context.Context, pr domain.PullRequest) (result domain.PullRequest, err error) {
+	defer r.observe("UpdatePullRequest", time.Now(), &err)
+	return r.Repository.UpdatePullRequest(ctx, pr)
+}
+
+func (r *instrumentedRepository) CompareAndUpdatePullRequest(ctx context.Context, seen, pr domain.PullRequest) (result domain.PullRequest, updated bool, err error) {
+	defer r.observe("CompareAndUpdatePullRequest", time.Now(), &err)
+	return r.Repository.CompareAndUpdatePullRequest(ctx, seen, pr)
+}
+
+func (r *instrumentedRepository) MergePullRequest(ctx context.Context, id string, mergedAt time.Time, force bool) (pr domain.PullRequest, merged bool, err error) {
+	defer r.observe("MergePullRequest", time.Now(), &err)
+	return r.Repository.MergePullRequest(ctx, id, mergedAt, force)
+}
+
+func (r *instrumentedRepository) GetPullRequest(ctx context.Context, id string) (result domain.PullRequest, err error) {
+	defer r.observe("GetPullRequest", time.Now(), &err)
+	return r.Repository.GetPullRequest(ctx, id)
+}
+
+func (r *instrumentedRepository) ArchivePullRequests(ctx context.Context, mergedBefore time.Time, limit int) (result int, err error) {
+	defer r.observe("ArchivePullRequests", time.Now(), &err)
+	return r.Repository.ArchivePullRequests(ctx, mergedBefore, limit)
+}
+
+func (r *instrumentedRepository) ListReminderCandidates(ctx context.Context, createdBefore time.Time) (result []domain.ReminderCandidate, err error) {
+	defer r.observe("ListReminderCandidates", time.Now(), &err)
+	return r.Repository.ListReminderCandidates(ctx, createdBefore)
+}
+
+func (r *instrumentedRepository) SetReminderStage(ctx context.Context, prID string, stage domain.ReminderStage) (err error) {
+	defer r.observe("SetReminderStage", time.Now(), &err)
+	return r.Repository.SetReminderStage(ctx, prID, stage)
+}
+
+func (r *instrumentedRepository) RecordReview(ctx context.Context, prID string, kind domain.ReviewKind, at time.Time) (result bool, err error) {
+	defer r.observe("RecordReview", time.Now(), &err)
+	return r.Repository.RecordReview(ctx, prID, kind, at)
+}
+
+func (r *instrumentedRepository) AppendReviewerHistory(ctx context.Context, changes []domain.ReviewerChange) (err error) {
+	defer r.observe("AppendReviewerHistory", time.Now(), &err)
+	return r.Repository.AppendReviewerHistory(ctx, changes)
+}
+
+func (r *instrumentedRepository) LinkPullRequests(ctx context.Context, prID, blockedBy string) (result bool, err error) {
+	defer r.observe("LinkPullRequests", time.Now(), &err)
+	return r.Repository.LinkPullRequests(ctx, prID, blockedBy)
+}
+
+func (r *instrumentedRepository) UnlinkPullRequests(ctx context.Context, prID, blockedBy string) (result bool, err error) {
+	defer r.observe("UnlinkPullRequests", time.Now(), &err)
+	return r.Repository.UnlinkPullRequests(ctx, prID, blockedBy)
+}
+
+func (r *instrumentedRepository) ListReviewerHistory(ctx context.Context, filter domain.AssignmentHistoryFilter, offset, limit int) (result []domain.ReviewerChange, err error) {
+	defer r.observe("ListReviewerHistory", time.Now(), &err)
+	return r.Repository.ListReviewerHistory(ctx, filter, offset, limit)
+}
+
+func (r *instrumentedRepository) AppendAssignmentDecision(ctx context.Context, decision domain.AssignmentDecision) (err error) {
+	defer r.observe("AppendAssignmentDecision", time.Now(), &err)
+	return r.Repository.AppendAssignmentDecision(ctx, decision)
+}
+
+func (r *instrumentedRepository) ListAssignmentDecisions(ctx context.Context, prID string) (result []domain.AssignmentDecision, err error) {
+	defer r.observe("ListAssignmentDecisions", time.Now(), &err)
+	return r.Repository.ListAssignmentDecisions(ctx, prID)
+}
+
+func (r *instrumentedRepository) ListPullRequestsByReviewer(ctx context.Context, userID string, filter domain.ReviewFilter) (result []domain.PullRequest, err error) {
+	defer r.observe("ListPullRequestsByReviewer", time.Now(), &err)
+	return r.Repository.ListPullRequestsByReviewer(ctx, userID, filter)
+}
+
+func (r *instrumentedRepository) ListParticipatedPullRequests(ctx context.Context, userIDs []string, limit int) (result []domain.PullRequest, err error) {
+	defer r.observe("ListParticipatedPullRequests", time.Now(), &err)
+	return r.Repository.ListParticipatedPullRequests(ctx, userIDs, limit)
+}
+
+func (r *instrumentedRepository) CountAssignments(ctx context.Context, teamName string, since time.Time) (result []domain.AssignmentCount, err error) {
+	defer r.observe("CountAssignments", time.Now(), &err)
+	return r.Repository.CountAssignments(ctx, teamName, since)
+}
+
+func (r *instrumentedRepository) CountOpenPullRequests(ctx context.Context, authorID string) (count int, err error) {
+	defer r.observe("CountOpenPullRequests", time.Now(), &err)
+	return r.Repository.CountOpenPullRequests(ctx, authorID)
+}
+
+func (r *instrumentedRepository) CountOpenReviews(ctx context.Context, userIDs []string) (result map[string]int, err error) {
+	defer r.observe("CountOpenReviews", time.Now(), &err)
+	return r.Repository.CountOpenReviews(ctx, userIDs)
+}
+
+func (r *instrumentedRepository) CountCreatedPullRequests(ctx context.Context, teamName string, since time.Time) (result map[string]int, err error) {
+	defer r.observe("CountCreatedPullRequests", time.Now(), &err)
+	return r.Repository.CountCreatedPullRequests(ctx, teamName, since)
+}
+
+func (r *instrumentedRepository) ListPreviousReviewers(ctx context.Context, authorID string) (result []string, err error) {
+	defer r.observe("ListPreviousReviewers", time.Now(), &err)
+	return r.Repository.ListPreviousReviewers(ctx, authorID)
+}
+
+func (r *instrumentedRepository) ListRecentReviewers(ctx context.Context, authorID string, since time.Time) (result []string, err error) {
+	defer r.observe("ListRecentReviewers", time.Now(), &err)
+	return r.Repository.ListRecentReviewers(ctx, authorID, since)
+}
+
+func (r *instrumentedRepository) ListUnderassignedPullRequests(ctx context.Context, teamName string) (result []domain.UnderassignedPullRequest, err error) {
+	defer r.observe("ListUnderassignedPullRequests", time.Now(), &err)
+	return r.Repository.ListUnderassignedPullRequests(ctx, teamName)
+}
+
+func (r *instrumentedRepository) ListHotPullRequests(ctx context.Context, teamName string, minReassignments int) (result []domain.HotPullRequest, err error) {
+	defer r.observe("ListHotPullRequests", time.Now(), &err)
+	return r.Repository.ListHotPullRequests(ctx, teamName, minReassignments)
+}
+
+func (r *instrumentedRepository) AssignmentBuckets(ctx context.Context, teamName string, since time.Time) (result []domain.AssignmentBucket, err error) {
+	defer r.observe("AssignmentBuckets", time.Now(), &err)
+	return r.Repository.AssignmentBuckets(ctx, teamName, since)
+}
+
+func (r *instrumentedRepository) TeamStats(ctx context.Context, teamName string, since time.Time) (result domain.TeamStats, err error) {
+	defer r.observe("TeamStats", time.Now(), &err)
+	return r.Repository.TeamStats(ctx, teamName, since)
+}
+
+func (r *instrumentedRepository) ListReviewLatencies(ctx context.Context, teamName string, since time.Time) (result []domain.ReviewLatency, err error) {
+	defer r.observe("ListReviewLatencies", time.Now(), &err)
+	return r.Repository.ListReviewLatencies(ctx, teamName, since)
+}
+
+func (r *instrumentedRepository) CreateWebhook(ctx context.Context, webhook domain.Webhook) (result domain.Webhook, err error) {
+	defer r.observe("CreateWebhook", time.Now(), &err)
+	return r.Repository.CreateWebhook(ctx, webhook)
+}
+
+func (r *instrumentedRepository) GetWebhook(ctx context.Context, id int64) (result domain.Webhook, err error) {
+	defer r.observe("GetWebhook", time.Now(), &err)
+	return r.Repository.GetWebhook(ctx, id)
+}
+
+func (r *instrumentedRepository) ListWebhooks(ctx context.Context) (result []domain.Webhook, err error) {
+	defer r.observe("ListWebhooks", time.Now(), &err)
+	return r.Repository.ListWebhooks(ctx)
+}
+
+func (r *instrumentedRepository) UpdateWebhook(ctx context.Context, webhook domain.Webhook) (result domain.Webhook, err error) {
+	defer r.observe("UpdateWebhook", time.Now(), &err)
+	return r.Repository.UpdateWebhook(ctx, webhook)
+}
+
+func (r *instrumentedRepository) DeleteWebhook(ctx context.Context, id int64) (err error) {
+	defer r.observe("DeleteWebhook", time.Now(), &err)
+	return r.Repository.DeleteWebhook(ctx, id)
+}
+
+func (r *instrumentedRepository) AppendWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) (err error) {
+	defer r.observe("AppendWebhookDelivery", time.Now(), &err)
+	return r.Repository.AppendWebhookDelivery(ctx, delivery)
+}
+
+func (r *instrumentedRepository) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit int) (result []domain.WebhookDelivery, err error) {
+	defer r.observe("ListWebhookDeliveries", time.Now(), &err)
+	return r.Repository.ListWebhookDeliveries(ctx, webhookID, limit)
+}
+
+func (r *instrumentedRepository) ClaimWebhookDispatch(ctx context.Context, owner string, now, until time.Time) (seq int64, ok bool, err error) {
+	defer r.observe("ClaimWebhookDispatch", time.Now(), &err)
+	return r.Repository.ClaimWebhookDispatch(ctx, owner, now, until)
+}
+
+func (r *instrumentedRepository) AdvanceWebhookDispatch(ctx context.Context, owner string, seq int64) (ok bool, err error) {
+	defer r.observe("AdvanceWebhookDispatch", time.Now(), &err)
+	return r.Repository.AdvanceWebhookDispatch(ctx, owner, seq)
+}
+
+func (r *instrumentedRepository) DeferAssignment(ctx context.Context, deferred domain.DeferredAssignment) (err error) {
+	defer r.observe("DeferAssignment", time.Now(), &err)
+	return r.Repository.DeferAssignment(ctx, deferred)
+}
+
+func (r *instrumentedRepository) ListDeferredAssignments(ctx context.Context) (result []domain.DeferredAssignment, err error) {
+	defer r.observe("ListDeferredAssignments", time.Now(), &err)
+	return r.Repository.ListDeferredAssignments(ctx)
+}
+
+func (r *instrumentedRepository) DeleteDeferredAssignment(ctx context.Context, prID string) (err error) {
+	defer r.observe("DeleteDeferredAssignment", time.Now(), &err)
+	return r.Repository.DeleteDeferredAssignment(ctx, prID)
+}
+
+func (r *instrumentedRepository) QueuePendingAssignment(ctx context.Context, prID string) (err error) {
+	defer r.observe("QueuePendingAssignment", time.Now(), &err)
+	return r.Repository.QueuePendingAssignment(ctx, prID)
+}
+
+func (r *instrumentedRepository) ListPendingAssignments(ctx context.Context) (result []domain.PendingAssignment, err error) {
+	defer r.observe("ListPendingAssignments", time.Now(), &err)
+	return r.Repository.ListPendingAssignments(ctx)
+}
+
+func (r *instrumentedRepository) DeletePendingAssignment(ctx context.Context, prID string) (err error) {
+	defer r.observe("DeletePendingAssignment", time.Now(), &err)
+	return r.Repository.DeletePendingAssignment(ctx, prID)
+}
+
+func (r *instrumentedRepository) SyncReviewAcceptances(ctx context.Context, prID string, withdrawn []string, requested []domain.ReviewAcceptance) (err error) {
+	defer r.observe("SyncReviewAcceptances", time.Now(), &err)
+	return r.Repository.SyncReviewAcceptances(ctx, prID, withdrawn, requested)
+}
+
+func (r *instrumentedRepository) CompleteReview(ctx context.Context, prID, reviewerID string, at time.Time) (result bool, err error) {
+	defer r.observe("CompleteReview", time.Now(), &err)
+	return r.Repository.CompleteReview(ctx, prID, reviewerID, at)
+}
+
+func (r *instrumentedRepository) ReopenReviews(ctx context.Context, prID string, reviewerIDs []string) (err error) {
+	defer r.observe("ReopenReviews", time.Now(), &err)
+	return r.Repository.ReopenReviews(ctx, prID, reviewerIDs)
+}
+
+func (r *instrumentedRepository) AcceptReview(ctx context.Context, prID, reviewerID string, at time.Time) (result bool, err error) {
+	defer r.observe("AcceptReview", time.Now(), &err)
+	return r.Repository.AcceptReview(ctx, prID, reviewerID, at)
+}
+
+func (r *instrumentedRepository) ExpireReviewAcceptances(ctx context.Context, now time.Time) (result []domain.ReviewAcceptance, err error) {
+	defer r.observe("ExpireReviewAcceptances", time.Now(), &err)
+	return r.Repository.ExpireReviewAcceptances(ctx, now)
+}
+
+func (r *instrumentedRepository) RestoreReviewAcceptance(ctx context.Context, acceptance domain.ReviewAcceptance) (err error) {
+	defer r.observe("RestoreReviewAcceptance", time.Now(), &err)
+	return r.Repository.RestoreReviewAcceptance(ctx, acceptance)
+}
+
+func (r *instrumentedRepository) ListReviewAcceptances(ctx context.Context, teamName string, since time.Time) (result []domain.ReviewAcceptance, err error) {
+	defer r.observe("ListReviewAcceptances", time.Now(), &err)
+	return r.Repository.ListReviewAcceptances(ctx, teamName, since)
+}
+
+func (r *instrumentedRepository) CreateDeadlineExtension(ctx context.Context, extension domain.DeadlineExtension) (result domain.DeadlineExtension, err error) {
+	defer r.observe("CreateDeadlineExtension", time.Now(), &err)
+	return r.Repository.CreateDeadlineExtension(ctx, extension)
+}
+
+func (r *instrumentedRepository) GetDeadlineExtension(ctx context.Context, id int64) (result domain.DeadlineExtension, err error) {
+	defer r.observe("GetDeadlineExtension", time.Now(), &err)
+	return r.Repository.GetDeadlineExtension(ctx, id)
+}
+
+func (r *instrumentedRepository) DecideDeadlineExtension(ctx context.Context, id int64, status domain.ExtensionStatus, decidedBy string, at time.Time) (result domain.DeadlineExtension, err error) {
+	defer r.observe("DecideDeadlineExtension", time.Now(), &err)
+	return r.Repository.DecideDeadlineExtension(ctx, id, status, decidedBy, at)
+}
+
+func (r *instrumentedRepository) ListDeadlineExtensions(ctx context.Context, prID string) (result []domain.DeadlineExtension, err error) {
+	defer r.observe("ListDeadlineExtensions", time.Now(), &err)
+	return r.Repository.ListDeadlineExtensions(ctx, prID)
+}
+
+func (r *instrumentedRepository) CreateTeamToken(ctx context.Context, token domain.TeamToken) (result domain.TeamToken, err error) {
+	defer r.observe("CreateTeamToken", time.Now(), &err)
+	return r.Repository.CreateTeamToken(ctx, token)
+}
+
+func (r *instrumentedRepository) GetTeamTokenByHash(ctx context.Context, hash string) (result domain.TeamToken, err error) {
+	defer r.observe("GetTeamTokenByHash", time.Now(), &err)
+	return r.Repository.GetTeamTokenByHash(ctx, hash)
+}
+
+func (r *instrumentedRepository) ListTeamTokens(ctx context.Context, teamName string) (result []domain.TeamToken, err error) {
+	defer r.observe("ListTeamTokens", time.Now(), &err)
+	return r.Repository.ListTeamTokens(ctx, teamName)
+}
+
+func (r *instrumentedRepository) DeleteTeamToken(ctx context.Context, id int64) (err error) {
+	defer r.observe("DeleteTeamToken", time.Now(), &err)
+	return r.Repository.DeleteTeamToken(ctx, id)
+}
+
+func (r *instrumentedRepository) Reset(ctx context.Context) (err error) {
+	defer r.observe("Reset", time.Now(), &err)
+	return r.Repository.Reset(ctx)
+}
+
+func (r *instrumentedRepository) GetMaintenance(ctx context.Context) (result domain.Maintenance, err error) {
+	defer r.observe("GetMaintenance", time.Now(), &err)
+	return r.Repository.GetMaintenance(ctx)
+}
+
+func (r *instrumentedRepository) SetMaintenance(ctx context.Context, enabled bool) (result domain.Maintenance, err error) {
+	defer r.observe("SetMaintenance", time.Now(), &err)
+	return r.Repository.SetMaintenance(ctx, enabled)
+}
+
+func (r *instrumentedRepository) AppendEvent(ctx context.Context, event domain.Event) (result domain.Event, err error) {
+	defer r.observe("AppendEvent", time.Now(), &err)
+	return r.Repository.AppendEvent(ctx, event)
+}
+
+func (r *instrumentedRepository) ListEvents(ctx context.Context, since int64, limit int) (result []domain.Event, err error) {
+	defer r.observe("ListEvents", time.Now(), &err)
+	return r.Repository.ListEvents(ctx, since, limit)
+}
+
+func (r *instrumentedRepository) ListLatestEvents(ctx context.Context, limit int) (result []domain.Event, err error) {
+	defer r.observe("ListLatestEvents", time.Now(), &err)
+	return r.Repository.ListLatestEvents(ctx, limit)
+}
+
+func (r *instrumentedRepository) CheckIntegrity(ctx context.Context, now time.Time, fix bool) (result []domain.IntegrityIssue, err error) {
+	defer r.observe("CheckIntegrity", time.Now(), &err)
+	return r.Repository.CheckIntegrity(ctx, now, fix)
+}
+
+func (r *instrumentedRepository) ExportSnapshot(ctx context.Context) (result domain.Snapshot, err error) {
+	defer r.observe("ExportSnapshot", time.Now(), &err)
+	return r.Repository.ExportSnapshot(ctx)
+}
+
+func (r *instrumentedRepository) ImportSnapshot(ctx context.Context, snapshot domain.Snapshot) (err error) {
+	defer r.observe("ImportSnapshot", time.Now(), &err)
+	return r.Repository.ImportSnapshot(ctx, snapshot)
+}
+
+func (r *instrumentedRepository) CheckOrphans(ctx context.Context, fix bool) (result []domain.IntegrityIssue, err error) {
+	defer r.observe("CheckOrphans", time.Now(), &err)
+	return r.Repository.CheckOrphans(ctx, fix)
+}
+
+func (r *instrumentedRepository) DeleteEvents(ctx context.Context, throughSeq int64) (result int, err error) {
+	defer r.observe("DeleteEvents", time.Now(), &err)
+	return r.Repository.DeleteEvents(ctx, throughSeq)
+}
+
+func (r *instrumentedRepository) Health(ctx context.Context) (err error) {
+	defer r.observe("Health", time.Now(), &err)
+	return r.Repository.Health(ctx)
+}
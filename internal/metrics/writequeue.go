@@ -0,0 +1,29 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var writeQueueOutcomes = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "write_queue",
+	Name:      "entries_total",
+	Help:      "Writes queued while the storage was down, by outcome: queued, replayed, rejected or expired.",
+}, []string{"outcome"})
+
+func init() {
+	prometheus.MustRegister(writeQueueOutcomes)
+}
+
+// RegisterWriteQueue exports the number of writes waiting for replay.
+func RegisterWriteQueue(depth func() int) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "write_queue",
+		Name:      "depth",
+		Help:      "Writes waiting for the storage to come back.",
+	}, func() float64 { return float64(depth()) }))
+}
+
+// ObserveWriteQueue counts n writes that reached outcome.
+func ObserveWriteQueue(outcome string, n int) {
+	writeQueueOutcomes.WithLabelValues(outcome).Add(float64(n))
+}
@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"Avito2025/internal/domain"
+	"Avito2025/internal/metrics"
+)
+
+// digestPage is how many events a digest run reads at a time.
+const digestPage = 500
+
+// DigestStore is what SendDigests needs on top of Store: the users whose
+// digest is due, the event log and the digest cursor.
+type DigestStore interface {
+	Store
+	DueDigests(ctx context.Context, now time.Time) ([]domain.NotificationPreference, error)
+	ListChanges(ctx context.Context, since int64, limit int) ([]domain.Event, error)
+	MarkDigestSent(ctx context.Context, userID string, seq int64, now time.Time) error
+}
+
+// SendDigests sends every user whose digest is due at now one message
+// summing up the events since their last digest: the pull requests they were
+// assigned to review, the merges of those they review and the news about
+// their own. Assignments are reported as of now, so a review the user was
+// taken off meanwhile is left out. A user with nothing to report gets no
+// message; their cursor still moves. A failed delivery is retried on the
+// next run.
+func (d *Dispatcher) SendDigests(ctx context.Context, store DigestStore, now time.Time) (int, error) {
+	due, err := store.DueDigests(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	prs := make(map[string]*domain.PullRequest)
+	sent := 0
+	for _, pref := range due {
+		lines, seq, err := d.digestLines(ctx, store, pref, prs)
+		if err != nil {
+			return sent, err
+		}
+		if len(lines) > 0 {
+			sender, ok := d.senders[pref.Channel]
+			if !ok {
+				metrics.ObserveNotification(string(pref.Channel), "unconfigured")
+			} else if err := sender.Send(ctx, pref, composeDigest(lines)); err != nil {
+				log.Printf("notify: %s digest to %s: %v", pref.Channel, pref.UserID, err)
+				metrics.ObserveNotification(string(pref.Channel), "failed")
+				continue
+			} else {
+				metrics.ObserveNotification(string(pref.Channel), "sent")
+				sent++
+			}
+		}
+		if err := store.MarkDigestSent(ctx, pref.UserID, seq, now); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// digestLines reads the events after the user's cursor and returns the
+// lines of their digest together with the last event read. prs caches pull
+// requests across users; nil marks one that no longer exists.
+func (d *Dispatcher) digestLines(ctx context.Context, store DigestStore, pref domain.NotificationPreference, prs map[string]*domain.PullRequest) ([]string, int64, error) {
+	var lines []string
+	seen := make(map[string]bool)
+	seq := pref.DigestSeq
+	for {
+		events, err := store.ListChanges(ctx, seq, digestPage)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, event := range events {
+			seq = event.Seq
+			if !notifiable(event) {
+				continue
+			}
+			pr, ok := prs[event.EntityID]
+			if !ok {
+				got, err := store.GetPullRequest(ctx, event.EntityID)
+				switch {
+				case errors.Is(err, domain.ErrPullRequestNotFound):
+				case err != nil:
+					return nil, 0, err
+				default:
+					pr = &got
+				}
+				prs[event.EntityID] = pr
+			}
+			if pr == nil {
+				continue
+			}
+			line, ok := digestLine(event, *pr, pref.UserID)
+			if ok && !seen[line] {
+				seen[line] = true
+				lines = append(lines, line)
+			}
+		}
+		if len(events) < digestPage {
+			return lines, seq, nil
+		}
+	}
+}
+
+// digestLine describes what an event means to the user: news about their
+// own pull request, or one they review.
+func digestLine(event domain.Event, pr domain.PullRequest, userID string) (string, bool) {
+	if pr.AuthorID == userID {
+		msg, ok := compose(event, pr)
+		return msg.Subject, ok
+	}
+	if !slices.Contains(pr.AssignedReviewers, userID) {
+		return "", false
+	}
+	switch event.Type {
+	case domain.EventPRCreated, domain.EventPRReady, domain.EventReviewersAssigned:
+		if draft, _ := event.Payload["draft"].(bool); draft {
+			return "", false
+		}
+		return fmt.Sprintf("You were assigned to review %q (%s)", pr.Name, pr.ID), true
+	case domain.EventReviewerReassigned:
+		if event.Payload["replaced_by"] != userID {
+			return "", false
+		}
+		return fmt.Sprintf("You were assigned to review %q (%s)", pr.Name, pr.ID), true
+	case domain.EventPRMerged:
+		return fmt.Sprintf("%q (%s), which you review, was merged", pr.Name, pr.ID), true
+	}
+	return "", false
+}
+
+func composeDigest(lines []string) Message {
+	subject := "Review digest: 1 update"
+	if len(lines) != 1 {
+		subject = fmt.Sprintf("Review digest: %d updates", len(lines))
+	}
+	return Message{
+		Subject: subject,
+		Text:    "- " + strings.Join(lines, "\n- "),
+	}
+}
@@ -0,0 +1,192 @@
+// Package notify tells pull request authors when reviewers are assigned or
+// replaced and when their pull request is merged, over the channel each
+// author picked. Users who asked for a digest instead get a periodic summary
+// that also covers the reviews assigned to them.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"Avito2025/internal/config"
+	"Avito2025/internal/domain"
+	"Avito2025/internal/metrics"
+)
+
+// Store looks up the pull request behind an event and its author's
+// preference.
+type Store interface {
+	GetPullRequest(ctx context.Context, prID string) (domain.PullRequest, error)
+	GetNotificationPreference(ctx context.Context, userID string) (domain.NotificationPreference, error)
+}
+
+// Message is a notification about one pull request.
+type Message struct {
+	Subject string
+	Text    string
+}
+
+// Sender delivers a message to a recipient over one channel.
+type Sender interface {
+	Send(ctx context.Context, to domain.NotificationPreference, msg Message) error
+}
+
+// Dispatcher turns review events into notifications for the pull request
+// author. Deliveries are best effort: a failed one is logged and counted but
+// not retried.
+type Dispatcher struct {
+	store   Store
+	senders map[domain.NotifyChannel]Sender
+	workers int
+}
+
+// New builds a dispatcher with a sender for every channel configured in cfg.
+func New(store Store, cfg config.NotifyConfig) *Dispatcher {
+	senders := make(map[domain.NotifyChannel]Sender)
+	if cfg.SlackWebhookURL != "" {
+		senders[domain.NotifySlack] = newSlackSender(cfg.SlackWebhookURL, cfg.Timeout)
+	}
+	if cfg.SMTPAddr != "" && cfg.SMTPFrom != "" {
+		senders[domain.NotifyEmail] = newEmailSender(cfg)
+	}
+	return NewWithSenders(store, senders, cfg.Workers)
+}
+
+// NewWithSenders builds a dispatcher with the given senders.
+func NewWithSenders(store Store, senders map[domain.NotifyChannel]Sender, workers int) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Dispatcher{store: store, senders: senders, workers: workers}
+}
+
+// Run delivers notifications until ctx is done or events is closed, then
+// waits for in-flight deliveries.
+func (d *Dispatcher) Run(ctx context.Context, events <-chan domain.Event) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	slots := make(chan struct{}, d.workers)
+	for {
+		var event domain.Event
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			event = e
+		}
+
+		if !notifiable(event) {
+			continue
+		}
+		pr, err := d.store.GetPullRequest(ctx, event.EntityID)
+		if err != nil {
+			log.Printf("notify: get pull request %s: %v", event.EntityID, err)
+			continue
+		}
+		msg, ok := compose(event, pr)
+		if !ok {
+			continue
+		}
+		pref, err := d.store.GetNotificationPreference(ctx, pr.AuthorID)
+		if err != nil {
+			log.Printf("notify: get preference of %s: %v", pr.AuthorID, err)
+			continue
+		}
+		// Users on a digest hear about the event from SendDigests.
+		if pref.Channel == domain.NotifyNone || pref.Channel == "" || pref.Digest > 0 {
+			continue
+		}
+		sender, ok := d.senders[pref.Channel]
+		if !ok {
+			metrics.ObserveNotification(string(pref.Channel), "unconfigured")
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case slots <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			// A delivery in flight is allowed to finish on shutdown, bounded
+			// by the sender's timeout.
+			if err := sender.Send(context.WithoutCancel(ctx), pref, msg); err != nil {
+				log.Printf("notify: %s to %s about %s: %v", pref.Channel, pref.UserID, pr.ID, err)
+				metrics.ObserveNotification(string(pref.Channel), "failed")
+				return
+			}
+			metrics.ObserveNotification(string(pref.Channel), "sent")
+		}()
+	}
+}
+
+func notifiable(event domain.Event) bool {
+	switch event.Type {
+	case domain.EventPRCreated, domain.EventPRReady, domain.EventReviewersAssigned,
+		domain.EventReviewerReassigned, domain.EventPRMerged, domain.EventDeadlineExtended:
+		return true
+	default:
+		return false
+	}
+}
+
+// descriptionExcerpt bounds the part of a pull request's description quoted in
+// messages, in runes.
+const descriptionExcerpt = 280
+
+// withDescription appends the start of the pull request's description to
+// text, so that reviewers see what they are asked to look at.
+func withDescription(text string, pr domain.PullRequest) string {
+	description := strings.TrimSpace(pr.Description)
+	if description == "" {
+		return text
+	}
+	if runes := []rune(description); len(runes) > descriptionExcerpt {
+		description = strings.TrimSpace(string(runes[:descriptionExcerpt])) + "…"
+	}
+	return text + "\n\n" + description
+}
+
+// compose writes the message for an event; the flag is false when there is
+// nothing to tell, e.g. for a draft or a pull request still waiting for
+// reviewers.
+func compose(event domain.Event, pr domain.PullRequest) (Message, bool) {
+	switch event.Type {
+	case domain.EventPRCreated, domain.EventPRReady, domain.EventReviewersAssigned:
+		if draft, _ := event.Payload["draft"].(bool); draft || len(pr.AssignedReviewers) == 0 {
+			return Message{}, false
+		}
+		return Message{
+			Subject: fmt.Sprintf("Reviewers assigned to %s", pr.Name),
+			Text:    withDescription(fmt.Sprintf("Reviewers of %q (%s): %s.", pr.Name, pr.ID, strings.Join(pr.AssignedReviewers, ", ")), pr),
+		}, true
+	case domain.EventReviewerReassigned:
+		return Message{
+			Subject: fmt.Sprintf("Reviewer reassigned on %s", pr.Name),
+			Text: withDescription(fmt.Sprintf("%v was replaced by %v on %q (%s).",
+				event.Payload["old_user_id"], event.Payload["replaced_by"], pr.Name, pr.ID), pr),
+		}, true
+	case domain.EventDeadlineExtended:
+		return Message{
+			Subject: fmt.Sprintf("Review deadline of %s extended", pr.Name),
+			Text: fmt.Sprintf("%v has until %v to review %q (%s).",
+				event.Payload["reviewer_id"], event.Payload["until"], pr.Name, pr.ID),
+		}, true
+	case domain.EventPRMerged:
+		return Message{
+			Subject: fmt.Sprintf("%s was merged", pr.Name),
+			Text:    fmt.Sprintf("%q (%s) was merged.", pr.Name, pr.ID),
+		}, true
+	}
+	return Message{}, false
+}
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"Avito2025/internal/config"
+	"Avito2025/internal/domain"
+)
+
+// slackSender posts to a Slack incoming webhook, mentioning the recipient
+// when their member ID is known.
+type slackSender struct {
+	url    string
+	client *http.Client
+}
+
+func newSlackSender(url string, timeout time.Duration) *slackSender {
+	return &slackSender{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (s *slackSender) Send(ctx context.Context, to domain.NotificationPreference, msg Message) error {
+	text := msg.Text
+	if to.Address != "" {
+		text = fmt.Sprintf("<@%s> %s", to.Address, text)
+	} else {
+		text = fmt.Sprintf("%s: %s", to.UserID, text)
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// emailSender sends plain text mail through an SMTP relay, upgrading to TLS
+// when the relay offers it.
+type emailSender struct {
+	addr    string
+	from    string
+	auth    smtp.Auth
+	timeout time.Duration
+}
+
+func newEmailSender(cfg config.NotifyConfig) *emailSender {
+	sender := &emailSender{addr: cfg.SMTPAddr, from: cfg.SMTPFrom, timeout: cfg.Timeout}
+	if cfg.SMTPUsername != "" {
+		host, _, _ := net.SplitHostPort(cfg.SMTPAddr)
+		sender.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, host)
+	}
+	return sender
+}
+
+func (s *emailSender) Send(ctx context.Context, to domain.NotificationPreference, msg Message) error {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	if s.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(s.timeout))
+	}
+	host, _, _ := net.SplitHostPort(s.addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.auth != nil {
+		if err := client.Auth(s.auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	// Pull request names are user input; a line break would start a new
+	// header.
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(msg.Subject)
+	fmt.Fprintf(&b, "From: %s\r\nTo: %s\r\nSubject: %s\r\n", s.from, to.Address, subject)
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(msg.Text)
+	b.WriteString("\r\n")
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
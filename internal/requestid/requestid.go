@@ -0,0 +1,20 @@
+// Package requestid carries the ID of the request being served through a
+// context, so that the service and storage layers can tag their errors and
+// logs with it without depending on the transport.
+package requestid
+
+import "context"
+
+type key struct{}
+
+// With returns a copy of ctx carrying id.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// From returns the request ID carried by ctx, or "" outside a request, e.g. in
+// background jobs.
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(key{}).(string)
+	return id
+}
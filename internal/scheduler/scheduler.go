@@ -0,0 +1,58 @@
+// Package scheduler runs periodic background jobs.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a unit of periodic work. Run is called every Interval until the
+// scheduler stops; an error is logged and the job keeps its schedule.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+type Scheduler struct {
+	jobs []Job
+}
+
+func New(jobs ...Job) *Scheduler {
+	return &Scheduler{jobs: jobs}
+}
+
+// Run starts every job with a positive interval and blocks until ctx is done
+// and all in-flight runs have returned.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		if job.Interval <= 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			runJob(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+}
+
+func runJob(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job.Run(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("scheduler: job %s failed: %v", job.Name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"Avito2025/internal/domain"
+	"Avito2025/internal/service"
+)
+
+// Fixture mirrors the public API payloads so that fixture files can be written
+// by hand or captured from a running instance.
+type Fixture struct {
+	Teams        []teamFixture        `json:"teams"`
+	PullRequests []pullRequestFixture `json:"pull_requests"`
+}
+
+type teamFixture struct {
+	TeamName string          `json:"team_name"`
+	Members  []memberFixture `json:"members"`
+}
+
+type memberFixture struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	IsActive bool   `json:"is_active"`
+}
+
+type pullRequestFixture struct {
+	ID       string `json:"pull_request_id"`
+	Name     string `json:"pull_request_name"`
+	AuthorID string `json:"author_id"`
+	Status   string `json:"status"`
+}
+
+// LoadFile reads a JSON fixture from path and applies it through svc.
+func LoadFile(ctx context.Context, svc service.Service, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read seed file: %w", err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return fmt.Errorf("decode seed file: %w", err)
+	}
+
+	return Apply(ctx, svc, fixture)
+}
+
+// Apply creates the fixture's teams and pull requests. Entities that already
+// exist are left untouched, so seeding the same file on every start is safe.
+func Apply(ctx context.Context, svc service.Service, fixture Fixture) error {
+	for _, team := range fixture.Teams {
+		members := make([]domain.User, 0, len(team.Members))
+		for _, member := range team.Members {
+			members = append(members, domain.User{
+				ID:       member.UserID,
+				Username: member.Username,
+				TeamName: team.TeamName,
+				IsActive: member.IsActive,
+			})
+		}
+
+		// Fixtures may list a user in several teams.
+		_, _, err := svc.CreateTeam(ctx, domain.Team{Name: team.TeamName, Members: members}, domain.ConflictJoin)
+		if err != nil && !errors.Is(err, domain.ErrTeamExists) {
+			return fmt.Errorf("seed team %s: %w", team.TeamName, err)
+		}
+	}
+
+	for _, pr := range fixture.PullRequests {
+		created := domain.PullRequest{
+			ID:       pr.ID,
+			Name:     pr.Name,
+			AuthorID: pr.AuthorID,
+		}
+		if domain.PRStatus(pr.Status) == domain.StatusDraft {
+			created.Status = domain.StatusDraft
+		}
+		_, err := svc.CreatePullRequest(ctx, created)
+		if errors.Is(err, domain.ErrPRExists) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("seed pull request %s: %w", pr.ID, err)
+		}
+
+		if domain.PRStatus(pr.Status) == domain.StatusMerged {
+			if _, err := svc.MergePullRequest(ctx, pr.ID); err != nil {
+				return fmt.Errorf("merge seeded pull request %s: %w", pr.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package seed
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Avito2025/internal/domain"
+	"Avito2025/internal/service"
+	"Avito2025/internal/storage/storagetest"
+)
+
+func TestLoadFileSeedsDemoFixture(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	// Loading twice must not fail, as it happens on every restart.
+	for range 2 {
+		if err := LoadFile(ctx, svc, filepath.Join("..", "..", "fixtures", "demo.json")); err != nil {
+			t.Fatalf("LoadFile: %v", err)
+		}
+	}
+
+	team, err := svc.GetTeam(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeam: %v", err)
+	}
+	if len(team.Members) != 4 {
+		t.Fatalf("expected 4 backend members, got %+v", team.Members)
+	}
+	for _, member := range team.Members {
+		if member.IsActive != (member.ID != "u4") {
+			t.Errorf("unexpected activity of %s: %v", member.ID, member.IsActive)
+		}
+	}
+	if _, err := svc.GetTeam(ctx, "frontend"); err != nil {
+		t.Fatalf("GetTeam frontend: %v", err)
+	}
+
+	want := map[string]domain.PRStatus{
+		"pr-1001": domain.StatusOpen,
+		"pr-1002": domain.StatusMerged,
+		"pr-1003": domain.StatusOpen,
+	}
+	for id, status := range want {
+		pr, err := svc.GetPullRequest(ctx, id)
+		if err != nil {
+			t.Fatalf("GetPullRequest %s: %v", id, err)
+		}
+		if pr.Status != status {
+			t.Errorf("expected %s to be %s, got %s", id, status, pr.Status)
+		}
+	}
+}
+
+func TestApplyKeepsDraftsAndSharedMembers(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	fixture := Fixture{
+		Teams: []teamFixture{
+			{TeamName: "backend", Members: []memberFixture{
+				{UserID: "u1", Username: "Alice", IsActive: true},
+				{UserID: "u2", Username: "Bob", IsActive: true},
+			}},
+			{TeamName: "platform", Members: []memberFixture{
+				{UserID: "u2", Username: "Bob", IsActive: true},
+				{UserID: "u3", Username: "Carol", IsActive: true},
+			}},
+		},
+		PullRequests: []pullRequestFixture{
+			{ID: "pr-1", Name: "Draft", AuthorID: "u1", Status: string(domain.StatusDraft)},
+		},
+	}
+	if err := Apply(ctx, svc, fixture); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	pr, err := svc.GetPullRequest(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if pr.Status != domain.StatusDraft || len(pr.AssignedReviewers) != 0 {
+		t.Fatalf("expected an unassigned draft, got %s with %v", pr.Status, pr.AssignedReviewers)
+	}
+	if _, err := svc.GetTeam(ctx, "platform"); err != nil {
+		t.Fatalf("expected a team sharing a member to be seeded: %v", err)
+	}
+}
+
+func TestLoadFileReportsBadFiles(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+	dir := t.TempDir()
+
+	malformed := filepath.Join(dir, "malformed.json")
+	if err := os.WriteFile(malformed, []byte(`{"teams": [`), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	unknownAuthor := filepath.Join(dir, "unknown-author.json")
+	if err := os.WriteFile(unknownAuthor, []byte(`{"pull_requests": [{"pull_request_id": "pr-1", "pull_request_name": "Orphan", "author_id": "ghost"}]}`), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+
+	for _, path := range []string{filepath.Join(dir, "missing.json"), malformed, unknownAuthor} {
+		if err := LoadFile(ctx, svc, path); err == nil {
+			t.Errorf("expected %s to fail", filepath.Base(path))
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"Avito2025/internal/domain"
+)
+
+// requestAcceptance withdraws the pending acceptances of the removed reviewers
+// and, when the author's team has an acceptance step, asks the added ones to
+// accept the review within the team's timeout.
+func (s *ReviewerService) requestAcceptance(ctx context.Context, pr *domain.PullRequest, removed, added []string) error {
+	var requested []domain.ReviewAcceptance
+	if len(added) > 0 && pr.Status == domain.StatusOpen {
+		author, err := s.repo.GetUser(ctx, pr.AuthorID)
+		if err != nil {
+			return err
+		}
+		settings, err := s.repo.GetTeamSettings(ctx, author.TeamName)
+		if err != nil {
+			return err
+		}
+		if settings.AcceptTimeout > 0 {
+			now := s.now()
+			for _, reviewer := range added {
+				// An author filling a slot has nobody to accept from.
+				if reviewer == pr.AuthorID {
+					continue
+				}
+				requested = append(requested, domain.ReviewAcceptance{
+					PullRequestID: pr.ID,
+					ReviewerID:    reviewer,
+					RequestedAt:   now,
+					AcceptBy:      now.Add(settings.AcceptTimeout),
+				})
+			}
+		}
+	}
+	if len(removed) == 0 && len(requested) == 0 {
+		return nil
+	}
+
+	if err := s.repo.SyncReviewAcceptances(ctx, pr.ID, removed, requested); err != nil {
+		return err
+	}
+	for _, reviewer := range removed {
+		delete(pr.Acceptance, reviewer)
+	}
+	for _, acceptance := range requested {
+		if pr.Acceptance == nil {
+			pr.Acceptance = make(map[string]domain.AcceptanceState)
+		}
+		pr.Acceptance[acceptance.ReviewerID] = domain.AcceptancePending
+	}
+	return nil
+}
+
+// AcceptReview records that a reviewer took the review on. Accepting twice, or
+// as a reviewer who owes no acceptance, leaves the pull request unchanged. A
+// reviewer whose acceptance expired without a replacement may still accept.
+func (s *ReviewerService) AcceptReview(ctx context.Context, prID, reviewerID string) (domain.PullRequest, error) {
+	pr, err := s.repo.GetPullRequest(ctx, prID)
+	if err != nil {
+		return domain.PullRequest{}, err
+	}
+	if pr.Status == domain.StatusMerged {
+		return domain.PullRequest{}, domain.ErrPRMerged
+	}
+	if !contains(pr.AssignedReviewers, reviewerID) {
+		return domain.PullRequest{}, domain.ErrReviewerNotFound
+	}
+
+	err = s.atomically(ctx, func(ctx context.Context) error {
+		accepted, err := s.repo.AcceptReview(ctx, prID, reviewerID, s.now())
+		if err != nil || !accepted {
+			return err
+		}
+
+		if pr.Acceptance == nil {
+			pr.Acceptance = make(map[string]domain.AcceptanceState)
+		}
+		pr.Acceptance[reviewerID] = domain.AcceptanceAccepted
+		return s.recordPullRequestEvent(ctx, domain.EventReviewAccepted, pr, map[string]any{
+			"reviewer_id": reviewerID,
+		})
+	})
+	if err != nil {
+		return domain.PullRequest{}, err
+	}
+	return pr, nil
+}
+
+// ProcessExpiredAcceptances replaces the reviewers who did not accept their
+// review in time. A reviewer nobody can replace keeps the review with an
+// EXPIRED acceptance, which the reviewer may still give. An acceptance whose
+// replacement fails otherwise is logged and made pending again, so that the
+// next run retries it, and the ones after it are still processed.
+func (s *ReviewerService) ProcessExpiredAcceptances(ctx context.Context, now time.Time) error {
+	expired, err := s.repo.ExpireReviewAcceptances(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for _, acceptance := range expired {
+		err := ctx.Err()
+		if err == nil {
+			_, _, err = s.ReassignReviewer(ctx, acceptance.PullRequestID, acceptance.ReviewerID)
+		}
+		if err == nil {
+			continue
+		}
+		log.Printf("expired acceptance of %s on %s: %v", acceptance.ReviewerID, acceptance.PullRequestID, err)
+		var domainErr *domain.Error
+		if errors.As(err, &domainErr) {
+			continue
+		}
+		if err := s.repo.RestoreReviewAcceptance(context.WithoutCancel(ctx), acceptance); err != nil {
+			log.Printf("restore acceptance of %s on %s: %v", acceptance.ReviewerID, acceptance.PullRequestID, err)
+		}
+	}
+	return ctx.Err()
+}
+
+// AcceptanceReport summarises the acceptances requested from reviewers of the
+// team's pull requests within the period.
+func (s *ReviewerService) AcceptanceReport(ctx context.Context, teamName string, period time.Duration) (domain.AcceptanceReport, error) {
+	since := s.now().Add(-period)
+
+	acceptances, err := s.repo.ListReviewAcceptances(ctx, teamName, since)
+	if err != nil {
+		return domain.AcceptanceReport{}, err
+	}
+
+	report := domain.AcceptanceReport{
+		TeamName:  teamName,
+		Since:     since,
+		Requested: len(acceptances),
+	}
+	var durations []time.Duration
+	for _, acceptance := range acceptances {
+		switch acceptance.State() {
+		case domain.AcceptanceAccepted:
+			report.Accepted++
+			durations = append(durations, acceptance.AcceptedAt.Sub(acceptance.RequestedAt))
+		case domain.AcceptanceExpired:
+			report.Expired++
+		default:
+			report.Pending++
+		}
+	}
+	if len(durations) > 0 {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		report.Median = percentile(durations, 50)
+		report.P90 = percentile(durations, 90)
+	}
+	return report, nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"unicode"
+
+	"Avito2025/internal/domain"
+)
+
+const (
+	// affinityHistoryLimit bounds how many past pull requests are scanned when
+	// ranking candidates by affinity.
+	affinityHistoryLimit = 500
+	// minTokenLength drops short words such as "a" or "to" from names.
+	minTokenLength = 3
+)
+
+func (s *ReviewerService) pickByStrategy(ctx context.Context, rnd *rand.Rand, strategy domain.SelectionStrategy, pr domain.PullRequest, candidates []domain.User, limit int) ([]string, error) {
+	if strategy == domain.StrategyAffinity {
+		return s.pickByAffinity(ctx, rnd, pr, candidates, limit)
+	}
+	return pickReviewers(rnd, candidates, limit), nil
+}
+
+// pickByAffinity ranks candidates by how much the names and labels of pull
+// requests they authored or reviewed overlap with pr, so reviewers familiar
+// with the area are preferred. Candidates with equal scores, including those
+// without any history, are ordered at random.
+func (s *ReviewerService) pickByAffinity(ctx context.Context, rnd *rand.Rand, pr domain.PullRequest, candidates []domain.User, limit int) ([]string, error) {
+	if len(candidates) == 0 || limit <= 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		ids = append(ids, candidate.ID)
+	}
+	history, err := s.repo.ListParticipatedPullRequests(ctx, ids, affinityHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	target := affinityTokens(pr.Name, pr.Labels)
+	scores := make(map[string]int, len(candidates))
+	for _, past := range history {
+		overlap := 0
+		for token := range affinityTokens(past.Name, past.Labels) {
+			if _, ok := target[token]; ok {
+				overlap++
+			}
+		}
+		if overlap == 0 {
+			continue
+		}
+		scores[past.AuthorID] += overlap
+		for _, reviewer := range past.AssignedReviewers {
+			if reviewer != past.AuthorID {
+				scores[reviewer] += overlap
+			}
+		}
+	}
+
+	rnd.Shuffle(len(ids), func(i, j int) {
+		ids[i], ids[j] = ids[j], ids[i]
+	})
+	sort.SliceStable(ids, func(i, j int) bool {
+		return scores[ids[i]] > scores[ids[j]]
+	})
+
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+// affinityTokens splits a pull request name into lower-cased words and adds
+// its labels, prefixed so that a label never matches a word.
+func affinityTokens(name string, labels []string) map[string]struct{} {
+	tokens := make(map[string]struct{})
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if len([]rune(word)) >= minTokenLength {
+			tokens[word] = struct{}{}
+		}
+	}
+	for _, label := range labels {
+		tokens["label:"+strings.ToLower(label)] = struct{}{}
+	}
+	return tokens
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// archiveBatchSize bounds how many pull requests are moved per transaction.
+const archiveBatchSize = 500
+
+// ArchivePullRequests moves pull requests merged before the cutoff out of the
+// hot tables and returns how many were moved. Archived pull requests can still
+// be fetched by ID but no longer appear in listings or statistics.
+func (s *ReviewerService) ArchivePullRequests(ctx context.Context, mergedBefore time.Time) (int, error) {
+	total := 0
+	for {
+		archived, err := s.repo.ArchivePullRequests(ctx, mergedBefore, archiveBatchSize)
+		total += archived
+		if err != nil {
+			return total, err
+		}
+		if archived < archiveBatchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Clock tells the service the current time. Creation and merge times,
+// snoozes, acceptance deadlines and report periods are all taken from it.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to Clock.
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time { return f() }
+
+// IDGenerator mints the random identifiers the service hands out, such as
+// webhook secrets.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// IDGeneratorFunc adapts a function to IDGenerator.
+type IDGeneratorFunc func() (string, error)
+
+func (f IDGeneratorFunc) NewID() (string, error) { return f() }
+
+// randomIDs generates hex-encoded random IDs of the given length in bytes.
+type randomIDs int
+
+func (n randomIDs) NewID() (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// WithClock makes the service read the time from clock instead of the system
+// clock. Meant for tests of time-dependent rules.
+func WithClock(clock Clock) Option {
+	return func(s *ReviewerService) {
+		s.clock = clock
+	}
+}
+
+// WithIDGenerator makes the service mint identifiers with ids instead of
+// random ones. Meant for tests.
+func WithIDGenerator(ids IDGenerator) Option {
+	return func(s *ReviewerService) {
+		s.ids = ids
+	}
+}
+
+// now returns the current time of the service's clock in UTC.
+func (s *ReviewerService) now() time.Time {
+	return s.clock.Now().UTC()
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"Avito2025/internal/domain"
+)
+
+// BuildDailyStats rebuilds the daily review aggregates from the last day
+// built, or yesterday if that is later, through today so far. Rebuilding the
+// last day built and yesterday on every run catches activity recorded after
+// the previous run, and starting from the last day built fills in every day
+// missed while the service was down. The days are built in order, so a run
+// that fails part way is resumed by the next one.
+func (s *ReviewerService) BuildDailyStats(ctx context.Context, now time.Time) error {
+	today := now.UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -1)
+	last, err := s.repo.LastDailyReviewStatsDay(ctx)
+	if err != nil {
+		return err
+	}
+	if !last.IsZero() && last.Before(from) {
+		from = last.UTC().Truncate(24 * time.Hour)
+	}
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		if err := s.repo.BuildDailyReviewStats(ctx, day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DailyStats returns the daily review aggregates of the days from from to to,
+// both included. An empty teamName covers all teams.
+func (s *ReviewerService) DailyStats(ctx context.Context, teamName string, from, to time.Time) ([]domain.DailyReviewStats, error) {
+	if teamName != "" {
+		if _, err := s.repo.GetTeam(ctx, teamName); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.ListDailyReviewStats(ctx, teamName, from, to)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+
+	"Avito2025/internal/domain"
+)
+
+// AssignmentTrace returns the decisions that picked the pull request's
+// reviewers, oldest first.
+func (s *ReviewerService) AssignmentTrace(ctx context.Context, prID string) ([]domain.AssignmentDecision, error) {
+	if _, err := s.repo.GetPullRequest(ctx, prID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListAssignmentDecisions(ctx, prID)
+}
+
+// seededRand draws a seed from the service's source and returns it with a
+// source of its own, so that a recorded pick can be replayed. The returned
+// source belongs to the caller and is not shared between goroutines.
+func (s *ReviewerService) seededRand() (int64, *rand.Rand) {
+	seed := s.seeds.Int64()
+	return seed, rand.New(rand.NewPCG(uint64(seed), 0))
+}
+
+// newSeeds returns the service's seed generator. A math/rand/v2 Rand keeps no
+// state besides its source, so locking the source makes it safe for
+// concurrent use.
+func newSeeds(seed uint64) *rand.Rand {
+	return rand.New(&lockedSource{src: rand.NewPCG(seed, 0)})
+}
+
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (l *lockedSource) Uint64() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.src.Uint64()
+}
+
+// snapshotCandidates records every team member with the first filter that
+// excluded it. excludedBy covers the filters that do not need the repository;
+// members it accepts but that are missing from eligible were dropped by the
+// open review limit.
+func snapshotCandidates(members, eligible []domain.User, excludedBy func(domain.User) string) []domain.DecisionCandidate {
+	kept := make(map[string]bool, len(eligible))
+	for _, user := range eligible {
+		kept[user.ID] = true
+	}
+
+	candidates := make([]domain.DecisionCandidate, 0, len(members))
+	for _, member := range members {
+		reason := excludedBy(member)
+		if reason == "" && !kept[member.ID] {
+			reason = domain.FilterMaxOpenReviews
+		}
+		candidates = append(candidates, domain.DecisionCandidate{UserID: member.ID, ExcludedBy: reason})
+	}
+	return candidates
+}
+
+// appliedFilters lists the given filters followed by the open review limit
+// when the team sets one.
+func appliedFilters(settings domain.TeamSettings, filters ...string) []string {
+	if settings.MaxOpenReviews > 0 {
+		filters = append(filters, domain.FilterMaxOpenReviews)
+	}
+	return filters
+}
+
+// authorFilters lists the filters keeping the authors of pr out of the pick,
+// the author and any co-authors, followed by the given filters.
+func authorFilters(pr domain.PullRequest, filters ...string) []string {
+	applied := []string{domain.FilterAuthor}
+	if len(pr.CoAuthors) > 0 {
+		applied = append(applied, domain.FilterCoAuthor)
+	}
+	return append(applied, filters...)
+}
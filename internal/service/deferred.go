@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"Avito2025/internal/domain"
+)
+
+// ProcessDeferredAssignments picks reviewers for pull requests created
+// outside their team's working hours once the team is at work again. The
+// assignment is announced with a REVIEWERS_ASSIGNED event; slots left open
+// are queued for ProcessPendingAssignments. Pull requests that were closed or
+// got reviewers by hand in the meantime are dropped from the queue.
+func (s *ReviewerService) ProcessDeferredAssignments(ctx context.Context, now time.Time) error {
+	deferred, err := s.repo.ListDeferredAssignments(ctx)
+	if err != nil {
+		return err
+	}
+
+	// An assignment that fails stays queued for the next run and is logged,
+	// without holding back the ones after it.
+	settingsByTeam := make(map[string]domain.TeamSettings)
+	for _, assignment := range deferred {
+		if err := s.processDeferred(ctx, assignment, settingsByTeam, now); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("deferred assignment of %s: %v", assignment.PullRequestID, err)
+		}
+	}
+	return nil
+}
+
+// processDeferred dispatches the assignment if its team is at work, caching
+// the team settings it reads in settingsByTeam.
+func (s *ReviewerService) processDeferred(ctx context.Context, assignment domain.DeferredAssignment, settingsByTeam map[string]domain.TeamSettings, now time.Time) error {
+	settings, ok := settingsByTeam[assignment.TeamName]
+	if !ok {
+		var err error
+		settings, err = s.repo.GetTeamSettings(ctx, assignment.TeamName)
+		if errors.Is(err, domain.ErrTeamNotFound) {
+			return s.repo.DeleteDeferredAssignment(ctx, assignment.PullRequestID)
+		}
+		if err != nil {
+			return err
+		}
+		settingsByTeam[assignment.TeamName] = settings
+	}
+	if !settings.WorkingAt(now) {
+		return nil
+	}
+
+	return s.atomically(ctx, func(ctx context.Context) error {
+		if err := s.dispatchDeferred(ctx, assignment, settings); err != nil {
+			return err
+		}
+		return s.repo.DeleteDeferredAssignment(ctx, assignment.PullRequestID)
+	})
+}
+
+func (s *ReviewerService) dispatchDeferred(ctx context.Context, assignment domain.DeferredAssignment, settings domain.TeamSettings) error {
+	pr, err := s.repo.GetPullRequest(ctx, assignment.PullRequestID)
+	if errors.Is(err, domain.ErrPullRequestNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if pr.Status != domain.StatusOpen || len(pr.AssignedReviewers) > 0 {
+		return nil
+	}
+
+	author, err := s.repo.GetUser(ctx, pr.AuthorID)
+	if err != nil {
+		return err
+	}
+	members, err := s.repo.ListUsersByTeam(ctx, assignment.TeamName)
+	if err != nil {
+		return err
+	}
+	required, err := requiredReviewers(settings, pr)
+	if err != nil {
+		// The team lowered its required reviewers below the requested count.
+		required = settings.RequiredReviewers
+	}
+
+	decision, err := s.pickInitialReviewers(ctx, pr, author, assignment.TeamName, members, settings, required)
+	if errors.Is(err, domain.ErrNotEnoughReviewers) {
+		log.Printf("deferred assignment of %s: %v", pr.ID, err)
+		return s.repo.QueuePendingAssignment(ctx, pr.ID)
+	}
+	if err != nil {
+		return err
+	}
+	if len(decision.Selected) < required {
+		if err := s.repo.QueuePendingAssignment(ctx, pr.ID); err != nil {
+			return err
+		}
+	}
+
+	pr.AssignedReviewers = decision.Selected
+	updated, err := s.repo.UpdatePullRequest(ctx, pr)
+	if err != nil {
+		return err
+	}
+	if err := s.recordReviewerChanges(ctx, &updated, nil, updated.AssignedReviewers, domain.ReasonAuto); err != nil {
+		return err
+	}
+	if err := s.repo.AppendAssignmentDecision(ctx, decision); err != nil {
+		return err
+	}
+	return s.recordPullRequestEvent(ctx, domain.EventReviewersAssigned, updated, map[string]any{
+		"assigned_reviewers": updated.AssignedReviewers,
+		"deferred":           true,
+	})
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"Avito2025/internal/domain"
+)
+
+// RequestDeadlineExtension asks for time to review the pull request until the
+// given moment. Extensions the author's team approves automatically take
+// effect at once; the others wait for a lead's decision.
+func (s *ReviewerService) RequestDeadlineExtension(ctx context.Context, prID, reviewerID string, until time.Time, reason string) (domain.DeadlineExtension, error) {
+	pr, err := s.repo.GetPullRequest(ctx, prID)
+	if err != nil {
+		return domain.DeadlineExtension{}, err
+	}
+	if pr.Status == domain.StatusMerged {
+		return domain.DeadlineExtension{}, domain.ErrPRMerged
+	}
+	if !contains(pr.AssignedReviewers, reviewerID) {
+		return domain.DeadlineExtension{}, domain.ErrReviewerNotFound
+	}
+
+	now := s.now()
+	reason = strings.TrimSpace(reason)
+	if !until.After(now) || until.After(now.Add(maxExtension)) || len(reason) > maxExtensionReasonBytes {
+		return domain.DeadlineExtension{}, domain.ErrInvalidExtension
+	}
+	if pr.Deadline != nil && !until.After(*pr.Deadline) {
+		return domain.DeadlineExtension{}, domain.ErrInvalidExtension
+	}
+
+	author, err := s.repo.GetUser(ctx, pr.AuthorID)
+	if err != nil {
+		return domain.DeadlineExtension{}, err
+	}
+	settings, err := s.repo.GetTeamSettings(ctx, author.TeamName)
+	if err != nil {
+		return domain.DeadlineExtension{}, err
+	}
+
+	extension := domain.DeadlineExtension{
+		PullRequestID: pr.ID,
+		ReviewerID:    reviewerID,
+		Until:         until,
+		Reason:        reason,
+		Status:        domain.ExtensionPending,
+		RequestedAt:   now,
+	}
+	eventType := domain.EventExtensionRequested
+	if settings.AutoApproveExtension > 0 && until.Sub(now) <= settings.AutoApproveExtension {
+		extension.Status = domain.ExtensionApproved
+		extension.DecidedAt = &now
+		eventType = domain.EventDeadlineExtended
+	}
+
+	err = s.atomically(ctx, func(ctx context.Context) error {
+		var err error
+		extension, err = s.repo.CreateDeadlineExtension(ctx, extension)
+		if err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, eventType, author.TeamName, pr.ID, extensionPayload(extension))
+	})
+	if err != nil {
+		return domain.DeadlineExtension{}, err
+	}
+	return extension, nil
+}
+
+// DecideDeadlineExtension approves or rejects a pending extension on behalf of
+// deciderID. An approved extension moves the pull request's deadline unless an
+// earlier approval already reaches further.
+func (s *ReviewerService) DecideDeadlineExtension(ctx context.Context, id int64, approve bool, deciderID string) (domain.DeadlineExtension, error) {
+	extension, err := s.repo.GetDeadlineExtension(ctx, id)
+	if err != nil {
+		return domain.DeadlineExtension{}, err
+	}
+	pr, err := s.repo.GetPullRequest(ctx, extension.PullRequestID)
+	if err != nil {
+		return domain.DeadlineExtension{}, err
+	}
+	if pr.Status == domain.StatusMerged {
+		return domain.DeadlineExtension{}, domain.ErrPRMerged
+	}
+
+	status, eventType := domain.ExtensionRejected, domain.EventExtensionRejected
+	if approve {
+		status, eventType = domain.ExtensionApproved, domain.EventDeadlineExtended
+	}
+	err = s.atomically(ctx, func(ctx context.Context) error {
+		var err error
+		extension, err = s.repo.DecideDeadlineExtension(ctx, id, status, deciderID, s.now())
+		if err != nil {
+			return err
+		}
+		return s.recordPullRequestEvent(ctx, eventType, pr, extensionPayload(extension))
+	})
+	if err != nil {
+		return domain.DeadlineExtension{}, err
+	}
+	return extension, nil
+}
+
+// GetDeadlineExtension returns the extension request with the given ID.
+func (s *ReviewerService) GetDeadlineExtension(ctx context.Context, id int64) (domain.DeadlineExtension, error) {
+	return s.repo.GetDeadlineExtension(ctx, id)
+}
+
+// ListDeadlineExtensions returns the extensions requested for the pull
+// request, oldest first.
+func (s *ReviewerService) ListDeadlineExtensions(ctx context.Context, prID string) ([]domain.DeadlineExtension, error) {
+	if _, err := s.repo.GetPullRequest(ctx, prID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeadlineExtensions(ctx, prID)
+}
+
+func extensionPayload(extension domain.DeadlineExtension) map[string]any {
+	payload := map[string]any{
+		"extension_id": extension.ID,
+		"reviewer_id":  extension.ReviewerID,
+		"until":        extension.Until.UTC().Format(time.RFC3339),
+		"status":       string(extension.Status),
+	}
+	if extension.Reason != "" {
+		payload["reason"] = extension.Reason
+	}
+	if extension.DecidedBy != "" {
+		payload["decided_by"] = extension.DecidedBy
+	}
+	return payload
+}
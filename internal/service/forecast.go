@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"Avito2025/internal/domain"
+)
+
+// forecastHorizon is how far ahead LoadForecast looks.
+const forecastHorizon = 7 * 24 * time.Hour
+
+// LoadForecast estimates the review assignments each member of the team can
+// expect over the coming week. Every author is assumed to keep creating pull
+// requests at their rate over the period, each asking for the team's required
+// reviewers; the reviewers are drawn from the other members in proportion to
+// their availability. Rotation, ownership, the mandatory reviewer and review
+// limits are not taken into account.
+func (s *ReviewerService) LoadForecast(ctx context.Context, teamName string, period time.Duration) (domain.LoadForecast, error) {
+	now := s.now()
+	forecast := domain.LoadForecast{
+		TeamName: teamName,
+		Since:    now.Add(-period),
+		From:     now,
+		To:       now.Add(forecastHorizon),
+	}
+
+	members, err := s.repo.ListUsersByTeam(ctx, teamName)
+	if err != nil {
+		return domain.LoadForecast{}, err
+	}
+	settings, err := s.repo.GetTeamSettings(ctx, teamName)
+	if err != nil {
+		return domain.LoadForecast{}, err
+	}
+	created, err := s.repo.CountCreatedPullRequests(ctx, teamName, forecast.Since)
+	if err != nil {
+		return domain.LoadForecast{}, err
+	}
+
+	availability := make([]float64, len(members))
+	for i, member := range members {
+		availability[i] = availableShare(member, forecast.From, forecast.To)
+	}
+
+	authors := make([]string, 0, len(created))
+	for authorID := range created {
+		authors = append(authors, authorID)
+	}
+	sort.Strings(authors)
+
+	assignments := make([]float64, len(members))
+	slots := float64(settings.RequiredReviewers)
+	for _, authorID := range authors {
+		expected := float64(created[authorID]) * float64(forecastHorizon) / float64(period)
+		forecast.PullRequests += expected
+
+		var pool float64
+		for i, member := range members {
+			if member.ID != authorID {
+				pool += availability[i]
+			}
+		}
+		filled := math.Min(slots, pool)
+		forecast.Unfilled += expected * (slots - filled)
+		if filled == 0 {
+			continue
+		}
+		for i, member := range members {
+			if member.ID != authorID {
+				assignments[i] += expected * filled * availability[i] / pool
+			}
+		}
+	}
+
+	forecast.Members = make([]domain.MemberForecast, 0, len(members))
+	for i, member := range members {
+		forecast.Members = append(forecast.Members, domain.MemberForecast{
+			UserID:       member.ID,
+			Username:     member.Username,
+			Availability: availability[i],
+			Assignments:  assignments[i],
+		})
+	}
+	return forecast, nil
+}
+
+// availableShare returns the part of from..to during which the user can be
+// picked as a reviewer.
+func availableShare(user domain.User, from, to time.Time) float64 {
+	switch {
+	case !user.IsActive:
+		return 0
+	case !user.Snoozed(from):
+		return 1
+	case !user.SnoozedUntil.Before(to):
+		return 0
+	}
+	return float64(to.Sub(*user.SnoozedUntil)) / float64(to.Sub(from))
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"Avito2025/internal/domain"
+)
+
+// SetTeamLead grants or revokes the lead role of a team member and returns
+// the team's leads.
+func (s *ReviewerService) SetTeamLead(ctx context.Context, teamName, userID string, isLead bool) ([]string, error) {
+	if err := s.repo.SetTeamLead(ctx, teamName, userID, isLead); err != nil {
+		return nil, err
+	}
+	return s.repo.ListTeamLeads(ctx, teamName)
+}
+
+func (s *ReviewerService) ListTeamLeads(ctx context.Context, teamName string) ([]string, error) {
+	if _, err := s.repo.GetTeam(ctx, teamName); err != nil {
+		return nil, err
+	}
+	return s.repo.ListTeamLeads(ctx, teamName)
+}
+
+// AuthorizeTeamLead returns ErrForbidden unless callerID is a lead of the team
+// and still one of its members. Unknown callers are forbidden as well.
+func (s *ReviewerService) AuthorizeTeamLead(ctx context.Context, callerID, teamName string) error {
+	caller, err := s.repo.GetUser(ctx, callerID)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return domain.ErrForbidden
+	}
+	if err != nil {
+		return err
+	}
+	if !contains(caller.Teams, teamName) {
+		return domain.ErrForbidden
+	}
+
+	leads, err := s.repo.ListTeamLeads(ctx, teamName)
+	if err != nil {
+		return err
+	}
+	if !contains(leads, callerID) {
+		return domain.ErrForbidden
+	}
+	return nil
+}
+
+// AuthorizePullRequestLead checks that callerID leads the team of the pull
+// request's author.
+func (s *ReviewerService) AuthorizePullRequestLead(ctx context.Context, callerID, prID string) error {
+	pr, err := s.repo.GetPullRequest(ctx, prID)
+	if err != nil {
+		return err
+	}
+	author, err := s.repo.GetUser(ctx, pr.AuthorID)
+	if err != nil {
+		return err
+	}
+	return s.AuthorizeTeamLead(ctx, callerID, author.TeamName)
+}
+
+// AuthorizeExtensionLead checks that callerID leads the team of the author of
+// the pull request the extension was requested for.
+func (s *ReviewerService) AuthorizeExtensionLead(ctx context.Context, callerID string, extensionID int64) error {
+	extension, err := s.repo.GetDeadlineExtension(ctx, extensionID)
+	if err != nil {
+		return err
+	}
+	return s.AuthorizePullRequestLead(ctx, callerID, extension.PullRequestID)
+}
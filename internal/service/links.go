@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+
+	"Avito2025/internal/domain"
+)
+
+// LinkPullRequests marks the pull request as blocked by another one. Both must
+// be unmerged, and a link closing a cycle is refused, since the pull requests
+// on it could never be merged under BlockMergeOnDependencies. Linking twice
+// is not an error.
+func (s *ReviewerService) LinkPullRequests(ctx context.Context, prID, blockedBy string) (domain.PullRequest, error) {
+	if prID == blockedBy {
+		return domain.PullRequest{}, domain.ErrInvalidLink
+	}
+	pr, err := s.repo.GetPullRequest(ctx, prID)
+	if err != nil {
+		return domain.PullRequest{}, err
+	}
+	blocker, err := s.repo.GetPullRequest(ctx, blockedBy)
+	if err != nil {
+		return domain.PullRequest{}, err
+	}
+	if pr.Status == domain.StatusMerged || blocker.Status == domain.StatusMerged {
+		return domain.PullRequest{}, domain.ErrPRMerged
+	}
+	if err := s.checkDependencyCycle(ctx, prID, blocker); err != nil {
+		return domain.PullRequest{}, err
+	}
+
+	return s.changeLink(ctx, prID, func(ctx context.Context) (bool, error) {
+		return s.repo.LinkPullRequests(ctx, prID, blockedBy)
+	})
+}
+
+// UnlinkPullRequests removes the link made by LinkPullRequests. Removing a
+// missing link is not an error.
+func (s *ReviewerService) UnlinkPullRequests(ctx context.Context, prID, blockedBy string) (domain.PullRequest, error) {
+	if _, err := s.repo.GetPullRequest(ctx, prID); err != nil {
+		return domain.PullRequest{}, err
+	}
+	return s.changeLink(ctx, prID, func(ctx context.Context) (bool, error) {
+		return s.repo.UnlinkPullRequests(ctx, prID, blockedBy)
+	})
+}
+
+// checkDependencyCycle fails when the pull request is already, directly or
+// through others, a blocker of the would-be blocker.
+func (s *ReviewerService) checkDependencyCycle(ctx context.Context, prID string, blocker domain.PullRequest) error {
+	seen := map[string]bool{blocker.ID: true}
+	queue := append([]string(nil), blocker.BlockedBy...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == prID {
+			return domain.ErrDependencyCycle
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		pr, err := s.repo.GetPullRequest(ctx, id)
+		if err != nil {
+			return err
+		}
+		queue = append(queue, pr.BlockedBy...)
+	}
+	return nil
+}
+
+// changeLink applies change, which reports whether it changed the links of
+// the pull request, and announces the new set of blockers as PR_UPDATED in
+// the same transaction.
+func (s *ReviewerService) changeLink(ctx context.Context, prID string, change func(ctx context.Context) (bool, error)) (domain.PullRequest, error) {
+	var pr domain.PullRequest
+	err := s.atomically(ctx, func(ctx context.Context) error {
+		changed, err := change(ctx)
+		if err != nil {
+			return err
+		}
+		pr, err = s.repo.GetPullRequest(ctx, prID)
+		if err != nil || !changed {
+			return err
+		}
+		return s.recordPullRequestEvent(ctx, domain.EventPRUpdated, pr, map[string]any{
+			"blocked_by": append([]string{}, pr.BlockedBy...),
+		})
+	})
+	if err != nil {
+		return domain.PullRequest{}, err
+	}
+	return pr, nil
+}
+
+// checkBlockers refuses to merge an open pull request with unmerged blockers
+// when its author's team has BlockMergeOnDependencies.
+func (s *ReviewerService) checkBlockers(ctx context.Context, prID string) error {
+	pr, err := s.repo.GetPullRequest(ctx, prID)
+	if err != nil {
+		return err
+	}
+	if pr.Status != domain.StatusOpen || len(pr.BlockedBy) == 0 {
+		return nil
+	}
+	author, err := s.repo.GetUser(ctx, pr.AuthorID)
+	if err != nil {
+		return err
+	}
+	settings, err := s.repo.GetTeamSettings(ctx, author.TeamName)
+	if err != nil {
+		return err
+	}
+	if settings.BlockMergeOnDependencies {
+		return domain.NewPRBlocked(pr.BlockedBy)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"net/mail"
+	"strings"
+	"time"
+
+	"Avito2025/internal/domain"
+)
+
+// SetNotificationPreference picks the channel the user hears about their own
+// pull requests on. Email needs a valid address; NotifyNone drops the
+// address. A positive digest period batches the user's notifications into
+// periodic summaries; the first one covers what happens from now on.
+func (s *ReviewerService) SetNotificationPreference(ctx context.Context, pref domain.NotificationPreference) (domain.NotificationPreference, error) {
+	if pref.Digest < 0 {
+		return domain.NotificationPreference{}, domain.ErrInvalidNotification
+	}
+	pref.DigestSentAt = s.now()
+	pref.Address = strings.TrimSpace(pref.Address)
+	switch pref.Channel {
+	case domain.NotifyNone:
+		pref.Address = ""
+	case domain.NotifyEmail:
+		addr, err := mail.ParseAddress(pref.Address)
+		if err != nil {
+			return domain.NotificationPreference{}, domain.ErrInvalidNotification
+		}
+		pref.Address = addr.Address
+	case domain.NotifySlack:
+	default:
+		return domain.NotificationPreference{}, domain.ErrInvalidNotification
+	}
+	return s.repo.SetNotificationPreference(ctx, pref)
+}
+
+func (s *ReviewerService) GetNotificationPreference(ctx context.Context, userID string) (domain.NotificationPreference, error) {
+	return s.repo.GetNotificationPreference(ctx, userID)
+}
+
+// DueDigests returns the preferences of users whose notification digest is
+// due at now.
+func (s *ReviewerService) DueDigests(ctx context.Context, now time.Time) ([]domain.NotificationPreference, error) {
+	return s.repo.ListDueDigests(ctx, now)
+}
+
+// MarkDigestSent records that the user's digest covering the events up to seq
+// was sent at now.
+func (s *ReviewerService) MarkDigestSent(ctx context.Context, userID string, seq int64, now time.Time) error {
+	return s.repo.MarkDigestSent(ctx, userID, seq, now)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"math/rand/v2"
+
+	"Avito2025/internal/domain"
+)
+
+func (s *ReviewerService) SetOwnership(ctx context.Context, ownership domain.Ownership) (domain.Ownership, error) {
+	return s.repo.SetOwnership(ctx, ownership)
+}
+
+func (s *ReviewerService) GetOwnership(ctx context.Context, teamName string) (domain.Ownership, error) {
+	if _, err := s.repo.GetTeam(ctx, teamName); err != nil {
+		return domain.Ownership{}, err
+	}
+	return s.repo.GetOwnership(ctx, teamName)
+}
+
+// pickOwners walks the team's ownership rules in order and, for every rule
+// matching a changed file that no owner picked so far satisfies, picks one of
+// the rule's members among candidates at random. Members out of their review
+// cooldown are preferred. A rule without an available member is left
+// unsatisfied, and no more than limit owners are picked.
+func (s *ReviewerService) pickOwners(ctx context.Context, random func() *rand.Rand, teamName string, files []string, fresh, cooled []domain.User, limit int) ([]string, error) {
+	if len(files) == 0 || limit <= 0 {
+		return nil, nil
+	}
+	ownership, err := s.repo.GetOwnership(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	var owners []string
+	for _, rule := range ownership.Rules {
+		if len(owners) == limit {
+			break
+		}
+		if !rule.Matches(files) || satisfiesRule(rule, owners) {
+			continue
+		}
+		for _, pool := range [][]domain.User{fresh, cooled} {
+			members := ruleMembers(rule, pool)
+			if len(members) == 0 {
+				continue
+			}
+			owners = append(owners, pickReviewers(random(), members, 1)...)
+			break
+		}
+	}
+	return owners, nil
+}
+
+func satisfiesRule(rule domain.OwnershipRule, reviewers []string) bool {
+	for _, reviewer := range reviewers {
+		if contains(rule.UserIDs, reviewer) {
+			return true
+		}
+	}
+	return false
+}
+
+func ruleMembers(rule domain.OwnershipRule, users []domain.User) []domain.User {
+	var members []domain.User
+	for _, user := range users {
+		if contains(rule.UserIDs, user.ID) {
+			members = append(members, user)
+		}
+	}
+	return members
+}
+
+// withoutUsers drops the users listed in ids.
+func withoutUsers(users []domain.User, ids []string) []domain.User {
+	rest := make([]domain.User, 0, len(users))
+	for _, user := range users {
+		if !contains(ids, user.ID) {
+			rest = append(rest, user)
+		}
+	}
+	return rest
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"Avito2025/internal/domain"
+)
+
+// ProcessPendingAssignments fills the open reviewer slots of pull requests
+// that got fewer reviewers than they need, oldest first. Slots are filled as
+// members become available: activated, back from a snooze or below the open
+// review limit. Every fill is announced with a REVIEWERS_ASSIGNED event. A pull
+// request leaves the queue once it has all its reviewers or is no longer open.
+func (s *ReviewerService) ProcessPendingAssignments(ctx context.Context, now time.Time) error {
+	pending, err := s.repo.ListPendingAssignments(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A pull request that fails stays queued for the next run and is logged,
+	// without holding back the ones after it.
+	for _, assignment := range pending {
+		err := s.atomically(ctx, func(ctx context.Context) error {
+			done, err := s.fillPending(ctx, assignment, now)
+			if err != nil || !done {
+				return err
+			}
+			return s.repo.DeletePendingAssignment(ctx, assignment.PullRequestID)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("pending assignment of %s: %v", assignment.PullRequestID, err)
+		}
+	}
+	return nil
+}
+
+// fillPending picks reviewers for the open slots of a queued pull request from
+// its author's team. It reports whether the pull request needs no more
+// reviewers. The picks are only added to the reviewers the pull request has
+// when they are stored, so a reassignment made since it was read stands.
+func (s *ReviewerService) fillPending(ctx context.Context, assignment domain.PendingAssignment, now time.Time) (bool, error) {
+	pr, err := s.repo.GetPullRequest(ctx, assignment.PullRequestID)
+	if errors.Is(err, domain.ErrPullRequestNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if pr.Status != domain.StatusOpen {
+		return true, nil
+	}
+
+	author, err := s.repo.GetUser(ctx, pr.AuthorID)
+	if err != nil {
+		return false, err
+	}
+	settings, err := s.repo.GetTeamSettings(ctx, author.TeamName)
+	if err != nil {
+		return false, err
+	}
+	required, err := requiredReviewers(settings, pr)
+	if err != nil {
+		// The team lowered its required reviewers below the requested count.
+		required = settings.RequiredReviewers
+	}
+	missing := required - len(pr.AssignedReviewers)
+	if missing <= 0 {
+		return true, nil
+	}
+
+	members, err := s.repo.ListUsersByTeam(ctx, author.TeamName)
+	if err != nil {
+		return false, err
+	}
+	candidates, err := s.withinReviewLimit(ctx, settings, filterForReplacement(members, pr.Authors(), pr.AssignedReviewers, now))
+	if err != nil {
+		return false, err
+	}
+	if len(candidates) == 0 {
+		return false, nil
+	}
+
+	seed, rnd := s.seededRand()
+	picked := pickReviewers(rnd, candidates, missing)
+	decision := domain.AssignmentDecision{
+		PullRequestID: pr.ID,
+		Reason:        domain.ReasonPending,
+		TeamName:      author.TeamName,
+		Strategy:      string(domain.StrategyRandom),
+		Seed:          seed,
+		Filters:       appliedFilters(settings, authorFilters(pr, domain.FilterInactive, domain.FilterSnoozed, domain.FilterAlreadyAssigned)...),
+		Candidates: snapshotCandidates(members, candidates, func(user domain.User) string {
+			switch {
+			case user.ID == pr.AuthorID:
+				return domain.FilterAuthor
+			case contains(pr.CoAuthors, user.ID):
+				return domain.FilterCoAuthor
+			case !user.IsActive:
+				return domain.FilterInactive
+			case user.Snoozed(now):
+				return domain.FilterSnoozed
+			case contains(pr.AssignedReviewers, user.ID):
+				return domain.FilterAlreadyAssigned
+			}
+			return ""
+		}),
+		Selected: picked,
+	}
+
+	updated, added, err := s.repo.AddReviewers(ctx, pr.ID, picked, required)
+	if err != nil {
+		return false, err
+	}
+	if updated.Status != domain.StatusOpen {
+		return true, nil
+	}
+	if len(added) == 0 {
+		return len(updated.AssignedReviewers) >= required, nil
+	}
+	decision.Selected = added
+
+	if err := s.recordReviewerChanges(ctx, &updated, nil, added, domain.ReasonPending); err != nil {
+		return false, err
+	}
+	if err := s.repo.AppendAssignmentDecision(ctx, decision); err != nil {
+		return false, err
+	}
+	if err := s.recordPullRequestEvent(ctx, domain.EventReviewersAssigned, updated, map[string]any{
+		"assigned_reviewers": updated.AssignedReviewers,
+		"added":              added,
+		"pending":            true,
+	}); err != nil {
+		return false, err
+	}
+	return len(updated.AssignedReviewers) >= required, nil
+}
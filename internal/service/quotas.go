@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"Avito2025/internal/domain"
+	"Avito2025/internal/warnings"
+)
+
+// Quota names as reported in errors and warnings.
+const (
+	quotaTeamMembers      = "team_members"
+	quotaOpenPullRequests = "open_pull_requests"
+	quotaReviewers        = "reviewers"
+)
+
+// checkQuota vets count, the value the request would leave behind, against
+// quota: over the hard limit the request fails, over the soft limit it goes
+// on with a warning.
+func checkQuota(ctx context.Context, name string, quota domain.Quota, count int) error {
+	if quota.Hard > 0 && count > quota.Hard {
+		return domain.NewQuotaExceeded(name, quota.Hard, count)
+	}
+	if quota.Soft > 0 && count > quota.Soft {
+		warnings.Add(ctx, domain.Warning{
+			Code:    "SOFT_QUOTA_EXCEEDED",
+			Message: fmt.Sprintf("%s soft quota exceeded: %d is over the limit of %d", name, count, quota.Soft),
+			Details: map[string]any{"quota": name, "limit": quota.Soft, "count": count},
+		})
+	}
+	return nil
+}
+
+// checkTeamSize vets a team growing to count members.
+func (s *ReviewerService) checkTeamSize(ctx context.Context, count int) error {
+	return checkQuota(ctx, quotaTeamMembers, s.quotas.TeamMembers, count)
+}
+
+// checkMergedTeamSize vets merging the source team into the target.
+func (s *ReviewerService) checkMergedTeamSize(ctx context.Context, source, target string) error {
+	if s.quotas.TeamMembers == (domain.Quota{}) {
+		return nil
+	}
+	count := 0
+	for _, name := range []string{source, target} {
+		team, err := s.repo.GetTeam(ctx, name)
+		if err != nil {
+			return err
+		}
+		count += len(team.Members)
+	}
+	return s.checkTeamSize(ctx, count)
+}
+
+// checkReviewerCount vets a pull request getting count reviewers.
+func (s *ReviewerService) checkReviewerCount(ctx context.Context, count int) error {
+	return checkQuota(ctx, quotaReviewers, s.quotas.Reviewers, count)
+}
+
+// checkOpenPullRequests vets the author opening one more pull request.
+func (s *ReviewerService) checkOpenPullRequests(ctx context.Context, authorID string) error {
+	quota := s.quotas.OpenPullRequests
+	if quota.Soft == 0 && quota.Hard == 0 {
+		return nil
+	}
+	open, err := s.repo.CountOpenPullRequests(ctx, authorID)
+	if err != nil {
+		return err
+	}
+	return checkQuota(ctx, quotaOpenPullRequests, quota, open+1)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"Avito2025/internal/domain"
+)
+
+// ProcessReminders advances the reminder policy of every open pull request.
+// Depending on its age and the team's settings a PR is reminded about
+// (reviewers are notified), escalated (the team is notified) or has its
+// reviewers reassigned. Each step is taken at most once per PR; when a PR is
+// already past several thresholds only the latest step is taken. Notifications
+// are published as events for the delivery subsystems to pick up. Until the
+// deadline granted by an approved extension a PR is at most reminded about.
+func (s *ReviewerService) ProcessReminders(ctx context.Context, now time.Time) error {
+	candidates, err := s.repo.ListReminderCandidates(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	// A pull request that fails is logged and tried again on the next run,
+	// without holding back the ones after it.
+	settingsByTeam := make(map[string]domain.TeamSettings)
+	for _, candidate := range candidates {
+		if err := s.processReminder(ctx, candidate, settingsByTeam, now); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("reminder for %s: %v", candidate.PullRequestID, err)
+		}
+	}
+	return nil
+}
+
+// processReminder takes the reminder step due for the candidate, if any,
+// caching the team settings it reads in settingsByTeam.
+func (s *ReviewerService) processReminder(ctx context.Context, candidate domain.ReminderCandidate, settingsByTeam map[string]domain.TeamSettings, now time.Time) error {
+	settings, ok := settingsByTeam[candidate.TeamName]
+	if !ok {
+		var err error
+		settings, err = s.repo.GetTeamSettings(ctx, candidate.TeamName)
+		if err != nil {
+			return err
+		}
+		settingsByTeam[candidate.TeamName] = settings
+	}
+
+	stage := reminderStage(settings, now.Sub(candidate.CreatedAt))
+	if candidate.Deadline != nil && now.Before(*candidate.Deadline) && stage > domain.ReminderSent {
+		stage = domain.ReminderSent
+	}
+	if stage <= candidate.Stage {
+		return nil
+	}
+	return s.atomically(ctx, func(ctx context.Context) error {
+		if err := s.applyReminder(ctx, candidate, stage, now); err != nil {
+			return err
+		}
+		return s.repo.SetReminderStage(ctx, candidate.PullRequestID, stage)
+	})
+}
+
+func (s *ReviewerService) applyReminder(ctx context.Context, candidate domain.ReminderCandidate, stage domain.ReminderStage, now time.Time) error {
+	pr, err := s.repo.GetPullRequest(ctx, candidate.PullRequestID)
+	if err != nil {
+		return err
+	}
+
+	switch stage {
+	case domain.ReminderSent:
+		return s.recordEvent(ctx, domain.EventReviewReminder, candidate.TeamName, pr.ID, map[string]any{
+			"assigned_reviewers": pr.AssignedReviewers,
+			"open_for_seconds":   int64(now.Sub(pr.CreatedAt).Seconds()),
+		})
+	case domain.ReminderEscalated:
+		return s.recordEvent(ctx, domain.EventReviewEscalated, candidate.TeamName, pr.ID, map[string]any{
+			"assigned_reviewers": pr.AssignedReviewers,
+			"open_for_seconds":   int64(now.Sub(pr.CreatedAt).Seconds()),
+		})
+	case domain.ReminderReassigned:
+		for _, reviewer := range pr.AssignedReviewers {
+			_, _, err := s.ReassignReviewer(ctx, pr.ID, reviewer)
+			if err != nil && !errors.Is(err, domain.ErrNoReplacement) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reminderStage returns the furthest enabled step whose threshold has passed.
+func reminderStage(settings domain.TeamSettings, age time.Duration) domain.ReminderStage {
+	switch {
+	case settings.ReassignAfter > 0 && age >= settings.ReassignAfter:
+		return domain.ReminderReassigned
+	case settings.EscalateAfter > 0 && age >= settings.EscalateAfter:
+		return domain.ReminderEscalated
+	case settings.RemindAfter > 0 && age >= settings.RemindAfter:
+		return domain.ReminderSent
+	default:
+		return domain.ReminderNone
+	}
+}
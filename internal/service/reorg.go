@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+
+	"Avito2025/internal/domain"
+)
+
+// MergeTeams folds source into target: every member of source joins target,
+// users whose primary team was source move to target, and pull requests
+// waiting for working hours are assigned from target's pool. The source team
+// and its settings are removed. Open reviews are left as they are; later
+// reassignments already draw from the merged roster.
+func (s *ReviewerService) MergeTeams(ctx context.Context, source, target string) (domain.Team, error) {
+	if source == target {
+		return domain.Team{}, domain.ErrInvalidTeamMerge
+	}
+	if err := s.checkMergedTeamSize(ctx, source, target); err != nil {
+		return domain.Team{}, err
+	}
+
+	var merged domain.Team
+	err := s.atomically(ctx, func(ctx context.Context) error {
+		var err error
+		merged, err = s.repo.MergeTeams(ctx, source, target)
+		if err != nil {
+			return err
+		}
+
+		members := make([]string, 0, len(merged.Members))
+		for _, member := range merged.Members {
+			members = append(members, member.ID)
+		}
+		return s.recordEvent(ctx, domain.EventTeamMerged, merged.Name, merged.Name, map[string]any{
+			"source_team": source,
+			"members":     members,
+		})
+	})
+	if err != nil {
+		return domain.Team{}, err
+	}
+	return merged, nil
+}
+
+// SplitTeam moves the selected members of a team into a new team that starts
+// with the source team's settings. It returns the source team as it is after
+// the split and the new team.
+func (s *ReviewerService) SplitTeam(ctx context.Context, split domain.TeamSplit) (domain.Team, domain.Team, error) {
+	if len(split.UserIDs) == 0 {
+		return domain.Team{}, domain.Team{}, domain.ErrInvalidTeamSplit
+	}
+	seen := make(map[string]bool, len(split.UserIDs))
+	for _, userID := range split.UserIDs {
+		if seen[userID] {
+			return domain.Team{}, domain.Team{}, domain.ErrInvalidTeamSplit
+		}
+		seen[userID] = true
+	}
+
+	var created, remaining domain.Team
+	err := s.atomically(ctx, func(ctx context.Context) error {
+		var err error
+		created, err = s.repo.SplitTeam(ctx, split)
+		if err != nil {
+			return err
+		}
+		remaining, err = s.repo.GetTeam(ctx, split.SourceTeam)
+		if err != nil {
+			return err
+		}
+
+		return s.recordEvent(ctx, domain.EventTeamSplit, remaining.Name, created.Name, map[string]any{
+			"new_team": created.Name,
+			"members":  split.UserIDs,
+		})
+	})
+	if err != nil {
+		return domain.Team{}, domain.Team{}, err
+	}
+	return remaining, created, nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"Avito2025/internal/domain"
+)
+
+// RecordReview registers review activity by an assigned reviewer. Only the
+// first activity of each kind is kept; the returned flag reports whether this
+// was the first review activity on the pull request at all. Reviews by the
+// shadow reviewer are announced but do not count as review activity.
+func (s *ReviewerService) RecordReview(ctx context.Context, prID, reviewerID string, kind domain.ReviewKind) (bool, error) {
+	pr, err := s.repo.GetPullRequest(ctx, prID)
+	if err != nil {
+		return false, err
+	}
+	if pr.Status == domain.StatusMerged {
+		return false, domain.ErrPRMerged
+	}
+	if !contains(pr.AssignedReviewers, reviewerID) {
+		if pr.ShadowReviewer == "" || pr.ShadowReviewer != reviewerID {
+			return false, domain.ErrReviewerNotFound
+		}
+		return false, s.recordPullRequestEvent(ctx, domain.EventReviewSubmitted, pr, map[string]any{
+			"reviewer_id": reviewerID,
+			"role":        domain.RoleShadow,
+			"kind":        kind,
+			"first":       false,
+		})
+	}
+
+	now := s.now()
+	var first bool
+	err = s.atomically(ctx, func(ctx context.Context) error {
+		var err error
+		first, err = s.repo.RecordReview(ctx, prID, kind, now)
+		if err != nil {
+			return err
+		}
+
+		payload := map[string]any{
+			"reviewer_id": reviewerID,
+			"role":        pr.RoleOf(reviewerID),
+			"kind":        kind,
+			"first":       first,
+		}
+		if first {
+			payload["time_to_review_seconds"] = now.Sub(pr.CreatedAt).Seconds()
+		}
+		return s.recordPullRequestEvent(ctx, domain.EventReviewSubmitted, pr, payload)
+	})
+	if err != nil {
+		return false, err
+	}
+	return first, nil
+}
+
+// CompleteReview marks the assigned reviewer done with the review of an open
+// pull request, taking it off their to-do list. Unlike an approval it says
+// nothing about the verdict. Completing a review twice is not an error.
+func (s *ReviewerService) CompleteReview(ctx context.Context, prID, reviewerID string) (domain.PullRequest, error) {
+	pr, err := s.repo.GetPullRequest(ctx, prID)
+	if err != nil {
+		return domain.PullRequest{}, err
+	}
+	if pr.Status == domain.StatusMerged {
+		return domain.PullRequest{}, domain.ErrPRMerged
+	}
+	if !contains(pr.AssignedReviewers, reviewerID) {
+		return domain.PullRequest{}, domain.ErrReviewerNotFound
+	}
+
+	err = s.atomically(ctx, func(ctx context.Context) error {
+		completed, err := s.repo.CompleteReview(ctx, prID, reviewerID, s.now())
+		if err != nil || !completed {
+			return err
+		}
+
+		pr.CompletedReviewers = append(pr.CompletedReviewers, reviewerID)
+		sort.Strings(pr.CompletedReviewers)
+		return s.recordPullRequestEvent(ctx, domain.EventReviewCompleted, pr, map[string]any{
+			"reviewer_id": reviewerID,
+		})
+	})
+	if err != nil {
+		return domain.PullRequest{}, err
+	}
+	return pr, nil
+}
+
+// TimeToReviewReport aggregates the delay between PR creation and first review
+// for the team's PRs created within the period, bucketed by ISO week.
+func (s *ReviewerService) TimeToReviewReport(ctx context.Context, teamName string, period time.Duration) (domain.TimeToReviewReport, error) {
+	since := s.now().Add(-period)
+
+	latencies, err := s.repo.ListReviewLatencies(ctx, teamName, since)
+	if err != nil {
+		return domain.TimeToReviewReport{}, err
+	}
+
+	perWeek := make(map[time.Time][]time.Duration)
+	for _, latency := range latencies {
+		week := domain.WeekStart(latency.CreatedAt)
+		perWeek[week] = append(perWeek[week], latency.FirstReviewAt.Sub(latency.CreatedAt))
+	}
+
+	report := domain.TimeToReviewReport{
+		TeamName: teamName,
+		Since:    since,
+		Weeks:    make([]domain.TimeToReviewWeek, 0, len(perWeek)),
+	}
+	for start, durations := range perWeek {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		report.Weeks = append(report.Weeks, domain.TimeToReviewWeek{
+			Start:  start,
+			Count:  len(durations),
+			Median: percentile(durations, 50),
+			P90:    percentile(durations, 90),
+		})
+	}
+	sort.Slice(report.Weeks, func(i, j int) bool {
+		return report.Weeks[i].Start.Before(report.Weeks[j].Start)
+	})
+	return report, nil
+}
+
+// percentile uses the nearest-rank method on sorted, non-empty input.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"Avito2025/internal/domain"
+)
+
+func (s *ReviewerService) SetRotation(ctx context.Context, rotation domain.Rotation) (domain.Rotation, error) {
+	return s.repo.SetRotation(ctx, rotation)
+}
+
+func (s *ReviewerService) GetRotation(ctx context.Context, teamName string) (domain.Rotation, error) {
+	if _, err := s.repo.GetTeam(ctx, teamName); err != nil {
+		return domain.Rotation{}, err
+	}
+	return s.repo.GetRotation(ctx, teamName)
+}
+
+// pickFromRotation walks the team's rotation from the stored position and takes
+// the next limit members that are among candidates, i.e. active and not the
+// author. The position is persisted just past the last member picked so the
+// order survives restarts. It only advances from the position the pick started
+// at, so that concurrent picks never hand out the same members: the one that
+// loses the race picks again from where the winner left the rotation.
+// ErrRotationNotFound is returned when the team has no rotation configured.
+func (s *ReviewerService) pickFromRotation(ctx context.Context, teamName string, candidates []domain.User, limit int) ([]string, error) {
+	for attempt := 1; ; attempt++ {
+		rotation, err := s.repo.GetRotation(ctx, teamName)
+		if err != nil {
+			return nil, err
+		}
+
+		picked, next := nextInRotation(rotation, candidates, limit)
+		if len(picked) == 0 {
+			return nil, nil
+		}
+
+		err = s.repo.AdvanceRotationPosition(ctx, teamName, rotation.Position, next)
+		if errors.Is(err, domain.ErrRotationConflict) && attempt < maxRotationAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return picked, nil
+	}
+}
+
+func nextInRotation(rotation domain.Rotation, candidates []domain.User, limit int) ([]string, int) {
+	size := len(rotation.UserIDs)
+	if size == 0 || limit <= 0 {
+		return nil, rotation.Position
+	}
+
+	eligible := make(map[string]bool, len(candidates))
+	for _, candidate := range candidates {
+		eligible[candidate.ID] = true
+	}
+
+	start := rotation.Position % size
+	next := start
+	picked := make([]string, 0, limit)
+	for i := 0; i < size && len(picked) < limit; i++ {
+		idx := (start + i) % size
+		if !eligible[rotation.UserIDs[idx]] {
+			continue
+		}
+		picked = append(picked, rotation.UserIDs[idx])
+		next = (idx + 1) % size
+	}
+	return picked, next
+}
@@ -2,144 +2,1965 @@ package service
 
 import (
 	"context"
-	"math/rand"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net/url"
+	"strings"
 	"time"
 
+	"Avito2025/internal/directory"
 	"Avito2025/internal/domain"
+	"Avito2025/internal/eventbus"
 	"Avito2025/internal/storage"
 )
 
 type Service interface {
-	CreateTeam(ctx context.Context, team domain.Team) (domain.Team, error)
+	CreateTeam(ctx context.Context, team domain.Team, conflict domain.MemberConflict) (domain.Team, []domain.ReviewHandover, error)
 	GetTeam(ctx context.Context, name string) (domain.Team, error)
+	ListTeams(ctx context.Context, prefix string, offset, limit int) ([]domain.TeamSummary, error)
+	GetTeamSettings(ctx context.Context, teamName string) (domain.TeamSettings, error)
+	UpdateTeamSettings(ctx context.Context, settings domain.TeamSettings) (domain.TeamSettings, error)
+	ListUsers(ctx context.Context, filter domain.UserFilter, offset, limit int) ([]domain.UserSummary, error)
 	SetUserActive(ctx context.Context, userID string, isActive bool) (domain.User, error)
+	BulkSetUserActive(ctx context.Context, userIDs []string, isActive, reassignReviews bool) ([]domain.UserResult, error)
+	SnoozeUser(ctx context.Context, userID string, duration time.Duration) (domain.User, error)
+	UpdateUser(ctx context.Context, userID string, update domain.UserUpdate, reassignReviews bool) (domain.User, []domain.ReviewHandover, error)
+	TeamHistory(ctx context.Context, teamName string) ([]domain.MembershipChange, error)
+	AssignmentHistory(ctx context.Context, filter domain.AssignmentHistoryFilter, offset, limit int) ([]domain.ReviewerChange, error)
+	MergeTeams(ctx context.Context, source, target string) (domain.Team, error)
+	SplitTeam(ctx context.Context, split domain.TeamSplit) (domain.Team, domain.Team, error)
 
+	SetRotation(ctx context.Context, rotation domain.Rotation) (domain.Rotation, error)
+	GetRotation(ctx context.Context, teamName string) (domain.Rotation, error)
+	SetOwnership(ctx context.Context, ownership domain.Ownership) (domain.Ownership, error)
+	GetOwnership(ctx context.Context, teamName string) (domain.Ownership, error)
+	SetTeamLead(ctx context.Context, teamName, userID string, isLead bool) ([]string, error)
+	ListTeamLeads(ctx context.Context, teamName string) ([]string, error)
+	AuthorizeTeamLead(ctx context.Context, callerID, teamName string) error
+	AuthorizePullRequestLead(ctx context.Context, callerID, prID string) error
+	AuthorizeExtensionLead(ctx context.Context, callerID string, extensionID int64) error
+
+	SetNotificationPreference(ctx context.Context, pref domain.NotificationPreference) (domain.NotificationPreference, error)
+	GetNotificationPreference(ctx context.Context, userID string) (domain.NotificationPreference, error)
+	DueDigests(ctx context.Context, now time.Time) ([]domain.NotificationPreference, error)
+	MarkDigestSent(ctx context.Context, userID string, seq int64, now time.Time) error
+
+	AddIdentity(ctx context.Context, identity domain.Identity) (domain.Identity, error)
+	ListIdentities(ctx context.Context, userID string) ([]domain.Identity, error)
+	ResolveIdentity(ctx context.Context, provider domain.IdentityProvider, externalID string) (domain.User, error)
+
+	GetPullRequest(ctx context.Context, prID string) (domain.PullRequest, error)
 	CreatePullRequest(ctx context.Context, pr domain.PullRequest) (domain.PullRequest, error)
+	BulkCreatePullRequests(ctx context.Context, prs []domain.PullRequest) ([]domain.PullRequestResult, error)
+	UpdatePullRequest(ctx context.Context, prID string, update domain.PullRequestUpdate, allowMerged bool) (domain.PullRequest, error)
 	MergePullRequest(ctx context.Context, prID string) (domain.PullRequest, error)
+	ForceMergePullRequest(ctx context.Context, prID, actor, reason string) (domain.PullRequest, error)
+	BulkMergePullRequests(ctx context.Context, prIDs []string) ([]domain.PullRequestResult, error)
+	MarkPullRequestReady(ctx context.Context, prID string) (domain.PullRequest, error)
 	ReassignReviewer(ctx context.Context, prID, oldReviewerID string) (domain.PullRequest, string, error)
-	ListUserReviews(ctx context.Context, userID string) ([]domain.PullRequest, error)
+	AssignReviewers(ctx context.Context, prID string, reviewerIDs []string) (domain.PullRequest, error)
+	AddReviewer(ctx context.Context, prID, reviewerID string) (domain.PullRequest, string, error)
+	RecordReview(ctx context.Context, prID, reviewerID string, kind domain.ReviewKind) (bool, error)
+	AcceptReview(ctx context.Context, prID, reviewerID string) (domain.PullRequest, error)
+	CompleteReview(ctx context.Context, prID, reviewerID string) (domain.PullRequest, error)
+	LinkPullRequests(ctx context.Context, prID, blockedBy string) (domain.PullRequest, error)
+	UnlinkPullRequests(ctx context.Context, prID, blockedBy string) (domain.PullRequest, error)
+	RequestDeadlineExtension(ctx context.Context, prID, reviewerID string, until time.Time, reason string) (domain.DeadlineExtension, error)
+	DecideDeadlineExtension(ctx context.Context, id int64, approve bool, deciderID string) (domain.DeadlineExtension, error)
+	GetDeadlineExtension(ctx context.Context, id int64) (domain.DeadlineExtension, error)
+	ListDeadlineExtensions(ctx context.Context, prID string) ([]domain.DeadlineExtension, error)
+	ListUserReviews(ctx context.Context, userID string, filter domain.ReviewFilter) ([]domain.PullRequest, error)
+	WaitUserReviews(ctx context.Context, userID string, unchanged func([]domain.PullRequest) bool) ([]domain.PullRequest, error)
+	ListChanges(ctx context.Context, since int64, limit int) ([]domain.Event, error)
+	ListLatestChanges(ctx context.Context, limit int) ([]domain.Event, error)
+	PruneEvents(ctx context.Context, throughSeq int64) (int, error)
+	CheckIntegrity(ctx context.Context, fix bool) (domain.IntegrityReport, error)
+	CheckOrphans(ctx context.Context, fix bool) (domain.IntegrityReport, error)
+	ExportSnapshot(ctx context.Context) (domain.Snapshot, error)
+	ImportSnapshot(ctx context.Context, snapshot domain.Snapshot, dryRun bool) (domain.ImportResult, error)
+	SubscribeEvents(teamNames []string) (<-chan domain.Event, func())
+	FairnessReport(ctx context.Context, teamName string, period time.Duration) (domain.FairnessReport, error)
+	TimeToReviewReport(ctx context.Context, teamName string, period time.Duration) (domain.TimeToReviewReport, error)
+	TeamStats(ctx context.Context, teamName string, period time.Duration) (domain.TeamStats, error)
+	AcceptanceReport(ctx context.Context, teamName string, period time.Duration) (domain.AcceptanceReport, error)
+	LoadForecast(ctx context.Context, teamName string, period time.Duration) (domain.LoadForecast, error)
+	BuildDailyStats(ctx context.Context, now time.Time) error
+	DailyStats(ctx context.Context, teamName string, from, to time.Time) ([]domain.DailyReviewStats, error)
+	ListUnderassigned(ctx context.Context, teamName string) ([]domain.UnderassignedPullRequest, error)
+	ListHotPullRequests(ctx context.Context, teamName string, minReassignments int) ([]domain.HotPullRequest, error)
+	AssignmentTrace(ctx context.Context, prID string) ([]domain.AssignmentDecision, error)
+	CreateWebhook(ctx context.Context, webhook domain.Webhook) (domain.Webhook, error)
+	GetWebhook(ctx context.Context, id int64) (domain.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]domain.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook domain.Webhook) (domain.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	ListWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]domain.WebhookDelivery, error)
+	RecordWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) error
+	CreateTeamToken(ctx context.Context, teamName, name, userID string) (domain.TeamToken, error)
+	ListTeamTokens(ctx context.Context, teamName string) ([]domain.TeamToken, error)
+	DeleteTeamToken(ctx context.Context, id int64) error
+	AuthorizeTeamToken(ctx context.Context, tokenHash string, prs []domain.PullRequest) error
+	TeamTokenUser(ctx context.Context, tokenHash string) (string, error)
+	Maintenance(ctx context.Context) (domain.Maintenance, error)
+	SetMaintenance(ctx context.Context, enabled bool) (domain.Maintenance, error)
+	Reset(ctx context.Context) error
+	ProcessReminders(ctx context.Context, now time.Time) error
+	ProcessDeferredAssignments(ctx context.Context, now time.Time) error
+	ProcessPendingAssignments(ctx context.Context, now time.Time) error
+	ProcessExpiredAcceptances(ctx context.Context, now time.Time) error
+	ArchivePullRequests(ctx context.Context, mergedBefore time.Time) (int, error)
+	PoolStats() (storage.PoolStats, bool)
+	MigrationStatus(ctx context.Context) ([]storage.MigrationStatus, bool, error)
 	Health(ctx context.Context) error
 }
 
-type ReviewerService struct {
-	repo storage.Repository
-	rnd  *rand.Rand
+const (
+	// subscriberBuffer is the number of live events buffered per subscriber.
+	subscriberBuffer = 64
+	// maxRequiredReviewers bounds the per-team required_reviewers setting.
+	maxRequiredReviewers = 10
+	// maxLabels bounds the number of labels on a pull request.
+	maxLabels = 20
+	// maxDescriptionBytes bounds the description of a pull request.
+	maxDescriptionBytes = 64 << 10
+	// maxReviewCooldown bounds the per-team review_cooldown setting.
+	maxReviewCooldown = 7 * 24 * time.Hour
+	// maxAcceptTimeout bounds the per-team accept_timeout setting.
+	maxAcceptTimeout = 7 * 24 * time.Hour
+	// maxExtension bounds how far ahead a deadline extension may reach, and
+	// so the per-team auto_approve_extension setting.
+	maxExtension = 30 * 24 * time.Hour
+	// maxExtensionReasonBytes bounds the reason given for an extension.
+	maxExtensionReasonBytes = 1 << 10
+	// maxReassignAttempts bounds how often a reassignment picks again after
+	// losing a race with a concurrent change of the pull request.
+	maxReassignAttempts = 3
+	// maxRotationAttempts bounds how often a pick from a team's rotation
+	// starts over after a concurrent pick advanced the rotation first.
+	maxRotationAttempts = 5
+)
+
+type ReviewerService struct {
+	repo storage.Repository
+	// seeds draws the seed of every selection. Handlers pick reviewers
+	// concurrently, so its source is locked.
+	seeds *rand.Rand
+	bus   *eventbus.Bus
+	dir   directory.Directory
+	// deferOffHours postpones automatic assignment outside working hours.
+	deferOffHours bool
+	// botTeams maps authors unknown to the service to the team reviewing
+	// their pull requests.
+	botTeams map[string]string
+	// quotas limit team sizes, open pull requests and reviewers.
+	quotas domain.Quotas
+	clock  Clock
+	ids    IDGenerator
+}
+
+// Option configures a ReviewerService at construction.
+type Option func(*ReviewerService)
+
+// WithRand seeds reviewer selection with seed instead of a random one, so that
+// the same calls in the same order pick the same reviewers. Meant for tests
+// and for reproducing an incident.
+func WithRand(seed int64) Option {
+	return func(s *ReviewerService) {
+		s.seeds = newSeeds(uint64(seed))
+	}
+}
+
+func New(repo storage.Repository, opts ...Option) *ReviewerService {
+	s := &ReviewerService{
+		repo:  repo,
+		seeds: newSeeds(rand.Uint64()),
+		bus:   eventbus.New(),
+		dir:   directory.Nop{},
+		clock: ClockFunc(time.Now),
+		ids:   randomIDs(webhookSecretBytes),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SetDirectory makes CreateTeam check member IDs against dir.
+func (s *ReviewerService) SetDirectory(dir directory.Directory) {
+	s.dir = dir
+}
+
+// SetDeferOffHours makes pull requests created outside their team's working
+// hours wait for ProcessDeferredAssignments to pick their reviewers.
+func (s *ReviewerService) SetDeferOffHours(enabled bool) {
+	s.deferOffHours = enabled
+}
+
+// SetBotTeams makes pull requests of the unknown authors in teams, such as
+// bots, reviewed by the team they map to.
+func (s *ReviewerService) SetBotTeams(teams map[string]string) {
+	s.botTeams = teams
+}
+
+// SetQuotas makes the service refuse requests going over the hard limits of
+// quotas and warn about those going over the soft ones.
+func (s *ReviewerService) SetQuotas(quotas domain.Quotas) {
+	s.quotas = quotas
+}
+
+// CreateTeam creates a team with its members. Members who already belong to
+// another team are handled according to conflict; with ConflictTransfer the
+// returned handovers list the open reviews they gave up.
+func (s *ReviewerService) CreateTeam(ctx context.Context, team domain.Team, conflict domain.MemberConflict) (domain.Team, []domain.ReviewHandover, error) {
+	if err := s.verifyMembers(ctx, team.Members); err != nil {
+		return domain.Team{}, nil, err
+	}
+
+	// An existing team is reported as such before its members are looked at,
+	// so that seeding the same team again stays harmless.
+	_, err := s.repo.GetTeam(ctx, team.Name)
+	if err == nil {
+		return domain.Team{}, nil, domain.ErrTeamExists
+	}
+	if !errors.Is(err, domain.ErrTeamNotFound) {
+		return domain.Team{}, nil, err
+	}
+	existing, err := s.existingMembers(ctx, team.Members)
+	if err != nil {
+		return domain.Team{}, nil, err
+	}
+	if len(existing) > 0 && conflict == domain.ConflictReject {
+		return domain.Team{}, nil, domain.ErrUserInOtherTeam
+	}
+	if err := s.checkTeamSize(ctx, len(team.Members)); err != nil {
+		return domain.Team{}, nil, err
+	}
+
+	var (
+		created   domain.Team
+		handovers []domain.ReviewHandover
+	)
+	err = s.atomically(ctx, func(ctx context.Context) error {
+		var err error
+		created, err = s.repo.CreateTeam(ctx, team)
+		if err != nil {
+			return err
+		}
+
+		members := make([]string, 0, len(created.Members))
+		joined := make([]domain.MembershipChange, 0, len(created.Members))
+		for _, member := range created.Members {
+			members = append(members, member.ID)
+			joined = append(joined, domain.MembershipChange{
+				TeamName: created.Name,
+				UserID:   member.ID,
+				Kind:     domain.MembershipJoined,
+				IsActive: member.IsActive,
+			})
+		}
+		if err := s.repo.AppendMembershipChanges(ctx, joined); err != nil {
+			return err
+		}
+
+		payload := map[string]any{"members": members}
+		if conflict == domain.ConflictTransfer && len(existing) > 0 {
+			transferred := make([]string, 0, len(existing))
+			for _, user := range existing {
+				moved, err := s.transferMember(ctx, user, created.Name)
+				if err != nil {
+					return err
+				}
+				handovers = append(handovers, moved...)
+				transferred = append(transferred, user.ID)
+			}
+			if created, err = s.repo.GetTeam(ctx, created.Name); err != nil {
+				return err
+			}
+			payload["transferred"] = transferred
+		}
+
+		return s.recordEvent(ctx, domain.EventTeamCreated, created.Name, created.Name, payload)
+	})
+	if err != nil {
+		return domain.Team{}, nil, err
+	}
+
+	return created, handovers, nil
+}
+
+// existingMembers returns the members that are already users, i.e. belong to
+// some team.
+func (s *ReviewerService) existingMembers(ctx context.Context, members []domain.User) ([]domain.User, error) {
+	var existing []domain.User
+	for _, member := range members {
+		user, err := s.repo.GetUser(ctx, member.ID)
+		if errors.Is(err, domain.ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		existing = append(existing, user)
+	}
+	return existing, nil
+}
+
+// transferMember makes teamName, which the user has just joined, the user's
+// primary team. The open reviews the user holds for the old primary team are
+// handed over to its other members first.
+func (s *ReviewerService) transferMember(ctx context.Context, user domain.User, teamName string) ([]domain.ReviewHandover, error) {
+	handovers, err := s.handOverReviews(ctx, user.ID, user.TeamName)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.repo.UpdateUser(ctx, user.ID, domain.UserUpdate{TeamName: &teamName})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.AppendMembershipChanges(ctx, []domain.MembershipChange{
+		{TeamName: user.TeamName, UserID: updated.ID, Kind: domain.MembershipLeft, IsActive: updated.IsActive},
+	}); err != nil {
+		return nil, err
+	}
+	return handovers, nil
+}
+
+// verifyMembers rejects members unknown to the directory. The check is soft:
+// when the directory cannot be reached the team is accepted as is.
+func (s *ReviewerService) verifyMembers(ctx context.Context, members []domain.User) error {
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.ID)
+	}
+
+	missing, err := s.dir.Missing(ctx, ids)
+	if err != nil {
+		log.Printf("directory lookup failed, skipping member check: %v", err)
+		return nil
+	}
+	if len(missing) > 0 {
+		return domain.ErrUnknownUser
+	}
+	return nil
+}
+
+func (s *ReviewerService) GetTeam(ctx context.Context, name string) (domain.Team, error) {
+	return s.repo.GetTeam(ctx, name)
+}
+
+// ListTeams returns a page of teams whose name starts with prefix, ordered by
+// name, with their member and open pull request counts.
+func (s *ReviewerService) ListTeams(ctx context.Context, prefix string, offset, limit int) ([]domain.TeamSummary, error) {
+	return s.repo.ListTeams(ctx, prefix, offset, limit)
+}
+
+// ListUsers returns a page of the users matching filter, ordered by ID, with
+// their open review counts. Filtering by a team that does not exist fails
+// with ErrTeamNotFound rather than listing nobody.
+func (s *ReviewerService) ListUsers(ctx context.Context, filter domain.UserFilter, offset, limit int) ([]domain.UserSummary, error) {
+	if filter.TeamName != "" {
+		if _, err := s.repo.GetTeam(ctx, filter.TeamName); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.ListUsers(ctx, filter, offset, limit)
+}
+
+func (s *ReviewerService) GetTeamSettings(ctx context.Context, teamName string) (domain.TeamSettings, error) {
+	return s.repo.GetTeamSettings(ctx, teamName)
+}
+
+func (s *ReviewerService) UpdateTeamSettings(ctx context.Context, settings domain.TeamSettings) (domain.TeamSettings, error) {
+	if settings.RequiredReviewers < 1 || settings.RequiredReviewers > maxRequiredReviewers {
+		return domain.TeamSettings{}, domain.ErrInvalidSettings
+	}
+	if settings.RemindAfter < 0 || settings.EscalateAfter < 0 || settings.ReassignAfter < 0 {
+		return domain.TeamSettings{}, domain.ErrInvalidSettings
+	}
+	if settings.MaxOpenReviews < 0 || settings.ReviewCooldown < 0 || settings.ReviewCooldown > maxReviewCooldown {
+		return domain.TeamSettings{}, domain.ErrInvalidSettings
+	}
+	if settings.AcceptTimeout < 0 || settings.AcceptTimeout > maxAcceptTimeout {
+		return domain.TeamSettings{}, domain.ErrInvalidSettings
+	}
+	if settings.AutoApproveExtension < 0 || settings.AutoApproveExtension > maxExtension {
+		return domain.TeamSettings{}, domain.ErrInvalidSettings
+	}
+	if settings.Strategy == "" {
+		settings.Strategy = domain.StrategyRandom
+	}
+	if !settings.Strategy.Valid() {
+		return domain.TeamSettings{}, domain.ErrInvalidSettings
+	}
+	if err := normalizeWorkingHours(&settings); err != nil {
+		return domain.TeamSettings{}, err
+	}
+	if err := s.normalizeShadowPool(ctx, &settings); err != nil {
+		return domain.TeamSettings{}, err
+	}
+	if settings.MandatoryReviewer != "" {
+		user, err := s.repo.GetUser(ctx, settings.MandatoryReviewer)
+		if err != nil {
+			return domain.TeamSettings{}, err
+		}
+		if !contains(user.Teams, settings.TeamName) {
+			return domain.TeamSettings{}, domain.ErrInvalidSettings
+		}
+	}
+	return s.repo.SaveTeamSettings(ctx, settings)
+}
+
+// normalizeWorkingHours validates the working hours and sorts the working
+// days, dropping duplicates.
+func normalizeWorkingHours(settings *domain.TeamSettings) error {
+	if settings.TimeZone == "" {
+		settings.TimeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(settings.TimeZone); err != nil {
+		return domain.ErrInvalidSettings
+	}
+	if settings.WorkStart < 0 || settings.WorkEnd > 24*time.Hour || settings.WorkStart > settings.WorkEnd {
+		return domain.ErrInvalidSettings
+	}
+
+	var seen [7]bool
+	for _, day := range settings.WorkDays {
+		if day < time.Sunday || day > time.Saturday {
+			return domain.ErrInvalidSettings
+		}
+		seen[day] = true
+	}
+	days := make([]time.Weekday, 0, len(settings.WorkDays))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if seen[day] {
+			days = append(days, day)
+		}
+	}
+	if settings.HasWorkingHours() && len(days) == 0 {
+		return domain.ErrInvalidSettings
+	}
+	settings.WorkDays = days
+	return nil
+}
+
+// SetUserActive changes the user's status and, when it actually changes,
+// records it in the membership history of every team the user belongs to.
+func (s *ReviewerService) SetUserActive(ctx context.Context, userID string, isActive bool) (domain.User, error) {
+	before, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return domain.User{}, err
+	}
+	var user domain.User
+	err = s.atomically(ctx, func(ctx context.Context) error {
+		user, err = s.repo.SetUserActive(ctx, userID, isActive)
+		if err != nil || before.IsActive == user.IsActive {
+			return err
+		}
+		return s.repo.AppendMembershipChanges(ctx, statusChanges(user))
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+	return user, nil
+}
+
+// BulkSetUserActive changes the status of several users in one transaction,
+// e.g. to deactivate a whole squad before holidays. Unknown users fail their
+// own result and leave the others alone. With reassignReviews, the open
+// reviews of deactivated users are handed over once everybody is deactivated,
+// so no review lands on another member of the same batch.
+func (s *ReviewerService) BulkSetUserActive(ctx context.Context, userIDs []string, isActive, reassignReviews bool) ([]domain.UserResult, error) {
+	results := make([]domain.UserResult, len(userIDs))
+	known := make([]string, 0, len(userIDs))
+	positions := make([]int, 0, len(userIDs))
+	wasActive := make(map[string]bool, len(userIDs))
+	for i, userID := range userIDs {
+		results[i].UserID = userID
+		before, err := s.repo.GetUser(ctx, userID)
+		if errors.Is(err, domain.ErrUserNotFound) {
+			results[i].Err = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		wasActive[userID] = before.IsActive
+		known = append(known, userID)
+		positions = append(positions, i)
+	}
+
+	// The statuses, their history and the hand-overs are stored together,
+	// so a failed hand-over leaves every user as they were.
+	err := s.atomically(ctx, func(ctx context.Context) error {
+		updated, err := s.repo.SetUsersActive(ctx, known, isActive)
+		if err != nil {
+			return err
+		}
+
+		var changes []domain.MembershipChange
+		for i, result := range updated {
+			results[positions[i]] = result
+			if result.Err == nil && wasActive[result.UserID] != result.User.IsActive {
+				changes = append(changes, statusChanges(result.User)...)
+			}
+		}
+		if err := s.repo.AppendMembershipChanges(ctx, changes); err != nil {
+			return err
+		}
+
+		if isActive || !reassignReviews {
+			return nil
+		}
+		for i := range results {
+			if results[i].Err != nil {
+				continue
+			}
+			results[i].Reassigned, err = s.handOverReviews(ctx, results[i].UserID, "")
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// statusChanges records the user's new status in each of their teams.
+func statusChanges(user domain.User) []domain.MembershipChange {
+	kind := domain.MembershipDeactivated
+	if user.IsActive {
+		kind = domain.MembershipActivated
+	}
+	changes := make([]domain.MembershipChange, 0, len(user.Teams))
+	for _, team := range user.Teams {
+		changes = append(changes, domain.MembershipChange{
+			TeamName: team,
+			UserID:   user.ID,
+			Kind:     kind,
+			IsActive: user.IsActive,
+		})
+	}
+	return changes
+}
+
+// UpdateUser renames the user and/or transfers them from their primary team to
+// another one. With reassignReviews, the open reviews the user holds for the
+// old team are handed over to its other members before the transfer; reviews
+// nobody can take over stay with the user. Either way the user leaves the old
+// team's candidate pool.
+func (s *ReviewerService) UpdateUser(ctx context.Context, userID string, update domain.UserUpdate, reassignReviews bool) (domain.User, []domain.ReviewHandover, error) {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return domain.User{}, nil, err
+	}
+	oldTeam := user.TeamName
+	transfer := update.TeamName != nil && *update.TeamName != oldTeam
+	if transfer {
+		target, err := s.repo.GetTeam(ctx, *update.TeamName)
+		if err != nil {
+			return domain.User{}, nil, err
+		}
+		if err := s.checkTeamSize(ctx, len(target.Members)+1); err != nil {
+			return domain.User{}, nil, err
+		}
+	}
+
+	var updated domain.User
+	var handovers []domain.ReviewHandover
+	err = s.atomically(ctx, func(ctx context.Context) error {
+		if transfer && reassignReviews {
+			handovers, err = s.handOverReviews(ctx, user.ID, oldTeam)
+			if err != nil {
+				return err
+			}
+		}
+
+		updated, err = s.repo.UpdateUser(ctx, userID, update)
+		if err != nil || !transfer {
+			return err
+		}
+		return s.repo.AppendMembershipChanges(ctx, []domain.MembershipChange{
+			{TeamName: oldTeam, UserID: updated.ID, Kind: domain.MembershipLeft, IsActive: updated.IsActive},
+			{TeamName: updated.TeamName, UserID: updated.ID, Kind: domain.MembershipJoined, IsActive: updated.IsActive},
+		})
+	})
+	if err != nil {
+		return domain.User{}, nil, err
+	}
+	return updated, handovers, nil
+}
+
+// handOverReviews reassigns the user's open reviews of pull requests authored
+// in teamName, or of every pull request when teamName is empty.
+func (s *ReviewerService) handOverReviews(ctx context.Context, userID, teamName string) ([]domain.ReviewHandover, error) {
+	prs, err := s.repo.ListPullRequestsByReviewer(ctx, userID, domain.ReviewFilter{Status: domain.StatusOpen})
+	if err != nil {
+		return nil, err
+	}
+
+	var handovers []domain.ReviewHandover
+	for _, pr := range prs {
+		author, err := s.repo.GetUser(ctx, pr.AuthorID)
+		if err != nil {
+			return nil, err
+		}
+		if teamName != "" && author.TeamName != teamName {
+			continue
+		}
+
+		handover := domain.ReviewHandover{PullRequestID: pr.ID}
+		_, replacement, err := s.ReassignReviewer(ctx, pr.ID, userID)
+		switch {
+		case err == nil:
+			handover.ReplacedBy = replacement
+		case !errors.Is(err, domain.ErrNoReplacement):
+			return nil, err
+		}
+		handovers = append(handovers, handover)
+	}
+	return handovers, nil
+}
+
+// TeamHistory returns the team's membership changes, oldest first.
+func (s *ReviewerService) TeamHistory(ctx context.Context, teamName string) ([]domain.MembershipChange, error) {
+	return s.repo.ListMembershipHistory(ctx, teamName)
+}
+
+// AssignmentHistory returns a page of the reviewer's assignments and removals
+// matching filter, oldest first, archived pull requests included. An unknown
+// reviewer fails with ErrUserNotFound rather than having no history.
+func (s *ReviewerService) AssignmentHistory(ctx context.Context, filter domain.AssignmentHistoryFilter, offset, limit int) ([]domain.ReviewerChange, error) {
+	if _, err := s.repo.GetUser(ctx, filter.ReviewerID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListReviewerHistory(ctx, filter, offset, limit)
+}
+
+// maxSnooze bounds how long a user can pause automatic assignments.
+const maxSnooze = 30 * 24 * time.Hour
+
+// SnoozeUser pauses automatic assignments to the user for the given
+// duration; a zero duration lifts an active snooze. The snooze expires on its
+// own, and explicit assignments still reach a snoozed user.
+func (s *ReviewerService) SnoozeUser(ctx context.Context, userID string, duration time.Duration) (domain.User, error) {
+	if duration < 0 || duration > maxSnooze {
+		return domain.User{}, domain.ErrInvalidSnooze
+	}
+	var until *time.Time
+	if duration > 0 {
+		t := s.now().Add(duration)
+		until = &t
+	}
+	return s.repo.SnoozeUser(ctx, userID, until)
+}
+
+func (s *ReviewerService) AddIdentity(ctx context.Context, identity domain.Identity) (domain.Identity, error) {
+	identity.ExternalID = normalizeExternalID(identity.ExternalID)
+	if !identity.Provider.Valid() || identity.ExternalID == "" {
+		return domain.Identity{}, domain.ErrInvalidIdentity
+	}
+	return s.repo.AddIdentity(ctx, identity)
+}
+
+func (s *ReviewerService) ListIdentities(ctx context.Context, userID string) ([]domain.Identity, error) {
+	return s.repo.ListIdentities(ctx, userID)
+}
+
+func (s *ReviewerService) ResolveIdentity(ctx context.Context, provider domain.IdentityProvider, externalID string) (domain.User, error) {
+	return s.repo.ResolveIdentity(ctx, provider, normalizeExternalID(externalID))
+}
+
+func (s *ReviewerService) GetPullRequest(ctx context.Context, prID string) (domain.PullRequest, error) {
+	return s.repo.GetPullRequest(ctx, prID)
+}
+
+func (s *ReviewerService) CreatePullRequest(ctx context.Context, pr domain.PullRequest) (domain.PullRequest, error) {
+	// The pull request, its first reviewers, the decision that picked them
+	// and the advance of the team's rotation are stored in one transaction,
+	// so that a failure after the pick does not leave a pull request the
+	// client was told does not exist or move the rotation past reviewers it
+	// never got.
+	var created domain.PullRequest
+	err := s.atomically(ctx, func(ctx context.Context) error {
+		prepared, err := s.preparePullRequest(ctx, pr)
+		if err != nil {
+			return err
+		}
+		created, err = s.repo.CreatePullRequest(ctx, prepared.pr)
+		if err != nil {
+			return err
+		}
+		return s.recordPullRequestCreated(ctx, &created, prepared)
+	})
+	if err != nil {
+		return domain.PullRequest{}, err
+	}
+
+	return created, nil
+}
+
+// BulkCreatePullRequests creates the pull requests in one transaction. Each
+// one is created in a nested transaction of its own, so that a pull request
+// that fails is reported in its result and leaves neither itself nor its pick
+// from the rotation behind, while the rest of the batch is kept.
+func (s *ReviewerService) BulkCreatePullRequests(ctx context.Context, prs []domain.PullRequest) ([]domain.PullRequestResult, error) {
+	results := make([]domain.PullRequestResult, len(prs))
+	err := s.atomically(ctx, func(ctx context.Context) error {
+		for i, pr := range prs {
+			created, err := s.CreatePullRequest(ctx, pr)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				results[i] = domain.PullRequestResult{PullRequest: pr, Err: err}
+				continue
+			}
+			results[i] = domain.PullRequestResult{PullRequest: created}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// preparedPullRequest is a pull request ready to be stored, together with the
+// decision that picked its reviewers.
+type preparedPullRequest struct {
+	pr       domain.PullRequest
+	decision domain.AssignmentDecision
+	// short reports that fewer reviewers than required were found.
+	short bool
+}
+
+// preparePullRequest picks reviewers from the author's team according to the
+// team's settings and fills in the fields owned by the service. The decision
+// describes the pick and is stored in the transaction that creates the pull
+// request. Drafts get no reviewers until MarkPullRequestReady.
+func (s *ReviewerService) preparePullRequest(ctx context.Context, pr domain.PullRequest) (preparedPullRequest, error) {
+	if len(pr.Description) > maxDescriptionBytes {
+		return preparedPullRequest{}, domain.ErrInvalidPullRequest
+	}
+	if pr.Labels != nil {
+		labels, err := normalizeLabels(pr.Labels)
+		if err != nil {
+			return preparedPullRequest{}, err
+		}
+		pr.Labels = labels
+	}
+
+	author, err := s.pullRequestAuthor(ctx, pr)
+	if err != nil {
+		return preparedPullRequest{}, err
+	}
+	pr.TeamName = ""
+	if err := s.checkOpenPullRequests(ctx, author.ID); err != nil {
+		return preparedPullRequest{}, err
+	}
+
+	members, err := s.repo.ListUsersByTeam(ctx, author.TeamName)
+	if err != nil {
+		return preparedPullRequest{}, err
+	}
+
+	settings, err := s.repo.GetTeamSettings(ctx, author.TeamName)
+	if err != nil {
+		return preparedPullRequest{}, err
+	}
+	required, err := requiredReviewers(settings, pr)
+	if err != nil {
+		return preparedPullRequest{}, err
+	}
+
+	if pr.Priority == "" {
+		pr.Priority = domain.PriorityNormal
+	}
+	pr.CreatedAt = s.now()
+	if pr.Status == domain.StatusDraft {
+		return preparedPullRequest{pr: pr}, nil
+	}
+	return s.openPullRequest(ctx, pr, author, members, settings, required)
+}
+
+// pullRequestAuthor returns the author of a new pull request. A bot configured
+// with SetBotTeams and unknown to the service yet is registered as an inactive
+// member of the team it maps to, so that the team reviews it and the bot is
+// never picked as a reviewer. Any other unknown author is not found: TeamName
+// only checks the team of the author and never registers one.
+func (s *ReviewerService) pullRequestAuthor(ctx context.Context, pr domain.PullRequest) (domain.User, error) {
+	author, err := s.repo.GetUser(ctx, pr.AuthorID)
+	if err == nil {
+		if pr.TeamName != "" && pr.TeamName != author.TeamName {
+			return domain.User{}, domain.ErrInvalidAuthorTeam
+		}
+		return author, nil
+	}
+	if !errors.Is(err, domain.ErrUserNotFound) {
+		return domain.User{}, err
+	}
+
+	teamName := s.botTeams[pr.AuthorID]
+	if teamName == "" {
+		return domain.User{}, err
+	}
+	if pr.TeamName != "" && pr.TeamName != teamName {
+		return domain.User{}, domain.ErrInvalidAuthorTeam
+	}
+	author, created, err := s.repo.EnsureUser(ctx, domain.User{
+		ID:       pr.AuthorID,
+		Username: pr.AuthorID,
+		TeamName: teamName,
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+	if created {
+		if err := s.repo.AppendMembershipChanges(ctx, []domain.MembershipChange{{
+			TeamName: teamName,
+			UserID:   author.ID,
+			Kind:     domain.MembershipJoined,
+		}}); err != nil {
+			return domain.User{}, err
+		}
+	}
+	if author.TeamName != teamName {
+		// Registered concurrently for another team.
+		return domain.User{}, domain.ErrInvalidAuthorTeam
+	}
+	return author, nil
+}
+
+// openPullRequest picks the first reviewers of a pull request, or defers the
+// pick when the team is off work, and moves it to OPEN.
+func (s *ReviewerService) openPullRequest(ctx context.Context, pr domain.PullRequest, author domain.User, members []domain.User, settings domain.TeamSettings, required int) (preparedPullRequest, error) {
+	if err := s.checkReviewerCount(ctx, required); err != nil {
+		return preparedPullRequest{}, err
+	}
+	var decision domain.AssignmentDecision
+	if s.deferOffHours && !settings.WorkingAt(pr.CreatedAt) {
+		decision = domain.AssignmentDecision{
+			PullRequestID: pr.ID,
+			Reason:        domain.ReasonAuto,
+			TeamName:      author.TeamName,
+			Strategy:      domain.DecisionDeferred,
+		}
+	} else {
+		var err error
+		decision, err = s.pickInitialReviewers(ctx, pr, author, author.TeamName, members, settings, required)
+		if err != nil {
+			return preparedPullRequest{}, err
+		}
+	}
+
+	pr.AssignedReviewers = decision.Selected
+	pr.ShadowReviewer = s.pickShadow(settings, members, pr, pr.CreatedAt)
+	pr.Status = domain.StatusOpen
+
+	return preparedPullRequest{
+		pr:       pr,
+		decision: decision,
+		short:    decision.Strategy != domain.DecisionDeferred && len(decision.Selected) < required,
+	}, nil
+}
+
+// errReadyRaced rolls back marking a draft ready that another call marked
+// first, along with the rotation its pick advanced.
+var errReadyRaced = errors.New("pull request marked ready concurrently")
+
+// MarkPullRequestReady takes a draft out of draft: its reviewers are picked
+// as on creation and it becomes OPEN. Reminders and review times count from
+// this moment, which becomes the pull request's creation time. Marking an
+// open pull request ready returns it unchanged. The pull request is read,
+// its reviewers picked and stored in one transaction, so concurrent calls
+// pick once.
+func (s *ReviewerService) MarkPullRequestReady(ctx context.Context, prID string) (domain.PullRequest, error) {
+	var ready domain.PullRequest
+	err := s.atomically(ctx, func(ctx context.Context) error {
+		pr, err := s.repo.GetPullRequest(ctx, prID)
+		if err != nil {
+			return err
+		}
+		ready = pr
+		if pr.Status != domain.StatusDraft {
+			return nil
+		}
+
+		author, err := s.repo.GetUser(ctx, pr.AuthorID)
+		if err != nil {
+			return err
+		}
+		members, err := s.repo.ListUsersByTeam(ctx, author.TeamName)
+		if err != nil {
+			return err
+		}
+		settings, err := s.repo.GetTeamSettings(ctx, author.TeamName)
+		if err != nil {
+			return err
+		}
+		required, err := requiredReviewers(settings, pr)
+		if err != nil {
+			// The team lowered its required reviewers below the requested count.
+			required = settings.RequiredReviewers
+		}
+
+		seen := pr
+		pr.CreatedAt = s.now()
+		prepared, err := s.openPullRequest(ctx, pr, author, members, settings, required)
+		if err != nil {
+			return err
+		}
+		var updated bool
+		ready, updated, err = s.repo.CompareAndUpdatePullRequest(ctx, seen, prepared.pr)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return errReadyRaced
+		}
+
+		payload := map[string]any{
+			"assigned_reviewers": ready.AssignedReviewers,
+		}
+		if err := s.recordInitialAssignment(ctx, &ready, prepared, payload); err != nil {
+			return err
+		}
+		return s.recordPullRequestEvent(ctx, domain.EventPRReady, ready, payload)
+	})
+	if err != nil && !errors.Is(err, errReadyRaced) {
+		return domain.PullRequest{}, err
+	}
+	if ready.Status == domain.StatusMerged {
+		return domain.PullRequest{}, domain.ErrPRMerged
+	}
+	return ready, nil
+}
+
+// requiredReviewers returns the number of reviewers the pull request needs:
+// the count it requested, or the team's default.
+func requiredReviewers(settings domain.TeamSettings, pr domain.PullRequest) (int, error) {
+	switch {
+	case pr.ReviewersCount < 0:
+		return 0, domain.ErrInvalidPullRequest
+	case pr.ReviewersCount > settings.RequiredReviewers:
+		return 0, domain.ErrTooManyReviewers
+	case pr.ReviewersCount > 0:
+		return pr.ReviewersCount, nil
+	}
+	return settings.RequiredReviewers, nil
+}
+
+// pickInitialReviewers picks the first reviewers of a pull request from the
+// team's members, following its rotation or selection strategy.
+func (s *ReviewerService) pickInitialReviewers(ctx context.Context, pr domain.PullRequest, author domain.User, teamName string, members []domain.User, settings domain.TeamSettings, required int) (domain.AssignmentDecision, error) {
+	now := s.now()
+	candidates, err := s.withinReviewLimit(ctx, settings, filterReviewers(members, pr.Authors(), now))
+	if err != nil {
+		return domain.AssignmentDecision{}, err
+	}
+	// The mandatory reviewer joins on top of the picked reviewers, whatever
+	// its open reviews, so it is kept out of the pick.
+	eligible := candidates
+	mandatory, hasMandatory := mandatoryReviewer(settings, members, pr.Authors(), now)
+	if hasMandatory {
+		candidates = withoutUsers(candidates, []string{mandatory.ID})
+		eligible = append(candidates[:len(candidates):len(candidates)], mandatory)
+	}
+	authorCanFill := settings.AllowAuthorReview && author.IsActive && !author.Snoozed(now)
+	poolSize := len(eligible)
+	if authorCanFill {
+		poolSize++
+	}
+	if poolSize < required && !settings.AllowSingleReviewer {
+		return domain.AssignmentDecision{}, domain.ErrNotEnoughReviewers
+	}
+
+	cooling, err := s.coolingDown(ctx, settings, pr.AuthorID, now)
+	if err != nil {
+		return domain.AssignmentDecision{}, err
+	}
+	var fresh, cooled []domain.User
+	for _, candidate := range candidates {
+		if cooling[candidate.ID] != "" {
+			cooled = append(cooled, candidate)
+		} else {
+			fresh = append(fresh, candidate)
+		}
+	}
+
+	decision := domain.AssignmentDecision{
+		PullRequestID: pr.ID,
+		Reason:        domain.ReasonAuto,
+		TeamName:      teamName,
+		Strategy:      domain.DecisionRotation,
+		Filters:       appliedFilters(settings, authorFilters(pr, domain.FilterInactive, domain.FilterSnoozed)...),
+	}
+	if settings.ReviewCooldown > 0 {
+		decision.Filters = append(decision.Filters, domain.FilterCooldown)
+	}
+	if settings.AvoidPreviousReviewers {
+		decision.Filters = append(decision.Filters, domain.FilterPrevious)
+	}
+
+	var rnd *rand.Rand
+	random := func() *rand.Rand {
+		if rnd == nil {
+			decision.Seed, rnd = s.seededRand()
+		}
+		return rnd
+	}
+	pick := func(pool []domain.User, limit int) ([]string, error) {
+		reviewers, err := s.pickFromRotation(ctx, teamName, pool, limit)
+		if !errors.Is(err, domain.ErrRotationNotFound) {
+			return reviewers, err
+		}
+		decision.Strategy = string(settings.Strategy)
+		return s.pickByStrategy(ctx, random(), settings.Strategy, pr, pool, limit)
+	}
+
+	// Owners of the changed files take the first slots; the rest are
+	// picked as usual.
+	owners, err := s.pickOwners(ctx, random, teamName, pr.Files, fresh, cooled, required)
+	if err != nil {
+		return domain.AssignmentDecision{}, err
+	}
+	decision.Owners = owners
+	reviewers := append([]string(nil), owners...)
+	if len(owners) > 0 && len(owners) == required {
+		decision.Strategy = domain.DecisionOwnership
+	}
+
+	// Members in their review cooldown or who reviewed the author's
+	// previous pull request only fill the slots the others cannot.
+	if len(reviewers) < required {
+		var more []string
+		more, err = pick(withoutUsers(fresh, owners), required-len(reviewers))
+		reviewers = append(reviewers, more...)
+	}
+	if err == nil && len(reviewers) < required && len(cooled) > 0 {
+		var more []string
+		more, err = pick(withoutUsers(cooled, owners), required-len(reviewers))
+		reviewers = append(reviewers, more...)
+	}
+	if err != nil {
+		return domain.AssignmentDecision{}, err
+	}
+	heldBack := make(map[string]string, len(cooled))
+	for _, user := range cooled {
+		if !contains(reviewers, user.ID) {
+			heldBack[user.ID] = cooling[user.ID]
+		}
+	}
+	decision.Candidates = snapshotCandidates(members, eligible, func(user domain.User) string {
+		switch {
+		case user.ID == pr.AuthorID:
+			return domain.FilterAuthor
+		case contains(pr.CoAuthors, user.ID):
+			return domain.FilterCoAuthor
+		case !user.IsActive:
+			return domain.FilterInactive
+		case user.Snoozed(now):
+			return domain.FilterSnoozed
+		case heldBack[user.ID] != "":
+			return heldBack[user.ID]
+		}
+		return ""
+	})
+
+	if hasMandatory {
+		reviewers = append(reviewers, mandatory.ID)
+		decision.Mandatory = mandatory.ID
+	}
+
+	// The author only ever fills a slot nobody else could take.
+	if len(reviewers) < required && authorCanFill {
+		reviewers = append(reviewers, author.ID)
+		decision.AuthorFallback = true
+	}
+	if len(reviewers) < required && !settings.AllowSingleReviewer {
+		return domain.AssignmentDecision{}, domain.ErrNotEnoughReviewers
+	}
+
+	decision.Selected = reviewers
+	return decision, nil
+}
+
+func (s *ReviewerService) recordPullRequestCreated(ctx context.Context, pr *domain.PullRequest, prepared preparedPullRequest) error {
+	payload := map[string]any{
+		"author_id":          pr.AuthorID,
+		"assigned_reviewers": pr.AssignedReviewers,
+	}
+	if pr.Status == domain.StatusDraft {
+		payload["draft"] = true
+	} else if err := s.recordInitialAssignment(ctx, pr, prepared, payload); err != nil {
+		return err
+	}
+	return s.recordPullRequestEvent(ctx, domain.EventPRCreated, *pr, payload)
+}
+
+// recordInitialAssignment records the first reviewers of a pull request that
+// was just opened, queues what is left to assign and notes both in payload.
+func (s *ReviewerService) recordInitialAssignment(ctx context.Context, pr *domain.PullRequest, prepared preparedPullRequest, payload map[string]any) error {
+	decision := prepared.decision
+	if err := s.recordReviewerChanges(ctx, pr, nil, pr.AssignedReviewers, domain.ReasonAuto); err != nil {
+		return err
+	}
+	if err := s.repo.AppendAssignmentDecision(ctx, decision); err != nil {
+		return err
+	}
+
+	if pr.ShadowReviewer != "" {
+		payload["shadow_reviewer_id"] = pr.ShadowReviewer
+	}
+	if decision.Strategy == domain.DecisionDeferred {
+		if err := s.repo.DeferAssignment(ctx, domain.DeferredAssignment{
+			PullRequestID: pr.ID,
+			TeamName:      decision.TeamName,
+		}); err != nil {
+			return err
+		}
+		payload["assignment_deferred"] = true
+	}
+	if prepared.short {
+		if err := s.repo.QueuePendingAssignment(ctx, pr.ID); err != nil {
+			return err
+		}
+		payload["assignment_pending"] = true
+	}
+	return nil
+}
+
+// UpdatePullRequest applies a partial edit to a pull request's descriptive
+// fields. Merged pull requests are frozen unless allowMerged is set.
+func (s *ReviewerService) UpdatePullRequest(ctx context.Context, prID string, update domain.PullRequestUpdate, allowMerged bool) (domain.PullRequest, error) {
+	pr, err := s.repo.GetPullRequest(ctx, prID)
+	if err != nil {
+		return domain.PullRequest{}, err
+	}
+	if pr.Status == domain.StatusMerged && !allowMerged {
+		return domain.PullRequest{}, domain.ErrPRMerged
+	}
+
+	changed := make(map[string]any)
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" {
+			return domain.PullRequest{}, domain.ErrInvalidPullRequest
+		}
+		pr.Name = name
+		changed["pull_request_name"] = name
+	}
+	if update.Labels != nil {
+		labels, err := normalizeLabels(*update.Labels)
+		if err != nil {
+			return domain.PullRequest{}, err
+		}
+		pr.Labels = labels
+		changed["labels"] = labels
+	}
+	if update.URL != nil {
+		if *update.URL != "" && !validURL(*update.URL) {
+			return domain.PullRequest{}, domain.ErrInvalidPullRequest
+		}
+		pr.URL = *update.URL
+		changed["url"] = pr.URL
+	}
+	if update.Priority != nil {
+		if !update.Priority.Valid() {
+			return domain.PullRequest{}, domain.ErrInvalidPullRequest
+		}
+		pr.Priority = *update.Priority
+		changed["priority"] = pr.Priority
+	}
+	if update.Description != nil {
+		if len(*update.Description) > maxDescriptionBytes {
+			return domain.PullRequest{}, domain.ErrInvalidPullRequest
+		}
+		pr.Description = *update.Description
+		changed["description"] = pr.Description
+	}
+	if len(changed) == 0 {
+		return pr, nil
+	}
+
+	var updated domain.PullRequest
+	err = s.atomically(ctx, func(ctx context.Context) error {
+		var err error
+		updated, err = s.repo.UpdatePullRequest(ctx, pr)
+		if err != nil {
+			return err
+		}
+		return s.recordPullRequestEvent(ctx, domain.EventPRUpdated, updated, changed)
+	})
+	if err != nil {
+		return domain.PullRequest{}, err
+	}
+
+	return updated, nil
+}
+
+func (s *ReviewerService) MergePullRequest(ctx context.Context, prID string) (domain.PullRequest, error) {
+	merged, _, err := s.mergePullRequest(ctx, prID, false)
+	return merged, err
 }
 
-func New(repo storage.Repository) *ReviewerService {
-	return &ReviewerService{
-		repo: repo,
-		rnd:  rand.New(rand.NewSource(time.Now().UnixNano())),
+// maxForceReason bounds the reason given for a force merge, in bytes.
+const maxForceReason = 500
+
+// ForceMergePullRequest merges the pull request on behalf of actor, an admin
+// or a lead of the author's team, past the guards of MergePullRequest, so that a draft is merged as well.
+// The bypass is recorded as PR_FORCE_MERGED, naming actor and reason, after
+// the usual PR_MERGED. Force merging a merged pull request returns it
+// unchanged and records nothing.
+func (s *ReviewerService) ForceMergePullRequest(ctx context.Context, prID, actor, reason string) (domain.PullRequest, error) {
+	actor, reason = strings.TrimSpace(actor), strings.TrimSpace(reason)
+	if actor == "" || reason == "" || len(reason) > maxForceReason {
+		return domain.PullRequest{}, domain.ErrInvalidForceMerge
+	}
+	before, err := s.repo.GetPullRequest(ctx, prID)
+	if err != nil {
+		return domain.PullRequest{}, err
+	}
+
+	var merged domain.PullRequest
+	err = s.atomically(ctx, func(ctx context.Context) error {
+		var (
+			transitioned bool
+			err          error
+		)
+		merged, transitioned, err = s.mergePullRequest(ctx, prID, true)
+		if err != nil || !transitioned {
+			return err
+		}
+		return s.recordPullRequestEvent(ctx, domain.EventPRForceMerged, merged, map[string]any{
+			"forced_by":     actor,
+			"reason":        reason,
+			"status_before": string(before.Status),
+		})
+	})
+	if err != nil {
+		return domain.PullRequest{}, err
 	}
+	return merged, nil
 }
 
-func (s *ReviewerService) CreateTeam(ctx context.Context, team domain.Team) (domain.Team, error) {
-	return s.repo.CreateTeam(ctx, team)
+// mergePullRequest merges the pull request and records PR_MERGED; the flag
+// reports whether this call merged it. Unless forced, a pull request with
+// unmerged blockers may be refused, see checkBlockers.
+func (s *ReviewerService) mergePullRequest(ctx context.Context, prID string, force bool) (domain.PullRequest, bool, error) {
+	if !force {
+		if err := s.checkBlockers(ctx, prID); err != nil {
+			return domain.PullRequest{}, false, err
+		}
+	}
+	var (
+		merged       domain.PullRequest
+		transitioned bool
+	)
+	err := s.atomically(ctx, func(ctx context.Context) error {
+		var err error
+		merged, transitioned, err = s.repo.MergePullRequest(ctx, prID, s.now(), force)
+		if err != nil {
+			return err
+		}
+		if merged.Status == domain.StatusDraft {
+			return domain.ErrPRDraft
+		}
+		// Concurrent or repeated merges observe the first caller's merged_at
+		// and leave recording the event to it.
+		if !transitioned {
+			return nil
+		}
+
+		payload := map[string]any{
+			"merged_at":     merged.MergedAt,
+			"reassignments": merged.Reassignments,
+		}
+		if force {
+			payload["forced"] = true
+		}
+		return s.recordPullRequestEvent(ctx, domain.EventPRMerged, merged, payload)
+	})
+	if err != nil {
+		return domain.PullRequest{}, false, err
+	}
+
+	return merged, transitioned, nil
 }
 
-func (s *ReviewerService) GetTeam(ctx context.Context, name string) (domain.Team, error) {
-	return s.repo.GetTeam(ctx, name)
+// BulkMergePullRequests merges the pull requests one by one, each in its own
+// transaction, so a failure leaves the others merged. Every error is reported
+// on its pull request and the batch goes on; once ctx is done the remaining
+// pull requests are reported with its error instead of being tried.
+func (s *ReviewerService) BulkMergePullRequests(ctx context.Context, prIDs []string) ([]domain.PullRequestResult, error) {
+	results := make([]domain.PullRequestResult, len(prIDs))
+	for i, id := range prIDs {
+		if err := ctx.Err(); err != nil {
+			results[i] = domain.PullRequestResult{PullRequest: domain.PullRequest{ID: id}, Err: err}
+			continue
+		}
+		merged, err := s.MergePullRequest(ctx, id)
+		if err != nil {
+			merged = domain.PullRequest{ID: id}
+		}
+		results[i] = domain.PullRequestResult{PullRequest: merged, Err: err}
+	}
+	return results, nil
 }
 
-func (s *ReviewerService) SetUserActive(ctx context.Context, userID string, isActive bool) (domain.User, error) {
-	return s.repo.SetUserActive(ctx, userID, isActive)
+// ReassignReviewer replaces a reviewer with a random eligible member. The
+// replacement is picked from a snapshot of the pull request and swapped in by
+// the store under a lock on the pull request; when a concurrent change made
+// the pick stale, the replacement is picked again.
+func (s *ReviewerService) ReassignReviewer(ctx context.Context, prID, oldReviewerID string) (domain.PullRequest, string, error) {
+	var updatedPR domain.PullRequest
+	var decision domain.AssignmentDecision
+	err := s.atomically(ctx, func(ctx context.Context) error {
+		for attempt := 1; ; attempt++ {
+			var err error
+			decision, err = s.pickReplacement(ctx, prID, oldReviewerID)
+			if err != nil {
+				return err
+			}
+			updatedPR, err = s.repo.ReassignReviewer(ctx, prID, oldReviewerID, decision.Selected[0])
+			if errors.Is(err, domain.ErrReassignConflict) && attempt < maxReassignAttempts {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			break
+		}
+		replacement := decision.Selected
+
+		if err := s.recordReviewerChanges(ctx, &updatedPR, []string{oldReviewerID}, replacement, domain.ReasonReassign); err != nil {
+			return err
+		}
+		if err := s.repo.AppendAssignmentDecision(ctx, decision); err != nil {
+			return err
+		}
+
+		return s.recordPullRequestEvent(ctx, domain.EventReviewerReassigned, updatedPR, map[string]any{
+			"old_user_id": oldReviewerID,
+			"replaced_by": replacement[0],
+		})
+	})
+	if err != nil {
+		return domain.PullRequest{}, "", err
+	}
+
+	return updatedPR, decision.Selected[0], nil
 }
 
-func (s *ReviewerService) CreatePullRequest(ctx context.Context, pr domain.PullRequest) (domain.PullRequest, error) {
+// pickReplacement chooses the member to replace oldReviewerID with on the
+// current state of the pull request. The choice is the only entry of the
+// decision's Selected.
+func (s *ReviewerService) pickReplacement(ctx context.Context, prID, oldReviewerID string) (domain.AssignmentDecision, error) {
+	pr, err := s.repo.GetPullRequest(ctx, prID)
+	if err != nil {
+		return domain.AssignmentDecision{}, err
+	}
+
+	if pr.Status == domain.StatusMerged {
+		return domain.AssignmentDecision{}, domain.ErrPRMerged
+	}
+
+	if reviewerIndex(pr.AssignedReviewers, oldReviewerID) == -1 {
+		return domain.AssignmentDecision{}, domain.ErrReviewerNotFound
+	}
+
+	oldReviewer, err := s.repo.GetUser(ctx, oldReviewerID)
+	if err != nil {
+		return domain.AssignmentDecision{}, err
+	}
 	author, err := s.repo.GetUser(ctx, pr.AuthorID)
 	if err != nil {
-		return domain.PullRequest{}, err
+		return domain.AssignmentDecision{}, err
 	}
 
-	members, err := s.repo.ListUsersByTeam(ctx, author.TeamName)
+	// Replacements come from the author's team when the replaced reviewer
+	// reviews for it, which holds unless reviewers were assigned by hand.
+	teamName := oldReviewer.TeamName
+	if contains(oldReviewer.Teams, author.TeamName) {
+		teamName = author.TeamName
+	}
+	members, err := s.repo.ListUsersByTeam(ctx, teamName)
 	if err != nil {
-		return domain.PullRequest{}, err
+		return domain.AssignmentDecision{}, err
 	}
 
-	candidates := filterReviewers(members, pr.AuthorID)
-	pr.AssignedReviewers = pickReviewers(s.rnd, candidates, 2)
-	pr.Status = domain.StatusOpen
-	pr.CreatedAt = time.Now().UTC()
+	settings, err := s.repo.GetTeamSettings(ctx, teamName)
+	if err != nil {
+		return domain.AssignmentDecision{}, err
+	}
+	now := s.now()
+	excluded := append([]string{oldReviewerID}, pr.CoAuthors...)
+	candidates, err := s.withinReviewLimit(ctx, settings, filterForReplacement(members, excluded, pr.AssignedReviewers, now))
+	if err != nil {
+		return domain.AssignmentDecision{}, err
+	}
+	if len(candidates) == 0 {
+		return domain.AssignmentDecision{}, domain.ErrNoReplacement
+	}
 
-	return s.repo.CreatePullRequest(ctx, pr)
+	seed, rnd := s.seededRand()
+	replacement := pickReviewers(rnd, candidates, 1)
+	if len(replacement) == 0 {
+		return domain.AssignmentDecision{}, domain.ErrNoReplacement
+	}
+	filters := []string{domain.FilterReplaced, domain.FilterInactive, domain.FilterSnoozed, domain.FilterAlreadyAssigned}
+	if len(pr.CoAuthors) > 0 {
+		filters = append(filters, domain.FilterCoAuthor)
+	}
+	return domain.AssignmentDecision{
+		PullRequestID: pr.ID,
+		Reason:        domain.ReasonReassign,
+		TeamName:      teamName,
+		Strategy:      string(domain.StrategyRandom),
+		Seed:          seed,
+		Filters:       appliedFilters(settings, filters...),
+		Candidates: snapshotCandidates(members, candidates, func(user domain.User) string {
+			switch {
+			case user.ID == oldReviewerID:
+				return domain.FilterReplaced
+			case contains(pr.CoAuthors, user.ID):
+				return domain.FilterCoAuthor
+			case !user.IsActive:
+				return domain.FilterInactive
+			case user.Snoozed(now):
+				return domain.FilterSnoozed
+			case contains(pr.AssignedReviewers, user.ID):
+				return domain.FilterAlreadyAssigned
+			}
+			return ""
+		}),
+		Selected: replacement,
+	}, nil
 }
 
-func (s *ReviewerService) MergePullRequest(ctx context.Context, prID string) (domain.PullRequest, error) {
+// AssignReviewers replaces the PR's reviewers with an explicit list, bypassing
+// automatic selection. Nominees must be active users other than the author
+// and the co-authors.
+func (s *ReviewerService) AssignReviewers(ctx context.Context, prID string, reviewerIDs []string) (domain.PullRequest, error) {
 	pr, err := s.repo.GetPullRequest(ctx, prID)
 	if err != nil {
 		return domain.PullRequest{}, err
 	}
-
 	if pr.Status == domain.StatusMerged {
-		return pr, nil
+		return domain.PullRequest{}, domain.ErrPRMerged
+	}
+	if pr.Status == domain.StatusDraft {
+		return domain.PullRequest{}, domain.ErrPRDraft
+	}
+
+	author, err := s.repo.GetUser(ctx, pr.AuthorID)
+	if err != nil {
+		return domain.PullRequest{}, err
+	}
+	settings, err := s.repo.GetTeamSettings(ctx, author.TeamName)
+	if err != nil {
+		return domain.PullRequest{}, err
+	}
+	if len(reviewerIDs) > settings.RequiredReviewers {
+		return domain.PullRequest{}, domain.ErrTooManyReviewers
+	}
+	if err := s.checkReviewerCount(ctx, len(reviewerIDs)); err != nil {
+		return domain.PullRequest{}, err
+	}
+
+	for i, reviewerID := range reviewerIDs {
+		if contains(pr.Authors(), reviewerID) || contains(reviewerIDs[:i], reviewerID) {
+			return domain.PullRequest{}, domain.ErrInvalidReviewer
+		}
+		reviewer, err := s.repo.GetUser(ctx, reviewerID)
+		if err != nil {
+			return domain.PullRequest{}, err
+		}
+		if !reviewer.IsActive {
+			return domain.PullRequest{}, domain.ErrInvalidReviewer
+		}
 	}
 
-	now := time.Now().UTC()
-	pr.Status = domain.StatusMerged
-	pr.MergedAt = &now
+	// The reviewers are replaced under a lock on the pull request, and only
+	// while it is open with the reviewers it was read with, so that a merge or
+	// a reassignment made since then is not overwritten.
+	seen := pr
+	previous := pr.AssignedReviewers
+	pr.AssignedReviewers = append([]string(nil), reviewerIDs...)
+	var updated domain.PullRequest
+	err = s.atomically(ctx, func(ctx context.Context) error {
+		var (
+			stored bool
+			err    error
+		)
+		updated, stored, err = s.repo.CompareAndUpdatePullRequest(ctx, seen, pr)
+		if err != nil {
+			return err
+		}
+		switch {
+		case stored:
+		case updated.Status == domain.StatusMerged:
+			return domain.ErrPRMerged
+		default:
+			return domain.ErrReassignConflict
+		}
+
+		removed, added := diffReviewers(previous, updated.AssignedReviewers)
+		if err := s.recordReviewerChanges(ctx, &updated, removed, added, domain.ReasonManual); err != nil {
+			return err
+		}
+		if err := s.repo.AppendAssignmentDecision(ctx, domain.AssignmentDecision{
+			PullRequestID: updated.ID,
+			Reason:        domain.ReasonManual,
+			TeamName:      author.TeamName,
+			Strategy:      domain.DecisionManual,
+			Selected:      updated.AssignedReviewers,
+		}); err != nil {
+			return err
+		}
+
+		return s.recordPullRequestEvent(ctx, domain.EventReviewersAssigned, updated, map[string]any{
+			"assigned_reviewers": updated.AssignedReviewers,
+		})
+	})
+	if err != nil {
+		return domain.PullRequest{}, err
+	}
 
-	return s.repo.UpdatePullRequest(ctx, pr)
+	return updated, nil
 }
 
-func (s *ReviewerService) ReassignReviewer(ctx context.Context, prID, oldReviewerID string) (domain.PullRequest, string, error) {
+// AddReviewer appends a reviewer beyond the requested count, for a second
+// opinion. An empty reviewerID picks one at random from the author's team;
+// otherwise the nominee must be an active user other than the author.
+func (s *ReviewerService) AddReviewer(ctx context.Context, prID, reviewerID string) (domain.PullRequest, string, error) {
 	pr, err := s.repo.GetPullRequest(ctx, prID)
 	if err != nil {
 		return domain.PullRequest{}, "", err
 	}
-
 	if pr.Status == domain.StatusMerged {
 		return domain.PullRequest{}, "", domain.ErrPRMerged
 	}
-
-	index := reviewerIndex(pr.AssignedReviewers, oldReviewerID)
-	if index == -1 {
-		return domain.PullRequest{}, "", domain.ErrReviewerNotFound
+	if pr.Status == domain.StatusDraft {
+		return domain.PullRequest{}, "", domain.ErrPRDraft
+	}
+	if len(pr.AssignedReviewers) >= maxRequiredReviewers {
+		return domain.PullRequest{}, "", domain.ErrTooManyReviewers
+	}
+	if err := s.checkReviewerCount(ctx, len(pr.AssignedReviewers)+1); err != nil {
+		return domain.PullRequest{}, "", err
 	}
 
-	oldReviewer, err := s.repo.GetUser(ctx, oldReviewerID)
+	author, err := s.repo.GetUser(ctx, pr.AuthorID)
 	if err != nil {
 		return domain.PullRequest{}, "", err
 	}
 
-	members, err := s.repo.ListUsersByTeam(ctx, oldReviewer.TeamName)
+	decision := domain.AssignmentDecision{
+		PullRequestID: pr.ID,
+		Reason:        domain.ReasonExtra,
+		TeamName:      author.TeamName,
+		Strategy:      domain.DecisionManual,
+	}
+	if reviewerID != "" {
+		if contains(pr.Authors(), reviewerID) {
+			return domain.PullRequest{}, "", domain.ErrInvalidReviewer
+		}
+		if contains(pr.AssignedReviewers, reviewerID) {
+			return domain.PullRequest{}, "", domain.ErrAlreadyAssigned
+		}
+		reviewer, err := s.repo.GetUser(ctx, reviewerID)
+		if err != nil {
+			return domain.PullRequest{}, "", err
+		}
+		if !reviewer.IsActive {
+			return domain.PullRequest{}, "", domain.ErrInvalidReviewer
+		}
+	} else {
+		members, err := s.repo.ListUsersByTeam(ctx, author.TeamName)
+		if err != nil {
+			return domain.PullRequest{}, "", err
+		}
+		settings, err := s.repo.GetTeamSettings(ctx, author.TeamName)
+		if err != nil {
+			return domain.PullRequest{}, "", err
+		}
+		now := s.now()
+		candidates, err := s.withinReviewLimit(ctx, settings, filterForReplacement(members, pr.Authors(), pr.AssignedReviewers, now))
+		if err != nil {
+			return domain.PullRequest{}, "", err
+		}
+
+		seed, rnd := s.seededRand()
+		picked := pickReviewers(rnd, candidates, 1)
+		if len(picked) == 0 {
+			return domain.PullRequest{}, "", domain.ErrNoReplacement
+		}
+		reviewerID = picked[0]
+
+		decision.Strategy = string(domain.StrategyRandom)
+		decision.Seed = seed
+		decision.Filters = appliedFilters(settings, authorFilters(pr, domain.FilterInactive, domain.FilterSnoozed, domain.FilterAlreadyAssigned)...)
+		decision.Candidates = snapshotCandidates(members, candidates, func(user domain.User) string {
+			switch {
+			case user.ID == pr.AuthorID:
+				return domain.FilterAuthor
+			case contains(pr.CoAuthors, user.ID):
+				return domain.FilterCoAuthor
+			case !user.IsActive:
+				return domain.FilterInactive
+			case user.Snoozed(now):
+				return domain.FilterSnoozed
+			case contains(pr.AssignedReviewers, user.ID):
+				return domain.FilterAlreadyAssigned
+			}
+			return ""
+		})
+	}
+	decision.Selected = []string{reviewerID}
+
+	// The reviewer is added under a lock on the pull request, and only while
+	// it has no more reviewers than it was read with: a reassignment made
+	// since then stands, while a fill or a merge fails the addition.
+	var updated domain.PullRequest
+	err = s.atomically(ctx, func(ctx context.Context) error {
+		var (
+			added []string
+			err   error
+		)
+		updated, added, err = s.repo.AddReviewers(ctx, pr.ID, decision.Selected, len(pr.AssignedReviewers)+1)
+		if err != nil {
+			return err
+		}
+		switch {
+		case updated.Status == domain.StatusMerged:
+			return domain.ErrPRMerged
+		case len(added) > 0:
+		case contains(updated.AssignedReviewers, reviewerID):
+			return domain.ErrAlreadyAssigned
+		default:
+			return domain.ErrReassignConflict
+		}
+
+		if err := s.recordReviewerChanges(ctx, &updated, nil, decision.Selected, domain.ReasonExtra); err != nil {
+			return err
+		}
+		if err := s.repo.AppendAssignmentDecision(ctx, decision); err != nil {
+			return err
+		}
+
+		return s.recordPullRequestEvent(ctx, domain.EventReviewersAssigned, updated, map[string]any{
+			"assigned_reviewers": updated.AssignedReviewers,
+			"added":              reviewerID,
+		})
+	})
 	if err != nil {
 		return domain.PullRequest{}, "", err
 	}
 
-	candidates := filterForReplacement(members, oldReviewerID, pr.AssignedReviewers)
-	if len(candidates) == 0 {
-		return domain.PullRequest{}, "", domain.ErrNoReplacement
+	return updated, reviewerID, nil
+}
+
+func (s *ReviewerService) ListUserReviews(ctx context.Context, userID string, filter domain.ReviewFilter) ([]domain.PullRequest, error) {
+	return s.repo.ListPullRequestsByReviewer(ctx, userID, filter)
+}
+
+// WaitUserReviews blocks until the user's open reviews are no longer
+// unchanged, or until ctx is done, and returns the latest list. The list is
+// reloaded whenever an event that can move reviews is published.
+func (s *ReviewerService) WaitUserReviews(ctx context.Context, userID string, unchanged func([]domain.PullRequest) bool) ([]domain.PullRequest, error) {
+	// Subscribing before the first load ensures no change slips in between.
+	events, cancel := s.bus.Subscribe(subscriberBuffer, func(event domain.Event) bool {
+		switch event.Type {
+		case domain.EventPRCreated, domain.EventPRReady, domain.EventPRUpdated, domain.EventPRMerged,
+			domain.EventReviewerReassigned, domain.EventReviewersAssigned:
+			return true
+		}
+		return false
+	})
+	defer cancel()
+
+	filter := domain.ReviewFilter{Status: domain.StatusOpen, SortBy: domain.SortByCreatedAt, Descending: true}
+	var last []domain.PullRequest
+	for loaded := false; ; loaded = true {
+		prs, err := s.repo.ListPullRequestsByReviewer(ctx, userID, filter)
+		if err != nil {
+			// A reload cut short by the deadline still answers with the
+			// list seen last.
+			if loaded && ctx.Err() != nil {
+				return last, nil
+			}
+			return nil, err
+		}
+		if !unchanged(prs) {
+			return prs, nil
+		}
+		last = prs
+
+		select {
+		case <-ctx.Done():
+			return last, nil
+		case <-events:
+		}
 	}
+}
 
-	replacement := pickReviewers(s.rnd, candidates, 1)
-	if len(replacement) == 0 {
-		return domain.PullRequest{}, "", domain.ErrNoReplacement
+func (s *ReviewerService) ListChanges(ctx context.Context, since int64, limit int) ([]domain.Event, error) {
+	return s.repo.ListEvents(ctx, since, limit)
+}
+
+// ListLatestChanges returns the last limit events of the change feed, oldest
+// first.
+func (s *ReviewerService) ListLatestChanges(ctx context.Context, limit int) ([]domain.Event, error) {
+	return s.repo.ListLatestEvents(ctx, limit)
+}
+
+// CheckIntegrity reports inconsistent data and, with fix set, repairs it.
+// Fixes change the data behind the service's back: no events are recorded.
+func (s *ReviewerService) CheckIntegrity(ctx context.Context, fix bool) (domain.IntegrityReport, error) {
+	now := s.now()
+	issues, err := s.repo.CheckIntegrity(ctx, now, fix)
+	if err != nil {
+		return domain.IntegrityReport{}, err
 	}
+	return domain.IntegrityReport{CheckedAt: now, Issues: issues}, nil
+}
 
-	pr.AssignedReviewers[index] = replacement[0]
-	updatedPR, err := s.repo.UpdatePullRequest(ctx, pr)
+// CheckOrphans reports the completions and pending acceptances left behind
+// by reviewers removed without the cleanup that should have followed and,
+// with fix set, deletes them.
+func (s *ReviewerService) CheckOrphans(ctx context.Context, fix bool) (domain.IntegrityReport, error) {
+	now := s.now()
+	issues, err := s.repo.CheckOrphans(ctx, fix)
 	if err != nil {
-		return domain.PullRequest{}, "", err
+		return domain.IntegrityReport{}, err
+	}
+	return domain.IntegrityReport{CheckedAt: now, Issues: issues}, nil
+}
+
+// PruneEvents drops the events up to and including throughSeq once they have
+// been exported. The change feed no longer returns them.
+func (s *ReviewerService) PruneEvents(ctx context.Context, throughSeq int64) (int, error) {
+	return s.repo.DeleteEvents(ctx, throughSeq)
+}
+
+func (s *ReviewerService) Maintenance(ctx context.Context) (domain.Maintenance, error) {
+	return s.repo.GetMaintenance(ctx)
+}
+
+// SetMaintenance switches the read-only mode. The flag is stored so that it
+// applies to every replica and survives restarts.
+func (s *ReviewerService) SetMaintenance(ctx context.Context, enabled bool) (domain.Maintenance, error) {
+	return s.repo.SetMaintenance(ctx, enabled)
+}
+
+// Reset deletes all data. Only test environments expose it.
+func (s *ReviewerService) Reset(ctx context.Context) error {
+	return s.repo.Reset(ctx)
+}
+
+// PoolStats reports connection pool statistics when the repository has a pool.
+func (s *ReviewerService) PoolStats() (storage.PoolStats, bool) {
+	provider, ok := storage.AsStatsProvider(s.repo)
+	if !ok {
+		return storage.PoolStats{}, false
 	}
+	return provider.PoolStats(), true
+}
 
-	return updatedPR, replacement[0], nil
+// MigrationStatus reports the applied and pending schema migrations when the
+// repository has a versioned schema.
+func (s *ReviewerService) MigrationStatus(ctx context.Context) ([]storage.MigrationStatus, bool, error) {
+	provider, ok := storage.AsMigrationProvider(s.repo)
+	if !ok {
+		return nil, false, nil
+	}
+	migrations, err := provider.MigrationStatus(ctx)
+	return migrations, true, err
 }
 
-func (s *ReviewerService) ListUserReviews(ctx context.Context, userID string) ([]domain.PullRequest, error) {
-	return s.repo.ListPullRequestsByReviewer(ctx, userID)
+// SubscribeEvents streams live events for the given teams, or for every team
+// when teamNames is empty. The returned function ends the subscription.
+func (s *ReviewerService) SubscribeEvents(teamNames []string) (<-chan domain.Event, func()) {
+	var filter eventbus.Filter
+	if len(teamNames) > 0 {
+		filter = func(event domain.Event) bool {
+			return contains(teamNames, event.TeamName)
+		}
+	}
+	return s.bus.Subscribe(subscriberBuffer, filter)
 }
 
 func (s *ReviewerService) Health(ctx context.Context) error {
 	return s.repo.Health(ctx)
 }
 
-func filterReviewers(users []domain.User, authorID string) []domain.User {
+// recordEvent persists an event to the change feed and publishes it to live
+// subscribers once it has a sequence number.
+func (s *ReviewerService) recordEvent(ctx context.Context, eventType domain.EventType, teamName, entityID string, payload map[string]any) error {
+	event, err := s.repo.AppendEvent(ctx, domain.Event{
+		Type:     eventType,
+		TeamName: teamName,
+		EntityID: entityID,
+		Payload:  payload,
+	})
+	if err != nil {
+		return err
+	}
+	if unit, ok := ctx.Value(unitKey{}).(*unitOfWork); ok {
+		unit.events = append(unit.events, event)
+		return nil
+	}
+	s.bus.Publish(event)
+	return nil
+}
+
+// unitKey is the context key of the unitOfWork that atomically runs.
+type unitKey struct{}
+
+// unitOfWork collects the events recorded within atomically, which are
+// published only once its transaction commits.
+type unitOfWork struct {
+	events []domain.Event
+}
+
+// atomically runs fn in one transaction of the repository, so that a change
+// and the records and events describing it are stored together or not at
+// all. Events recorded by fn reach subscribers after the commit. Repositories
+// without transactions run fn as it is. A nested call runs in a nested
+// transaction: when it fails, its changes and events are dropped and the
+// outer one goes on; otherwise they are kept until the outer one ends.
+func (s *ReviewerService) atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	parent, nested := ctx.Value(unitKey{}).(*unitOfWork)
+	unit := &unitOfWork{}
+	run := func(ctx context.Context) error {
+		unit.events = nil
+		return fn(context.WithValue(ctx, unitKey{}, unit))
+	}
+	var err error
+	if tx, ok := storage.AsTransactor(s.repo); ok {
+		err = tx.Atomically(ctx, run)
+	} else {
+		err = run(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	if nested {
+		parent.events = append(parent.events, unit.events...)
+		return nil
+	}
+	for _, event := range unit.events {
+		s.bus.Publish(event)
+	}
+	return nil
+}
+
+// recordPullRequestEvent attributes a pull request event to the author's team.
+func (s *ReviewerService) recordPullRequestEvent(ctx context.Context, eventType domain.EventType, pr domain.PullRequest, payload map[string]any) error {
+	author, err := s.repo.GetUser(ctx, pr.AuthorID)
+	if err != nil {
+		return err
+	}
+	return s.recordEvent(ctx, eventType, author.TeamName, pr.ID, payload)
+}
+
+// normalizeExternalID lowercases logins and emails: every supported provider
+// treats them case-insensitively.
+func normalizeExternalID(externalID string) string {
+	return strings.ToLower(strings.TrimSpace(externalID))
+}
+
+// normalizeLabels trims labels and drops duplicates, keeping the first
+// occurrence's position.
+func normalizeLabels(labels []string) ([]string, error) {
+	result := make([]string, 0, len(labels))
+	seen := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			return nil, domain.ErrInvalidPullRequest
+		}
+		if seen[label] {
+			continue
+		}
+		seen[label] = true
+		result = append(result, label)
+	}
+	if len(result) > maxLabels {
+		return nil, domain.ErrInvalidPullRequest
+	}
+	return result, nil
+}
+
+func validURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// recordReviewerChanges appends the reviewer changes of the pull request to
+// its history, reopens the reviews of the removed reviewers so that they
+// start over if assigned again, and asks the added reviewers to accept the
+// review when the author's team requires it, noting them in pr.Acceptance.
+func (s *ReviewerService) recordReviewerChanges(ctx context.Context, pr *domain.PullRequest, removed, added []string, reason string) error {
+	prID := pr.ID
+	changes := make([]domain.ReviewerChange, 0, len(removed)+len(added))
+	for _, reviewerID := range removed {
+		changes = append(changes, domain.ReviewerChange{
+			PullRequestID: prID,
+			ReviewerID:    reviewerID,
+			Action:        domain.ReviewerUnassigned,
+			Reason:        reason,
+		})
+	}
+	for _, reviewerID := range added {
+		changes = append(changes, domain.ReviewerChange{
+			PullRequestID: prID,
+			ReviewerID:    reviewerID,
+			Action:        domain.ReviewerAssigned,
+			Reason:        reason,
+		})
+	}
+	if err := s.repo.AppendReviewerHistory(ctx, changes); err != nil {
+		return err
+	}
+	if len(removed) > 0 {
+		if err := s.repo.ReopenReviews(ctx, prID, removed); err != nil {
+			return err
+		}
+	}
+	return s.requestAcceptance(ctx, pr, removed, added)
+}
+
+// diffReviewers returns reviewers present only in before and only in after.
+func diffReviewers(before, after []string) (removed, added []string) {
+	for _, reviewer := range before {
+		if !contains(after, reviewer) {
+			removed = append(removed, reviewer)
+		}
+	}
+	for _, reviewer := range after {
+		if !contains(before, reviewer) {
+			added = append(added, reviewer)
+		}
+	}
+	return removed, added
+}
+
+func filterReviewers(users []domain.User, authors []string, now time.Time) []domain.User {
 	candidates := make([]domain.User, 0, len(users))
 	for _, user := range users {
-		if user.ID == authorID {
+		if contains(authors, user.ID) {
 			continue
 		}
-		if !user.IsActive {
+		if !user.IsActive || user.Snoozed(now) {
 			continue
 		}
 		candidates = append(candidates, user)
@@ -147,13 +1968,79 @@ func filterReviewers(users []domain.User, authorID string) []domain.User {
 	return candidates
 }
 
-func filterForReplacement(users []domain.User, oldReviewerID string, assigned []string) []domain.User {
+// mandatoryReviewer returns the team's mandatory reviewer unless it is one
+// of the authors, inactive, snoozed or no longer among members.
+func mandatoryReviewer(settings domain.TeamSettings, members []domain.User, authors []string, now time.Time) (domain.User, bool) {
+	if settings.MandatoryReviewer == "" {
+		return domain.User{}, false
+	}
+	for _, user := range filterReviewers(members, authors, now) {
+		if user.ID == settings.MandatoryReviewer {
+			return user, true
+		}
+	}
+	return domain.User{}, false
+}
+
+// coolingDown returns the members to hold back for the author, mapped to
+// the filter holding them back: reviewers of the author's pull requests merged
+// within the team's review cooldown and, when the team avoids them, reviewers
+// of the author's last merged pull request.
+func (s *ReviewerService) coolingDown(ctx context.Context, settings domain.TeamSettings, authorID string, now time.Time) (map[string]string, error) {
+	cooling := make(map[string]string)
+	if settings.AvoidPreviousReviewers {
+		previous, err := s.repo.ListPreviousReviewers(ctx, authorID)
+		if err != nil {
+			return nil, err
+		}
+		for _, reviewerID := range previous {
+			cooling[reviewerID] = domain.FilterPrevious
+		}
+	}
+	if settings.ReviewCooldown > 0 {
+		recent, err := s.repo.ListRecentReviewers(ctx, authorID, now.Add(-settings.ReviewCooldown))
+		if err != nil {
+			return nil, err
+		}
+		for _, reviewerID := range recent {
+			cooling[reviewerID] = domain.FilterCooldown
+		}
+	}
+	return cooling, nil
+}
+
+// withinReviewLimit drops candidates who already hold the team's maximum
+// number of open reviews.
+func (s *ReviewerService) withinReviewLimit(ctx context.Context, settings domain.TeamSettings, candidates []domain.User) ([]domain.User, error) {
+	if settings.MaxOpenReviews == 0 || len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	ids := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		ids = append(ids, candidate.ID)
+	}
+	open, err := s.repo.CountOpenReviews(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]domain.User, 0, len(candidates))
+	for _, candidate := range candidates {
+		if open[candidate.ID] < settings.MaxOpenReviews {
+			result = append(result, candidate)
+		}
+	}
+	return result, nil
+}
+
+func filterForReplacement(users []domain.User, excluded []string, assigned []string, now time.Time) []domain.User {
 	candidates := make([]domain.User, 0, len(users))
 	for _, user := range users {
-		if user.ID == oldReviewerID {
+		if contains(excluded, user.ID) {
 			continue
 		}
-		if !user.IsActive {
+		if !user.IsActive || user.Snoozed(now) {
 			continue
 		}
 		if contains(assigned, user.ID) {
@@ -1,24 +1,40 @@
 package service_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"Avito2025/internal/config"
 	"Avito2025/internal/domain"
+	"Avito2025/internal/export"
+	"Avito2025/internal/notify"
 	"Avito2025/internal/service"
-	"Avito2025/internal/storage/postgres"
-
-	"github.com/testcontainers/testcontainers-go"
-	"github.com/testcontainers/testcontainers-go/wait"
+	"Avito2025/internal/storage"
+	"Avito2025/internal/storage/cache"
+	"Avito2025/internal/storage/storagetest"
+	"Avito2025/internal/warnings"
+	"Avito2025/internal/webhook"
 )
 
 func TestCreatePullRequestAssignsReviewers(t *testing.T) {
 	ctx := context.Background()
-	store := newTestStore(t, ctx)
-	defer store.Close()
-	svc := service.New(store)
+	svc := service.New(storagetest.New(t))
 
 	createTeam(t, ctx, svc, domain.Team{
 		Name: "backend",
@@ -50,9 +66,7 @@ func TestCreatePullRequestAssignsReviewers(t *testing.T) {
 
 func TestReassignReviewer(t *testing.T) {
 	ctx := context.Background()
-	store := newTestStore(t, ctx)
-	defer store.Close()
-	svc := service.New(store)
+	svc := service.New(storagetest.New(t))
 
 	createTeam(t, ctx, svc, domain.Team{
 		Name: "backend",
@@ -99,11 +113,136 @@ func TestReassignReviewer(t *testing.T) {
 	}
 }
 
+func TestConcurrentReassignmentsKeepReviewersDistinct(t *testing.T) {
+	ctx := context.Background()
+	repo := storagetest.New(t)
+	svc := service.New(repo)
+
+	// With a single spare member both reassignments want the same
+	// replacement; the one that loses picks again.
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+		},
+	})
+
+	for i := 0; i < 20; i++ {
+		pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: fmt.Sprintf("pr-race-%d", i), Name: "Race", AuthorID: "u1"})
+		if err != nil {
+			t.Fatalf("CreatePullRequest: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, len(pr.AssignedReviewers))
+		for j, reviewer := range pr.AssignedReviewers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, errs[j] = svc.ReassignReviewer(ctx, pr.ID, reviewer)
+			}()
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				t.Fatalf("ReassignReviewer: %v", err)
+			}
+		}
+
+		got, err := svc.GetPullRequest(ctx, pr.ID)
+		if err != nil {
+			t.Fatalf("GetPullRequest: %v", err)
+		}
+		if len(got.AssignedReviewers) != 2 || got.AssignedReviewers[0] == got.AssignedReviewers[1] {
+			t.Fatalf("expected two distinct reviewers, got %v", got.AssignedReviewers)
+		}
+	}
+
+	pr, err := svc.GetPullRequest(ctx, "pr-race-0")
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if _, err := repo.ReassignReviewer(ctx, pr.ID, pr.AssignedReviewers[0], pr.AssignedReviewers[1]); err != domain.ErrReassignConflict {
+		t.Fatalf("expected ErrReassignConflict for an assigned replacement, got %v", err)
+	}
+	if _, err := repo.ReassignReviewer(ctx, pr.ID, "ghost", "u4"); err != domain.ErrReviewerNotFound {
+		t.Fatalf("expected ErrReviewerNotFound for an unassigned reviewer, got %v", err)
+	}
+}
+
+func TestHotPullRequests(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: "u5", Username: "Eve", IsActive: true},
+		},
+	})
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-hot", Name: "Hot", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-calm", Name: "Calm", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		pr, _, err = svc.ReassignReviewer(ctx, pr.ID, pr.AssignedReviewers[0])
+		if err != nil {
+			t.Fatalf("ReassignReviewer: %v", err)
+		}
+	}
+	if pr.Reassignments != 3 {
+		t.Fatalf("expected 3 reassignments, got %d", pr.Reassignments)
+	}
+
+	hot, err := svc.ListHotPullRequests(ctx, "backend", 3)
+	if err != nil {
+		t.Fatalf("ListHotPullRequests: %v", err)
+	}
+	if len(hot) != 1 || hot[0].PullRequestID != "pr-hot" || hot[0].Reassignments != 3 {
+		t.Fatalf("expected only pr-hot with 3 reassignments, got %+v", hot)
+	}
+
+	// Editing the pull request keeps the count.
+	name := "Still hot"
+	updated, err := svc.UpdatePullRequest(ctx, pr.ID, domain.PullRequestUpdate{Name: &name}, false)
+	if err != nil {
+		t.Fatalf("UpdatePullRequest: %v", err)
+	}
+	if updated.Reassignments != 3 {
+		t.Fatalf("expected the update to keep 3 reassignments, got %d", updated.Reassignments)
+	}
+
+	// Merged pull requests are no longer hot.
+	if _, err := svc.MergePullRequest(ctx, pr.ID); err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
+	}
+	hot, err = svc.ListHotPullRequests(ctx, "", 1)
+	if err != nil {
+		t.Fatalf("ListHotPullRequests: %v", err)
+	}
+	if len(hot) != 0 {
+		t.Fatalf("expected no hot pull requests after the merge, got %+v", hot)
+	}
+
+	if _, err := svc.ListHotPullRequests(ctx, "ghosts", 1); !errors.Is(err, domain.ErrTeamNotFound) {
+		t.Fatalf("expected ErrTeamNotFound, got %v", err)
+	}
+}
+
 func TestMergePullRequestIdempotent(t *testing.T) {
 	ctx := context.Background()
-	store := newTestStore(t, ctx)
-	defer store.Close()
-	svc := service.New(store)
+	svc := service.New(storagetest.New(t))
 
 	createTeam(t, ctx, svc, domain.Team{
 		Name: "backend",
@@ -142,78 +281,4889 @@ func TestMergePullRequestIdempotent(t *testing.T) {
 	}
 }
 
-func newTestStore(t *testing.T, ctx context.Context) *postgres.Store {
-	t.Helper()
+func TestForceMergeRecordsBypass(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-hotfix", Name: "Hotfix", AuthorID: "u1", Status: domain.StatusDraft}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if _, err := svc.MergePullRequest(ctx, "pr-hotfix"); err != domain.ErrPRDraft {
+		t.Fatalf("expected ErrPRDraft without force, got %v", err)
+	}
+	if _, err := svc.ForceMergePullRequest(ctx, "pr-hotfix", "admin", "  "); err != domain.ErrInvalidForceMerge {
+		t.Fatalf("expected ErrInvalidForceMerge without a reason, got %v", err)
+	}
+
+	merged, err := svc.ForceMergePullRequest(ctx, "pr-hotfix", "admin", "production outage")
+	if err != nil || merged.Status != domain.StatusMerged {
+		t.Fatalf("ForceMergePullRequest: %+v, %v", merged, err)
+	}
+	again, err := svc.ForceMergePullRequest(ctx, "pr-hotfix", "admin", "production outage")
+	if err != nil || !again.MergedAt.Equal(*merged.MergedAt) {
+		t.Fatalf("expected a repeated force merge to return the merged pull request, got %+v, %v", again, err)
+	}
+
+	events, err := svc.ListChanges(ctx, 0, 100)
+	if err != nil {
+		t.Fatalf("ListChanges: %v", err)
+	}
+	var types []domain.EventType
+	var forced domain.Event
+	for _, event := range events {
+		if event.EntityID != "pr-hotfix" {
+			continue
+		}
+		types = append(types, event.Type)
+		if event.Type == domain.EventPRForceMerged {
+			forced = event
+		}
+	}
+	want := []domain.EventType{domain.EventPRCreated, domain.EventPRMerged, domain.EventPRForceMerged}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("expected events %v, got %v", want, types)
+	}
+	if forced.Payload["forced_by"] != "admin" || forced.Payload["reason"] != "production outage" ||
+		forced.Payload["status_before"] != string(domain.StatusDraft) {
+		t.Fatalf("unexpected force merge payload: %v", forced.Payload)
+	}
+}
+
+func TestConcurrentMergesAgree(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-110", Name: "Race", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+
+	const workers = 8
+	results := make([]domain.PullRequest, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = svc.MergePullRequest(ctx, "pr-110")
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatalf("MergePullRequest: %v", errs[i])
+		}
+		if !results[i].MergedAt.Equal(*results[0].MergedAt) {
+			t.Fatalf("merged_at differs between concurrent merges")
+		}
+	}
+
+	events, err := svc.ListChanges(ctx, 0, 100)
+	if err != nil {
+		t.Fatalf("ListChanges: %v", err)
+	}
+	merges := 0
+	for _, event := range events {
+		if event.Type == domain.EventPRMerged {
+			merges++
+		}
+	}
+	if merges != 1 {
+		t.Fatalf("expected one merge event, got %d", merges)
+	}
+}
 
-	postgresContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
-		ContainerRequest: testcontainers.ContainerRequest{
-			Image:        "postgres:15-alpine",
-			ExposedPorts: []string{"5432/tcp"},
-			Env: map[string]string{
-				"POSTGRES_USER":     "test",
-				"POSTGRES_PASSWORD": "test",
-				"POSTGRES_DB":       "test",
-			},
-			WaitingFor: wait.ForLog("database system is ready to accept connections").
-				WithOccurrence(2).
-				WithStartupTimeout(30 * time.Second),
+func TestListChangesReturnsOrderedEvents(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
 		},
-		Started: true,
 	})
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{
+		ID:       "pr-4",
+		Name:     "Feed",
+		AuthorID: "u1",
+	})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if _, err := svc.MergePullRequest(ctx, pr.ID); err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
+	}
+
+	events, err := svc.ListChanges(ctx, 0, 100)
 	if err != nil {
-		t.Fatalf("failed to start postgres container: %v", err)
+		t.Fatalf("ListChanges: %v", err)
 	}
 
-	t.Cleanup(func() {
-		if err := postgresContainer.Terminate(ctx); err != nil {
-			t.Logf("failed to terminate postgres container: %v", err)
+	expected := []domain.EventType{domain.EventTeamCreated, domain.EventPRCreated, domain.EventPRMerged}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i, event := range events {
+		if event.Type != expected[i] {
+			t.Fatalf("event %d: expected %s, got %s", i, expected[i], event.Type)
+		}
+		if i > 0 && event.Seq <= events[i-1].Seq {
+			t.Fatalf("sequence is not increasing: %d after %d", event.Seq, events[i-1].Seq)
 		}
+	}
+
+	tail, err := svc.ListChanges(ctx, events[0].Seq, 100)
+	if err != nil {
+		t.Fatalf("ListChanges since: %v", err)
+	}
+	if len(tail) != len(expected)-1 {
+		t.Fatalf("expected %d events after cursor, got %d", len(expected)-1, len(tail))
+	}
+}
+
+func TestBulkCreatePullRequestsReportsPerItem(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Charlie", IsActive: true},
+		},
 	})
 
-	host, err := postgresContainer.Host(ctx)
+	results, err := svc.BulkCreatePullRequests(ctx, []domain.PullRequest{
+		{ID: "pr-10", Name: "First", AuthorID: "u1"},
+		{ID: "pr-10", Name: "Duplicate", AuthorID: "u2"},
+		{ID: "pr-11", Name: "Unknown author", AuthorID: "ghost"},
+		{ID: "pr-12", Name: "Second", AuthorID: "u3"},
+	})
 	if err != nil {
-		t.Fatalf("failed to get postgres host: %v", err)
+		t.Fatalf("BulkCreatePullRequests: %v", err)
+	}
+
+	expected := []error{nil, domain.ErrPRExists, domain.ErrUserNotFound, nil}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+	for i, result := range results {
+		if result.Err != expected[i] {
+			t.Fatalf("result %d: expected %v, got %v", i, expected[i], result.Err)
+		}
+		if result.Err == nil && len(result.PullRequest.AssignedReviewers) != 2 {
+			t.Fatalf("result %d: expected 2 reviewers, got %+v", i, result.PullRequest.AssignedReviewers)
+		}
+	}
+}
+
+func TestBulkMergePullRequestsReportsPerItem(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Charlie", IsActive: true},
+		},
+	})
+	for _, pr := range []domain.PullRequest{
+		{ID: "pr-1", Name: "First", AuthorID: "u1"},
+		{ID: "pr-2", Name: "Second", AuthorID: "u2"},
+		{ID: "pr-draft", Name: "Draft", AuthorID: "u3", Status: domain.StatusDraft},
+	} {
+		if _, err := svc.CreatePullRequest(ctx, pr); err != nil {
+			t.Fatalf("CreatePullRequest %s: %v", pr.ID, err)
+		}
+	}
+	if _, err := svc.MergePullRequest(ctx, "pr-2"); err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
 	}
 
-	port, err := postgresContainer.MappedPort(ctx, "5432")
+	results, err := svc.BulkMergePullRequests(ctx, []string{"pr-1", "pr-2", "ghost", "pr-draft"})
 	if err != nil {
-		t.Fatalf("failed to get postgres port: %v", err)
+		t.Fatalf("BulkMergePullRequests: %v", err)
 	}
 
-	pgConfig := config.PostgresConfig{
-		Host:     host,
-		Port:     port.Port(),
-		User:     "test",
-		Password: "test",
-		DBName:   "test",
-		SSLMode:  "disable",
-		MaxConns: 4,
+	expected := []error{nil, nil, domain.ErrPullRequestNotFound, domain.ErrPRDraft}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+	for i, result := range results {
+		if result.Err != expected[i] {
+			t.Fatalf("result %d: expected %v, got %v", i, expected[i], result.Err)
+		}
+		if result.Err == nil && result.PullRequest.Status != domain.StatusMerged {
+			t.Fatalf("result %d: expected MERGED, got %s", i, result.PullRequest.Status)
+		}
+	}
+	if results[2].PullRequest.ID != "ghost" {
+		t.Fatalf("expected the failed result to keep its ID, got %q", results[2].PullRequest.ID)
 	}
 
-	store, err := postgres.New(ctx, pgConfig)
+	// The already merged pull request is not merged again.
+	events, err := svc.ListChanges(ctx, 0, 100)
 	if err != nil {
-		t.Fatalf("failed to create postgres store: %v", err)
+		t.Fatalf("ListChanges: %v", err)
+	}
+	merges := 0
+	for _, event := range events {
+		if event.Type == domain.EventPRMerged {
+			merges++
+		}
+	}
+	if merges != 2 {
+		t.Fatalf("expected 2 merge events, got %d", merges)
 	}
+}
+
+func TestBulkMergePullRequestsReportsStorageErrorsPerItem(t *testing.T) {
+	ctx := context.Background()
+	repo := &faultyRepository{Repository: storagetest.New(t), failing: "pr-2"}
+	svc := service.New(repo)
 
-	t.Cleanup(func() {
-		store.Close()
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
 	})
+	for _, id := range []string{"pr-1", "pr-2", "pr-3"} {
+		if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: id, Name: id, AuthorID: "u1"}); err != nil {
+			t.Fatalf("CreatePullRequest %s: %v", id, err)
+		}
+	}
 
-	return store
+	results, err := svc.BulkMergePullRequests(ctx, []string{"pr-1", "pr-2", "pr-3"})
+	if err != nil {
+		t.Fatalf("BulkMergePullRequests: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if !errors.Is(results[1].Err, errStorageFault) || results[1].PullRequest.ID != "pr-2" {
+		t.Fatalf("expected the storage fault on pr-2, got %+v", results[1])
+	}
+	for _, i := range []int{0, 2} {
+		if results[i].Err != nil || results[i].PullRequest.Status != domain.StatusMerged {
+			t.Fatalf("result %d: expected MERGED, got %+v", i, results[i])
+		}
+	}
 }
 
-func createTeam(t *testing.T, ctx context.Context, svc service.Service, team domain.Team) {
-	t.Helper()
-	if _, err := svc.CreateTeam(ctx, team); err != nil {
-		t.Fatalf("CreateTeam: %v", err)
+func TestCreatePullRequestStoresDecisionInItsTransaction(t *testing.T) {
+	ctx := context.Background()
+	repo := &transactionRepository{Repository: storagetest.New(t)}
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	repo.transactions = 0
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-1", Name: "Feature", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if repo.outside != nil {
+		t.Fatalf("expected every write of the creation in its transaction, got %v outside", repo.outside)
+	}
+	if repo.transactions != 1 {
+		t.Fatalf("expected one transaction, got %d", repo.transactions)
+	}
+
+	events, stop := svc.SubscribeEvents(nil)
+	defer stop()
+	repo.failDecision = true
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-2", Name: "Fix", AuthorID: "u1"}); !errors.Is(err, errStorageFault) {
+		t.Fatalf("expected the decision write to fail the creation, got %v", err)
+	}
+	if !errors.Is(repo.rolledBack, errStorageFault) {
+		t.Fatalf("expected the transaction to be rolled back, got %v", repo.rolledBack)
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("expected no event for a rolled back creation, got %+v", event)
+	default:
 	}
 }
 
-func contains(items []string, target string) bool {
-	for _, item := range items {
-		if item == target {
-			return true
+func TestEventsAreStoredInTheTransactionOfTheirChange(t *testing.T) {
+	ctx := context.Background()
+	repo := &transactionRepository{Repository: storagetest.New(t)}
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+		},
+	})
+	for _, id := range []string{"pr-1", "pr-2"} {
+		if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: id, Name: id, AuthorID: "u1", ReviewersCount: 1}); err != nil {
+			t.Fatalf("CreatePullRequest %s: %v", id, err)
 		}
 	}
-	return false
+	name := "Renamed"
+	if _, err := svc.UpdatePullRequest(ctx, "pr-1", domain.PullRequestUpdate{Name: &name}, false); err != nil {
+		t.Fatalf("UpdatePullRequest: %v", err)
+	}
+	if _, err := svc.LinkPullRequests(ctx, "pr-1", "pr-2"); err != nil {
+		t.Fatalf("LinkPullRequests: %v", err)
+	}
+	created, err := svc.GetPullRequest(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	pr, reviewer, err := svc.ReassignReviewer(ctx, "pr-1", created.AssignedReviewers[0])
+	if err != nil {
+		t.Fatalf("ReassignReviewer: %v", err)
+	}
+	if _, err := svc.RecordReview(ctx, pr.ID, reviewer, domain.ReviewApproval); err != nil {
+		t.Fatalf("RecordReview: %v", err)
+	}
+	if _, err := svc.CompleteReview(ctx, pr.ID, reviewer); err != nil {
+		t.Fatalf("CompleteReview: %v", err)
+	}
+	if _, err := svc.MergePullRequest(ctx, "pr-2"); err != nil {
+		t.Fatalf("MergePullRequest pr-2: %v", err)
+	}
+	if _, err := svc.MergePullRequest(ctx, "pr-1"); err != nil {
+		t.Fatalf("MergePullRequest pr-1: %v", err)
+	}
+
+	if repo.outside != nil {
+		t.Fatalf("expected every event stored with its change, got %v outside a transaction", repo.outside)
+	}
+	events, err := svc.ListChanges(ctx, 0, 100)
+	if err != nil {
+		t.Fatalf("ListChanges: %v", err)
+	}
+	if len(events) < 9 {
+		t.Fatalf("expected the events of every change, got %d", len(events))
+	}
+}
+
+func TestCreatePullRequestByBotAuthor(t *testing.T) {
+	ctx := context.Background()
+	repo := storagetest.New(t)
+	svc := service.New(repo)
+	svc.SetBotTeams(map[string]string{"dependabot": "backend"})
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	createTeam(t, ctx, svc, domain.Team{
+		Name:    "frontend",
+		Members: []domain.User{{ID: "u3", Username: "Cathy", IsActive: true}},
+	})
+
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-ghost", Name: "Ghost", AuthorID: "ghost"}); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound for an unmapped author, got %v", err)
+	}
+
+	// A mapped bot is reviewed by its team and never reviews itself.
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-bump", Name: "Bump deps", AuthorID: "dependabot"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 2 || !contains(pr.AssignedReviewers, "u1") || !contains(pr.AssignedReviewers, "u2") {
+		t.Fatalf("expected backend to review the bot, got %v", pr.AssignedReviewers)
+	}
+	bot, err := repo.GetUser(ctx, "dependabot")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if bot.TeamName != "backend" || bot.IsActive {
+		t.Fatalf("expected an inactive backend member, got %+v", bot)
+	}
+
+	// A team does not register an author that is not a configured bot.
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-ci", Name: "CI", AuthorID: "ci-bot", TeamName: "frontend"}); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound for an unmapped author with a team, got %v", err)
+	}
+	if _, err := repo.GetUser(ctx, "ci-bot"); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected the unmapped author not to be registered, got %v", err)
+	}
+	frontend, err := svc.GetTeam(ctx, "frontend")
+	if err != nil || len(frontend.Members) != 1 {
+		t.Fatalf("expected frontend to keep its one member, got %+v, %v", frontend, err)
+	}
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-dep", Name: "Deps", AuthorID: "dependabot", TeamName: "frontend"}); !errors.Is(err, domain.ErrInvalidAuthorTeam) {
+		t.Fatalf("expected ErrInvalidAuthorTeam for a bot and another team, got %v", err)
+	}
+
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-other", Name: "Other", AuthorID: "u1", TeamName: "frontend"}); !errors.Is(err, domain.ErrInvalidAuthorTeam) {
+		t.Fatalf("expected ErrInvalidAuthorTeam for another team of a known author, got %v", err)
+	}
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-lost", Name: "Lost", AuthorID: "lost-bot", TeamName: "missing"}); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestNeedsMoreReviewers(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+
+	short, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-short", Name: "Short", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if !short.NeedsMoreReviewers || len(short.AssignedReviewers) != 1 {
+		t.Fatalf("expected one reviewer flagged as short, got %v", short)
+	}
+	single, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-single", Name: "Single", AuthorID: "u1", ReviewersCount: 1})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if single.NeedsMoreReviewers {
+		t.Fatalf("a pull request asking for one reviewer is not short, got %+v", single)
+	}
+	if pr, err := svc.GetPullRequest(ctx, "pr-short"); err != nil || !pr.NeedsMoreReviewers {
+		t.Fatalf("expected the flag on reads, got %+v, %v", pr, err)
+	}
+
+	prs, err := svc.ListUserReviews(ctx, "u2", domain.ReviewFilter{NeedsMoreReviewers: true})
+	if err != nil {
+		t.Fatalf("ListUserReviews: %v", err)
+	}
+	if len(prs) != 1 || prs[0].ID != "pr-short" || !prs[0].NeedsMoreReviewers {
+		t.Fatalf("expected only pr-short, got %+v", prs)
+	}
+
+	merged, err := svc.MergePullRequest(ctx, "pr-short")
+	if err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
+	}
+	if merged.NeedsMoreReviewers {
+		t.Fatalf("a merged pull request needs no more reviewers")
+	}
+	prs, err = svc.ListUserReviews(ctx, "u2", domain.ReviewFilter{NeedsMoreReviewers: true})
+	if err != nil {
+		t.Fatalf("ListUserReviews: %v", err)
+	}
+	if len(prs) != 0 {
+		t.Fatalf("expected no short pull requests after the merge, got %+v", prs)
+	}
+}
+
+func TestReviewAcceptance(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Carol", IsActive: true},
+			{ID: "u4", Username: "Dave", IsActive: false},
+		},
+	})
+	settings, err := svc.GetTeamSettings(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	settings.AcceptTimeout = time.Hour
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-1", Name: "Feature", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	for _, reviewer := range pr.AssignedReviewers {
+		if pr.Acceptance[reviewer] != domain.AcceptancePending {
+			t.Fatalf("expected %s pending, got %v", reviewer, pr.Acceptance)
+		}
+	}
+	accepting, idle := pr.AssignedReviewers[0], pr.AssignedReviewers[1]
+
+	accepted, err := svc.AcceptReview(ctx, "pr-1", accepting)
+	if err != nil {
+		t.Fatalf("AcceptReview: %v", err)
+	}
+	if accepted.Acceptance[accepting] != domain.AcceptanceAccepted || accepted.Acceptance[idle] != domain.AcceptancePending {
+		t.Fatalf("expected only %s accepted, got %v", accepting, accepted.Acceptance)
+	}
+	if _, err := svc.AcceptReview(ctx, "pr-1", accepting); err != nil {
+		t.Fatalf("accepting twice: %v", err)
+	}
+	if _, err := svc.AcceptReview(ctx, "pr-1", "u1"); !errors.Is(err, domain.ErrReviewerNotFound) {
+		t.Fatalf("expected ErrReviewerNotFound for a non-reviewer, got %v", err)
+	}
+
+	if err := svc.ProcessExpiredAcceptances(ctx, time.Now().UTC()); err != nil {
+		t.Fatalf("ProcessExpiredAcceptances: %v", err)
+	}
+	if pr, err := svc.GetPullRequest(ctx, "pr-1"); err != nil || !contains(pr.AssignedReviewers, idle) {
+		t.Fatalf("expected %s kept before the timeout, got %+v, %v", idle, pr, err)
+	}
+
+	// With the author away only u4, back after the pull request was
+	// created, can replace the idle reviewer.
+	if _, err := svc.SnoozeUser(ctx, "u1", 3*time.Hour); err != nil {
+		t.Fatalf("SnoozeUser: %v", err)
+	}
+	if _, err := svc.SetUserActive(ctx, "u4", true); err != nil {
+		t.Fatalf("SetUserActive: %v", err)
+	}
+	if err := svc.ProcessExpiredAcceptances(ctx, time.Now().UTC().Add(2*time.Hour)); err != nil {
+		t.Fatalf("ProcessExpiredAcceptances: %v", err)
+	}
+	pr, err = svc.GetPullRequest(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if contains(pr.AssignedReviewers, idle) || !contains(pr.AssignedReviewers, accepting) || !contains(pr.AssignedReviewers, "u4") {
+		t.Fatalf("expected %s replaced by u4 and %s kept, got %v", idle, accepting, pr.AssignedReviewers)
+	}
+	for _, reviewer := range pr.AssignedReviewers {
+		want := domain.AcceptancePending
+		if reviewer == accepting {
+			want = domain.AcceptanceAccepted
+		}
+		if pr.Acceptance[reviewer] != want {
+			t.Fatalf("expected %s %s, got %v", reviewer, want, pr.Acceptance)
+		}
+	}
+
+	report, err := svc.AcceptanceReport(ctx, "backend", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("AcceptanceReport: %v", err)
+	}
+	if report.Requested != 3 || report.Accepted != 1 || report.Expired != 1 || report.Pending != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
+
+func TestExpiredAcceptancesRetryFailedReplacements(t *testing.T) {
+	ctx := context.Background()
+	repo := &faultyRepository{Repository: storagetest.New(t), failing: "pr-2"}
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Carol", IsActive: true},
+			{ID: "u4", Username: "Dave", IsActive: true},
+			{ID: "u5", Username: "Erin", IsActive: true},
+		},
+	})
+	settings, err := svc.GetTeamSettings(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	settings.AcceptTimeout = time.Hour
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+	assigned := make(map[string][]string)
+	for _, id := range []string{"pr-2", "pr-3"} {
+		pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: id, Name: "Feature", AuthorID: "u1"})
+		if err != nil {
+			t.Fatalf("CreatePullRequest: %v", err)
+		}
+		assigned[id] = pr.AssignedReviewers
+	}
+
+	later := time.Now().UTC().Add(2 * time.Hour)
+	if err := svc.ProcessExpiredAcceptances(ctx, later); err != nil {
+		t.Fatalf("ProcessExpiredAcceptances: %v", err)
+	}
+	pr, err := svc.GetPullRequest(ctx, "pr-3")
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if pr.Reassignments != 2 {
+		t.Fatalf("expected both idle reviewers of pr-3 replaced past the failing pr-2, got %d reassignments", pr.Reassignments)
+	}
+
+	// The failed replacements are pending again and go through once the
+	// fault is gone.
+	pr, err = repo.Repository.GetPullRequest(ctx, "pr-2")
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	for _, reviewer := range assigned["pr-2"] {
+		if pr.Acceptance[reviewer] != domain.AcceptancePending {
+			t.Fatalf("expected %s pending again on pr-2, got %v", reviewer, pr.Acceptance)
+		}
+	}
+	repo.failing = ""
+	if err := svc.ProcessExpiredAcceptances(ctx, later); err != nil {
+		t.Fatalf("ProcessExpiredAcceptances: %v", err)
+	}
+	// A replaced reviewer may come back as the replacement of the other one,
+	// so the reassignments are counted rather than the reviewers compared.
+	if pr, _ = svc.GetPullRequest(ctx, "pr-2"); pr.Reassignments != 2 {
+		t.Fatalf("expected both idle reviewers of pr-2 replaced on the next run, got %d reassignments", pr.Reassignments)
+	}
+}
+
+func TestCompleteReview(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Carol", IsActive: true},
+			{ID: "u4", Username: "Dave", IsActive: true},
+		},
+	})
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-1", Name: "Feature", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	done, other := pr.AssignedReviewers[0], pr.AssignedReviewers[1]
+
+	completed, err := svc.CompleteReview(ctx, "pr-1", done)
+	if err != nil {
+		t.Fatalf("CompleteReview: %v", err)
+	}
+	if !reflect.DeepEqual(completed.CompletedReviewers, []string{done}) {
+		t.Fatalf("expected only %s completed, got %v", done, completed.CompletedReviewers)
+	}
+	if _, err := svc.CompleteReview(ctx, "pr-1", done); err != nil {
+		t.Fatalf("completing twice: %v", err)
+	}
+	if _, err := svc.CompleteReview(ctx, "pr-1", "u1"); !errors.Is(err, domain.ErrReviewerNotFound) {
+		t.Fatalf("expected ErrReviewerNotFound for a non-reviewer, got %v", err)
+	}
+
+	todo := func(userID string) int {
+		t.Helper()
+		prs, err := svc.ListUserReviews(ctx, userID, domain.ReviewFilter{Status: domain.StatusOpen, ExcludeCompleted: true})
+		if err != nil {
+			t.Fatalf("ListUserReviews: %v", err)
+		}
+		return len(prs)
+	}
+	if todo(done) != 0 || todo(other) != 1 {
+		t.Fatalf("expected pr-1 only on %s's to-do list", other)
+	}
+	if prs, err := svc.ListUserReviews(ctx, done, domain.ReviewFilter{}); err != nil || len(prs) != 1 {
+		t.Fatalf("expected pr-1 listed without the filter, got %v, %v", prs, err)
+	}
+
+	// Taken off and assigned again, the reviewer starts over.
+	if _, _, err := svc.ReassignReviewer(ctx, "pr-1", done); err != nil {
+		t.Fatalf("ReassignReviewer: %v", err)
+	}
+	reassigned, err := svc.AssignReviewers(ctx, "pr-1", []string{done, other})
+	if err != nil {
+		t.Fatalf("AssignReviewers: %v", err)
+	}
+	if reassigned.CompletedReviewers != nil || todo(done) != 1 {
+		t.Fatalf("expected the completion dropped, got %v", reassigned.CompletedReviewers)
+	}
+}
+
+func TestPullRequestLinks(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Carol", IsActive: true},
+		},
+	})
+	for _, id := range []string{"pr-1", "pr-2", "pr-3"} {
+		if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: id, Name: id, AuthorID: "u1"}); err != nil {
+			t.Fatalf("CreatePullRequest %s: %v", id, err)
+		}
+	}
+
+	// pr-1 waits for pr-2, which waits for pr-3.
+	linked, err := svc.LinkPullRequests(ctx, "pr-1", "pr-2")
+	if err != nil {
+		t.Fatalf("LinkPullRequests: %v", err)
+	}
+	if !reflect.DeepEqual(linked.BlockedBy, []string{"pr-2"}) {
+		t.Fatalf("expected pr-1 blocked by pr-2, got %v", linked.BlockedBy)
+	}
+	if _, err := svc.LinkPullRequests(ctx, "pr-1", "pr-2"); err != nil {
+		t.Fatalf("linking twice: %v", err)
+	}
+	if _, err := svc.LinkPullRequests(ctx, "pr-2", "pr-3"); err != nil {
+		t.Fatalf("LinkPullRequests: %v", err)
+	}
+	if _, err := svc.LinkPullRequests(ctx, "pr-3", "pr-1"); !errors.Is(err, domain.ErrDependencyCycle) {
+		t.Fatalf("expected ErrDependencyCycle, got %v", err)
+	}
+	if _, err := svc.LinkPullRequests(ctx, "pr-1", "pr-1"); !errors.Is(err, domain.ErrInvalidLink) {
+		t.Fatalf("expected ErrInvalidLink, got %v", err)
+	}
+	if _, err := svc.LinkPullRequests(ctx, "pr-1", "missing"); !errors.Is(err, domain.ErrPullRequestNotFound) {
+		t.Fatalf("expected ErrPullRequestNotFound, got %v", err)
+	}
+
+	// With two other members, every pull request has the same reviewers.
+	isBlocked := true
+	blocked, err := svc.ListUserReviews(ctx, linked.AssignedReviewers[0], domain.ReviewFilter{Blocked: &isBlocked})
+	if err != nil {
+		t.Fatalf("ListUserReviews: %v", err)
+	}
+	var ids []string
+	for _, pr := range blocked {
+		ids = append(ids, pr.ID)
+	}
+	sort.Strings(ids)
+	if !reflect.DeepEqual(ids, []string{"pr-1", "pr-2"}) {
+		t.Fatalf("expected pr-1 and pr-2 listed as blocked, got %v", ids)
+	}
+
+	settings, err := svc.GetTeamSettings(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	settings.BlockMergeOnDependencies = true
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+	var domainErr *domain.Error
+	if _, err := svc.MergePullRequest(ctx, "pr-1"); !errors.As(err, &domainErr) || domainErr.Code != "PR_BLOCKED" ||
+		!reflect.DeepEqual(domainErr.Details["blocked_by"], []string{"pr-2"}) {
+		t.Fatalf("expected PR_BLOCKED by pr-2, got %v", err)
+	}
+
+	// Unlinking pr-3 and merging pr-2 unblock pr-1.
+	if _, err := svc.UnlinkPullRequests(ctx, "pr-2", "pr-3"); err != nil {
+		t.Fatalf("UnlinkPullRequests: %v", err)
+	}
+	if _, err := svc.MergePullRequest(ctx, "pr-2"); err != nil {
+		t.Fatalf("MergePullRequest pr-2: %v", err)
+	}
+	merged, err := svc.MergePullRequest(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("MergePullRequest pr-1: %v", err)
+	}
+	if merged.BlockedBy != nil {
+		t.Fatalf("expected no blockers left, got %v", merged.BlockedBy)
+	}
+	if _, err := svc.LinkPullRequests(ctx, "pr-3", "pr-1"); !errors.Is(err, domain.ErrPRMerged) {
+		t.Fatalf("expected ErrPRMerged for a merged blocker, got %v", err)
+	}
+}
+
+func TestClockDrivesMergeAndAcceptanceDeadlines(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)}
+	start := clock.Now()
+	svc := service.New(storagetest.New(t), service.WithClock(clock))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Carol", IsActive: true},
+		},
+	})
+	settings, err := svc.GetTeamSettings(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	settings.RequiredReviewers = 1
+	settings.AcceptTimeout = time.Hour
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-1", Name: "Feature", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if !pr.CreatedAt.Equal(start) {
+		t.Fatalf("expected createdAt %v, got %v", start, pr.CreatedAt)
+	}
+	reviewer := pr.AssignedReviewers[0]
+
+	if err := svc.ProcessExpiredAcceptances(ctx, start.Add(time.Hour-time.Second)); err != nil {
+		t.Fatalf("ProcessExpiredAcceptances: %v", err)
+	}
+	if pr, err := svc.GetPullRequest(ctx, "pr-1"); err != nil || pr.Acceptance[reviewer] != domain.AcceptancePending {
+		t.Fatalf("expected %s still pending a second before the deadline, got %+v, %v", reviewer, pr, err)
+	}
+	if err := svc.ProcessExpiredAcceptances(ctx, start.Add(time.Hour)); err != nil {
+		t.Fatalf("ProcessExpiredAcceptances: %v", err)
+	}
+	if pr, err := svc.GetPullRequest(ctx, "pr-1"); err != nil || contains(pr.AssignedReviewers, reviewer) {
+		t.Fatalf("expected %s replaced at the deadline, got %+v, %v", reviewer, pr, err)
+	}
+
+	clock.Advance(2 * time.Hour)
+	merged, err := svc.MergePullRequest(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
+	}
+	mergedAt := start.Add(2 * time.Hour)
+	if merged.MergedAt == nil || !merged.MergedAt.Equal(mergedAt) {
+		t.Fatalf("expected mergedAt %v, got %v", mergedAt, merged.MergedAt)
+	}
+	clock.Advance(time.Hour)
+	again, err := svc.MergePullRequest(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("second MergePullRequest: %v", err)
+	}
+	if again.MergedAt == nil || !again.MergedAt.Equal(mergedAt) {
+		t.Fatalf("expected a repeated merge to keep mergedAt %v, got %v", mergedAt, again.MergedAt)
+	}
+}
+
+func TestListUserReviewsFiltersAndSorts(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)}
+	svc := service.New(storagetest.New(t), service.WithClock(clock))
+
+	// u2 is the only possible reviewer, so it reviews every pull request.
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	for _, id := range []string{"pr-a", "pr-b", "pr-c"} {
+		if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: id, Name: id, AuthorID: "u1"}); err != nil {
+			t.Fatalf("CreatePullRequest %s: %v", id, err)
+		}
+		clock.Advance(time.Hour)
+	}
+	// pr-c is merged before pr-a, the reverse of their creation order.
+	for _, id := range []string{"pr-c", "pr-a"} {
+		if _, err := svc.MergePullRequest(ctx, id); err != nil {
+			t.Fatalf("MergePullRequest %s: %v", id, err)
+		}
+		clock.Advance(time.Hour)
+	}
+
+	tests := []struct {
+		name   string
+		filter domain.ReviewFilter
+		want   []string
+	}{
+		{name: "oldest first", filter: domain.ReviewFilter{}, want: []string{"pr-a", "pr-b", "pr-c"}},
+		{name: "newest first", filter: domain.ReviewFilter{Descending: true}, want: []string{"pr-c", "pr-b", "pr-a"}},
+		{name: "open", filter: domain.ReviewFilter{Status: domain.StatusOpen}, want: []string{"pr-b"}},
+		{name: "merged by merge time", filter: domain.ReviewFilter{Status: domain.StatusMerged, SortBy: domain.SortByMergedAt}, want: []string{"pr-c", "pr-a"}},
+		{name: "merged latest first", filter: domain.ReviewFilter{Status: domain.StatusMerged, SortBy: domain.SortByMergedAt, Descending: true}, want: []string{"pr-a", "pr-c"}},
+		{name: "unmerged last", filter: domain.ReviewFilter{SortBy: domain.SortByMergedAt, Descending: true}, want: []string{"pr-a", "pr-c", "pr-b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prs, err := svc.ListUserReviews(ctx, "u2", tt.filter)
+			if err != nil {
+				t.Fatalf("ListUserReviews: %v", err)
+			}
+			got := make([]string, 0, len(prs))
+			for _, pr := range prs {
+				got = append(got, pr.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWebhookSecretFromIDGenerator(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t), service.WithIDGenerator(service.IDGeneratorFunc(func() (string, error) {
+		return "fixed-secret", nil
+	})))
+
+	created, err := svc.CreateWebhook(ctx, domain.Webhook{
+		URL:    "http://example.com/hook",
+		Events: []domain.WebhookEvent{domain.WebhookPRCreated},
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	if created.Secret != "fixed-secret" {
+		t.Fatalf("expected the generated secret, got %q", created.Secret)
+	}
+
+	given, err := svc.CreateWebhook(ctx, domain.Webhook{
+		URL:    "http://example.com/other",
+		Secret: "own-secret",
+		Events: []domain.WebhookEvent{domain.WebhookPRCreated},
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	if given.Secret != "own-secret" {
+		t.Fatalf("expected the given secret kept, got %q", given.Secret)
+	}
+}
+
+func TestTeamTokenScopesAuthors(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t), service.WithIDGenerator(service.IDGeneratorFunc(func() (string, error) {
+		return "fixed", nil
+	})))
+	svc.SetBotTeams(map[string]string{"dependabot": "backend", "renovate": "frontend"})
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name:    "backend",
+		Members: []domain.User{{ID: "u1", Username: "Alice", IsActive: true}},
+	})
+	createTeam(t, ctx, svc, domain.Team{
+		Name:    "frontend",
+		Members: []domain.User{{ID: "u2", Username: "Bob", IsActive: true}},
+	})
+
+	token, err := svc.CreateTeamToken(ctx, "backend", "ci", "")
+	if err != nil {
+		t.Fatalf("CreateTeamToken: %v", err)
+	}
+	if token.Token != "rvt_fixed" || token.Hash != service.HashTeamToken("rvt_fixed") {
+		t.Fatalf("expected the generated token and its hash, got %+v", token)
+	}
+	if _, err := svc.CreateTeamToken(ctx, "missing", "ci", ""); !errors.Is(err, domain.ErrTeamNotFound) {
+		t.Fatalf("expected ErrTeamNotFound, got %v", err)
+	}
+
+	own := []domain.PullRequest{{AuthorID: "u1"}, {AuthorID: "dependabot"}}
+	if err := svc.AuthorizeTeamToken(ctx, token.Hash, own); err != nil {
+		t.Fatalf("expected members and mapped bots of the team allowed, got %v", err)
+	}
+	if err := svc.AuthorizeTeamToken(ctx, token.Hash, []domain.PullRequest{{AuthorID: "u2"}}); !errors.Is(err, domain.ErrTokenTeamMismatch) {
+		t.Fatalf("expected ErrTokenTeamMismatch for another team's member, got %v", err)
+	}
+	if err := svc.AuthorizeTeamToken(ctx, token.Hash, []domain.PullRequest{{AuthorID: "renovate"}}); !errors.Is(err, domain.ErrTokenTeamMismatch) {
+		t.Fatalf("expected ErrTokenTeamMismatch for a bot of another team, got %v", err)
+	}
+
+	tokens, err := svc.ListTeamTokens(ctx, "backend")
+	if err != nil || len(tokens) != 1 || tokens[0].Token != "" {
+		t.Fatalf("expected one listed token without its secret, got %+v, %v", tokens, err)
+	}
+	if err := svc.DeleteTeamToken(ctx, token.ID); err != nil {
+		t.Fatalf("DeleteTeamToken: %v", err)
+	}
+	if err := svc.AuthorizeTeamToken(ctx, token.Hash, own); !errors.Is(err, domain.ErrTeamTokenNotFound) {
+		t.Fatalf("expected a revoked token unknown, got %v", err)
+	}
+}
+
+func TestTeamTokenIssuedToMemberIdentifiesIt(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name:    "backend",
+		Members: []domain.User{{ID: "u1", Username: "Alice", IsActive: true}},
+	})
+	createTeam(t, ctx, svc, domain.Team{
+		Name:    "frontend",
+		Members: []domain.User{{ID: "u2", Username: "Bob", IsActive: true}},
+	})
+
+	if _, err := svc.CreateTeamToken(ctx, "backend", "laptop", "u2"); !errors.Is(err, domain.ErrInvalidTokenUser) {
+		t.Fatalf("expected ErrInvalidTokenUser for another team's member, got %v", err)
+	}
+	if _, err := svc.CreateTeamToken(ctx, "backend", "laptop", "ghost"); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+
+	personal, err := svc.CreateTeamToken(ctx, "backend", "laptop", "u1")
+	if err != nil {
+		t.Fatalf("CreateTeamToken: %v", err)
+	}
+	if callerID, err := svc.TeamTokenUser(ctx, personal.Hash); err != nil || callerID != "u1" {
+		t.Fatalf("expected the token to identify u1, got %q, %v", callerID, err)
+	}
+
+	ci, err := svc.CreateTeamToken(ctx, "backend", "ci", "")
+	if err != nil {
+		t.Fatalf("CreateTeamToken: %v", err)
+	}
+	if _, err := svc.TeamTokenUser(ctx, ci.Hash); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden for a token issued to no one, got %v", err)
+	}
+	if _, err := svc.TeamTokenUser(ctx, service.HashTeamToken("rvt_unknown")); !errors.Is(err, domain.ErrTeamTokenNotFound) {
+		t.Fatalf("expected ErrTeamTokenNotFound, got %v", err)
+	}
+}
+
+func TestLoadForecast(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)}
+	svc := service.New(storagetest.New(t), service.WithClock(clock))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Carol", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: false},
+		},
+	})
+	for _, pr := range []domain.PullRequest{
+		{ID: "pr-1", Name: "One", AuthorID: "u1"},
+		{ID: "pr-2", Name: "Two", AuthorID: "u1"},
+		{ID: "pr-3", Name: "Three", AuthorID: "u2"},
+	} {
+		if _, err := svc.CreatePullRequest(ctx, pr); err != nil {
+			t.Fatalf("CreatePullRequest %s: %v", pr.ID, err)
+		}
+	}
+	clock.Advance(time.Hour)
+	// Carol is away for the first half of the coming week.
+	if _, err := svc.SnoozeUser(ctx, "u3", 84*time.Hour); err != nil {
+		t.Fatalf("SnoozeUser: %v", err)
+	}
+
+	forecast, err := svc.LoadForecast(ctx, "backend", 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("LoadForecast: %v", err)
+	}
+	// With two reviewers per pull request, Alice's two go to Bob and half of
+	// Carol, Bob's one to Alice and half of Carol; half a slot of each is
+	// left unfilled.
+	near := func(got, want float64) bool { return got > want-1e-9 && got < want+1e-9 }
+	if !near(forecast.PullRequests, 3) || !near(forecast.Unfilled, 1.5) {
+		t.Fatalf("expected 3 pull requests and 1.5 unfilled slots, got %+v", forecast)
+	}
+	want := map[string][2]float64{"u1": {1, 1}, "u2": {1, 2}, "u3": {0.5, 1.5}, "u4": {0, 0}}
+	if len(forecast.Members) != len(want) {
+		t.Fatalf("expected %d members, got %+v", len(want), forecast.Members)
+	}
+	for _, member := range forecast.Members {
+		w := want[member.UserID]
+		if !near(member.Availability, w[0]) || !near(member.Assignments, w[1]) {
+			t.Fatalf("expected %s available %v with %v assignments, got %+v", member.UserID, w[0], w[1], member)
+		}
+	}
+
+	if _, err := svc.LoadForecast(ctx, "missing", 7*24*time.Hour); !errors.Is(err, domain.ErrTeamNotFound) {
+		t.Fatalf("expected ErrTeamNotFound, got %v", err)
+	}
+}
+
+func TestRotationAssignsInOrder(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Charlie", IsActive: false},
+			{ID: "u4", Username: "Dora", IsActive: true},
+			{ID: "u5", Username: "Eve", IsActive: true},
+		},
+	})
+
+	if _, err := svc.SetRotation(ctx, domain.Rotation{
+		TeamName: "backend",
+		UserIDs:  []string{"u2", "u3", "u4", "u5", "u1"},
+	}); err != nil {
+		t.Fatalf("SetRotation: %v", err)
+	}
+
+	first, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-20", Name: "First", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest first: %v", err)
+	}
+	if !contains(first.AssignedReviewers, "u2") || !contains(first.AssignedReviewers, "u4") {
+		t.Fatalf("expected u2 and u4, got %+v", first.AssignedReviewers)
+	}
+
+	second, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-21", Name: "Second", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest second: %v", err)
+	}
+	if !contains(second.AssignedReviewers, "u5") || !contains(second.AssignedReviewers, "u2") {
+		t.Fatalf("expected u5 and u2, got %+v", second.AssignedReviewers)
+	}
+
+	rotation, err := svc.GetRotation(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetRotation: %v", err)
+	}
+	if rotation.Position != 1 {
+		t.Fatalf("expected position 1, got %d", rotation.Position)
+	}
+}
+
+func TestRotationPicksAreNotSharedByConcurrentPullRequests(t *testing.T) {
+	ctx := context.Background()
+	repo := &interleavingRepository{Repository: storagetest.New(t)}
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Charlie", IsActive: true},
+			{ID: "u4", Username: "Dora", IsActive: true},
+			{ID: "u5", Username: "Eve", IsActive: true},
+		},
+	})
+	if _, err := svc.SetRotation(ctx, domain.Rotation{
+		TeamName: "backend",
+		UserIDs:  []string{"u2", "u3", "u4", "u5"},
+	}); err != nil {
+		t.Fatalf("SetRotation: %v", err)
+	}
+
+	// pr-31 picks from the rotation after pr-30 read it but before pr-30
+	// advanced it.
+	var concurrent domain.PullRequest
+	repo.beforeAdvanceRotation = func() {
+		repo.beforeAdvanceRotation = nil
+		var err error
+		concurrent, err = svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-31", Name: "Concurrent", AuthorID: "u1"})
+		if err != nil {
+			t.Errorf("CreatePullRequest concurrent: %v", err)
+		}
+	}
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-30", Name: "First", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+
+	if !reflect.DeepEqual(concurrent.AssignedReviewers, []string{"u2", "u3"}) {
+		t.Fatalf("expected u2 and u3 on the concurrent pull request, got %v", concurrent.AssignedReviewers)
+	}
+	if !reflect.DeepEqual(pr.AssignedReviewers, []string{"u4", "u5"}) {
+		t.Fatalf("expected u4 and u5, got %v", pr.AssignedReviewers)
+	}
+	rotation, err := svc.GetRotation(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetRotation: %v", err)
+	}
+	if rotation.Position != 0 {
+		t.Fatalf("expected position 0, got %d", rotation.Position)
+	}
+}
+
+func TestBulkCreatePullRequestsAdvancesRotationOnlyForCreated(t *testing.T) {
+	ctx := context.Background()
+	repo := &rotationRollbackRepository{Repository: storagetest.New(t), team: "backend"}
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Charlie", IsActive: true},
+			{ID: "u4", Username: "Dora", IsActive: true},
+			{ID: "u5", Username: "Eve", IsActive: true},
+		},
+	})
+	if _, err := svc.SetRotation(ctx, domain.Rotation{
+		TeamName: "backend",
+		UserIDs:  []string{"u2", "u3", "u4", "u5"},
+	}); err != nil {
+		t.Fatalf("SetRotation: %v", err)
+	}
+
+	results, err := svc.BulkCreatePullRequests(ctx, []domain.PullRequest{
+		{ID: "pr-40", Name: "First", AuthorID: "u1"},
+		{ID: "pr-40", Name: "Duplicate", AuthorID: "u1"},
+		{ID: "pr-41", Name: "Second", AuthorID: "u1"},
+	})
+	if err != nil {
+		t.Fatalf("BulkCreatePullRequests: %v", err)
+	}
+
+	if results[1].Err != domain.ErrPRExists {
+		t.Fatalf("expected ErrPRExists for the duplicate, got %v", results[1].Err)
+	}
+	if !reflect.DeepEqual(results[0].PullRequest.AssignedReviewers, []string{"u2", "u3"}) {
+		t.Fatalf("expected u2 and u3 on the first, got %v", results[0].PullRequest.AssignedReviewers)
+	}
+	if !reflect.DeepEqual(results[2].PullRequest.AssignedReviewers, []string{"u4", "u5"}) {
+		t.Fatalf("expected the duplicate's pick to go to the second, got %v", results[2].PullRequest.AssignedReviewers)
+	}
+}
+
+func TestAssignReviewersValidatesNominees(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Charlie", IsActive: false},
+			{ID: "u4", Username: "Dora", IsActive: true},
+		},
+	})
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-30", Name: "Manual", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+
+	if _, err := svc.AssignReviewers(ctx, pr.ID, []string{"u1"}); err != domain.ErrInvalidReviewer {
+		t.Fatalf("expected ErrInvalidReviewer for author, got %v", err)
+	}
+	if _, err := svc.AssignReviewers(ctx, pr.ID, []string{"u3"}); err != domain.ErrInvalidReviewer {
+		t.Fatalf("expected ErrInvalidReviewer for inactive user, got %v", err)
+	}
+
+	updated, err := svc.AssignReviewers(ctx, pr.ID, []string{"u4"})
+	if err != nil {
+		t.Fatalf("AssignReviewers: %v", err)
+	}
+	if len(updated.AssignedReviewers) != 1 || updated.AssignedReviewers[0] != "u4" {
+		t.Fatalf("expected only u4, got %+v", updated.AssignedReviewers)
+	}
+}
+
+func TestCoAuthorsAreNotPickedAsReviewers(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: "u5", Username: "Eve", IsActive: true},
+		},
+	})
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-1", Name: "Pairing", AuthorID: "u1", CoAuthors: []string{"u2", "u3"}})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if !reflect.DeepEqual(pr.AssignedReviewers, []string{"u4", "u5"}) {
+		t.Fatalf("expected only u4 and u5 to be picked, got %v", pr.AssignedReviewers)
+	}
+	if !reflect.DeepEqual(pr.CoAuthors, []string{"u2", "u3"}) {
+		t.Fatalf("expected the co-authors to be kept, got %v", pr.CoAuthors)
+	}
+
+	decisions, err := svc.AssignmentTrace(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("AssignmentTrace: %v", err)
+	}
+	if len(decisions) != 1 || !contains(decisions[0].Filters, domain.FilterCoAuthor) {
+		t.Fatalf("expected the co-author filter in the decision, got %+v", decisions)
+	}
+	for _, candidate := range decisions[0].Candidates {
+		if (candidate.UserID == "u2" || candidate.UserID == "u3") != (candidate.ExcludedBy == domain.FilterCoAuthor) {
+			t.Fatalf("unexpected exclusion %+v", candidate)
+		}
+	}
+
+	if _, _, err := svc.AddReviewer(ctx, "pr-1", ""); !errors.Is(err, domain.ErrNoReplacement) {
+		t.Fatalf("expected ErrNoReplacement with only co-authors left, got %v", err)
+	}
+	if _, err := svc.AssignReviewers(ctx, "pr-1", []string{"u2"}); err != domain.ErrInvalidReviewer {
+		t.Fatalf("expected ErrInvalidReviewer for a co-author, got %v", err)
+	}
+}
+
+func TestTeamSettingsForSmallTeams(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "pair",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+
+	settings := domain.TeamSettings{TeamName: "pair", RequiredReviewers: 2}
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings strict: %v", err)
+	}
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-40", Name: "Strict", AuthorID: "u1"}); err != domain.ErrNotEnoughReviewers {
+		t.Fatalf("expected ErrNotEnoughReviewers, got %v", err)
+	}
+
+	settings.AllowAuthorReview = true
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings author review: %v", err)
+	}
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-41", Name: "Self", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if !contains(pr.AssignedReviewers, "u1") || !contains(pr.AssignedReviewers, "u2") {
+		t.Fatalf("expected u1 and u2, got %+v", pr.AssignedReviewers)
+	}
+}
+
+func TestProcessRemindersSendsOnce(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	settings := domain.DefaultTeamSettings("backend")
+	settings.RemindAfter = time.Hour
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-50", Name: "Slow", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+
+	later := time.Now().UTC().Add(2 * time.Hour)
+	for i := 0; i < 2; i++ {
+		if err := svc.ProcessReminders(ctx, later); err != nil {
+			t.Fatalf("ProcessReminders: %v", err)
+		}
+	}
+
+	events, err := svc.ListChanges(ctx, 0, 100)
+	if err != nil {
+		t.Fatalf("ListChanges: %v", err)
+	}
+	reminders := 0
+	for _, event := range events {
+		if event.Type == domain.EventReviewReminder {
+			reminders++
+		}
+	}
+	if reminders != 1 {
+		t.Fatalf("expected exactly one reminder, got %d", reminders)
+	}
+}
+
+func TestProcessRemindersSkipsFailingPullRequest(t *testing.T) {
+	ctx := context.Background()
+	repo := &faultyRepository{Repository: storagetest.New(t), failing: "pr-51"}
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	settings := domain.DefaultTeamSettings("backend")
+	settings.RemindAfter = time.Hour
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+	for _, id := range []string{"pr-51", "pr-52"} {
+		if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: id, Name: "Slow", AuthorID: "u1"}); err != nil {
+			t.Fatalf("CreatePullRequest: %v", err)
+		}
+	}
+
+	if err := svc.ProcessReminders(ctx, time.Now().UTC().Add(2*time.Hour)); err != nil {
+		t.Fatalf("ProcessReminders: %v", err)
+	}
+
+	events, err := svc.ListChanges(ctx, 0, 100)
+	if err != nil {
+		t.Fatalf("ListChanges: %v", err)
+	}
+	var reminded []string
+	for _, event := range events {
+		if event.Type == domain.EventReviewReminder {
+			reminded = append(reminded, event.EntityID)
+		}
+	}
+	if !reflect.DeepEqual(reminded, []string{"pr-52"}) {
+		t.Fatalf("expected pr-52 to be reminded past the failing pr-51, got %v", reminded)
+	}
+}
+
+func TestPullRequestDescription(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-61", Name: "Add login", AuthorID: "u1", Description: "Adds OAuth login."}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	pr, err := svc.GetPullRequest(ctx, "pr-61")
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if pr.Description != "Adds OAuth login." {
+		t.Fatalf("expected stored description, got %q", pr.Description)
+	}
+
+	description := "Adds OAuth and SSO login."
+	pr, err = svc.UpdatePullRequest(ctx, "pr-61", domain.PullRequestUpdate{Description: &description}, false)
+	if err != nil {
+		t.Fatalf("UpdatePullRequest: %v", err)
+	}
+	if pr.Description != description {
+		t.Fatalf("expected updated description, got %q", pr.Description)
+	}
+
+	tooLong := strings.Repeat("x", 64<<10+1)
+	if _, err := svc.UpdatePullRequest(ctx, "pr-61", domain.PullRequestUpdate{Description: &tooLong}, false); err != domain.ErrInvalidPullRequest {
+		t.Fatalf("expected ErrInvalidPullRequest, got %v", err)
+	}
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-62", Name: "Huge", AuthorID: "u1", Description: tooLong}); err != domain.ErrInvalidPullRequest {
+		t.Fatalf("expected ErrInvalidPullRequest, got %v", err)
+	}
+}
+
+func TestDeadlineExtensionHoldsBackEscalation(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)}
+	start := clock.Now()
+	svc := service.New(storagetest.New(t), service.WithClock(clock))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	settings := domain.DefaultTeamSettings("backend")
+	settings.EscalateAfter = 2 * time.Hour
+	settings.AutoApproveExtension = 24 * time.Hour
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-70", Name: "Slow", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 1 || pr.AssignedReviewers[0] != "u2" {
+		t.Fatalf("expected u2 to review, got %+v", pr.AssignedReviewers)
+	}
+
+	if _, err := svc.RequestDeadlineExtension(ctx, "pr-70", "u1", start.Add(time.Hour), ""); err != domain.ErrReviewerNotFound {
+		t.Fatalf("expected ErrReviewerNotFound, got %v", err)
+	}
+	if _, err := svc.RequestDeadlineExtension(ctx, "pr-70", "u2", start.Add(-time.Hour), ""); err != domain.ErrInvalidExtension {
+		t.Fatalf("expected ErrInvalidExtension, got %v", err)
+	}
+
+	extension, err := svc.RequestDeadlineExtension(ctx, "pr-70", "u2", start.Add(5*time.Hour), " on call ")
+	if err != nil {
+		t.Fatalf("RequestDeadlineExtension: %v", err)
+	}
+	if extension.Status != domain.ExtensionApproved || extension.Reason != "on call" {
+		t.Fatalf("expected an automatically approved extension, got %+v", extension)
+	}
+	pr, err = svc.GetPullRequest(ctx, "pr-70")
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if pr.Deadline == nil || !pr.Deadline.Equal(start.Add(5*time.Hour)) {
+		t.Fatalf("expected the deadline to move, got %v", pr.Deadline)
+	}
+
+	escalations := func() int {
+		events, err := svc.ListChanges(ctx, 0, 100)
+		if err != nil {
+			t.Fatalf("ListChanges: %v", err)
+		}
+		count := 0
+		for _, event := range events {
+			if event.Type == domain.EventReviewEscalated {
+				count++
+			}
+		}
+		return count
+	}
+	if err := svc.ProcessReminders(ctx, start.Add(3*time.Hour)); err != nil {
+		t.Fatalf("ProcessReminders: %v", err)
+	}
+	if n := escalations(); n != 0 {
+		t.Fatalf("expected no escalation before the deadline, got %d", n)
+	}
+	if err := svc.ProcessReminders(ctx, start.Add(6*time.Hour)); err != nil {
+		t.Fatalf("ProcessReminders: %v", err)
+	}
+	if n := escalations(); n != 1 {
+		t.Fatalf("expected an escalation after the deadline, got %d", n)
+	}
+
+	if _, err := svc.RequestDeadlineExtension(ctx, "pr-70", "u2", start.Add(4*time.Hour), ""); err != domain.ErrInvalidExtension {
+		t.Fatalf("expected ErrInvalidExtension before the current deadline, got %v", err)
+	}
+	extension, err = svc.RequestDeadlineExtension(ctx, "pr-70", "u2", start.Add(72*time.Hour), "")
+	if err != nil {
+		t.Fatalf("RequestDeadlineExtension: %v", err)
+	}
+	if extension.Status != domain.ExtensionPending {
+		t.Fatalf("expected a pending extension beyond the policy, got %+v", extension)
+	}
+	extension, err = svc.DecideDeadlineExtension(ctx, extension.ID, false, "u1")
+	if err != nil {
+		t.Fatalf("DecideDeadlineExtension: %v", err)
+	}
+	if extension.Status != domain.ExtensionRejected || extension.DecidedBy != "u1" {
+		t.Fatalf("expected a rejection by u1, got %+v", extension)
+	}
+	if _, err := svc.DecideDeadlineExtension(ctx, extension.ID, true, "u1"); err != domain.ErrExtensionDecided {
+		t.Fatalf("expected ErrExtensionDecided, got %v", err)
+	}
+
+	extensions, err := svc.ListDeadlineExtensions(ctx, "pr-70")
+	if err != nil {
+		t.Fatalf("ListDeadlineExtensions: %v", err)
+	}
+	if len(extensions) != 2 || extensions[0].Status != domain.ExtensionApproved {
+		t.Fatalf("unexpected extensions: %+v", extensions)
+	}
+}
+
+func TestUpdatePullRequestRespectsMergeState(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-60", Name: "Draft", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+
+	name := "Add login"
+	labels := []string{" backend ", "auth", "backend"}
+	priority := domain.PriorityHigh
+	pr, err := svc.UpdatePullRequest(ctx, "pr-60", domain.PullRequestUpdate{Name: &name, Labels: &labels, Priority: &priority}, false)
+	if err != nil {
+		t.Fatalf("UpdatePullRequest: %v", err)
+	}
+	if pr.Name != name || pr.Priority != domain.PriorityHigh || len(pr.Labels) != 2 || pr.Labels[0] != "backend" {
+		t.Fatalf("unexpected pull request: %+v", pr)
+	}
+
+	badURL := "not a url"
+	if _, err := svc.UpdatePullRequest(ctx, "pr-60", domain.PullRequestUpdate{URL: &badURL}, false); err != domain.ErrInvalidPullRequest {
+		t.Fatalf("expected ErrInvalidPullRequest, got %v", err)
+	}
+
+	if _, err := svc.MergePullRequest(ctx, "pr-60"); err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
+	}
+	if _, err := svc.UpdatePullRequest(ctx, "pr-60", domain.PullRequestUpdate{Name: &name}, false); err != domain.ErrPRMerged {
+		t.Fatalf("expected ErrPRMerged, got %v", err)
+	}
+	if _, err := svc.UpdatePullRequest(ctx, "pr-60", domain.PullRequestUpdate{Name: &name}, true); err != nil {
+		t.Fatalf("UpdatePullRequest as admin: %v", err)
+	}
+}
+
+func TestArchivePullRequestsMovesMerged(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	for _, id := range []string{"pr-70", "pr-71"} {
+		if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: id, Name: id, AuthorID: "u1"}); err != nil {
+			t.Fatalf("CreatePullRequest: %v", err)
+		}
+	}
+	if _, err := svc.MergePullRequest(ctx, "pr-70"); err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
+	}
+
+	archived, err := svc.ArchivePullRequests(ctx, time.Now().UTC().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("ArchivePullRequests: %v", err)
+	}
+	if archived != 1 {
+		t.Fatalf("expected 1 archived pull request, got %d", archived)
+	}
+
+	reviews, err := svc.ListUserReviews(ctx, "u2", domain.ReviewFilter{})
+	if err != nil {
+		t.Fatalf("ListUserReviews: %v", err)
+	}
+	if len(reviews) != 1 || reviews[0].ID != "pr-71" {
+		t.Fatalf("expected only pr-71 in reviews, got %+v", reviews)
+	}
+
+	pr, err := svc.GetPullRequest(ctx, "pr-70")
+	if err != nil {
+		t.Fatalf("GetPullRequest archived: %v", err)
+	}
+	if pr.Status != domain.StatusMerged {
+		t.Fatalf("expected archived pull request to stay merged, got %s", pr.Status)
+	}
+}
+
+func TestTeamStats(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2025, 2, 25, 10, 0, 0, 0, time.UTC)}
+	svc := service.New(storagetest.New(t), service.WithClock(clock))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+		},
+	})
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-old", Name: "Old", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if _, err := svc.MergePullRequest(ctx, "pr-old"); err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
+	}
+
+	clock.Advance(7 * 24 * time.Hour)
+	for _, pr := range []domain.PullRequest{
+		{ID: "pr-a", Name: "Search", AuthorID: "u1"},
+		{ID: "pr-b", Name: "Cache", AuthorID: "u2"},
+		{ID: "pr-d", Name: "WIP", AuthorID: "u3", Status: domain.StatusDraft},
+	} {
+		if _, err := svc.CreatePullRequest(ctx, pr); err != nil {
+			t.Fatalf("CreatePullRequest %s: %v", pr.ID, err)
+		}
+	}
+	created, err := svc.GetPullRequest(ctx, "pr-a")
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if _, _, err := svc.ReassignReviewer(ctx, "pr-a", created.AssignedReviewers[0]); err != nil {
+		t.Fatalf("ReassignReviewer: %v", err)
+	}
+	if _, err := svc.MergePullRequest(ctx, "pr-a"); err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
+	}
+
+	assignments := make(map[string]int)
+	for _, id := range []string{"pr-old", "pr-a", "pr-b"} {
+		pr, err := svc.GetPullRequest(ctx, id)
+		if err != nil {
+			t.Fatalf("GetPullRequest %s: %v", id, err)
+		}
+		for _, reviewer := range pr.AssignedReviewers {
+			assignments[reviewer]++
+		}
+	}
+	busiest := ""
+	for _, userID := range []string{"u1", "u2", "u3", "u4"} {
+		if assignments[userID] > assignments[busiest] {
+			busiest = userID
+		}
+	}
+
+	stats, err := svc.TeamStats(ctx, "backend", 14*24*time.Hour)
+	if err != nil {
+		t.Fatalf("TeamStats: %v", err)
+	}
+	if stats.OpenPullRequests != 1 || stats.PullRequests != 3 || stats.AvgReviewers != 2 {
+		t.Fatalf("expected 1 open and 3 recent pull requests with 2 reviewers each, got %+v", stats)
+	}
+	if stats.Reassigned != 1 || stats.ReassignmentRate != 1.0/3 {
+		t.Fatalf("expected one of three reassigned, got %d (%v)", stats.Reassigned, stats.ReassignmentRate)
+	}
+	if stats.BusiestReviewer != busiest || stats.BusiestReviewerAssignments != assignments[busiest] {
+		t.Fatalf("expected busiest reviewer %s with %d, got %s with %d",
+			busiest, assignments[busiest], stats.BusiestReviewer, stats.BusiestReviewerAssignments)
+	}
+	want := []domain.WeekCount{
+		{Start: time.Date(2025, 2, 17, 0, 0, 0, 0, time.UTC), Count: 0},
+		{Start: time.Date(2025, 2, 24, 0, 0, 0, 0, time.UTC), Count: 1},
+		{Start: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), Count: 1},
+	}
+	if !reflect.DeepEqual(stats.MergesPerWeek, want) {
+		t.Fatalf("expected merges per week %v, got %v", want, stats.MergesPerWeek)
+	}
+
+	if _, err := svc.TeamStats(ctx, "missing", time.Hour); !errors.Is(err, domain.ErrTeamNotFound) {
+		t.Fatalf("expected ErrTeamNotFound, got %v", err)
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	svc := service.New(storagetest.New(t))
+	svc.SetQuotas(domain.Quotas{
+		TeamMembers:      domain.Quota{Hard: 4},
+		OpenPullRequests: domain.Quota{Soft: 1},
+		Reviewers:        domain.Quota{Soft: 2, Hard: 3},
+	})
+	ctx, collected := warnings.With(context.Background())
+
+	quotaError := func(err error) map[string]any {
+		t.Helper()
+		var domainErr *domain.Error
+		if !errors.As(err, &domainErr) || domainErr.Code != "QUOTA_EXCEEDED" {
+			t.Fatalf("expected QUOTA_EXCEEDED, got %v", err)
+		}
+		return domainErr.Details
+	}
+
+	members := []domain.User{
+		{ID: "u1", Username: "Alice", IsActive: true},
+		{ID: "u2", Username: "Bob", IsActive: true},
+		{ID: "u3", Username: "Cathy", IsActive: true},
+		{ID: "u4", Username: "Dan", IsActive: true},
+		{ID: "u5", Username: "Eve", IsActive: true},
+	}
+	_, _, err := svc.CreateTeam(ctx, domain.Team{Name: "backend", Members: members}, domain.ConflictReject)
+	details := quotaError(err)
+	if details["quota"] != "team_members" || details["limit"] != 4 || details["count"] != 5 {
+		t.Fatalf("unexpected details: %v", details)
+	}
+	createTeam(t, ctx, svc, domain.Team{Name: "backend", Members: members[:4]})
+
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-1", Name: "Search", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if got := collected.Items(); len(got) != 0 {
+		t.Fatalf("expected no warnings yet, got %+v", got)
+	}
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-2", Name: "Cache", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	got := collected.Items()
+	if len(got) != 1 || got[0].Code != "SOFT_QUOTA_EXCEEDED" || got[0].Details["quota"] != "open_pull_requests" || got[0].Details["count"] != 2 {
+		t.Fatalf("unexpected warnings: %+v", got)
+	}
+
+	if _, _, err := svc.AddReviewer(ctx, "pr-1", ""); err != nil {
+		t.Fatalf("AddReviewer: %v", err)
+	}
+	if got := collected.Items(); len(got) != 2 || got[1].Details["quota"] != "reviewers" {
+		t.Fatalf("unexpected warnings: %+v", got)
+	}
+	_, _, err = svc.AddReviewer(ctx, "pr-1", "")
+	if details := quotaError(err); details["quota"] != "reviewers" || details["count"] != 4 {
+		t.Fatalf("unexpected details: %v", details)
+	}
+}
+
+func TestTimeToReviewReport(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-80", Name: "Cache", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+
+	if _, err := svc.RecordReview(ctx, pr.ID, "u1", domain.ReviewComment); err != domain.ErrReviewerNotFound {
+		t.Fatalf("expected ErrReviewerNotFound for the author, got %v", err)
+	}
+	first, err := svc.RecordReview(ctx, pr.ID, "u2", domain.ReviewComment)
+	if err != nil {
+		t.Fatalf("RecordReview comment: %v", err)
+	}
+	if !first {
+		t.Fatalf("expected the first comment to be the first review")
+	}
+	first, err = svc.RecordReview(ctx, pr.ID, "u2", domain.ReviewApproval)
+	if err != nil {
+		t.Fatalf("RecordReview approval: %v", err)
+	}
+	if first {
+		t.Fatalf("approval after a comment is not the first review")
+	}
+
+	report, err := svc.TimeToReviewReport(ctx, "backend", 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("TimeToReviewReport: %v", err)
+	}
+	if len(report.Weeks) != 1 || report.Weeks[0].Count != 1 {
+		t.Fatalf("expected one week with one review, got %+v", report.Weeks)
+	}
+	if report.Weeks[0].Median < 0 || report.Weeks[0].P90 < report.Weeks[0].Median {
+		t.Fatalf("unexpected percentiles: %+v", report.Weeks[0])
+	}
+}
+
+func TestDailyStats(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+		},
+	})
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-90", Name: "Search", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-91", Name: "Cache", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	reassigned := pr.AssignedReviewers[0]
+	if _, _, err := svc.ReassignReviewer(ctx, pr.ID, reassigned); err != nil {
+		t.Fatalf("ReassignReviewer: %v", err)
+	}
+	if _, err := svc.MergePullRequest(ctx, pr.ID); err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
+	}
+
+	now := time.Now().UTC()
+	if err := svc.BuildDailyStats(ctx, now); err != nil {
+		t.Fatalf("BuildDailyStats: %v", err)
+	}
+	// Rebuilding replaces the day instead of adding to it.
+	if err := svc.BuildDailyStats(ctx, now); err != nil {
+		t.Fatalf("BuildDailyStats again: %v", err)
+	}
+
+	today := now.Truncate(24 * time.Hour)
+	stats, err := svc.DailyStats(ctx, "backend", today.AddDate(0, 0, -1), today)
+	if err != nil {
+		t.Fatalf("DailyStats: %v", err)
+	}
+	byUser := make(map[string]domain.DailyReviewStats)
+	for _, row := range stats {
+		if !row.Day.Equal(today) {
+			t.Fatalf("expected rows of today only, got %+v", row)
+		}
+		byUser[row.UserID] = row
+	}
+	author := byUser["u1"]
+	if author.Created != 2 || author.Merged != 1 || author.AvgTimeToMerge < 0 {
+		t.Fatalf("unexpected author stats: %+v", author)
+	}
+	if byUser[reassigned].Reassignments != 1 {
+		t.Fatalf("expected one reassignment for %s, got %+v", reassigned, byUser[reassigned])
+	}
+
+	if _, err := svc.DailyStats(ctx, "missing", today, today); err != domain.ErrTeamNotFound {
+		t.Fatalf("expected ErrTeamNotFound, got %v", err)
+	}
+}
+
+func TestDailyStatsFillDaysMissedDuringOutage(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)}
+	svc := service.New(storagetest.New(t), service.WithClock(clock))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	if err := svc.BuildDailyStats(ctx, clock.Now()); err != nil {
+		t.Fatalf("BuildDailyStats: %v", err)
+	}
+
+	// The service is down from March 4 to March 7, after a pull request was
+	// created on March 4.
+	clock.Advance(24 * time.Hour)
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-1", Name: "Search", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	clock.Advance(3 * 24 * time.Hour)
+	if err := svc.BuildDailyStats(ctx, clock.Now()); err != nil {
+		t.Fatalf("BuildDailyStats after the outage: %v", err)
+	}
+
+	march4 := time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)
+	stats, err := svc.DailyStats(ctx, "backend", march4, march4)
+	if err != nil {
+		t.Fatalf("DailyStats: %v", err)
+	}
+	if len(stats) != 1 || stats[0].UserID != "u1" || stats[0].Created != 1 {
+		t.Fatalf("expected the pull request of March 4 to be counted, got %+v", stats)
+	}
+}
+
+func TestUserReviewsForSeveralTeams(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	if _, _, err := svc.CreateTeam(ctx, domain.Team{
+		Name: "payments",
+		Members: []domain.User{
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	}, domain.ConflictJoin); err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+
+	team, err := svc.GetTeam(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeam: %v", err)
+	}
+	if len(team.Members) != 2 {
+		t.Fatalf("expected u2 to stay in backend, got %+v", team.Members)
+	}
+
+	for _, pr := range []domain.PullRequest{
+		{ID: "pr-90", Name: "Backend", AuthorID: "u1"},
+		{ID: "pr-91", Name: "Payments", AuthorID: "u3"},
+	} {
+		created, err := svc.CreatePullRequest(ctx, pr)
+		if err != nil {
+			t.Fatalf("CreatePullRequest %s: %v", pr.ID, err)
+		}
+		if len(created.AssignedReviewers) != 1 || created.AssignedReviewers[0] != "u2" {
+			t.Fatalf("%s: expected u2 as reviewer, got %+v", pr.ID, created.AssignedReviewers)
+		}
+	}
+}
+
+func TestAffinityStrategyPrefersFamiliarReviewers(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+		},
+	})
+	settings := domain.DefaultTeamSettings("backend")
+	settings.RequiredReviewers = 1
+	settings.Strategy = domain.StrategyAffinity
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+
+	past, err := svc.CreatePullRequest(ctx, domain.PullRequest{
+		ID: "pr-100", Name: "Payments refund API", AuthorID: "u3", Labels: []string{"payments"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePullRequest history: %v", err)
+	}
+	if _, err := svc.AssignReviewers(ctx, past.ID, []string{"u1"}); err != nil {
+		t.Fatalf("AssignReviewers: %v", err)
+	}
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{
+		ID: "pr-101", Name: "Payments refund UI", AuthorID: "u1", Labels: []string{"payments"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 1 || pr.AssignedReviewers[0] != "u3" {
+		t.Fatalf("expected u3 as the familiar reviewer, got %+v", pr.AssignedReviewers)
+	}
+}
+
+func TestMaxOpenReviewsLimitsAssignment(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+		},
+	})
+	settings := domain.DefaultTeamSettings("backend")
+	settings.RequiredReviewers = 1
+	settings.MaxOpenReviews = 1
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+
+	first, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-120", Name: "One", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest first: %v", err)
+	}
+	second, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-121", Name: "Two", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest second: %v", err)
+	}
+	if len(first.AssignedReviewers) != 1 || len(second.AssignedReviewers) != 1 {
+		t.Fatalf("expected one reviewer each, got %+v and %+v", first.AssignedReviewers, second.AssignedReviewers)
+	}
+	if first.AssignedReviewers[0] == second.AssignedReviewers[0] {
+		t.Fatalf("reviewer %s exceeded max_open_reviews", first.AssignedReviewers[0])
+	}
+
+	third, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-122", Name: "Three", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest third: %v", err)
+	}
+	if len(third.AssignedReviewers) != 0 {
+		t.Fatalf("expected no free reviewers, got %+v", third.AssignedReviewers)
+	}
+}
+
+func TestListUnderassigned(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "frontend",
+		Members: []domain.User{
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: "u5", Username: "Eve", IsActive: true},
+		},
+	})
+
+	for _, pr := range []domain.PullRequest{
+		{ID: "pr-130", Name: "Short", AuthorID: "u1"},
+		{ID: "pr-131", Name: "Full", AuthorID: "u3"},
+		{ID: "pr-132", Name: "Merged", AuthorID: "u1"},
+	} {
+		if _, err := svc.CreatePullRequest(ctx, pr); err != nil {
+			t.Fatalf("CreatePullRequest %s: %v", pr.ID, err)
+		}
+	}
+	if _, err := svc.MergePullRequest(ctx, "pr-132"); err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
+	}
+
+	prs, err := svc.ListUnderassigned(ctx, "")
+	if err != nil {
+		t.Fatalf("ListUnderassigned: %v", err)
+	}
+	if len(prs) != 1 || prs[0].PullRequestID != "pr-130" {
+		t.Fatalf("expected only pr-130, got %+v", prs)
+	}
+	if prs[0].TeamName != "backend" || prs[0].Assigned != 1 || prs[0].Required != domain.DefaultRequiredReviewers {
+		t.Fatalf("unexpected entry %+v", prs[0])
+	}
+
+	prs, err = svc.ListUnderassigned(ctx, "frontend")
+	if err != nil {
+		t.Fatalf("ListUnderassigned frontend: %v", err)
+	}
+	if len(prs) != 0 {
+		t.Fatalf("expected no frontend entries, got %+v", prs)
+	}
+
+	if _, err := svc.ListUnderassigned(ctx, "missing"); err != domain.ErrTeamNotFound {
+		t.Fatalf("expected ErrTeamNotFound, got %v", err)
+	}
+}
+
+func TestCreateTeamChecksDirectory(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+	dir := &stubDirectory{known: map[string]bool{"u1": true, "u2": true}}
+	svc.SetDirectory(dir)
+
+	_, _, err := svc.CreateTeam(ctx, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u3", Username: "Typo", IsActive: true},
+		},
+	}, domain.ConflictReject)
+	if err != domain.ErrUnknownUser {
+		t.Fatalf("expected ErrUnknownUser, got %v", err)
+	}
+	if _, err := svc.GetTeam(ctx, "backend"); err != domain.ErrTeamNotFound {
+		t.Fatalf("expected team not to be created, got %v", err)
+	}
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+
+	dir.err = errors.New("directory unavailable")
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "frontend",
+		Members: []domain.User{
+			{ID: "u4", Username: "Dan", IsActive: true},
+		},
+	})
+}
+
+func TestAssignmentTraceExplainsPicks(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: false},
+		},
+	})
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-140", Name: "Trace", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+
+	trace, err := svc.AssignmentTrace(ctx, pr.ID)
+	if err != nil {
+		t.Fatalf("AssignmentTrace: %v", err)
+	}
+	if len(trace) != 1 {
+		t.Fatalf("expected one decision, got %+v", trace)
+	}
+	decision := trace[0]
+	if decision.Reason != domain.ReasonAuto || decision.Strategy != string(domain.StrategyRandom) || decision.Seed == 0 {
+		t.Fatalf("unexpected decision %+v", decision)
+	}
+	excluded := make(map[string]string)
+	for _, candidate := range decision.Candidates {
+		excluded[candidate.UserID] = candidate.ExcludedBy
+	}
+	want := map[string]string{"u1": domain.FilterAuthor, "u2": "", "u3": "", "u4": domain.FilterInactive}
+	for userID, reason := range want {
+		if got, ok := excluded[userID]; !ok || got != reason {
+			t.Fatalf("candidate %s: expected %q, got %q (present %v)", userID, reason, got, ok)
+		}
+	}
+	if len(decision.Selected) != 2 || !contains(decision.Selected, "u2") || !contains(decision.Selected, "u3") {
+		t.Fatalf("unexpected selection %+v", decision.Selected)
+	}
+
+	if _, err := svc.SetUserActive(ctx, "u4", true); err != nil {
+		t.Fatalf("SetUserActive: %v", err)
+	}
+	if _, _, err := svc.ReassignReviewer(ctx, pr.ID, "u2"); err != nil {
+		t.Fatalf("ReassignReviewer: %v", err)
+	}
+	trace, err = svc.AssignmentTrace(ctx, pr.ID)
+	if err != nil {
+		t.Fatalf("AssignmentTrace after reassign: %v", err)
+	}
+	if len(trace) != 2 || trace[1].Reason != domain.ReasonReassign {
+		t.Fatalf("expected reassign decision, got %+v", trace)
+	}
+	for _, candidate := range trace[1].Candidates {
+		if candidate.UserID == "u2" && candidate.ExcludedBy != domain.FilterReplaced ||
+			candidate.UserID == "u3" && candidate.ExcludedBy != domain.FilterAlreadyAssigned {
+			t.Fatalf("unexpected reassign candidate %+v", candidate)
+		}
+	}
+
+	if _, err := svc.AssignmentTrace(ctx, "missing"); err != domain.ErrPullRequestNotFound {
+		t.Fatalf("expected ErrPullRequestNotFound, got %v", err)
+	}
+}
+
+func TestCreatePullRequestWithReviewersCount(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+		},
+	})
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-150", Name: "Small", AuthorID: "u1", ReviewersCount: 1})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 1 || pr.ReviewersCount != 1 {
+		t.Fatalf("expected one requested reviewer, got %+v", pr)
+	}
+
+	_, err = svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-151", Name: "Large", AuthorID: "u1", ReviewersCount: 3})
+	if err != domain.ErrTooManyReviewers {
+		t.Fatalf("expected ErrTooManyReviewers, got %v", err)
+	}
+
+	if _, err := svc.SetUserActive(ctx, "u3", false); err != nil {
+		t.Fatalf("SetUserActive: %v", err)
+	}
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-152", Name: "Short", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest short: %v", err)
+	}
+
+	prs, err := svc.ListUnderassigned(ctx, "backend")
+	if err != nil {
+		t.Fatalf("ListUnderassigned: %v", err)
+	}
+	if len(prs) != 1 || prs[0].PullRequestID != "pr-152" {
+		t.Fatalf("expected only pr-152 under-assigned, got %+v", prs)
+	}
+}
+
+func TestWebhookDeliversSignedEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc := service.New(storagetest.New(t))
+
+	if _, err := svc.CreateWebhook(ctx, domain.Webhook{
+		URL:    "ftp://example.com",
+		Events: []domain.WebhookEvent{domain.WebhookPRCreated},
+	}); err != domain.ErrInvalidWebhook {
+		t.Fatalf("expected ErrInvalidWebhook for a non-http url, got %v", err)
+	}
+	if _, err := svc.CreateWebhook(ctx, domain.Webhook{
+		URL:    "http://example.com",
+		Events: []domain.WebhookEvent{"pr.closed"},
+	}); err != domain.ErrInvalidWebhook {
+		t.Fatalf("expected ErrInvalidWebhook for an unknown event, got %v", err)
+	}
+
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer receiver.Close()
+
+	hook, err := svc.CreateWebhook(ctx, domain.Webhook{
+		URL:    receiver.URL,
+		Events: []domain.WebhookEvent{domain.WebhookPRCreated, domain.WebhookPRCreated},
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	if hook.Secret == "" || len(hook.Events) != 1 {
+		t.Fatalf("expected a generated secret and deduplicated events, got %+v", hook)
+	}
+
+	events, stop := svc.SubscribeEvents(nil)
+	defer stop()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		webhook.New(svc, config.WebhookConfig{MaxAttempts: 1, Timeout: time.Second, Workers: 1}).Run(ctx, events)
+	}()
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-150", Name: "Hook", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+
+	var req *http.Request
+	select {
+	case req = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+	body := <-bodies
+	if got := req.Header.Get(webhook.HeaderEvent); got != string(domain.WebhookPRCreated) {
+		t.Fatalf("expected event header pr.created, got %q", got)
+	}
+	if got, want := req.Header.Get(webhook.HeaderSignature), webhook.Sign(hook.Secret, body); got != want {
+		t.Fatalf("expected signature %q, got %q", want, got)
+	}
+
+	cancel()
+	<-done
+
+	deliveries, err := svc.ListWebhookDeliveries(context.Background(), hook.ID, 10)
+	if err != nil {
+		t.Fatalf("ListWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 1 || !deliveries[0].Delivered || deliveries[0].StatusCode != http.StatusOK {
+		t.Fatalf("expected one successful delivery, got %+v", deliveries)
+	}
+}
+
+func TestWebhookDispatchFollowsTheChangeFeed(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	deliveries := make(chan string, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveries <- r.Header.Get(webhook.HeaderDelivery)
+	}))
+	defer receiver.Close()
+	if _, err := svc.CreateWebhook(ctx, domain.Webhook{
+		URL:    receiver.URL,
+		Events: []domain.WebhookEvent{domain.WebhookPRCreated},
+	}); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+	created := func(id string) string {
+		t.Helper()
+		if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: id, Name: id, AuthorID: "u1"}); err != nil {
+			t.Fatalf("CreatePullRequest %s: %v", id, err)
+		}
+		events, err := svc.ListLatestChanges(ctx, 10)
+		if err != nil {
+			t.Fatalf("ListLatestChanges: %v", err)
+		}
+		for _, event := range events {
+			if event.Type == domain.EventPRCreated && event.EntityID == id {
+				return fmt.Sprint(event.Seq)
+			}
+		}
+		t.Fatalf("no PR_CREATED event for %s in %+v", id, events)
+		return ""
+	}
+	// Neither dispatcher hears of new events on the live stream: everything
+	// is read from the change feed.
+	run := func() func() {
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			cfg := config.WebhookConfig{MaxAttempts: 1, Timeout: time.Second, Workers: 1, PollInterval: 10 * time.Millisecond, Lease: 50 * time.Millisecond}
+			webhook.New(svc, cfg).Run(runCtx, nil)
+		}()
+		return func() {
+			cancel()
+			<-done
+		}
+	}
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-deliveries:
+			if got != want {
+				t.Fatalf("expected delivery of event %s, got %s", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("event %s was not delivered", want)
+		}
+	}
+
+	// An event stored before the dispatcher starts is delivered.
+	first := created("pr-1")
+	stop := run()
+	expect(first)
+	stop()
+
+	// A second instance takes over once the lease expires and picks up
+	// after the events already delivered.
+	second := created("pr-2")
+	stop = run()
+	expect(second)
+	stop()
+	select {
+	case got := <-deliveries:
+		t.Fatalf("expected no further delivery, got event %s", got)
+	default:
+	}
+}
+
+func TestWebhookDeliveryIDsAreNotReused(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	var hooks []domain.Webhook
+	for range 2 {
+		hook, err := svc.CreateWebhook(ctx, domain.Webhook{
+			URL:    "http://example.com",
+			Events: []domain.WebhookEvent{domain.WebhookPRCreated},
+		})
+		if err != nil {
+			t.Fatalf("CreateWebhook: %v", err)
+		}
+		hooks = append(hooks, hook)
+	}
+	record := func(hook domain.Webhook, seq int64) {
+		t.Helper()
+		if err := svc.RecordWebhookDelivery(ctx, domain.WebhookDelivery{WebhookID: hook.ID, EventSeq: seq, Event: domain.WebhookPRCreated, Attempt: 1}); err != nil {
+			t.Fatalf("RecordWebhookDelivery: %v", err)
+		}
+	}
+	record(hooks[0], 1)
+	record(hooks[1], 1)
+	if err := svc.DeleteWebhook(ctx, hooks[0].ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	record(hooks[1], 2)
+
+	deliveries, err := svc.ListWebhookDeliveries(ctx, hooks[1].ID, 10)
+	if err != nil {
+		t.Fatalf("ListWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 2 || deliveries[0].ID == deliveries[1].ID {
+		t.Fatalf("expected two deliveries with distinct IDs, got %+v", deliveries)
+	}
+}
+
+func TestAddReviewerBeyondRequestedCount(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: "u5", Username: "Eve", IsActive: false},
+		},
+	})
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-160", Name: "Second opinion", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+
+	if _, _, err := svc.AddReviewer(ctx, pr.ID, "u1"); err != domain.ErrInvalidReviewer {
+		t.Fatalf("expected ErrInvalidReviewer for the author, got %v", err)
+	}
+	if _, _, err := svc.AddReviewer(ctx, pr.ID, "u5"); err != domain.ErrInvalidReviewer {
+		t.Fatalf("expected ErrInvalidReviewer for an inactive user, got %v", err)
+	}
+	if _, _, err := svc.AddReviewer(ctx, pr.ID, pr.AssignedReviewers[0]); err != domain.ErrAlreadyAssigned {
+		t.Fatalf("expected ErrAlreadyAssigned, got %v", err)
+	}
+
+	updated, added, err := svc.AddReviewer(ctx, pr.ID, "")
+	if err != nil {
+		t.Fatalf("AddReviewer: %v", err)
+	}
+	if len(updated.AssignedReviewers) != 3 || !contains(updated.AssignedReviewers, added) {
+		t.Fatalf("expected %s added as a third reviewer, got %v", added, updated.AssignedReviewers)
+	}
+	if added == "u1" || added == "u5" || contains(pr.AssignedReviewers, added) {
+		t.Fatalf("auto-picked an ineligible reviewer %s", added)
+	}
+
+	if _, _, err := svc.AddReviewer(ctx, pr.ID, ""); err != domain.ErrNoReplacement {
+		t.Fatalf("expected ErrNoReplacement once the team is exhausted, got %v", err)
+	}
+
+	trace, err := svc.AssignmentTrace(ctx, pr.ID)
+	if err != nil {
+		t.Fatalf("AssignmentTrace: %v", err)
+	}
+	if last := trace[len(trace)-1]; last.Reason != domain.ReasonExtra || last.Selected[0] != added {
+		t.Fatalf("expected an extra decision for %s, got %+v", added, last)
+	}
+}
+
+func TestAddReviewerKeepsConcurrentChanges(t *testing.T) {
+	ctx := context.Background()
+	repo := &interleavingRepository{Repository: storagetest.New(t)}
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: "u5", Username: "Eve", IsActive: true},
+			{ID: "u6", Username: "Frank", IsActive: true},
+		},
+	})
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-161", Name: "Second opinion", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if _, err := svc.AssignReviewers(ctx, pr.ID, []string{"u2", "u3"}); err != nil {
+		t.Fatalf("AssignReviewers: %v", err)
+	}
+
+	// u2 is swapped for u4 after AddReviewer read the pull request but before
+	// it stored the addition.
+	repo.beforeAddReviewers = func() {
+		repo.beforeAddReviewers = nil
+		if _, err := repo.Repository.ReassignReviewer(ctx, pr.ID, "u2", "u4"); err != nil {
+			t.Fatalf("ReassignReviewer: %v", err)
+		}
+	}
+	if _, _, err := svc.AddReviewer(ctx, pr.ID, "u5"); err != nil {
+		t.Fatalf("AddReviewer: %v", err)
+	}
+	assertReviewers(t, ctx, svc, pr.ID, []string{"u3", "u4", "u5"})
+
+	// A reviewer added in between fails the addition rather than being
+	// exceeded.
+	repo.beforeAddReviewers = func() {
+		repo.beforeAddReviewers = nil
+		if _, _, err := repo.Repository.AddReviewers(ctx, pr.ID, []string{"u2"}, 4); err != nil {
+			t.Fatalf("AddReviewers: %v", err)
+		}
+	}
+	if _, _, err := svc.AddReviewer(ctx, pr.ID, "u6"); err != domain.ErrReassignConflict {
+		t.Fatalf("expected ErrReassignConflict, got %v", err)
+	}
+	assertReviewers(t, ctx, svc, pr.ID, []string{"u2", "u3", "u4", "u5"})
+}
+
+func TestAssignReviewersKeepsConcurrentChanges(t *testing.T) {
+	ctx := context.Background()
+	repo := &interleavingRepository{Repository: storagetest.New(t)}
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: "u5", Username: "Eve", IsActive: true},
+		},
+	})
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-162", Name: "Hand-picked", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if _, err := svc.AssignReviewers(ctx, pr.ID, []string{"u2", "u3"}); err != nil {
+		t.Fatalf("AssignReviewers: %v", err)
+	}
+
+	// u2 is swapped for u4 after AssignReviewers read the pull request but
+	// before it stored the new reviewers.
+	repo.beforeCompareAndUpdate = func() {
+		repo.beforeCompareAndUpdate = nil
+		if _, err := repo.Repository.ReassignReviewer(ctx, pr.ID, "u2", "u4"); err != nil {
+			t.Fatalf("ReassignReviewer: %v", err)
+		}
+	}
+	if _, err := svc.AssignReviewers(ctx, pr.ID, []string{"u2", "u5"}); err != domain.ErrReassignConflict {
+		t.Fatalf("expected ErrReassignConflict, got %v", err)
+	}
+	assertReviewers(t, ctx, svc, pr.ID, []string{"u3", "u4"})
+
+	// A merge in between keeps the merged pull request's reviewers.
+	repo.beforeCompareAndUpdate = func() {
+		repo.beforeCompareAndUpdate = nil
+		if _, err := svc.MergePullRequest(ctx, pr.ID); err != nil {
+			t.Fatalf("MergePullRequest: %v", err)
+		}
+	}
+	if _, err := svc.AssignReviewers(ctx, pr.ID, []string{"u2", "u5"}); err != domain.ErrPRMerged {
+		t.Fatalf("expected ErrPRMerged, got %v", err)
+	}
+	assertReviewers(t, ctx, svc, pr.ID, []string{"u3", "u4"})
+
+	trace, err := svc.AssignmentTrace(ctx, pr.ID)
+	if err != nil {
+		t.Fatalf("AssignmentTrace: %v", err)
+	}
+	if last := trace[len(trace)-1]; !reflect.DeepEqual(last.Selected, []string{"u2", "u3"}) {
+		t.Fatalf("expected no decision for the failed assignments, got %+v", last)
+	}
+}
+
+func TestDeferredAssignmentWaitsForWorkingHours(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+	svc.SetDeferOffHours(true)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+		},
+	})
+
+	// A one-hour working day two hours from now, every day of the week.
+	now := time.Now().UTC()
+	start := time.Duration((now.Hour()+2)%24) * time.Hour
+	settings, err := svc.GetTeamSettings(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	settings.TimeZone = "UTC"
+	settings.WorkStart = start
+	settings.WorkEnd = start + time.Hour
+	settings.WorkDays = []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-170", Name: "Night owl", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 0 {
+		t.Fatalf("expected no reviewers outside working hours, got %v", pr.AssignedReviewers)
+	}
+
+	if err := svc.ProcessDeferredAssignments(ctx, now); err != nil {
+		t.Fatalf("ProcessDeferredAssignments: %v", err)
+	}
+	if pr, _ = svc.GetPullRequest(ctx, pr.ID); len(pr.AssignedReviewers) != 0 {
+		t.Fatalf("expected the assignment to wait, got %v", pr.AssignedReviewers)
+	}
+
+	workday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Add(start + 30*time.Minute)
+	if err := svc.ProcessDeferredAssignments(ctx, workday); err != nil {
+		t.Fatalf("ProcessDeferredAssignments: %v", err)
+	}
+	pr, err = svc.GetPullRequest(ctx, pr.ID)
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 2 || contains(pr.AssignedReviewers, "u1") {
+		t.Fatalf("expected two reviewers once the day starts, got %v", pr.AssignedReviewers)
+	}
+
+	trace, err := svc.AssignmentTrace(ctx, pr.ID)
+	if err != nil {
+		t.Fatalf("AssignmentTrace: %v", err)
+	}
+	if len(trace) != 2 || trace[0].Strategy != domain.DecisionDeferred {
+		t.Fatalf("expected a deferred decision followed by the pick, got %+v", trace)
+	}
+}
+
+func TestDeferredAssignmentsSkipFailingPullRequest(t *testing.T) {
+	ctx := context.Background()
+	repo := &faultyRepository{Repository: storagetest.New(t), failing: "pr-171"}
+	svc := service.New(repo)
+	svc.SetDeferOffHours(true)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+		},
+	})
+
+	now := time.Now().UTC()
+	start := time.Duration((now.Hour()+2)%24) * time.Hour
+	settings, err := svc.GetTeamSettings(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	settings.TimeZone = "UTC"
+	settings.WorkStart = start
+	settings.WorkEnd = start + time.Hour
+	settings.WorkDays = []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+	for _, id := range []string{"pr-171", "pr-172"} {
+		if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: id, Name: "Night owl", AuthorID: "u1"}); err != nil {
+			t.Fatalf("CreatePullRequest: %v", err)
+		}
+	}
+
+	workday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Add(start + 30*time.Minute)
+	if err := svc.ProcessDeferredAssignments(ctx, workday); err != nil {
+		t.Fatalf("ProcessDeferredAssignments: %v", err)
+	}
+	pr, err := svc.GetPullRequest(ctx, "pr-172")
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 2 {
+		t.Fatalf("expected pr-172 to get reviewers past the failing pr-171, got %v", pr.AssignedReviewers)
+	}
+
+	// The failed assignment stays queued and goes through once the fault is gone.
+	repo.failing = ""
+	if err := svc.ProcessDeferredAssignments(ctx, workday); err != nil {
+		t.Fatalf("ProcessDeferredAssignments: %v", err)
+	}
+	if pr, _ = svc.GetPullRequest(ctx, "pr-171"); len(pr.AssignedReviewers) != 2 {
+		t.Fatalf("expected pr-171 to get reviewers on the next run, got %v", pr.AssignedReviewers)
+	}
+}
+
+func TestPendingAssignmentFilledWhenMemberReturns(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: false},
+		},
+	})
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-175", Name: "Short-handed", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 1 {
+		t.Fatalf("expected a single reviewer, got %v", pr.AssignedReviewers)
+	}
+
+	if err := svc.ProcessPendingAssignments(ctx, time.Now().UTC()); err != nil {
+		t.Fatalf("ProcessPendingAssignments: %v", err)
+	}
+	if pr, _ = svc.GetPullRequest(ctx, pr.ID); len(pr.AssignedReviewers) != 1 {
+		t.Fatalf("expected the slot to stay open, got %v", pr.AssignedReviewers)
+	}
+
+	if _, err := svc.SetUserActive(ctx, "u3", true); err != nil {
+		t.Fatalf("SetUserActive: %v", err)
+	}
+	if err := svc.ProcessPendingAssignments(ctx, time.Now().UTC()); err != nil {
+		t.Fatalf("ProcessPendingAssignments: %v", err)
+	}
+	pr, err = svc.GetPullRequest(ctx, pr.ID)
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 2 || !contains(pr.AssignedReviewers, "u3") {
+		t.Fatalf("expected u3 to fill the open slot, got %v", pr.AssignedReviewers)
+	}
+
+	trace, err := svc.AssignmentTrace(ctx, pr.ID)
+	if err != nil {
+		t.Fatalf("AssignmentTrace: %v", err)
+	}
+	if last := trace[len(trace)-1]; last.Reason != domain.ReasonPending || last.Selected[0] != "u3" {
+		t.Fatalf("expected a pending decision for u3, got %+v", last)
+	}
+}
+
+func TestPendingAssignmentKeepsConcurrentReassignment(t *testing.T) {
+	ctx := context.Background()
+	repo := &interleavingRepository{Repository: storagetest.New(t)}
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: false},
+			{ID: "u4", Username: "Dave", IsActive: false},
+		},
+	})
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-176", Name: "Short-handed", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if !reflect.DeepEqual(pr.AssignedReviewers, []string{"u2"}) {
+		t.Fatalf("expected u2 alone, got %v", pr.AssignedReviewers)
+	}
+	if _, err := svc.BulkSetUserActive(ctx, []string{"u3", "u4"}, true, false); err != nil {
+		t.Fatalf("BulkSetUserActive: %v", err)
+	}
+
+	// u2 is swapped for u4 after the queue read the pull request but before
+	// it stored its pick.
+	repo.beforeAddReviewers = func() {
+		if _, err := repo.Repository.ReassignReviewer(ctx, pr.ID, "u2", "u4"); err != nil {
+			t.Fatalf("ReassignReviewer: %v", err)
+		}
+	}
+	if err := svc.ProcessPendingAssignments(ctx, time.Now().UTC()); err != nil {
+		t.Fatalf("ProcessPendingAssignments: %v", err)
+	}
+	pr, err = svc.GetPullRequest(ctx, pr.ID)
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if contains(pr.AssignedReviewers, "u2") || !contains(pr.AssignedReviewers, "u4") || len(pr.AssignedReviewers) > 2 {
+		t.Fatalf("expected the reassignment to u4 to stand, got %v", pr.AssignedReviewers)
+	}
+}
+
+func TestPendingAssignmentsSkipFailingPullRequest(t *testing.T) {
+	ctx := context.Background()
+	repo := &faultyRepository{Repository: storagetest.New(t), failing: "pr-177"}
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: false},
+		},
+	})
+	for _, id := range []string{"pr-177", "pr-178"} {
+		if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: id, Name: "Short-handed", AuthorID: "u1"}); err != nil {
+			t.Fatalf("CreatePullRequest: %v", err)
+		}
+	}
+	if _, err := svc.SetUserActive(ctx, "u3", true); err != nil {
+		t.Fatalf("SetUserActive: %v", err)
+	}
+
+	if err := svc.ProcessPendingAssignments(ctx, time.Now().UTC()); err != nil {
+		t.Fatalf("ProcessPendingAssignments: %v", err)
+	}
+	pr, err := svc.GetPullRequest(ctx, "pr-178")
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if !reflect.DeepEqual(pr.AssignedReviewers, []string{"u2", "u3"}) {
+		t.Fatalf("expected pr-178 to be filled past the failing pr-177, got %v", pr.AssignedReviewers)
+	}
+}
+
+func TestSnoozedUserSkipsAutomaticAssignment(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+		},
+	})
+
+	if _, err := svc.SnoozeUser(ctx, "u2", -time.Hour); err != domain.ErrInvalidSnooze {
+		t.Fatalf("expected ErrInvalidSnooze, got %v", err)
+	}
+	if _, err := svc.SnoozeUser(ctx, "missing", time.Hour); err != domain.ErrUserNotFound {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+
+	user, err := svc.SnoozeUser(ctx, "u2", 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("SnoozeUser: %v", err)
+	}
+	if !user.IsActive || !user.Snoozed(time.Now()) {
+		t.Fatalf("expected an active, snoozed user, got %+v", user)
+	}
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-170", Name: "Quiet week", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if contains(pr.AssignedReviewers, "u2") {
+		t.Fatalf("snoozed user was assigned: %v", pr.AssignedReviewers)
+	}
+
+	// Explicit picks still reach a snoozed user.
+	if _, _, err := svc.AddReviewer(ctx, pr.ID, "u2"); err != nil {
+		t.Fatalf("AddReviewer: %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := svc.SnoozeUser(ctx, "u3", time.Hour); err != nil {
+		t.Fatalf("SnoozeUser: %v", err)
+	}
+	pr, err = svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-171", Name: "Back again", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if !contains(pr.AssignedReviewers, "u2") || contains(pr.AssignedReviewers, "u3") {
+		t.Fatalf("expected the expired snooze to lift and u3 to be skipped, got %v", pr.AssignedReviewers)
+	}
+
+	user, err = svc.SnoozeUser(ctx, "u3", 0)
+	if err != nil {
+		t.Fatalf("SnoozeUser: %v", err)
+	}
+	if user.SnoozedUntil != nil {
+		t.Fatalf("expected a zero duration to lift the snooze, got %v", user.SnoozedUntil)
+	}
+}
+
+func TestCachedRepositorySeesItsOwnWrites(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(cache.New(storagetest.New(t), config.StorageCacheConfig{TTL: time.Hour}))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+		},
+	})
+
+	// The first pull request loads the roster and settings into the cache.
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-180", Name: "Warm up", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+
+	if _, err := svc.SetUserActive(ctx, "u3", false); err != nil {
+		t.Fatalf("SetUserActive: %v", err)
+	}
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-181", Name: "Stale roster", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 1 || pr.AssignedReviewers[0] != "u2" {
+		t.Fatalf("expected the deactivation to reach the cached roster, got %v", pr.AssignedReviewers)
+	}
+
+	if _, err := svc.UpdateTeamSettings(ctx, domain.TeamSettings{TeamName: "backend", RequiredReviewers: 2}); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-182", Name: "Stale settings", AuthorID: "u1"}); err != domain.ErrNotEnoughReviewers {
+		t.Fatalf("expected the saved settings to reach the cache, got %v", err)
+	}
+}
+
+func TestFairnessCountsMembersDeactivatedInPeriod(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+		},
+	})
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-190", Name: "Before leave", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.SetUserActive(ctx, "u3", false); err != nil {
+			t.Fatalf("SetUserActive: %v", err)
+		}
+	}
+
+	history, err := svc.TeamHistory(ctx, "backend")
+	if err != nil {
+		t.Fatalf("TeamHistory: %v", err)
+	}
+	if len(history) != 4 {
+		t.Fatalf("expected three joins and one deactivation, got %+v", history)
+	}
+	last := history[len(history)-1]
+	if last.UserID != "u3" || last.Kind != domain.MembershipDeactivated || last.IsActive {
+		t.Fatalf("unexpected last change %+v", last)
+	}
+	if _, err := svc.TeamHistory(ctx, "missing"); err != domain.ErrTeamNotFound {
+		t.Fatalf("expected ErrTeamNotFound, got %v", err)
+	}
+
+	report, err := svc.FairnessReport(ctx, "backend", 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("FairnessReport: %v", err)
+	}
+	for _, member := range report.Members {
+		if !member.ActiveInPeriod {
+			t.Fatalf("expected %s to count as active in the period", member.UserID)
+		}
+		if member.UserID == "u3" && member.IsActive {
+			t.Fatalf("expected u3 to be inactive now")
+		}
+	}
+	// Assignments of [0, 1, 1] across the three members.
+	if report.Gini < 0.33 || report.Gini > 0.34 {
+		t.Fatalf("expected the deactivated member to take part in the gini, got %v", report.Gini)
+	}
+}
+
+func TestTransferUserHandsOverOpenReviews(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+		},
+	})
+	createTeam(t, ctx, svc, domain.Team{
+		Name:    "frontend",
+		Members: []domain.User{{ID: "f1", Username: "Fiona", IsActive: true}},
+	})
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-200", Name: "Handover", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	leaving := pr.AssignedReviewers[0]
+
+	missing := "mobile"
+	if _, _, err := svc.UpdateUser(ctx, leaving, domain.UserUpdate{TeamName: &missing}, true); err != domain.ErrTeamNotFound {
+		t.Fatalf("expected ErrTeamNotFound, got %v", err)
+	}
+
+	username, team := "Renamed", "frontend"
+	user, handovers, err := svc.UpdateUser(ctx, leaving, domain.UserUpdate{Username: &username, TeamName: &team}, true)
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if user.Username != "Renamed" || user.TeamName != "frontend" || len(user.Teams) != 1 {
+		t.Fatalf("unexpected user after transfer: %+v", user)
+	}
+	if len(handovers) != 1 || handovers[0].PullRequestID != pr.ID || handovers[0].ReplacedBy == "" {
+		t.Fatalf("expected the open review to be handed over, got %+v", handovers)
+	}
+
+	updated, err := svc.GetPullRequest(ctx, pr.ID)
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if contains(updated.AssignedReviewers, leaving) || !contains(updated.AssignedReviewers, handovers[0].ReplacedBy) {
+		t.Fatalf("expected %s replaced by %s, got %v", leaving, handovers[0].ReplacedBy, updated.AssignedReviewers)
+	}
+
+	backend, err := svc.GetTeam(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeam: %v", err)
+	}
+	for _, member := range backend.Members {
+		if member.ID == leaving {
+			t.Fatalf("transferred user is still a backend member")
+		}
+	}
+
+	history, err := svc.TeamHistory(ctx, "backend")
+	if err != nil {
+		t.Fatalf("TeamHistory: %v", err)
+	}
+	last := history[len(history)-1]
+	if last.UserID != leaving || last.Kind != domain.MembershipLeft {
+		t.Fatalf("expected a leave entry, got %+v", last)
+	}
+}
+
+func TestSplitAndMergeTeams(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+		},
+	})
+	required := 1
+	settings, err := svc.GetTeamSettings(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	settings.RequiredReviewers = required
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+
+	split := domain.TeamSplit{SourceTeam: "backend", NewTeam: "payments", UserIDs: []string{"u3", "u4"}}
+	if _, _, err := svc.SplitTeam(ctx, domain.TeamSplit{SourceTeam: "backend", NewTeam: "payments", UserIDs: []string{"u3", "x9"}}); err != domain.ErrInvalidTeamSplit {
+		t.Fatalf("expected ErrInvalidTeamSplit, got %v", err)
+	}
+	remaining, created, err := svc.SplitTeam(ctx, split)
+	if err != nil {
+		t.Fatalf("SplitTeam: %v", err)
+	}
+	if len(remaining.Members) != 2 || len(created.Members) != 2 {
+		t.Fatalf("unexpected rosters after split: %+v / %+v", remaining.Members, created.Members)
+	}
+	moved, err := svc.GetTeamSettings(ctx, "payments")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	if moved.RequiredReviewers != required {
+		t.Fatalf("expected the new team to inherit settings, got %+v", moved)
+	}
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-300", Name: "Split", AuthorID: "u3"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 1 || pr.AssignedReviewers[0] != "u4" {
+		t.Fatalf("expected the review to stay within the new team, got %v", pr.AssignedReviewers)
+	}
+
+	if _, err := svc.MergeTeams(ctx, "payments", "payments"); err != domain.ErrInvalidTeamMerge {
+		t.Fatalf("expected ErrInvalidTeamMerge, got %v", err)
+	}
+	merged, err := svc.MergeTeams(ctx, "payments", "backend")
+	if err != nil {
+		t.Fatalf("MergeTeams: %v", err)
+	}
+	if len(merged.Members) != 4 {
+		t.Fatalf("expected all four members after merge, got %+v", merged.Members)
+	}
+	if _, err := svc.GetTeam(ctx, "payments"); err != domain.ErrTeamNotFound {
+		t.Fatalf("expected the source team to be gone, got %v", err)
+	}
+
+	history, err := svc.TeamHistory(ctx, "backend")
+	if err != nil {
+		t.Fatalf("TeamHistory: %v", err)
+	}
+	var kinds []domain.MembershipChangeKind
+	for _, change := range history {
+		if change.UserID == "u3" {
+			kinds = append(kinds, change.Kind)
+		}
+	}
+	expected := []domain.MembershipChangeKind{domain.MembershipJoined, domain.MembershipLeft, domain.MembershipJoined}
+	if len(kinds) != len(expected) || kinds[0] != expected[0] || kinds[1] != expected[1] || kinds[2] != expected[2] {
+		t.Fatalf("expected u3 history %v, got %v", expected, kinds)
+	}
+}
+
+func TestReviewCooldownPrefersFreshReviewers(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: "u5", Username: "Eve", IsActive: true},
+		},
+	})
+	settings, err := svc.GetTeamSettings(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	settings.ReviewCooldown = 24 * time.Hour
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+
+	first, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-400", Name: "First", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if _, err := svc.MergePullRequest(ctx, first.ID); err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
+	}
+
+	second, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-401", Name: "Second", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	for _, reviewer := range second.AssignedReviewers {
+		if contains(first.AssignedReviewers, reviewer) {
+			t.Fatalf("expected fresh reviewers, %s reviewed %v", reviewer, first.AssignedReviewers)
+		}
+	}
+
+	trace, err := svc.AssignmentTrace(ctx, second.ID)
+	if err != nil {
+		t.Fatalf("AssignmentTrace: %v", err)
+	}
+	for _, candidate := range trace[0].Candidates {
+		if contains(first.AssignedReviewers, candidate.UserID) && candidate.ExcludedBy != domain.FilterCooldown {
+			t.Fatalf("expected %s held back by the cooldown, got %+v", candidate.UserID, candidate)
+		}
+	}
+
+	// Without fresh members the cooldown gives way.
+	for _, reviewer := range second.AssignedReviewers {
+		if _, err := svc.SetUserActive(ctx, reviewer, false); err != nil {
+			t.Fatalf("SetUserActive: %v", err)
+		}
+	}
+	third, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-402", Name: "Third", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if len(third.AssignedReviewers) != 2 || !contains(first.AssignedReviewers, third.AssignedReviewers[0]) || !contains(first.AssignedReviewers, third.AssignedReviewers[1]) {
+		t.Fatalf("expected the cooling reviewers %v, got %v", first.AssignedReviewers, third.AssignedReviewers)
+	}
+}
+
+func TestAvoidPreviousReviewers(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: "u5", Username: "Eve", IsActive: true},
+		},
+	})
+	settings, err := svc.GetTeamSettings(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	settings.AvoidPreviousReviewers = true
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+
+	createAndMerge := func(id string) domain.PullRequest {
+		t.Helper()
+		pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: id, Name: id, AuthorID: "u1"})
+		if err != nil {
+			t.Fatalf("CreatePullRequest %s: %v", id, err)
+		}
+		if _, err := svc.MergePullRequest(ctx, id); err != nil {
+			t.Fatalf("MergePullRequest %s: %v", id, err)
+		}
+		return pr
+	}
+	disjoint := func(a, b []string) bool {
+		for _, reviewer := range a {
+			if contains(b, reviewer) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Only the last merged pull request counts: the third one goes back to
+	// the reviewers of the first.
+	first := createAndMerge("pr-500")
+	second := createAndMerge("pr-501")
+	if !disjoint(first.AssignedReviewers, second.AssignedReviewers) {
+		t.Fatalf("expected fresh reviewers, got %v after %v", second.AssignedReviewers, first.AssignedReviewers)
+	}
+	third, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-502", Name: "Third", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if !disjoint(second.AssignedReviewers, third.AssignedReviewers) {
+		t.Fatalf("expected fresh reviewers, got %v after %v", third.AssignedReviewers, second.AssignedReviewers)
+	}
+
+	trace, err := svc.AssignmentTrace(ctx, third.ID)
+	if err != nil {
+		t.Fatalf("AssignmentTrace: %v", err)
+	}
+	if !contains(trace[0].Filters, domain.FilterPrevious) {
+		t.Fatalf("expected the previous reviewers filter, got %v", trace[0].Filters)
+	}
+	for _, candidate := range trace[0].Candidates {
+		if contains(second.AssignedReviewers, candidate.UserID) && candidate.ExcludedBy != domain.FilterPrevious {
+			t.Fatalf("expected %s held back as a previous reviewer, got %+v", candidate.UserID, candidate)
+		}
+	}
+
+	// A pool too small to avoid them falls back to the previous reviewers.
+	for _, reviewer := range third.AssignedReviewers {
+		if _, err := svc.SetUserActive(ctx, reviewer, false); err != nil {
+			t.Fatalf("SetUserActive: %v", err)
+		}
+	}
+	fourth, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-503", Name: "Fourth", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if len(fourth.AssignedReviewers) != 2 || disjoint(second.AssignedReviewers, fourth.AssignedReviewers) {
+		t.Fatalf("expected the previous reviewers %v, got %v", second.AssignedReviewers, fourth.AssignedReviewers)
+	}
+}
+
+func TestCreateTeamWithMemberOfAnotherTeam(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+		},
+	})
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-180", Name: "Handover", AuthorID: "u1", ReviewersCount: 1})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	leaving := pr.AssignedReviewers[0]
+
+	payments := domain.Team{
+		Name: "payments",
+		Members: []domain.User{
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: leaving, Username: "Moved", IsActive: true},
+		},
+	}
+	if _, _, err := svc.CreateTeam(ctx, payments, domain.ConflictReject); !errors.Is(err, domain.ErrUserInOtherTeam) {
+		t.Fatalf("expected ErrUserInOtherTeam, got %v", err)
+	}
+	if _, err := svc.GetTeam(ctx, "payments"); err != domain.ErrTeamNotFound {
+		t.Fatalf("expected team not to be created, got %v", err)
+	}
+
+	_, handovers, err := svc.CreateTeam(ctx, payments, domain.ConflictTransfer)
+	if err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+	if len(handovers) != 1 || handovers[0].PullRequestID != pr.ID || handovers[0].ReplacedBy == "" {
+		t.Fatalf("expected the open review to be handed over, got %+v", handovers)
+	}
+
+	backend, err := svc.GetTeam(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeam: %v", err)
+	}
+	for _, member := range backend.Members {
+		if member.ID == leaving {
+			t.Fatalf("expected %s to leave backend, got %+v", leaving, backend.Members)
+		}
+	}
+	pr, err = svc.GetPullRequest(ctx, pr.ID)
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if contains(pr.AssignedReviewers, leaving) {
+		t.Fatalf("expected %s to give up the review, got %v", leaving, pr.AssignedReviewers)
+	}
+}
+
+func TestBulkSetUserActiveHandsOverReviews(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: "u5", Username: "Eve", IsActive: true},
+		},
+	})
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-210", Name: "Holidays", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	squad := append([]string{}, pr.AssignedReviewers...)
+
+	results, err := svc.BulkSetUserActive(ctx, append(squad, "ghost"), false, true)
+	if err != nil {
+		t.Fatalf("BulkSetUserActive: %v", err)
+	}
+	if len(results) != 3 || results[2].UserID != "ghost" || results[2].Err != domain.ErrUserNotFound {
+		t.Fatalf("expected the unknown user to fail alone, got %+v", results)
+	}
+	for _, result := range results[:2] {
+		if result.Err != nil || result.User.IsActive {
+			t.Fatalf("expected %s deactivated, got %+v", result.UserID, result)
+		}
+		if len(result.Reassigned) != 1 || result.Reassigned[0].PullRequestID != pr.ID {
+			t.Fatalf("expected the open review of %s handed over, got %+v", result.UserID, result.Reassigned)
+		}
+		if replacement := result.Reassigned[0].ReplacedBy; replacement == "" || contains(squad, replacement) {
+			t.Fatalf("expected a replacement outside the squad, got %q", replacement)
+		}
+	}
+
+	updated, err := svc.GetPullRequest(ctx, pr.ID)
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	for _, reviewer := range updated.AssignedReviewers {
+		if contains(squad, reviewer) {
+			t.Fatalf("deactivated user %s still reviews %s", reviewer, pr.ID)
+		}
+	}
+
+	history, err := svc.TeamHistory(ctx, "backend")
+	if err != nil {
+		t.Fatalf("TeamHistory: %v", err)
+	}
+	deactivated := 0
+	for _, change := range history {
+		if change.Kind == domain.MembershipDeactivated {
+			deactivated++
+		}
+	}
+	if deactivated != 2 {
+		t.Fatalf("expected 2 deactivation entries, got %d", deactivated)
+	}
+}
+
+func TestBulkSetUserActiveRollsBackOnFailedHandover(t *testing.T) {
+	ctx := context.Background()
+	repo := &snapshotRepository{Repository: storagetest.New(t), t: t}
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: "u5", Username: "Eve", IsActive: true},
+		},
+	})
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-1", Name: "Holidays", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	squad := append([]string{}, pr.AssignedReviewers...)
+
+	repo.failDecision = pr.ID
+	if _, err := svc.BulkSetUserActive(ctx, squad, false, true); !errors.Is(err, errStorageFault) {
+		t.Fatalf("expected the failed hand-over to fail the batch, got %v", err)
+	}
+
+	team, err := svc.GetTeam(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeam: %v", err)
+	}
+	for _, member := range team.Members {
+		if !member.IsActive {
+			t.Errorf("expected %s to stay active", member.ID)
+		}
+	}
+	assertReviewers(t, ctx, svc, pr.ID, squad)
+	assertNoMembershipChange(t, ctx, svc, "backend", domain.MembershipDeactivated)
+}
+
+func TestUpdateUserRollsBackOnFailedHandover(t *testing.T) {
+	ctx := context.Background()
+	repo := &snapshotRepository{Repository: storagetest.New(t), t: t}
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+		},
+	})
+	createTeam(t, ctx, svc, domain.Team{
+		Name:    "frontend",
+		Members: []domain.User{{ID: "u9", Username: "Ivan", IsActive: true}},
+	})
+	// u2 reviews both pull requests; the first hand-over goes through and the
+	// second one fails.
+	for _, id := range []string{"pr-1", "pr-2"} {
+		if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: id, Name: id, AuthorID: "u1"}); err != nil {
+			t.Fatalf("CreatePullRequest %s: %v", id, err)
+		}
+		if _, err := svc.AssignReviewers(ctx, id, []string{"u2", "u3"}); err != nil {
+			t.Fatalf("AssignReviewers %s: %v", id, err)
+		}
+	}
+	mover := "u2"
+
+	repo.failDecision = "pr-2"
+	frontend := "frontend"
+	if _, _, err := svc.UpdateUser(ctx, mover, domain.UserUpdate{TeamName: &frontend}, true); !errors.Is(err, errStorageFault) {
+		t.Fatalf("expected the failed hand-over to fail the transfer, got %v", err)
+	}
+
+	team, err := svc.GetTeam(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeam: %v", err)
+	}
+	if !slices.ContainsFunc(team.Members, func(member domain.User) bool { return member.ID == mover }) {
+		t.Errorf("expected %s to stay in backend, got %+v", mover, team.Members)
+	}
+	for _, id := range []string{"pr-1", "pr-2"} {
+		assertReviewers(t, ctx, svc, id, []string{"u2", "u3"})
+	}
+	assertNoMembershipChange(t, ctx, svc, "backend", domain.MembershipLeft)
+}
+
+func TestShadowReviewerDoesNotCountAsReview(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "t1", Username: "Trainee", IsActive: true},
+		},
+	})
+	createTeam(t, ctx, svc, domain.Team{
+		Name:    "frontend",
+		Members: []domain.User{{ID: "f1", Username: "Fiona", IsActive: true}},
+	})
+	if _, err := svc.SetRotation(ctx, domain.Rotation{TeamName: "backend", UserIDs: []string{"u2", "u3"}}); err != nil {
+		t.Fatalf("SetRotation: %v", err)
+	}
+
+	settings := domain.DefaultTeamSettings("backend")
+	settings.ShadowPool = []string{"f1"}
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != domain.ErrInvalidSettings {
+		t.Fatalf("expected ErrInvalidSettings for a trainee of another team, got %v", err)
+	}
+	settings.ShadowPool = []string{"t1", "t1"}
+	saved, err := svc.UpdateTeamSettings(ctx, settings)
+	if err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+	if len(saved.ShadowPool) != 1 {
+		t.Fatalf("expected the duplicate trainee dropped, got %v", saved.ShadowPool)
+	}
+
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-220", Name: "Training", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if pr.ShadowReviewer != "t1" || len(pr.AssignedReviewers) != 2 || contains(pr.AssignedReviewers, "t1") {
+		t.Fatalf("expected u2 and u3 shadowed by t1, got %+v", pr)
+	}
+
+	first, err := svc.RecordReview(ctx, pr.ID, "t1", domain.ReviewApproval)
+	if err != nil {
+		t.Fatalf("RecordReview shadow: %v", err)
+	}
+	if first {
+		t.Fatalf("a shadow review must not count as review activity")
+	}
+	first, err = svc.RecordReview(ctx, pr.ID, "u2", domain.ReviewComment)
+	if err != nil {
+		t.Fatalf("RecordReview: %v", err)
+	}
+	if !first {
+		t.Fatalf("expected the required reviewer's comment to be the first review")
+	}
+}
+
+func TestReviewerPlaces(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: "t1", Username: "Trainee", IsActive: true},
+		},
+	})
+	if _, err := svc.SetRotation(ctx, domain.Rotation{TeamName: "backend", UserIDs: []string{"u3", "u2"}}); err != nil {
+		t.Fatalf("SetRotation: %v", err)
+	}
+	settings := domain.DefaultTeamSettings("backend")
+	settings.ShadowPool = []string{"t1"}
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+
+	places := func(pr domain.PullRequest) []string {
+		var got []string
+		for _, reviewer := range pr.Reviewers {
+			got = append(got, reviewer.UserID+":"+string(reviewer.Role))
+		}
+		return got
+	}
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-1", Name: "Feature", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if want := []string{"u3:primary", "u2:secondary", "t1:shadow"}; !reflect.DeepEqual(places(pr), want) {
+		t.Fatalf("expected reviewers %v in the order picked, got %v", want, places(pr))
+	}
+	if !reflect.DeepEqual(pr.AssignedReviewers, []string{"u2", "u3"}) {
+		t.Fatalf("expected the assigned reviewers sorted by ID, got %v", pr.AssignedReviewers)
+	}
+	for _, reviewer := range pr.Reviewers {
+		if !reviewer.AssignedAt.Equal(pr.CreatedAt) {
+			t.Fatalf("expected %s assigned at creation, got %v", reviewer.UserID, reviewer.AssignedAt)
+		}
+	}
+
+	pr, replacedBy, err := svc.ReassignReviewer(ctx, "pr-1", "u3")
+	if err != nil {
+		t.Fatalf("ReassignReviewer: %v", err)
+	}
+	if want := []string{replacedBy + ":primary", "u2:secondary", "t1:shadow"}; !reflect.DeepEqual(places(pr), want) {
+		t.Fatalf("expected the replacement to take the primary place, got %v", places(pr))
+	}
+	if pr.Reviewers[0].AssignedAt.Before(pr.CreatedAt) || !pr.Reviewers[1].AssignedAt.Equal(pr.CreatedAt) {
+		t.Fatalf("expected only the replacement to be assigned anew, got %+v", pr.Reviewers)
+	}
+}
+
+func TestWithRandMakesSelectionReproducible(t *testing.T) {
+	ctx := context.Background()
+
+	assign := func() [][]string {
+		svc := service.New(storagetest.New(t), service.WithRand(42))
+		members := make([]domain.User, 0, 8)
+		for i := 1; i <= 8; i++ {
+			members = append(members, domain.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("User %d", i), IsActive: true})
+		}
+		createTeam(t, ctx, svc, domain.Team{Name: "backend", Members: members})
+
+		var picks [][]string
+		for i := 0; i < 5; i++ {
+			pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: fmt.Sprintf("pr-23%d", i), Name: "Seeded", AuthorID: "u1"})
+			if err != nil {
+				t.Fatalf("CreatePullRequest: %v", err)
+			}
+			picks = append(picks, pr.AssignedReviewers)
+		}
+		return picks
+	}
+
+	first, second := assign(), assign()
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("expected the same picks with the same seed, got %v and %v", first, second)
+	}
+}
+
+// TestParallelCreatePullRequest is meant for the race detector:
+// go test -race ./internal/service -run TestParallelCreatePullRequest
+func TestParallelCreatePullRequest(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	members := make([]domain.User, 0, 10)
+	for i := 1; i <= 10; i++ {
+		members = append(members, domain.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("User %d", i), IsActive: true})
+	}
+	createTeam(t, ctx, svc, domain.Team{Name: "backend", Members: members})
+
+	const workers = 16
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{
+				ID:       fmt.Sprintf("pr-24%02d", i),
+				Name:     "Parallel",
+				AuthorID: fmt.Sprintf("u%d", i%10+1),
+			})
+			if err == nil && len(pr.AssignedReviewers) != 2 {
+				err = fmt.Errorf("%s got reviewers %v", pr.ID, pr.AssignedReviewers)
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("CreatePullRequest: %v", err)
+		}
+	}
+}
+
+func TestDraftGetsReviewersWhenReady(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+		},
+	})
+
+	draft, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-250", Name: "WIP", AuthorID: "u1", Status: domain.StatusDraft})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if draft.Status != domain.StatusDraft || len(draft.AssignedReviewers) != 0 {
+		t.Fatalf("expected a draft without reviewers, got %+v", draft)
+	}
+	if _, _, err := svc.AddReviewer(ctx, draft.ID, "u2"); err != domain.ErrPRDraft {
+		t.Fatalf("expected ErrPRDraft on AddReviewer, got %v", err)
+	}
+	if _, err := svc.MergePullRequest(ctx, draft.ID); err != domain.ErrPRDraft {
+		t.Fatalf("expected ErrPRDraft on merge, got %v", err)
+	}
+
+	ready, err := svc.MarkPullRequestReady(ctx, draft.ID)
+	if err != nil {
+		t.Fatalf("MarkPullRequestReady: %v", err)
+	}
+	if ready.Status != domain.StatusOpen || len(ready.AssignedReviewers) != 2 {
+		t.Fatalf("expected an open pull request with 2 reviewers, got %+v", ready)
+	}
+	again, err := svc.MarkPullRequestReady(ctx, draft.ID)
+	if err != nil {
+		t.Fatalf("MarkPullRequestReady again: %v", err)
+	}
+	if !reflect.DeepEqual(again.AssignedReviewers, ready.AssignedReviewers) {
+		t.Fatalf("expected marking ready twice to keep %v, got %v", ready.AssignedReviewers, again.AssignedReviewers)
+	}
+
+	trace, err := svc.AssignmentTrace(ctx, draft.ID)
+	if err != nil {
+		t.Fatalf("AssignmentTrace: %v", err)
+	}
+	if len(trace) != 1 {
+		t.Fatalf("expected one assignment decision, got %d", len(trace))
+	}
+}
+
+func TestConcurrentMarkReadyPicksOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := &interleavingRepository{Repository: storagetest.New(t)}
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: "u5", Username: "Erin", IsActive: true},
+		},
+	})
+	if _, err := svc.SetRotation(ctx, domain.Rotation{
+		TeamName: "backend",
+		UserIDs:  []string{"u2", "u3", "u4", "u5"},
+	}); err != nil {
+		t.Fatalf("SetRotation: %v", err)
+	}
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-251", Name: "WIP", AuthorID: "u1", Status: domain.StatusDraft}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+
+	// The second call marks the draft ready after the first one read it as a
+	// draft but before the first one stored its picks.
+	var concurrent domain.PullRequest
+	repo.beforeAdvanceRotation = func() {
+		repo.beforeAdvanceRotation = nil
+		var err error
+		concurrent, err = svc.MarkPullRequestReady(ctx, "pr-251")
+		if err != nil {
+			t.Errorf("MarkPullRequestReady concurrent: %v", err)
+		}
+	}
+	ready, err := svc.MarkPullRequestReady(ctx, "pr-251")
+	if err != nil {
+		t.Fatalf("MarkPullRequestReady: %v", err)
+	}
+
+	if !reflect.DeepEqual(concurrent.AssignedReviewers, []string{"u2", "u3"}) {
+		t.Fatalf("expected u2 and u3 from the concurrent call, got %v", concurrent.AssignedReviewers)
+	}
+	if !reflect.DeepEqual(ready.AssignedReviewers, concurrent.AssignedReviewers) {
+		t.Fatalf("expected the first call to return the concurrent picks, got %v", ready.AssignedReviewers)
+	}
+	assertReviewers(t, ctx, svc, "pr-251", []string{"u2", "u3"})
+
+	trace, err := svc.AssignmentTrace(ctx, "pr-251")
+	if err != nil {
+		t.Fatalf("AssignmentTrace: %v", err)
+	}
+	if len(trace) != 1 {
+		t.Fatalf("expected one assignment decision, got %d", len(trace))
+	}
+	events, err := svc.ListChanges(ctx, 0, 100)
+	if err != nil {
+		t.Fatalf("ListChanges: %v", err)
+	}
+	readyEvents := 0
+	for _, event := range events {
+		if event.Type == domain.EventPRReady {
+			readyEvents++
+		}
+	}
+	if readyEvents != 1 {
+		t.Fatalf("expected one ready event, got %d", readyEvents)
+	}
+}
+
+func TestOwnershipRulesPickOwnersFirst(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: "u5", Username: "Eve", IsActive: true},
+		},
+	})
+	createTeam(t, ctx, svc, domain.Team{
+		Name:    "frontend",
+		Members: []domain.User{{ID: "u6", Username: "Frank", IsActive: true}},
+	})
+
+	if _, err := svc.SetOwnership(ctx, domain.Ownership{
+		TeamName: "backend",
+		Rules:    []domain.OwnershipRule{{PathPrefix: "api/", UserIDs: []string{"u6"}}},
+	}); err != domain.ErrInvalidOwnership {
+		t.Fatalf("expected ErrInvalidOwnership for an outside member, got %v", err)
+	}
+	if _, err := svc.SetOwnership(ctx, domain.Ownership{
+		TeamName: "backend",
+		Rules: []domain.OwnershipRule{
+			{PathPrefix: "api/", UserIDs: []string{"u4"}},
+			{PathPrefix: "db/", UserIDs: []string{"u5"}},
+		},
+	}); err != nil {
+		t.Fatalf("SetOwnership: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{
+			ID:       fmt.Sprintf("pr-both-%d", i),
+			Name:     "Schema and handler",
+			AuthorID: "u1",
+			Files:    []string{"api/users.go", "db/schema.sql"},
+		})
+		if err != nil {
+			t.Fatalf("CreatePullRequest: %v", err)
+		}
+		if !reflect.DeepEqual(pr.AssignedReviewers, []string{"u4", "u5"}) {
+			t.Fatalf("expected the owners u4 and u5, got %v", pr.AssignedReviewers)
+		}
+
+		pr, err = svc.CreatePullRequest(ctx, domain.PullRequest{
+			ID:       fmt.Sprintf("pr-api-%d", i),
+			Name:     "Handler",
+			AuthorID: "u1",
+			Files:    []string{"api/users.go", "README.md"},
+		})
+		if err != nil {
+			t.Fatalf("CreatePullRequest: %v", err)
+		}
+		if len(pr.AssignedReviewers) != 2 || !contains(pr.AssignedReviewers, "u4") {
+			t.Fatalf("expected the owner u4 and one more reviewer, got %v", pr.AssignedReviewers)
+		}
+		trace, err := svc.AssignmentTrace(ctx, pr.ID)
+		if err != nil {
+			t.Fatalf("AssignmentTrace: %v", err)
+		}
+		if !reflect.DeepEqual(trace[0].Owners, []string{"u4"}) {
+			t.Fatalf("expected the decision to record owner u4, got %v", trace[0].Owners)
+		}
+	}
+}
+
+type recordingSender struct {
+	sent chan string
+}
+
+func (s *recordingSender) Send(_ context.Context, to domain.NotificationPreference, msg notify.Message) error {
+	s.sent <- to.UserID + ": " + msg.Subject
+	return nil
+}
+
+func TestNotifyAuthorAboutReviews(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+		},
+	})
+
+	if _, err := svc.SetNotificationPreference(ctx, domain.NotificationPreference{
+		UserID: "u1", Channel: domain.NotifyEmail, Address: "not an address",
+	}); err != domain.ErrInvalidNotification {
+		t.Fatalf("expected ErrInvalidNotification, got %v", err)
+	}
+	if _, err := svc.SetNotificationPreference(ctx, domain.NotificationPreference{
+		UserID: "ghost", Channel: domain.NotifySlack,
+	}); err != domain.ErrUserNotFound {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	pref, err := svc.GetNotificationPreference(ctx, "u2")
+	if err != nil || pref.Channel != domain.NotifyNone {
+		t.Fatalf("expected the default channel none, got %+v, %v", pref, err)
+	}
+	pref, err = svc.SetNotificationPreference(ctx, domain.NotificationPreference{
+		UserID: "u1", Channel: domain.NotifySlack, Address: " U0ALICE ",
+	})
+	if err != nil || pref.Address != "U0ALICE" {
+		t.Fatalf("SetNotificationPreference: %+v, %v", pref, err)
+	}
+
+	sender := &recordingSender{sent: make(chan string, 10)}
+	events, stop := svc.SubscribeEvents(nil)
+	defer stop()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		notify.NewWithSenders(svc, map[domain.NotifyChannel]notify.Sender{domain.NotifySlack: sender}, 1).Run(ctx, events)
+	}()
+
+	// u2 keeps the default and is never notified.
+	for _, pr := range []domain.PullRequest{
+		{ID: "pr-quiet", Name: "Quiet", AuthorID: "u2"},
+		{ID: "pr-1", Name: "Search", AuthorID: "u1"},
+	} {
+		if _, err := svc.CreatePullRequest(ctx, pr); err != nil {
+			t.Fatalf("CreatePullRequest %s: %v", pr.ID, err)
+		}
+	}
+	created, err := svc.GetPullRequest(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	if _, _, err := svc.ReassignReviewer(ctx, "pr-1", created.AssignedReviewers[0]); err != nil {
+		t.Fatalf("ReassignReviewer: %v", err)
+	}
+	if _, err := svc.MergePullRequest(ctx, "pr-1"); err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
+	}
+
+	want := []string{
+		"u1: Reviewers assigned to Search",
+		"u1: Reviewer reassigned on Search",
+		"u1: Search was merged",
+	}
+	for _, expected := range want {
+		select {
+		case got := <-sender.sent:
+			if got != expected {
+				t.Fatalf("expected %q, got %q", expected, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %q", expected)
+		}
+	}
+	cancel()
+	<-done
+	select {
+	case got := <-sender.sent:
+		t.Fatalf("unexpected notification %q", got)
+	default:
+	}
+}
+
+func TestNotificationDigest(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)}
+	svc := service.New(storagetest.New(t), service.WithClock(clock))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+		},
+	})
+	// Events from before the digest is switched on are not reported.
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-old", Name: "Old", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if _, err := svc.SetNotificationPreference(ctx, domain.NotificationPreference{
+		UserID: "u2", Channel: domain.NotifySlack, Digest: -time.Hour,
+	}); err != domain.ErrInvalidNotification {
+		t.Fatalf("expected ErrInvalidNotification, got %v", err)
+	}
+	for _, userID := range []string{"u1", "u2", "u3", "u4"} {
+		pref, err := svc.SetNotificationPreference(ctx, domain.NotificationPreference{
+			UserID: userID, Channel: domain.NotifySlack, Digest: time.Hour,
+		})
+		if err != nil || pref.Digest != time.Hour {
+			t.Fatalf("SetNotificationPreference %s: %+v, %v", userID, pref, err)
+		}
+	}
+
+	created, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-1", Name: "Search", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if _, err := svc.MergePullRequest(ctx, "pr-1"); err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
+	}
+
+	sender := &recordingSender{sent: make(chan string, 10)}
+	dispatcher := notify.NewWithSenders(svc, map[domain.NotifyChannel]notify.Sender{domain.NotifySlack: sender}, 1)
+	if sent, err := dispatcher.SendDigests(ctx, svc, clock.Now()); err != nil || sent != 0 {
+		t.Fatalf("expected no digest before the period is over, got %d, %v", sent, err)
+	}
+
+	clock.Advance(time.Hour)
+	want := map[string]bool{"u1: Review digest: 2 updates": true}
+	for _, reviewer := range created.AssignedReviewers {
+		want[reviewer+": Review digest: 2 updates"] = true
+	}
+	sent, err := dispatcher.SendDigests(ctx, svc, clock.Now())
+	if err != nil || sent != len(want) {
+		t.Fatalf("expected %d digests, got %d, %v", len(want), sent, err)
+	}
+	for range want {
+		got := <-sender.sent
+		if !want[got] {
+			t.Fatalf("unexpected digest %q, want %v", got, want)
+		}
+		delete(want, got)
+	}
+
+	clock.Advance(time.Hour)
+	if sent, err := dispatcher.SendDigests(ctx, svc, clock.Now()); err != nil || sent != 0 {
+		t.Fatalf("expected nothing new for the next digest, got %d, %v", sent, err)
+	}
+}
+
+func TestMandatoryReviewer(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+			{ID: "u4", Username: "Dan", IsActive: true},
+			{ID: "lead", Username: "Lead", IsActive: true},
+		},
+	})
+	createTeam(t, ctx, svc, domain.Team{
+		Name:    "frontend",
+		Members: []domain.User{{ID: "u9", Username: "Zed", IsActive: true}},
+	})
+
+	settings, err := svc.GetTeamSettings(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	settings.MandatoryReviewer = "u9"
+	if _, err := svc.UpdateTeamSettings(ctx, settings); !errors.Is(err, domain.ErrInvalidSettings) {
+		t.Fatalf("expected ErrInvalidSettings for a member of another team, got %v", err)
+	}
+	settings.MandatoryReviewer = "lead"
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+
+	// The lead joins on top of the two picked reviewers.
+	for i := 0; i < 10; i++ {
+		pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: fmt.Sprintf("pr-lead-%d", i), Name: "Lead", AuthorID: "u1"})
+		if err != nil {
+			t.Fatalf("CreatePullRequest: %v", err)
+		}
+		if len(pr.AssignedReviewers) != 3 || !contains(pr.AssignedReviewers, "lead") {
+			t.Fatalf("expected the lead and two more reviewers, got %v", pr.AssignedReviewers)
+		}
+	}
+	trace, err := svc.AssignmentTrace(ctx, "pr-lead-0")
+	if err != nil {
+		t.Fatalf("AssignmentTrace: %v", err)
+	}
+	if len(trace) != 1 || trace[0].Mandatory != "lead" {
+		t.Fatalf("expected the decision to name the mandatory reviewer, got %+v", trace)
+	}
+
+	// The lead's own pull requests get the usual reviewers.
+	own, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-lead-own", Name: "Own", AuthorID: "lead"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if len(own.AssignedReviewers) != 2 || contains(own.AssignedReviewers, "lead") {
+		t.Fatalf("expected two reviewers other than the lead, got %v", own.AssignedReviewers)
+	}
+
+	// An inactive lead is skipped.
+	if _, err := svc.SetUserActive(ctx, "lead", false); err != nil {
+		t.Fatalf("SetUserActive: %v", err)
+	}
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-lead-away", Name: "Away", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 2 || contains(pr.AssignedReviewers, "lead") {
+		t.Fatalf("expected two reviewers without the inactive lead, got %v", pr.AssignedReviewers)
+	}
+}
+
+func TestExportEventsToBucket(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	failing := false
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		if r.Method != http.MethodPut ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") ||
+			r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		objects[r.URL.Path] = body
+	}))
+	defer bucket.Close()
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+		},
+	})
+	for i := 0; i < 3; i++ {
+		if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: fmt.Sprintf("pr-export-%d", i), Name: "Export", AuthorID: "u1"}); err != nil {
+			t.Fatalf("CreatePullRequest: %v", err)
+		}
+	}
+	events, err := svc.ListChanges(ctx, 0, 100)
+	if err != nil {
+		t.Fatalf("ListChanges: %v", err)
+	}
+
+	exporter := export.New(svc, config.ExportConfig{
+		Endpoint:  bucket.URL,
+		Bucket:    "audit",
+		Region:    "us-east-1",
+		AccessKey: "access",
+		SecretKey: "secret",
+		Prefix:    "events/",
+		MinAge:    time.Hour,
+		BatchSize: 2,
+		Timeout:   time.Second,
+	})
+
+	// Recent events stay in the database.
+	if exported, err := exporter.Export(ctx, time.Now()); err != nil || exported != 0 {
+		t.Fatalf("expected nothing to export yet, got %d, %v", exported, err)
+	}
+
+	// A failed upload keeps the events.
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	if _, err := exporter.Export(ctx, time.Now().Add(2*time.Hour)); err == nil {
+		t.Fatal("expected the failed upload to be reported")
+	}
+	if left, _ := svc.ListChanges(ctx, 0, 100); len(left) != len(events) {
+		t.Fatalf("expected %d events after a failed upload, got %d", len(events), len(left))
+	}
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+
+	exported, err := exporter.Export(ctx, time.Now().Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if exported != len(events) || len(objects) != (len(events)+1)/2 {
+		t.Fatalf("expected %d events in %d objects, got %d in %d", len(events), (len(events)+1)/2, exported, len(objects))
+	}
+	if left, _ := svc.ListChanges(ctx, 0, 100); len(left) != 0 {
+		t.Fatalf("expected exported events to be pruned, got %d", len(left))
+	}
+
+	var seqs []int64
+	for path, body := range objects {
+		if !strings.HasPrefix(path, "/audit/events/") || !strings.HasSuffix(path, ".jsonl.gz") {
+			t.Fatalf("unexpected object %s", path)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("gzip: %v", err)
+		}
+		dec := json.NewDecoder(zr)
+		for dec.More() {
+			var event struct {
+				Seq  int64  `json:"seq"`
+				Type string `json:"type"`
+			}
+			if err := dec.Decode(&event); err != nil {
+				t.Fatalf("decode %s: %v", path, err)
+			}
+			seqs = append(seqs, event.Seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	for i, event := range events {
+		if seqs[i] != event.Seq {
+			t.Fatalf("expected exported sequence numbers %v to match the events", seqs)
+		}
+	}
+
+	// Sequence numbers keep growing after a prune.
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-export-late", Name: "Late", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	left, err := svc.ListChanges(ctx, 0, 100)
+	if err != nil || len(left) == 0 || left[0].Seq <= events[len(events)-1].Seq {
+		t.Fatalf("expected new events after seq %d, got %v, %v", events[len(events)-1].Seq, left, err)
+	}
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	ctx := context.Background()
+	repo := storagetest.New(t)
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+		},
+	})
+	for _, id := range []string{"pr-self", "pr-future"} {
+		if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: id, Name: id, AuthorID: "u1"}); err != nil {
+			t.Fatalf("CreatePullRequest %s: %v", id, err)
+		}
+	}
+
+	report, err := svc.CheckIntegrity(ctx, false)
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Fatalf("expected consistent data, got %+v", report.Issues)
+	}
+
+	// Break the data behind the service's back.
+	pr, err := repo.GetPullRequest(ctx, "pr-self")
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	pr.AssignedReviewers = []string{"u1", "u2"}
+	if _, err := repo.UpdatePullRequest(ctx, pr); err != nil {
+		t.Fatalf("UpdatePullRequest: %v", err)
+	}
+	if _, _, err := repo.MergePullRequest(ctx, "pr-future", time.Now().Add(24*time.Hour), false); err != nil {
+		t.Fatalf("MergePullRequest: %v", err)
+	}
+
+	report, err = svc.CheckIntegrity(ctx, false)
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	want := []domain.IntegrityIssue{
+		{Check: domain.IntegrityAuthorReviewer, PullRequestID: "pr-self", UserID: "u1", TeamName: "backend"},
+		{Check: domain.IntegrityFutureMerge, PullRequestID: "pr-future"},
+	}
+	if !reflect.DeepEqual(report.Issues, want) {
+		t.Fatalf("expected issues %+v, got %+v", want, report.Issues)
+	}
+	if pr, _ := svc.GetPullRequest(ctx, "pr-self"); !contains(pr.AssignedReviewers, "u1") {
+		t.Fatalf("a check without fix must not change data, got %v", pr.AssignedReviewers)
+	}
+
+	report, err = svc.CheckIntegrity(ctx, true)
+	if err != nil {
+		t.Fatalf("CheckIntegrity with fix: %v", err)
+	}
+	if len(report.Issues) != 2 || !report.Issues[0].Fixed || !report.Issues[1].Fixed {
+		t.Fatalf("expected two fixed issues, got %+v", report.Issues)
+	}
+	if pr, _ := svc.GetPullRequest(ctx, "pr-self"); !reflect.DeepEqual(pr.AssignedReviewers, []string{"u2"}) {
+		t.Fatalf("expected the author dropped from the reviewers, got %v", pr.AssignedReviewers)
+	}
+	if pr, _ := svc.GetPullRequest(ctx, "pr-future"); pr.MergedAt == nil || pr.MergedAt.After(report.CheckedAt) {
+		t.Fatalf("expected merged_at clamped to %v, got %v", report.CheckedAt, pr.MergedAt)
+	}
+
+	report, err = svc.CheckIntegrity(ctx, false)
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Fatalf("expected no issues after the fix, got %+v", report.Issues)
+	}
+}
+
+func TestCheckOrphans(t *testing.T) {
+	ctx := context.Background()
+	repo := storagetest.New(t)
+	svc := service.New(repo)
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+		},
+	})
+	settings, err := svc.GetTeamSettings(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	settings.AcceptTimeout = time.Hour
+	if _, err := svc.UpdateTeamSettings(ctx, settings); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+	pr, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-1", Name: "Feature", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	if _, err := svc.AcceptReview(ctx, "pr-1", "u2"); err != nil {
+		t.Fatalf("AcceptReview: %v", err)
+	}
+	if _, err := svc.CompleteReview(ctx, "pr-1", "u2"); err != nil {
+		t.Fatalf("CompleteReview: %v", err)
+	}
+
+	// Drop u3 without the cleanup the service does after it, as a crash
+	// between the two would.
+	pr.AssignedReviewers = []string{"u2"}
+	if _, err := repo.UpdatePullRequest(ctx, pr); err != nil {
+		t.Fatalf("UpdatePullRequest: %v", err)
+	}
+	if pr, _ := svc.GetPullRequest(ctx, "pr-1"); !reflect.DeepEqual(pr.CompletedReviewers, []string{"u2"}) {
+		t.Fatalf("expected the completion of the kept reviewer to survive the update, got %v", pr.CompletedReviewers)
+	}
+
+	report, err := svc.CheckOrphans(ctx, false)
+	if err != nil {
+		t.Fatalf("CheckOrphans: %v", err)
+	}
+	want := []domain.IntegrityIssue{{Check: domain.IntegrityOrphanedAcceptance, PullRequestID: "pr-1", UserID: "u3"}}
+	if !reflect.DeepEqual(report.Issues, want) {
+		t.Fatalf("expected issues %+v, got %+v", want, report.Issues)
+	}
+
+	report, err = svc.CheckOrphans(ctx, true)
+	if err != nil {
+		t.Fatalf("CheckOrphans with fix: %v", err)
+	}
+	want[0].Fixed = true
+	if !reflect.DeepEqual(report.Issues, want) {
+		t.Fatalf("expected issues %+v, got %+v", want, report.Issues)
+	}
+	report, err = svc.CheckIntegrity(ctx, false)
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Fatalf("expected no issues after the fix, got %+v", report.Issues)
+	}
+}
+
+// benchmarkTeam creates a team of size members, each the author of a pull
+// request with files, so that reads have reviewers and files to collect.
+func benchmarkTeam(b *testing.B, size int) (service.Service, string) {
+	b.Helper()
+	ctx := context.Background()
+	svc := service.New(storagetest.New(b))
+
+	members := make([]domain.User, 0, size)
+	for i := range size {
+		members = append(members, domain.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("User %d", i), IsActive: true})
+	}
+	if _, _, err := svc.CreateTeam(ctx, domain.Team{Name: "backend", Members: members}, domain.ConflictReject); err != nil {
+		b.Fatalf("CreateTeam: %v", err)
+	}
+	for i := range size {
+		if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{
+			ID:       fmt.Sprintf("pr-%d", i),
+			Name:     "Change",
+			AuthorID: fmt.Sprintf("u%d", i),
+			Files:    []string{"go.mod", "main.go"},
+		}); err != nil {
+			b.Fatalf("CreatePullRequest: %v", err)
+		}
+	}
+	return svc, "pr-0"
+}
+
+// BenchmarkGetTeam and BenchmarkGetPullRequest measure the reads behind the
+// team and pull request endpoints. Against Postgres (TEST_STORAGE) they are
+// dominated by round trips to the database.
+func BenchmarkGetTeam(b *testing.B) {
+	ctx := context.Background()
+	svc, _ := benchmarkTeam(b, 20)
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := svc.GetTeam(ctx, "backend"); err != nil {
+			b.Fatalf("GetTeam: %v", err)
+		}
+	}
+}
+
+func BenchmarkGetPullRequest(b *testing.B) {
+	ctx := context.Background()
+	svc, prID := benchmarkTeam(b, 20)
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := svc.GetPullRequest(ctx, prID); err != nil {
+			b.Fatalf("GetPullRequest: %v", err)
+		}
+	}
+}
+
+func TestIdentitiesNormalizeExternalIDs(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+		},
+	})
+
+	added, err := svc.AddIdentity(ctx, domain.Identity{Provider: domain.ProviderGitHub, ExternalID: "  Alice-Dev ", UserID: "u1"})
+	if err != nil {
+		t.Fatalf("AddIdentity: %v", err)
+	}
+	if added.ExternalID != "alice-dev" {
+		t.Fatalf("expected the trimmed lowercase login, got %q", added.ExternalID)
+	}
+
+	user, err := svc.ResolveIdentity(ctx, domain.ProviderGitHub, "ALICE-DEV\t")
+	if err != nil || user.ID != "u1" {
+		t.Fatalf("ResolveIdentity: %+v, %v", user, err)
+	}
+	if _, err := svc.ResolveIdentity(ctx, domain.ProviderGitLab, "alice-dev"); !errors.Is(err, domain.ErrIdentityNotFound) {
+		t.Fatalf("expected ErrIdentityNotFound on another provider, got %v", err)
+	}
+
+	if _, err := svc.AddIdentity(ctx, domain.Identity{Provider: domain.ProviderGitHub, ExternalID: "alice-dev", UserID: "u2"}); !errors.Is(err, domain.ErrIdentityExists) {
+		t.Fatalf("expected ErrIdentityExists for the same login, got %v", err)
+	}
+	for _, identity := range []domain.Identity{
+		{Provider: domain.ProviderEmail, ExternalID: "", UserID: "u2"},
+		{Provider: domain.ProviderEmail, ExternalID: " \t\n", UserID: "u2"},
+		{Provider: "bitbucket", ExternalID: "bob", UserID: "u2"},
+	} {
+		if _, err := svc.AddIdentity(ctx, identity); !errors.Is(err, domain.ErrInvalidIdentity) {
+			t.Fatalf("expected ErrInvalidIdentity for %+v, got %v", identity, err)
+		}
+	}
+
+	identities, err := svc.ListIdentities(ctx, "u2")
+	if err != nil || len(identities) != 0 {
+		t.Fatalf("expected no identities for u2, got %+v, %v", identities, err)
+	}
+}
+
+// fakeClock is a service.Clock that stands still until advanced.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func createTeam(t *testing.T, ctx context.Context, svc service.Service, team domain.Team) {
+	t.Helper()
+	if _, _, err := svc.CreateTeam(ctx, team, domain.ConflictReject); err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+}
+
+func contains(items []string, target string) bool {
+	for _, item := range items {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
+
+type stubDirectory struct {
+	known map[string]bool
+	err   error
+}
+
+func (d *stubDirectory) Missing(_ context.Context, userIDs []string) ([]string, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	var missing []string
+	for _, userID := range userIDs {
+		if !d.known[userID] {
+			missing = append(missing, userID)
+		}
+	}
+	return missing, nil
+}
+
+// faultyRepository fails reads of one pull request, standing in for a
+// storage error that concerns a single item of a background job.
+type faultyRepository struct {
+	storage.Repository
+	failing string
+}
+
+var errStorageFault = errors.New("storage fault")
+
+func (r *faultyRepository) GetPullRequest(ctx context.Context, id string) (domain.PullRequest, error) {
+	if id == r.failing {
+		return domain.PullRequest{}, errStorageFault
+	}
+	return r.Repository.GetPullRequest(ctx, id)
+}
+
+// interleavingRepository runs beforeAddReviewers ahead of AddReviewers and
+// beforeCompareAndUpdate ahead of CompareAndUpdatePullRequest, to change a
+// pull request between its read and the write of new reviewers, and
+// beforeAdvanceRotation ahead of AdvanceRotationPosition, to pick from a
+// rotation between its read and its advance.
+type interleavingRepository struct {
+	storage.Repository
+	beforeAddReviewers     func()
+	beforeCompareAndUpdate func()
+	beforeAdvanceRotation  func()
+}
+
+func (r *interleavingRepository) CompareAndUpdatePullRequest(ctx context.Context, seen, pr domain.PullRequest) (domain.PullRequest, bool, error) {
+	if r.beforeCompareAndUpdate != nil {
+		r.beforeCompareAndUpdate()
+	}
+	return r.Repository.CompareAndUpdatePullRequest(ctx, seen, pr)
+}
+
+func (r *interleavingRepository) AddReviewers(ctx context.Context, prID string, reviewerIDs []string, limit int) (domain.PullRequest, []string, error) {
+	if r.beforeAddReviewers != nil {
+		r.beforeAddReviewers()
+	}
+	return r.Repository.AddReviewers(ctx, prID, reviewerIDs, limit)
+}
+
+func (r *interleavingRepository) AdvanceRotationPosition(ctx context.Context, teamName string, from, to int) error {
+	if r.beforeAdvanceRotation != nil {
+		r.beforeAdvanceRotation()
+	}
+	return r.Repository.AdvanceRotationPosition(ctx, teamName, from, to)
+}
+
+// rotationRollbackRepository rolls the rotation of team back when Atomically
+// fails, standing in for a transactional store as far as the rotation goes.
+type rotationRollbackRepository struct {
+	storage.Repository
+	team string
+}
+
+func (r *rotationRollbackRepository) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	before, err := r.GetRotation(ctx, r.team)
+	if errors.Is(err, domain.ErrRotationNotFound) {
+		return fn(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	if err := fn(ctx); err != nil {
+		after, getErr := r.GetRotation(ctx, r.team)
+		if getErr != nil {
+			return getErr
+		}
+		if advanceErr := r.AdvanceRotationPosition(ctx, r.team, after.Position, before.Position); advanceErr != nil {
+			return advanceErr
+		}
+		return err
+	}
+	return nil
+}
+
+// snapshotRepository rolls a failed transaction back as Postgres would, by
+// restoring the teams, users, pull requests and membership history exported
+// when it began into a fresh store. Nested transactions run in the outer one.
+// failDecision fails the assignment decisions of that pull request.
+type snapshotRepository struct {
+	storage.Repository
+	t            *testing.T
+	failDecision string
+	depth        int
+}
+
+func (r *snapshotRepository) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > 1 {
+		return fn(ctx)
+	}
+
+	snapshot, err := r.ExportSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	var history []domain.MembershipChange
+	for _, team := range snapshot.Teams {
+		changes, err := r.ListMembershipHistory(ctx, team)
+		if err != nil {
+			return err
+		}
+		history = append(history, changes...)
+	}
+
+	if err := fn(ctx); err != nil {
+		fresh := storagetest.New(r.t)
+		if importErr := fresh.ImportSnapshot(ctx, snapshot); importErr != nil {
+			return importErr
+		}
+		if appendErr := fresh.AppendMembershipChanges(ctx, history); appendErr != nil {
+			return appendErr
+		}
+		r.Repository = fresh
+		return err
+	}
+	return nil
+}
+
+func (r *snapshotRepository) AppendAssignmentDecision(ctx context.Context, decision domain.AssignmentDecision) error {
+	if decision.PullRequestID == r.failDecision {
+		return errStorageFault
+	}
+	return r.Repository.AppendAssignmentDecision(ctx, decision)
+}
+
+func assertReviewers(t *testing.T, ctx context.Context, svc service.Service, prID string, want []string) {
+	t.Helper()
+	pr, err := svc.GetPullRequest(ctx, prID)
+	if err != nil {
+		t.Fatalf("GetPullRequest: %v", err)
+	}
+	got := append([]string(nil), pr.AssignedReviewers...)
+	want = append([]string(nil), want...)
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected reviewers %v of %s, got %v", want, prID, got)
+	}
+}
+
+func assertNoMembershipChange(t *testing.T, ctx context.Context, svc service.Service, teamName string, kind domain.MembershipChangeKind) {
+	t.Helper()
+	history, err := svc.TeamHistory(ctx, teamName)
+	if err != nil {
+		t.Fatalf("TeamHistory: %v", err)
+	}
+	for _, change := range history {
+		if change.Kind == kind {
+			t.Errorf("expected no %s change in %s, got %+v", kind, teamName, change)
+		}
+	}
+}
+
+// transactionRepository records which writes run inside Atomically and can
+// fail the assignment decision write to see the transaction rolled back.
+type transactionRepository struct {
+	storage.Repository
+	failDecision bool
+	transactions int
+	rolledBack   error
+	outside      []string
+}
+
+type inTransactionKey struct{}
+
+func (r *transactionRepository) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	r.transactions++
+	err := fn(context.WithValue(ctx, inTransactionKey{}, true))
+	if err != nil {
+		r.rolledBack = err
+	}
+	return err
+}
+
+func (r *transactionRepository) write(ctx context.Context, name string) {
+	if ctx.Value(inTransactionKey{}) == nil {
+		r.outside = append(r.outside, name)
+	}
+}
+
+func (r *transactionRepository) CreatePullRequest(ctx context.Context, pr domain.PullRequest) (domain.PullRequest, error) {
+	r.write(ctx, "CreatePullRequest")
+	return r.Repository.CreatePullRequest(ctx, pr)
+}
+
+func (r *transactionRepository) AppendAssignmentDecision(ctx context.Context, decision domain.AssignmentDecision) error {
+	r.write(ctx, "AppendAssignmentDecision")
+	if r.failDecision {
+		return errStorageFault
+	}
+	return r.Repository.AppendAssignmentDecision(ctx, decision)
+}
+
+func (r *transactionRepository) AppendEvent(ctx context.Context, event domain.Event) (domain.Event, error) {
+	r.write(ctx, "AppendEvent")
+	return r.Repository.AppendEvent(ctx, event)
+}
+
+func TestImportSnapshot(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(storagetest.New(t))
+
+	createTeam(t, ctx, svc, domain.Team{
+		Name: "backend",
+		Members: []domain.User{
+			{ID: "u1", Username: "Alice", IsActive: true},
+			{ID: "u2", Username: "Bob", IsActive: true},
+			{ID: "u3", Username: "Cathy", IsActive: true},
+		},
+	})
+	if _, err := svc.CreatePullRequest(ctx, domain.PullRequest{ID: "pr-1", Name: "Feature", AuthorID: "u1"}); err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	snapshot, err := svc.ExportSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("ExportSnapshot: %v", err)
+	}
+
+	fresh := service.New(storagetest.New(t))
+	result, err := fresh.ImportSnapshot(ctx, snapshot, true)
+	if err != nil {
+		t.Fatalf("ImportSnapshot dry run: %v", err)
+	}
+	if want := (domain.ImportResult{DryRun: true, Teams: 1, Users: 3, PullRequests: 1}); result != want {
+		t.Fatalf("expected %+v, got %+v", want, result)
+	}
+	if _, err := fresh.GetPullRequest(ctx, "pr-1"); !errors.Is(err, domain.ErrPullRequestNotFound) {
+		t.Fatalf("expected a dry run to write nothing, got %v", err)
+	}
+
+	if _, err := fresh.ImportSnapshot(ctx, snapshot, false); err != nil {
+		t.Fatalf("ImportSnapshot: %v", err)
+	}
+	restored, err := fresh.ExportSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("ExportSnapshot after import: %v", err)
+	}
+	restored.ExportedAt = snapshot.ExportedAt
+	if !reflect.DeepEqual(restored, snapshot) {
+		t.Fatalf("expected the import to restore %+v, got %+v", snapshot, restored)
+	}
+	if _, err := fresh.ImportSnapshot(ctx, snapshot, true); !errors.Is(err, domain.ErrNotEmpty) {
+		t.Fatalf("expected ErrNotEmpty for an instance with data, got %v", err)
+	}
+
+	snapshot.PullRequests[0].AuthorID = "ghost"
+	_, err = service.New(storagetest.New(t)).ImportSnapshot(ctx, snapshot, true)
+	var domainErr *domain.Error
+	if !errors.As(err, &domainErr) || domainErr.Code != "INVALID_SNAPSHOT" {
+		t.Fatalf("expected INVALID_SNAPSHOT, got %v", err)
+	}
 }
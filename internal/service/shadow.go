@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"Avito2025/internal/domain"
+)
+
+// normalizeShadowPool drops duplicates from the shadow pool and checks that
+// every trainee in it is a member of the team.
+func (s *ReviewerService) normalizeShadowPool(ctx context.Context, settings *domain.TeamSettings) error {
+	pool := make([]string, 0, len(settings.ShadowPool))
+	for _, userID := range settings.ShadowPool {
+		if contains(pool, userID) {
+			continue
+		}
+		user, err := s.repo.GetUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if !contains(user.Teams, settings.TeamName) {
+			return domain.ErrInvalidSettings
+		}
+		pool = append(pool, userID)
+	}
+	settings.ShadowPool = pool
+	return nil
+}
+
+// pickShadow picks a shadow reviewer at random from the trainees of the
+// team's shadow pool who are available and not already reviewing. Trainees
+// who left the team since the pool was saved are skipped.
+func (s *ReviewerService) pickShadow(settings domain.TeamSettings, members []domain.User, pr domain.PullRequest, now time.Time) string {
+	if len(settings.ShadowPool) == 0 {
+		return ""
+	}
+
+	var candidates []domain.User
+	for _, user := range filterReviewers(members, pr.Authors(), now) {
+		if contains(settings.ShadowPool, user.ID) && !contains(pr.AssignedReviewers, user.ID) {
+			candidates = append(candidates, user)
+		}
+	}
+	_, rnd := s.seededRand()
+	picked := pickReviewers(rnd, candidates, 1)
+	if len(picked) == 0 {
+		return ""
+	}
+	return picked[0]
+}
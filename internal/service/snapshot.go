@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"Avito2025/internal/domain"
+)
+
+// ExportSnapshot captures the teams, users, settings and pull requests for
+// ImportSnapshot on another instance.
+func (s *ReviewerService) ExportSnapshot(ctx context.Context) (domain.Snapshot, error) {
+	snapshot, err := s.repo.ExportSnapshot(ctx)
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+	snapshot.ExportedAt = s.now()
+	return snapshot, nil
+}
+
+// ImportSnapshot restores an exported snapshot into an instance without
+// data. The snapshot is checked as a whole before anything is written, and
+// every problem found is reported at once; with dryRun set nothing is
+// written at all. Like integrity fixes, an import records no events.
+func (s *ReviewerService) ImportSnapshot(ctx context.Context, snapshot domain.Snapshot, dryRun bool) (domain.ImportResult, error) {
+	snapshot = normalizeSnapshot(snapshot)
+	if problems := validateSnapshot(snapshot); len(problems) > 0 {
+		return domain.ImportResult{}, domain.NewInvalidSnapshot(problems)
+	}
+	result := domain.ImportResult{
+		DryRun:       dryRun,
+		Teams:        len(snapshot.Teams),
+		Users:        len(snapshot.Users),
+		PullRequests: len(snapshot.PullRequests),
+	}
+
+	if dryRun {
+		// Users cannot exist without a team, so no teams means no data.
+		teams, err := s.repo.ListTeams(ctx, "", 0, 1)
+		if err != nil {
+			return domain.ImportResult{}, err
+		}
+		if len(teams) > 0 {
+			return domain.ImportResult{}, domain.ErrNotEmpty
+		}
+		return result, nil
+	}
+	if err := s.repo.ImportSnapshot(ctx, snapshot); err != nil {
+		return domain.ImportResult{}, err
+	}
+	return result, nil
+}
+
+// normalizeSnapshot fills in what hand-written snapshots may leave out: a
+// user's memberships default to the primary team and a pull request's
+// priority to normal. Ordered reviewers other than the shadow one get their
+// roles from their places.
+func normalizeSnapshot(snapshot domain.Snapshot) domain.Snapshot {
+	users := make([]domain.User, len(snapshot.Users))
+	for i, user := range snapshot.Users {
+		if len(user.Teams) == 0 && user.TeamName != "" {
+			user.Teams = []string{user.TeamName}
+		}
+		users[i] = user
+	}
+	snapshot.Users = users
+
+	prs := make([]domain.PullRequest, len(snapshot.PullRequests))
+	for i, pr := range snapshot.PullRequests {
+		if pr.Priority == "" {
+			pr.Priority = domain.PriorityNormal
+		}
+		if len(pr.Reviewers) > 0 {
+			var required, shadows []domain.Reviewer
+			for _, reviewer := range pr.Reviewers {
+				if reviewer.Role == domain.RoleShadow {
+					shadows = append(shadows, reviewer)
+				} else {
+					required = append(required, reviewer)
+				}
+			}
+			pr.Reviewers = append(domain.RankReviewers(required, domain.Reviewer{}), shadows...)
+		}
+		prs[i] = pr
+	}
+	snapshot.PullRequests = prs
+	return snapshot
+}
+
+// validateSnapshot lists the problems that would break the references
+// between the snapshot's records or its constraints once stored.
+func validateSnapshot(snapshot domain.Snapshot) []string {
+	var problems []string
+	report := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	teams := make(map[string]bool, len(snapshot.Teams))
+	for i, name := range snapshot.Teams {
+		switch {
+		case name == "":
+			report("teams[%d]: team_name is required", i)
+		case teams[name]:
+			report("teams[%d]: duplicate team %s", i, name)
+		}
+		teams[name] = true
+	}
+
+	users := make(map[string]bool, len(snapshot.Users))
+	for i, user := range snapshot.Users {
+		switch {
+		case user.ID == "":
+			report("users[%d]: user_id is required", i)
+		case users[user.ID]:
+			report("users[%d]: duplicate user %s", i, user.ID)
+		}
+		users[user.ID] = true
+		if !contains(user.Teams, user.TeamName) {
+			report("users[%d]: teams must include team_name %q", i, user.TeamName)
+		}
+		for _, team := range user.Teams {
+			if !teams[team] {
+				report("users[%d]: unknown team %q", i, team)
+			}
+		}
+	}
+
+	settings := make(map[string]bool, len(snapshot.Settings))
+	for _, team := range snapshot.Settings {
+		switch {
+		case !teams[team.TeamName]:
+			report("settings: unknown team %q", team.TeamName)
+		case settings[team.TeamName]:
+			report("settings: duplicate settings of team %s", team.TeamName)
+		}
+		settings[team.TeamName] = true
+		if team.MandatoryReviewer != "" && !users[team.MandatoryReviewer] {
+			report("settings of team %s: unknown mandatory reviewer %s", team.TeamName, team.MandatoryReviewer)
+		}
+		for _, trainee := range team.ShadowPool {
+			if !users[trainee] {
+				report("settings of team %s: unknown shadow pool member %s", team.TeamName, trainee)
+			}
+		}
+	}
+
+	prs := make(map[string]bool, len(snapshot.PullRequests))
+	for i, pr := range snapshot.PullRequests {
+		switch {
+		case pr.ID == "":
+			report("pull_requests[%d]: pull_request_id is required", i)
+		case prs[pr.ID]:
+			report("pull_requests[%d]: duplicate pull request %s", i, pr.ID)
+		}
+		prs[pr.ID] = true
+		if !users[pr.AuthorID] {
+			report("pull_requests[%d]: unknown author %q", i, pr.AuthorID)
+		}
+		if !pr.Status.Valid() {
+			report("pull_requests[%d]: unknown status %q", i, pr.Status)
+		}
+		if !pr.Priority.Valid() {
+			report("pull_requests[%d]: unknown priority %q", i, pr.Priority)
+		}
+		if pr.Status == domain.StatusMerged && pr.MergedAt == nil {
+			report("pull_requests[%d]: merged pull request needs merged_at", i)
+		}
+		if pr.CreatedAt.IsZero() {
+			report("pull_requests[%d]: created_at is required", i)
+		}
+		seen := make(map[string]bool, len(pr.AssignedReviewers))
+		for _, reviewer := range pr.AssignedReviewers {
+			switch {
+			case !users[reviewer]:
+				report("pull_requests[%d]: unknown reviewer %q", i, reviewer)
+			case seen[reviewer]:
+				report("pull_requests[%d]: duplicate reviewer %s", i, reviewer)
+			}
+			seen[reviewer] = true
+		}
+		if pr.ShadowReviewer != "" && !users[pr.ShadowReviewer] {
+			report("pull_requests[%d]: unknown shadow reviewer %s", i, pr.ShadowReviewer)
+		}
+		if len(pr.Reviewers) > 0 && !placesAllReviewers(pr) {
+			report("pull_requests[%d]: reviewers must list the assigned and the shadow reviewer", i)
+		}
+	}
+	return problems
+}
+
+// placesAllReviewers reports whether the ordered reviewers of pr are exactly
+// its assigned reviewers and its shadow reviewer.
+func placesAllReviewers(pr domain.PullRequest) bool {
+	var required []string
+	var shadow string
+	for _, reviewer := range pr.Reviewers {
+		switch {
+		case reviewer.Role != domain.RoleShadow:
+			required = append(required, reviewer.UserID)
+		case shadow != "":
+			return false
+		default:
+			shadow = reviewer.UserID
+		}
+	}
+	slices.Sort(required)
+	return shadow == pr.ShadowReviewer && slices.Equal(required, slices.Sorted(slices.Values(pr.AssignedReviewers)))
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"Avito2025/internal/domain"
+)
+
+// FairnessReport summarises how review assignments were spread across the
+// team's members for PRs created within the period. Gini and the max/min ratio
+// are computed over members that were active for some part of the period, as
+// told by the membership history: inactive ones never receive assignments by
+// design, while a member deactivated since still took their share.
+func (s *ReviewerService) FairnessReport(ctx context.Context, teamName string, period time.Duration) (domain.FairnessReport, error) {
+	since := s.now().Add(-period)
+
+	counts, err := s.repo.CountAssignments(ctx, teamName, since)
+	if err != nil {
+		return domain.FairnessReport{}, err
+	}
+
+	buckets, err := s.repo.AssignmentBuckets(ctx, teamName, since)
+	if err != nil {
+		return domain.FairnessReport{}, err
+	}
+
+	history, err := s.repo.ListMembershipHistory(ctx, teamName)
+	if err != nil {
+		return domain.FairnessReport{}, err
+	}
+	activeInPeriod := activeSince(history, since)
+	for i := range counts {
+		active, known := activeInPeriod[counts[i].UserID]
+		if !known {
+			active = counts[i].IsActive
+		}
+		counts[i].ActiveInPeriod = active
+	}
+
+	report := domain.FairnessReport{
+		TeamName: teamName,
+		Since:    since,
+		Members:  counts,
+		Buckets:  buckets,
+	}
+
+	active := make([]int, 0, len(counts))
+	for _, count := range counts {
+		report.Total += count.Count
+		if count.ActiveInPeriod {
+			active = append(active, count.Count)
+		}
+	}
+	report.Gini = gini(active)
+	report.MaxMinRatio = maxMinRatio(active)
+
+	return report, nil
+}
+
+// ListUnderassigned returns open pull requests with fewer reviewers than their
+// team requires. An empty teamName covers all teams.
+func (s *ReviewerService) ListUnderassigned(ctx context.Context, teamName string) ([]domain.UnderassignedPullRequest, error) {
+	if teamName != "" {
+		if _, err := s.repo.GetTeam(ctx, teamName); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.ListUnderassignedPullRequests(ctx, teamName)
+}
+
+// ListHotPullRequests returns open pull requests reassigned at least
+// minReassignments times, most reassigned first. An empty teamName covers all
+// teams.
+func (s *ReviewerService) ListHotPullRequests(ctx context.Context, teamName string, minReassignments int) ([]domain.HotPullRequest, error) {
+	if teamName != "" {
+		if _, err := s.repo.GetTeam(ctx, teamName); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.ListHotPullRequests(ctx, teamName, minReassignments)
+}
+
+// TeamStats sums up the team's pull requests over the period: how many are
+// open now, the average number of reviewers and the share reassigned among
+// those created within it, its busiest reviewer and its merges per ISO week.
+// Every week of the period is listed, those without merges with a zero count.
+func (s *ReviewerService) TeamStats(ctx context.Context, teamName string, period time.Duration) (domain.TeamStats, error) {
+	now := s.now()
+	since := now.Add(-period)
+
+	stats, err := s.repo.TeamStats(ctx, teamName, since)
+	if err != nil {
+		return domain.TeamStats{}, err
+	}
+	if stats.PullRequests > 0 {
+		stats.ReassignmentRate = float64(stats.Reassigned) / float64(stats.PullRequests)
+	}
+
+	merges := make(map[time.Time]int, len(stats.MergesPerWeek))
+	for _, week := range stats.MergesPerWeek {
+		merges[week.Start] = week.Count
+	}
+	stats.MergesPerWeek = nil
+	for week := domain.WeekStart(since); !week.After(now); week = week.AddDate(0, 0, 7) {
+		stats.MergesPerWeek = append(stats.MergesPerWeek, domain.WeekCount{Start: week, Count: merges[week]})
+	}
+	return stats, nil
+}
+
+// activeSince tells, for every member in the history, whether they were an
+// active member at since or became one afterwards. Members without history
+// are left out for the caller to fall back to their current status.
+func activeSince(history []domain.MembershipChange, since time.Time) map[string]bool {
+	active := make(map[string]bool)
+	for _, change := range history {
+		if change.ChangedAt.After(since) {
+			active[change.UserID] = active[change.UserID] || change.IsActive
+		} else {
+			active[change.UserID] = change.IsActive
+		}
+	}
+	return active
+}
+
+func gini(values []int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+
+	var sum, weighted float64
+	for i, v := range sorted {
+		sum += float64(v)
+		weighted += float64(i+1) * float64(v)
+	}
+	if sum == 0 {
+		return 0
+	}
+
+	n := float64(len(sorted))
+	return 2*weighted/(n*sum) - (n+1)/n
+}
+
+// maxMinRatio returns nil when the ratio is undefined, i.e. when there are no
+// values or the least loaded member has no assignments at all.
+func maxMinRatio(values []int) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+
+	minVal, maxVal := values[0], values[0]
+	for _, v := range values[1:] {
+		if v < minVal {
+			minVal = v
+		}
+		if v > maxVal {
+			maxVal = v
+		}
+	}
+	if minVal == 0 {
+		return nil
+	}
+
+	ratio := float64(maxVal) / float64(minVal)
+	return &ratio
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"Avito2025/internal/domain"
+)
+
+const (
+	// teamTokenPrefix marks team tokens so that they are easy to tell apart
+	// from the admin token and to find in leaked text.
+	teamTokenPrefix       = "rvt_"
+	maxTeamTokenNameBytes = 100
+)
+
+// HashTeamToken returns the hash team tokens are stored and looked up by.
+func HashTeamToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateTeamToken mints a token for the team, issued to the member userID
+// unless it is empty. The returned token is the only one carrying the token
+// itself.
+func (s *ReviewerService) CreateTeamToken(ctx context.Context, teamName, name, userID string) (domain.TeamToken, error) {
+	if name == "" || len(name) > maxTeamTokenNameBytes {
+		return domain.TeamToken{}, domain.ErrInvalidTeamToken
+	}
+	if userID != "" {
+		user, err := s.repo.GetUser(ctx, userID)
+		if err != nil {
+			return domain.TeamToken{}, err
+		}
+		if !contains(user.Teams, teamName) {
+			return domain.TeamToken{}, domain.ErrInvalidTokenUser
+		}
+	}
+	secret, err := s.ids.NewID()
+	if err != nil {
+		return domain.TeamToken{}, err
+	}
+	token := teamTokenPrefix + secret
+	return s.repo.CreateTeamToken(ctx, domain.TeamToken{
+		TeamName: teamName,
+		UserID:   userID,
+		Name:     name,
+		Token:    token,
+		Hash:     HashTeamToken(token),
+	})
+}
+
+func (s *ReviewerService) ListTeamTokens(ctx context.Context, teamName string) ([]domain.TeamToken, error) {
+	if teamName != "" {
+		if _, err := s.repo.GetTeam(ctx, teamName); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.ListTeamTokens(ctx, teamName)
+}
+
+func (s *ReviewerService) DeleteTeamToken(ctx context.Context, id int64) error {
+	return s.repo.DeleteTeamToken(ctx, id)
+}
+
+// AuthorizeTeamToken checks that the team token hashed to tokenHash exists and
+// that the authors of prs are members of its team. An author unknown to the
+// service counts as a member of the team the pull request would register it
+// in. It fails with ErrTeamTokenNotFound for an unknown token and with
+// ErrTokenTeamMismatch for an author of another team.
+func (s *ReviewerService) AuthorizeTeamToken(ctx context.Context, tokenHash string, prs []domain.PullRequest) error {
+	token, err := s.repo.GetTeamTokenByHash(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	for _, pr := range prs {
+		author, err := s.repo.GetUser(ctx, pr.AuthorID)
+		switch {
+		case err == nil:
+			if !contains(author.Teams, token.TeamName) {
+				return domain.ErrTokenTeamMismatch
+			}
+		case errors.Is(err, domain.ErrUserNotFound):
+			// Unknown authors other than the configured bots are not
+			// found when the pull request is created.
+			if teamName, ok := s.botTeams[pr.AuthorID]; ok && teamName != token.TeamName {
+				return domain.ErrTokenTeamMismatch
+			}
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// TeamTokenUser returns the member the team token hashed to tokenHash was
+// issued to. It fails with ErrTeamTokenNotFound for an unknown token and with
+// ErrForbidden for a token issued to no one, such as one of the team's CI.
+func (s *ReviewerService) TeamTokenUser(ctx context.Context, tokenHash string) (string, error) {
+	token, err := s.repo.GetTeamTokenByHash(ctx, tokenHash)
+	if err != nil {
+		return "", err
+	}
+	if token.UserID == "" {
+		return "", domain.ErrForbidden
+	}
+	return token.UserID, nil
+}
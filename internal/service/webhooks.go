@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"Avito2025/internal/domain"
+)
+
+// webhookSecretBytes is the length of generated webhook secrets before hex
+// encoding.
+const webhookSecretBytes = 32
+
+// CreateWebhook registers a webhook. A secret is generated when none is given.
+func (s *ReviewerService) CreateWebhook(ctx context.Context, webhook domain.Webhook) (domain.Webhook, error) {
+	if err := s.normalizeWebhook(&webhook); err != nil {
+		return domain.Webhook{}, err
+	}
+	return s.repo.CreateWebhook(ctx, webhook)
+}
+
+func (s *ReviewerService) GetWebhook(ctx context.Context, id int64) (domain.Webhook, error) {
+	return s.repo.GetWebhook(ctx, id)
+}
+
+func (s *ReviewerService) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	return s.repo.ListWebhooks(ctx)
+}
+
+// UpdateWebhook replaces the URL and events of a webhook. An empty secret
+// keeps the current one.
+func (s *ReviewerService) UpdateWebhook(ctx context.Context, webhook domain.Webhook) (domain.Webhook, error) {
+	existing, err := s.repo.GetWebhook(ctx, webhook.ID)
+	if err != nil {
+		return domain.Webhook{}, err
+	}
+	if webhook.Secret == "" {
+		webhook.Secret = existing.Secret
+	}
+	if err := s.normalizeWebhook(&webhook); err != nil {
+		return domain.Webhook{}, err
+	}
+	return s.repo.UpdateWebhook(ctx, webhook)
+}
+
+func (s *ReviewerService) DeleteWebhook(ctx context.Context, id int64) error {
+	return s.repo.DeleteWebhook(ctx, id)
+}
+
+// ListWebhookDeliveries returns the latest delivery attempts of a webhook,
+// newest first.
+func (s *ReviewerService) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]domain.WebhookDelivery, error) {
+	return s.repo.ListWebhookDeliveries(ctx, webhookID, limit)
+}
+
+func (s *ReviewerService) RecordWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	return s.repo.AppendWebhookDelivery(ctx, delivery)
+}
+
+// ClaimWebhookDispatch takes or renews owner's lease on webhook dispatch and
+// returns the seq of the last event dispatched; ok is false while another
+// owner holds the lease.
+func (s *ReviewerService) ClaimWebhookDispatch(ctx context.Context, owner string, now, until time.Time) (int64, bool, error) {
+	return s.repo.ClaimWebhookDispatch(ctx, owner, now, until)
+}
+
+// AdvanceWebhookDispatch records that the events through seq were
+// dispatched, unless owner has lost the lease meanwhile.
+func (s *ReviewerService) AdvanceWebhookDispatch(ctx context.Context, owner string, seq int64) (bool, error) {
+	return s.repo.AdvanceWebhookDispatch(ctx, owner, seq)
+}
+
+// normalizeWebhook validates the webhook, drops duplicate events and fills in
+// a missing secret.
+func (s *ReviewerService) normalizeWebhook(webhook *domain.Webhook) error {
+	if !validURL(webhook.URL) || len(webhook.Events) == 0 {
+		return domain.ErrInvalidWebhook
+	}
+
+	seen := make(map[domain.WebhookEvent]bool, len(webhook.Events))
+	events := make([]domain.WebhookEvent, 0, len(webhook.Events))
+	for _, event := range webhook.Events {
+		if !event.Valid() {
+			return domain.ErrInvalidWebhook
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	webhook.Events = events
+
+	if webhook.Secret == "" {
+		secret, err := s.ids.NewID()
+		if err != nil {
+			return err
+		}
+		webhook.Secret = secret
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+// Package cache decorates a repository with a write-through, in-memory cache
+// of the lookups made on every assignment: users, team rosters, team settings
+// and the maintenance flag.
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"Avito2025/internal/config"
+	"Avito2025/internal/domain"
+	"Avito2025/internal/storage"
+)
+
+// Repository serves cached reads and refreshes the cache with the result of
+// every write that goes through it. Writes made by other instances are seen
+// once the entries expire, so the TTL bounds how stale a read can be.
+type Repository struct {
+	storage.Repository
+	ttl time.Duration
+
+	mu          sync.Mutex
+	users       map[string]entry[domain.User]
+	members     map[string]entry[[]domain.User]
+	settings    map[string]entry[domain.TeamSettings]
+	maintenance *entry[domain.Maintenance]
+}
+
+type entry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+func (e entry[T]) fresh() bool {
+	return time.Now().Before(e.expires)
+}
+
+func New(next storage.Repository, cfg config.StorageCacheConfig) *Repository {
+	r := &Repository{Repository: next, ttl: cfg.TTL}
+	r.clear()
+	return r
+}
+
+// Decorator returns New as a storage.Decorator.
+func Decorator(cfg config.StorageCacheConfig) storage.Decorator {
+	return func(next storage.Repository) storage.Repository {
+		return New(next, cfg)
+	}
+}
+
+func (r *Repository) Unwrap() storage.Repository {
+	return r.Repository
+}
+
+func (r *Repository) clear() {
+	r.users = make(map[string]entry[domain.User])
+	r.members = make(map[string]entry[[]domain.User])
+	r.settings = make(map[string]entry[domain.TeamSettings])
+	r.maintenance = nil
+}
+
+func newEntry[T any](value T, ttl time.Duration) entry[T] {
+	return entry[T]{value: value, expires: time.Now().Add(ttl)}
+}
+
+func (r *Repository) CreateTeam(ctx context.Context, team domain.Team) (domain.Team, error) {
+	created, err := r.Repository.CreateTeam(ctx, team)
+	if err != nil {
+		return created, err
+	}
+
+	// Members that already existed joined another team, which changes their
+	// team list in every roster they appear in.
+	r.mu.Lock()
+	delete(r.members, created.Name)
+	for _, member := range created.Members {
+		delete(r.users, member.ID)
+		for _, team := range member.Teams {
+			delete(r.members, team)
+		}
+	}
+	r.mu.Unlock()
+	return created, nil
+}
+
+func (r *Repository) GetUser(ctx context.Context, userID string) (domain.User, error) {
+	r.mu.Lock()
+	cached, ok := r.users[userID]
+	r.mu.Unlock()
+	if ok && cached.fresh() {
+		return cloneUser(cached.value), nil
+	}
+
+	user, err := r.Repository.GetUser(ctx, userID)
+	if err != nil {
+		return user, err
+	}
+	r.mu.Lock()
+	r.users[userID] = newEntry(cloneUser(user), r.ttl)
+	r.mu.Unlock()
+	return user, nil
+}
+
+func (r *Repository) EnsureUser(ctx context.Context, user domain.User) (domain.User, bool, error) {
+	stored, created, err := r.Repository.EnsureUser(ctx, user)
+	if err != nil {
+		return stored, created, err
+	}
+	r.storeUser(stored)
+	return stored, created, nil
+}
+
+func (r *Repository) SetUserActive(ctx context.Context, userID string, isActive bool) (domain.User, error) {
+	user, err := r.Repository.SetUserActive(ctx, userID, isActive)
+	if err != nil {
+		return user, err
+	}
+	r.storeUser(user)
+	return user, nil
+}
+
+func (r *Repository) SetUsersActive(ctx context.Context, userIDs []string, isActive bool) ([]domain.UserResult, error) {
+	results, err := r.Repository.SetUsersActive(ctx, userIDs, isActive)
+	if err != nil {
+		return results, err
+	}
+	for _, result := range results {
+		if result.Err == nil {
+			r.storeUser(result.User)
+		}
+	}
+	return results, nil
+}
+
+func (r *Repository) SnoozeUser(ctx context.Context, userID string, until *time.Time) (domain.User, error) {
+	user, err := r.Repository.SnoozeUser(ctx, userID, until)
+	if err != nil {
+		return user, err
+	}
+	r.storeUser(user)
+	return user, nil
+}
+
+func (r *Repository) UpdateUser(ctx context.Context, userID string, update domain.UserUpdate) (domain.User, error) {
+	user, err := r.Repository.UpdateUser(ctx, userID, update)
+	if err != nil {
+		return user, err
+	}
+	// A transfer also removes the user from a roster that is no longer in
+	// their team list.
+	r.mu.Lock()
+	r.members = make(map[string]entry[[]domain.User])
+	r.mu.Unlock()
+	r.storeUser(user)
+	return user, nil
+}
+
+// MergeTeams and SplitTeam move many users between rosters and copy or drop
+// team settings, so they start the cache over.
+func (r *Repository) MergeTeams(ctx context.Context, source, target string) (domain.Team, error) {
+	team, err := r.Repository.MergeTeams(ctx, source, target)
+	if err != nil {
+		return team, err
+	}
+	r.mu.Lock()
+	r.clear()
+	r.mu.Unlock()
+	return team, nil
+}
+
+func (r *Repository) SplitTeam(ctx context.Context, split domain.TeamSplit) (domain.Team, error) {
+	team, err := r.Repository.SplitTeam(ctx, split)
+	if err != nil {
+		return team, err
+	}
+	r.mu.Lock()
+	r.clear()
+	r.mu.Unlock()
+	return team, nil
+}
+
+// ImportSnapshot starts the cache over: rosters read before the import may
+// be cached empty.
+func (r *Repository) ImportSnapshot(ctx context.Context, snapshot domain.Snapshot) error {
+	if err := r.Repository.ImportSnapshot(ctx, snapshot); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.clear()
+	r.mu.Unlock()
+	return nil
+}
+
+// storeUser writes an updated user through and drops the rosters that list it.
+func (r *Repository) storeUser(user domain.User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.users[user.ID] = newEntry(cloneUser(user), r.ttl)
+	delete(r.members, user.TeamName)
+	for _, team := range user.Teams {
+		delete(r.members, team)
+	}
+}
+
+func (r *Repository) ListUsersByTeam(ctx context.Context, teamName string) ([]domain.User, error) {
+	r.mu.Lock()
+	cached, ok := r.members[teamName]
+	r.mu.Unlock()
+	if ok && cached.fresh() {
+		return cloneUsers(cached.value), nil
+	}
+
+	users, err := r.Repository.ListUsersByTeam(ctx, teamName)
+	if err != nil {
+		return users, err
+	}
+	r.mu.Lock()
+	r.members[teamName] = newEntry(cloneUsers(users), r.ttl)
+	r.mu.Unlock()
+	return users, nil
+}
+
+func (r *Repository) GetTeamSettings(ctx context.Context, teamName string) (domain.TeamSettings, error) {
+	r.mu.Lock()
+	cached, ok := r.settings[teamName]
+	r.mu.Unlock()
+	if ok && cached.fresh() {
+		return cloneSettings(cached.value), nil
+	}
+
+	settings, err := r.Repository.GetTeamSettings(ctx, teamName)
+	if err != nil {
+		return settings, err
+	}
+	r.mu.Lock()
+	r.settings[teamName] = newEntry(cloneSettings(settings), r.ttl)
+	r.mu.Unlock()
+	return settings, nil
+}
+
+func (r *Repository) SaveTeamSettings(ctx context.Context, settings domain.TeamSettings) (domain.TeamSettings, error) {
+	saved, err := r.Repository.SaveTeamSettings(ctx, settings)
+	if err != nil {
+		return saved, err
+	}
+	r.mu.Lock()
+	r.settings[saved.TeamName] = newEntry(cloneSettings(saved), r.ttl)
+	r.mu.Unlock()
+	return saved, nil
+}
+
+func (r *Repository) GetMaintenance(ctx context.Context) (domain.Maintenance, error) {
+	r.mu.Lock()
+	cached := r.maintenance
+	r.mu.Unlock()
+	if cached != nil && cached.fresh() {
+		return cached.value, nil
+	}
+
+	maintenance, err := r.Repository.GetMaintenance(ctx)
+	if err != nil {
+		return maintenance, err
+	}
+	r.storeMaintenance(maintenance)
+	return maintenance, nil
+}
+
+func (r *Repository) SetMaintenance(ctx context.Context, enabled bool) (domain.Maintenance, error) {
+	maintenance, err := r.Repository.SetMaintenance(ctx, enabled)
+	if err != nil {
+		return maintenance, err
+	}
+	r.storeMaintenance(maintenance)
+	return maintenance, nil
+}
+
+func (r *Repository) storeMaintenance(maintenance domain.Maintenance) {
+	cached := newEntry(maintenance, r.ttl)
+	r.mu.Lock()
+	r.maintenance = &cached
+	r.mu.Unlock()
+}
+
+// Atomically runs fn in a transaction of the wrapped repository, if it has
+// them. Writes made by fn refresh the cache before they commit, so the cache
+// is dropped when the transaction is rolled back.
+func (r *Repository) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, ok := storage.AsTransactor(r.Repository)
+	if !ok {
+		return fn(ctx)
+	}
+	err := tx.Atomically(ctx, fn)
+	if err != nil {
+		r.mu.Lock()
+		r.clear()
+		r.mu.Unlock()
+	}
+	return err
+}
+
+func (r *Repository) Reset(ctx context.Context) error {
+	err := r.Repository.Reset(ctx)
+	// A failed reset may have been partly applied, so the cache is dropped
+	// either way.
+	r.mu.Lock()
+	r.clear()
+	r.mu.Unlock()
+	return err
+}
+
+func cloneUser(user domain.User) domain.User {
+	user.Teams = append([]string(nil), user.Teams...)
+	if user.SnoozedUntil != nil {
+		until := *user.SnoozedUntil
+		user.SnoozedUntil = &until
+	}
+	return user
+}
+
+func cloneUsers(users []domain.User) []domain.User {
+	result := make([]domain.User, len(users))
+	for i, user := range users {
+		result[i] = cloneUser(user)
+	}
+	return result
+}
+
+func cloneSettings(settings domain.TeamSettings) domain.TeamSettings {
+	settings.WorkDays = append([]time.Weekday(nil), settings.WorkDays...)
+	settings.ShadowPool = append([]string(nil), settings.ShadowPool...)
+	return settings
+}
@@ -0,0 +1,52 @@
+package storage
+
+// Decorator wraps a repository to add a cross-cutting concern such as
+// caching, retries or instrumentation.
+type Decorator func(Repository) Repository
+
+// Chain wraps repo with the decorators so that the first one is outermost:
+// Chain(repo, a, b) serves calls through a, then b, then repo.
+func Chain(repo Repository, decorators ...Decorator) Repository {
+	for i := len(decorators) - 1; i >= 0; i-- {
+		repo = decorators[i](repo)
+	}
+	return repo
+}
+
+// Unwrapper is implemented by decorators to expose the repository they wrap.
+type Unwrapper interface {
+	Unwrap() Repository
+}
+
+// AsStatsProvider looks for a StatsProvider through a chain of decorators.
+func AsStatsProvider(repo Repository) (StatsProvider, bool) {
+	return find[StatsProvider](repo)
+}
+
+// AsMigrationProvider looks for a MigrationProvider through a chain of
+// decorators.
+func AsMigrationProvider(repo Repository) (MigrationProvider, bool) {
+	return find[MigrationProvider](repo)
+}
+
+// AsTransactor looks for a Transactor through a chain of decorators.
+func AsTransactor(repo Repository) (Transactor, bool) {
+	return find[Transactor](repo)
+}
+
+// find returns the first repository in the chain of decorators starting at
+// repo that implements T.
+func find[T any](repo Repository) (T, bool) {
+	for repo != nil {
+		if found, ok := repo.(T); ok {
+			return found, true
+		}
+		unwrapper, ok := repo.(Unwrapper)
+		if !ok {
+			break
+		}
+		repo = unwrapper.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
@@ -0,0 +1,2569 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"Avito2025/internal/domain"
+	"Avito2025/internal/storage"
+)
+
+var _ storage.Repository = (*Store)(nil)
+
+// Store is an in-process Repository. It mirrors the observable behaviour of the
+// postgres store (ordering, error values, upsert semantics) and is meant for
+// tests and local runs where a database is not available.
+type Store struct {
+	mu         sync.RWMutex
+	teams      map[string]time.Time
+	users      map[string]domain.User
+	prs        map[string]domain.PullRequest
+	archived   map[string]domain.PullRequest
+	identities map[identityKey]domain.Identity
+	notify     map[string]domain.NotificationPreference
+	rotations  map[string]domain.Rotation
+	ownership  map[string][]domain.OwnershipRule
+	leads      map[string][]string
+	daily      map[dailyKey]domain.DailyReviewStats
+	// lastDaily is the latest day of the daily aggregates built.
+	lastDaily time.Time
+	settings  map[string]domain.TeamSettings
+	reminders map[string]domain.ReminderStage
+	reviews   map[string]reviewTimes
+	history   []domain.ReviewerChange
+	decisions map[string][]domain.AssignmentDecision
+	// archivedHistory holds reviewer history of archived pull requests.
+	archivedHistory []domain.ReviewerChange
+	events          []domain.Event
+	eventSeq        int64
+	webhooks        map[int64]domain.Webhook
+	deliveries      []domain.WebhookDelivery
+	lastWebhookID   int64
+	lastDeliveryID  int64
+	// dispatch is the webhook dispatch cursor and the lease on it.
+	dispatch    webhookDispatch
+	maintenance domain.Maintenance
+	deferred    map[string]domain.DeferredAssignment
+	pending     map[string]domain.PendingAssignment
+	// acceptances holds the review acceptances by pull request and reviewer.
+	acceptances map[string]map[string]domain.ReviewAcceptance
+	// completions holds when reviewers completed their review by pull
+	// request and reviewer.
+	completions map[string]map[string]time.Time
+	// links holds the blockers of pull requests, by pull request and
+	// blocker.
+	links      map[string]map[string]time.Time
+	membership []domain.MembershipChange
+	// extensions holds the deadline extension requests by ID.
+	extensions      map[int64]domain.DeadlineExtension
+	lastExtensionID int64
+	tokens          map[int64]domain.TeamToken
+	lastTokenID     int64
+}
+
+// reviewTimes holds the first review activity of each kind on a PR.
+type reviewTimes struct {
+	comment  *time.Time
+	approval *time.Time
+}
+
+// dailyKey identifies a row of the daily review aggregates.
+type dailyKey struct {
+	day      string
+	teamName string
+	userID   string
+}
+
+type identityKey struct {
+	provider   domain.IdentityProvider
+	externalID string
+}
+
+func New() *Store {
+	s := &Store{}
+	s.clear()
+	return s
+}
+
+func (s *Store) Close() {}
+
+// Reset drops all data except the maintenance switch.
+func (s *Store) Reset(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clear()
+	return nil
+}
+
+func (s *Store) clear() {
+	s.teams = make(map[string]time.Time)
+	s.users = make(map[string]domain.User)
+	s.prs = make(map[string]domain.PullRequest)
+	s.archived = make(map[string]domain.PullRequest)
+	s.identities = make(map[identityKey]domain.Identity)
+	s.notify = make(map[string]domain.NotificationPreference)
+	s.rotations = make(map[string]domain.Rotation)
+	s.ownership = make(map[string][]domain.OwnershipRule)
+	s.leads = make(map[string][]string)
+	s.daily = make(map[dailyKey]domain.DailyReviewStats)
+	s.lastDaily = time.Time{}
+	s.settings = make(map[string]domain.TeamSettings)
+	s.reminders = make(map[string]domain.ReminderStage)
+	s.reviews = make(map[string]reviewTimes)
+	s.history = nil
+	s.decisions = make(map[string][]domain.AssignmentDecision)
+	s.archivedHistory = nil
+	s.events = nil
+	s.eventSeq = 0
+	s.webhooks = make(map[int64]domain.Webhook)
+	s.deliveries = nil
+	s.lastWebhookID = 0
+	s.lastDeliveryID = 0
+	s.dispatch = webhookDispatch{}
+	s.deferred = make(map[string]domain.DeferredAssignment)
+	s.pending = make(map[string]domain.PendingAssignment)
+	s.acceptances = make(map[string]map[string]domain.ReviewAcceptance)
+	s.completions = make(map[string]map[string]time.Time)
+	s.links = make(map[string]map[string]time.Time)
+	s.membership = nil
+	s.extensions = make(map[int64]domain.DeadlineExtension)
+	s.lastExtensionID = 0
+	s.tokens = make(map[int64]domain.TeamToken)
+	s.lastTokenID = 0
+}
+
+func (s *Store) CreateTeam(ctx context.Context, team domain.Team) (domain.Team, error) {
+	s.mu.Lock()
+	if _, ok := s.teams[team.Name]; ok {
+		s.mu.Unlock()
+		return domain.Team{}, domain.ErrTeamExists
+	}
+
+	s.teams[team.Name] = time.Now().UTC()
+	for _, member := range team.Members {
+		// Existing users keep their primary team and join this one too.
+		if existing, ok := s.users[member.ID]; ok {
+			member.TeamName = existing.TeamName
+			member.Teams = append(existing.Teams, team.Name)
+			member.SnoozedUntil = existing.SnoozedUntil
+			sort.Strings(member.Teams)
+		} else {
+			member.TeamName = team.Name
+			member.Teams = []string{team.Name}
+		}
+		s.users[member.ID] = member
+	}
+	s.mu.Unlock()
+
+	return s.GetTeam(ctx, team.Name)
+}
+
+func (s *Store) GetTeam(_ context.Context, name string) (domain.Team, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.teams[name]; !ok {
+		return domain.Team{}, domain.ErrTeamNotFound
+	}
+
+	members := s.teamMembers(name)
+	for i := range members {
+		members[i].TeamName = name
+	}
+	return domain.Team{
+		Name:    name,
+		Members: members,
+	}, nil
+}
+
+func (s *Store) ListTeams(_ context.Context, prefix string, offset, limit int) ([]domain.TeamSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.teams))
+	for name := range s.teams {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if offset >= len(names) {
+		return nil, nil
+	}
+	names = names[offset:]
+	if len(names) > limit {
+		names = names[:limit]
+	}
+
+	teams := make([]domain.TeamSummary, 0, len(names))
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		teams = append(teams, domain.TeamSummary{Name: name})
+		index[name] = i
+	}
+	for _, user := range s.users {
+		for _, name := range user.Teams {
+			if i, ok := index[name]; ok {
+				teams[i].Members++
+				if user.IsActive {
+					teams[i].ActiveMembers++
+				}
+			}
+		}
+	}
+	for _, pr := range s.prs {
+		if pr.Status != domain.StatusOpen {
+			continue
+		}
+		if i, ok := index[s.users[pr.AuthorID].TeamName]; ok {
+			teams[i].OpenPullRequests++
+		}
+	}
+	return teams, nil
+}
+
+func (s *Store) GetUser(_ context.Context, userID string) (domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	return cloneUser(user), nil
+}
+
+func (s *Store) ListUsers(_ context.Context, filter domain.UserFilter, offset, limit int) ([]domain.UserSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.users))
+	for id, user := range s.users {
+		if filter.TeamName != "" && !containsString(user.Teams, filter.TeamName) ||
+			filter.IsActive != nil && user.IsActive != *filter.IsActive ||
+			!strings.HasPrefix(user.Username, filter.UsernamePrefix) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if offset >= len(ids) {
+		return nil, nil
+	}
+	ids = ids[offset:]
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	users := make([]domain.UserSummary, 0, len(ids))
+	index := make(map[string]int, len(ids))
+	for i, id := range ids {
+		users = append(users, domain.UserSummary{User: cloneUser(s.users[id])})
+		index[id] = i
+	}
+	for _, pr := range s.prs {
+		if pr.Status != domain.StatusOpen {
+			continue
+		}
+		for _, reviewer := range pr.AssignedReviewers {
+			if i, ok := index[reviewer]; ok {
+				users[i].OpenReviews++
+			}
+		}
+	}
+	return users, nil
+}
+
+func (s *Store) EnsureUser(_ context.Context, user domain.User) (domain.User, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.teams[user.TeamName]; !ok {
+		return domain.User{}, false, domain.ErrTeamNotFound
+	}
+	if existing, ok := s.users[user.ID]; ok {
+		return cloneUser(existing), false, nil
+	}
+	user.Teams = []string{user.TeamName}
+	user.SnoozedUntil = nil
+	s.users[user.ID] = user
+	return cloneUser(user), true, nil
+}
+
+func (s *Store) SetUserActive(_ context.Context, userID string, isActive bool) (domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	user.IsActive = isActive
+	s.users[userID] = user
+	return cloneUser(user), nil
+}
+
+func (s *Store) SetUsersActive(_ context.Context, userIDs []string, isActive bool) ([]domain.UserResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]domain.UserResult, len(userIDs))
+	for i, userID := range userIDs {
+		results[i].UserID = userID
+		user, ok := s.users[userID]
+		if !ok {
+			results[i].Err = domain.ErrUserNotFound
+			continue
+		}
+		user.IsActive = isActive
+		s.users[userID] = user
+		results[i].User = cloneUser(user)
+	}
+	return results, nil
+}
+
+func (s *Store) SnoozeUser(_ context.Context, userID string, until *time.Time) (domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	if until != nil {
+		t := *until
+		until = &t
+	}
+	user.SnoozedUntil = until
+	s.users[userID] = user
+	return cloneUser(user), nil
+}
+
+func (s *Store) UpdateUser(_ context.Context, userID string, update domain.UserUpdate) (domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	if update.TeamName != nil && *update.TeamName != user.TeamName {
+		if _, ok := s.teams[*update.TeamName]; !ok {
+			return domain.User{}, domain.ErrTeamNotFound
+		}
+		teams := make([]string, 0, len(user.Teams))
+		for _, team := range user.Teams {
+			if team != user.TeamName && team != *update.TeamName {
+				teams = append(teams, team)
+			}
+		}
+		user.TeamName = *update.TeamName
+		user.Teams = append(teams, user.TeamName)
+		sort.Strings(user.Teams)
+	}
+	if update.Username != nil {
+		user.Username = *update.Username
+	}
+	s.users[userID] = user
+	return cloneUser(user), nil
+}
+
+func (s *Store) AppendMembershipChanges(_ context.Context, changes []domain.MembershipChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, change := range changes {
+		change.ChangedAt = now
+		s.membership = append(s.membership, change)
+	}
+	return nil
+}
+
+func (s *Store) ListMembershipHistory(_ context.Context, teamName string) ([]domain.MembershipChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.teams[teamName]; !ok {
+		return nil, domain.ErrTeamNotFound
+	}
+	var changes []domain.MembershipChange
+	for _, change := range s.membership {
+		if change.TeamName == teamName {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func (s *Store) ListUsersByTeam(_ context.Context, teamName string) ([]domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.teams[teamName]; !ok {
+		return nil, domain.ErrTeamNotFound
+	}
+	return s.teamMembers(teamName), nil
+}
+
+func (s *Store) MergeTeams(ctx context.Context, source, target string) (domain.Team, error) {
+	s.mu.Lock()
+	_, sourceOK := s.teams[source]
+	_, targetOK := s.teams[target]
+	if !sourceOK || !targetOK {
+		s.mu.Unlock()
+		return domain.Team{}, domain.ErrTeamNotFound
+	}
+
+	now := time.Now().UTC()
+	for _, member := range s.teamMembers(source) {
+		teams := make([]string, 0, len(member.Teams))
+		for _, team := range member.Teams {
+			if team != source {
+				teams = append(teams, team)
+			}
+		}
+		if !containsString(teams, target) {
+			teams = append(teams, target)
+			sort.Strings(teams)
+			s.membership = append(s.membership, domain.MembershipChange{
+				TeamName:  target,
+				UserID:    member.ID,
+				Kind:      domain.MembershipJoined,
+				IsActive:  member.IsActive,
+				ChangedAt: now,
+			})
+		}
+		member.Teams = teams
+		if member.TeamName == source {
+			member.TeamName = target
+		}
+		s.users[member.ID] = member
+	}
+	for id, deferred := range s.deferred {
+		if deferred.TeamName == source {
+			deferred.TeamName = target
+			s.deferred[id] = deferred
+		}
+	}
+	s.dropTeam(source)
+	s.mu.Unlock()
+
+	return s.GetTeam(ctx, target)
+}
+
+// dropTeam removes a team along with the rows postgres deletes in cascade.
+func (s *Store) dropTeam(name string) {
+	delete(s.teams, name)
+	delete(s.settings, name)
+	delete(s.rotations, name)
+	delete(s.ownership, name)
+	delete(s.leads, name)
+	for id, token := range s.tokens {
+		if token.TeamName == name {
+			delete(s.tokens, id)
+		}
+	}
+	membership := s.membership[:0]
+	for _, change := range s.membership {
+		if change.TeamName != name {
+			membership = append(membership, change)
+		}
+	}
+	s.membership = membership
+}
+
+func (s *Store) SplitTeam(ctx context.Context, split domain.TeamSplit) (domain.Team, error) {
+	s.mu.Lock()
+	if _, ok := s.teams[split.SourceTeam]; !ok {
+		s.mu.Unlock()
+		return domain.Team{}, domain.ErrTeamNotFound
+	}
+	if _, ok := s.teams[split.NewTeam]; ok {
+		s.mu.Unlock()
+		return domain.Team{}, domain.ErrTeamExists
+	}
+	for _, userID := range split.UserIDs {
+		if !containsString(s.users[userID].Teams, split.SourceTeam) {
+			s.mu.Unlock()
+			return domain.Team{}, domain.ErrInvalidTeamSplit
+		}
+	}
+
+	now := time.Now().UTC()
+	s.teams[split.NewTeam] = now
+	if settings, ok := s.settings[split.SourceTeam]; ok {
+		settings.TeamName = split.NewTeam
+		settings.WorkDays = append([]time.Weekday(nil), settings.WorkDays...)
+		settings.ShadowPool = append([]string(nil), settings.ShadowPool...)
+		s.settings[split.NewTeam] = settings
+	}
+
+	userIDs := append([]string(nil), split.UserIDs...)
+	sort.Strings(userIDs)
+	var left, joined []domain.MembershipChange
+	for _, userID := range userIDs {
+		user := s.users[userID]
+		teams := make([]string, 0, len(user.Teams))
+		for _, team := range user.Teams {
+			if team != split.SourceTeam {
+				teams = append(teams, team)
+			}
+		}
+		user.Teams = append(teams, split.NewTeam)
+		sort.Strings(user.Teams)
+		if user.TeamName == split.SourceTeam {
+			user.TeamName = split.NewTeam
+		}
+		s.users[userID] = user
+
+		change := domain.MembershipChange{UserID: userID, IsActive: user.IsActive, ChangedAt: now}
+		change.TeamName, change.Kind = split.SourceTeam, domain.MembershipLeft
+		left = append(left, change)
+		change.TeamName, change.Kind = split.NewTeam, domain.MembershipJoined
+		joined = append(joined, change)
+	}
+	s.membership = append(append(s.membership, left...), joined...)
+
+	for id, deferred := range s.deferred {
+		if deferred.TeamName != split.SourceTeam {
+			continue
+		}
+		if pr, ok := s.prs[deferred.PullRequestID]; ok && s.users[pr.AuthorID].TeamName == split.NewTeam {
+			deferred.TeamName = split.NewTeam
+			s.deferred[id] = deferred
+		}
+	}
+	s.mu.Unlock()
+
+	return s.GetTeam(ctx, split.NewTeam)
+}
+
+func (s *Store) GetTeamSettings(_ context.Context, teamName string) (domain.TeamSettings, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.teams[teamName]; !ok {
+		return domain.TeamSettings{}, domain.ErrTeamNotFound
+	}
+	if settings, ok := s.settings[teamName]; ok {
+		return settings, nil
+	}
+	return domain.DefaultTeamSettings(teamName), nil
+}
+
+func (s *Store) SaveTeamSettings(_ context.Context, settings domain.TeamSettings) (domain.TeamSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.teams[settings.TeamName]; !ok {
+		return domain.TeamSettings{}, domain.ErrTeamNotFound
+	}
+	settings.WorkDays = append([]time.Weekday(nil), settings.WorkDays...)
+	settings.ShadowPool = append([]string(nil), settings.ShadowPool...)
+	s.settings[settings.TeamName] = settings
+	return settings, nil
+}
+
+func (s *Store) SetRotation(_ context.Context, rotation domain.Rotation) (domain.Rotation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.teams[rotation.TeamName]; !ok {
+		return domain.Rotation{}, domain.ErrTeamNotFound
+	}
+	if len(rotation.UserIDs) == 0 {
+		delete(s.rotations, rotation.TeamName)
+		return rotation, nil
+	}
+
+	seen := make(map[string]bool, len(rotation.UserIDs))
+	for _, userID := range rotation.UserIDs {
+		if seen[userID] || !containsString(s.users[userID].Teams, rotation.TeamName) {
+			return domain.Rotation{}, domain.ErrInvalidRotation
+		}
+		seen[userID] = true
+	}
+
+	rotation.UserIDs = append([]string(nil), rotation.UserIDs...)
+	rotation.Position = 0
+	s.rotations[rotation.TeamName] = rotation
+	return rotation, nil
+}
+
+func (s *Store) GetRotation(_ context.Context, teamName string) (domain.Rotation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rotation, ok := s.rotations[teamName]
+	if !ok {
+		return domain.Rotation{}, domain.ErrRotationNotFound
+	}
+	rotation.UserIDs = append([]string(nil), rotation.UserIDs...)
+	return rotation, nil
+}
+
+func (s *Store) AdvanceRotationPosition(_ context.Context, teamName string, from, to int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rotation, ok := s.rotations[teamName]
+	if !ok {
+		return domain.ErrRotationNotFound
+	}
+	if rotation.Position != from {
+		return domain.ErrRotationConflict
+	}
+	rotation.Position = to
+	s.rotations[teamName] = rotation
+	return nil
+}
+
+func (s *Store) SetOwnership(_ context.Context, ownership domain.Ownership) (domain.Ownership, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.teams[ownership.TeamName]; !ok {
+		return domain.Ownership{}, domain.ErrTeamNotFound
+	}
+	if len(ownership.Rules) == 0 {
+		delete(s.ownership, ownership.TeamName)
+		return domain.Ownership{TeamName: ownership.TeamName}, nil
+	}
+
+	for _, rule := range ownership.Rules {
+		for _, userID := range rule.UserIDs {
+			if !containsString(s.users[userID].Teams, ownership.TeamName) {
+				return domain.Ownership{}, domain.ErrInvalidOwnership
+			}
+		}
+	}
+
+	s.ownership[ownership.TeamName] = cloneOwnershipRules(ownership.Rules)
+	return domain.Ownership{TeamName: ownership.TeamName, Rules: cloneOwnershipRules(ownership.Rules)}, nil
+}
+
+func (s *Store) GetOwnership(_ context.Context, teamName string) (domain.Ownership, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return domain.Ownership{TeamName: teamName, Rules: cloneOwnershipRules(s.ownership[teamName])}, nil
+}
+
+func (s *Store) SetTeamLead(_ context.Context, teamName, userID string, isLead bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.teams[teamName]; !ok {
+		return domain.ErrTeamNotFound
+	}
+	user, ok := s.users[userID]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+
+	leads := make([]string, 0, len(s.leads[teamName])+1)
+	for _, lead := range s.leads[teamName] {
+		if lead != userID {
+			leads = append(leads, lead)
+		}
+	}
+	if isLead {
+		if !containsString(user.Teams, teamName) {
+			return domain.ErrInvalidLead
+		}
+		leads = append(leads, userID)
+		sort.Strings(leads)
+	}
+	s.leads[teamName] = leads
+	return nil
+}
+
+func (s *Store) ListTeamLeads(_ context.Context, teamName string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]string(nil), s.leads[teamName]...), nil
+}
+
+func cloneOwnershipRules(rules []domain.OwnershipRule) []domain.OwnershipRule {
+	if rules == nil {
+		return nil
+	}
+	cloned := make([]domain.OwnershipRule, len(rules))
+	for i, rule := range rules {
+		cloned[i] = domain.OwnershipRule{PathPrefix: rule.PathPrefix, UserIDs: append([]string(nil), rule.UserIDs...)}
+	}
+	return cloned
+}
+
+func (s *Store) SetNotificationPreference(_ context.Context, pref domain.NotificationPreference) (domain.NotificationPreference, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[pref.UserID]; !ok {
+		return domain.NotificationPreference{}, domain.ErrUserNotFound
+	}
+	stored := s.notify[pref.UserID]
+	if stored.Digest > 0 || pref.Digest <= 0 {
+		pref.DigestSeq, pref.DigestSentAt = stored.DigestSeq, stored.DigestSentAt
+	} else {
+		pref.DigestSeq = s.eventSeq
+	}
+	s.notify[pref.UserID] = pref
+	return pref, nil
+}
+
+func (s *Store) GetNotificationPreference(_ context.Context, userID string) (domain.NotificationPreference, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return domain.NotificationPreference{}, domain.ErrUserNotFound
+	}
+	if pref, ok := s.notify[userID]; ok {
+		return pref, nil
+	}
+	return domain.NotificationPreference{UserID: userID, Channel: domain.NotifyNone}, nil
+}
+
+func (s *Store) ListDueDigests(_ context.Context, now time.Time) ([]domain.NotificationPreference, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var due []domain.NotificationPreference
+	for _, pref := range s.notify {
+		if pref.Digest <= 0 || pref.Channel == domain.NotifyNone {
+			continue
+		}
+		if now.Before(pref.DigestSentAt.Add(pref.Digest)) {
+			continue
+		}
+		due = append(due, pref)
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].UserID < due[j].UserID })
+	return due, nil
+}
+
+func (s *Store) MarkDigestSent(_ context.Context, userID string, seq int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pref, ok := s.notify[userID]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	pref.DigestSeq, pref.DigestSentAt = seq, at
+	s.notify[userID] = pref
+	return nil
+}
+
+func (s *Store) AddIdentity(_ context.Context, identity domain.Identity) (domain.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[identity.UserID]; !ok {
+		return domain.Identity{}, domain.ErrUserNotFound
+	}
+	key := identityKey{provider: identity.Provider, externalID: identity.ExternalID}
+	if _, ok := s.identities[key]; ok {
+		return domain.Identity{}, domain.ErrIdentityExists
+	}
+
+	identity.CreatedAt = time.Now().UTC()
+	s.identities[key] = identity
+	return identity, nil
+}
+
+func (s *Store) ListIdentities(_ context.Context, userID string) ([]domain.Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return nil, domain.ErrUserNotFound
+	}
+
+	var identities []domain.Identity
+	for _, identity := range s.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool {
+		if identities[i].Provider != identities[j].Provider {
+			return identities[i].Provider < identities[j].Provider
+		}
+		return identities[i].ExternalID < identities[j].ExternalID
+	})
+	return identities, nil
+}
+
+func (s *Store) ResolveIdentity(_ context.Context, provider domain.IdentityProvider, externalID string) (domain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	identity, ok := s.identities[identityKey{provider: provider, externalID: externalID}]
+	if !ok {
+		return domain.User{}, domain.ErrIdentityNotFound
+	}
+	user, ok := s.users[identity.UserID]
+	if !ok {
+		return domain.User{}, domain.ErrIdentityNotFound
+	}
+	return cloneUser(user), nil
+}
+
+func (s *Store) CreatePullRequest(_ context.Context, pr domain.PullRequest) (domain.PullRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.insertPullRequest(pr); err != nil {
+		return domain.PullRequest{}, err
+	}
+	return s.presentPullRequest(s.prs[pr.ID]), nil
+}
+
+func (s *Store) UpdatePullRequest(_ context.Context, pr domain.PullRequest) (domain.PullRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updatePullRequest(pr)
+}
+
+func (s *Store) CompareAndUpdatePullRequest(_ context.Context, seen, pr domain.PullRequest) (domain.PullRequest, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.prs[pr.ID]
+	if !ok {
+		if _, archived := s.archived[pr.ID]; archived {
+			return domain.PullRequest{}, false, domain.ErrPRMerged
+		}
+		return domain.PullRequest{}, false, domain.ErrPullRequestNotFound
+	}
+	if current.Status != seen.Status || !sameReviewers(current.AssignedReviewers, seen.AssignedReviewers) {
+		return s.presentPullRequest(current), false, nil
+	}
+	updated, err := s.updatePullRequest(pr)
+	if err != nil {
+		return domain.PullRequest{}, false, err
+	}
+	return updated, true, nil
+}
+
+// updatePullRequest stores pr in place of the pull request with its ID. The
+// caller holds s.mu.
+func (s *Store) updatePullRequest(pr domain.PullRequest) (domain.PullRequest, error) {
+	if _, ok := s.prs[pr.ID]; !ok {
+		return domain.PullRequest{}, domain.ErrPullRequestNotFound
+	}
+	if _, ok := s.users[pr.AuthorID]; !ok {
+		return domain.PullRequest{}, domain.ErrUserNotFound
+	}
+	for _, reviewer := range pr.AssignedReviewers {
+		if _, ok := s.users[reviewer]; !ok {
+			return domain.PullRequest{}, domain.ErrUserNotFound
+		}
+	}
+
+	// Changed files and co-authors are recorded once, at creation, and
+	// reassignments are only counted by ReassignReviewer.
+	pr.Files = s.prs[pr.ID].Files
+	pr.CoAuthors = s.prs[pr.ID].CoAuthors
+	pr.Reassignments = s.prs[pr.ID].Reassignments
+	pr.Reviewers = reconcileReviewers(s.prs[pr.ID].Reviewers, pr.AssignedReviewers, pr.ShadowReviewer, time.Now())
+	s.dropCompletions(pr.ID, pr.AssignedReviewers)
+	s.prs[pr.ID] = normalizePullRequest(pr)
+	return s.presentPullRequest(s.prs[pr.ID]), nil
+}
+
+func (s *Store) ReassignReviewer(_ context.Context, prID, oldReviewerID, newReviewerID string) (domain.PullRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr, ok := s.prs[prID]
+	if !ok {
+		if _, archived := s.archived[prID]; archived {
+			return domain.PullRequest{}, domain.ErrPRMerged
+		}
+		return domain.PullRequest{}, domain.ErrPullRequestNotFound
+	}
+	if pr.Status == domain.StatusMerged {
+		return domain.PullRequest{}, domain.ErrPRMerged
+	}
+	if !containsString(pr.AssignedReviewers, oldReviewerID) {
+		return domain.PullRequest{}, domain.ErrReviewerNotFound
+	}
+	replacement, ok := s.users[newReviewerID]
+	if !ok {
+		return domain.PullRequest{}, domain.ErrUserNotFound
+	}
+	if containsString(pr.AssignedReviewers, newReviewerID) || !replacement.IsActive {
+		return domain.PullRequest{}, domain.ErrReassignConflict
+	}
+
+	reviewers := make([]string, len(pr.AssignedReviewers))
+	for i, reviewer := range pr.AssignedReviewers {
+		if reviewer == oldReviewerID {
+			reviewer = newReviewerID
+		}
+		reviewers[i] = reviewer
+	}
+	pr.AssignedReviewers = reviewers
+	pr.Reviewers = append([]domain.Reviewer(nil), pr.Reviewers...)
+	for i, reviewer := range pr.Reviewers {
+		if reviewer.UserID == oldReviewerID && reviewer.Role != domain.RoleShadow {
+			pr.Reviewers[i] = domain.Reviewer{UserID: newReviewerID, Role: reviewer.Role, AssignedAt: time.Now()}
+		}
+	}
+	pr.Reassignments++
+	delete(s.completions[prID], oldReviewerID)
+	s.prs[prID] = normalizePullRequest(pr)
+	return s.presentPullRequest(s.prs[prID]), nil
+}
+
+func (s *Store) AddReviewers(_ context.Context, prID string, reviewerIDs []string, limit int) (domain.PullRequest, []string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr, ok := s.prs[prID]
+	if !ok {
+		if _, archived := s.archived[prID]; archived {
+			return domain.PullRequest{}, nil, domain.ErrPRMerged
+		}
+		return domain.PullRequest{}, nil, domain.ErrPullRequestNotFound
+	}
+	if pr.Status != domain.StatusOpen {
+		return s.presentPullRequest(pr), nil, nil
+	}
+
+	assigned := append([]string(nil), pr.AssignedReviewers...)
+	var added []string
+	for _, reviewerID := range reviewerIDs {
+		if len(assigned) >= limit {
+			break
+		}
+		user, ok := s.users[reviewerID]
+		if !ok || !user.IsActive || containsString(assigned, reviewerID) {
+			continue
+		}
+		assigned = append(assigned, reviewerID)
+		added = append(added, reviewerID)
+	}
+	if len(added) == 0 {
+		return s.presentPullRequest(pr), nil, nil
+	}
+
+	pr.AssignedReviewers = assigned
+	pr.Reviewers = reconcileReviewers(pr.Reviewers, assigned, pr.ShadowReviewer, time.Now())
+	s.prs[prID] = normalizePullRequest(pr)
+	return s.presentPullRequest(s.prs[prID]), added, nil
+}
+
+func (s *Store) MergePullRequest(_ context.Context, id string, mergedAt time.Time, force bool) (domain.PullRequest, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr, ok := s.prs[id]
+	if !ok {
+		pr, ok = s.archived[id]
+	}
+	if !ok {
+		return domain.PullRequest{}, false, domain.ErrPullRequestNotFound
+	}
+	if pr.Status != domain.StatusOpen && (!force || pr.Status != domain.StatusDraft) {
+		return s.presentPullRequest(pr), false, nil
+	}
+
+	mergedAt = mergedAt.UTC()
+	pr.Status = domain.StatusMerged
+	pr.MergedAt = &mergedAt
+	s.prs[id] = pr
+	return s.presentPullRequest(pr), true, nil
+}
+
+func (s *Store) GetPullRequest(_ context.Context, id string) (domain.PullRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pr, ok := s.prs[id]
+	if !ok {
+		pr, ok = s.archived[id]
+	}
+	if !ok {
+		return domain.PullRequest{}, domain.ErrPullRequestNotFound
+	}
+	return s.presentPullRequest(pr), nil
+}
+
+func (s *Store) ArchivePullRequests(_ context.Context, mergedBefore time.Time, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []domain.PullRequest
+	for _, pr := range s.prs {
+		if pr.Status == domain.StatusMerged && pr.MergedAt != nil && pr.MergedAt.Before(mergedBefore) {
+			expired = append(expired, pr)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].MergedAt.Before(*expired[j].MergedAt)
+	})
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+
+	moved := make(map[string]bool, len(expired))
+	for _, pr := range expired {
+		pr.Files = nil
+		s.archived[pr.ID] = pr
+		delete(s.prs, pr.ID)
+		delete(s.reminders, pr.ID)
+		delete(s.reviews, pr.ID)
+		delete(s.decisions, pr.ID)
+		delete(s.acceptances, pr.ID)
+		delete(s.completions, pr.ID)
+		moved[pr.ID] = true
+	}
+	for id, extension := range s.extensions {
+		if moved[extension.PullRequestID] {
+			delete(s.extensions, id)
+		}
+	}
+	for id, blockers := range s.links {
+		if moved[id] {
+			delete(s.links, id)
+			continue
+		}
+		for blocker := range blockers {
+			if moved[blocker] {
+				delete(blockers, blocker)
+			}
+		}
+	}
+
+	history := s.history[:0]
+	for _, change := range s.history {
+		if moved[change.PullRequestID] {
+			s.archivedHistory = append(s.archivedHistory, change)
+			continue
+		}
+		history = append(history, change)
+	}
+	s.history = history
+	return len(expired), nil
+}
+
+func (s *Store) ListReminderCandidates(_ context.Context, createdBefore time.Time) ([]domain.ReminderCandidate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var candidates []domain.ReminderCandidate
+	for _, pr := range s.prs {
+		if pr.Status != domain.StatusOpen || !pr.CreatedAt.Before(createdBefore) {
+			continue
+		}
+		candidates = append(candidates, domain.ReminderCandidate{
+			PullRequestID: pr.ID,
+			TeamName:      s.users[pr.AuthorID].TeamName,
+			CreatedAt:     pr.CreatedAt,
+			Stage:         s.reminders[pr.ID],
+			Deadline:      s.extendedDeadline(pr.ID),
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].CreatedAt.Before(candidates[j].CreatedAt)
+	})
+	return candidates, nil
+}
+
+func (s *Store) SetReminderStage(_ context.Context, prID string, stage domain.ReminderStage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.prs[prID]; !ok {
+		return domain.ErrPullRequestNotFound
+	}
+	s.reminders[prID] = stage
+	return nil
+}
+
+func (s *Store) RecordReview(_ context.Context, prID string, kind domain.ReviewKind, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.prs[prID]; !ok {
+		return false, domain.ErrPullRequestNotFound
+	}
+	times := s.reviews[prID]
+	first := times.comment == nil && times.approval == nil
+	at = at.UTC()
+	switch {
+	case kind == domain.ReviewComment && times.comment == nil:
+		times.comment = &at
+	case kind == domain.ReviewApproval && times.approval == nil:
+		times.approval = &at
+	}
+	s.reviews[prID] = times
+	return first, nil
+}
+
+func (s *Store) AppendReviewerHistory(_ context.Context, changes []domain.ReviewerChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, change := range changes {
+		change.CreatedAt = now
+		s.history = append(s.history, change)
+	}
+	return nil
+}
+
+func (s *Store) ListReviewerHistory(_ context.Context, filter domain.AssignmentHistoryFilter, offset, limit int) ([]domain.ReviewerChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var changes []domain.ReviewerChange
+	for _, history := range [][]domain.ReviewerChange{s.archivedHistory, s.history} {
+		for _, change := range history {
+			if change.ReviewerID != filter.ReviewerID ||
+				!filter.From.IsZero() && change.CreatedAt.Before(filter.From) ||
+				!filter.To.IsZero() && !change.CreatedAt.Before(filter.To) {
+				continue
+			}
+			changes = append(changes, change)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].CreatedAt.Before(changes[j].CreatedAt) })
+	if offset >= len(changes) {
+		return nil, nil
+	}
+	changes = changes[offset:]
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, nil
+}
+
+func (s *Store) AppendAssignmentDecision(_ context.Context, decision domain.AssignmentDecision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	decision.Filters = append([]string(nil), decision.Filters...)
+	decision.Candidates = append([]domain.DecisionCandidate(nil), decision.Candidates...)
+	decision.Selected = append([]string(nil), decision.Selected...)
+	decision.Owners = append([]string(nil), decision.Owners...)
+	decision.CreatedAt = time.Now().UTC()
+	s.decisions[decision.PullRequestID] = append(s.decisions[decision.PullRequestID], decision)
+	return nil
+}
+
+func (s *Store) ListAssignmentDecisions(_ context.Context, prID string) ([]domain.AssignmentDecision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]domain.AssignmentDecision(nil), s.decisions[prID]...), nil
+}
+
+func (s *Store) ListPullRequestsByReviewer(_ context.Context, userID string, filter domain.ReviewFilter) ([]domain.PullRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []domain.PullRequest
+	for _, pr := range s.prs {
+		if !containsString(pr.AssignedReviewers, userID) {
+			continue
+		}
+		if filter.Status != "" && pr.Status != filter.Status {
+			continue
+		}
+		pr := s.presentPullRequest(pr)
+		if filter.NeedsMoreReviewers && !pr.NeedsMoreReviewers {
+			continue
+		}
+		if _, done := s.completions[pr.ID][userID]; done && filter.ExcludeCompleted {
+			continue
+		}
+		if filter.Blocked != nil && (len(pr.BlockedBy) > 0) != *filter.Blocked {
+			continue
+		}
+		pr.AssignedReviewers = nil
+		pr.Reviewers = nil
+		pr.CompletedReviewers = nil
+		pr.BlockedBy = nil
+		result = append(result, pr)
+	}
+
+	key := func(pr domain.PullRequest) *time.Time {
+		if filter.SortBy == domain.SortByMergedAt {
+			return pr.MergedAt
+		}
+		return &pr.CreatedAt
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := key(result[i]), key(result[j])
+		switch {
+		case a == nil && b == nil:
+			return result[i].ID < result[j].ID
+		case a == nil:
+			return false
+		case b == nil:
+			return true
+		case !a.Equal(*b):
+			if filter.Descending {
+				return a.After(*b)
+			}
+			return a.Before(*b)
+		default:
+			return result[i].ID < result[j].ID
+		}
+	})
+	return result, nil
+}
+
+func (s *Store) ListParticipatedPullRequests(_ context.Context, userIDs []string, limit int) ([]domain.PullRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []domain.PullRequest
+	for _, pr := range s.prs {
+		participated := containsString(userIDs, pr.AuthorID)
+		for _, reviewer := range pr.AssignedReviewers {
+			participated = participated || containsString(userIDs, reviewer)
+		}
+		if participated {
+			result = append(result, clonePullRequest(pr))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.After(result[j].CreatedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (s *Store) CountAssignments(_ context.Context, teamName string, since time.Time) ([]domain.AssignmentCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.teams[teamName]; !ok {
+		return nil, domain.ErrTeamNotFound
+	}
+
+	members := s.teamMembers(teamName)
+	counts := make([]domain.AssignmentCount, 0, len(members))
+	for _, member := range members {
+		count := domain.AssignmentCount{
+			UserID:   member.ID,
+			Username: member.Username,
+			IsActive: member.IsActive,
+		}
+		for _, pr := range s.prs {
+			if !pr.CreatedAt.Before(since) && containsString(pr.AssignedReviewers, member.ID) {
+				count.Count++
+			}
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
+}
+
+func (s *Store) CountOpenReviews(_ context.Context, userIDs []string) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, pr := range s.prs {
+		if pr.Status != domain.StatusOpen {
+			continue
+		}
+		for _, reviewer := range pr.AssignedReviewers {
+			if containsString(userIDs, reviewer) {
+				counts[reviewer]++
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (s *Store) CountOpenPullRequests(_ context.Context, authorID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, pr := range s.prs {
+		if pr.AuthorID == authorID && pr.Status != domain.StatusMerged {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) CountCreatedPullRequests(_ context.Context, teamName string, since time.Time) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, pr := range s.prs {
+		if pr.CreatedAt.Before(since) || s.users[pr.AuthorID].TeamName != teamName {
+			continue
+		}
+		counts[pr.AuthorID]++
+	}
+	return counts, nil
+}
+
+func (s *Store) ListRecentReviewers(_ context.Context, authorID string, since time.Time) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var reviewers []string
+	for _, pr := range s.prs {
+		if pr.AuthorID != authorID || pr.Status != domain.StatusMerged || pr.MergedAt == nil || pr.MergedAt.Before(since) {
+			continue
+		}
+		for _, reviewer := range pr.AssignedReviewers {
+			if !seen[reviewer] {
+				seen[reviewer] = true
+				reviewers = append(reviewers, reviewer)
+			}
+		}
+	}
+	sort.Strings(reviewers)
+	return reviewers, nil
+}
+
+func (s *Store) ListPreviousReviewers(_ context.Context, authorID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var last *domain.PullRequest
+	for _, pr := range s.prs {
+		if pr.AuthorID != authorID || pr.Status != domain.StatusMerged || pr.MergedAt == nil {
+			continue
+		}
+		if last == nil || pr.MergedAt.After(*last.MergedAt) ||
+			(pr.MergedAt.Equal(*last.MergedAt) && pr.ID > last.ID) {
+			last = &pr
+		}
+	}
+	if last == nil {
+		return nil, nil
+	}
+	reviewers := append([]string(nil), last.AssignedReviewers...)
+	sort.Strings(reviewers)
+	return reviewers, nil
+}
+
+func (s *Store) ListUnderassignedPullRequests(_ context.Context, teamName string) ([]domain.UnderassignedPullRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []domain.UnderassignedPullRequest
+	for _, pr := range s.prs {
+		team := s.users[pr.AuthorID].TeamName
+		if pr.Status != domain.StatusOpen || (teamName != "" && team != teamName) {
+			continue
+		}
+		required := s.requiredReviewers(pr)
+		if len(pr.AssignedReviewers) >= required {
+			continue
+		}
+		result = append(result, domain.UnderassignedPullRequest{
+			PullRequestID: pr.ID,
+			TeamName:      team,
+			Assigned:      len(pr.AssignedReviewers),
+			Required:      required,
+			CreatedAt:     pr.CreatedAt,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].PullRequestID < result[j].PullRequestID
+	})
+	return result, nil
+}
+
+func (s *Store) ListHotPullRequests(_ context.Context, teamName string, minReassignments int) ([]domain.HotPullRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []domain.HotPullRequest
+	for _, pr := range s.prs {
+		team := s.users[pr.AuthorID].TeamName
+		if pr.Status != domain.StatusOpen || pr.Reassignments < minReassignments || (teamName != "" && team != teamName) {
+			continue
+		}
+		result = append(result, domain.HotPullRequest{
+			PullRequestID:     pr.ID,
+			Name:              pr.Name,
+			AuthorID:          pr.AuthorID,
+			TeamName:          team,
+			Reassignments:     pr.Reassignments,
+			AssignedReviewers: append([]string{}, pr.AssignedReviewers...),
+			CreatedAt:         pr.CreatedAt,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Reassignments != result[j].Reassignments {
+			return result[i].Reassignments > result[j].Reassignments
+		}
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].PullRequestID < result[j].PullRequestID
+	})
+	return result, nil
+}
+
+func (s *Store) AssignmentBuckets(_ context.Context, teamName string, since time.Time) ([]domain.AssignmentBucket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	perDay := make(map[time.Time]int)
+	for _, pr := range s.prs {
+		if pr.CreatedAt.Before(since) {
+			continue
+		}
+		for _, reviewer := range pr.AssignedReviewers {
+			if !containsString(s.users[reviewer].Teams, teamName) {
+				continue
+			}
+			perDay[pr.CreatedAt.UTC().Truncate(24*time.Hour)]++
+		}
+	}
+
+	buckets := make([]domain.AssignmentBucket, 0, len(perDay))
+	for start, count := range perDay {
+		buckets = append(buckets, domain.AssignmentBucket{Start: start, Count: count})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Start.Before(buckets[j].Start)
+	})
+	return buckets, nil
+}
+
+func (s *Store) TeamStats(_ context.Context, teamName string, since time.Time) (domain.TeamStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.teams[teamName]; !ok {
+		return domain.TeamStats{}, domain.ErrTeamNotFound
+	}
+
+	stats := domain.TeamStats{TeamName: teamName, Since: since}
+	reviewers := 0
+	assignments := make(map[string]int)
+	merges := make(map[time.Time]int)
+	for _, pr := range s.prs {
+		for _, reviewer := range pr.AssignedReviewers {
+			if !pr.CreatedAt.Before(since) && containsString(s.users[reviewer].Teams, teamName) {
+				assignments[reviewer]++
+			}
+		}
+		if s.users[pr.AuthorID].TeamName != teamName {
+			continue
+		}
+		if pr.Status == domain.StatusOpen {
+			stats.OpenPullRequests++
+		}
+		if pr.Status == domain.StatusMerged && pr.MergedAt != nil && !pr.MergedAt.Before(since) {
+			merges[domain.WeekStart(*pr.MergedAt)]++
+		}
+		if pr.Status == domain.StatusDraft || pr.CreatedAt.Before(since) {
+			continue
+		}
+		stats.PullRequests++
+		reviewers += len(pr.AssignedReviewers)
+		if pr.Reassignments > 0 {
+			stats.Reassigned++
+		}
+	}
+	if stats.PullRequests > 0 {
+		stats.AvgReviewers = float64(reviewers) / float64(stats.PullRequests)
+	}
+	for userID, count := range assignments {
+		if count > stats.BusiestReviewerAssignments || (count == stats.BusiestReviewerAssignments && userID < stats.BusiestReviewer) {
+			stats.BusiestReviewer, stats.BusiestReviewerAssignments = userID, count
+		}
+	}
+	for start, count := range merges {
+		stats.MergesPerWeek = append(stats.MergesPerWeek, domain.WeekCount{Start: start, Count: count})
+	}
+	sort.Slice(stats.MergesPerWeek, func(i, j int) bool {
+		return stats.MergesPerWeek[i].Start.Before(stats.MergesPerWeek[j].Start)
+	})
+	return stats, nil
+}
+
+func (s *Store) ListReviewLatencies(_ context.Context, teamName string, since time.Time) ([]domain.ReviewLatency, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.teams[teamName]; !ok {
+		return nil, domain.ErrTeamNotFound
+	}
+
+	var latencies []domain.ReviewLatency
+	for prID, times := range s.reviews {
+		pr, ok := s.prs[prID]
+		if !ok || pr.CreatedAt.Before(since) || s.users[pr.AuthorID].TeamName != teamName {
+			continue
+		}
+		first := times.comment
+		if first == nil || (times.approval != nil && times.approval.Before(*first)) {
+			first = times.approval
+		}
+		if first == nil {
+			continue
+		}
+		latencies = append(latencies, domain.ReviewLatency{
+			PullRequestID: prID,
+			CreatedAt:     pr.CreatedAt,
+			FirstReviewAt: *first,
+		})
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		if !latencies[i].CreatedAt.Equal(latencies[j].CreatedAt) {
+			return latencies[i].CreatedAt.Before(latencies[j].CreatedAt)
+		}
+		return latencies[i].PullRequestID < latencies[j].PullRequestID
+	})
+	return latencies, nil
+}
+
+func (s *Store) BuildDailyReviewStats(_ context.Context, day time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := day.UTC()
+	end := start.AddDate(0, 0, 1)
+	within := func(at time.Time) bool { return !at.Before(start) && at.Before(end) }
+
+	rows := make(map[string]*domain.DailyReviewStats)
+	mergeTimes := make(map[string]time.Duration)
+	row := func(userID string) *domain.DailyReviewStats {
+		if stats, ok := rows[userID]; ok {
+			return stats
+		}
+		stats := &domain.DailyReviewStats{Day: start, TeamName: s.users[userID].TeamName, UserID: userID}
+		rows[userID] = stats
+		return stats
+	}
+
+	for _, pr := range s.prs {
+		if within(pr.CreatedAt) {
+			row(pr.AuthorID).Created++
+		}
+		if pr.Status == domain.StatusMerged && pr.MergedAt != nil && within(*pr.MergedAt) {
+			row(pr.AuthorID).Merged++
+			mergeTimes[pr.AuthorID] += pr.MergedAt.Sub(pr.CreatedAt)
+		}
+	}
+	for _, change := range s.history {
+		if change.Action == domain.ReviewerUnassigned && change.Reason == domain.ReasonReassign && within(change.CreatedAt) {
+			row(change.ReviewerID).Reassignments++
+		}
+	}
+
+	date := start.Format(time.DateOnly)
+	for key := range s.daily {
+		if key.day == date {
+			delete(s.daily, key)
+		}
+	}
+	for userID, stats := range rows {
+		if stats.Merged > 0 {
+			stats.AvgTimeToMerge = mergeTimes[userID] / time.Duration(stats.Merged)
+		}
+		s.daily[dailyKey{day: date, teamName: stats.TeamName, userID: userID}] = *stats
+	}
+	if start.After(s.lastDaily) {
+		s.lastDaily = start
+	}
+	return nil
+}
+
+func (s *Store) LastDailyReviewStatsDay(_ context.Context) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastDaily, nil
+}
+
+func (s *Store) ListDailyReviewStats(_ context.Context, teamName string, from, to time.Time) ([]domain.DailyReviewStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []domain.DailyReviewStats
+	for _, stats := range s.daily {
+		if stats.Day.Before(from) || stats.Day.After(to) {
+			continue
+		}
+		if teamName != "" && stats.TeamName != teamName {
+			continue
+		}
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Day.Equal(result[j].Day) {
+			return result[i].Day.Before(result[j].Day)
+		}
+		if result[i].TeamName != result[j].TeamName {
+			return result[i].TeamName < result[j].TeamName
+		}
+		return result[i].UserID < result[j].UserID
+	})
+	return result, nil
+}
+
+func (s *Store) AppendEvent(_ context.Context, event domain.Event) (domain.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if event.Payload == nil {
+		event.Payload = map[string]any{}
+	}
+	s.eventSeq++
+	event.Seq = s.eventSeq
+	event.CreatedAt = time.Now().UTC()
+	s.events = append(s.events, event)
+	return event, nil
+}
+
+func (s *Store) ListEvents(_ context.Context, since int64, limit int) ([]domain.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []domain.Event
+	for _, event := range s.events {
+		if event.Seq <= since {
+			continue
+		}
+		if len(events) == limit {
+			break
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (s *Store) ListLatestEvents(_ context.Context, limit int) ([]domain.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := max(len(s.events)-limit, 0)
+	return append([]domain.Event(nil), s.events[start:]...), nil
+}
+
+func (s *Store) CheckIntegrity(_ context.Context, now time.Time, fix bool) ([]domain.IntegrityIssue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.prs))
+	for id := range s.prs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var unknown, authors, merges []domain.IntegrityIssue
+	for _, id := range ids {
+		pr := s.prs[id]
+		team := s.users[pr.AuthorID].TeamName
+		allowAuthor := s.settings[team].AllowAuthorReview
+		kept := make([]string, 0, len(pr.AssignedReviewers))
+		for _, reviewer := range pr.AssignedReviewers {
+			switch _, known := s.users[reviewer]; {
+			case !known:
+				unknown = append(unknown, domain.IntegrityIssue{Check: domain.IntegrityUnknownReviewer, PullRequestID: id, UserID: reviewer, Fixed: fix})
+			case reviewer == pr.AuthorID && pr.Status == domain.StatusOpen && !allowAuthor:
+				authors = append(authors, domain.IntegrityIssue{Check: domain.IntegrityAuthorReviewer, PullRequestID: id, UserID: reviewer, TeamName: team, Fixed: fix})
+			default:
+				kept = append(kept, reviewer)
+			}
+		}
+		if pr.MergedAt != nil && pr.MergedAt.After(now) {
+			merges = append(merges, domain.IntegrityIssue{Check: domain.IntegrityFutureMerge, PullRequestID: id, Fixed: fix})
+			if fix {
+				mergedAt := now.UTC()
+				pr.MergedAt = &mergedAt
+			}
+		}
+		if fix {
+			pr.AssignedReviewers = kept
+			pr.Reviewers = reconcileReviewers(pr.Reviewers, kept, pr.ShadowReviewer, now)
+			s.dropCompletions(id, kept)
+			s.prs[id] = pr
+		}
+	}
+
+	userIDs := make([]string, 0, len(s.users))
+	for id := range s.users {
+		userIDs = append(userIDs, id)
+	}
+	sort.Strings(userIDs)
+	var teams []domain.IntegrityIssue
+	for _, id := range userIDs {
+		team := s.users[id].TeamName
+		if _, ok := s.teams[team]; ok {
+			continue
+		}
+		teams = append(teams, domain.IntegrityIssue{Check: domain.IntegrityMissingTeam, UserID: id, TeamName: team, Fixed: fix})
+	}
+	if fix {
+		for _, issue := range teams {
+			if _, ok := s.teams[issue.TeamName]; !ok {
+				s.teams[issue.TeamName] = now.UTC()
+			}
+		}
+	}
+
+	issues := append(unknown, authors...)
+	issues = append(issues, merges...)
+	issues = append(issues, teams...)
+	return append(issues, s.orphans(fix)...), nil
+}
+
+func (s *Store) CheckOrphans(_ context.Context, fix bool) ([]domain.IntegrityIssue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.orphans(fix), nil
+}
+
+func (s *Store) ExportSnapshot(_ context.Context) (domain.Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var snapshot domain.Snapshot
+	for name := range s.teams {
+		snapshot.Teams = append(snapshot.Teams, name)
+	}
+	sort.Strings(snapshot.Teams)
+	for _, name := range snapshot.Teams {
+		if settings, ok := s.settings[name]; ok {
+			settings.WorkDays = append([]time.Weekday(nil), settings.WorkDays...)
+			settings.ShadowPool = append([]string(nil), settings.ShadowPool...)
+			snapshot.Settings = append(snapshot.Settings, settings)
+		}
+	}
+	for _, user := range s.users {
+		snapshot.Users = append(snapshot.Users, cloneUser(user))
+	}
+	sort.Slice(snapshot.Users, func(i, j int) bool {
+		return snapshot.Users[i].ID < snapshot.Users[j].ID
+	})
+	for _, pr := range s.prs {
+		snapshot.PullRequests = append(snapshot.PullRequests, clonePullRequest(pr))
+	}
+	sort.Slice(snapshot.PullRequests, func(i, j int) bool {
+		return snapshot.PullRequests[i].ID < snapshot.PullRequests[j].ID
+	})
+	return snapshot, nil
+}
+
+func (s *Store) ImportSnapshot(_ context.Context, snapshot domain.Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.teams) > 0 || len(s.users) > 0 {
+		return domain.ErrNotEmpty
+	}
+	now := time.Now().UTC()
+	for _, name := range snapshot.Teams {
+		s.teams[name] = now
+	}
+	for _, user := range snapshot.Users {
+		user = cloneUser(user)
+		sort.Strings(user.Teams)
+		s.users[user.ID] = user
+	}
+	for _, settings := range snapshot.Settings {
+		settings.WorkDays = append([]time.Weekday(nil), settings.WorkDays...)
+		settings.ShadowPool = append([]string(nil), settings.ShadowPool...)
+		s.settings[settings.TeamName] = settings
+	}
+	for _, pr := range snapshot.PullRequests {
+		s.prs[pr.ID] = normalizePullRequest(pr)
+	}
+	return nil
+}
+
+// orphans runs the orphan checks: completions and pending acceptances of
+// reviewers no longer assigned. The caller holds s.mu.
+func (s *Store) orphans(fix bool) []domain.IntegrityIssue {
+	var completions, acceptances []domain.IntegrityIssue
+	for prID, reviewers := range s.completions {
+		for reviewer := range reviewers {
+			if containsString(s.prs[prID].AssignedReviewers, reviewer) {
+				continue
+			}
+			completions = append(completions, domain.IntegrityIssue{Check: domain.IntegrityOrphanedCompletion, PullRequestID: prID, UserID: reviewer, Fixed: fix})
+			if fix {
+				delete(reviewers, reviewer)
+			}
+		}
+	}
+	for prID, pending := range s.acceptances {
+		for reviewer, acceptance := range pending {
+			if acceptance.State() != domain.AcceptancePending || containsString(s.prs[prID].AssignedReviewers, reviewer) {
+				continue
+			}
+			acceptances = append(acceptances, domain.IntegrityIssue{Check: domain.IntegrityOrphanedAcceptance, PullRequestID: prID, UserID: reviewer, Fixed: fix})
+			if fix {
+				delete(pending, reviewer)
+			}
+		}
+	}
+	for _, issues := range [][]domain.IntegrityIssue{completions, acceptances} {
+		sort.Slice(issues, func(i, j int) bool {
+			if issues[i].PullRequestID != issues[j].PullRequestID {
+				return issues[i].PullRequestID < issues[j].PullRequestID
+			}
+			return issues[i].UserID < issues[j].UserID
+		})
+	}
+	return append(completions, acceptances...)
+}
+
+// dropCompletions forgets the completions of the reviewers of the pull
+// request missing from kept, as deleting their rows does in Postgres. The
+// caller holds s.mu.
+func (s *Store) dropCompletions(prID string, kept []string) {
+	for reviewer := range s.completions[prID] {
+		if !containsString(kept, reviewer) {
+			delete(s.completions[prID], reviewer)
+		}
+	}
+}
+
+func (s *Store) DeleteEvents(_ context.Context, throughSeq int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var kept []domain.Event
+	for _, event := range s.events {
+		if event.Seq > throughSeq {
+			kept = append(kept, event)
+		}
+	}
+	deleted := len(s.events) - len(kept)
+	s.events = kept
+	return deleted, nil
+}
+
+func (s *Store) Health(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// insertPullRequest validates references the same way the postgres foreign
+// keys do. Callers must hold the write lock.
+func (s *Store) insertPullRequest(pr domain.PullRequest) error {
+	if _, ok := s.prs[pr.ID]; ok {
+		return domain.ErrPRExists
+	}
+	if _, ok := s.users[pr.AuthorID]; !ok {
+		return domain.ErrUserNotFound
+	}
+	for _, reviewer := range pr.AssignedReviewers {
+		if _, ok := s.users[reviewer]; !ok {
+			return domain.ErrUserNotFound
+		}
+	}
+
+	s.prs[pr.ID] = normalizePullRequest(pr)
+	return nil
+}
+
+// teamMembers returns the team's users ordered by ID. Callers must hold the lock.
+func (s *Store) teamMembers(teamName string) []domain.User {
+	var members []domain.User
+	for _, user := range s.users {
+		if containsString(user.Teams, teamName) {
+			members = append(members, cloneUser(user))
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].ID < members[j].ID
+	})
+	return members
+}
+
+func cloneUser(user domain.User) domain.User {
+	user.Teams = append([]string(nil), user.Teams...)
+	return user
+}
+
+// normalizePullRequest copies pr into the shape the postgres store returns:
+// assigned reviewers sorted by ID, ordered reviewers with their roles, a
+// non-nil label set and timestamps in UTC.
+func normalizePullRequest(pr domain.PullRequest) domain.PullRequest {
+	pr = clonePullRequest(pr)
+	pr.Reviewers = pr.OrderedReviewers()
+	for i := range pr.Reviewers {
+		pr.Reviewers[i].AssignedAt = pr.Reviewers[i].AssignedAt.UTC()
+	}
+	sort.Strings(pr.AssignedReviewers)
+	sort.Strings(pr.Files)
+	if pr.Labels == nil {
+		pr.Labels = []string{}
+	}
+	pr.CreatedAt = pr.CreatedAt.UTC()
+	return pr
+}
+
+// reconcileReviewers keeps the places and assignment times of the reviewers
+// still assigned and gives the new ones the places after them, as the
+// postgres store does.
+func reconcileReviewers(previous []domain.Reviewer, assigned []string, shadow string, now time.Time) []domain.Reviewer {
+	var required []domain.Reviewer
+	var shadowReviewer domain.Reviewer
+	placed := make(map[string]bool, len(assigned))
+	for _, reviewer := range previous {
+		switch {
+		case reviewer.Role == domain.RoleShadow:
+			if reviewer.UserID == shadow {
+				shadowReviewer = reviewer
+			}
+		case containsString(assigned, reviewer.UserID):
+			required = append(required, reviewer)
+			placed[reviewer.UserID] = true
+		}
+	}
+	for _, reviewer := range assigned {
+		if !placed[reviewer] {
+			required = append(required, domain.Reviewer{UserID: reviewer, AssignedAt: now})
+			placed[reviewer] = true
+		}
+	}
+	if shadowReviewer.UserID == "" {
+		shadowReviewer = domain.Reviewer{UserID: shadow, AssignedAt: now}
+	}
+	return domain.RankReviewers(required, shadowReviewer)
+}
+
+// presentPullRequest copies a stored pull request for a caller, setting the
+// fields derived on reads. Callers must hold the lock.
+func (s *Store) presentPullRequest(pr domain.PullRequest) domain.PullRequest {
+	pr = clonePullRequest(pr)
+	pr.NeedsMoreReviewers = pr.Status == domain.StatusOpen && len(pr.AssignedReviewers) < s.requiredReviewers(pr)
+	pr.Acceptance = nil
+	for _, reviewer := range pr.AssignedReviewers {
+		acceptance, ok := s.acceptances[pr.ID][reviewer]
+		if !ok {
+			continue
+		}
+		if pr.Acceptance == nil {
+			pr.Acceptance = make(map[string]domain.AcceptanceState)
+		}
+		pr.Acceptance[reviewer] = acceptance.State()
+	}
+	pr.CompletedReviewers = nil
+	for _, reviewer := range pr.AssignedReviewers {
+		if _, ok := s.completions[pr.ID][reviewer]; ok {
+			pr.CompletedReviewers = append(pr.CompletedReviewers, reviewer)
+		}
+	}
+	pr.BlockedBy = nil
+	for blocker := range s.links[pr.ID] {
+		if other, ok := s.prs[blocker]; ok && other.Status != domain.StatusMerged {
+			pr.BlockedBy = append(pr.BlockedBy, blocker)
+		}
+	}
+	sort.Strings(pr.BlockedBy)
+	pr.Deadline = s.extendedDeadline(pr.ID)
+	return pr
+}
+
+// extendedDeadline returns the latest deadline approved for the pull request,
+// or nil. Callers must hold the lock.
+func (s *Store) extendedDeadline(prID string) *time.Time {
+	var deadline *time.Time
+	for _, extension := range s.extensions {
+		if extension.PullRequestID != prID || extension.Status != domain.ExtensionApproved {
+			continue
+		}
+		if deadline == nil || extension.Until.After(*deadline) {
+			until := extension.Until
+			deadline = &until
+		}
+	}
+	return deadline
+}
+
+// requiredReviewers returns the reviewers the pull request was created with
+// or, failing that, the ones its author's team requires. Callers must hold
+// the lock.
+func (s *Store) requiredReviewers(pr domain.PullRequest) int {
+	if pr.ReviewersCount > 0 {
+		return pr.ReviewersCount
+	}
+	if settings, ok := s.settings[s.users[pr.AuthorID].TeamName]; ok {
+		return settings.RequiredReviewers
+	}
+	return domain.DefaultRequiredReviewers
+}
+
+func clonePullRequest(pr domain.PullRequest) domain.PullRequest {
+	if pr.AssignedReviewers != nil {
+		pr.AssignedReviewers = append([]string(nil), pr.AssignedReviewers...)
+	}
+	if pr.Reviewers != nil {
+		pr.Reviewers = append([]domain.Reviewer(nil), pr.Reviewers...)
+	}
+	if pr.Labels != nil {
+		pr.Labels = append([]string(nil), pr.Labels...)
+	}
+	if pr.Files != nil {
+		pr.Files = append([]string(nil), pr.Files...)
+	}
+	if pr.CoAuthors != nil {
+		pr.CoAuthors = append([]string(nil), pr.CoAuthors...)
+	}
+	if pr.MergedAt != nil {
+		mergedAt := pr.MergedAt.UTC()
+		pr.MergedAt = &mergedAt
+	}
+	return pr
+}
+
+// sameReviewers reports whether both lists hold the same reviewers, in any
+// order.
+func sameReviewers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, reviewer := range a {
+		if !containsString(b, reviewer) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(items []string, target string) bool {
+	for _, item := range items {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) CreateWebhook(_ context.Context, webhook domain.Webhook) (domain.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastWebhookID++
+	webhook.ID = s.lastWebhookID
+	webhook.Events = append([]domain.WebhookEvent(nil), webhook.Events...)
+	webhook.CreatedAt = time.Now().UTC()
+	s.webhooks[webhook.ID] = webhook
+	return webhook, nil
+}
+
+func (s *Store) GetWebhook(_ context.Context, id int64) (domain.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	webhook, ok := s.webhooks[id]
+	if !ok {
+		return domain.Webhook{}, domain.ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+func (s *Store) ListWebhooks(_ context.Context) ([]domain.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	webhooks := make([]domain.Webhook, 0, len(s.webhooks))
+	for _, webhook := range s.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks, nil
+}
+
+func (s *Store) UpdateWebhook(_ context.Context, webhook domain.Webhook) (domain.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.webhooks[webhook.ID]
+	if !ok {
+		return domain.Webhook{}, domain.ErrWebhookNotFound
+	}
+	existing.URL = webhook.URL
+	existing.Secret = webhook.Secret
+	existing.Events = append([]domain.WebhookEvent(nil), webhook.Events...)
+	s.webhooks[webhook.ID] = existing
+	return existing, nil
+}
+
+func (s *Store) DeleteWebhook(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.webhooks[id]; !ok {
+		return domain.ErrWebhookNotFound
+	}
+	delete(s.webhooks, id)
+
+	deliveries := s.deliveries[:0]
+	for _, delivery := range s.deliveries {
+		if delivery.WebhookID != id {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	s.deliveries = deliveries
+	return nil
+}
+
+func (s *Store) AppendWebhookDelivery(_ context.Context, delivery domain.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.webhooks[delivery.WebhookID]; !ok {
+		return domain.ErrWebhookNotFound
+	}
+	s.lastDeliveryID++
+	delivery.ID = s.lastDeliveryID
+	delivery.CreatedAt = time.Now().UTC()
+	s.deliveries = append(s.deliveries, delivery)
+	return nil
+}
+
+func (s *Store) ListWebhookDeliveries(_ context.Context, webhookID int64, limit int) ([]domain.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.webhooks[webhookID]; !ok {
+		return nil, domain.ErrWebhookNotFound
+	}
+	var deliveries []domain.WebhookDelivery
+	for i := len(s.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if s.deliveries[i].WebhookID == webhookID {
+			deliveries = append(deliveries, s.deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
+
+type webhookDispatch struct {
+	seq        int64
+	owner      string
+	leaseUntil time.Time
+}
+
+func (s *Store) ClaimWebhookDispatch(_ context.Context, owner string, now, until time.Time) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dispatch.owner != owner && now.Before(s.dispatch.leaseUntil) {
+		return 0, false, nil
+	}
+	s.dispatch.owner = owner
+	s.dispatch.leaseUntil = until
+	return s.dispatch.seq, true, nil
+}
+
+func (s *Store) AdvanceWebhookDispatch(_ context.Context, owner string, seq int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dispatch.owner != owner {
+		return false, nil
+	}
+	s.dispatch.seq = max(s.dispatch.seq, seq)
+	return true, nil
+}
+
+func (s *Store) GetMaintenance(_ context.Context) (domain.Maintenance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.maintenance, nil
+}
+
+func (s *Store) SetMaintenance(_ context.Context, enabled bool) (domain.Maintenance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maintenance = domain.Maintenance{Enabled: enabled, UpdatedAt: time.Now().UTC()}
+	return s.maintenance, nil
+}
+
+func (s *Store) DeferAssignment(_ context.Context, deferred domain.DeferredAssignment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.deferred[deferred.PullRequestID]; ok {
+		return nil
+	}
+	deferred.CreatedAt = time.Now().UTC()
+	s.deferred[deferred.PullRequestID] = deferred
+	return nil
+}
+
+func (s *Store) ListDeferredAssignments(_ context.Context) ([]domain.DeferredAssignment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	assignments := make([]domain.DeferredAssignment, 0, len(s.deferred))
+	for _, deferred := range s.deferred {
+		assignments = append(assignments, deferred)
+	}
+	sort.Slice(assignments, func(i, j int) bool {
+		if !assignments[i].CreatedAt.Equal(assignments[j].CreatedAt) {
+			return assignments[i].CreatedAt.Before(assignments[j].CreatedAt)
+		}
+		return assignments[i].PullRequestID < assignments[j].PullRequestID
+	})
+	return assignments, nil
+}
+
+func (s *Store) DeleteDeferredAssignment(_ context.Context, prID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.deferred, prID)
+	return nil
+}
+
+func (s *Store) QueuePendingAssignment(_ context.Context, prID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pending[prID]; ok {
+		return nil
+	}
+	s.pending[prID] = domain.PendingAssignment{PullRequestID: prID, CreatedAt: time.Now().UTC()}
+	return nil
+}
+
+func (s *Store) ListPendingAssignments(_ context.Context) ([]domain.PendingAssignment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	assignments := make([]domain.PendingAssignment, 0, len(s.pending))
+	for _, pending := range s.pending {
+		assignments = append(assignments, pending)
+	}
+	sort.Slice(assignments, func(i, j int) bool {
+		if !assignments[i].CreatedAt.Equal(assignments[j].CreatedAt) {
+			return assignments[i].CreatedAt.Before(assignments[j].CreatedAt)
+		}
+		return assignments[i].PullRequestID < assignments[j].PullRequestID
+	})
+	return assignments, nil
+}
+
+func (s *Store) DeletePendingAssignment(_ context.Context, prID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pending, prID)
+	return nil
+}
+
+func (s *Store) SyncReviewAcceptances(_ context.Context, prID string, withdrawn []string, requested []domain.ReviewAcceptance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acceptances := s.acceptances[prID]
+	for _, reviewer := range withdrawn {
+		if acceptance, ok := acceptances[reviewer]; ok && acceptance.State() == domain.AcceptancePending {
+			delete(acceptances, reviewer)
+		}
+	}
+	for _, acceptance := range requested {
+		if acceptances == nil {
+			acceptances = make(map[string]domain.ReviewAcceptance)
+			s.acceptances[prID] = acceptances
+		}
+		acceptance.PullRequestID = prID
+		acceptance.AcceptedAt = nil
+		acceptance.ExpiredAt = nil
+		acceptances[acceptance.ReviewerID] = acceptance
+	}
+	return nil
+}
+
+func (s *Store) AcceptReview(_ context.Context, prID, reviewerID string, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acceptance, ok := s.acceptances[prID][reviewerID]
+	if !ok || acceptance.AcceptedAt != nil {
+		return false, nil
+	}
+	at = at.UTC()
+	acceptance.AcceptedAt = &at
+	s.acceptances[prID][reviewerID] = acceptance
+	return true, nil
+}
+
+func (s *Store) CompleteReview(_ context.Context, prID, reviewerID string, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr, ok := s.prs[prID]
+	if !ok || !containsString(pr.AssignedReviewers, reviewerID) {
+		return false, domain.ErrReviewerNotFound
+	}
+	if _, ok := s.completions[prID][reviewerID]; ok {
+		return false, nil
+	}
+	if s.completions[prID] == nil {
+		s.completions[prID] = make(map[string]time.Time)
+	}
+	s.completions[prID][reviewerID] = at.UTC()
+	return true, nil
+}
+
+func (s *Store) LinkPullRequests(_ context.Context, prID, blockedBy string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.prs[prID]; !ok {
+		return false, domain.ErrPullRequestNotFound
+	}
+	if _, ok := s.prs[blockedBy]; !ok {
+		return false, domain.ErrPullRequestNotFound
+	}
+	if _, ok := s.links[prID][blockedBy]; ok {
+		return false, nil
+	}
+	if s.links[prID] == nil {
+		s.links[prID] = make(map[string]time.Time)
+	}
+	s.links[prID][blockedBy] = time.Now().UTC()
+	return true, nil
+}
+
+func (s *Store) UnlinkPullRequests(_ context.Context, prID, blockedBy string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[prID][blockedBy]; !ok {
+		return false, nil
+	}
+	delete(s.links[prID], blockedBy)
+	return true, nil
+}
+
+func (s *Store) ReopenReviews(_ context.Context, prID string, reviewerIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, reviewer := range reviewerIDs {
+		delete(s.completions[prID], reviewer)
+	}
+	return nil
+}
+
+func (s *Store) ExpireReviewAcceptances(_ context.Context, now time.Time) ([]domain.ReviewAcceptance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now = now.UTC()
+	var expired []domain.ReviewAcceptance
+	for prID, acceptances := range s.acceptances {
+		pr, ok := s.prs[prID]
+		if !ok || pr.Status != domain.StatusOpen {
+			continue
+		}
+		for reviewer, acceptance := range acceptances {
+			if acceptance.State() != domain.AcceptancePending || acceptance.AcceptBy.After(now) || !containsString(pr.AssignedReviewers, reviewer) {
+				continue
+			}
+			expiredAt := now
+			acceptance.ExpiredAt = &expiredAt
+			acceptances[reviewer] = acceptance
+			expired = append(expired, acceptance)
+		}
+	}
+	sortAcceptances(expired)
+	return expired, nil
+}
+
+func (s *Store) RestoreReviewAcceptance(_ context.Context, acceptance domain.ReviewAcceptance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.acceptances[acceptance.PullRequestID][acceptance.ReviewerID]
+	if !ok || stored.ExpiredAt == nil || acceptance.ExpiredAt == nil || !stored.ExpiredAt.Equal(*acceptance.ExpiredAt) {
+		return nil
+	}
+	stored.ExpiredAt = nil
+	s.acceptances[acceptance.PullRequestID][acceptance.ReviewerID] = stored
+	return nil
+}
+
+func (s *Store) ListReviewAcceptances(_ context.Context, teamName string, since time.Time) ([]domain.ReviewAcceptance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.teams[teamName]; !ok {
+		return nil, domain.ErrTeamNotFound
+	}
+
+	var result []domain.ReviewAcceptance
+	for prID, acceptances := range s.acceptances {
+		pr, ok := s.prs[prID]
+		if !ok || s.users[pr.AuthorID].TeamName != teamName {
+			continue
+		}
+		for _, acceptance := range acceptances {
+			if !acceptance.RequestedAt.Before(since) {
+				result = append(result, acceptance)
+			}
+		}
+	}
+	sortAcceptances(result)
+	return result, nil
+}
+
+// sortAcceptances orders acceptances by request time, pull request and
+// reviewer.
+func sortAcceptances(acceptances []domain.ReviewAcceptance) {
+	sort.Slice(acceptances, func(i, j int) bool {
+		a, b := acceptances[i], acceptances[j]
+		if !a.RequestedAt.Equal(b.RequestedAt) {
+			return a.RequestedAt.Before(b.RequestedAt)
+		}
+		if a.PullRequestID != b.PullRequestID {
+			return a.PullRequestID < b.PullRequestID
+		}
+		return a.ReviewerID < b.ReviewerID
+	})
+}
+
+func (s *Store) CreateDeadlineExtension(_ context.Context, extension domain.DeadlineExtension) (domain.DeadlineExtension, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.prs[extension.PullRequestID]; !ok {
+		return domain.DeadlineExtension{}, domain.ErrPullRequestNotFound
+	}
+	s.lastExtensionID++
+	extension.ID = s.lastExtensionID
+	extension.Until = extension.Until.UTC()
+	extension.RequestedAt = extension.RequestedAt.UTC()
+	if extension.DecidedAt != nil {
+		decidedAt := extension.DecidedAt.UTC()
+		extension.DecidedAt = &decidedAt
+	}
+	s.extensions[extension.ID] = extension
+	return extension, nil
+}
+
+func (s *Store) GetDeadlineExtension(_ context.Context, id int64) (domain.DeadlineExtension, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	extension, ok := s.extensions[id]
+	if !ok {
+		return domain.DeadlineExtension{}, domain.ErrExtensionNotFound
+	}
+	return extension, nil
+}
+
+func (s *Store) DecideDeadlineExtension(_ context.Context, id int64, status domain.ExtensionStatus, decidedBy string, at time.Time) (domain.DeadlineExtension, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	extension, ok := s.extensions[id]
+	if !ok {
+		return domain.DeadlineExtension{}, domain.ErrExtensionNotFound
+	}
+	if extension.Status != domain.ExtensionPending {
+		return domain.DeadlineExtension{}, domain.ErrExtensionDecided
+	}
+	at = at.UTC()
+	extension.Status = status
+	extension.DecidedBy = decidedBy
+	extension.DecidedAt = &at
+	s.extensions[id] = extension
+	return extension, nil
+}
+
+func (s *Store) ListDeadlineExtensions(_ context.Context, prID string) ([]domain.DeadlineExtension, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []domain.DeadlineExtension
+	for _, extension := range s.extensions {
+		if extension.PullRequestID == prID {
+			result = append(result, extension)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (s *Store) CreateTeamToken(_ context.Context, token domain.TeamToken) (domain.TeamToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.teams[token.TeamName]; !ok {
+		return domain.TeamToken{}, domain.ErrTeamNotFound
+	}
+	s.lastTokenID++
+	token.ID = s.lastTokenID
+	token.CreatedAt = time.Now().UTC()
+	stored := token
+	stored.Token = ""
+	s.tokens[token.ID] = stored
+	return token, nil
+}
+
+func (s *Store) GetTeamTokenByHash(_ context.Context, hash string) (domain.TeamToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.tokens {
+		if token.Hash == hash {
+			return token, nil
+		}
+	}
+	return domain.TeamToken{}, domain.ErrTeamTokenNotFound
+}
+
+func (s *Store) ListTeamTokens(_ context.Context, teamName string) ([]domain.TeamToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []domain.TeamToken
+	for _, token := range s.tokens {
+		if teamName == "" || token.TeamName == teamName {
+			result = append(result, token)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (s *Store) DeleteTeamToken(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tokens[id]; !ok {
+		return domain.ErrTeamTokenNotFound
+	}
+	delete(s.tokens, id)
+	return nil
+}
@@ -0,0 +1,216 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Store) SyncReviewAcceptances(ctx context.Context, prID string, withdrawn []string, requested []domain.ReviewAcceptance) error {
+	reviewers := make([]string, 0, len(requested))
+	requestedAt := make([]time.Time, 0, len(requested))
+	acceptBy := make([]time.Time, 0, len(requested))
+	for _, acceptance := range requested {
+		reviewers = append(reviewers, acceptance.ReviewerID)
+		requestedAt = append(requestedAt, acceptance.RequestedAt)
+		acceptBy = append(acceptBy, acceptance.AcceptBy)
+	}
+
+	return s.withTx(ctx, func(tx pgx.Tx) error {
+		if len(withdrawn) > 0 {
+			if _, err := tx.Exec(ctx, `
+				DELETE FROM review_acceptances
+				WHERE pull_request_id = $1 AND reviewer_id = ANY($2)
+				  AND accepted_at IS NULL AND expired_at IS NULL
+			`, prID, withdrawn); err != nil {
+				return err
+			}
+		}
+		if len(requested) == 0 {
+			return nil
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO review_acceptances (pull_request_id, reviewer_id, requested_at, accept_by)
+			SELECT $1, r.reviewer_id, r.requested_at, r.accept_by
+			FROM unnest($2::text[], $3::timestamptz[], $4::timestamptz[]) AS r (reviewer_id, requested_at, accept_by)
+			ON CONFLICT (pull_request_id, reviewer_id) DO UPDATE
+			SET requested_at = EXCLUDED.requested_at,
+			    accept_by = EXCLUDED.accept_by,
+			    accepted_at = NULL,
+			    expired_at = NULL
+		`, prID, reviewers, requestedAt, acceptBy)
+		return err
+	})
+}
+
+// AcceptReview marks the reviewer's acceptance accepted, even when it has
+// expired, and reports whether an acceptance was waiting for it.
+func (s *Store) AcceptReview(ctx context.Context, prID, reviewerID string, at time.Time) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE review_acceptances
+		SET accepted_at = $3
+		WHERE pull_request_id = $1 AND reviewer_id = $2 AND accepted_at IS NULL
+	`, prID, reviewerID, at)
+	if err != nil {
+		return false, translateError(err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// CompleteReview marks the reviewer's review done and reports whether it was
+// not done yet. A reviewer not assigned to the pull request is refused.
+func (s *Store) CompleteReview(ctx context.Context, prID, reviewerID string, at time.Time) (bool, error) {
+	var assigned, inserted bool
+	err := s.pool.QueryRow(ctx, `
+		WITH assigned AS (
+		    SELECT 1 FROM pull_request_reviewers WHERE pull_request_id = $1 AND reviewer_id = $2
+		), completed AS (
+		    INSERT INTO review_completions (pull_request_id, reviewer_id, completed_at)
+		    SELECT $1, $2, $3 FROM assigned
+		    ON CONFLICT (pull_request_id, reviewer_id) DO NOTHING
+		    RETURNING 1
+		)
+		SELECT EXISTS (SELECT 1 FROM assigned), EXISTS (SELECT 1 FROM completed)
+	`, prID, reviewerID, at).Scan(&assigned, &inserted)
+	if err != nil {
+		return false, translateError(err)
+	}
+	if !assigned {
+		return false, domain.ErrReviewerNotFound
+	}
+	return inserted, nil
+}
+
+func (s *Store) ReopenReviews(ctx context.Context, prID string, reviewerIDs []string) error {
+	_, err := s.pool.Exec(ctx, `
+		DELETE FROM review_completions WHERE pull_request_id = $1 AND reviewer_id = ANY($2)
+	`, prID, reviewerIDs)
+	return err
+}
+
+// ExpireReviewAcceptances marks expired the pending acceptances due by now of
+// reviewers still assigned to open pull requests and returns them. Rows
+// locked by a concurrent run are left to it.
+func (s *Store) ExpireReviewAcceptances(ctx context.Context, now time.Time) ([]domain.ReviewAcceptance, error) {
+	rows, err := s.pool.Query(ctx, `
+		UPDATE review_acceptances a
+		SET expired_at = $1
+		WHERE (a.pull_request_id, a.reviewer_id) IN (
+		    SELECT a.pull_request_id, a.reviewer_id
+		    FROM review_acceptances a
+		    JOIN pull_requests pr ON pr.pull_request_id = a.pull_request_id
+		    JOIN pull_request_reviewers r
+		      ON r.pull_request_id = a.pull_request_id AND r.reviewer_id = a.reviewer_id
+		    WHERE a.accepted_at IS NULL AND a.expired_at IS NULL
+		      AND a.accept_by <= $1 AND pr.status = $2
+		    FOR UPDATE OF a SKIP LOCKED
+		)
+		RETURNING a.pull_request_id, a.reviewer_id, a.requested_at, a.accept_by, a.accepted_at, a.expired_at
+	`, now, string(domain.StatusOpen))
+	if err != nil {
+		return nil, err
+	}
+	acceptances, err := scanAcceptances(rows)
+	if err != nil {
+		return nil, err
+	}
+	sortAcceptances(acceptances)
+	return acceptances, nil
+}
+
+// RestoreReviewAcceptance clears the expiry only if it is still the one
+// ExpireReviewAcceptances set, so a later request for the reviewer stands.
+func (s *Store) RestoreReviewAcceptance(ctx context.Context, acceptance domain.ReviewAcceptance) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE review_acceptances
+		SET expired_at = NULL
+		WHERE pull_request_id = $1 AND reviewer_id = $2 AND expired_at = $3
+	`, acceptance.PullRequestID, acceptance.ReviewerID, acceptance.ExpiredAt)
+	return err
+}
+
+// ListReviewAcceptances returns the acceptances requested since the cutoff
+// for pull requests authored by the team's members, oldest first.
+func (s *Store) ListReviewAcceptances(ctx context.Context, teamName string, since time.Time) ([]domain.ReviewAcceptance, error) {
+	var name string
+	if err := s.pool.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1`, teamName).Scan(&name); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrTeamNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT a.pull_request_id, a.reviewer_id, a.requested_at, a.accept_by, a.accepted_at, a.expired_at
+		FROM review_acceptances a
+		JOIN pull_requests pr ON pr.pull_request_id = a.pull_request_id
+		JOIN users u ON u.user_id = pr.author_id
+		WHERE u.team_name = $1 AND a.requested_at >= $2
+		ORDER BY a.requested_at, a.pull_request_id, a.reviewer_id
+	`, teamName, since)
+	if err != nil {
+		return nil, err
+	}
+	return scanAcceptances(rows)
+}
+
+// reviewAcceptancesQuery selects the acceptances of the reviewers still
+// assigned to the pull request $1.
+const reviewAcceptancesQuery = `
+		SELECT a.pull_request_id, a.reviewer_id, a.requested_at, a.accept_by, a.accepted_at, a.expired_at
+		FROM review_acceptances a
+		JOIN pull_request_reviewers r
+		  ON r.pull_request_id = a.pull_request_id AND r.reviewer_id = a.reviewer_id
+		WHERE a.pull_request_id = $1
+	`
+
+// acceptanceStates maps reviewers to the state of their acceptance, nil when
+// there are none.
+func acceptanceStates(acceptances []domain.ReviewAcceptance) map[string]domain.AcceptanceState {
+	if len(acceptances) == 0 {
+		return nil
+	}
+	states := make(map[string]domain.AcceptanceState, len(acceptances))
+	for _, acceptance := range acceptances {
+		states[acceptance.ReviewerID] = acceptance.State()
+	}
+	return states
+}
+
+func scanAcceptances(rows pgx.Rows) ([]domain.ReviewAcceptance, error) {
+	defer rows.Close()
+
+	var acceptances []domain.ReviewAcceptance
+	for rows.Next() {
+		var acceptance domain.ReviewAcceptance
+		if err := rows.Scan(&acceptance.PullRequestID, &acceptance.ReviewerID, &acceptance.RequestedAt,
+			&acceptance.AcceptBy, &acceptance.AcceptedAt, &acceptance.ExpiredAt); err != nil {
+			return nil, err
+		}
+		acceptances = append(acceptances, acceptance)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return acceptances, nil
+}
+
+// sortAcceptances orders acceptances by request time, pull request and
+// reviewer, as RETURNING gives no order.
+func sortAcceptances(acceptances []domain.ReviewAcceptance) {
+	sort.Slice(acceptances, func(i, j int) bool {
+		a, b := acceptances[i], acceptances[j]
+		if !a.RequestedAt.Equal(b.RequestedAt) {
+			return a.RequestedAt.Before(b.RequestedAt)
+		}
+		if a.PullRequestID != b.PullRequestID {
+			return a.PullRequestID < b.PullRequestID
+		}
+		return a.ReviewerID < b.ReviewerID
+	})
+}
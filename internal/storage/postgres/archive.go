@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ArchivePullRequests moves up to limit pull requests merged before the cutoff,
+// together with their reviewers and reviewer history, into the archive tables.
+// Rows locked by concurrent writers are skipped and picked up on a later run.
+func (s *Store) ArchivePullRequests(ctx context.Context, mergedBefore time.Time, limit int) (int, error) {
+	var archived int
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+			SELECT pull_request_id
+			FROM pull_requests
+			WHERE status = $1 AND merged_at < $2
+			ORDER BY merged_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		`, string(domain.StatusMerged), mergedBefore, limit)
+		if err != nil {
+			return err
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO pull_requests_archive
+				(pull_request_id, pull_request_name, author_id, status, created_at, merged_at, labels, url, priority, reviewers_count, shadow_reviewer_id, reassignments, description, shadow_assigned_at, co_author_ids)
+			SELECT pull_request_id, pull_request_name, author_id, status, created_at, merged_at, labels, url, priority, reviewers_count, shadow_reviewer_id, reassignments, description, shadow_assigned_at, co_author_ids
+			FROM pull_requests
+			WHERE pull_request_id = ANY($1)
+		`, ids); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO pull_request_reviewers_archive (pull_request_id, reviewer_id, position, assigned_at)
+			SELECT pull_request_id, reviewer_id, position, assigned_at
+			FROM pull_request_reviewers
+			WHERE pull_request_id = ANY($1)
+		`, ids); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO reviewer_history_archive (id, pull_request_id, reviewer_id, action, reason, created_at)
+			SELECT id, pull_request_id, reviewer_id, action, reason, created_at
+			FROM reviewer_history
+			WHERE pull_request_id = ANY($1)
+		`, ids); err != nil {
+			return err
+		}
+
+		// Reviewers, history and reminder state go with the row via ON DELETE CASCADE.
+		tag, err := tx.Exec(ctx, `DELETE FROM pull_requests WHERE pull_request_id = ANY($1)`, ids)
+		if err != nil {
+			return err
+		}
+		archived = int(tag.RowsAffected())
+		return nil
+	})
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return archived, nil
+}
+
+// getArchivedPullRequest reads a pull request from the archive tables.
+func (s *Store) getArchivedPullRequest(ctx context.Context, id string) (domain.PullRequest, error) {
+	var pr domain.PullRequest
+	var mergedAt sql.NullTime
+	var places reviewerPlaceRows
+	err := s.pool.QueryRow(ctx, `
+		SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status, pr.created_at, pr.merged_at, pr.labels, pr.url,
+		       pr.priority, pr.reviewers_count, COALESCE(pr.shadow_reviewer_id, ''), pr.reassignments, pr.description, pr.co_author_ids,
+		       `+reviewerPlacesFrom("pull_request_reviewers_archive")+`
+		FROM pull_requests_archive pr
+		WHERE pr.pull_request_id = $1
+	`, id).Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Status, &pr.CreatedAt, &mergedAt, &pr.Labels, &pr.URL, &pr.Priority, &pr.ReviewersCount,
+		&pr.ShadowReviewer, &pr.Reassignments, &pr.Description, &pr.CoAuthors, &places.ids, &places.assignedAt, &places.shadowAssignedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.PullRequest{}, domain.ErrPullRequestNotFound
+		}
+		return domain.PullRequest{}, err
+	}
+	if mergedAt.Valid {
+		pr.MergedAt = &mergedAt.Time
+	}
+	places.apply(&pr)
+	if len(pr.CoAuthors) == 0 {
+		pr.CoAuthors = nil
+	}
+	return pr, nil
+}
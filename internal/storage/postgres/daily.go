@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BuildDailyReviewStats recomputes one day of daily_review_stats from the hot
+// tables. Days are rebuilt long before their pull requests are archived, so
+// the archive is not consulted.
+func (s *Store) BuildDailyReviewStats(ctx context.Context, day time.Time) error {
+	start := day.UTC()
+	end := start.AddDate(0, 0, 1)
+	return s.withTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM daily_review_stats WHERE day = $1`, start); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO daily_review_stats (day, team_name, user_id, prs_created, prs_merged, reassignments, avg_merge_seconds)
+			SELECT $1, u.team_name, u.user_id,
+			       COUNT(*) FILTER (WHERE a.kind = 'created'),
+			       COUNT(*) FILTER (WHERE a.kind = 'merged'),
+			       COUNT(*) FILTER (WHERE a.kind = 'reassigned'),
+			       AVG(a.merge_seconds) FILTER (WHERE a.kind = 'merged')
+			FROM (
+				SELECT author_id AS user_id, 'created' AS kind, NULL::DOUBLE PRECISION AS merge_seconds
+				FROM pull_requests
+				WHERE created_at >= $2 AND created_at < $3
+				UNION ALL
+				SELECT author_id, 'merged', EXTRACT(EPOCH FROM merged_at - created_at)::DOUBLE PRECISION
+				FROM pull_requests
+				WHERE status = $4 AND merged_at >= $2 AND merged_at < $3
+				UNION ALL
+				SELECT reviewer_id, 'reassigned', NULL
+				FROM reviewer_history
+				WHERE action = $5 AND reason = $6 AND created_at >= $2 AND created_at < $3
+			) a
+			JOIN users u ON u.user_id = a.user_id
+			GROUP BY u.team_name, u.user_id
+		`, start, start, end, string(domain.StatusMerged), string(domain.ReviewerUnassigned), domain.ReasonReassign)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO daily_stats_builds (id, last_day) VALUES (TRUE, $1)
+			ON CONFLICT (id) DO UPDATE SET last_day = GREATEST(daily_stats_builds.last_day, EXCLUDED.last_day)
+		`, start)
+		return err
+	})
+}
+
+func (s *Store) LastDailyReviewStatsDay(ctx context.Context) (time.Time, error) {
+	var day time.Time
+	err := s.pool.QueryRow(ctx, `SELECT last_day FROM daily_stats_builds`).Scan(&day)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.UTC(), nil
+}
+
+func (s *Store) ListDailyReviewStats(ctx context.Context, teamName string, from, to time.Time) ([]domain.DailyReviewStats, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT day, team_name, user_id, prs_created, prs_merged, reassignments, avg_merge_seconds
+		FROM daily_review_stats
+		WHERE day >= $1 AND day <= $2 AND ($3 = '' OR team_name = $3)
+		ORDER BY day, team_name, user_id
+	`, from, to, teamName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []domain.DailyReviewStats
+	for rows.Next() {
+		var stats domain.DailyReviewStats
+		var avgMerge sql.NullFloat64
+		if err := rows.Scan(&stats.Day, &stats.TeamName, &stats.UserID, &stats.Created, &stats.Merged,
+			&stats.Reassignments, &avgMerge); err != nil {
+			return nil, err
+		}
+		if avgMerge.Valid {
+			stats.AvgTimeToMerge = time.Duration(avgMerge.Float64 * float64(time.Second))
+		}
+		result = append(result, stats)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return result, nil
+}
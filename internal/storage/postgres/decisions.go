@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"Avito2025/internal/domain"
+)
+
+// decisionCandidate is the JSON form of a candidate in assignment_decisions.
+type decisionCandidate struct {
+	UserID     string `json:"user_id"`
+	ExcludedBy string `json:"excluded_by,omitempty"`
+}
+
+// AppendAssignmentDecision stores the context of an assignment. Decisions are
+// dropped together with their pull request when it is archived.
+func (s *Store) AppendAssignmentDecision(ctx context.Context, decision domain.AssignmentDecision) error {
+	candidates := make([]decisionCandidate, 0, len(decision.Candidates))
+	for _, candidate := range decision.Candidates {
+		candidates = append(candidates, decisionCandidate(candidate))
+	}
+	raw, err := json.Marshal(candidates)
+	if err != nil {
+		return fmt.Errorf("marshal decision candidates: %w", err)
+	}
+
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO assignment_decisions
+			(pull_request_id, reason, team_name, strategy, seed, filters, candidates, selected, owners, mandatory_reviewer_id, author_fallback)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11)
+	`, decision.PullRequestID, decision.Reason, decision.TeamName, decision.Strategy, decision.Seed,
+		labelsParam(decision.Filters), raw, labelsParam(decision.Selected), labelsParam(decision.Owners), decision.Mandatory, decision.AuthorFallback)
+	return err
+}
+
+// ListAssignmentDecisions returns the pull request's decisions, oldest first.
+func (s *Store) ListAssignmentDecisions(ctx context.Context, prID string) ([]domain.AssignmentDecision, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT pull_request_id, reason, team_name, strategy, seed, filters, candidates, selected, owners,
+		       COALESCE(mandatory_reviewer_id, ''), author_fallback, created_at
+		FROM assignment_decisions
+		WHERE pull_request_id = $1
+		ORDER BY id
+	`, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var decisions []domain.AssignmentDecision
+	for rows.Next() {
+		var decision domain.AssignmentDecision
+		var raw []byte
+		if err := rows.Scan(&decision.PullRequestID, &decision.Reason, &decision.TeamName, &decision.Strategy,
+			&decision.Seed, &decision.Filters, &raw, &decision.Selected, &decision.Owners, &decision.Mandatory, &decision.AuthorFallback, &decision.CreatedAt); err != nil {
+			return nil, err
+		}
+		var candidates []decisionCandidate
+		if err := json.Unmarshal(raw, &candidates); err != nil {
+			return nil, fmt.Errorf("unmarshal decision candidates: %w", err)
+		}
+		for _, candidate := range candidates {
+			decision.Candidates = append(decision.Candidates, domain.DecisionCandidate(candidate))
+		}
+		decisions = append(decisions, decision)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return decisions, nil
+}
@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+
+	"Avito2025/internal/domain"
+)
+
+func (s *Store) DeferAssignment(ctx context.Context, deferred domain.DeferredAssignment) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO deferred_assignments (pull_request_id, team_name)
+		VALUES ($1, $2)
+		ON CONFLICT (pull_request_id) DO NOTHING
+	`, deferred.PullRequestID, deferred.TeamName)
+	return err
+}
+
+// ListDeferredAssignments returns the postponed assignments, oldest first.
+func (s *Store) ListDeferredAssignments(ctx context.Context) ([]domain.DeferredAssignment, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT pull_request_id, team_name, created_at
+		FROM deferred_assignments
+		ORDER BY created_at, pull_request_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assignments []domain.DeferredAssignment
+	for rows.Next() {
+		var deferred domain.DeferredAssignment
+		if err := rows.Scan(&deferred.PullRequestID, &deferred.TeamName, &deferred.CreatedAt); err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, deferred)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return assignments, nil
+}
+
+func (s *Store) DeleteDeferredAssignment(ctx context.Context, prID string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM deferred_assignments WHERE pull_request_id = $1`, prID)
+	return err
+}
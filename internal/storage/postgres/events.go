@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// eventLockKey identifies the transaction advisory lock that orders event
+// appends.
+const eventLockKey int64 = 0x4576656e74536571 // "EventSeq"
+
+// AppendEvent stores the event in the transaction of ctx, if any. Appends
+// hold an advisory lock until their transaction ends, so that sequence
+// numbers commit in the order they are drawn: a reader that has seen seq
+// never finds a smaller one committed later, and every seq it can see is
+// below the ones of transactions still in progress.
+func (s *Store) AppendEvent(ctx context.Context, event domain.Event) (domain.Event, error) {
+	payload := event.Payload
+	if payload == nil {
+		payload = map[string]any{}
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return domain.Event{}, fmt.Errorf("marshal event payload: %w", err)
+	}
+
+	err = s.withTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, eventLockKey); err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			INSERT INTO events (event_type, team_name, entity_id, payload)
+			VALUES ($1, $2, $3, $4)
+			RETURNING seq, created_at
+		`, string(event.Type), event.TeamName, event.EntityID, raw).Scan(&event.Seq, &event.CreatedAt)
+	})
+	if err != nil {
+		return domain.Event{}, err
+	}
+	event.Payload = payload
+	return event, nil
+}
+
+func (s *Store) ListEvents(ctx context.Context, since int64, limit int) ([]domain.Event, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT seq, event_type, team_name, entity_id, payload, created_at
+		FROM events
+		WHERE seq > $1
+		ORDER BY seq
+		LIMIT $2
+	`, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanEvents(rows)
+}
+
+func (s *Store) ListLatestEvents(ctx context.Context, limit int) ([]domain.Event, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT seq, event_type, team_name, entity_id, payload, created_at
+		FROM (
+			SELECT * FROM events ORDER BY seq DESC LIMIT $1
+		) latest
+		ORDER BY seq
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanEvents(rows)
+}
+
+func scanEvents(rows pgx.Rows) ([]domain.Event, error) {
+	defer rows.Close()
+
+	var events []domain.Event
+	for rows.Next() {
+		var event domain.Event
+		var raw []byte
+		if err := rows.Scan(&event.Seq, &event.Type, &event.TeamName, &event.EntityID, &raw, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &event.Payload); err != nil {
+			return nil, fmt.Errorf("unmarshal event %d payload: %w", event.Seq, err)
+		}
+		events = append(events, event)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return events, nil
+}
+
+func (s *Store) DeleteEvents(ctx context.Context, throughSeq int64) (int, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM events WHERE seq <= $1`, throughSeq)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// extendedDeadline selects the latest deadline approved for the pull request
+// aliased pr, or NULL.
+const extendedDeadline = `(
+	SELECT MAX(e.until) FROM deadline_extensions e
+	WHERE e.pull_request_id = pr.pull_request_id AND e.status = 'APPROVED'
+)`
+
+const extensionColumns = `id, pull_request_id, reviewer_id, until, reason, status, requested_at, decided_by, decided_at`
+
+func (s *Store) CreateDeadlineExtension(ctx context.Context, extension domain.DeadlineExtension) (domain.DeadlineExtension, error) {
+	row := s.pool.QueryRow(ctx, `
+		INSERT INTO deadline_extensions (pull_request_id, reviewer_id, until, reason, status, requested_at, decided_by, decided_at)
+		SELECT pull_request_id, $2, $3, $4, $5, $6, $7, $8
+		FROM pull_requests
+		WHERE pull_request_id = $1
+		RETURNING `+extensionColumns,
+		extension.PullRequestID, extension.ReviewerID, extension.Until, extension.Reason, string(extension.Status),
+		extension.RequestedAt, extension.DecidedBy, extension.DecidedAt)
+	created, err := scanExtension(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.DeadlineExtension{}, domain.ErrPullRequestNotFound
+	}
+	return created, translateError(err)
+}
+
+func (s *Store) GetDeadlineExtension(ctx context.Context, id int64) (domain.DeadlineExtension, error) {
+	extension, err := scanExtension(s.pool.QueryRow(ctx, `
+		SELECT `+extensionColumns+`
+		FROM deadline_extensions
+		WHERE id = $1
+	`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.DeadlineExtension{}, domain.ErrExtensionNotFound
+	}
+	return extension, translateError(err)
+}
+
+// DecideDeadlineExtension records the decision on a pending extension. An
+// extension decided concurrently is reported as ErrExtensionDecided.
+func (s *Store) DecideDeadlineExtension(ctx context.Context, id int64, status domain.ExtensionStatus, decidedBy string, at time.Time) (domain.DeadlineExtension, error) {
+	extension, err := scanExtension(s.pool.QueryRow(ctx, `
+		UPDATE deadline_extensions
+		SET status = $2, decided_by = $3, decided_at = $4
+		WHERE id = $1 AND status = $5
+		RETURNING `+extensionColumns,
+		id, string(status), decidedBy, at, string(domain.ExtensionPending)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := s.GetDeadlineExtension(ctx, id); err != nil {
+			return domain.DeadlineExtension{}, err
+		}
+		return domain.DeadlineExtension{}, domain.ErrExtensionDecided
+	}
+	return extension, translateError(err)
+}
+
+func (s *Store) ListDeadlineExtensions(ctx context.Context, prID string) ([]domain.DeadlineExtension, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+extensionColumns+`
+		FROM deadline_extensions
+		WHERE pull_request_id = $1
+		ORDER BY id
+	`, prID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	var extensions []domain.DeadlineExtension
+	for rows.Next() {
+		extension, err := scanExtension(rows)
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, extension)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return extensions, nil
+}
+
+func scanExtension(row pgx.Row) (domain.DeadlineExtension, error) {
+	var extension domain.DeadlineExtension
+	err := row.Scan(&extension.ID, &extension.PullRequestID, &extension.ReviewerID, &extension.Until, &extension.Reason,
+		&extension.Status, &extension.RequestedAt, &extension.DecidedBy, &extension.DecidedAt)
+	return extension, err
+}
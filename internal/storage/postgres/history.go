@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Store) AppendReviewerHistory(ctx context.Context, changes []domain.ReviewerChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, change := range changes {
+		batch.Queue(`
+			INSERT INTO reviewer_history (pull_request_id, reviewer_id, action, reason)
+			VALUES ($1, $2, $3, $4)
+		`, change.PullRequestID, change.ReviewerID, string(change.Action), change.Reason)
+	}
+	return s.pool.SendBatch(ctx, batch).Close()
+}
+
+func (s *Store) ListReviewerHistory(ctx context.Context, filter domain.AssignmentHistoryFilter, offset, limit int) ([]domain.ReviewerChange, error) {
+	var from, to *time.Time
+	if !filter.From.IsZero() {
+		from = &filter.From
+	}
+	if !filter.To.IsZero() {
+		to = &filter.To
+	}
+	rows, err := s.pool.Query(ctx, `
+		SELECT pull_request_id, reviewer_id, action, reason, created_at
+		FROM (
+			SELECT id, pull_request_id, reviewer_id, action, reason, created_at FROM reviewer_history
+			UNION ALL
+			SELECT id, pull_request_id, reviewer_id, action, reason, created_at FROM reviewer_history_archive
+		) h
+		WHERE reviewer_id = $1
+		  AND ($2::timestamptz IS NULL OR created_at >= $2)
+		  AND ($3::timestamptz IS NULL OR created_at < $3)
+		ORDER BY created_at, id
+		OFFSET $4
+		LIMIT $5
+	`, filter.ReviewerID, from, to, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []domain.ReviewerChange
+	for rows.Next() {
+		var change domain.ReviewerChange
+		var action string
+		if err := rows.Scan(&change.PullRequestID, &change.ReviewerID, &action, &change.Reason, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		change.Action = domain.ReviewerAction(action)
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// ListParticipatedPullRequests returns the most recent pull requests authored
+// or reviewed by any of the users, newest first. Only the fields describing
+// participation and content are filled in.
+func (s *Store) ListParticipatedPullRequests(ctx context.Context, userIDs []string, limit int) ([]domain.PullRequest, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.labels,
+		       ARRAY(SELECT r.reviewer_id FROM pull_request_reviewers r
+		             WHERE r.pull_request_id = pr.pull_request_id ORDER BY r.reviewer_id)
+		FROM pull_requests pr
+		WHERE pr.author_id = ANY($1)
+		   OR EXISTS (SELECT 1 FROM pull_request_reviewers r
+		              WHERE r.pull_request_id = pr.pull_request_id AND r.reviewer_id = ANY($1))
+		ORDER BY pr.created_at DESC, pr.pull_request_id
+		LIMIT $2
+	`, userIDs, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prs []domain.PullRequest
+	for rows.Next() {
+		var pr domain.PullRequest
+		if err := rows.Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Labels, &pr.AssignedReviewers); err != nil {
+			return nil, err
+		}
+		prs = append(prs, pr)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return prs, nil
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Store) AddIdentity(ctx context.Context, identity domain.Identity) (domain.Identity, error) {
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		var userID string
+		err := tx.QueryRow(ctx, `SELECT user_id FROM users WHERE user_id = $1`, identity.UserID).Scan(&userID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrUserNotFound
+			}
+			return err
+		}
+
+		return tx.QueryRow(ctx, `
+			INSERT INTO user_identities (provider, external_id, user_id)
+			VALUES ($1, $2, $3)
+			RETURNING created_at
+		`, string(identity.Provider), identity.ExternalID, identity.UserID).Scan(&identity.CreatedAt)
+	})
+	if err != nil {
+		return domain.Identity{}, translateError(err)
+	}
+	return identity, nil
+}
+
+func (s *Store) ListIdentities(ctx context.Context, userID string) ([]domain.Identity, error) {
+	if _, err := s.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT provider, external_id, user_id, created_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY provider, external_id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []domain.Identity
+	for rows.Next() {
+		var identity domain.Identity
+		if err := rows.Scan(&identity.Provider, &identity.ExternalID, &identity.UserID, &identity.CreatedAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return identities, nil
+}
+
+func (s *Store) ResolveIdentity(ctx context.Context, provider domain.IdentityProvider, externalID string) (domain.User, error) {
+	var user domain.User
+	err := s.pool.QueryRow(ctx, `
+		SELECT u.user_id, u.username, u.team_name, u.is_active, u.snoozed_until, `+userTeams+`
+		FROM user_identities i
+		JOIN users u ON u.user_id = i.user_id
+		WHERE i.provider = $1 AND i.external_id = $2
+	`, string(provider), externalID).Scan(&user.ID, &user.Username, &user.TeamName, &user.IsActive, &user.SnoozedUntil, &user.Teams)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.User{}, domain.ErrIdentityNotFound
+		}
+		return domain.User{}, err
+	}
+	return user, nil
+}
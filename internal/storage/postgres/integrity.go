@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// integrityCheck finds one kind of inconsistency and repairs it. find returns
+// the pull request, user and team of every issue, with empty strings for the
+// fields that do not apply. With withNow set, both queries take the time of
+// the check as $1.
+type integrityCheck struct {
+	name    string
+	find    string
+	fix     string
+	withNow bool
+}
+
+func (c integrityCheck) args(now time.Time) []any {
+	if c.withNow {
+		return []any{now}
+	}
+	return nil
+}
+
+var integrityChecks = append([]integrityCheck{
+	{
+		name: domain.IntegrityUnknownReviewer,
+		find: `
+			SELECT r.pull_request_id, r.reviewer_id, ''
+			FROM pull_request_reviewers r
+			WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.user_id = r.reviewer_id)
+			ORDER BY r.pull_request_id, r.reviewer_id
+		`,
+		fix: `
+			DELETE FROM pull_request_reviewers r
+			WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.user_id = r.reviewer_id)
+		`,
+	},
+	{
+		name: domain.IntegrityAuthorReviewer,
+		find: `
+			SELECT pr.pull_request_id, pr.author_id, u.team_name
+			FROM pull_requests pr
+			JOIN pull_request_reviewers r ON r.pull_request_id = pr.pull_request_id AND r.reviewer_id = pr.author_id
+			JOIN users u ON u.user_id = pr.author_id
+			LEFT JOIN team_settings ts ON ts.team_name = u.team_name
+			WHERE pr.status = 'OPEN' AND NOT COALESCE(ts.allow_author_review, FALSE)
+			ORDER BY pr.pull_request_id
+		`,
+		fix: `
+			DELETE FROM pull_request_reviewers r
+			USING pull_requests pr
+			JOIN users u ON u.user_id = pr.author_id
+			LEFT JOIN team_settings ts ON ts.team_name = u.team_name
+			WHERE r.pull_request_id = pr.pull_request_id AND r.reviewer_id = pr.author_id
+			  AND pr.status = 'OPEN' AND NOT COALESCE(ts.allow_author_review, FALSE)
+		`,
+	},
+	{
+		name: domain.IntegrityFutureMerge,
+		find: `
+			SELECT pull_request_id, '', ''
+			FROM pull_requests
+			WHERE merged_at > $1
+			ORDER BY pull_request_id
+		`,
+		fix:     `UPDATE pull_requests SET merged_at = $1 WHERE merged_at > $1`,
+		withNow: true,
+	},
+	{
+		name: domain.IntegrityMissingTeam,
+		find: `
+			SELECT '', u.user_id, u.team_name
+			FROM users u
+			WHERE NOT EXISTS (SELECT 1 FROM teams t WHERE t.name = u.team_name)
+			ORDER BY u.user_id
+		`,
+		fix: `
+			INSERT INTO teams (name)
+			SELECT DISTINCT u.team_name
+			FROM users u
+			WHERE NOT EXISTS (SELECT 1 FROM teams t WHERE t.name = u.team_name)
+			ON CONFLICT (name) DO NOTHING
+		`,
+	},
+}, orphanChecks...)
+
+// orphanChecks find the reviewer state that outlived the assignment because
+// the service stopped between removing a reviewer and cleaning up after it.
+// Accepted and expired acceptances are kept on purpose for the statistics.
+var orphanChecks = []integrityCheck{
+	{
+		name: domain.IntegrityOrphanedCompletion,
+		find: `
+			SELECT c.pull_request_id, c.reviewer_id, ''
+			FROM review_completions c
+			WHERE NOT EXISTS (
+			    SELECT 1 FROM pull_request_reviewers r
+			    WHERE r.pull_request_id = c.pull_request_id AND r.reviewer_id = c.reviewer_id
+			)
+			ORDER BY c.pull_request_id, c.reviewer_id
+		`,
+		fix: `
+			DELETE FROM review_completions c
+			WHERE NOT EXISTS (
+			    SELECT 1 FROM pull_request_reviewers r
+			    WHERE r.pull_request_id = c.pull_request_id AND r.reviewer_id = c.reviewer_id
+			)
+		`,
+	},
+	{
+		name: domain.IntegrityOrphanedAcceptance,
+		find: `
+			SELECT a.pull_request_id, a.reviewer_id, ''
+			FROM review_acceptances a
+			WHERE a.accepted_at IS NULL AND a.expired_at IS NULL
+			  AND NOT EXISTS (
+			    SELECT 1 FROM pull_request_reviewers r
+			    WHERE r.pull_request_id = a.pull_request_id AND r.reviewer_id = a.reviewer_id
+			  )
+			ORDER BY a.pull_request_id, a.reviewer_id
+		`,
+		fix: `
+			DELETE FROM review_acceptances a
+			WHERE a.accepted_at IS NULL AND a.expired_at IS NULL
+			  AND NOT EXISTS (
+			    SELECT 1 FROM pull_request_reviewers r
+			    WHERE r.pull_request_id = a.pull_request_id AND r.reviewer_id = a.reviewer_id
+			  )
+		`,
+	},
+}
+
+// CheckIntegrity runs every integrity check in one REPEATABLE READ
+// transaction, so that the fixes apply to exactly the issues reported: the
+// fixes see the snapshot the checks read, and a concurrent change to the rows
+// they touch fails the transaction, which is then run again.
+func (s *Store) CheckIntegrity(ctx context.Context, now time.Time, fix bool) ([]domain.IntegrityIssue, error) {
+	return s.runIntegrityChecks(ctx, integrityChecks, now, fix)
+}
+
+func (s *Store) CheckOrphans(ctx context.Context, fix bool) ([]domain.IntegrityIssue, error) {
+	return s.runIntegrityChecks(ctx, orphanChecks, time.Time{}, fix)
+}
+
+// integrityAttempts bounds how often runIntegrityChecks runs its transaction
+// again after a serialization failure.
+const integrityAttempts = 3
+
+func (s *Store) runIntegrityChecks(ctx context.Context, checks []integrityCheck, now time.Time, fix bool) ([]domain.IntegrityIssue, error) {
+	var issues []domain.IntegrityIssue
+	var err error
+	for attempt := 1; attempt <= integrityAttempts; attempt++ {
+		issues, err = s.runIntegrityChecksOnce(ctx, checks, now, fix)
+		if !serializationFailure(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return issues, nil
+}
+
+func (s *Store) runIntegrityChecksOnce(ctx context.Context, checks []integrityCheck, now time.Time, fix bool) ([]domain.IntegrityIssue, error) {
+	var issues []domain.IntegrityIssue
+	opts := pgx.TxOptions{IsoLevel: pgx.RepeatableRead}
+	err := s.withTxOptions(ctx, opts, func(tx pgx.Tx) error {
+		for _, check := range checks {
+			rows, err := tx.Query(ctx, check.find, check.args(now)...)
+			if err != nil {
+				return err
+			}
+			found := 0
+			for rows.Next() {
+				issue := domain.IntegrityIssue{Check: check.name, Fixed: fix}
+				if err := rows.Scan(&issue.PullRequestID, &issue.UserID, &issue.TeamName); err != nil {
+					rows.Close()
+					return err
+				}
+				issues = append(issues, issue)
+				found++
+			}
+			rows.Close()
+			if rows.Err() != nil {
+				return rows.Err()
+			}
+
+			if fix && found > 0 {
+				if _, err := tx.Exec(ctx, check.fix, check.args(now)...); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return issues, err
+}
+
+// serializationFailure reports whether err is a REPEATABLE READ transaction
+// failing on a concurrent update.
+func serializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "40001"
+}
@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SetTeamLead grants or revokes the lead role. Only current members of the
+// team may be granted it.
+func (s *Store) SetTeamLead(ctx context.Context, teamName, userID string, isLead bool) error {
+	return s.withTx(ctx, func(tx pgx.Tx) error {
+		var member bool
+		err := tx.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM team_members WHERE team_name = t.name AND user_id = u.user_id)
+			FROM teams t, users u
+			WHERE t.name = $1 AND u.user_id = $2
+		`, teamName, userID).Scan(&member)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return missingTeamOrUser(ctx, tx, teamName)
+		}
+		if err != nil {
+			return err
+		}
+
+		if !isLead {
+			_, err := tx.Exec(ctx, `DELETE FROM team_leads WHERE team_name = $1 AND user_id = $2`, teamName, userID)
+			return err
+		}
+		if !member {
+			return domain.ErrInvalidLead
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO team_leads (team_name, user_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, teamName, userID)
+		return err
+	})
+}
+
+// missingTeamOrUser tells which of the team and the user does not exist.
+func missingTeamOrUser(ctx context.Context, tx pgx.Tx, teamName string) error {
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM teams WHERE name = $1)`, teamName).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrTeamNotFound
+	}
+	return domain.ErrUserNotFound
+}
+
+func (s *Store) ListTeamLeads(ctx context.Context, teamName string) ([]string, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT user_id
+		FROM team_leads
+		WHERE team_name = $1
+		ORDER BY user_id
+	`, teamName)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (s *Store) LinkPullRequests(ctx context.Context, prID, blockedBy string) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		INSERT INTO pr_links (pull_request_id, blocked_by)
+		VALUES ($1, $2)
+		ON CONFLICT (pull_request_id, blocked_by) DO NOTHING
+	`, prID, blockedBy)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		// A foreign key violation: one of the pull requests is gone, e.g.
+		// archived since it was read.
+		return false, domain.ErrPullRequestNotFound
+	}
+	if err != nil {
+		return false, translateError(err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *Store) UnlinkPullRequests(ctx context.Context, prID, blockedBy string) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		DELETE FROM pr_links WHERE pull_request_id = $1 AND blocked_by = $2
+	`, prID, blockedBy)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
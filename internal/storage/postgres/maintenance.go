@@ -0,0 +1,23 @@
+package postgres
+
+import (
+	"context"
+
+	"Avito2025/internal/domain"
+)
+
+func (s *Store) GetMaintenance(ctx context.Context) (domain.Maintenance, error) {
+	var maintenance domain.Maintenance
+	err := s.pool.QueryRow(ctx, `SELECT enabled, updated_at FROM maintenance`).
+		Scan(&maintenance.Enabled, &maintenance.UpdatedAt)
+	return maintenance, err
+}
+
+func (s *Store) SetMaintenance(ctx context.Context, enabled bool) (domain.Maintenance, error) {
+	var maintenance domain.Maintenance
+	err := s.pool.QueryRow(ctx, `
+		UPDATE maintenance SET enabled = $1, updated_at = NOW()
+		RETURNING enabled, updated_at
+	`, enabled).Scan(&maintenance.Enabled, &maintenance.UpdatedAt)
+	return maintenance, err
+}
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Store) AppendMembershipChanges(ctx context.Context, changes []domain.MembershipChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, change := range changes {
+		batch.Queue(`
+			INSERT INTO team_membership_history (team_name, user_id, kind, is_active)
+			VALUES ($1, $2, $3, $4)
+		`, change.TeamName, change.UserID, string(change.Kind), change.IsActive)
+	}
+	return s.pool.SendBatch(ctx, batch).Close()
+}
+
+func (s *Store) ListMembershipHistory(ctx context.Context, teamName string) ([]domain.MembershipChange, error) {
+	var name string
+	if err := s.pool.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1`, teamName).Scan(&name); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrTeamNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT team_name, user_id, kind, is_active, changed_at
+		FROM team_membership_history
+		WHERE team_name = $1
+		ORDER BY changed_at, id
+	`, teamName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []domain.MembershipChange
+	for rows.Next() {
+		var change domain.MembershipChange
+		var kind string
+		if err := rows.Scan(&change.TeamName, &change.UserID, &kind, &change.IsActive, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		change.Kind = domain.MembershipChangeKind(kind)
+		changes = append(changes, change)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return changes, nil
+}
@@ -0,0 +1,316 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"Avito2025/internal/config"
+	"Avito2025/internal/storage"
+	"Avito2025/internal/storage/postgres/migrations"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// migrationLockKey identifies the advisory lock serialising migrations across
+// replicas that start at the same time.
+const migrationLockKey int64 = 0x5265766965776572 // "Reviewer"
+
+// Migration phases. PhasePre applies the pre-deploy migrations, PhasePost all
+// of them, and PhaseNone none but still refuses to start on a schema missing
+// pre-deploy migrations.
+const (
+	PhaseNone = "none"
+	PhasePre  = "pre"
+	PhasePost = "post"
+)
+
+// migration is an embedded migration file. name, without the directory, is
+// what schema_migrations records.
+type migration struct {
+	name string
+	path string
+	post bool
+}
+
+// Migrate applies the migrations of the phase on its own connection and
+// returns the names of the migrations applied.
+func Migrate(ctx context.Context, cfg config.PostgresConfig, phase string) ([]string, error) {
+	connCfg, err := pgx.ParseConfig(cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("parse postgres dsn: %w", err)
+	}
+	return applyMigrations(ctx, connCfg, phase)
+}
+
+// migrationConn is the part of *pgx.Conn that migrations run on.
+type migrationConn interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// applyMigrations applies the embedded migrations of the phase on a
+// connection of its own, see runMigrations.
+func applyMigrations(ctx context.Context, connCfg *pgx.ConnConfig, phase string) ([]string, error) {
+	switch phase {
+	case "":
+		phase = PhasePost
+	case PhaseNone, PhasePre, PhasePost:
+	default:
+		return nil, fmt.Errorf("unsupported migrate phase: %s", phase)
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, connCfg)
+	if err != nil {
+		return nil, fmt.Errorf("connect postgres: %w", err)
+	}
+	// Closing the session also releases the lock should the unlock fail.
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	return runMigrations(ctx, conn, migrations.Files, phase)
+}
+
+// runMigrations applies the migrations in fsys of the phase that are not yet
+// recorded in schema_migrations, each in a transaction of its own together
+// with its record. A session advisory lock makes concurrent deploys wait for
+// each other instead of applying the same file twice.
+func runMigrations(ctx context.Context, conn migrationConn, fsys fs.FS, phase string) ([]string, error) {
+	all, err := listMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	if phase == PhaseNone {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		_, err = planMigrations(all, applied, phase)
+		return nil, err
+	}
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return nil, fmt.Errorf("lock migrations: %w", err)
+	}
+	defer func() {
+		_, _ = conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+	}()
+
+	// Checksums are recorded from this release on; older rows keep NULL.
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		    name TEXT PRIMARY KEY,
+		    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS checksum TEXT;
+	`); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	// The migrations planned ahead of a blocked one are still applied, so
+	// that the error is returned after them.
+	plan, planErr := planMigrations(all, applied, phase)
+	var done []string
+	for _, m := range plan {
+		sqlBytes, err := fs.ReadFile(fsys, m.path)
+		if err != nil {
+			return done, fmt.Errorf("read migration %s: %w", m.name, err)
+		}
+
+		if err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, string(sqlBytes)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (name, checksum) VALUES ($1, $2)`, m.name, checksum(sqlBytes))
+			return err
+		}); err != nil {
+			return done, fmt.Errorf("apply migration %s: %w", m.name, err)
+		}
+		done = append(done, m.name)
+	}
+	return done, planErr
+}
+
+// planMigrations picks, from all in the order they apply, the migrations the
+// phase applies that are not applied yet. PhaseNone picks none and fails if a
+// pre-deploy migration is missing. PhasePre skips post-deploy migrations, and
+// since a pre-deploy migration may build on an earlier post-deploy one, it
+// stops at the first pre-deploy migration after a skipped one: the plan up to
+// there is returned together with an error naming both.
+func planMigrations(all []migration, applied map[string]bool, phase string) ([]migration, error) {
+	if phase == PhaseNone {
+		for _, m := range all {
+			if !m.post && !applied[m.name] {
+				return nil, fmt.Errorf("pre-deploy migration %s is not applied", m.name)
+			}
+		}
+		return nil, nil
+	}
+
+	var plan []migration
+	var pendingPost string
+	for _, m := range all {
+		if applied[m.name] {
+			continue
+		}
+		if m.post && phase == PhasePre {
+			if pendingPost == "" {
+				pendingPost = m.name
+			}
+			continue
+		}
+		if pendingPost != "" {
+			return plan, fmt.Errorf("apply migration %s: post-deploy migration %s is not applied", m.name, pendingPost)
+		}
+		plan = append(plan, m)
+	}
+	return plan, nil
+}
+
+// listMigrations lists the migrations of both phases in fsys, laid out like
+// migrations.Files, in the order of their names.
+func listMigrations(fsys fs.FS) ([]migration, error) {
+	var all []migration
+	seen := make(map[string]string)
+	for _, dir := range []string{migrations.PreDir, migrations.PostDir} {
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return nil, fmt.Errorf("read migrations: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+				continue
+			}
+			if other, ok := seen[entry.Name()]; ok {
+				return nil, fmt.Errorf("migration %s is both in %s and %s", entry.Name(), other, dir)
+			}
+			seen[entry.Name()] = dir
+			all = append(all, migration{
+				name: entry.Name(),
+				path: path.Join(dir, entry.Name()),
+				post: dir == migrations.PostDir,
+			})
+		}
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].name < all[j].name
+	})
+	return all, nil
+}
+
+// checksum is the hex SHA-256 digest of a migration file.
+func checksum(sqlBytes []byte) string {
+	sum := sha256.Sum256(sqlBytes)
+	return hex.EncodeToString(sum[:])
+}
+
+// MigrationStatus lists the migrations shipped with this release in the order
+// they apply, each with the time and checksum it was applied with, if it was,
+// followed by migrations applied by other releases that this one lacks.
+func (s *Store) MigrationStatus(ctx context.Context) ([]storage.MigrationStatus, error) {
+	all, err := listMigrations(migrations.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	type appliedRow struct {
+		name      string
+		appliedAt time.Time
+		checksum  *string
+	}
+	// The checksum is read through to_jsonb, which leaves it NULL on a schema
+	// last migrated by a release that did not record checksums yet.
+	rows, err := s.pool.Query(ctx, `SELECT name, applied_at, to_jsonb(m)->>'checksum' FROM schema_migrations m ORDER BY name`)
+	var applied []appliedRow
+	if err == nil {
+		applied, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (appliedRow, error) {
+			var r appliedRow
+			err := row.Scan(&r.name, &r.appliedAt, &r.checksum)
+			return r, err
+		})
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P01" {
+		applied, err = nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list applied migrations: %w", err)
+	}
+
+	byName := make(map[string]appliedRow, len(applied))
+	for _, r := range applied {
+		byName[r.name] = r
+	}
+	result := make([]storage.MigrationStatus, 0, len(all))
+	for _, m := range all {
+		sqlBytes, err := fs.ReadFile(migrations.Files, m.path)
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", m.name, err)
+		}
+		status := storage.MigrationStatus{
+			Name:     m.name,
+			Phase:    PhasePre,
+			Checksum: checksum(sqlBytes),
+		}
+		if m.post {
+			status.Phase = PhasePost
+		}
+		if r, ok := byName[m.name]; ok {
+			status.AppliedAt = &r.appliedAt
+			if r.checksum != nil {
+				status.AppliedChecksum = *r.checksum
+			}
+			delete(byName, m.name)
+		}
+		result = append(result, status)
+	}
+	// byName is left with the migrations this release does not ship.
+	for _, r := range applied {
+		if _, ok := byName[r.name]; !ok {
+			continue
+		}
+		status := storage.MigrationStatus{Name: r.name, AppliedAt: &r.appliedAt}
+		if r.checksum != nil {
+			status.AppliedChecksum = *r.checksum
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// appliedMigrations reads schema_migrations; a database never migrated has
+// none applied.
+func appliedMigrations(ctx context.Context, conn migrationConn) (map[string]bool, error) {
+	var names []string
+	rows, err := conn.Query(ctx, `SELECT name FROM schema_migrations`)
+	if err == nil {
+		names, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P01" {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list applied migrations: %w", err)
+	}
+
+	applied := make(map[string]bool, len(names))
+	for _, name := range names {
+		applied[name] = true
+	}
+	return applied, nil
+}
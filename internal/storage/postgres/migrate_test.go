@@ -0,0 +1,381 @@
+package postgres
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"Avito2025/internal/storage/postgres/migrations"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// phasedFiles ships a post-deploy migration between two pre-deploy ones.
+var phasedFiles = fstest.MapFS{
+	"pre/001_users.sql":      {Data: []byte("CREATE TABLE users ();")},
+	"pre/003_teams.sql":      {Data: []byte("CREATE TABLE teams ();")},
+	"pre/README.md":          {Data: []byte("not a migration")},
+	"post/002_drop_name.sql": {Data: []byte("ALTER TABLE users DROP COLUMN name;")},
+	"post/old/004_skip.sql":  {Data: []byte("nested directories are not read")},
+}
+
+func migrationNames(ms []migration) []string {
+	names := make([]string, 0, len(ms))
+	for _, m := range ms {
+		names = append(names, m.name)
+	}
+	return names
+}
+
+func TestListMigrationsOrdersPhasesByName(t *testing.T) {
+	all, err := listMigrations(phasedFiles)
+	if err != nil {
+		t.Fatalf("list migrations: %v", err)
+	}
+
+	want := []migration{
+		{name: "001_users.sql", path: "pre/001_users.sql"},
+		{name: "002_drop_name.sql", path: "post/002_drop_name.sql", post: true},
+		{name: "003_teams.sql", path: "pre/003_teams.sql"},
+	}
+	if !slices.Equal(all, want) {
+		t.Fatalf("expected %v, got %v", want, all)
+	}
+}
+
+func TestListMigrationsRejectsANameInBothPhases(t *testing.T) {
+	files := fstest.MapFS{
+		"pre/001_users.sql":  {Data: []byte("CREATE TABLE users ();")},
+		"post/001_users.sql": {Data: []byte("DROP TABLE users;")},
+	}
+	if _, err := listMigrations(files); err == nil {
+		t.Fatal("expected a migration in both phases to be refused")
+	}
+}
+
+func TestListMigrationsReadsEmbeddedFiles(t *testing.T) {
+	all, err := listMigrations(migrations.Files)
+	if err != nil {
+		t.Fatalf("list migrations: %v", err)
+	}
+	if len(all) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+	if !slices.IsSortedFunc(all, func(a, b migration) int { return cmp.Compare(a.name, b.name) }) {
+		t.Fatalf("expected migrations sorted by name, got %v", migrationNames(all))
+	}
+}
+
+func TestPlanMigrations(t *testing.T) {
+	all, err := listMigrations(phasedFiles)
+	if err != nil {
+		t.Fatalf("list migrations: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		phase   string
+		applied []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "post applies both phases in order",
+			phase: PhasePost,
+			want:  []string{"001_users.sql", "002_drop_name.sql", "003_teams.sql"},
+		},
+		{
+			name:    "post skips applied migrations",
+			phase:   PhasePost,
+			applied: []string{"001_users.sql", "002_drop_name.sql"},
+			want:    []string{"003_teams.sql"},
+		},
+		{
+			name:    "pre waits for an earlier post-deploy migration",
+			phase:   PhasePre,
+			want:    []string{"001_users.sql"},
+			wantErr: "apply migration 003_teams.sql: post-deploy migration 002_drop_name.sql is not applied",
+		},
+		{
+			name:    "pre goes on once the post-deploy migration ran",
+			phase:   PhasePre,
+			applied: []string{"002_drop_name.sql"},
+			want:    []string{"001_users.sql", "003_teams.sql"},
+		},
+		{
+			name:    "pre leaves a trailing post-deploy migration",
+			phase:   PhasePre,
+			applied: []string{"001_users.sql", "003_teams.sql"},
+		},
+		{
+			name:    "none refuses a schema missing pre-deploy migrations",
+			phase:   PhaseNone,
+			applied: []string{"001_users.sql"},
+			wantErr: "pre-deploy migration 003_teams.sql is not applied",
+		},
+		{
+			name:    "none accepts a schema missing only post-deploy migrations",
+			phase:   PhaseNone,
+			applied: []string{"001_users.sql", "003_teams.sql"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := make(map[string]bool, len(tt.applied))
+			for _, name := range tt.applied {
+				applied[name] = true
+			}
+
+			plan, err := planMigrations(all, applied, tt.phase)
+			if got := migrationNames(plan); !slices.Equal(got, tt.want) {
+				t.Errorf("expected plan %v, got %v", tt.want, got)
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestApplyMigrationsRejectsUnknownPhase(t *testing.T) {
+	// The phase is checked before connecting, so no database is needed.
+	if _, err := applyMigrations(context.Background(), nil, "sideways"); err == nil {
+		t.Fatal("expected an unknown phase to be refused")
+	}
+}
+
+// fakeServer stands in for Postgres behind runMigrations: it keeps the
+// advisory lock and the schema_migrations rows, and counts the statements of
+// committed transactions.
+type fakeServer struct {
+	lock chan struct{}
+	// waiting is told of every connection about to take the lock, and gate,
+	// when set, holds back the first statement run until it is closed.
+	waiting chan struct{}
+	gate    chan struct{}
+	running chan struct{}
+	hold    sync.Once
+	fail    string
+
+	mu      sync.Mutex
+	table   bool
+	records map[string]string
+	runs    map[string]int
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{
+		lock:    make(chan struct{}, 1),
+		waiting: make(chan struct{}, 2),
+		records: make(map[string]string),
+		runs:    make(map[string]int),
+	}
+}
+
+type fakeConn struct {
+	server *fakeServer
+	locked bool
+}
+
+func (c *fakeConn) Exec(ctx context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	switch {
+	case strings.HasPrefix(sql, "SELECT pg_advisory_lock"):
+		c.server.waiting <- struct{}{}
+		select {
+		case c.server.lock <- struct{}{}:
+			c.locked = true
+		case <-ctx.Done():
+			return pgconn.CommandTag{}, ctx.Err()
+		}
+	case strings.HasPrefix(sql, "SELECT pg_advisory_unlock"):
+		if c.locked {
+			<-c.server.lock
+			c.locked = false
+		}
+	case strings.Contains(sql, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		c.server.mu.Lock()
+		c.server.table = true
+		c.server.mu.Unlock()
+	default:
+		return pgconn.CommandTag{}, fmt.Errorf("unexpected statement %q", sql)
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+func (c *fakeConn) Query(_ context.Context, sql string, _ ...any) (pgx.Rows, error) {
+	if sql != `SELECT name FROM schema_migrations` {
+		return nil, fmt.Errorf("unexpected query %q", sql)
+	}
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	if !c.server.table {
+		return nil, &pgconn.PgError{Code: "42P01"}
+	}
+	return &fakeRows{names: slices.Sorted(maps.Keys(c.server.records))}, nil
+}
+
+func (c *fakeConn) Begin(context.Context) (pgx.Tx, error) {
+	return &fakeTx{server: c.server, staged: make(map[string]string)}, nil
+}
+
+type fakeRows struct {
+	pgx.Rows
+	names []string
+	next  int
+}
+
+func (r *fakeRows) Next() bool {
+	r.next++
+	return r.next <= len(r.names)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	*dest[0].(*string) = r.names[r.next-1]
+	return nil
+}
+
+func (r *fakeRows) Close()     {}
+func (r *fakeRows) Err() error { return nil }
+
+type fakeTx struct {
+	pgx.Tx
+	server *fakeServer
+	ran    []string
+	staged map[string]string
+	closed bool
+}
+
+func (tx *fakeTx) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if strings.HasPrefix(sql, "INSERT INTO schema_migrations") {
+		tx.staged[args[0].(string)] = args[1].(string)
+		return pgconn.CommandTag{}, nil
+	}
+	if tx.server.gate != nil {
+		tx.server.hold.Do(func() {
+			close(tx.server.running)
+			<-tx.server.gate
+		})
+	}
+	if sql == tx.server.fail {
+		return pgconn.CommandTag{}, errors.New("syntax error")
+	}
+	tx.ran = append(tx.ran, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	tx.server.mu.Lock()
+	defer tx.server.mu.Unlock()
+	tx.closed = true
+	for name := range tx.staged {
+		if _, ok := tx.server.records[name]; ok {
+			return &pgconn.PgError{Code: "23505", ConstraintName: "schema_migrations_pkey"}
+		}
+	}
+	maps.Copy(tx.server.records, tx.staged)
+	for _, sql := range tx.ran {
+		tx.server.runs[sql]++
+	}
+	return nil
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+	return nil
+}
+
+func TestRunMigrationsSerializesConcurrentDeploys(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer()
+	server.gate = make(chan struct{})
+	server.running = make(chan struct{})
+
+	// The first deploy stops in its first migration, holding the lock, while
+	// the second one reaches for the lock.
+	type result struct {
+		done []string
+		err  error
+	}
+	first, second := make(chan result, 1), make(chan result, 1)
+	go func() {
+		done, err := runMigrations(ctx, &fakeConn{server: server}, phasedFiles, PhasePost)
+		first <- result{done, err}
+	}()
+	<-server.waiting
+	<-server.running
+	go func() {
+		done, err := runMigrations(ctx, &fakeConn{server: server}, phasedFiles, PhasePost)
+		second <- result{done, err}
+	}()
+	<-server.waiting
+	close(server.gate)
+
+	a, b := <-first, <-second
+	if a.err != nil || b.err != nil {
+		t.Fatalf("unexpected errors: %v, %v", a.err, b.err)
+	}
+	if want := []string{"001_users.sql", "002_drop_name.sql", "003_teams.sql"}; !slices.Equal(a.done, want) || len(b.done) != 0 {
+		t.Fatalf("expected the first deploy to apply %v and the second none, got %v and %v", want, a.done, b.done)
+	}
+	for sql, runs := range server.runs {
+		if runs != 1 {
+			t.Errorf("expected %q to run once, got %d", sql, runs)
+		}
+	}
+	if len(server.lock) != 0 {
+		t.Error("expected the lock to be released")
+	}
+}
+
+func TestRunMigrationsRecordsAppliedFiles(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer()
+	server.fail = "CREATE TABLE teams ();"
+
+	done, err := runMigrations(ctx, &fakeConn{server: server}, phasedFiles, PhasePost)
+	if err == nil || !strings.Contains(err.Error(), "apply migration 003_teams.sql") {
+		t.Fatalf("expected 003_teams.sql to fail, got %v", err)
+	}
+	// The failed file is rolled back with its record; the ones before stay.
+	want := map[string]string{
+		"001_users.sql":     checksum(phasedFiles["pre/001_users.sql"].Data),
+		"002_drop_name.sql": checksum(phasedFiles["post/002_drop_name.sql"].Data),
+	}
+	if !slices.Equal(done, []string{"001_users.sql", "002_drop_name.sql"}) || !maps.Equal(server.records, want) {
+		t.Fatalf("expected %v recorded, got %v recorded after applying %v", want, server.records, done)
+	}
+	if len(server.lock) != 0 {
+		t.Error("expected the lock to be released after a failure")
+	}
+
+	server.fail = ""
+	if done, err = runMigrations(ctx, &fakeConn{server: server}, phasedFiles, PhasePost); err != nil || !slices.Equal(done, []string{"003_teams.sql"}) {
+		t.Fatalf("expected the next deploy to apply only 003_teams.sql, got %v, %v", done, err)
+	}
+}
+
+func TestRunMigrationsPhaseNoneOnlyChecks(t *testing.T) {
+	server := newFakeServer()
+
+	_, err := runMigrations(context.Background(), &fakeConn{server: server}, phasedFiles, PhaseNone)
+	if err == nil || err.Error() != "pre-deploy migration 001_users.sql is not applied" {
+		t.Fatalf("expected an unmigrated schema to be refused, got %v", err)
+	}
+	if server.table || len(server.waiting) != 0 {
+		t.Error("expected no lock and no schema_migrations table")
+	}
+}
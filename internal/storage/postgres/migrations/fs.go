@@ -1,6 +1,17 @@
+// Package migrations embeds the schema migrations, split by the phase of a
+// deploy they run in. Migrations in pre keep the schema compatible with the
+// release being replaced and run before it is replaced; migrations in post,
+// e.g. dropping a column the old release still reads, run once it is gone.
+// File names are numbered across both directories and applied in that order.
 package migrations
 
 import "embed"
 
-//go:embed *.sql
+// Directories of the migration phases within Files.
+const (
+	PreDir  = "pre"
+	PostDir = "post"
+)
+
+//go:embed pre post
 var Files embed.FS
@@ -0,0 +1,14 @@
+-- Completions left behind by reviewers removed without ReopenReviews would
+-- break the constraint.
+DELETE FROM review_completions c
+WHERE NOT EXISTS (
+    SELECT 1 FROM pull_request_reviewers r
+    WHERE r.pull_request_id = c.pull_request_id AND r.reviewer_id = c.reviewer_id
+);
+
+-- The previous release rewrites every reviewer row on update, which would now
+-- drop the completions of the reviewers it keeps.
+ALTER TABLE review_completions
+    ADD CONSTRAINT review_completions_reviewer_fkey
+    FOREIGN KEY (pull_request_id, reviewer_id)
+    REFERENCES pull_request_reviewers (pull_request_id, reviewer_id) ON DELETE CASCADE;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS team_rotations (
+    team_name TEXT PRIMARY KEY REFERENCES teams(name) ON DELETE CASCADE,
+    user_ids TEXT[] NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
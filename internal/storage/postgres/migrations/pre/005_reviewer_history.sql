@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS reviewer_history (
+    id BIGSERIAL PRIMARY KEY,
+    pull_request_id TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    reviewer_id TEXT NOT NULL REFERENCES users(user_id),
+    action TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS reviewer_history_pull_request_id_idx ON reviewer_history (pull_request_id);
+CREATE INDEX IF NOT EXISTS reviewer_history_reviewer_id_idx ON reviewer_history (reviewer_id);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS team_settings (
+    team_name TEXT PRIMARY KEY REFERENCES teams(name) ON DELETE CASCADE,
+    required_reviewers INTEGER NOT NULL DEFAULT 2,
+    allow_single_reviewer BOOLEAN NOT NULL DEFAULT TRUE,
+    allow_author_review BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+ALTER TABLE events ADD COLUMN IF NOT EXISTS team_name TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS events_team_name_idx ON events (team_name);
@@ -0,0 +1,9 @@
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS remind_after_seconds BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS escalate_after_seconds BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS reassign_after_seconds BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS pull_request_reminders (
+    pull_request_id TEXT PRIMARY KEY REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    stage INTEGER NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS pull_requests_archive (
+    pull_request_id TEXT PRIMARY KEY,
+    pull_request_name TEXT NOT NULL,
+    author_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    merged_at TIMESTAMPTZ NULL,
+    labels TEXT[] NOT NULL DEFAULT '{}',
+    url TEXT NOT NULL DEFAULT '',
+    priority TEXT NOT NULL DEFAULT 'NORMAL',
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS pull_request_reviewers_archive (
+    pull_request_id TEXT NOT NULL REFERENCES pull_requests_archive(pull_request_id) ON DELETE CASCADE,
+    reviewer_id TEXT NOT NULL,
+    PRIMARY KEY (pull_request_id, reviewer_id)
+);
+
+CREATE TABLE IF NOT EXISTS reviewer_history_archive (
+    id BIGINT PRIMARY KEY,
+    pull_request_id TEXT NOT NULL REFERENCES pull_requests_archive(pull_request_id) ON DELETE CASCADE,
+    reviewer_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS pull_requests_merged_at_idx ON pull_requests (merged_at) WHERE status = 'MERGED';
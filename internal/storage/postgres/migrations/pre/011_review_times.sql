@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS pull_request_review_times (
+    pull_request_id TEXT PRIMARY KEY REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    first_comment_at TIMESTAMPTZ NULL,
+    first_approval_at TIMESTAMPTZ NULL
+);
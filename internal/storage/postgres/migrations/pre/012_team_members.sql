@@ -0,0 +1,14 @@
+-- users.team_name is kept as the user's primary team; team_members lists
+-- every team a user belongs to.
+CREATE TABLE IF NOT EXISTS team_members (
+    team_name TEXT NOT NULL REFERENCES teams(name) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    joined_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (team_name, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS team_members_user_id_idx ON team_members (user_id);
+
+INSERT INTO team_members (team_name, user_id)
+SELECT team_name, user_id FROM users
+ON CONFLICT (team_name, user_id) DO NOTHING;
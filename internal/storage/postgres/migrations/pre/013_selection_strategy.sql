@@ -0,0 +1 @@
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS strategy TEXT NOT NULL DEFAULT 'random';
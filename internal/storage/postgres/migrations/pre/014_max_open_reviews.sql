@@ -0,0 +1 @@
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS max_open_reviews INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS assignment_decisions (
+    id BIGSERIAL PRIMARY KEY,
+    pull_request_id TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    team_name TEXT NOT NULL,
+    strategy TEXT NOT NULL,
+    seed BIGINT NOT NULL DEFAULT 0,
+    filters TEXT[] NOT NULL DEFAULT '{}',
+    candidates JSONB NOT NULL DEFAULT '[]',
+    selected TEXT[] NOT NULL DEFAULT '{}',
+    author_fallback BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS assignment_decisions_pull_request_id_idx ON assignment_decisions (pull_request_id);
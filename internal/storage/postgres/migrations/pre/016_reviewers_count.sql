@@ -0,0 +1,2 @@
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS reviewers_count INT NOT NULL DEFAULT 0;
+ALTER TABLE pull_requests_archive ADD COLUMN IF NOT EXISTS reviewers_count INT NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS maintenance (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO maintenance (id) VALUES (TRUE) ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,11 @@
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS time_zone TEXT NOT NULL DEFAULT 'UTC';
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS work_start_minutes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS work_end_minutes INTEGER NOT NULL DEFAULT 0;
+-- Bit n is set when weekday n (0 = Sunday) is a working day; 62 is Monday to Friday.
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS work_days INTEGER NOT NULL DEFAULT 62;
+
+CREATE TABLE IF NOT EXISTS deferred_assignments (
+    pull_request_id TEXT PRIMARY KEY REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    team_name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
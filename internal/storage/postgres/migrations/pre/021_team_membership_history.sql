@@ -0,0 +1,22 @@
+-- Every change of a team's roster; is_active is the member's status after the
+-- change, so the latest row before a moment gives the status at that moment.
+CREATE TABLE IF NOT EXISTS team_membership_history (
+    id BIGSERIAL PRIMARY KEY,
+    team_name TEXT NOT NULL REFERENCES teams(name) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    is_active BOOLEAN NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS team_membership_history_team_name_idx ON team_membership_history (team_name, changed_at);
+
+-- Existing members are recorded as having joined with their current status.
+INSERT INTO team_membership_history (team_name, user_id, kind, is_active, changed_at)
+SELECT tm.team_name, tm.user_id, 'joined', u.is_active, tm.joined_at
+FROM team_members tm
+JOIN users u ON u.user_id = tm.user_id
+WHERE NOT EXISTS (
+    SELECT 1 FROM team_membership_history h
+    WHERE h.team_name = tm.team_name AND h.user_id = tm.user_id
+);
@@ -0,0 +1 @@
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS review_cooldown_seconds BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS pending_assignments (
+    pull_request_id TEXT PRIMARY KEY REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
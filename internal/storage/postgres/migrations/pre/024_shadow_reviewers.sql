@@ -0,0 +1,3 @@
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS shadow_pool TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS shadow_reviewer_id TEXT REFERENCES users(user_id);
+ALTER TABLE pull_requests_archive ADD COLUMN IF NOT EXISTS shadow_reviewer_id TEXT;
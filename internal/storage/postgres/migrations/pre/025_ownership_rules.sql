@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS team_ownership_rules (
+    team_name TEXT NOT NULL REFERENCES teams(name) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    path_prefix TEXT NOT NULL,
+    user_ids TEXT[] NOT NULL,
+    PRIMARY KEY (team_name, position)
+);
+
+CREATE TABLE IF NOT EXISTS pull_request_files (
+    pull_request_id TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    path TEXT NOT NULL,
+    PRIMARY KEY (pull_request_id, path)
+);
+
+ALTER TABLE assignment_decisions ADD COLUMN IF NOT EXISTS owners TEXT[] NOT NULL DEFAULT '{}';
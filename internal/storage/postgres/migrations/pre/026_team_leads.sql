@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS team_leads (
+    team_name TEXT NOT NULL REFERENCES teams(name) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    PRIMARY KEY (team_name, user_id)
+);
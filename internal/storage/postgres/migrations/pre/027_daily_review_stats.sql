@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS daily_review_stats (
+    day DATE NOT NULL,
+    team_name TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    prs_created INTEGER NOT NULL DEFAULT 0,
+    prs_merged INTEGER NOT NULL DEFAULT 0,
+    reassignments INTEGER NOT NULL DEFAULT 0,
+    avg_merge_seconds DOUBLE PRECISION,
+    PRIMARY KEY (day, team_name, user_id)
+);
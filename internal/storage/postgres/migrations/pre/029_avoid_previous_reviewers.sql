@@ -0,0 +1 @@
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS avoid_previous_reviewers BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,24 @@
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS reassignments INT NOT NULL DEFAULT 0;
+ALTER TABLE pull_requests_archive ADD COLUMN IF NOT EXISTS reassignments INT NOT NULL DEFAULT 0;
+
+UPDATE pull_requests pr
+SET reassignments = h.count
+FROM (
+    SELECT pull_request_id, COUNT(*) AS count
+    FROM reviewer_history
+    WHERE action = 'UNASSIGNED' AND reason = 'reassign'
+    GROUP BY pull_request_id
+) h
+WHERE h.pull_request_id = pr.pull_request_id;
+
+UPDATE pull_requests_archive pr
+SET reassignments = h.count
+FROM (
+    SELECT pull_request_id, COUNT(*) AS count
+    FROM reviewer_history_archive
+    WHERE action = 'UNASSIGNED' AND reason = 'reassign'
+    GROUP BY pull_request_id
+) h
+WHERE h.pull_request_id = pr.pull_request_id;
+
+CREATE INDEX IF NOT EXISTS pull_requests_reassignments_idx ON pull_requests (reassignments) WHERE status = 'OPEN';
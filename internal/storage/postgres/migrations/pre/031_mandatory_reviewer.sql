@@ -0,0 +1,2 @@
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS mandatory_reviewer_id TEXT;
+ALTER TABLE assignment_decisions ADD COLUMN IF NOT EXISTS mandatory_reviewer_id TEXT;
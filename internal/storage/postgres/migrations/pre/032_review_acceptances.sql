@@ -0,0 +1,15 @@
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS accept_timeout_seconds BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS review_acceptances (
+    pull_request_id TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    reviewer_id TEXT NOT NULL,
+    requested_at TIMESTAMPTZ NOT NULL,
+    accept_by TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    expired_at TIMESTAMPTZ,
+    PRIMARY KEY (pull_request_id, reviewer_id)
+);
+
+CREATE INDEX IF NOT EXISTS review_acceptances_pending_idx ON review_acceptances (accept_by)
+    WHERE accepted_at IS NULL AND expired_at IS NULL;
+CREATE INDEX IF NOT EXISTS review_acceptances_requested_at_idx ON review_acceptances (requested_at);
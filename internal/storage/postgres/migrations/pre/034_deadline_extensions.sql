@@ -0,0 +1,15 @@
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS auto_approve_extension_seconds BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS deadline_extensions (
+    id BIGSERIAL PRIMARY KEY,
+    pull_request_id TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    reviewer_id TEXT NOT NULL,
+    until TIMESTAMPTZ NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    requested_at TIMESTAMPTZ NOT NULL,
+    decided_by TEXT NOT NULL DEFAULT '',
+    decided_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS deadline_extensions_pull_request_idx ON deadline_extensions (pull_request_id, id);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS team_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    team_name TEXT NOT NULL REFERENCES teams(name) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS team_tokens_team_idx ON team_tokens (team_name, id);
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS pull_requests_author_unmerged_idx ON pull_requests (author_id) WHERE status <> 'MERGED';
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS review_completions (
+    pull_request_id TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    reviewer_id TEXT NOT NULL,
+    completed_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (pull_request_id, reviewer_id)
+);
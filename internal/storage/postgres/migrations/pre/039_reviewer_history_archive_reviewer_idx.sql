@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS reviewer_history_archive_reviewer_id_idx ON reviewer_history_archive (reviewer_id);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS pr_links (
+    pull_request_id TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    blocked_by TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (pull_request_id, blocked_by),
+    CHECK (pull_request_id <> blocked_by)
+);
+
+CREATE INDEX IF NOT EXISTS pr_links_blocked_by_idx ON pr_links (blocked_by);
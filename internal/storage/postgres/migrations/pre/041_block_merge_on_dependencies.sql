@@ -0,0 +1 @@
+ALTER TABLE team_settings ADD COLUMN IF NOT EXISTS block_merge_on_dependencies BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,34 @@
+-- Reviewers keep the place they were assigned to; the first place holds the
+-- primary reviewer. Rows of the previous release get place 0 and the time
+-- they were written, and are ordered by reviewer ID among themselves.
+ALTER TABLE pull_request_reviewers ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
+ALTER TABLE pull_request_reviewers ADD COLUMN IF NOT EXISTS assigned_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE pull_request_reviewers_archive ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
+ALTER TABLE pull_request_reviewers_archive ADD COLUMN IF NOT EXISTS assigned_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+-- NULL reads as the creation time of the pull request.
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS shadow_assigned_at TIMESTAMPTZ;
+ALTER TABLE pull_requests_archive ADD COLUMN IF NOT EXISTS shadow_assigned_at TIMESTAMPTZ;
+
+-- Existing reviewers are placed in the order they were read in so far and
+-- taken as assigned at creation.
+UPDATE pull_request_reviewers r
+SET position = o.position,
+    assigned_at = o.created_at
+FROM (
+    SELECT r.pull_request_id, r.reviewer_id, pr.created_at,
+           ROW_NUMBER() OVER (PARTITION BY r.pull_request_id ORDER BY r.reviewer_id) - 1 AS position
+    FROM pull_request_reviewers r
+    JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id
+) o
+WHERE o.pull_request_id = r.pull_request_id AND o.reviewer_id = r.reviewer_id;
+
+UPDATE pull_request_reviewers_archive r
+SET position = o.position,
+    assigned_at = o.created_at
+FROM (
+    SELECT r.pull_request_id, r.reviewer_id, pr.created_at,
+           ROW_NUMBER() OVER (PARTITION BY r.pull_request_id ORDER BY r.reviewer_id) - 1 AS position
+    FROM pull_request_reviewers_archive r
+    JOIN pull_requests_archive pr ON pr.pull_request_id = r.pull_request_id
+) o
+WHERE o.pull_request_id = r.pull_request_id AND o.reviewer_id = r.reviewer_id;
@@ -0,0 +1,2 @@
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS co_author_ids TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE pull_requests_archive ADD COLUMN IF NOT EXISTS co_author_ids TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS daily_stats_builds (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    last_day DATE NOT NULL
+);
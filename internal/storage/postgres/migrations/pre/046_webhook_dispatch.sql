@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS webhook_dispatch (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    last_seq BIGINT NOT NULL,
+    owner TEXT NOT NULL DEFAULT '',
+    lease_until TIMESTAMPTZ NOT NULL DEFAULT '-infinity'
+);
+
+-- Dispatch picks up after the events that exist when it is introduced
+-- instead of replaying the whole change feed to the webhooks.
+INSERT INTO webhook_dispatch (last_seq)
+SELECT COALESCE(MAX(seq), 0) FROM events
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,2 @@
+ALTER TABLE team_tokens
+    ADD COLUMN IF NOT EXISTS user_id TEXT NULL REFERENCES users(user_id) ON DELETE CASCADE;
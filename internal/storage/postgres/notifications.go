@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const notificationColumns = `user_id, notify_channel, notify_address, notify_digest_seconds, notify_digest_seq, notify_digest_sent_at`
+
+func scanNotificationPreference(row pgx.Row) (domain.NotificationPreference, error) {
+	var (
+		pref          domain.NotificationPreference
+		channel       string
+		digestSeconds int64
+		sentAt        *time.Time
+	)
+	if err := row.Scan(&pref.UserID, &channel, &pref.Address, &digestSeconds, &pref.DigestSeq, &sentAt); err != nil {
+		return domain.NotificationPreference{}, err
+	}
+	pref.Channel = domain.NotifyChannel(channel)
+	pref.Digest = time.Duration(digestSeconds) * time.Second
+	if sentAt != nil {
+		pref.DigestSentAt = sentAt.UTC()
+	}
+	return pref, nil
+}
+
+func (s *Store) SetNotificationPreference(ctx context.Context, pref domain.NotificationPreference) (domain.NotificationPreference, error) {
+	// Column references on the right-hand side see the row before the
+	// update, so the cursor only moves when the digest is switched on.
+	stored, err := scanNotificationPreference(s.pool.QueryRow(ctx, `
+		UPDATE users
+		SET notify_channel = $2,
+		    notify_address = $3,
+		    notify_digest_seconds = $4,
+		    notify_digest_seq = CASE
+		        WHEN notify_digest_seconds = 0 AND $4 > 0 THEN COALESCE((SELECT MAX(seq) FROM events), 0)
+		        ELSE notify_digest_seq
+		    END,
+		    notify_digest_sent_at = CASE
+		        WHEN notify_digest_seconds = 0 AND $4 > 0 THEN $5
+		        ELSE notify_digest_sent_at
+		    END,
+		    updated_at = NOW()
+		WHERE user_id = $1
+		RETURNING `+notificationColumns,
+		pref.UserID, string(pref.Channel), pref.Address, int64(pref.Digest/time.Second), pref.DigestSentAt))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.NotificationPreference{}, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return domain.NotificationPreference{}, err
+	}
+	return stored, nil
+}
+
+func (s *Store) GetNotificationPreference(ctx context.Context, userID string) (domain.NotificationPreference, error) {
+	pref, err := scanNotificationPreference(s.pool.QueryRow(ctx, `
+		SELECT `+notificationColumns+`
+		FROM users
+		WHERE user_id = $1
+	`, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.NotificationPreference{}, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return domain.NotificationPreference{}, err
+	}
+	return pref, nil
+}
+
+func (s *Store) ListDueDigests(ctx context.Context, now time.Time) ([]domain.NotificationPreference, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+notificationColumns+`
+		FROM users
+		WHERE notify_digest_seconds > 0
+		  AND notify_channel <> 'none'
+		  AND (notify_digest_sent_at IS NULL
+		       OR notify_digest_sent_at + make_interval(secs => notify_digest_seconds) <= $1)
+		ORDER BY user_id
+	`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []domain.NotificationPreference
+	for rows.Next() {
+		pref, err := scanNotificationPreference(rows)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, pref)
+	}
+	return due, rows.Err()
+}
+
+func (s *Store) MarkDigestSent(ctx context.Context, userID string, seq int64, at time.Time) error {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE users
+		SET notify_digest_seq = $2,
+		    notify_digest_sent_at = $3
+		WHERE user_id = $1
+	`, userID, seq, at)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
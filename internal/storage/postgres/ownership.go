@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SetOwnership replaces the team's ownership rules. An empty list removes the
+// rules altogether.
+func (s *Store) SetOwnership(ctx context.Context, ownership domain.Ownership) (domain.Ownership, error) {
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		var name string
+		err := tx.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1`, ownership.TeamName).Scan(&name)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrTeamNotFound
+			}
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM team_ownership_rules WHERE team_name = $1`, ownership.TeamName); err != nil {
+			return err
+		}
+
+		for i, rule := range ownership.Rules {
+			var members int
+			if err := tx.QueryRow(ctx, `
+				SELECT COUNT(DISTINCT user_id)
+				FROM team_members
+				WHERE team_name = $1 AND user_id = ANY($2)
+			`, ownership.TeamName, rule.UserIDs).Scan(&members); err != nil {
+				return err
+			}
+			if members != len(rule.UserIDs) {
+				return domain.ErrInvalidOwnership
+			}
+
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO team_ownership_rules (team_name, position, path_prefix, user_ids)
+				VALUES ($1, $2, $3, $4)
+			`, ownership.TeamName, i, rule.PathPrefix, rule.UserIDs); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.Ownership{}, err
+	}
+	return ownership, nil
+}
+
+func (s *Store) GetOwnership(ctx context.Context, teamName string) (domain.Ownership, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT path_prefix, user_ids
+		FROM team_ownership_rules
+		WHERE team_name = $1
+		ORDER BY position
+	`, teamName)
+	if err != nil {
+		return domain.Ownership{}, err
+	}
+	defer rows.Close()
+
+	ownership := domain.Ownership{TeamName: teamName}
+	for rows.Next() {
+		var rule domain.OwnershipRule
+		if err := rows.Scan(&rule.PathPrefix, &rule.UserIDs); err != nil {
+			return domain.Ownership{}, err
+		}
+		ownership.Rules = append(ownership.Rules, rule)
+	}
+	if rows.Err() != nil {
+		return domain.Ownership{}, rows.Err()
+	}
+	return ownership, nil
+}
@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+
+	"Avito2025/internal/domain"
+)
+
+func (s *Store) QueuePendingAssignment(ctx context.Context, prID string) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO pending_assignments (pull_request_id)
+		VALUES ($1)
+		ON CONFLICT (pull_request_id) DO NOTHING
+	`, prID)
+	return err
+}
+
+// ListPendingAssignments returns the queued pull requests, oldest first.
+func (s *Store) ListPendingAssignments(ctx context.Context) ([]domain.PendingAssignment, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT pull_request_id, created_at
+		FROM pending_assignments
+		ORDER BY created_at, pull_request_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assignments []domain.PendingAssignment
+	for rows.Next() {
+		var pending domain.PendingAssignment
+		if err := rows.Scan(&pending.PullRequestID, &pending.CreatedAt); err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, pending)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return assignments, nil
+}
+
+func (s *Store) DeletePendingAssignment(ctx context.Context, prID string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM pending_assignments WHERE pull_request_id = $1`, prID)
+	return err
+}
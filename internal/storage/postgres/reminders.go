@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"Avito2025/internal/domain"
+)
+
+// ListReminderCandidates returns open pull requests created before the cutoff
+// with the author's team, the last reminder step taken and the deadline granted
+// by approved extensions.
+func (s *Store) ListReminderCandidates(ctx context.Context, createdBefore time.Time) ([]domain.ReminderCandidate, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT pr.pull_request_id, u.team_name, pr.created_at, COALESCE(rem.stage, 0), `+extendedDeadline+`
+		FROM pull_requests pr
+		JOIN users u ON u.user_id = pr.author_id
+		LEFT JOIN pull_request_reminders rem ON rem.pull_request_id = pr.pull_request_id
+		WHERE pr.status = $1 AND pr.created_at < $2
+		ORDER BY pr.created_at
+	`, string(domain.StatusOpen), createdBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []domain.ReminderCandidate
+	for rows.Next() {
+		var candidate domain.ReminderCandidate
+		if err := rows.Scan(&candidate.PullRequestID, &candidate.TeamName, &candidate.CreatedAt, &candidate.Stage, &candidate.Deadline); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return candidates, nil
+}
+
+func (s *Store) SetReminderStage(ctx context.Context, prID string, stage domain.ReminderStage) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO pull_request_reminders (pull_request_id, stage)
+		VALUES ($1, $2)
+		ON CONFLICT (pull_request_id) DO UPDATE
+		SET stage = EXCLUDED.stage,
+		    updated_at = NOW()
+	`, prID, int(stage))
+	return translateError(err)
+}
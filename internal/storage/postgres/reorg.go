@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Store) MergeTeams(ctx context.Context, source, target string) (domain.Team, error) {
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		// Both rows are locked in name order so concurrent reorgs of the same
+		// teams cannot deadlock.
+		rows, err := tx.Query(ctx, `
+			SELECT name FROM teams WHERE name = ANY($1) ORDER BY name FOR UPDATE
+		`, []string{source, target})
+		if err != nil {
+			return err
+		}
+		locked, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return err
+		}
+		if len(locked) != 2 {
+			return domain.ErrTeamNotFound
+		}
+
+		if _, err := tx.Exec(ctx, `
+			WITH joined AS (
+				INSERT INTO team_members (team_name, user_id)
+				SELECT $2, user_id FROM team_members WHERE team_name = $1
+				ON CONFLICT (team_name, user_id) DO NOTHING
+				RETURNING user_id
+			)
+			INSERT INTO team_membership_history (team_name, user_id, kind, is_active)
+			SELECT $2, u.user_id, $3, u.is_active
+			FROM joined j
+			JOIN users u ON u.user_id = j.user_id
+		`, source, target, string(domain.MembershipJoined)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE users SET team_name = $2, updated_at = NOW() WHERE team_name = $1
+		`, source, target); err != nil {
+			return err
+		}
+		// Pull requests waiting for working hours are now assigned from the
+		// target team's pool.
+		if _, err := tx.Exec(ctx, `
+			UPDATE deferred_assignments SET team_name = $2 WHERE team_name = $1
+		`, source, target); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM teams WHERE name = $1`, source)
+		return err
+	})
+	if err != nil {
+		return domain.Team{}, translateError(err)
+	}
+
+	return s.GetTeam(ctx, target)
+}
+
+func (s *Store) SplitTeam(ctx context.Context, split domain.TeamSplit) (domain.Team, error) {
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		var name string
+		err := tx.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1 FOR UPDATE`, split.SourceTeam).Scan(&name)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrTeamNotFound
+			}
+			return err
+		}
+		err = tx.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1`, split.NewTeam).Scan(&name)
+		if err == nil {
+			return domain.ErrTeamExists
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		var members int
+		if err := tx.QueryRow(ctx, `
+			SELECT COUNT(*) FROM team_members WHERE team_name = $1 AND user_id = ANY($2)
+		`, split.SourceTeam, split.UserIDs).Scan(&members); err != nil {
+			return err
+		}
+		if members != len(split.UserIDs) {
+			return domain.ErrInvalidTeamSplit
+		}
+
+		if _, err := tx.Exec(ctx, `INSERT INTO teams (name) VALUES ($1)`, split.NewTeam); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO team_settings (
+				team_name, required_reviewers, allow_single_reviewer, allow_author_review,
+				remind_after_seconds, escalate_after_seconds, reassign_after_seconds, strategy,
+				max_open_reviews, time_zone, work_start_minutes, work_end_minutes, work_days,
+				review_cooldown_seconds, shadow_pool, accept_timeout_seconds, auto_approve_extension_seconds
+			)
+			SELECT $2, required_reviewers, allow_single_reviewer, allow_author_review,
+			       remind_after_seconds, escalate_after_seconds, reassign_after_seconds, strategy,
+			       max_open_reviews, time_zone, work_start_minutes, work_end_minutes, work_days,
+			       review_cooldown_seconds, shadow_pool, accept_timeout_seconds, auto_approve_extension_seconds
+			FROM team_settings
+			WHERE team_name = $1
+		`, split.SourceTeam, split.NewTeam); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM team_members WHERE team_name = $1 AND user_id = ANY($2)
+		`, split.SourceTeam, split.UserIDs); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO team_members (team_name, user_id)
+			SELECT $1, user_id FROM unnest($2::text[]) AS user_id
+		`, split.NewTeam, split.UserIDs); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE users SET team_name = $2, updated_at = NOW()
+			WHERE team_name = $1 AND user_id = ANY($3)
+		`, split.SourceTeam, split.NewTeam, split.UserIDs); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE deferred_assignments d
+			SET team_name = $2
+			FROM pull_requests p
+			JOIN users u ON u.user_id = p.author_id
+			WHERE d.pull_request_id = p.pull_request_id
+			  AND d.team_name = $1
+			  AND u.team_name = $2
+		`, split.SourceTeam, split.NewTeam); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO team_membership_history (team_name, user_id, kind, is_active)
+			SELECT t.team_name, u.user_id, t.kind, u.is_active
+			FROM users u
+			CROSS JOIN (VALUES ($1::text, $3::text), ($2::text, $4::text)) AS t (team_name, kind)
+			WHERE u.user_id = ANY($5)
+			ORDER BY t.kind = $4, u.user_id
+		`, split.SourceTeam, split.NewTeam, string(domain.MembershipLeft), string(domain.MembershipJoined), split.UserIDs)
+		return err
+	})
+	if err != nil {
+		return domain.Team{}, translateError(err)
+	}
+
+	return s.GetTeam(ctx, split.NewTeam)
+}
@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Reset truncates every table of the schema except the maintenance switch and
+// the record of applied migrations.
+// The table list is read from the catalog so that tables added by later
+// migrations are covered without changes here.
+func (s *Store) Reset(ctx context.Context) error {
+	rows, err := s.pool.Query(ctx, `
+		SELECT tablename FROM pg_tables
+		WHERE schemaname = current_schema()
+		  AND tablename NOT IN ('maintenance', 'schema_migrations')
+	`)
+	if err != nil {
+		return err
+	}
+	tables, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, 0, len(tables))
+	for _, table := range tables {
+		quoted = append(quoted, pgx.Identifier{table}.Sanitize())
+	}
+	_, err = s.pool.Exec(ctx, `TRUNCATE `+strings.Join(quoted, ", ")+` RESTART IDENTITY CASCADE`)
+	return err
+}
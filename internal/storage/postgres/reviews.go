@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RecordReview stores the time of the first review activity of the given kind
+// and reports whether it was the first activity of any kind on the PR.
+func (s *Store) RecordReview(ctx context.Context, prID string, kind domain.ReviewKind, at time.Time) (bool, error) {
+	var first bool
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO pull_request_review_times (pull_request_id)
+			VALUES ($1)
+			ON CONFLICT (pull_request_id) DO NOTHING
+		`, prID); err != nil {
+			return err
+		}
+
+		var commentAt, approvalAt *time.Time
+		if err := tx.QueryRow(ctx, `
+			SELECT first_comment_at, first_approval_at
+			FROM pull_request_review_times
+			WHERE pull_request_id = $1
+			FOR UPDATE
+		`, prID).Scan(&commentAt, &approvalAt); err != nil {
+			return err
+		}
+		first = commentAt == nil && approvalAt == nil
+
+		_, err := tx.Exec(ctx, `
+			UPDATE pull_request_review_times
+			SET first_comment_at = CASE WHEN $2 = 'COMMENT' THEN COALESCE(first_comment_at, $3) ELSE first_comment_at END,
+			    first_approval_at = CASE WHEN $2 = 'APPROVAL' THEN COALESCE(first_approval_at, $3) ELSE first_approval_at END
+			WHERE pull_request_id = $1
+		`, prID, string(kind), at)
+		return err
+	})
+	if err != nil {
+		return false, translateError(err)
+	}
+	return first, nil
+}
+
+// ListReviewLatencies returns reviewed pull requests authored by the team's
+// members since the cutoff, oldest first.
+func (s *Store) ListReviewLatencies(ctx context.Context, teamName string, since time.Time) ([]domain.ReviewLatency, error) {
+	var name string
+	if err := s.pool.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1`, teamName).Scan(&name); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrTeamNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT pr.pull_request_id, pr.created_at, LEAST(t.first_comment_at, t.first_approval_at)
+		FROM pull_request_review_times t
+		JOIN pull_requests pr ON pr.pull_request_id = t.pull_request_id
+		JOIN users u ON u.user_id = pr.author_id
+		WHERE u.team_name = $1 AND pr.created_at >= $2
+		  AND (t.first_comment_at IS NOT NULL OR t.first_approval_at IS NOT NULL)
+		ORDER BY pr.created_at, pr.pull_request_id
+	`, teamName, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var latencies []domain.ReviewLatency
+	for rows.Next() {
+		var latency domain.ReviewLatency
+		if err := rows.Scan(&latency.PullRequestID, &latency.CreatedAt, &latency.FirstReviewAt); err != nil {
+			return nil, err
+		}
+		latencies = append(latencies, latency)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return latencies, nil
+}
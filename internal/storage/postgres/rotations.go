@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SetRotation replaces the team's rotation and resets its position. An empty
+// list removes the rotation altogether.
+func (s *Store) SetRotation(ctx context.Context, rotation domain.Rotation) (domain.Rotation, error) {
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		var name string
+		err := tx.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1`, rotation.TeamName).Scan(&name)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrTeamNotFound
+			}
+			return err
+		}
+
+		if len(rotation.UserIDs) == 0 {
+			_, err := tx.Exec(ctx, `DELETE FROM team_rotations WHERE team_name = $1`, rotation.TeamName)
+			return err
+		}
+
+		var members int
+		if err := tx.QueryRow(ctx, `
+			SELECT COUNT(*)
+			FROM team_members
+			WHERE team_name = $1 AND user_id = ANY($2)
+		`, rotation.TeamName, rotation.UserIDs).Scan(&members); err != nil {
+			return err
+		}
+		if members != len(rotation.UserIDs) {
+			return domain.ErrInvalidRotation
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO team_rotations (team_name, user_ids, position)
+			VALUES ($1, $2, 0)
+			ON CONFLICT (team_name) DO UPDATE
+			SET user_ids = EXCLUDED.user_ids,
+			    position = 0,
+			    updated_at = NOW()
+		`, rotation.TeamName, rotation.UserIDs)
+		return err
+	})
+	if err != nil {
+		return domain.Rotation{}, err
+	}
+
+	rotation.Position = 0
+	return rotation, nil
+}
+
+func (s *Store) GetRotation(ctx context.Context, teamName string) (domain.Rotation, error) {
+	rotation := domain.Rotation{TeamName: teamName}
+	err := s.pool.QueryRow(ctx, `
+		SELECT user_ids, position
+		FROM team_rotations
+		WHERE team_name = $1
+	`, teamName).Scan(&rotation.UserIDs, &rotation.Position)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.Rotation{}, domain.ErrRotationNotFound
+		}
+		return domain.Rotation{}, err
+	}
+	return rotation, nil
+}
+
+// AdvanceRotationPosition compares and sets the position in one statement, so
+// that of two concurrent picks from the same position only one advances it.
+func (s *Store) AdvanceRotationPosition(ctx context.Context, teamName string, from, to int) error {
+	var advanced bool
+	err := s.pool.QueryRow(ctx, `
+		WITH advanced AS (
+			UPDATE team_rotations
+			SET position = $3,
+			    updated_at = NOW()
+			WHERE team_name = $1 AND position = $2
+			RETURNING 1
+		)
+		SELECT EXISTS (SELECT 1 FROM advanced)
+		FROM team_rotations
+		WHERE team_name = $1
+	`, teamName, from, to).Scan(&advanced)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrRotationNotFound
+		}
+		return err
+	}
+	if !advanced {
+		return domain.ErrRotationConflict
+	}
+	return nil
+}
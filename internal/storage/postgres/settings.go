@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// teamSettingsColumns are the team_settings columns read by
+// scanTeamSettings, in its order.
+const teamSettingsColumns = `
+	team_name, required_reviewers, allow_single_reviewer, allow_author_review,
+	remind_after_seconds, escalate_after_seconds, reassign_after_seconds, strategy,
+	max_open_reviews, time_zone, work_start_minutes, work_end_minutes, work_days,
+	review_cooldown_seconds, shadow_pool, avoid_previous_reviewers, COALESCE(mandatory_reviewer_id, ''),
+	accept_timeout_seconds, auto_approve_extension_seconds, block_merge_on_dependencies
+`
+
+func scanTeamSettings(row pgx.Row) (domain.TeamSettings, error) {
+	var settings domain.TeamSettings
+	var remindAfter, escalateAfter, reassignAfter, cooldown, acceptTimeout, autoApprove int64
+	var workStart, workEnd, workDays int
+	err := row.Scan(
+		&settings.TeamName, &settings.RequiredReviewers, &settings.AllowSingleReviewer, &settings.AllowAuthorReview,
+		&remindAfter, &escalateAfter, &reassignAfter, &settings.Strategy,
+		&settings.MaxOpenReviews, &settings.TimeZone, &workStart, &workEnd, &workDays,
+		&cooldown, &settings.ShadowPool, &settings.AvoidPreviousReviewers, &settings.MandatoryReviewer,
+		&acceptTimeout, &autoApprove, &settings.BlockMergeOnDependencies,
+	)
+	if err != nil {
+		return domain.TeamSettings{}, err
+	}
+	settings.RemindAfter = time.Duration(remindAfter) * time.Second
+	settings.EscalateAfter = time.Duration(escalateAfter) * time.Second
+	settings.ReassignAfter = time.Duration(reassignAfter) * time.Second
+	settings.ReviewCooldown = time.Duration(cooldown) * time.Second
+	settings.AcceptTimeout = time.Duration(acceptTimeout) * time.Second
+	settings.AutoApproveExtension = time.Duration(autoApprove) * time.Second
+	settings.WorkStart = time.Duration(workStart) * time.Minute
+	settings.WorkEnd = time.Duration(workEnd) * time.Minute
+	settings.WorkDays = workDaysFromMask(workDays)
+	return settings, nil
+}
+
+// GetTeamSettings returns the stored settings or the defaults when the team
+// never saved any.
+func (s *Store) GetTeamSettings(ctx context.Context, teamName string) (domain.TeamSettings, error) {
+	var name string
+	if err := s.pool.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1`, teamName).Scan(&name); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.TeamSettings{}, domain.ErrTeamNotFound
+		}
+		return domain.TeamSettings{}, err
+	}
+
+	settings, err := scanTeamSettings(s.pool.QueryRow(ctx, `
+		SELECT `+teamSettingsColumns+`
+		FROM team_settings
+		WHERE team_name = $1
+	`, teamName))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.DefaultTeamSettings(teamName), nil
+		}
+		return domain.TeamSettings{}, err
+	}
+	return settings, nil
+}
+
+func (s *Store) SaveTeamSettings(ctx context.Context, settings domain.TeamSettings) (domain.TeamSettings, error) {
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		var name string
+		err := tx.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1`, settings.TeamName).Scan(&name)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrTeamNotFound
+			}
+			return err
+		}
+		return saveTeamSettings(ctx, tx, settings)
+	})
+	if err != nil {
+		return domain.TeamSettings{}, err
+	}
+	return settings, nil
+}
+
+func saveTeamSettings(ctx context.Context, tx pgx.Tx, settings domain.TeamSettings) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO team_settings (
+			team_name, required_reviewers, allow_single_reviewer, allow_author_review,
+			remind_after_seconds, escalate_after_seconds, reassign_after_seconds, strategy,
+			max_open_reviews, time_zone, work_start_minutes, work_end_minutes, work_days,
+			review_cooldown_seconds, shadow_pool, avoid_previous_reviewers, mandatory_reviewer_id,
+			accept_timeout_seconds, auto_approve_extension_seconds, block_merge_on_dependencies
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, NULLIF($17, ''), $18, $19, $20)
+		ON CONFLICT (team_name) DO UPDATE
+		SET required_reviewers = EXCLUDED.required_reviewers,
+		    allow_single_reviewer = EXCLUDED.allow_single_reviewer,
+		    allow_author_review = EXCLUDED.allow_author_review,
+		    remind_after_seconds = EXCLUDED.remind_after_seconds,
+		    escalate_after_seconds = EXCLUDED.escalate_after_seconds,
+		    reassign_after_seconds = EXCLUDED.reassign_after_seconds,
+		    strategy = EXCLUDED.strategy,
+		    max_open_reviews = EXCLUDED.max_open_reviews,
+		    time_zone = EXCLUDED.time_zone,
+		    work_start_minutes = EXCLUDED.work_start_minutes,
+		    work_end_minutes = EXCLUDED.work_end_minutes,
+		    work_days = EXCLUDED.work_days,
+		    review_cooldown_seconds = EXCLUDED.review_cooldown_seconds,
+		    shadow_pool = EXCLUDED.shadow_pool,
+		    avoid_previous_reviewers = EXCLUDED.avoid_previous_reviewers,
+		    mandatory_reviewer_id = EXCLUDED.mandatory_reviewer_id,
+		    accept_timeout_seconds = EXCLUDED.accept_timeout_seconds,
+		    auto_approve_extension_seconds = EXCLUDED.auto_approve_extension_seconds,
+		    block_merge_on_dependencies = EXCLUDED.block_merge_on_dependencies,
+		    updated_at = NOW()
+	`, settings.TeamName, settings.RequiredReviewers, settings.AllowSingleReviewer, settings.AllowAuthorReview,
+		int64(settings.RemindAfter.Seconds()), int64(settings.EscalateAfter.Seconds()), int64(settings.ReassignAfter.Seconds()),
+		string(settings.Strategy), settings.MaxOpenReviews, settings.TimeZone,
+		int(settings.WorkStart.Minutes()), int(settings.WorkEnd.Minutes()), workDaysMask(settings.WorkDays),
+		int64(settings.ReviewCooldown.Seconds()), labelsParam(settings.ShadowPool), settings.AvoidPreviousReviewers,
+		settings.MandatoryReviewer, int64(settings.AcceptTimeout.Seconds()),
+		int64(settings.AutoApproveExtension.Seconds()), settings.BlockMergeOnDependencies)
+	return err
+}
+
+// workDaysMask packs weekdays into a bit set with bit n for weekday n.
+func workDaysMask(days []time.Weekday) int {
+	mask := 0
+	for _, day := range days {
+		mask |= 1 << day
+	}
+	return mask
+}
+
+func workDaysFromMask(mask int) []time.Weekday {
+	days := make([]time.Weekday, 0, 7)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if mask&(1<<day) != 0 {
+			days = append(days, day)
+		}
+	}
+	return days
+}
@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ExportSnapshot reads everything in one repeatable-read transaction, so the
+// snapshot never has a pull request whose author it lacks.
+func (s *Store) ExportSnapshot(ctx context.Context) (domain.Snapshot, error) {
+	var snapshot domain.Snapshot
+	opts := pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}
+	err := s.withTxOptions(ctx, opts, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `SELECT name FROM teams ORDER BY name`)
+		if err != nil {
+			return err
+		}
+		if snapshot.Teams, err = pgx.CollectRows(rows, pgx.RowTo[string]); err != nil {
+			return err
+		}
+
+		rows, err = tx.Query(ctx, `
+			SELECT u.user_id, u.username, u.team_name, `+userTeams+`, u.is_active, u.snoozed_until
+			FROM users u
+			ORDER BY u.user_id
+		`)
+		if err != nil {
+			return err
+		}
+		snapshot.Users, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (domain.User, error) {
+			var user domain.User
+			err := row.Scan(&user.ID, &user.Username, &user.TeamName, &user.Teams, &user.IsActive, &user.SnoozedUntil)
+			return user, err
+		})
+		if err != nil {
+			return err
+		}
+
+		rows, err = tx.Query(ctx, `SELECT `+teamSettingsColumns+` FROM team_settings ORDER BY team_name`)
+		if err != nil {
+			return err
+		}
+		snapshot.Settings, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (domain.TeamSettings, error) {
+			return scanTeamSettings(row)
+		})
+		if err != nil {
+			return err
+		}
+
+		rows, err = tx.Query(ctx, `
+			SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status, pr.created_at, pr.merged_at,
+			       pr.labels, pr.url, pr.priority, pr.reviewers_count, COALESCE(pr.shadow_reviewer_id, ''), pr.reassignments,
+			       pr.description, pr.co_author_ids, `+reviewerPlaces+`,
+			       ARRAY(SELECT f.path FROM pull_request_files f
+			             WHERE f.pull_request_id = pr.pull_request_id ORDER BY f.path)
+			FROM pull_requests pr
+			ORDER BY pr.pull_request_id
+		`)
+		if err != nil {
+			return err
+		}
+		snapshot.PullRequests, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (domain.PullRequest, error) {
+			var pr domain.PullRequest
+			var mergedAt sql.NullTime
+			var places reviewerPlaceRows
+			err := row.Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Status, &pr.CreatedAt, &mergedAt, &pr.Labels, &pr.URL,
+				&pr.Priority, &pr.ReviewersCount, &pr.ShadowReviewer, &pr.Reassignments, &pr.Description, &pr.CoAuthors,
+				&places.ids, &places.assignedAt, &places.shadowAssignedAt, &pr.Files)
+			if mergedAt.Valid {
+				pr.MergedAt = &mergedAt.Time
+			}
+			places.apply(&pr)
+			if len(pr.CoAuthors) == 0 {
+				pr.CoAuthors = nil
+			}
+			return pr, err
+		})
+		return err
+	})
+	if err != nil {
+		return domain.Snapshot{}, translateError(err)
+	}
+	return snapshot, nil
+}
+
+// ImportSnapshot checks for existing data and writes the snapshot in the
+// same transaction, so concurrent imports cannot both succeed. The teams
+// table is locked for the check: an empty table has no rows to lock.
+func (s *Store) ImportSnapshot(ctx context.Context, snapshot domain.Snapshot) error {
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `LOCK TABLE teams IN EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+		var exists bool
+		if err := tx.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM teams) OR EXISTS (SELECT 1 FROM users)
+		`).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return domain.ErrNotEmpty
+		}
+
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO teams (name) SELECT UNNEST($1::TEXT[])
+		`, snapshot.Teams); err != nil {
+			return err
+		}
+		for _, user := range snapshot.Users {
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO users (user_id, username, team_name, is_active, snoozed_until)
+				VALUES ($1, $2, $3, $4, $5)
+			`, user.ID, user.Username, user.TeamName, user.IsActive, user.SnoozedUntil); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO team_members (team_name, user_id)
+				SELECT UNNEST($1::TEXT[]), $2
+			`, user.Teams, user.ID); err != nil {
+				return err
+			}
+		}
+		for _, settings := range snapshot.Settings {
+			if err := saveTeamSettings(ctx, tx, settings); err != nil {
+				return err
+			}
+		}
+		for _, pr := range snapshot.PullRequests {
+			if err := insertPullRequest(ctx, tx, pr); err != nil {
+				return err
+			}
+			// Reassignments are only ever counted up, never set on creation.
+			if pr.Reassignments > 0 {
+				if _, err := tx.Exec(ctx, `
+					UPDATE pull_requests SET reassignments = $2 WHERE pull_request_id = $1
+				`, pr.ID, pr.Reassignments); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return translateError(err)
+}
@@ -0,0 +1,324 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Store) CountAssignments(ctx context.Context, teamName string, since time.Time) ([]domain.AssignmentCount, error) {
+	var name string
+	if err := s.pool.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1`, teamName).Scan(&name); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrTeamNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT u.user_id, u.username, u.is_active, COUNT(pr.pull_request_id)
+		FROM team_members tm
+		JOIN users u ON u.user_id = tm.user_id
+		LEFT JOIN pull_request_reviewers r ON r.reviewer_id = u.user_id
+		LEFT JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id AND pr.created_at >= $2
+		WHERE tm.team_name = $1
+		GROUP BY u.user_id, u.username, u.is_active
+		ORDER BY u.user_id
+	`, teamName, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []domain.AssignmentCount
+	for rows.Next() {
+		var count domain.AssignmentCount
+		if err := rows.Scan(&count.UserID, &count.Username, &count.IsActive, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return counts, nil
+}
+
+// CountOpenPullRequests returns how many pull requests of the author are not
+// merged yet, drafts included.
+func (s *Store) CountOpenPullRequests(ctx context.Context, authorID string) (int, error) {
+	var count int
+	err := s.pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM pull_requests WHERE author_id = $1 AND status <> $2
+	`, authorID, string(domain.StatusMerged)).Scan(&count)
+	return count, err
+}
+
+// CountOpenReviews returns how many open pull requests each of the users is
+// assigned to. Users without open reviews are omitted.
+func (s *Store) CountOpenReviews(ctx context.Context, userIDs []string) (map[string]int, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT r.reviewer_id, COUNT(*)
+		FROM pull_request_reviewers r
+		JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id
+		WHERE pr.status = $1 AND r.reviewer_id = ANY($2)
+		GROUP BY r.reviewer_id
+	`, string(domain.StatusOpen), userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var userID string
+		var count int
+		if err := rows.Scan(&userID, &count); err != nil {
+			return nil, err
+		}
+		counts[userID] = count
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return counts, nil
+}
+
+func (s *Store) CountCreatedPullRequests(ctx context.Context, teamName string, since time.Time) (map[string]int, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT pr.author_id, COUNT(*)
+		FROM pull_requests pr
+		JOIN users u ON u.user_id = pr.author_id
+		WHERE u.team_name = $1 AND pr.created_at >= $2
+		GROUP BY pr.author_id
+	`, teamName, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var authorID string
+		var count int
+		if err := rows.Scan(&authorID, &count); err != nil {
+			return nil, err
+		}
+		counts[authorID] = count
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return counts, nil
+}
+
+func (s *Store) ListRecentReviewers(ctx context.Context, authorID string, since time.Time) ([]string, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT r.reviewer_id
+		FROM pull_request_reviewers r
+		JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id
+		WHERE pr.author_id = $1 AND pr.status = $2 AND pr.merged_at >= $3
+		ORDER BY r.reviewer_id
+	`, authorID, string(domain.StatusMerged), since)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func (s *Store) ListPreviousReviewers(ctx context.Context, authorID string) ([]string, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT r.reviewer_id
+		FROM pull_request_reviewers r
+		WHERE r.pull_request_id = (
+			SELECT pull_request_id
+			FROM pull_requests
+			WHERE author_id = $1 AND status = $2 AND merged_at IS NOT NULL
+			ORDER BY merged_at DESC, pull_request_id DESC
+			LIMIT 1
+		)
+		ORDER BY r.reviewer_id
+	`, authorID, string(domain.StatusMerged))
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// ListUnderassignedPullRequests returns open pull requests with fewer reviewers
+// than requested at creation or, failing that, than the author's team
+// requires, oldest first. An empty teamName matches all
+// teams.
+func (s *Store) ListUnderassignedPullRequests(ctx context.Context, teamName string) ([]domain.UnderassignedPullRequest, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT pr.pull_request_id, u.team_name, COUNT(r.reviewer_id),
+		       COALESCE(NULLIF(pr.reviewers_count, 0), ts.required_reviewers, $3), pr.created_at
+		FROM pull_requests pr
+		JOIN users u ON u.user_id = pr.author_id
+		LEFT JOIN team_settings ts ON ts.team_name = u.team_name
+		LEFT JOIN pull_request_reviewers r ON r.pull_request_id = pr.pull_request_id
+		WHERE pr.status = $1 AND ($2 = '' OR u.team_name = $2)
+		GROUP BY pr.pull_request_id, u.team_name, ts.required_reviewers, pr.reviewers_count, pr.created_at
+		HAVING COUNT(r.reviewer_id) < COALESCE(NULLIF(pr.reviewers_count, 0), ts.required_reviewers, $3)
+		ORDER BY pr.created_at, pr.pull_request_id
+	`, string(domain.StatusOpen), teamName, domain.DefaultRequiredReviewers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []domain.UnderassignedPullRequest
+	for rows.Next() {
+		var pr domain.UnderassignedPullRequest
+		if err := rows.Scan(&pr.PullRequestID, &pr.TeamName, &pr.Assigned, &pr.Required, &pr.CreatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, pr)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return result, nil
+}
+
+// ListHotPullRequests returns open pull requests reassigned at least
+// minReassignments times, most reassigned first. An empty teamName matches all
+// teams.
+func (s *Store) ListHotPullRequests(ctx context.Context, teamName string, minReassignments int) ([]domain.HotPullRequest, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, u.team_name, pr.reassignments,
+		       COALESCE(array_agg(r.reviewer_id ORDER BY r.reviewer_id) FILTER (WHERE r.reviewer_id IS NOT NULL), '{}'),
+		       pr.created_at
+		FROM pull_requests pr
+		JOIN users u ON u.user_id = pr.author_id
+		LEFT JOIN pull_request_reviewers r ON r.pull_request_id = pr.pull_request_id
+		WHERE pr.status = $1 AND pr.reassignments >= $3 AND ($2 = '' OR u.team_name = $2)
+		GROUP BY pr.pull_request_id, u.team_name
+		ORDER BY pr.reassignments DESC, pr.created_at, pr.pull_request_id
+	`, string(domain.StatusOpen), teamName, minReassignments)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []domain.HotPullRequest
+	for rows.Next() {
+		var pr domain.HotPullRequest
+		if err := rows.Scan(&pr.PullRequestID, &pr.Name, &pr.AuthorID, &pr.TeamName, &pr.Reassignments, &pr.AssignedReviewers, &pr.CreatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, pr)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return result, nil
+}
+
+// TeamStats reads the team's figures and its weekly merges in one batch.
+// Weeks are truncated in UTC so that they match domain.WeekStart.
+func (s *Store) TeamStats(ctx context.Context, teamName string, since time.Time) (domain.TeamStats, error) {
+	batch := &pgx.Batch{}
+	batch.Queue(`
+		WITH team_prs AS (
+			SELECT pr.pull_request_id, pr.status, pr.created_at, pr.reassignments
+			FROM pull_requests pr
+			JOIN users u ON u.user_id = pr.author_id
+			WHERE u.team_name = $1
+		), recent AS (
+			SELECT p.reassignments,
+			       (SELECT COUNT(*) FROM pull_request_reviewers r WHERE r.pull_request_id = p.pull_request_id) AS reviewers
+			FROM team_prs p
+			WHERE p.created_at >= $2 AND p.status <> $4
+		)
+		SELECT EXISTS (SELECT 1 FROM teams WHERE name = $1),
+		       (SELECT COUNT(*) FROM team_prs WHERE status = $3),
+		       (SELECT COUNT(*) FROM recent),
+		       COALESCE((SELECT AVG(reviewers) FROM recent), 0)::float8,
+		       (SELECT COUNT(*) FROM recent WHERE reassignments > 0),
+		       COALESCE(busiest.reviewer_id, ''),
+		       COALESCE(busiest.assignments, 0)
+		FROM (SELECT 1) AS one
+		LEFT JOIN LATERAL (
+			SELECT r.reviewer_id, COUNT(*) AS assignments
+			FROM pull_request_reviewers r
+			JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id
+			JOIN team_members tm ON tm.user_id = r.reviewer_id
+			WHERE tm.team_name = $1 AND pr.created_at >= $2
+			GROUP BY r.reviewer_id
+			ORDER BY assignments DESC, r.reviewer_id
+			LIMIT 1
+		) busiest ON TRUE
+	`, teamName, since, string(domain.StatusOpen), string(domain.StatusDraft))
+	batch.Queue(`
+		SELECT date_trunc('week', pr.merged_at AT TIME ZONE 'UTC') AS week, COUNT(*)
+		FROM pull_requests pr
+		JOIN users u ON u.user_id = pr.author_id
+		WHERE u.team_name = $1 AND pr.status = $3 AND pr.merged_at >= $2
+		GROUP BY week
+		ORDER BY week
+	`, teamName, since, string(domain.StatusMerged))
+	results := s.pool.SendBatch(ctx, batch)
+	defer results.Close()
+
+	stats := domain.TeamStats{TeamName: teamName, Since: since}
+	var exists bool
+	if err := results.QueryRow().Scan(&exists, &stats.OpenPullRequests, &stats.PullRequests, &stats.AvgReviewers,
+		&stats.Reassigned, &stats.BusiestReviewer, &stats.BusiestReviewerAssignments); err != nil {
+		return domain.TeamStats{}, queryError(ctx, batchSQL(batch), err)
+	}
+	if !exists {
+		return domain.TeamStats{}, domain.ErrTeamNotFound
+	}
+
+	rows, err := results.Query()
+	if err != nil {
+		return domain.TeamStats{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var week domain.WeekCount
+		if err := rows.Scan(&week.Start, &week.Count); err != nil {
+			return domain.TeamStats{}, err
+		}
+		week.Start = week.Start.UTC()
+		stats.MergesPerWeek = append(stats.MergesPerWeek, week)
+	}
+	if rows.Err() != nil {
+		return domain.TeamStats{}, rows.Err()
+	}
+	return stats, nil
+}
+
+func (s *Store) AssignmentBuckets(ctx context.Context, teamName string, since time.Time) ([]domain.AssignmentBucket, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT date_trunc('day', pr.created_at) AS bucket, COUNT(*)
+		FROM pull_request_reviewers r
+		JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id
+		JOIN team_members tm ON tm.user_id = r.reviewer_id
+		WHERE tm.team_name = $1 AND pr.created_at >= $2
+		GROUP BY bucket
+		ORDER BY bucket
+	`, teamName, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []domain.AssignmentBucket
+	for rows.Next() {
+		var bucket domain.AssignmentBucket
+		if err := rows.Scan(&bucket.Start, &bucket.Count); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return buckets, nil
+}
@@ -5,33 +5,90 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"io/fs"
-	"sort"
+	"log"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"Avito2025/internal/config"
 	"Avito2025/internal/domain"
+	"Avito2025/internal/requestid"
 	"Avito2025/internal/storage"
-	"Avito2025/internal/storage/postgres/migrations"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var _ storage.Repository = (*Store)(nil)
+var (
+	_ storage.Repository        = (*Store)(nil)
+	_ storage.StatsProvider     = (*Store)(nil)
+	_ storage.MigrationProvider = (*Store)(nil)
+	_ storage.Transactor        = (*Store)(nil)
+)
+
+const rollbackTimeout = 2 * time.Second
+
+// userTeams selects the sorted team memberships of the user aliased as u.
+const userTeams = `ARRAY(SELECT m.team_name FROM team_members m WHERE m.user_id = u.user_id ORDER BY m.team_name)`
+
+// requiredReviewers selects the reviewers the pull request aliased as pr was
+// created with or, failing that, the ones required by the team settings
+// aliased as ts.
+var requiredReviewers = `COALESCE(NULLIF(pr.reviewers_count, 0), ts.required_reviewers, ` + strconv.Itoa(domain.DefaultRequiredReviewers) + `)`
+
+// reviewerPlaces selects the reviewers of the pull request aliased as pr in
+// the order of their places, their assignment times in the same order and
+// the assignment time of the shadow reviewer, for a reviewerPlaceRows.
+var reviewerPlaces = reviewerPlacesFrom("pull_request_reviewers")
+
+// reviewerPlacesFrom is reviewerPlaces reading the reviewers from table.
+func reviewerPlacesFrom(table string) string {
+	return `ARRAY(SELECT r.reviewer_id FROM ` + table + ` r
+		             WHERE r.pull_request_id = pr.pull_request_id ORDER BY r.position, r.reviewer_id),
+		       ARRAY(SELECT r.assigned_at FROM ` + table + ` r
+		             WHERE r.pull_request_id = pr.pull_request_id ORDER BY r.position, r.reviewer_id),
+		       COALESCE(pr.shadow_assigned_at, pr.created_at)`
+}
+
+// reviewerPlaceRows holds the columns selected by reviewerPlaces.
+type reviewerPlaceRows struct {
+	ids              []string
+	assignedAt       []time.Time
+	shadowAssignedAt time.Time
+}
+
+// apply sets the assigned and the ordered reviewers of pr, whose shadow
+// reviewer must already be scanned.
+func (p reviewerPlaceRows) apply(pr *domain.PullRequest) {
+	required := make([]domain.Reviewer, len(p.ids))
+	for i, id := range p.ids {
+		required[i] = domain.Reviewer{UserID: id, AssignedAt: p.assignedAt[i]}
+	}
+	pr.Reviewers = domain.RankReviewers(required, domain.Reviewer{UserID: pr.ShadowReviewer, AssignedAt: p.shadowAssignedAt})
+	pr.AssignedReviewers = nil
+	if len(p.ids) > 0 {
+		pr.AssignedReviewers = slices.Sorted(slices.Values(p.ids))
+	}
+}
 
 type Store struct {
-	pool *pgxpool.Pool
+	pool *timedPool
 }
 
 func New(ctx context.Context, cfg config.PostgresConfig) (*Store, error) {
-	poolCfg, err := pgxpool.ParseConfig(cfg.DSN())
+	poolCfg, err := poolConfig(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("parse postgres dsn: %w", err)
+		return nil, err
 	}
-	if cfg.MaxConns > 0 {
-		poolCfg.MaxConns = cfg.MaxConns
+	// Migrations may legitimately run long, so they use a connection of their
+	// own without the statement timeout.
+	migrationCfg := poolCfg.ConnConfig.Copy()
+	delete(migrationCfg.RuntimeParams, "statement_timeout")
+
+	if _, err := applyMigrations(ctx, migrationCfg, cfg.MigratePhase); err != nil {
+		return nil, err
 	}
 
 	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
@@ -39,44 +96,53 @@ func New(ctx context.Context, cfg config.PostgresConfig) (*Store, error) {
 		return nil, fmt.Errorf("connect postgres: %w", err)
 	}
 
-	store := &Store{pool: pool}
-	if err := store.applyMigrations(ctx); err != nil {
-		pool.Close()
-		return nil, err
-	}
+	return &Store{pool: &timedPool{Pool: pool, timeout: cfg.QueryTimeout}}, nil
+}
 
-	return store, nil
+// poolConfig parses the DSN of cfg and applies its pool tuning and statement
+// timeout. Zero values keep the pgxpool and server defaults.
+func poolConfig(cfg config.PostgresConfig) (*pgxpool.Config, error) {
+	poolCfg, err := pgxpool.ParseConfig(cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("parse postgres dsn: %w", err)
+	}
+	if cfg.MaxConns > 0 {
+		poolCfg.MaxConns = cfg.MaxConns
+	}
+	if cfg.MinConns > 0 {
+		poolCfg.MinConns = cfg.MinConns
+	}
+	if cfg.MaxConnLifetime > 0 {
+		poolCfg.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		poolCfg.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
+	if cfg.HealthCheckPeriod > 0 {
+		poolCfg.HealthCheckPeriod = cfg.HealthCheckPeriod
+	}
+	if cfg.StatementTimeout > 0 {
+		poolCfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+	return poolCfg, nil
 }
 
 func (s *Store) Close() {
 	s.pool.Close()
 }
 
-func (s *Store) applyMigrations(ctx context.Context) error {
-	entries, err := migrations.Files.ReadDir(".")
-	if err != nil {
-		return fmt.Errorf("read migrations: %w", err)
+func (s *Store) PoolStats() storage.PoolStats {
+	stat := s.pool.Stat()
+	return storage.PoolStats{
+		AcquiredConns:        stat.AcquiredConns(),
+		IdleConns:            stat.IdleConns(),
+		TotalConns:           stat.TotalConns(),
+		MaxConns:             stat.MaxConns(),
+		AcquireCount:         stat.AcquireCount(),
+		AcquireDuration:      stat.AcquireDuration(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
 	}
-
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].Name() < entries[j].Name()
-	})
-
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
-			continue
-		}
-
-		sqlBytes, err := fs.ReadFile(migrations.Files, entry.Name())
-		if err != nil {
-			return fmt.Errorf("read migration %s: %w", entry.Name(), err)
-		}
-
-		if _, err := s.pool.Exec(ctx, string(sqlBytes)); err != nil {
-			return fmt.Errorf("apply migration %s: %w", entry.Name(), err)
-		}
-	}
-	return nil
 }
 
 func (s *Store) CreateTeam(ctx context.Context, team domain.Team) (domain.Team, error) {
@@ -95,17 +161,24 @@ func (s *Store) CreateTeam(ctx context.Context, team domain.Team) (domain.Team,
 		}
 
 		for _, member := range team.Members {
+			// Existing users keep their primary team and join this one too.
 			if _, err := tx.Exec(ctx, `
 				INSERT INTO users (user_id, username, team_name, is_active)
 				VALUES ($1, $2, $3, $4)
 				ON CONFLICT (user_id) DO UPDATE
 				SET username = EXCLUDED.username,
-				    team_name = EXCLUDED.team_name,
 				    is_active = EXCLUDED.is_active,
 				    updated_at = NOW()
 			`, member.ID, member.Username, team.Name, member.IsActive); err != nil {
 				return err
 			}
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO team_members (team_name, user_id)
+				VALUES ($1, $2)
+				ON CONFLICT (team_name, user_id) DO NOTHING
+			`, team.Name, member.ID); err != nil {
+				return err
+			}
 		}
 
 		return nil
@@ -117,51 +190,86 @@ func (s *Store) CreateTeam(ctx context.Context, team domain.Team) (domain.Team,
 	return s.GetTeam(ctx, team.Name)
 }
 
+// GetTeam reads the team and its members in one query. A team without
+// members yields a single row with the member columns NULL.
 func (s *Store) GetTeam(ctx context.Context, name string) (domain.Team, error) {
-	var teamName string
-	err := s.pool.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1`, name).Scan(&teamName)
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return domain.Team{}, domain.ErrTeamNotFound
-		}
-		return domain.Team{}, err
-	}
-
 	rows, err := s.pool.Query(ctx, `
-		SELECT user_id, username, is_active
-		FROM users
-		WHERE team_name = $1
-		ORDER BY user_id`, name)
+		SELECT u.user_id, u.username, u.is_active, u.snoozed_until, `+userTeams+`
+		FROM teams t
+		LEFT JOIN team_members tm ON tm.team_name = t.name
+		LEFT JOIN users u ON u.user_id = tm.user_id
+		WHERE t.name = $1
+		ORDER BY u.user_id`, name)
 	if err != nil {
 		return domain.Team{}, err
 	}
 	defer rows.Close()
 
+	found := false
 	var members []domain.User
 	for rows.Next() {
-		var u domain.User
-		u.TeamName = name
-		if err := rows.Scan(&u.ID, &u.Username, &u.IsActive); err != nil {
+		found = true
+		var id, username sql.NullString
+		var isActive sql.NullBool
+		u := domain.User{TeamName: name}
+		if err := rows.Scan(&id, &username, &isActive, &u.SnoozedUntil, &u.Teams); err != nil {
 			return domain.Team{}, err
 		}
+		if !id.Valid {
+			continue
+		}
+		u.ID, u.Username, u.IsActive = id.String, username.String, isActive.Bool
 		members = append(members, u)
 	}
 	if rows.Err() != nil {
 		return domain.Team{}, rows.Err()
 	}
+	if !found {
+		return domain.Team{}, domain.ErrTeamNotFound
+	}
 
 	return domain.Team{
-		Name:    teamName,
+		Name:    name,
 		Members: members,
 	}, nil
 }
 
+func (s *Store) ListTeams(ctx context.Context, prefix string, offset, limit int) ([]domain.TeamSummary, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT t.name,
+		       (SELECT COUNT(*) FROM team_members tm WHERE tm.team_name = t.name),
+		       (SELECT COUNT(*) FROM team_members tm JOIN users u ON u.user_id = tm.user_id
+		        WHERE tm.team_name = t.name AND u.is_active),
+		       (SELECT COUNT(*) FROM pull_requests pr JOIN users u ON u.user_id = pr.author_id
+		        WHERE u.team_name = t.name AND pr.status = $2)
+		FROM teams t
+		WHERE starts_with(t.name, $1)
+		ORDER BY t.name
+		OFFSET $3
+		LIMIT $4
+	`, prefix, string(domain.StatusOpen), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teams []domain.TeamSummary
+	for rows.Next() {
+		var team domain.TeamSummary
+		if err := rows.Scan(&team.Name, &team.Members, &team.ActiveMembers, &team.OpenPullRequests); err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	return teams, rows.Err()
+}
+
 func (s *Store) GetUser(ctx context.Context, userID string) (domain.User, error) {
 	var user domain.User
 	err := s.pool.QueryRow(ctx, `
-		SELECT user_id, username, team_name, is_active
-		FROM users
-		WHERE user_id = $1`, userID).Scan(&user.ID, &user.Username, &user.TeamName, &user.IsActive)
+		SELECT u.user_id, u.username, u.team_name, u.is_active, u.snoozed_until, `+userTeams+`
+		FROM users u
+		WHERE u.user_id = $1`, userID).Scan(&user.ID, &user.Username, &user.TeamName, &user.IsActive, &user.SnoozedUntil, &user.Teams)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return domain.User{}, domain.ErrUserNotFound
@@ -171,15 +279,130 @@ func (s *Store) GetUser(ctx context.Context, userID string) (domain.User, error)
 	return user, nil
 }
 
+func (s *Store) ListUsers(ctx context.Context, filter domain.UserFilter, offset, limit int) ([]domain.UserSummary, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT u.user_id, u.username, u.team_name, u.is_active, u.snoozed_until, `+userTeams+`,
+		       (SELECT COUNT(*) FROM pull_request_reviewers r
+		        JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id
+		        WHERE r.reviewer_id = u.user_id AND pr.status = $4)
+		FROM users u
+		WHERE ($1 = '' OR EXISTS (SELECT 1 FROM team_members m WHERE m.user_id = u.user_id AND m.team_name = $1))
+		  AND ($2::boolean IS NULL OR u.is_active = $2)
+		  AND starts_with(u.username, $3)
+		ORDER BY u.user_id
+		OFFSET $5
+		LIMIT $6
+	`, filter.TeamName, filter.IsActive, filter.UsernamePrefix, string(domain.StatusOpen), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []domain.UserSummary
+	for rows.Next() {
+		var user domain.UserSummary
+		if err := rows.Scan(&user.ID, &user.Username, &user.TeamName, &user.IsActive, &user.SnoozedUntil, &user.Teams, &user.OpenReviews); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (s *Store) EnsureUser(ctx context.Context, user domain.User) (domain.User, bool, error) {
+	var created bool
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		var name string
+		if err := tx.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1`, user.TeamName).Scan(&name); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrTeamNotFound
+			}
+			return err
+		}
+
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO users (user_id, username, team_name, is_active)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id) DO NOTHING
+		`, user.ID, user.Username, name, user.IsActive)
+		if err != nil {
+			return err
+		}
+		created = tag.RowsAffected() == 1
+		if !created {
+			return nil
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO team_members (team_name, user_id)
+			VALUES ($1, $2)
+			ON CONFLICT (team_name, user_id) DO NOTHING
+		`, name, user.ID)
+		return err
+	})
+	if err != nil {
+		return domain.User{}, false, translateError(err)
+	}
+
+	stored, err := s.GetUser(ctx, user.ID)
+	return stored, created, err
+}
+
 func (s *Store) SetUserActive(ctx context.Context, userID string, isActive bool) (domain.User, error) {
 	var user domain.User
 	err := s.pool.QueryRow(ctx, `
-		UPDATE users
+		UPDATE users u
 		SET is_active = $2,
 		    updated_at = NOW()
-		WHERE user_id = $1
-		RETURNING user_id, username, team_name, is_active
-	`, userID, isActive).Scan(&user.ID, &user.Username, &user.TeamName, &user.IsActive)
+		WHERE u.user_id = $1
+		RETURNING u.user_id, u.username, u.team_name, u.is_active, u.snoozed_until, `+userTeams+`
+	`, userID, isActive).Scan(&user.ID, &user.Username, &user.TeamName, &user.IsActive, &user.SnoozedUntil, &user.Teams)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.User{}, domain.ErrUserNotFound
+		}
+		return domain.User{}, err
+	}
+	return user, nil
+}
+
+func (s *Store) SetUsersActive(ctx context.Context, userIDs []string, isActive bool) ([]domain.UserResult, error) {
+	results := make([]domain.UserResult, len(userIDs))
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		for i, userID := range userIDs {
+			results[i] = domain.UserResult{UserID: userID}
+			user := &results[i].User
+			err := tx.QueryRow(ctx, `
+				UPDATE users u
+				SET is_active = $2,
+				    updated_at = NOW()
+				WHERE u.user_id = $1
+				RETURNING u.user_id, u.username, u.team_name, u.is_active, u.snoozed_until, `+userTeams+`
+			`, userID, isActive).Scan(&user.ID, &user.Username, &user.TeamName, &user.IsActive, &user.SnoozedUntil, &user.Teams)
+			if errors.Is(err, pgx.ErrNoRows) {
+				results[i].Err = domain.ErrUserNotFound
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return results, nil
+}
+
+func (s *Store) SnoozeUser(ctx context.Context, userID string, until *time.Time) (domain.User, error) {
+	var user domain.User
+	err := s.pool.QueryRow(ctx, `
+		UPDATE users u
+		SET snoozed_until = $2,
+		    updated_at = NOW()
+		WHERE u.user_id = $1
+		RETURNING u.user_id, u.username, u.team_name, u.is_active, u.snoozed_until, `+userTeams+`
+	`, userID, until).Scan(&user.ID, &user.Username, &user.TeamName, &user.IsActive, &user.SnoozedUntil, &user.Teams)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return domain.User{}, domain.ErrUserNotFound
@@ -189,6 +412,59 @@ func (s *Store) SetUserActive(ctx context.Context, userID string, isActive bool)
 	return user, nil
 }
 
+func (s *Store) UpdateUser(ctx context.Context, userID string, update domain.UserUpdate) (domain.User, error) {
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		var primary string
+		err := tx.QueryRow(ctx, `SELECT team_name FROM users WHERE user_id = $1 FOR UPDATE`, userID).Scan(&primary)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrUserNotFound
+			}
+			return err
+		}
+
+		if update.Username != nil {
+			if _, err := tx.Exec(ctx, `
+				UPDATE users SET username = $2, updated_at = NOW() WHERE user_id = $1
+			`, userID, *update.Username); err != nil {
+				return err
+			}
+		}
+
+		if update.TeamName == nil || *update.TeamName == primary {
+			return nil
+		}
+		var name string
+		if err := tx.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1`, *update.TeamName).Scan(&name); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrTeamNotFound
+			}
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM team_members WHERE team_name = $1 AND user_id = $2
+		`, primary, userID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO team_members (team_name, user_id)
+			VALUES ($1, $2)
+			ON CONFLICT (team_name, user_id) DO NOTHING
+		`, name, userID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE users SET team_name = $2, updated_at = NOW() WHERE user_id = $1
+		`, userID, name)
+		return err
+	})
+	if err != nil {
+		return domain.User{}, translateError(err)
+	}
+
+	return s.GetUser(ctx, userID)
+}
+
 func (s *Store) ListUsersByTeam(ctx context.Context, teamName string) ([]domain.User, error) {
 	var name string
 	if err := s.pool.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1`, teamName).Scan(&name); err != nil {
@@ -199,9 +475,10 @@ func (s *Store) ListUsersByTeam(ctx context.Context, teamName string) ([]domain.
 	}
 
 	rows, err := s.pool.Query(ctx, `
-		SELECT user_id, username, team_name, is_active
-		FROM users
-		WHERE team_name = $1`, teamName)
+		SELECT u.user_id, u.username, u.team_name, u.is_active, u.snoozed_until, `+userTeams+`
+		FROM team_members tm
+		JOIN users u ON u.user_id = tm.user_id
+		WHERE tm.team_name = $1`, teamName)
 	if err != nil {
 		return nil, err
 	}
@@ -210,7 +487,7 @@ func (s *Store) ListUsersByTeam(ctx context.Context, teamName string) ([]domain.
 	var users []domain.User
 	for rows.Next() {
 		var user domain.User
-		if err := rows.Scan(&user.ID, &user.Username, &user.TeamName, &user.IsActive); err != nil {
+		if err := rows.Scan(&user.ID, &user.Username, &user.TeamName, &user.IsActive, &user.SnoozedUntil, &user.Teams); err != nil {
 			return nil, err
 		}
 		users = append(users, user)
@@ -223,120 +500,505 @@ func (s *Store) ListUsersByTeam(ctx context.Context, teamName string) ([]domain.
 
 func (s *Store) CreatePullRequest(ctx context.Context, pr domain.PullRequest) (domain.PullRequest, error) {
 	err := s.withTx(ctx, func(tx pgx.Tx) error {
-		_, err := tx.Exec(ctx, `
-			INSERT INTO pull_requests (pull_request_id, pull_request_name, author_id, status, created_at, merged_at)
-			VALUES ($1, $2, $3, $4, $5, $6)
-		`, pr.ID, pr.Name, pr.AuthorID, string(pr.Status), pr.CreatedAt, pr.MergedAt)
-		if err != nil {
+		return insertPullRequest(ctx, tx, pr)
+	})
+	if err != nil {
+		return domain.PullRequest{}, translateError(err)
+	}
+
+	return s.GetPullRequest(ctx, pr.ID)
+}
+
+// insertPullRequest places the reviewers in the order of pr.Reviewers or,
+// when unset, of pr.AssignedReviewers.
+func insertPullRequest(ctx context.Context, tx pgx.Tx, pr domain.PullRequest) error {
+	reviewers := pr.OrderedReviewers()
+	var shadowAssignedAt *time.Time
+	for _, reviewer := range reviewers {
+		if reviewer.Role == domain.RoleShadow {
+			shadowAssignedAt = &reviewer.AssignedAt
+		}
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO pull_requests (pull_request_id, pull_request_name, author_id, status, created_at, merged_at, labels, url, priority, reviewers_count, shadow_reviewer_id, description, shadow_assigned_at, co_author_ids)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12, $13, $14)
+	`, pr.ID, pr.Name, pr.AuthorID, string(pr.Status), pr.CreatedAt, pr.MergedAt, labelsParam(pr.Labels), pr.URL, string(pr.Priority), pr.ReviewersCount, pr.ShadowReviewer, pr.Description, shadowAssignedAt, labelsParam(pr.CoAuthors))
+	if err != nil {
+		return err
+	}
+
+	for position, reviewer := range reviewers {
+		if reviewer.Role == domain.RoleShadow {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO pull_request_reviewers (pull_request_id, reviewer_id, position, assigned_at)
+			VALUES ($1, $2, $3, $4)
+		`, pr.ID, reviewer.UserID, position, reviewer.AssignedAt); err != nil {
 			return err
 		}
+	}
+	if len(pr.Files) > 0 {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO pull_request_files (pull_request_id, path)
+			SELECT $1, path FROM UNNEST($2::TEXT[]) AS path
+			ON CONFLICT DO NOTHING
+		`, pr.ID, pr.Files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		for _, reviewer := range pr.AssignedReviewers {
-			if _, err := tx.Exec(ctx, `
-				INSERT INTO pull_request_reviewers (pull_request_id, reviewer_id)
-				VALUES ($1, $2)
-			`, pr.ID, reviewer); err != nil {
+// labelsParam keeps an empty label set, or any other empty TEXT[] value,
+// from being sent as NULL.
+func labelsParam(labels []string) []string {
+	if labels == nil {
+		return []string{}
+	}
+	return labels
+}
+
+func (s *Store) UpdatePullRequest(ctx context.Context, pr domain.PullRequest) (domain.PullRequest, error) {
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		return updatePullRequest(ctx, tx, pr)
+	})
+	if err != nil {
+		return domain.PullRequest{}, translateError(err)
+	}
+
+	return s.GetPullRequest(ctx, pr.ID)
+}
+
+// CompareAndUpdatePullRequest locks the pull request row while it compares
+// the status and reviewers with seen and stores pr, so that a change made in
+// between by a reassignment, a fill or a merge is not overwritten.
+func (s *Store) CompareAndUpdatePullRequest(ctx context.Context, seen, pr domain.PullRequest) (domain.PullRequest, bool, error) {
+	var updated bool
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		updated = false
+		var (
+			status    string
+			reviewers []string
+		)
+		err := tx.QueryRow(ctx, `
+			SELECT status,
+			       ARRAY(SELECT reviewer_id FROM pull_request_reviewers WHERE pull_request_id = $1)
+			FROM pull_requests
+			WHERE pull_request_id = $1
+			FOR UPDATE
+		`, pr.ID).Scan(&status, &reviewers)
+		if errors.Is(err, pgx.ErrNoRows) {
+			var archived bool
+			if err := tx.QueryRow(ctx, `
+				SELECT EXISTS (SELECT 1 FROM pull_requests_archive WHERE pull_request_id = $1)
+			`, pr.ID).Scan(&archived); err != nil {
 				return err
 			}
+			if archived {
+				return domain.ErrPRMerged
+			}
+			return domain.ErrPullRequestNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if status != string(seen.Status) || !sameReviewers(reviewers, seen.AssignedReviewers) {
+			return nil
 		}
+		if err := updatePullRequest(ctx, tx, pr); err != nil {
+			return err
+		}
+		updated = true
 		return nil
 	})
 	if err != nil {
-		return domain.PullRequest{}, translateError(err)
+		return domain.PullRequest{}, false, translateError(err)
 	}
 
-	return s.GetPullRequest(ctx, pr.ID)
+	result, err := s.GetPullRequest(ctx, pr.ID)
+	if err != nil {
+		return domain.PullRequest{}, false, err
+	}
+	return result, updated, nil
 }
 
-func (s *Store) UpdatePullRequest(ctx context.Context, pr domain.PullRequest) (domain.PullRequest, error) {
+// updatePullRequest writes pr over the pull request row and its reviewers.
+func updatePullRequest(ctx context.Context, tx pgx.Tx, pr domain.PullRequest) error {
+	commandTag, err := tx.Exec(ctx, `
+		UPDATE pull_requests
+		SET pull_request_name = $2,
+		    author_id = $3,
+		    status = $4,
+		    created_at = $5,
+		    merged_at = $6,
+		    labels = $7,
+		    url = $8,
+		    priority = $9,
+		    shadow_assigned_at = CASE
+		        WHEN shadow_reviewer_id IS NOT DISTINCT FROM NULLIF($10, '') THEN shadow_assigned_at
+		        WHEN $10 <> '' THEN NOW()
+		    END,
+		    shadow_reviewer_id = NULLIF($10, ''),
+		    description = $11
+		WHERE pull_request_id = $1
+	`, pr.ID, pr.Name, pr.AuthorID, string(pr.Status), pr.CreatedAt, pr.MergedAt, labelsParam(pr.Labels), pr.URL, string(pr.Priority), pr.ShadowReviewer, pr.Description)
+	if err != nil {
+		return err
+	}
+	if commandTag.RowsAffected() == 0 {
+		return domain.ErrPullRequestNotFound
+	}
+
+	// Only the reviewers that changed are touched: the review completions
+	// of a reviewer go with its row via ON DELETE CASCADE, and the kept
+	// reviewers keep their places while new ones are placed after them.
+	kept := pr.AssignedReviewers
+	if kept == nil {
+		kept = []string{}
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM pull_request_reviewers
+		WHERE pull_request_id = $1 AND reviewer_id <> ALL($2)
+	`, pr.ID, kept); err != nil {
+		return err
+	}
+	for _, reviewer := range pr.AssignedReviewers {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO pull_request_reviewers (pull_request_id, reviewer_id, position)
+			SELECT $1, $2, COALESCE(MAX(position) + 1, 0)
+			FROM pull_request_reviewers
+			WHERE pull_request_id = $1
+			ON CONFLICT (pull_request_id, reviewer_id) DO NOTHING
+		`, pr.ID, reviewer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameReviewers reports whether both lists hold the same reviewers, in any
+// order.
+func sameReviewers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, reviewer := range a {
+		if !slices.Contains(b, reviewer) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReassignReviewer locks the pull request row for the whole check-and-swap, so
+// concurrent reassignments of the same pull request run one after the other
+// and each checks the reviewers the previous one left.
+func (s *Store) ReassignReviewer(ctx context.Context, prID, oldReviewerID, newReviewerID string) (domain.PullRequest, error) {
 	err := s.withTx(ctx, func(tx pgx.Tx) error {
-		commandTag, err := tx.Exec(ctx, `
-			UPDATE pull_requests
-			SET pull_request_name = $2,
-			    author_id = $3,
-			    status = $4,
-			    created_at = $5,
-			    merged_at = $6
+		var status string
+		err := tx.QueryRow(ctx, `
+			SELECT status
+			FROM pull_requests
 			WHERE pull_request_id = $1
-		`, pr.ID, pr.Name, pr.AuthorID, string(pr.Status), pr.CreatedAt, pr.MergedAt)
+			FOR UPDATE
+		`, prID).Scan(&status)
+		if errors.Is(err, pgx.ErrNoRows) {
+			var archived bool
+			if err := tx.QueryRow(ctx, `
+				SELECT EXISTS (SELECT 1 FROM pull_requests_archive WHERE pull_request_id = $1)
+			`, prID).Scan(&archived); err != nil {
+				return err
+			}
+			if archived {
+				return domain.ErrPRMerged
+			}
+			return domain.ErrPullRequestNotFound
+		}
 		if err != nil {
 			return err
 		}
-		if commandTag.RowsAffected() == 0 {
+		if status == string(domain.StatusMerged) {
+			return domain.ErrPRMerged
+		}
+
+		var oldAssigned, newAssigned bool
+		var newActive sql.NullBool
+		if err := tx.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM pull_request_reviewers WHERE pull_request_id = $1 AND reviewer_id = $2),
+			       EXISTS (SELECT 1 FROM pull_request_reviewers WHERE pull_request_id = $1 AND reviewer_id = $3),
+			       (SELECT is_active FROM users WHERE user_id = $3)
+		`, prID, oldReviewerID, newReviewerID).Scan(&oldAssigned, &newAssigned, &newActive); err != nil {
+			return err
+		}
+		switch {
+		case !oldAssigned:
+			return domain.ErrReviewerNotFound
+		case !newActive.Valid:
+			return domain.ErrUserNotFound
+		case newAssigned || !newActive.Bool:
+			return domain.ErrReassignConflict
+		}
+
+		// Deleting the row rather than renaming it drops the completion of
+		// the old reviewer with it. The new reviewer takes the old one's place.
+		var position int
+		err = tx.QueryRow(ctx, `
+			DELETE FROM pull_request_reviewers
+			WHERE pull_request_id = $1 AND reviewer_id = $2
+			RETURNING position
+		`, prID, oldReviewerID).Scan(&position)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO pull_request_reviewers (pull_request_id, reviewer_id, position)
+			VALUES ($1, $2, $3)
+		`, prID, newReviewerID, position)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE pull_requests
+			SET reassignments = reassignments + 1
+			WHERE pull_request_id = $1
+		`, prID)
+		return err
+	})
+	if err != nil {
+		return domain.PullRequest{}, translateError(err)
+	}
+
+	return s.GetPullRequest(ctx, prID)
+}
+
+// AddReviewers locks the pull request row while it counts and adds the
+// reviewers, so that a reassignment or another fill running at the same time
+// is neither undone nor exceeded.
+func (s *Store) AddReviewers(ctx context.Context, prID string, reviewerIDs []string, limit int) (domain.PullRequest, []string, error) {
+	var added []string
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		added = nil
+		var status string
+		err := tx.QueryRow(ctx, `
+			SELECT status
+			FROM pull_requests
+			WHERE pull_request_id = $1
+			FOR UPDATE
+		`, prID).Scan(&status)
+		if errors.Is(err, pgx.ErrNoRows) {
+			var archived bool
+			if err := tx.QueryRow(ctx, `
+				SELECT EXISTS (SELECT 1 FROM pull_requests_archive WHERE pull_request_id = $1)
+			`, prID).Scan(&archived); err != nil {
+				return err
+			}
+			if archived {
+				return domain.ErrPRMerged
+			}
 			return domain.ErrPullRequestNotFound
 		}
+		if err != nil {
+			return err
+		}
+		if status != string(domain.StatusOpen) {
+			return nil
+		}
 
-		if _, err := tx.Exec(ctx, `DELETE FROM pull_request_reviewers WHERE pull_request_id = $1`, pr.ID); err != nil {
+		var assigned int
+		if err := tx.QueryRow(ctx, `
+			SELECT COUNT(*) FROM pull_request_reviewers WHERE pull_request_id = $1
+		`, prID).Scan(&assigned); err != nil {
 			return err
 		}
-		for _, reviewer := range pr.AssignedReviewers {
-			if _, err := tx.Exec(ctx, `
-				INSERT INTO pull_request_reviewers (pull_request_id, reviewer_id)
-				VALUES ($1, $2)
-			`, pr.ID, reviewer); err != nil {
+		for _, reviewerID := range reviewerIDs {
+			if assigned >= limit {
+				break
+			}
+			tag, err := tx.Exec(ctx, `
+				INSERT INTO pull_request_reviewers (pull_request_id, reviewer_id, position)
+				SELECT $1, u.user_id, (SELECT COALESCE(MAX(position) + 1, 0) FROM pull_request_reviewers WHERE pull_request_id = $1)
+				FROM users u
+				WHERE u.user_id = $2 AND u.is_active
+				ON CONFLICT (pull_request_id, reviewer_id) DO NOTHING
+			`, prID, reviewerID)
+			if err != nil {
 				return err
 			}
+			if tag.RowsAffected() == 1 {
+				assigned++
+				added = append(added, reviewerID)
+			}
 		}
 		return nil
 	})
 	if err != nil {
-		return domain.PullRequest{}, translateError(err)
+		return domain.PullRequest{}, nil, translateError(err)
 	}
 
-	return s.GetPullRequest(ctx, pr.ID)
+	pr, err := s.GetPullRequest(ctx, prID)
+	if err != nil {
+		return domain.PullRequest{}, nil, err
+	}
+	return pr, added, nil
 }
 
+// MergePullRequest moves the pull request to MERGED in a single conditional
+// UPDATE that returns the merged pull request, so concurrent merges cannot
+// interleave a read with the write. A pull request the UPDATE does not match
+// is read as it is and reported as not transitioned.
+func (s *Store) MergePullRequest(ctx context.Context, id string, mergedAt time.Time, force bool) (domain.PullRequest, bool, error) {
+	mergeable := []string{string(domain.StatusOpen)}
+	if force {
+		mergeable = append(mergeable, string(domain.StatusDraft))
+	}
+	batch := &pgx.Batch{}
+	batch.Queue(`
+		UPDATE pull_requests pr
+		SET status = $2,
+		    merged_at = $3
+		FROM pull_requests cur
+		LEFT JOIN users u ON u.user_id = cur.author_id
+		LEFT JOIN team_settings ts ON ts.team_name = u.team_name
+		WHERE pr.pull_request_id = $1 AND cur.pull_request_id = pr.pull_request_id AND pr.status = ANY($4)
+		RETURNING `+pullRequestColumns+`
+	`, id, string(domain.StatusMerged), mergedAt, mergeable)
+	batch.Queue(reviewAcceptancesQuery, id)
+
+	pr, err := s.readPullRequest(ctx, batch)
+	if errors.Is(err, pgx.ErrNoRows) {
+		pr, err = s.GetPullRequest(ctx, id)
+		return pr, false, err
+	}
+	if err != nil {
+		return domain.PullRequest{}, false, err
+	}
+	return pr, true, nil
+}
+
+// pullRequestColumns selects a pull request aliased as pr, with its author
+// joined as u and the team settings as ts, for readPullRequest.
+var pullRequestColumns = `pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status, pr.created_at, pr.merged_at,
+		       pr.labels, pr.url, pr.priority, pr.reviewers_count, COALESCE(pr.shadow_reviewer_id, ''), pr.reassignments,
+		       pr.description, pr.co_author_ids, ` + requiredReviewers + `, ` + extendedDeadline + `,
+		       ` + reviewerPlaces + `,
+		       ARRAY(SELECT f.path FROM pull_request_files f
+		             WHERE f.pull_request_id = pr.pull_request_id ORDER BY f.path),
+		       ARRAY(SELECT c.reviewer_id FROM review_completions c
+		             JOIN pull_request_reviewers r
+		               ON r.pull_request_id = c.pull_request_id AND r.reviewer_id = c.reviewer_id
+		             WHERE c.pull_request_id = pr.pull_request_id ORDER BY c.reviewer_id),
+		       ARRAY(SELECT l.blocked_by FROM pr_links l
+		             JOIN pull_requests b ON b.pull_request_id = l.blocked_by
+		             WHERE l.pull_request_id = pr.pull_request_id AND b.status <> 'MERGED' ORDER BY l.blocked_by)`
+
+// GetPullRequest reads the pull request with its reviewers and files in one
+// query and the acceptance states of its reviewers in a second one, sent in
+// the same batch so that a call costs a single round trip.
 func (s *Store) GetPullRequest(ctx context.Context, id string) (domain.PullRequest, error) {
+	batch := &pgx.Batch{}
+	batch.Queue(`
+		SELECT `+pullRequestColumns+`
+		FROM pull_requests pr
+		LEFT JOIN users u ON u.user_id = pr.author_id
+		LEFT JOIN team_settings ts ON ts.team_name = u.team_name
+		WHERE pr.pull_request_id = $1
+	`, id)
+	batch.Queue(reviewAcceptancesQuery, id)
+
+	pr, err := s.readPullRequest(ctx, batch)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s.getArchivedPullRequest(ctx, id)
+	}
+	return pr, err
+}
+
+// readPullRequest sends a batch of a query selecting pullRequestColumns and
+// reviewAcceptancesQuery and reads the pull request they return. It fails
+// with pgx.ErrNoRows when the first query matches nothing.
+func (s *Store) readPullRequest(ctx context.Context, batch *pgx.Batch) (domain.PullRequest, error) {
+	results := s.pool.SendBatch(ctx, batch)
+	defer results.Close()
+
 	var pr domain.PullRequest
 	var mergedAt sql.NullTime
-	err := s.pool.QueryRow(ctx, `
-		SELECT pull_request_id, pull_request_name, author_id, status, created_at, merged_at
-		FROM pull_requests
-		WHERE pull_request_id = $1
-	`, id).Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Status, &pr.CreatedAt, &mergedAt)
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return domain.PullRequest{}, domain.ErrPullRequestNotFound
-		}
+	var required int
+	var places reviewerPlaceRows
+	err := results.QueryRow().Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Status, &pr.CreatedAt, &mergedAt, &pr.Labels, &pr.URL,
+		&pr.Priority, &pr.ReviewersCount, &pr.ShadowReviewer, &pr.Reassignments, &pr.Description, &pr.CoAuthors, &required, &pr.Deadline,
+		&places.ids, &places.assignedAt, &places.shadowAssignedAt, &pr.Files, &pr.CompletedReviewers, &pr.BlockedBy)
+	if errors.Is(err, pgx.ErrNoRows) {
 		return domain.PullRequest{}, err
 	}
+	if err != nil {
+		return domain.PullRequest{}, queryError(ctx, batchSQL(batch), err)
+	}
 	if mergedAt.Valid {
 		pr.MergedAt = &mergedAt.Time
 	}
-
-	rows, err := s.pool.Query(ctx, `
-		SELECT reviewer_id
-		FROM pull_request_reviewers
-		WHERE pull_request_id = $1
-		ORDER BY reviewer_id
-	`, id)
-	if err != nil {
-		return domain.PullRequest{}, err
+	places.apply(&pr)
+	if len(pr.CoAuthors) == 0 {
+		pr.CoAuthors = nil
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var reviewer string
-		if err := rows.Scan(&reviewer); err != nil {
-			return domain.PullRequest{}, err
-		}
-		pr.AssignedReviewers = append(pr.AssignedReviewers, reviewer)
+	if len(pr.CompletedReviewers) == 0 {
+		pr.CompletedReviewers = nil
 	}
-	if rows.Err() != nil {
-		return domain.PullRequest{}, rows.Err()
+	if len(pr.BlockedBy) == 0 {
+		pr.BlockedBy = nil
 	}
+	pr.NeedsMoreReviewers = pr.Status == domain.StatusOpen && len(pr.AssignedReviewers) < required
 
+	rows, err := results.Query()
+	if err != nil {
+		return domain.PullRequest{}, queryError(ctx, batchSQL(batch), err)
+	}
+	acceptances, err := scanAcceptances(rows)
+	if err != nil {
+		return domain.PullRequest{}, queryError(ctx, batchSQL(batch), err)
+	}
+	pr.Acceptance = acceptanceStates(acceptances)
 	return pr, nil
 }
 
-func (s *Store) ListPullRequestsByReviewer(ctx context.Context, userID string) ([]domain.PullRequest, error) {
-	rows, err := s.pool.Query(ctx, `
-		SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status, pr.created_at, pr.merged_at
+func (s *Store) ListPullRequestsByReviewer(ctx context.Context, userID string, filter domain.ReviewFilter) ([]domain.PullRequest, error) {
+	sortColumn := "pr.created_at"
+	if filter.SortBy == domain.SortByMergedAt {
+		sortColumn = "pr.merged_at"
+	}
+	direction := "ASC"
+	if filter.Descending {
+		direction = "DESC"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status, pr.created_at, pr.merged_at,
+		       pr.labels, pr.url, pr.priority, pr.reviewers_count, COALESCE(pr.shadow_reviewer_id, ''), pr.reassignments,
+		       n.needs_more
 		FROM pull_requests pr
 		JOIN pull_request_reviewers r ON r.pull_request_id = pr.pull_request_id
+		LEFT JOIN users u ON u.user_id = pr.author_id
+		LEFT JOIN team_settings ts ON ts.team_name = u.team_name
+		CROSS JOIN LATERAL (
+		    SELECT pr.status = $3 AND (
+		        SELECT COUNT(*) FROM pull_request_reviewers x WHERE x.pull_request_id = pr.pull_request_id
+		    ) < `+requiredReviewers+` AS needs_more
+		) n
 		WHERE r.reviewer_id = $1
-		ORDER BY pr.created_at DESC
-	`, userID)
+		  AND ($2 = '' OR pr.status = $2)
+		  AND (NOT $4 OR n.needs_more)
+		  AND (NOT $5 OR NOT EXISTS (
+		      SELECT 1 FROM review_completions c
+		      WHERE c.pull_request_id = pr.pull_request_id AND c.reviewer_id = $1
+		  ))
+		  AND ($6::boolean IS NULL OR EXISTS (
+		      SELECT 1 FROM pr_links l
+		      JOIN pull_requests b ON b.pull_request_id = l.blocked_by
+		      WHERE l.pull_request_id = pr.pull_request_id AND b.status <> 'MERGED'
+		  ) = $6)
+		ORDER BY %s %s NULLS LAST, pr.pull_request_id
+	`, sortColumn, direction)
+
+	rows, err := s.pool.Query(ctx, query, userID, string(filter.Status), string(domain.StatusOpen), filter.NeedsMoreReviewers, filter.ExcludeCompleted, filter.Blocked)
 	if err != nil {
 		return nil, err
 	}
@@ -346,7 +1008,7 @@ func (s *Store) ListPullRequestsByReviewer(ctx context.Context, userID string) (
 	for rows.Next() {
 		var pr domain.PullRequest
 		var mergedAt sql.NullTime
-		if err := rows.Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Status, &pr.CreatedAt, &mergedAt); err != nil {
+		if err := rows.Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Status, &pr.CreatedAt, &mergedAt, &pr.Labels, &pr.URL, &pr.Priority, &pr.ReviewersCount, &pr.ShadowReviewer, &pr.Reassignments, &pr.NeedsMoreReviewers); err != nil {
 			return nil, err
 		}
 		if mergedAt.Valid {
@@ -364,12 +1026,42 @@ func (s *Store) Health(ctx context.Context) error {
 	return s.pool.Ping(ctx)
 }
 
+// txKey is the context key of the transaction Atomically runs in.
+type txKey struct{}
+
+// Atomically runs fn in a transaction that every statement of the store
+// issued with fn's context joins: the pool hands them to the transaction, and
+// the store's own transactions become savepoints in it. Within the
+// transaction of an outer call, Atomically runs fn in a savepoint as well.
+func (s *Store) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.withTx(ctx, func(tx pgx.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// ambientTx returns the transaction of Atomically that ctx runs in, or nil.
+func ambientTx(ctx context.Context) pgx.Tx {
+	tx, _ := ctx.Value(txKey{}).(pgx.Tx)
+	return tx
+}
+
 func (s *Store) withTx(ctx context.Context, fn func(pgx.Tx) error) error {
-	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	return s.withTxOptions(ctx, pgx.TxOptions{}, fn)
+}
+
+func (s *Store) withTxOptions(ctx context.Context, opts pgx.TxOptions, fn func(pgx.Tx) error) error {
+	tx, err := s.pool.BeginTx(ctx, opts)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(ctx) //nolint:errcheck
+	// Roll back on a context detached from cancellation: when the request
+	// deadline fires mid-transaction the rollback must still reach the server
+	// instead of leaving the connection to be torn down.
+	defer func() {
+		rbCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+		defer cancel()
+		_ = tx.Rollback(rbCtx)
+	}()
 
 	if err := fn(tx); err != nil {
 		return err
@@ -379,6 +1071,9 @@ func (s *Store) withTx(ctx context.Context, fn func(pgx.Tx) error) error {
 }
 
 func translateError(err error) error {
+	if serverTimeout(err) {
+		return domain.ErrStorageTimeout
+	}
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) {
 		if pgErr.Code == "23505" {
@@ -387,8 +1082,224 @@ func translateError(err error) error {
 				return domain.ErrTeamExists
 			case pgErr.ConstraintName == "pull_requests_pkey":
 				return domain.ErrPRExists
+			case pgErr.ConstraintName == "user_identities_pkey":
+				return domain.ErrIdentityExists
 			}
 		}
 	}
 	return err
 }
+
+// timedPool bounds every query sent through the pool by a timeout, including
+// the wait for a free connection, so that an exhausted pool or a slow query
+// fails fast. Result sets, single rows and batch results keep the deadline
+// until they are closed or scanned. Statements inside transactions are bounded by the
+// server-side statement_timeout, including the ones the pool passes on to the
+// transaction of Atomically.
+type timedPool struct {
+	*pgxpool.Pool
+	timeout time.Duration
+}
+
+func (p *timedPool) bound(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.timeout)
+}
+
+func (p *timedPool) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if tx := ambientTx(ctx); tx != nil {
+		return tx.Exec(ctx, sql, args...)
+	}
+	queryCtx, cancel := p.bound(ctx)
+	defer cancel()
+	tag, err := p.Pool.Exec(queryCtx, sql, args...)
+	return tag, queryError(ctx, sql, err)
+}
+
+func (p *timedPool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if tx := ambientTx(ctx); tx != nil {
+		return tx.Query(ctx, sql, args...)
+	}
+	queryCtx, cancel := p.bound(ctx)
+	rows, err := p.Pool.Query(queryCtx, sql, args...)
+	if err != nil {
+		cancel()
+		return nil, queryError(ctx, sql, err)
+	}
+	return &timedRows{Rows: rows, ctx: ctx, cancel: cancel, sql: sql}, nil
+}
+
+func (p *timedPool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	if tx := ambientTx(ctx); tx != nil {
+		return tx.QueryRow(ctx, sql, args...)
+	}
+	queryCtx, cancel := p.bound(ctx)
+	return &timedRow{row: p.Pool.QueryRow(queryCtx, sql, args...), ctx: ctx, cancel: cancel, sql: sql}
+}
+
+func (p *timedPool) SendBatch(ctx context.Context, batch *pgx.Batch) pgx.BatchResults {
+	if tx := ambientTx(ctx); tx != nil {
+		return tx.SendBatch(ctx, batch)
+	}
+	queryCtx, cancel := p.bound(ctx)
+	return &timedBatch{BatchResults: p.Pool.SendBatch(queryCtx, batch), ctx: ctx, cancel: cancel, sql: batchSQL(batch)}
+}
+
+// BeginTx starts a transaction or, within the transaction of Atomically, a
+// savepoint in it, which keeps the isolation level of the transaction.
+func (p *timedPool) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	if tx := ambientTx(ctx); tx != nil {
+		savepoint, err := tx.Begin(ctx)
+		if err != nil {
+			return nil, queryError(ctx, "SAVEPOINT", err)
+		}
+		return &loggedTx{Tx: savepoint}, nil
+	}
+	queryCtx, cancel := p.bound(ctx)
+	defer cancel()
+	tx, err := p.Pool.BeginTx(queryCtx, opts)
+	if err != nil {
+		return nil, queryError(ctx, "BEGIN", err)
+	}
+	return &loggedTx{Tx: tx}, nil
+}
+
+type timedRows struct {
+	pgx.Rows
+	ctx    context.Context
+	cancel context.CancelFunc
+	sql    string
+}
+
+func (r *timedRows) Close() {
+	r.Rows.Close()
+	r.cancel()
+}
+
+func (r *timedRows) Err() error {
+	return queryError(r.ctx, r.sql, r.Rows.Err())
+}
+
+type timedRow struct {
+	row    pgx.Row
+	ctx    context.Context
+	cancel context.CancelFunc
+	sql    string
+}
+
+func (r *timedRow) Scan(dest ...any) error {
+	defer r.cancel()
+	return queryError(r.ctx, r.sql, r.row.Scan(dest...))
+}
+
+type timedBatch struct {
+	pgx.BatchResults
+	ctx    context.Context
+	cancel context.CancelFunc
+	sql    string
+}
+
+func (b *timedBatch) Close() error {
+	defer b.cancel()
+	return queryError(b.ctx, b.sql, b.BatchResults.Close())
+}
+
+// loggedTx reports failed statements of a transaction like timedPool does.
+// They are bounded by the server-side statement_timeout rather than a client
+// deadline.
+type loggedTx struct {
+	pgx.Tx
+}
+
+func (tx *loggedTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tag, err := tx.Tx.Exec(ctx, sql, args...)
+	return tag, queryError(ctx, sql, err)
+}
+
+func (tx *loggedTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	rows, err := tx.Tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, queryError(ctx, sql, err)
+	}
+	return &timedRows{Rows: rows, ctx: ctx, cancel: func() {}, sql: sql}, nil
+}
+
+func (tx *loggedTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return &timedRow{row: tx.Tx.QueryRow(ctx, sql, args...), ctx: ctx, cancel: func() {}, sql: sql}
+}
+
+func (tx *loggedTx) SendBatch(ctx context.Context, batch *pgx.Batch) pgx.BatchResults {
+	return &timedBatch{BatchResults: tx.Tx.SendBatch(ctx, batch), ctx: ctx, cancel: func() {}, sql: batchSQL(batch)}
+}
+
+func (tx *loggedTx) Commit(ctx context.Context) error {
+	return queryError(ctx, "COMMIT", tx.Tx.Commit(ctx))
+}
+
+// queryError turns a failed statement into the error returned by the store.
+// Timeouts become ErrStorageTimeout. Unexpected failures are logged with the
+// statement and the ID of the request that issued it, and the ID is added to
+// the error so that a 500 can be traced back to the SQL call. Missing rows,
+// constraint violations and the caller's own cancellation are expected
+// outcomes and pass through unlogged.
+func queryError(ctx context.Context, sql string, err error) error {
+	err = timeoutError(ctx, err)
+	if err == nil || ctx.Err() != nil || errors.Is(err, pgx.ErrNoRows) || constraintViolation(err) {
+		return err
+	}
+
+	id := requestid.From(ctx)
+	if id != "" {
+		log.Printf("postgres statement failed: request_id=%s sql=%q: %v", id, compactSQL(sql), err)
+	} else {
+		log.Printf("postgres statement failed: sql=%q: %v", compactSQL(sql), err)
+	}
+
+	// Domain errors keep their identity for the callers comparing them.
+	var domainErr *domain.Error
+	if id == "" || errors.As(err, &domainErr) {
+		return err
+	}
+	return fmt.Errorf("request %s: %w", id, err)
+}
+
+// constraintViolation reports integrity constraint violations (class 23),
+// which the store translates into domain conflicts.
+func constraintViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "23")
+}
+
+func batchSQL(batch *pgx.Batch) string {
+	statements := make([]string, 0, len(batch.QueuedQueries))
+	for _, query := range batch.QueuedQueries {
+		statements = append(statements, query.SQL)
+	}
+	return strings.Join(statements, "; ")
+}
+
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
+
+// timeoutError reports server-side timeouts and the expiry of the query
+// deadline as ErrStorageTimeout. When the caller's own context has expired the
+// error is returned as is, so the caller sees its deadline.
+func timeoutError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) || serverTimeout(err) {
+		return domain.ErrStorageTimeout
+	}
+	return err
+}
+
+// serverTimeout reports query_canceled, raised by statement_timeout, and
+// lock_not_available, raised by lock_timeout.
+func serverTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "57014" || pgErr.Code == "55P03")
+}
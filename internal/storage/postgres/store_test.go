@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"Avito2025/internal/config"
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestPoolConfigAppliesTuning(t *testing.T) {
+	base := config.PostgresConfig{Host: "localhost", Port: "5432", User: "reviewer", Password: "p@ss", DBName: "reviewer", SSLMode: "disable"}
+	defaults, err := pgxpool.ParseConfig(base.DSN())
+	if err != nil {
+		t.Fatalf("parse dsn: %v", err)
+	}
+
+	// Zero values keep the pgxpool defaults.
+	got, err := poolConfig(base)
+	if err != nil {
+		t.Fatalf("poolConfig: %v", err)
+	}
+	if got.MaxConns != defaults.MaxConns || got.MinConns != defaults.MinConns ||
+		got.MaxConnLifetime != defaults.MaxConnLifetime || got.MaxConnIdleTime != defaults.MaxConnIdleTime ||
+		got.HealthCheckPeriod != defaults.HealthCheckPeriod {
+		t.Errorf("expected the pgxpool defaults, got %v", got)
+	}
+	if got.ConnConfig.Password != "p@ss" {
+		t.Errorf("expected the password from the DSN, got %q", got.ConnConfig.Password)
+	}
+
+	tuned := base
+	tuned.MaxConns = 8
+	tuned.MinConns = 2
+	tuned.MaxConnLifetime = time.Hour
+	tuned.MaxConnIdleTime = 30 * time.Minute
+	tuned.HealthCheckPeriod = 15 * time.Second
+	got, err = poolConfig(tuned)
+	if err != nil {
+		t.Fatalf("poolConfig: %v", err)
+	}
+	if got.MaxConns != 8 || got.MinConns != 2 || got.MaxConnLifetime != time.Hour ||
+		got.MaxConnIdleTime != 30*time.Minute || got.HealthCheckPeriod != 15*time.Second {
+		t.Errorf("expected the configured tuning, got %v", got)
+	}
+}
+
+func TestPoolConfigSetsStatementTimeout(t *testing.T) {
+	cfg := config.PostgresConfig{Host: "localhost", Port: "5432", DBName: "reviewer", SSLMode: "disable"}
+	got, err := poolConfig(cfg)
+	if err != nil {
+		t.Fatalf("poolConfig: %v", err)
+	}
+	if timeout, ok := got.ConnConfig.RuntimeParams["statement_timeout"]; ok {
+		t.Errorf("expected the server default without a timeout, got %q", timeout)
+	}
+
+	cfg.StatementTimeout = 1500 * time.Millisecond
+	if got, err = poolConfig(cfg); err != nil {
+		t.Fatalf("poolConfig: %v", err)
+	}
+	if timeout := got.ConnConfig.RuntimeParams["statement_timeout"]; timeout != "1500" {
+		t.Errorf("expected statement_timeout 1500, got %q", timeout)
+	}
+}
+
+func TestTimeoutErrorTranslation(t *testing.T) {
+	live := context.Background()
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := &pgconn.PgError{Code: "57014"}
+	other := errors.New("connection reset")
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want error
+	}{
+		{name: "query deadline", ctx: live, err: fmt.Errorf("query: %w", context.DeadlineExceeded), want: domain.ErrStorageTimeout},
+		{name: "statement timeout", ctx: live, err: &pgconn.PgError{Code: "57014"}, want: domain.ErrStorageTimeout},
+		{name: "lock timeout", ctx: live, err: &pgconn.PgError{Code: "55P03"}, want: domain.ErrStorageTimeout},
+		{name: "caller gave up", ctx: expired, err: context.Canceled, want: context.Canceled},
+		{name: "caller gone before the server gave up", ctx: expired, err: canceled, want: canceled},
+		{name: "other failure", ctx: live, err: other, want: other},
+		{name: "no error", ctx: live},
+	}
+	for _, tt := range tests {
+		got := timeoutError(tt.ctx, tt.err)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	// Statement timeouts surfacing outside timedPool are translated too.
+	if got := translateError(&pgconn.PgError{Code: "57014"}); got != domain.ErrStorageTimeout {
+		t.Errorf("expected ErrStorageTimeout, got %v", got)
+	}
+}
+
+func TestTimedPoolBoundsQueries(t *testing.T) {
+	unbounded, cancel := (&timedPool{}).bound(context.Background())
+	defer cancel()
+	if _, ok := unbounded.Deadline(); ok {
+		t.Error("expected no deadline without a query timeout")
+	}
+
+	start := time.Now()
+	bounded, cancel := (&timedPool{timeout: time.Second}).bound(context.Background())
+	defer cancel()
+	deadline, ok := bounded.Deadline()
+	if !ok || deadline.Before(start.Add(time.Second)) || deadline.After(time.Now().Add(time.Second)) {
+		t.Errorf("expected a deadline a second away, got %v (%v)", deadline, ok)
+	}
+}
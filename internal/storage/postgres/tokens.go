@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const tokenColumns = `id, team_name, COALESCE(user_id, ''), name, hash, created_at`
+
+func scanToken(row pgx.Row) (domain.TeamToken, error) {
+	var token domain.TeamToken
+	err := row.Scan(&token.ID, &token.TeamName, &token.UserID, &token.Name, &token.Hash, &token.CreatedAt)
+	return token, err
+}
+
+func (s *Store) CreateTeamToken(ctx context.Context, token domain.TeamToken) (domain.TeamToken, error) {
+	created, err := scanToken(s.pool.QueryRow(ctx, `
+		INSERT INTO team_tokens (team_name, user_id, name, hash)
+		SELECT name, NULLIF($4, ''), $2, $3
+		FROM teams
+		WHERE name = $1
+		RETURNING `+tokenColumns,
+		token.TeamName, token.Name, token.Hash, token.UserID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.TeamToken{}, domain.ErrTeamNotFound
+	}
+	if err != nil {
+		return domain.TeamToken{}, translateError(err)
+	}
+	created.Token = token.Token
+	return created, nil
+}
+
+func (s *Store) GetTeamTokenByHash(ctx context.Context, hash string) (domain.TeamToken, error) {
+	token, err := scanToken(s.pool.QueryRow(ctx, `
+		SELECT `+tokenColumns+`
+		FROM team_tokens
+		WHERE hash = $1
+	`, hash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.TeamToken{}, domain.ErrTeamTokenNotFound
+	}
+	return token, translateError(err)
+}
+
+func (s *Store) ListTeamTokens(ctx context.Context, teamName string) ([]domain.TeamToken, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+tokenColumns+`
+		FROM team_tokens
+		WHERE $1 = '' OR team_name = $1
+		ORDER BY id
+	`, teamName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []domain.TeamToken
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *Store) DeleteTeamToken(ctx context.Context, id int64) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM team_tokens WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrTeamTokenNotFound
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Avito2025/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Store) CreateWebhook(ctx context.Context, webhook domain.Webhook) (domain.Webhook, error) {
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO webhooks (url, secret, events)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, webhook.URL, webhook.Secret, webhookEventsParam(webhook.Events)).Scan(&webhook.ID, &webhook.CreatedAt)
+	if err != nil {
+		return domain.Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (s *Store) GetWebhook(ctx context.Context, id int64) (domain.Webhook, error) {
+	var webhook domain.Webhook
+	var events []string
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, url, secret, events, created_at
+		FROM webhooks
+		WHERE id = $1
+	`, id).Scan(&webhook.ID, &webhook.URL, &webhook.Secret, &events, &webhook.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.Webhook{}, domain.ErrWebhookNotFound
+		}
+		return domain.Webhook{}, err
+	}
+	webhook.Events = webhookEvents(events)
+	return webhook, nil
+}
+
+func (s *Store) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, url, secret, events, created_at
+		FROM webhooks
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []domain.Webhook
+	for rows.Next() {
+		var webhook domain.Webhook
+		var events []string
+		if err := rows.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, &events, &webhook.CreatedAt); err != nil {
+			return nil, err
+		}
+		webhook.Events = webhookEvents(events)
+		webhooks = append(webhooks, webhook)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return webhooks, nil
+}
+
+func (s *Store) UpdateWebhook(ctx context.Context, webhook domain.Webhook) (domain.Webhook, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE webhooks
+		SET url = $2,
+		    secret = $3,
+		    events = $4
+		WHERE id = $1
+	`, webhook.ID, webhook.URL, webhook.Secret, webhookEventsParam(webhook.Events))
+	if err != nil {
+		return domain.Webhook{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.Webhook{}, domain.ErrWebhookNotFound
+	}
+	return s.GetWebhook(ctx, webhook.ID)
+}
+
+// DeleteWebhook removes the webhook together with its delivery log.
+func (s *Store) DeleteWebhook(ctx context.Context, id int64) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (s *Store) AppendWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_seq, event, attempt, status_code, error, delivered)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, delivery.WebhookID, delivery.EventSeq, string(delivery.Event), delivery.Attempt,
+		delivery.StatusCode, delivery.Error, delivery.Delivered)
+	return err
+}
+
+// ClaimWebhookDispatch creates the dispatch row when a reset has removed it,
+// with the cursor at the start of the restarted change feed.
+func (s *Store) ClaimWebhookDispatch(ctx context.Context, owner string, now, until time.Time) (int64, bool, error) {
+	var seq int64
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO webhook_dispatch (last_seq, owner, lease_until)
+		VALUES (0, $1, $3)
+		ON CONFLICT (id) DO UPDATE
+		SET owner = EXCLUDED.owner, lease_until = EXCLUDED.lease_until
+		WHERE webhook_dispatch.owner = EXCLUDED.owner OR webhook_dispatch.lease_until <= $2
+		RETURNING last_seq
+	`, owner, now, until).Scan(&seq)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return seq, true, nil
+}
+
+func (s *Store) AdvanceWebhookDispatch(ctx context.Context, owner string, seq int64) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE webhook_dispatch SET last_seq = GREATEST(last_seq, $2) WHERE owner = $1
+	`, owner, seq)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListWebhookDeliveries returns the webhook's most recent delivery attempts,
+// newest first.
+func (s *Store) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]domain.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, webhook_id, event_seq, event, attempt, status_code, error, delivered, created_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []domain.WebhookDelivery
+	for rows.Next() {
+		var delivery domain.WebhookDelivery
+		if err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventSeq, &delivery.Event, &delivery.Attempt,
+			&delivery.StatusCode, &delivery.Error, &delivery.Delivered, &delivery.CreatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return deliveries, nil
+}
+
+func webhookEventsParam(events []domain.WebhookEvent) []string {
+	result := make([]string, 0, len(events))
+	for _, event := range events {
+		result = append(result, string(event))
+	}
+	return result
+}
+
+func webhookEvents(events []string) []domain.WebhookEvent {
+	result := make([]domain.WebhookEvent, 0, len(events))
+	for _, event := range events {
+		result = append(result, domain.WebhookEvent(event))
+	}
+	return result
+}
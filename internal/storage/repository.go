@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"Avito2025/internal/domain"
 )
@@ -9,14 +10,304 @@ import (
 type Repository interface {
 	CreateTeam(ctx context.Context, team domain.Team) (domain.Team, error)
 	GetTeam(ctx context.Context, name string) (domain.Team, error)
+	// ListTeams returns teams whose name starts with prefix, ordered by name,
+	// skipping offset and returning at most limit.
+	ListTeams(ctx context.Context, prefix string, offset, limit int) ([]domain.TeamSummary, error)
 	GetUser(ctx context.Context, userID string) (domain.User, error)
+	// ListUsers returns the users matching filter, ordered by ID, skipping
+	// offset and returning at most limit.
+	ListUsers(ctx context.Context, filter domain.UserFilter, offset, limit int) ([]domain.UserSummary, error)
+	// EnsureUser adds the user as a member of their TeamName unless a user
+	// with the ID exists, and reports whether it did. An existing user is
+	// returned unchanged.
+	EnsureUser(ctx context.Context, user domain.User) (domain.User, bool, error)
 	SetUserActive(ctx context.Context, userID string, isActive bool) (domain.User, error)
+	// SetUsersActive sets the status of several users in one transaction.
+	// Unknown users fail their own result with ErrUserNotFound.
+	SetUsersActive(ctx context.Context, userIDs []string, isActive bool) ([]domain.UserResult, error)
+	// SnoozeUser sets or, with a nil until, clears the user's snooze.
+	SnoozeUser(ctx context.Context, userID string, until *time.Time) (domain.User, error)
+	// UpdateUser applies a partial edit; a team transfer replaces the user's
+	// primary team membership with the new team.
+	UpdateUser(ctx context.Context, userID string, update domain.UserUpdate) (domain.User, error)
 	ListUsersByTeam(ctx context.Context, teamName string) ([]domain.User, error)
+	// MergeTeams moves every member of source into target and deletes source;
+	// the target's settings are kept. The joins are recorded in the target's
+	// membership history within the same transaction.
+	MergeTeams(ctx context.Context, source, target string) (domain.Team, error)
+	// SplitTeam creates split.NewTeam and moves the selected members of the
+	// source team into it, recording the moves in both teams' histories. It
+	// returns the new team.
+	SplitTeam(ctx context.Context, split domain.TeamSplit) (domain.Team, error)
+	AppendMembershipChanges(ctx context.Context, changes []domain.MembershipChange) error
+	// ListMembershipHistory returns the team's membership changes, oldest
+	// first.
+	ListMembershipHistory(ctx context.Context, teamName string) ([]domain.MembershipChange, error)
+
+	GetTeamSettings(ctx context.Context, teamName string) (domain.TeamSettings, error)
+	SaveTeamSettings(ctx context.Context, settings domain.TeamSettings) (domain.TeamSettings, error)
+
+	SetRotation(ctx context.Context, rotation domain.Rotation) (domain.Rotation, error)
+	GetRotation(ctx context.Context, teamName string) (domain.Rotation, error)
+	// AdvanceRotationPosition moves the team's rotation from position from
+	// to position to. It fails with ErrRotationConflict when the position is
+	// no longer from, i.e. a concurrent pick advanced it first.
+	AdvanceRotationPosition(ctx context.Context, teamName string, from, to int) error
+
+	// SetOwnership replaces the team's ownership rules; no rules removes
+	// them. GetOwnership returns no rules for a team without any.
+	SetOwnership(ctx context.Context, ownership domain.Ownership) (domain.Ownership, error)
+	GetOwnership(ctx context.Context, teamName string) (domain.Ownership, error)
+
+	// SetTeamLead grants or revokes the lead role of a team member.
+	// ListTeamLeads returns the team's leads ordered by ID.
+	SetTeamLead(ctx context.Context, teamName, userID string, isLead bool) error
+	ListTeamLeads(ctx context.Context, teamName string) ([]string, error)
+
+	// SetNotificationPreference stores how the user wants to be notified.
+	// Switching the digest on starts it after the latest event, with
+	// pref.DigestSentAt as the time of the last digest; the digest cursor is
+	// left alone otherwise. GetNotificationPreference returns NotifyNone for
+	// a user who never set one.
+	SetNotificationPreference(ctx context.Context, pref domain.NotificationPreference) (domain.NotificationPreference, error)
+	GetNotificationPreference(ctx context.Context, userID string) (domain.NotificationPreference, error)
+	// ListDueDigests returns the preferences of users on a channel whose
+	// digest was last sent at least one digest period before now, ordered by
+	// user ID. MarkDigestSent moves the user's digest cursor to seq and at.
+	ListDueDigests(ctx context.Context, now time.Time) ([]domain.NotificationPreference, error)
+	MarkDigestSent(ctx context.Context, userID string, seq int64, at time.Time) error
+
+	AddIdentity(ctx context.Context, identity domain.Identity) (domain.Identity, error)
+	ListIdentities(ctx context.Context, userID string) ([]domain.Identity, error)
+	ResolveIdentity(ctx context.Context, provider domain.IdentityProvider, externalID string) (domain.User, error)
 
 	CreatePullRequest(ctx context.Context, pr domain.PullRequest) (domain.PullRequest, error)
 	UpdatePullRequest(ctx context.Context, pr domain.PullRequest) (domain.PullRequest, error)
+	// CompareAndUpdatePullRequest stores pr like UpdatePullRequest in one
+	// transaction holding a lock on the pull request, as long as it still has
+	// the status and reviewers of seen. The flag reports whether pr was
+	// stored; a pull request changed in the meantime is returned unchanged.
+	CompareAndUpdatePullRequest(ctx context.Context, seen, pr domain.PullRequest) (domain.PullRequest, bool, error)
+	// ReassignReviewer swaps oldReviewerID for newReviewerID in one
+	// transaction holding a lock on the pull request. It fails with
+	// ErrPRMerged, ErrReviewerNotFound when oldReviewerID is no longer
+	// assigned, and ErrReassignConflict when newReviewerID was assigned or
+	// deactivated in the meantime.
+	ReassignReviewer(ctx context.Context, prID, oldReviewerID, newReviewerID string) (domain.PullRequest, error)
+	// AddReviewers assigns reviewerIDs, in order, to an open pull request in
+	// one transaction holding a lock on it, leaving the reviewers it already
+	// has in place. It stops once the pull request has limit reviewers and
+	// skips reviewers assigned or deactivated in the meantime, returning the
+	// ones it added; a pull request no longer open is returned unchanged.
+	AddReviewers(ctx context.Context, prID string, reviewerIDs []string, limit int) (domain.PullRequest, []string, error)
+	// MergePullRequest atomically moves an open pull request, or with force
+	// a draft too, to MERGED. The flag reports whether this call performed
+	// the transition; merging an already merged pull request returns it
+	// unchanged.
+	MergePullRequest(ctx context.Context, id string, mergedAt time.Time, force bool) (domain.PullRequest, bool, error)
 	GetPullRequest(ctx context.Context, id string) (domain.PullRequest, error)
-	ListPullRequestsByReviewer(ctx context.Context, userID string) ([]domain.PullRequest, error)
+	ArchivePullRequests(ctx context.Context, mergedBefore time.Time, limit int) (int, error)
+	ListReminderCandidates(ctx context.Context, createdBefore time.Time) ([]domain.ReminderCandidate, error)
+	SetReminderStage(ctx context.Context, prID string, stage domain.ReminderStage) error
+	RecordReview(ctx context.Context, prID string, kind domain.ReviewKind, at time.Time) (bool, error)
+	AppendReviewerHistory(ctx context.Context, changes []domain.ReviewerChange) error
+	// LinkPullRequests records that the pull request is blocked by another
+	// and reports whether the link is new. Either pull request missing
+	// fails with ErrPullRequestNotFound.
+	LinkPullRequests(ctx context.Context, prID, blockedBy string) (bool, error)
+	// UnlinkPullRequests removes the link and reports whether it existed.
+	UnlinkPullRequests(ctx context.Context, prID, blockedBy string) (bool, error)
+	// ListReviewerHistory returns the reviewer's assignments and removals
+	// matching filter, archived pull requests included, oldest first,
+	// skipping offset of them and returning at most limit.
+	ListReviewerHistory(ctx context.Context, filter domain.AssignmentHistoryFilter, offset, limit int) ([]domain.ReviewerChange, error)
+	AppendAssignmentDecision(ctx context.Context, decision domain.AssignmentDecision) error
+	ListAssignmentDecisions(ctx context.Context, prID string) ([]domain.AssignmentDecision, error)
+	ListPullRequestsByReviewer(ctx context.Context, userID string, filter domain.ReviewFilter) ([]domain.PullRequest, error)
+	ListParticipatedPullRequests(ctx context.Context, userIDs []string, limit int) ([]domain.PullRequest, error)
+
+	CountAssignments(ctx context.Context, teamName string, since time.Time) ([]domain.AssignmentCount, error)
+	CountOpenReviews(ctx context.Context, userIDs []string) (map[string]int, error)
+	// CountOpenPullRequests returns how many pull requests of the author are
+	// not merged yet, drafts included.
+	CountOpenPullRequests(ctx context.Context, authorID string) (int, error)
+	// CountCreatedPullRequests returns how many pull requests each author
+	// whose primary team is teamName created at or after since. Authors
+	// without pull requests are omitted.
+	CountCreatedPullRequests(ctx context.Context, teamName string, since time.Time) (map[string]int, error)
+	// ListRecentReviewers returns the reviewers of the author's pull requests
+	// merged at or after since.
+	ListRecentReviewers(ctx context.Context, authorID string, since time.Time) ([]string, error)
+	// ListPreviousReviewers returns the reviewers of the author's most
+	// recently merged pull request; none when the author has not merged any.
+	ListPreviousReviewers(ctx context.Context, authorID string) ([]string, error)
+	ListUnderassignedPullRequests(ctx context.Context, teamName string) ([]domain.UnderassignedPullRequest, error)
+	// ListHotPullRequests returns open pull requests reassigned at least
+	// minReassignments times, most reassigned first. An empty teamName
+	// matches all teams.
+	ListHotPullRequests(ctx context.Context, teamName string, minReassignments int) ([]domain.HotPullRequest, error)
+	AssignmentBuckets(ctx context.Context, teamName string, since time.Time) ([]domain.AssignmentBucket, error)
+	// TeamStats computes the team's statistics over pull requests created
+	// or merged at or after since, all but ReassignmentRate; MergesPerWeek
+	// lists only the weeks with merges.
+	TeamStats(ctx context.Context, teamName string, since time.Time) (domain.TeamStats, error)
+	ListReviewLatencies(ctx context.Context, teamName string, since time.Time) ([]domain.ReviewLatency, error)
+	// BuildDailyReviewStats replaces the aggregates of the UTC day starting
+	// at day with ones computed from the pull requests and reviewer history,
+	// and records the day as built.
+	BuildDailyReviewStats(ctx context.Context, day time.Time) error
+	// LastDailyReviewStatsDay returns the latest day BuildDailyReviewStats
+	// has built, or the zero time before the first build.
+	LastDailyReviewStatsDay(ctx context.Context) (time.Time, error)
+	// ListDailyReviewStats returns the aggregates of the days in [from, to]
+	// ordered by day, team and user. An empty teamName matches all teams.
+	ListDailyReviewStats(ctx context.Context, teamName string, from, to time.Time) ([]domain.DailyReviewStats, error)
+
+	CreateWebhook(ctx context.Context, webhook domain.Webhook) (domain.Webhook, error)
+	GetWebhook(ctx context.Context, id int64) (domain.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]domain.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook domain.Webhook) (domain.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	AppendWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) error
+	ListWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]domain.WebhookDelivery, error)
+	// ClaimWebhookDispatch takes the lease on webhook dispatch for owner, or
+	// renews it, until until and returns the dispatch cursor: the seq of the
+	// last event dispatched. ok is false while another owner holds a lease
+	// that has not expired at now.
+	ClaimWebhookDispatch(ctx context.Context, owner string, now, until time.Time) (seq int64, ok bool, err error)
+	// AdvanceWebhookDispatch moves the dispatch cursor forward to seq; ok is
+	// false when owner no longer holds the lease.
+	AdvanceWebhookDispatch(ctx context.Context, owner string, seq int64) (ok bool, err error)
+
+	DeferAssignment(ctx context.Context, deferred domain.DeferredAssignment) error
+	ListDeferredAssignments(ctx context.Context) ([]domain.DeferredAssignment, error)
+	DeleteDeferredAssignment(ctx context.Context, prID string) error
+
+	QueuePendingAssignment(ctx context.Context, prID string) error
+	// ListPendingAssignments returns the queued pull requests, oldest first.
+	ListPendingAssignments(ctx context.Context) ([]domain.PendingAssignment, error)
+	DeletePendingAssignment(ctx context.Context, prID string) error
+
+	// SyncReviewAcceptances drops the pending acceptances of the withdrawn
+	// reviewers of the pull request and requests the given ones, replacing
+	// any earlier acceptance of the same reviewer.
+	SyncReviewAcceptances(ctx context.Context, prID string, withdrawn []string, requested []domain.ReviewAcceptance) error
+	// AcceptReview marks the reviewer's acceptance accepted, even when it has
+	// expired. The flag reports whether an acceptance was waiting for it.
+	AcceptReview(ctx context.Context, prID, reviewerID string, at time.Time) (bool, error)
+	// CompleteReview marks the assigned reviewer's review of the pull
+	// request done. The flag reports whether it was not done yet.
+	CompleteReview(ctx context.Context, prID, reviewerID string, at time.Time) (bool, error)
+	// ReopenReviews drops the completions of the reviewers, e.g. because
+	// they were taken off the pull request.
+	ReopenReviews(ctx context.Context, prID string, reviewerIDs []string) error
+	// ExpireReviewAcceptances marks expired and returns the pending
+	// acceptances due at or before now of reviewers still assigned to open
+	// pull requests.
+	ExpireReviewAcceptances(ctx context.Context, now time.Time) ([]domain.ReviewAcceptance, error)
+	// RestoreReviewAcceptance makes an acceptance expired by
+	// ExpireReviewAcceptances pending again, for the next run to retry.
+	RestoreReviewAcceptance(ctx context.Context, acceptance domain.ReviewAcceptance) error
+	// ListReviewAcceptances returns the acceptances requested at or after
+	// since for pull requests authored by the team's members.
+	ListReviewAcceptances(ctx context.Context, teamName string, since time.Time) ([]domain.ReviewAcceptance, error)
+
+	// CreateDeadlineExtension stores a deadline extension request, possibly
+	// already approved, and returns it with its ID.
+	CreateDeadlineExtension(ctx context.Context, extension domain.DeadlineExtension) (domain.DeadlineExtension, error)
+	GetDeadlineExtension(ctx context.Context, id int64) (domain.DeadlineExtension, error)
+	// DecideDeadlineExtension approves or rejects a pending extension. It
+	// fails with ErrExtensionDecided when the extension is already decided.
+	DecideDeadlineExtension(ctx context.Context, id int64, status domain.ExtensionStatus, decidedBy string, at time.Time) (domain.DeadlineExtension, error)
+	// ListDeadlineExtensions returns the extensions requested for the pull
+	// request, oldest first.
+	ListDeadlineExtensions(ctx context.Context, prID string) ([]domain.DeadlineExtension, error)
+
+	// CreateTeamToken stores a team token by its hash and returns it with its
+	// ID. It fails with ErrTeamNotFound when the team does not exist.
+	CreateTeamToken(ctx context.Context, token domain.TeamToken) (domain.TeamToken, error)
+	GetTeamTokenByHash(ctx context.Context, hash string) (domain.TeamToken, error)
+	// ListTeamTokens returns the tokens of the team, or of all teams when
+	// teamName is empty, oldest first.
+	ListTeamTokens(ctx context.Context, teamName string) ([]domain.TeamToken, error)
+	DeleteTeamToken(ctx context.Context, id int64) error
+
+	// Reset deletes all data. It backs the test environment endpoints.
+	Reset(ctx context.Context) error
+
+	GetMaintenance(ctx context.Context) (domain.Maintenance, error)
+	SetMaintenance(ctx context.Context, enabled bool) (domain.Maintenance, error)
+
+	AppendEvent(ctx context.Context, event domain.Event) (domain.Event, error)
+	ListEvents(ctx context.Context, since int64, limit int) ([]domain.Event, error)
+	// ListLatestEvents returns the last limit events, oldest first, for
+	// readers that start at the tail of the feed rather than its beginning.
+	ListLatestEvents(ctx context.Context, limit int) ([]domain.Event, error)
+	// CheckIntegrity looks for inconsistent data as of now and, with fix
+	// set, repairs it in the same transaction: unknown and author reviewers
+	// are unassigned, future merge times are moved back to now, missing
+	// teams are created and the orphaned state of removed reviewers is
+	// deleted.
+	CheckIntegrity(ctx context.Context, now time.Time, fix bool) ([]domain.IntegrityIssue, error)
+	// CheckOrphans runs only the orphan checks of CheckIntegrity.
+	CheckOrphans(ctx context.Context, fix bool) ([]domain.IntegrityIssue, error)
+	// ExportSnapshot reads the state moved by a snapshot as of a single
+	// moment, everything ordered by ID.
+	ExportSnapshot(ctx context.Context) (domain.Snapshot, error)
+	// ImportSnapshot writes the snapshot in one transaction. It fails with
+	// ErrNotEmpty unless the store holds no teams and users.
+	ImportSnapshot(ctx context.Context, snapshot domain.Snapshot) error
+	// DeleteEvents removes the events up to and including throughSeq and
+	// returns how many were removed.
+	DeleteEvents(ctx context.Context, throughSeq int64) (int, error)
 
 	Health(ctx context.Context) error
 }
+
+// PoolStats describes the state of a repository's connection pool.
+type PoolStats struct {
+	AcquiredConns        int32
+	IdleConns            int32
+	TotalConns           int32
+	MaxConns             int32
+	AcquireCount         int64
+	AcquireDuration      time.Duration
+	EmptyAcquireCount    int64
+	CanceledAcquireCount int64
+}
+
+// StatsProvider is implemented by repositories backed by a connection pool.
+type StatsProvider interface {
+	PoolStats() PoolStats
+}
+
+// MigrationStatus describes a schema migration shipped with the release or
+// recorded as applied. Checksums are hex SHA-256 digests of the file.
+type MigrationStatus struct {
+	Name string
+	// Phase and Checksum are empty for a migration applied by another
+	// release that does not ship with this one.
+	Phase    string
+	Checksum string
+	// AppliedAt is nil while the migration is pending.
+	AppliedAt *time.Time
+	// AppliedChecksum is the checksum of the file as applied, empty for
+	// migrations applied before checksums were recorded.
+	AppliedChecksum string
+}
+
+// MigrationProvider is implemented by repositories with a versioned schema.
+type MigrationProvider interface {
+	MigrationStatus(ctx context.Context) ([]MigrationStatus, error)
+}
+
+// Transactor is implemented by repositories that can make several calls in
+// one transaction.
+type Transactor interface {
+	// Atomically runs fn in a transaction. The repository calls fn makes
+	// with the context it is given are committed together when fn returns
+	// nil and rolled back otherwise. Calling Atomically within fn runs a
+	// nested transaction, which rolls back alone when its fn fails and
+	// commits with the outer one otherwise.
+	Atomically(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,548 @@
+// Package retry decorates a repository so that calls failing on a transient
+// connection error are retried with exponential backoff.
+package retry
+
+import (
+	"context"
+	"time"
+
+	"Avito2025/internal/config"
+	"Avito2025/internal/domain"
+	"Avito2025/internal/storage"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Repository retries a call only when pgconn reports that the failed
+// attempt never reached the server, so writes are not applied twice. Health
+// is passed through untouched to report the backend as it is.
+type Repository struct {
+	storage.Repository
+	cfg config.StorageRetryConfig
+}
+
+// New wraps next; with fewer than two attempts configured calls are passed
+// through as they are.
+func New(next storage.Repository, cfg config.StorageRetryConfig) *Repository {
+	return &Repository{Repository: next, cfg: cfg}
+}
+
+// Decorator returns New as a storage.Decorator.
+func Decorator(cfg config.StorageRetryConfig) storage.Decorator {
+	return func(next storage.Repository) storage.Repository {
+		return New(next, cfg)
+	}
+}
+
+func (r *Repository) Unwrap() storage.Repository {
+	return r.Repository
+}
+
+func (r *Repository) run(ctx context.Context, fn func() error) error {
+	backoff := r.cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.cfg.MaxAttempts || !pgconn.SafeToRetry(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func do[T any](ctx context.Context, r *Repository, fn func() (T, error)) (T, error) {
+	var result T
+	err := r.run(ctx, func() error {
+		var err error
+		result, err = fn()
+		return err
+	})
+	return result, err
+}
+
+func (r *Repository) MergePullRequest(ctx context.Context, id string, mergedAt time.Time, force bool) (domain.PullRequest, bool, error) {
+	var merged bool
+	pr, err := do(ctx, r, func() (domain.PullRequest, error) {
+		pr, ok, err := r.Repository.MergePullRequest(ctx, id, mergedAt, force)
+		merged = ok
+		return pr, err
+	})
+	return pr, merged, err
+}
+
+func (r *Repository) CreateTeam(ctx context.Context, team domain.Team) (domain.Team, error) {
+	return do(ctx, r, func() (domain.Team, error) { return r.Repository.CreateTeam(ctx, team) })
+}
+
+func (r *Repository) GetTeam(ctx context.Context, name string) (domain.Team, error) {
+	return do(ctx, r, func() (domain.Team, error) { return r.Repository.GetTeam(ctx, name) })
+}
+
+func (r *Repository) ListUsers(ctx context.Context, filter domain.UserFilter, offset, limit int) ([]domain.UserSummary, error) {
+	return do(ctx, r, func() ([]domain.UserSummary, error) { return r.Repository.ListUsers(ctx, filter, offset, limit) })
+}
+
+func (r *Repository) ListTeams(ctx context.Context, prefix string, offset, limit int) ([]domain.TeamSummary, error) {
+	return do(ctx, r, func() ([]domain.TeamSummary, error) { return r.Repository.ListTeams(ctx, prefix, offset, limit) })
+}
+
+func (r *Repository) GetUser(ctx context.Context, userID string) (domain.User, error) {
+	return do(ctx, r, func() (domain.User, error) { return r.Repository.GetUser(ctx, userID) })
+}
+
+func (r *Repository) EnsureUser(ctx context.Context, user domain.User) (domain.User, bool, error) {
+	var created bool
+	stored, err := do(ctx, r, func() (domain.User, error) {
+		stored, ok, err := r.Repository.EnsureUser(ctx, user)
+		created = ok
+		return stored, err
+	})
+	return stored, created, err
+}
+
+func (r *Repository) SetUserActive(ctx context.Context, userID string, isActive bool) (domain.User, error) {
+	return do(ctx, r, func() (domain.User, error) { return r.Repository.SetUserActive(ctx, userID, isActive) })
+}
+
+func (r *Repository) SetUsersActive(ctx context.Context, userIDs []string, isActive bool) ([]domain.UserResult, error) {
+	return do(ctx, r, func() ([]domain.UserResult, error) { return r.Repository.SetUsersActive(ctx, userIDs, isActive) })
+}
+
+func (r *Repository) SnoozeUser(ctx context.Context, userID string, until *time.Time) (domain.User, error) {
+	return do(ctx, r, func() (domain.User, error) { return r.Repository.SnoozeUser(ctx, userID, until) })
+}
+
+func (r *Repository) UpdateUser(ctx context.Context, userID string, update domain.UserUpdate) (domain.User, error) {
+	return do(ctx, r, func() (domain.User, error) { return r.Repository.UpdateUser(ctx, userID, update) })
+}
+
+func (r *Repository) ListUsersByTeam(ctx context.Context, teamName string) ([]domain.User, error) {
+	return do(ctx, r, func() ([]domain.User, error) { return r.Repository.ListUsersByTeam(ctx, teamName) })
+}
+
+func (r *Repository) MergeTeams(ctx context.Context, source, target string) (domain.Team, error) {
+	return do(ctx, r, func() (domain.Team, error) { return r.Repository.MergeTeams(ctx, source, target) })
+}
+
+func (r *Repository) SplitTeam(ctx context.Context, split domain.TeamSplit) (domain.Team, error) {
+	return do(ctx, r, func() (domain.Team, error) { return r.Repository.SplitTeam(ctx, split) })
+}
+
+func (r *Repository) AppendMembershipChanges(ctx context.Context, changes []domain.MembershipChange) error {
+	return r.run(ctx, func() error { return r.Repository.AppendMembershipChanges(ctx, changes) })
+}
+
+func (r *Repository) ListMembershipHistory(ctx context.Context, teamName string) ([]domain.MembershipChange, error) {
+	return do(ctx, r, func() ([]domain.MembershipChange, error) { return r.Repository.ListMembershipHistory(ctx, teamName) })
+}
+
+func (r *Repository) GetTeamSettings(ctx context.Context, teamName string) (domain.TeamSettings, error) {
+	return do(ctx, r, func() (domain.TeamSettings, error) { return r.Repository.GetTeamSettings(ctx, teamName) })
+}
+
+func (r *Repository) SaveTeamSettings(ctx context.Context, settings domain.TeamSettings) (domain.TeamSettings, error) {
+	return do(ctx, r, func() (domain.TeamSettings, error) { return r.Repository.SaveTeamSettings(ctx, settings) })
+}
+
+func (r *Repository) SetRotation(ctx context.Context, rotation domain.Rotation) (domain.Rotation, error) {
+	return do(ctx, r, func() (domain.Rotation, error) { return r.Repository.SetRotation(ctx, rotation) })
+}
+
+func (r *Repository) GetRotation(ctx context.Context, teamName string) (domain.Rotation, error) {
+	return do(ctx, r, func() (domain.Rotation, error) { return r.Repository.GetRotation(ctx, teamName) })
+}
+
+func (r *Repository) AdvanceRotationPosition(ctx context.Context, teamName string, from, to int) error {
+	return r.run(ctx, func() error { return r.Repository.AdvanceRotationPosition(ctx, teamName, from, to) })
+}
+
+func (r *Repository) SetOwnership(ctx context.Context, ownership domain.Ownership) (domain.Ownership, error) {
+	return do(ctx, r, func() (domain.Ownership, error) { return r.Repository.SetOwnership(ctx, ownership) })
+}
+
+func (r *Repository) GetOwnership(ctx context.Context, teamName string) (domain.Ownership, error) {
+	return do(ctx, r, func() (domain.Ownership, error) { return r.Repository.GetOwnership(ctx, teamName) })
+}
+
+func (r *Repository) BuildDailyReviewStats(ctx context.Context, day time.Time) error {
+	return r.run(ctx, func() error { return r.Repository.BuildDailyReviewStats(ctx, day) })
+}
+
+func (r *Repository) LastDailyReviewStatsDay(ctx context.Context) (time.Time, error) {
+	return do(ctx, r, func() (time.Time, error) { return r.Repository.LastDailyReviewStatsDay(ctx) })
+}
+
+func (r *Repository) ListDailyReviewStats(ctx context.Context, teamName string, from, to time.Time) ([]domain.DailyReviewStats, error) {
+	return do(ctx, r, func() ([]domain.DailyReviewStats, error) {
+		return r.Repository.ListDailyReviewStats(ctx, teamName, from, to)
+	})
+}
+
+func (r *Repository) SetTeamLead(ctx context.Context, teamName, userID string, isLead bool) error {
+	return r.run(ctx, func() error { return r.Repository.SetTeamLead(ctx, teamName, userID, isLead) })
+}
+
+func (r *Repository) ListTeamLeads(ctx context.Context, teamName string) ([]string, error) {
+	return do(ctx, r, func() ([]string, error) { return r.Repository.ListTeamLeads(ctx, teamName) })
+}
+
+func (r *Repository) SetNotificationPreference(ctx context.Context, pref domain.NotificationPreference) (domain.NotificationPreference, error) {
+	return do(ctx, r, func() (domain.NotificationPreference, error) {
+		return r.Repository.SetNotificationPreference(ctx, pref)
+	})
+}
+
+func (r *Repository) GetNotificationPreference(ctx context.Context, userID string) (domain.NotificationPreference, error) {
+	return do(ctx, r, func() (domain.NotificationPreference, error) {
+		return r.Repository.GetNotificationPreference(ctx, userID)
+	})
+}
+
+func (r *Repository) ListDueDigests(ctx context.Context, now time.Time) ([]domain.NotificationPreference, error) {
+	return do(ctx, r, func() ([]domain.NotificationPreference, error) {
+		return r.Repository.ListDueDigests(ctx, now)
+	})
+}
+
+func (r *Repository) MarkDigestSent(ctx context.Context, userID string, seq int64, at time.Time) error {
+	return r.run(ctx, func() error {
+		return r.Repository.MarkDigestSent(ctx, userID, seq, at)
+	})
+}
+
+func (r *Repository) AddIdentity(ctx context.Context, identity domain.Identity) (domain.Identity, error) {
+	return do(ctx, r, func() (domain.Identity, error) { return r.Repository.AddIdentity(ctx, identity) })
+}
+
+func (r *Repository) ListIdentities(ctx context.Context, userID string) ([]domain.Identity, error) {
+	return do(ctx, r, func() ([]domain.Identity, error) { return r.Repository.ListIdentities(ctx, userID) })
+}
+
+func (r *Repository) ResolveIdentity(ctx context.Context, provider domain.IdentityProvider, externalID string) (domain.User, error) {
+	return do(ctx, r, func() (domain.User, error) { return r.Repository.ResolveIdentity(ctx, provider, externalID) })
+}
+
+func (r *Repository) CreatePullRequest(ctx context.Context, pr domain.PullRequest) (domain.PullRequest, error) {
+	return do(ctx, r, func() (domain.PullRequest, error) { return r.Repository.CreatePullRequest(ctx, pr) })
+}
+
+func (r *Repository) ReassignReviewer(ctx context.Context, prID, oldReviewerID, newReviewerID string) (domain.PullRequest, error) {
+	return do(ctx, r, func() (domain.PullRequest, error) {
+		return r.Repository.ReassignReviewer(ctx, prID, oldReviewerID, newReviewerID)
+	})
+}
+
+func (r *Repository) AddReviewers(ctx context.Context, prID string, reviewerIDs []string, limit int) (domain.PullRequest, []string, error) {
+	var added []string
+	pr, err := do(ctx, r, func() (domain.PullRequest, error) {
+		pr, ids, err := r.Repository.AddReviewers(ctx, prID, reviewerIDs, limit)
+		added = ids
+		return pr, err
+	})
+	return pr, added, err
+}
+
+func (r *Repository) UpdatePullRequest(ctx context.Context, pr domain.PullRequest) (domain.PullRequest, error) {
+	return do(ctx, r, func() (domain.PullRequest, error) { return r.Repository.UpdatePullRequest(ctx, pr) })
+}
+
+func (r *Repository) CompareAndUpdatePullRequest(ctx context.Context, seen, pr domain.PullRequest) (domain.PullRequest, bool, error) {
+	var updated bool
+	result, err := do(ctx, r, func() (domain.PullRequest, error) {
+		result, ok, err := r.Repository.CompareAndUpdatePullRequest(ctx, seen, pr)
+		updated = ok
+		return result, err
+	})
+	return result, updated, err
+}
+
+func (r *Repository) GetPullRequest(ctx context.Context, id string) (domain.PullRequest, error) {
+	return do(ctx, r, func() (domain.PullRequest, error) { return r.Repository.GetPullRequest(ctx, id) })
+}
+
+func (r *Repository) ArchivePullRequests(ctx context.Context, mergedBefore time.Time, limit int) (int, error) {
+	return do(ctx, r, func() (int, error) { return r.Repository.ArchivePullRequests(ctx, mergedBefore, limit) })
+}
+
+func (r *Repository) ListReminderCandidates(ctx context.Context, createdBefore time.Time) ([]domain.ReminderCandidate, error) {
+	return do(ctx, r, func() ([]domain.ReminderCandidate, error) {
+		return r.Repository.ListReminderCandidates(ctx, createdBefore)
+	})
+}
+
+func (r *Repository) SetReminderStage(ctx context.Context, prID string, stage domain.ReminderStage) error {
+	return r.run(ctx, func() error { return r.Repository.SetReminderStage(ctx, prID, stage) })
+}
+
+func (r *Repository) RecordReview(ctx context.Context, prID string, kind domain.ReviewKind, at time.Time) (bool, error) {
+	return do(ctx, r, func() (bool, error) { return r.Repository.RecordReview(ctx, prID, kind, at) })
+}
+
+func (r *Repository) AppendReviewerHistory(ctx context.Context, changes []domain.ReviewerChange) error {
+	return r.run(ctx, func() error { return r.Repository.AppendReviewerHistory(ctx, changes) })
+}
+
+func (r *Repository) LinkPullRequests(ctx context.Context, prID, blockedBy string) (bool, error) {
+	return do(ctx, r, func() (bool, error) { return r.Repository.LinkPullRequests(ctx, prID, blockedBy) })
+}
+
+func (r *Repository) UnlinkPullRequests(ctx context.Context, prID, blockedBy string) (bool, error) {
+	return do(ctx, r, func() (bool, error) { return r.Repository.UnlinkPullRequests(ctx, prID, blockedBy) })
+}
+
+func (r *Repository) ListReviewerHistory(ctx context.Context, filter domain.AssignmentHistoryFilter, offset, limit int) ([]domain.ReviewerChange, error) {
+	return do(ctx, r, func() ([]domain.ReviewerChange, error) {
+		return r.Repository.ListReviewerHistory(ctx, filter, offset, limit)
+	})
+}
+
+func (r *Repository) AppendAssignmentDecision(ctx context.Context, decision domain.AssignmentDecision) error {
+	return r.run(ctx, func() error { return r.Repository.AppendAssignmentDecision(ctx, decision) })
+}
+
+func (r *Repository) ListAssignmentDecisions(ctx context.Context, prID string) ([]domain.AssignmentDecision, error) {
+	return do(ctx, r, func() ([]domain.AssignmentDecision, error) { return r.Repository.ListAssignmentDecisions(ctx, prID) })
+}
+
+func (r *Repository) ListPullRequestsByReviewer(ctx context.Context, userID string, filter domain.ReviewFilter) ([]domain.PullRequest, error) {
+	return do(ctx, r, func() ([]domain.PullRequest, error) {
+		return r.Repository.ListPullRequestsByReviewer(ctx, userID, filter)
+	})
+}
+
+func (r *Repository) ListParticipatedPullRequests(ctx context.Context, userIDs []string, limit int) ([]domain.PullRequest, error) {
+	return do(ctx, r, func() ([]domain.PullRequest, error) {
+		return r.Repository.ListParticipatedPullRequests(ctx, userIDs, limit)
+	})
+}
+
+func (r *Repository) CountAssignments(ctx context.Context, teamName string, since time.Time) ([]domain.AssignmentCount, error) {
+	return do(ctx, r, func() ([]domain.AssignmentCount, error) { return r.Repository.CountAssignments(ctx, teamName, since) })
+}
+
+func (r *Repository) CountOpenPullRequests(ctx context.Context, authorID string) (int, error) {
+	return do(ctx, r, func() (int, error) { return r.Repository.CountOpenPullRequests(ctx, authorID) })
+}
+
+func (r *Repository) CountOpenReviews(ctx context.Context, userIDs []string) (map[string]int, error) {
+	return do(ctx, r, func() (map[string]int, error) { return r.Repository.CountOpenReviews(ctx, userIDs) })
+}
+
+func (r *Repository) CountCreatedPullRequests(ctx context.Context, teamName string, since time.Time) (map[string]int, error) {
+	return do(ctx, r, func() (map[string]int, error) { return r.Repository.CountCreatedPullRequests(ctx, teamName, since) })
+}
+
+func (r *Repository) ListPreviousReviewers(ctx context.Context, authorID string) ([]string, error) {
+	return do(ctx, r, func() ([]string, error) { return r.Repository.ListPreviousReviewers(ctx, authorID) })
+}
+
+func (r *Repository) ListRecentReviewers(ctx context.Context, authorID string, since time.Time) ([]string, error) {
+	return do(ctx, r, func() ([]string, error) { return r.Repository.ListRecentReviewers(ctx, authorID, since) })
+}
+
+func (r *Repository) ListUnderassignedPullRequests(ctx context.Context, teamName string) ([]domain.UnderassignedPullRequest, error) {
+	return do(ctx, r, func() ([]domain.UnderassignedPullRequest, error) {
+		return r.Repository.ListUnderassignedPullRequests(ctx, teamName)
+	})
+}
+
+func (r *Repository) ListHotPullRequests(ctx context.Context, teamName string, minReassignments int) ([]domain.HotPullRequest, error) {
+	return do(ctx, r, func() ([]domain.HotPullRequest, error) {
+		return r.Repository.ListHotPullRequests(ctx, teamName, minReassignments)
+	})
+}
+
+func (r *Repository) AssignmentBuckets(ctx context.Context, teamName string, since time.Time) ([]domain.AssignmentBucket, error) {
+	return do(ctx, r, func() ([]domain.AssignmentBucket, error) { return r.Repository.AssignmentBuckets(ctx, teamName, since) })
+}
+
+func (r *Repository) TeamStats(ctx context.Context, teamName string, since time.Time) (domain.TeamStats, error) {
+	return do(ctx, r, func() (domain.TeamStats, error) { return r.Repository.TeamStats(ctx, teamName, since) })
+}
+
+func (r *Repository) ListReviewLatencies(ctx context.Context, teamName string, since time.Time) ([]domain.ReviewLatency, error) {
+	return do(ctx, r, func() ([]domain.ReviewLatency, error) { return r.Repository.ListReviewLatencies(ctx, teamName, since) })
+}
+
+func (r *Repository) CreateWebhook(ctx context.Context, webhook domain.Webhook) (domain.Webhook, error) {
+	return do(ctx, r, func() (domain.Webhook, error) { return r.Repository.CreateWebhook(ctx, webhook) })
+}
+
+func (r *Repository) GetWebhook(ctx context.Context, id int64) (domain.Webhook, error) {
+	return do(ctx, r, func() (domain.Webhook, error) { return r.Repository.GetWebhook(ctx, id) })
+}
+
+func (r *Repository) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	return do(ctx, r, func() ([]domain.Webhook, error) { return r.Repository.ListWebhooks(ctx) })
+}
+
+func (r *Repository) UpdateWebhook(ctx context.Context, webhook domain.Webhook) (domain.Webhook, error) {
+	return do(ctx, r, func() (domain.Webhook, error) { return r.Repository.UpdateWebhook(ctx, webhook) })
+}
+
+func (r *Repository) DeleteWebhook(ctx context.Context, id int64) error {
+	return r.run(ctx, func() error { return r.Repository.DeleteWebhook(ctx, id) })
+}
+
+func (r *Repository) AppendWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	return r.run(ctx, func() error { return r.Repository.AppendWebhookDelivery(ctx, delivery) })
+}
+
+func (r *Repository) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]domain.WebhookDelivery, error) {
+	return do(ctx, r, func() ([]domain.WebhookDelivery, error) {
+		return r.Repository.ListWebhookDeliveries(ctx, webhookID, limit)
+	})
+}
+
+func (r *Repository) ClaimWebhookDispatch(ctx context.Context, owner string, now, until time.Time) (int64, bool, error) {
+	var ok bool
+	seq, err := do(ctx, r, func() (int64, error) {
+		seq, claimed, err := r.Repository.ClaimWebhookDispatch(ctx, owner, now, until)
+		ok = claimed
+		return seq, err
+	})
+	return seq, ok, err
+}
+
+func (r *Repository) AdvanceWebhookDispatch(ctx context.Context, owner string, seq int64) (bool, error) {
+	return do(ctx, r, func() (bool, error) { return r.Repository.AdvanceWebhookDispatch(ctx, owner, seq) })
+}
+
+func (r *Repository) DeferAssignment(ctx context.Context, deferred domain.DeferredAssignment) error {
+	return r.run(ctx, func() error { return r.Repository.DeferAssignment(ctx, deferred) })
+}
+
+func (r *Repository) ListDeferredAssignments(ctx context.Context) ([]domain.DeferredAssignment, error) {
+	return do(ctx, r, func() ([]domain.DeferredAssignment, error) { return r.Repository.ListDeferredAssignments(ctx) })
+}
+
+func (r *Repository) DeleteDeferredAssignment(ctx context.Context, prID string) error {
+	return r.run(ctx, func() error { return r.Repository.DeleteDeferredAssignment(ctx, prID) })
+}
+
+func (r *Repository) QueuePendingAssignment(ctx context.Context, prID string) error {
+	return r.run(ctx, func() error { return r.Repository.QueuePendingAssignment(ctx, prID) })
+}
+
+func (r *Repository) ListPendingAssignments(ctx context.Context) ([]domain.PendingAssignment, error) {
+	return do(ctx, r, func() ([]domain.PendingAssignment, error) { return r.Repository.ListPendingAssignments(ctx) })
+}
+
+func (r *Repository) DeletePendingAssignment(ctx context.Context, prID string) error {
+	return r.run(ctx, func() error { return r.Repository.DeletePendingAssignment(ctx, prID) })
+}
+
+func (r *Repository) SyncReviewAcceptances(ctx context.Context, prID string, withdrawn []string, requested []domain.ReviewAcceptance) error {
+	return r.run(ctx, func() error { return r.Repository.SyncReviewAcceptances(ctx, prID, withdrawn, requested) })
+}
+
+func (r *Repository) CompleteReview(ctx context.Context, prID, reviewerID string, at time.Time) (bool, error) {
+	return do(ctx, r, func() (bool, error) { return r.Repository.CompleteReview(ctx, prID, reviewerID, at) })
+}
+
+func (r *Repository) ReopenReviews(ctx context.Context, prID string, reviewerIDs []string) error {
+	return r.run(ctx, func() error { return r.Repository.ReopenReviews(ctx, prID, reviewerIDs) })
+}
+
+func (r *Repository) AcceptReview(ctx context.Context, prID, reviewerID string, at time.Time) (bool, error) {
+	return do(ctx, r, func() (bool, error) { return r.Repository.AcceptReview(ctx, prID, reviewerID, at) })
+}
+
+func (r *Repository) ExpireReviewAcceptances(ctx context.Context, now time.Time) ([]domain.ReviewAcceptance, error) {
+	return do(ctx, r, func() ([]domain.ReviewAcceptance, error) { return r.Repository.ExpireReviewAcceptances(ctx, now) })
+}
+
+func (r *Repository) RestoreReviewAcceptance(ctx context.Context, acceptance domain.ReviewAcceptance) error {
+	return r.run(ctx, func() error { return r.Repository.RestoreReviewAcceptance(ctx, acceptance) })
+}
+
+func (r *Repository) ListReviewAcceptances(ctx context.Context, teamName string, since time.Time) ([]domain.ReviewAcceptance, error) {
+	return do(ctx, r, func() ([]domain.ReviewAcceptance, error) {
+		return r.Repository.ListReviewAcceptances(ctx, teamName, since)
+	})
+}
+
+func (r *Repository) CreateDeadlineExtension(ctx context.Context, extension domain.DeadlineExtension) (domain.DeadlineExtension, error) {
+	return do(ctx, r, func() (domain.DeadlineExtension, error) {
+		return r.Repository.CreateDeadlineExtension(ctx, extension)
+	})
+}
+
+func (r *Repository) GetDeadlineExtension(ctx context.Context, id int64) (domain.DeadlineExtension, error) {
+	return do(ctx, r, func() (domain.DeadlineExtension, error) { return r.Repository.GetDeadlineExtension(ctx, id) })
+}
+
+func (r *Repository) DecideDeadlineExtension(ctx context.Context, id int64, status domain.ExtensionStatus, decidedBy string, at time.Time) (domain.DeadlineExtension, error) {
+	return do(ctx, r, func() (domain.DeadlineExtension, error) {
+		return r.Repository.DecideDeadlineExtension(ctx, id, status, decidedBy, at)
+	})
+}
+
+func (r *Repository) ListDeadlineExtensions(ctx context.Context, prID string) ([]domain.DeadlineExtension, error) {
+	return do(ctx, r, func() ([]domain.DeadlineExtension, error) { return r.Repository.ListDeadlineExtensions(ctx, prID) })
+}
+
+func (r *Repository) CreateTeamToken(ctx context.Context, token domain.TeamToken) (domain.TeamToken, error) {
+	return do(ctx, r, func() (domain.TeamToken, error) { return r.Repository.CreateTeamToken(ctx, token) })
+}
+
+func (r *Repository) GetTeamTokenByHash(ctx context.Context, hash string) (domain.TeamToken, error) {
+	return do(ctx, r, func() (domain.TeamToken, error) { return r.Repository.GetTeamTokenByHash(ctx, hash) })
+}
+
+func (r *Repository) ListTeamTokens(ctx context.Context, teamName string) ([]domain.TeamToken, error) {
+	return do(ctx, r, func() ([]domain.TeamToken, error) { return r.Repository.ListTeamTokens(ctx, teamName) })
+}
+
+func (r *Repository) DeleteTeamToken(ctx context.Context, id int64) error {
+	return r.run(ctx, func() error { return r.Repository.DeleteTeamToken(ctx, id) })
+}
+
+func (r *Repository) Reset(ctx context.Context) error {
+	return r.run(ctx, func() error { return r.Repository.Reset(ctx) })
+}
+
+func (r *Repository) GetMaintenance(ctx context.Context) (domain.Maintenance, error) {
+	return do(ctx, r, func() (domain.Maintenance, error) { return r.Repository.GetMaintenance(ctx) })
+}
+
+func (r *Repository) SetMaintenance(ctx context.Context, enabled bool) (domain.Maintenance, error) {
+	return do(ctx, r, func() (domain.Maintenance, error) { return r.Repository.SetMaintenance(ctx, enabled) })
+}
+
+func (r *Repository) AppendEvent(ctx context.Context, event domain.Event) (domain.Event, error) {
+	return do(ctx, r, func() (domain.Event, error) { return r.Repository.AppendEvent(ctx, event) })
+}
+
+func (r *Repository) ListEvents(ctx context.Context, since int64, limit int) ([]domain.Event, error) {
+	return do(ctx, r, func() ([]domain.Event, error) { return r.Repository.ListEvents(ctx, since, limit) })
+}
+
+func (r *Repository) ListLatestEvents(ctx context.Context, limit int) ([]domain.Event, error) {
+	return do(ctx, r, func() ([]domain.Event, error) { return r.Repository.ListLatestEvents(ctx, limit) })
+}
+
+func (r *Repository) CheckIntegrity(ctx context.Context, now time.Time, fix bool) ([]domain.IntegrityIssue, error) {
+	return do(ctx, r, func() ([]domain.IntegrityIssue, error) { return r.Repository.CheckIntegrity(ctx, now, fix) })
+}
+
+func (r *Repository) ExportSnapshot(ctx context.Context) (domain.Snapshot, error) {
+	return do(ctx, r, func() (domain.Snapshot, error) { return r.Repository.ExportSnapshot(ctx) })
+}
+
+func (r *Repository) ImportSnapshot(ctx context.Context, snapshot domain.Snapshot) error {
+	return r.run(ctx, func() error { return r.Repository.ImportSnapshot(ctx, snapshot) })
+}
+
+func (r *Repository) CheckOrphans(ctx context.Context, fix bool) ([]domain.IntegrityIssue, error) {
+	return do(ctx, r, func() ([]domain.IntegrityIssue, error) { return r.Repository.CheckOrphans(ctx, fix) })
+}
+
+func (r *Repository) DeleteEvents(ctx context.Context, throughSeq int64) (int, error) {
+	return do(ctx, r, func() (int, error) { return r.Repository.DeleteEvents(ctx, throughSeq) })
+}
@@ -0,0 +1,162 @@
+// Package storagetest provides the repository used by service and e2e tests.
+//
+// The backend is selected with the TEST_STORAGE environment variable:
+//
+//	memory    in-process store, no external dependencies (default)
+//	postgres  disposable Postgres container started through testcontainers (requires Docker)
+//	embedded  Postgres binaries downloaded and run as a child process (no Docker)
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"Avito2025/internal/config"
+	"Avito2025/internal/storage"
+	"Avito2025/internal/storage/memory"
+	"Avito2025/internal/storage/postgres"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	BackendMemory   = "memory"
+	BackendPostgres = "postgres"
+	BackendEmbedded = "embedded"
+)
+
+// Backend reports the backend selected by TEST_STORAGE.
+func Backend() string {
+	if backend := os.Getenv("TEST_STORAGE"); backend != "" {
+		return backend
+	}
+	return BackendMemory
+}
+
+// New returns a fresh, empty repository for the selected backend. Resources are
+// released through t.Cleanup.
+func New(t testing.TB) storage.Repository {
+	t.Helper()
+
+	switch backend := Backend(); backend {
+	case BackendMemory:
+		return memory.New()
+	case BackendPostgres:
+		return newContainerStore(t)
+	case BackendEmbedded:
+		return newEmbeddedStore(t)
+	default:
+		t.Fatalf("unsupported TEST_STORAGE %q", backend)
+		return nil
+	}
+}
+
+func newContainerStore(t testing.TB) storage.Repository {
+	t.Helper()
+
+	ctx := context.Background()
+
+	postgresContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "postgres:15-alpine",
+			ExposedPorts: []string{"5432/tcp"},
+			Env: map[string]string{
+				"POSTGRES_USER":     "test",
+				"POSTGRES_PASSWORD": "test",
+				"POSTGRES_DB":       "test",
+			},
+			WaitingFor: wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(30 * time.Second),
+		},
+		Started: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := postgresContainer.Terminate(ctx); err != nil {
+			t.Logf("failed to terminate postgres container: %v", err)
+		}
+	})
+
+	host, err := postgresContainer.Host(ctx)
+	if err != nil {
+		t.Fatalf("failed to get postgres host: %v", err)
+	}
+
+	port, err := postgresContainer.MappedPort(ctx, "5432")
+	if err != nil {
+		t.Fatalf("failed to get postgres port: %v", err)
+	}
+
+	return openStore(t, host, port.Port())
+}
+
+func newEmbeddedStore(t testing.TB) storage.Repository {
+	t.Helper()
+
+	port, err := freePort()
+	if err != nil {
+		t.Fatalf("failed to pick port for embedded postgres: %v", err)
+	}
+
+	db := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+		Version(embeddedpostgres.V15).
+		Username("test").
+		Password("test").
+		Database("test").
+		Port(port).
+		RuntimePath(t.TempDir()).
+		StartTimeout(60 * time.Second).
+		Logger(io.Discard))
+	if err := db.Start(); err != nil {
+		t.Fatalf("failed to start embedded postgres: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := db.Stop(); err != nil {
+			t.Logf("failed to stop embedded postgres: %v", err)
+		}
+	})
+
+	return openStore(t, "localhost", fmt.Sprint(port))
+}
+
+func openStore(t testing.TB, host, port string) storage.Repository {
+	t.Helper()
+
+	store, err := postgres.New(context.Background(), config.PostgresConfig{
+		Host:     host,
+		Port:     port,
+		User:     "test",
+		Password: "test",
+		DBName:   "test",
+		SSLMode:  "disable",
+		MaxConns: 4,
+	})
+	if err != nil {
+		t.Fatalf("failed to create postgres store: %v", err)
+	}
+
+	t.Cleanup(store.Close)
+
+	return store
+}
+
+func freePort() (uint32, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return uint32(listener.Addr().(*net.TCPAddr).Port), nil
+}
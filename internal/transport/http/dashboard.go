@@ -0,0 +1,34 @@
+package httptransport
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"net/http"
+)
+
+//go:embed ui/*.html
+var uiTemplates embed.FS
+
+var dashboardTemplate = template.Must(template.ParseFS(uiTemplates, "ui/dashboard.html"))
+
+// dashboardData is what the dashboard template is rendered with. The page
+// itself holds no data: its script reads everything from the JSON API under
+// APIBase, so it shows exactly what the API would answer.
+type dashboardData struct {
+	APIBase string
+}
+
+// Dashboard serves a read-only HTML page with the teams, the open pull
+// requests, the reviewer load and the recent events, for quick checks by
+// operators without a frontend of their own.
+func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
+	var page bytes.Buffer
+	if err := dashboardTemplate.Execute(&page, dashboardData{APIBase: "/v1"}); err != nil {
+		respondError(w, http.StatusInternalServerError, "INTERNAL", "internal server error")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(page.Bytes())
+}
@@ -1,8 +1,11 @@
 package httptransport
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"Avito2025/internal/domain"
 )
@@ -10,12 +13,21 @@ import (
 type teamRequest struct {
 	TeamName string              `json:"team_name"`
 	Members  []teamMemberRequest `json:"members"`
+	// Transfer moves members who belong to another team into this one,
+	// handing over their open reviews there; Join keeps them in both. By
+	// default such members are refused.
+	Transfer bool `json:"transfer"`
+	Join     bool `json:"join"`
+	// Links are read-only. They are accepted, and ignored, so that strict
+	// decoding lets a team read from the API be sent back.
+	Links json.RawMessage `json:"links"`
 }
 
 type teamMemberRequest struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	IsActive bool   `json:"is_active"`
+	UserID   string          `json:"user_id"`
+	Username string          `json:"username"`
+	IsActive bool            `json:"is_active"`
+	Links    json.RawMessage `json:"links"`
 }
 
 func (t teamRequest) validate() error {
@@ -25,6 +37,9 @@ func (t teamRequest) validate() error {
 	if len(t.Members) == 0 {
 		return errors.New("members are required")
 	}
+	if t.Transfer && t.Join {
+		return errors.New("transfer and join are mutually exclusive")
+	}
 	for i, member := range t.Members {
 		if member.UserID == "" {
 			return fmt.Errorf("members[%d].user_id is required", i)
@@ -53,6 +68,291 @@ func (t teamRequest) toDomain() domain.Team {
 	}
 }
 
+func (t teamRequest) conflict() domain.MemberConflict {
+	switch {
+	case t.Transfer:
+		return domain.ConflictTransfer
+	case t.Join:
+		return domain.ConflictJoin
+	default:
+		return domain.ConflictReject
+	}
+}
+
+// teamSettingsRequest is a partial update: omitted fields keep their value.
+type teamSettingsRequest struct {
+	TeamName            string  `json:"team_name"`
+	RequiredReviewers   *int    `json:"required_reviewers"`
+	AllowSingleReviewer *bool   `json:"allow_single_reviewer"`
+	AllowAuthorReview   *bool   `json:"allow_author_review"`
+	RemindAfterHours    *int    `json:"remind_after_hours"`
+	EscalateAfterHours  *int    `json:"escalate_after_hours"`
+	ReassignAfterHours  *int    `json:"reassign_after_hours"`
+	Strategy            *string `json:"strategy"`
+	MaxOpenReviews      *int    `json:"max_open_reviews"`
+	ReviewCooldownHours *int    `json:"review_cooldown_hours"`
+	// AcceptTimeoutHours makes assigned reviewers accept the review within
+	// it or be replaced; zero disables the step.
+	AcceptTimeoutHours *int `json:"accept_timeout_hours"`
+	// AutoApproveExtensionHours approves without a lead the deadline
+	// extensions reaching at most this far; zero leaves them all to leads.
+	AutoApproveExtensionHours *int `json:"auto_approve_extension_hours"`
+	// AvoidPreviousReviewers holds back the reviewers of the author's last
+	// merged pull request.
+	AvoidPreviousReviewers *bool `json:"avoid_previous_reviewers"`
+	// BlockMergeOnDependencies refuses merges while blockers linked through
+	// /pullRequest/link are unmerged.
+	BlockMergeOnDependencies *bool `json:"block_merge_on_dependencies"`
+	// MandatoryReviewer is assigned to every pull request on top of the
+	// picked reviewers; an empty string removes it.
+	MandatoryReviewer *string `json:"mandatory_reviewer_id"`
+	// Working hours: an IANA time zone, HH:MM bounds of the working day and
+	// three-letter lowercase weekday names.
+	TimeZone  *string   `json:"time_zone"`
+	WorkStart *string   `json:"work_start"`
+	WorkEnd   *string   `json:"work_end"`
+	WorkDays  *[]string `json:"work_days"`
+	// ShadowPool lists the trainees picked as non-blocking shadow reviewers.
+	ShadowPool *[]string `json:"shadow_pool"`
+}
+
+func (r teamSettingsRequest) validate() error {
+	if r.TeamName == "" {
+		return errors.New("team_name is required")
+	}
+	if r.RequiredReviewers != nil && *r.RequiredReviewers < 1 {
+		return errors.New("required_reviewers must be positive")
+	}
+	for name, hours := range map[string]*int{
+		"remind_after_hours":           r.RemindAfterHours,
+		"escalate_after_hours":         r.EscalateAfterHours,
+		"reassign_after_hours":         r.ReassignAfterHours,
+		"review_cooldown_hours":        r.ReviewCooldownHours,
+		"accept_timeout_hours":         r.AcceptTimeoutHours,
+		"auto_approve_extension_hours": r.AutoApproveExtensionHours,
+	} {
+		if hours != nil && *hours < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	if r.MaxOpenReviews != nil && *r.MaxOpenReviews < 0 {
+		return errors.New("max_open_reviews must not be negative")
+	}
+	if r.Strategy != nil && !domain.SelectionStrategy(*r.Strategy).Valid() {
+		return errors.New("strategy must be one of random, affinity")
+	}
+	if r.TimeZone != nil {
+		if _, err := time.LoadLocation(*r.TimeZone); err != nil || *r.TimeZone == "" {
+			return errors.New("time_zone must be an IANA time zone such as Europe/Moscow")
+		}
+	}
+	for name, clock := range map[string]*string{
+		"work_start": r.WorkStart,
+		"work_end":   r.WorkEnd,
+	} {
+		if clock == nil {
+			continue
+		}
+		if _, err := parseClock(*clock); err != nil {
+			return fmt.Errorf("%s must be a time of day such as 09:00", name)
+		}
+	}
+	if r.WorkDays != nil {
+		for i, day := range *r.WorkDays {
+			if _, ok := weekdays[day]; !ok {
+				return fmt.Errorf("work_days[%d] must be one of mon, tue, wed, thu, fri, sat, sun", i)
+			}
+		}
+	}
+	if r.ShadowPool != nil {
+		for i, userID := range *r.ShadowPool {
+			if userID == "" {
+				return fmt.Errorf("shadow_pool[%d] is required", i)
+			}
+		}
+	}
+	return nil
+}
+
+func (r teamSettingsRequest) apply(settings domain.TeamSettings) domain.TeamSettings {
+	if r.RequiredReviewers != nil {
+		settings.RequiredReviewers = *r.RequiredReviewers
+	}
+	if r.AllowSingleReviewer != nil {
+		settings.AllowSingleReviewer = *r.AllowSingleReviewer
+	}
+	if r.AllowAuthorReview != nil {
+		settings.AllowAuthorReview = *r.AllowAuthorReview
+	}
+	if r.RemindAfterHours != nil {
+		settings.RemindAfter = time.Duration(*r.RemindAfterHours) * time.Hour
+	}
+	if r.EscalateAfterHours != nil {
+		settings.EscalateAfter = time.Duration(*r.EscalateAfterHours) * time.Hour
+	}
+	if r.ReassignAfterHours != nil {
+		settings.ReassignAfter = time.Duration(*r.ReassignAfterHours) * time.Hour
+	}
+	if r.Strategy != nil {
+		settings.Strategy = domain.SelectionStrategy(*r.Strategy)
+	}
+	if r.MaxOpenReviews != nil {
+		settings.MaxOpenReviews = *r.MaxOpenReviews
+	}
+	if r.ReviewCooldownHours != nil {
+		settings.ReviewCooldown = time.Duration(*r.ReviewCooldownHours) * time.Hour
+	}
+	if r.AcceptTimeoutHours != nil {
+		settings.AcceptTimeout = time.Duration(*r.AcceptTimeoutHours) * time.Hour
+	}
+	if r.AutoApproveExtensionHours != nil {
+		settings.AutoApproveExtension = time.Duration(*r.AutoApproveExtensionHours) * time.Hour
+	}
+	if r.AvoidPreviousReviewers != nil {
+		settings.AvoidPreviousReviewers = *r.AvoidPreviousReviewers
+	}
+	if r.BlockMergeOnDependencies != nil {
+		settings.BlockMergeOnDependencies = *r.BlockMergeOnDependencies
+	}
+	if r.MandatoryReviewer != nil {
+		settings.MandatoryReviewer = *r.MandatoryReviewer
+	}
+	if r.TimeZone != nil {
+		settings.TimeZone = *r.TimeZone
+	}
+	if r.WorkStart != nil {
+		settings.WorkStart, _ = parseClock(*r.WorkStart)
+	}
+	if r.WorkEnd != nil {
+		settings.WorkEnd, _ = parseClock(*r.WorkEnd)
+	}
+	if r.WorkDays != nil {
+		days := make([]time.Weekday, 0, len(*r.WorkDays))
+		for _, day := range *r.WorkDays {
+			days = append(days, weekdays[day])
+		}
+		settings.WorkDays = days
+	}
+	if r.ShadowPool != nil {
+		settings.ShadowPool = append([]string{}, *r.ShadowPool...)
+	}
+	return settings
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseClock parses an HH:MM time of day into an offset from midnight;
+// 24:00 denotes the end of the day.
+func parseClock(raw string) (time.Duration, error) {
+	if raw == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", raw)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func formatClock(offset time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(offset.Hours()), int(offset.Minutes())%60)
+}
+
+type setRotationRequest struct {
+	TeamName string   `json:"team_name"`
+	UserIDs  []string `json:"user_ids"`
+}
+
+func (r setRotationRequest) validate() error {
+	if r.TeamName == "" {
+		return errors.New("team_name is required")
+	}
+	seen := make(map[string]bool, len(r.UserIDs))
+	for i, userID := range r.UserIDs {
+		if userID == "" {
+			return fmt.Errorf("user_ids[%d] is required", i)
+		}
+		if seen[userID] {
+			return fmt.Errorf("user_ids[%d] is duplicated", i)
+		}
+		seen[userID] = true
+	}
+	return nil
+}
+
+type setOwnershipRequest struct {
+	TeamName string                 `json:"team_name"`
+	Rules    []ownershipRuleRequest `json:"rules"`
+}
+
+type ownershipRuleRequest struct {
+	PathPrefix string   `json:"path_prefix"`
+	UserIDs    []string `json:"user_ids"`
+}
+
+func (r setOwnershipRequest) validate() error {
+	if r.TeamName == "" {
+		return errors.New("team_name is required")
+	}
+	if len(r.Rules) > maxOwnershipRules {
+		return fmt.Errorf("at most %d rules are allowed", maxOwnershipRules)
+	}
+	for i, rule := range r.Rules {
+		if rule.PathPrefix == "" {
+			return fmt.Errorf("rules[%d].path_prefix is required", i)
+		}
+		if len(rule.UserIDs) == 0 {
+			return fmt.Errorf("rules[%d].user_ids are required", i)
+		}
+		seen := make(map[string]bool, len(rule.UserIDs))
+		for j, userID := range rule.UserIDs {
+			if userID == "" {
+				return fmt.Errorf("rules[%d].user_ids[%d] is required", i, j)
+			}
+			if seen[userID] {
+				return fmt.Errorf("rules[%d].user_ids[%d] is duplicated", i, j)
+			}
+			seen[userID] = true
+		}
+	}
+	return nil
+}
+
+func (r setOwnershipRequest) toDomain() domain.Ownership {
+	ownership := domain.Ownership{TeamName: r.TeamName}
+	for _, rule := range r.Rules {
+		ownership.Rules = append(ownership.Rules, domain.OwnershipRule{
+			PathPrefix: rule.PathPrefix,
+			UserIDs:    rule.UserIDs,
+		})
+	}
+	return ownership
+}
+
+type setTeamLeadRequest struct {
+	TeamName string `json:"team_name"`
+	UserID   string `json:"user_id"`
+	IsLead   bool   `json:"is_lead"`
+}
+
+func (r setTeamLeadRequest) validate() error {
+	if r.TeamName == "" {
+		return errors.New("team_name is required")
+	}
+	if r.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	return nil
+}
+
 type setUserActiveRequest struct {
 	UserID   string `json:"user_id"`
 	IsActive bool   `json:"is_active"`
@@ -65,10 +365,163 @@ func (r setUserActiveRequest) validate() error {
 	return nil
 }
 
+type bulkSetUserActiveRequest struct {
+	UserIDs  []string `json:"user_ids"`
+	IsActive bool     `json:"is_active"`
+	// ReassignOpenReviews hands the open reviews of deactivated users over to
+	// other members.
+	ReassignOpenReviews bool `json:"reassign_open_reviews"`
+}
+
+func (r bulkSetUserActiveRequest) validate() error {
+	if len(r.UserIDs) == 0 {
+		return errors.New("user_ids are required")
+	}
+	if len(r.UserIDs) > maxBulkUsers {
+		return fmt.Errorf("at most %d user_ids are allowed", maxBulkUsers)
+	}
+	seen := make(map[string]bool, len(r.UserIDs))
+	for i, userID := range r.UserIDs {
+		if userID == "" {
+			return fmt.Errorf("user_ids[%d] is required", i)
+		}
+		if seen[userID] {
+			return fmt.Errorf("user_ids[%d] is duplicated", i)
+		}
+		seen[userID] = true
+	}
+	if r.IsActive && r.ReassignOpenReviews {
+		return errors.New("reassign_open_reviews requires is_active to be false")
+	}
+	return nil
+}
+
+type mergeTeamsRequest struct {
+	SourceTeam string `json:"source_team"`
+	TargetTeam string `json:"target_team"`
+}
+
+func (r mergeTeamsRequest) validate() error {
+	if r.SourceTeam == "" {
+		return errors.New("source_team is required")
+	}
+	if r.TargetTeam == "" {
+		return errors.New("target_team is required")
+	}
+	return nil
+}
+
+// splitTeamRequest moves UserIDs from TeamName into the new team NewTeamName.
+type splitTeamRequest struct {
+	TeamName    string   `json:"team_name"`
+	NewTeamName string   `json:"new_team_name"`
+	UserIDs     []string `json:"user_ids"`
+}
+
+func (r splitTeamRequest) validate() error {
+	if r.TeamName == "" {
+		return errors.New("team_name is required")
+	}
+	if r.NewTeamName == "" {
+		return errors.New("new_team_name is required")
+	}
+	if len(r.UserIDs) == 0 {
+		return errors.New("user_ids are required")
+	}
+	for i, userID := range r.UserIDs {
+		if userID == "" {
+			return fmt.Errorf("user_ids[%d] is required", i)
+		}
+	}
+	return nil
+}
+
+func (r splitTeamRequest) toDomain() domain.TeamSplit {
+	return domain.TeamSplit{
+		SourceTeam: r.TeamName,
+		NewTeam:    r.NewTeamName,
+		UserIDs:    r.UserIDs,
+	}
+}
+
+type updateUserRequest struct {
+	UserID   string  `json:"user_id"`
+	Username *string `json:"username"`
+	TeamName *string `json:"team_name"`
+	// ReassignOpenReviews hands the user's open reviews in the old team over
+	// to its other members when the user is transferred.
+	ReassignOpenReviews bool `json:"reassign_open_reviews"`
+}
+
+func (r updateUserRequest) validate() error {
+	if r.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	if r.Username == nil && r.TeamName == nil {
+		return errors.New("username or team_name is required")
+	}
+	if r.Username != nil && *r.Username == "" {
+		return errors.New("username must not be empty")
+	}
+	if r.TeamName != nil && *r.TeamName == "" {
+		return errors.New("team_name must not be empty")
+	}
+	return nil
+}
+
+func (r updateUserRequest) toDomain() domain.UserUpdate {
+	return domain.UserUpdate{
+		Username: r.Username,
+		TeamName: r.TeamName,
+	}
+}
+
+// snoozeUserRequest pauses automatic assignments; Duration accepts
+// time.ParseDuration values plus day and week units, "0" lifts the snooze.
+type snoozeUserRequest struct {
+	UserID   string `json:"user_id"`
+	Duration string `json:"duration"`
+}
+
+func (r snoozeUserRequest) validate() error {
+	if r.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	if r.Duration == "" {
+		return errors.New("duration is required")
+	}
+	if _, err := parsePeriod(r.Duration); err != nil {
+		return fmt.Errorf("invalid duration %q", r.Duration)
+	}
+	return nil
+}
+
+func (r snoozeUserRequest) period() time.Duration {
+	d, _ := parsePeriod(r.Duration)
+	return d
+}
+
 type createPRRequest struct {
-	ID       string `json:"pull_request_id"`
-	Name     string `json:"pull_request_name"`
-	AuthorID string `json:"author_id"`
+	ID       string   `json:"pull_request_id"`
+	Name     string   `json:"pull_request_name"`
+	AuthorID string   `json:"author_id"`
+	Labels   []string `json:"labels"`
+	// ReviewersCount overrides the team's required reviewers; it may not
+	// exceed them.
+	ReviewersCount *int `json:"reviewers_count"`
+	// Draft creates the pull request without reviewers until it is marked
+	// ready.
+	Draft bool `json:"draft"`
+	// Files are the changed paths matched against the team's ownership
+	// rules.
+	Files []string `json:"files"`
+	// TeamName picks the reviewing team of an author unknown to the
+	// service, such as a bot; for a known author it must be their team.
+	TeamName    string `json:"team_name"`
+	Description string `json:"description"`
+	// CoAuthorIDs are the users who helped write the pull request; like
+	// the author, they are never picked as its reviewers.
+	CoAuthorIDs []string `json:"co_author_ids"`
 }
 
 func (r createPRRequest) validate() error {
@@ -81,17 +534,415 @@ func (r createPRRequest) validate() error {
 	if r.AuthorID == "" {
 		return errors.New("author_id is required")
 	}
+	if r.ReviewersCount != nil && *r.ReviewersCount < 1 {
+		return errors.New("reviewers_count must be positive")
+	}
+	if len(r.Files) > maxChangedFiles {
+		return fmt.Errorf("at most %d files are allowed", maxChangedFiles)
+	}
+	seen := make(map[string]bool, len(r.Files))
+	for i, file := range r.Files {
+		if file == "" {
+			return fmt.Errorf("files[%d] is required", i)
+		}
+		if seen[file] {
+			return fmt.Errorf("files[%d] is duplicated", i)
+		}
+		seen[file] = true
+	}
+	if len(r.CoAuthorIDs) > maxCoAuthors {
+		return fmt.Errorf("at most %d co_author_ids are allowed", maxCoAuthors)
+	}
+	coAuthors := make(map[string]bool, len(r.CoAuthorIDs))
+	for i, coAuthor := range r.CoAuthorIDs {
+		switch {
+		case coAuthor == "":
+			return fmt.Errorf("co_author_ids[%d] is required", i)
+		case coAuthor == r.AuthorID:
+			return fmt.Errorf("co_author_ids[%d] is the author", i)
+		case coAuthors[coAuthor]:
+			return fmt.Errorf("co_author_ids[%d] is duplicated", i)
+		}
+		coAuthors[coAuthor] = true
+	}
+	return nil
+}
+
+func (r createPRRequest) toDomain() domain.PullRequest {
+	pr := domain.PullRequest{
+		ID:          r.ID,
+		Name:        r.Name,
+		AuthorID:    r.AuthorID,
+		Labels:      r.Labels,
+		Files:       r.Files,
+		TeamName:    r.TeamName,
+		Description: r.Description,
+		CoAuthors:   r.CoAuthorIDs,
+	}
+	if r.ReviewersCount != nil {
+		pr.ReviewersCount = *r.ReviewersCount
+	}
+	if r.Draft {
+		pr.Status = domain.StatusDraft
+	}
+	return pr
+}
+
+type bulkCreatePRRequest struct {
+	PullRequests []createPRRequest `json:"pull_requests"`
+}
+
+func (r bulkCreatePRRequest) validate() error {
+	if len(r.PullRequests) == 0 {
+		return errors.New("pull_requests are required")
+	}
+	if len(r.PullRequests) > maxBulkCreate {
+		return fmt.Errorf("at most %d pull_requests are allowed", maxBulkCreate)
+	}
+	for i, pr := range r.PullRequests {
+		if err := pr.validate(); err != nil {
+			return fmt.Errorf("pull_requests[%d]: %w", i, err)
+		}
+	}
 	return nil
 }
 
+func (r bulkCreatePRRequest) toDomain() []domain.PullRequest {
+	prs := make([]domain.PullRequest, 0, len(r.PullRequests))
+	for _, pr := range r.PullRequests {
+		prs = append(prs, pr.toDomain())
+	}
+	return prs
+}
+
 type mergePRRequest struct {
 	ID string `json:"pull_request_id"`
+	// Force, for admins only, merges past the merge guards; Reason is
+	// required with it and kept in the event log.
+	Force  bool   `json:"force"`
+	Reason string `json:"reason"`
 }
 
 func (r mergePRRequest) validate() error {
 	if r.ID == "" {
 		return errors.New("pull_request_id is required")
 	}
+	if r.Force && r.Reason == "" {
+		return errors.New("reason is required to force a merge")
+	}
+	if !r.Force && r.Reason != "" {
+		return errors.New("reason is only accepted with force")
+	}
+	if len(r.Reason) > 500 {
+		return errors.New("reason must be at most 500 bytes")
+	}
+	return nil
+}
+
+type bulkMergePRRequest struct {
+	IDs []string `json:"pull_request_ids"`
+}
+
+func (r bulkMergePRRequest) validate() error {
+	if len(r.IDs) == 0 {
+		return errors.New("pull_request_ids are required")
+	}
+	if len(r.IDs) > maxBulkMerge {
+		return fmt.Errorf("at most %d pull_request_ids are allowed", maxBulkMerge)
+	}
+	for i, id := range r.IDs {
+		if id == "" {
+			return fmt.Errorf("pull_request_ids[%d] is empty", i)
+		}
+	}
+	return nil
+}
+
+type updatePRRequest struct {
+	PullRequestID string    `json:"pull_request_id"`
+	Name          *string   `json:"pull_request_name"`
+	Labels        *[]string `json:"labels"`
+	URL           *string   `json:"url"`
+	Priority      *string   `json:"priority"`
+	Description   *string   `json:"description"`
+}
+
+func (r updatePRRequest) validate() error {
+	if r.PullRequestID == "" {
+		return errors.New("pull_request_id is required")
+	}
+	if r.Name != nil && *r.Name == "" {
+		return errors.New("pull_request_name must not be empty")
+	}
+	if r.Priority != nil && !domain.Priority(*r.Priority).Valid() {
+		return errors.New("priority must be one of LOW, NORMAL, HIGH")
+	}
+	return nil
+}
+
+func (r updatePRRequest) toDomain() domain.PullRequestUpdate {
+	update := domain.PullRequestUpdate{
+		Name:        r.Name,
+		Labels:      r.Labels,
+		URL:         r.URL,
+		Description: r.Description,
+	}
+	if r.Priority != nil {
+		priority := domain.Priority(*r.Priority)
+		update.Priority = &priority
+	}
+	return update
+}
+
+type reviewRequest struct {
+	PullRequestID string `json:"pull_request_id"`
+	ReviewerID    string `json:"reviewer_id"`
+	Kind          string `json:"kind"`
+}
+
+func (r reviewRequest) validate() error {
+	if r.PullRequestID == "" {
+		return errors.New("pull_request_id is required")
+	}
+	if r.ReviewerID == "" {
+		return errors.New("reviewer_id is required")
+	}
+	if !domain.ReviewKind(r.Kind).Valid() {
+		return errors.New("kind must be one of COMMENT, APPROVAL")
+	}
+	return nil
+}
+
+type acceptReviewRequest struct {
+	PullRequestID string `json:"pull_request_id"`
+	UserID        string `json:"user_id"`
+}
+
+func (r acceptReviewRequest) validate() error {
+	if r.PullRequestID == "" {
+		return errors.New("pull_request_id is required")
+	}
+	if r.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	return nil
+}
+
+// completeReviewRequest marks the review of an assigned reviewer done.
+type completeReviewRequest struct {
+	PullRequestID string `json:"pull_request_id"`
+	UserID        string `json:"user_id"`
+}
+
+func (r completeReviewRequest) validate() error {
+	if r.PullRequestID == "" {
+		return errors.New("pull_request_id is required")
+	}
+	if r.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	return nil
+}
+
+// linkRequest marks, or with /pullRequest/unlink unmarks, a pull request as
+// blocked by another.
+type linkRequest struct {
+	PullRequestID string `json:"pull_request_id"`
+	BlockedBy     string `json:"blocked_by"`
+}
+
+func (r linkRequest) validate() error {
+	if r.PullRequestID == "" {
+		return errors.New("pull_request_id is required")
+	}
+	if r.BlockedBy == "" {
+		return errors.New("blocked_by is required")
+	}
+	return nil
+}
+
+// extendDeadlineRequest asks, on behalf of an assigned reviewer, for time to
+// review until Until.
+type extendDeadlineRequest struct {
+	PullRequestID string    `json:"pull_request_id"`
+	UserID        string    `json:"user_id"`
+	Until         time.Time `json:"until"`
+	Reason        string    `json:"reason"`
+}
+
+func (r extendDeadlineRequest) validate() error {
+	if r.PullRequestID == "" {
+		return errors.New("pull_request_id is required")
+	}
+	if r.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	if r.Until.IsZero() {
+		return errors.New("until is required")
+	}
+	return nil
+}
+
+type decideExtensionRequest struct {
+	ExtensionID int64 `json:"extension_id"`
+	Approve     *bool `json:"approve"`
+}
+
+func (r decideExtensionRequest) validate() error {
+	if r.ExtensionID < 1 {
+		return errors.New("extension_id is required")
+	}
+	if r.Approve == nil {
+		return errors.New("approve is required")
+	}
+	return nil
+}
+
+type archiveRequest struct {
+	OlderThanDays int `json:"older_than_days"`
+}
+
+func (r archiveRequest) validate() error {
+	if r.OlderThanDays < 1 {
+		return errors.New("older_than_days must be positive")
+	}
+	return nil
+}
+
+type integrityRequest struct {
+	// Fix repairs the issues found in the same run.
+	Fix bool `json:"fix"`
+}
+
+// snapshotRequest is a snapshot produced by /admin/export, or written by hand
+// in the same shape.
+type snapshotRequest struct {
+	Teams        []snapshotTeamRequest `json:"teams"`
+	Users        []snapshotUser        `json:"users"`
+	PullRequests []snapshotPullRequest `json:"pull_requests"`
+}
+
+type snapshotTeamRequest struct {
+	TeamName string `json:"team_name"`
+	// Settings are applied over the defaults; nil keeps the defaults.
+	Settings *teamSettingsRequest `json:"settings"`
+}
+
+// snapshotUser and snapshotPullRequest are written by the export and read
+// back by the import as they are.
+type snapshotUser struct {
+	UserID       string     `json:"user_id"`
+	Username     string     `json:"username"`
+	TeamName     string     `json:"team_name"`
+	Teams        []string   `json:"teams"`
+	IsActive     bool       `json:"is_active"`
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+}
+
+type snapshotPullRequest struct {
+	ID                string             `json:"pull_request_id"`
+	Name              string             `json:"pull_request_name"`
+	AuthorID          string             `json:"author_id"`
+	Status            string             `json:"status"`
+	AssignedReviewers []string           `json:"assigned_reviewers"`
+	ShadowReviewer    string             `json:"shadow_reviewer_id,omitempty"`
+	Reviewers         []snapshotReviewer `json:"reviewers,omitempty"`
+	CoAuthorIDs       []string           `json:"co_author_ids,omitempty"`
+	ReviewersCount    int                `json:"reviewers_count"`
+	Reassignments     int                `json:"reassignments"`
+	Labels            []string           `json:"labels"`
+	URL               string             `json:"url,omitempty"`
+	Priority          string             `json:"priority"`
+	Description       string             `json:"description,omitempty"`
+	Files             []string           `json:"files"`
+	CreatedAt         time.Time          `json:"created_at"`
+	MergedAt          *time.Time         `json:"merged_at"`
+}
+
+// snapshotReviewer keeps the place of a reviewer. Snapshots without
+// reviewers place them in the order of assigned_reviewers.
+type snapshotReviewer struct {
+	UserID     string    `json:"user_id"`
+	Role       string    `json:"role"`
+	AssignedAt time.Time `json:"assigned_at"`
+}
+
+// toDomain checks the settings of every team the way /team/settings does.
+// References between records are left to the service, which reports them
+// all at once.
+func (r snapshotRequest) toDomain() (domain.Snapshot, error) {
+	var snapshot domain.Snapshot
+	for i, team := range r.Teams {
+		snapshot.Teams = append(snapshot.Teams, team.TeamName)
+		if team.Settings == nil {
+			continue
+		}
+		settings := *team.Settings
+		settings.TeamName = team.TeamName
+		if err := settings.validate(); err != nil {
+			return domain.Snapshot{}, fmt.Errorf("teams[%d].settings: %w", i, err)
+		}
+		snapshot.Settings = append(snapshot.Settings, settings.apply(domain.DefaultTeamSettings(team.TeamName)))
+	}
+	for _, user := range r.Users {
+		snapshot.Users = append(snapshot.Users, domain.User{
+			ID:           user.UserID,
+			Username:     user.Username,
+			TeamName:     user.TeamName,
+			Teams:        user.Teams,
+			IsActive:     user.IsActive,
+			SnoozedUntil: user.SnoozedUntil,
+		})
+	}
+	for _, pr := range r.PullRequests {
+		var reviewers []domain.Reviewer
+		for _, reviewer := range pr.Reviewers {
+			reviewers = append(reviewers, domain.Reviewer{
+				UserID:     reviewer.UserID,
+				Role:       domain.ReviewerRole(reviewer.Role),
+				AssignedAt: reviewer.AssignedAt,
+			})
+		}
+		snapshot.PullRequests = append(snapshot.PullRequests, domain.PullRequest{
+			ID:                pr.ID,
+			Name:              pr.Name,
+			AuthorID:          pr.AuthorID,
+			Status:            domain.PRStatus(pr.Status),
+			AssignedReviewers: pr.AssignedReviewers,
+			ShadowReviewer:    pr.ShadowReviewer,
+			Reviewers:         reviewers,
+			CoAuthors:         pr.CoAuthorIDs,
+			ReviewersCount:    pr.ReviewersCount,
+			Reassignments:     pr.Reassignments,
+			Labels:            pr.Labels,
+			URL:               pr.URL,
+			Priority:          domain.Priority(pr.Priority),
+			Description:       pr.Description,
+			Files:             pr.Files,
+			CreatedAt:         pr.CreatedAt,
+			MergedAt:          pr.MergedAt,
+		})
+	}
+	return snapshot, nil
+}
+
+type assignReviewersRequest struct {
+	PullRequestID string   `json:"pull_request_id"`
+	ReviewerIDs   []string `json:"reviewer_ids"`
+}
+
+func (r assignReviewersRequest) validate() error {
+	if r.PullRequestID == "" {
+		return errors.New("pull_request_id is required")
+	}
+	if r.ReviewerIDs == nil {
+		return errors.New("reviewer_ids is required")
+	}
+	for i, reviewerID := range r.ReviewerIDs {
+		if reviewerID == "" {
+			return fmt.Errorf("reviewer_ids[%d] is required", i)
+		}
+	}
 	return nil
 }
 
@@ -109,3 +960,139 @@ func (r reassignRequest) validate() error {
 	}
 	return nil
 }
+
+type addReviewerRequest struct {
+	PullRequestID string `json:"pull_request_id"`
+	// UserID is optional; without it a reviewer is picked automatically.
+	UserID string `json:"user_id"`
+}
+
+func (r addReviewerRequest) validate() error {
+	if r.PullRequestID == "" {
+		return errors.New("pull_request_id is required")
+	}
+	return nil
+}
+
+type notificationRequest struct {
+	UserID  string `json:"user_id"`
+	Channel string `json:"channel"`
+	Address string `json:"address"`
+	// DigestHours batches the notifications into a summary sent every that
+	// many hours; zero sends them one by one.
+	DigestHours int `json:"digest_hours"`
+}
+
+func (r notificationRequest) validate() error {
+	if r.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	if !domain.NotifyChannel(r.Channel).Valid() {
+		return errors.New("channel must be one of none, slack, email")
+	}
+	if r.DigestHours < 0 || r.DigestHours > 24*7 {
+		return errors.New("digest_hours must be between 0 and 168")
+	}
+	return nil
+}
+
+func (r notificationRequest) toDomain() domain.NotificationPreference {
+	return domain.NotificationPreference{
+		UserID:  r.UserID,
+		Channel: domain.NotifyChannel(r.Channel),
+		Address: r.Address,
+		Digest:  time.Duration(r.DigestHours) * time.Hour,
+	}
+}
+
+type addIdentityRequest struct {
+	UserID     string `json:"user_id"`
+	Provider   string `json:"provider"`
+	ExternalID string `json:"external_id"`
+}
+
+func (r addIdentityRequest) validate() error {
+	if r.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	if !domain.IdentityProvider(r.Provider).Valid() {
+		return errors.New("provider must be one of github, gitlab, email")
+	}
+	if strings.TrimSpace(r.ExternalID) == "" {
+		return errors.New("external_id is required")
+	}
+	return nil
+}
+
+func (r addIdentityRequest) toDomain() domain.Identity {
+	return domain.Identity{
+		Provider:   domain.IdentityProvider(r.Provider),
+		ExternalID: r.ExternalID,
+		UserID:     r.UserID,
+	}
+}
+
+type webhookRequest struct {
+	ID     int64    `json:"id"`
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+func (r webhookRequest) validate(update bool) error {
+	if update && r.ID <= 0 {
+		return errors.New("id is required")
+	}
+	if r.URL == "" {
+		return errors.New("url is required")
+	}
+	if len(r.Events) == 0 {
+		return errors.New("events is required")
+	}
+	for i, event := range r.Events {
+		if !domain.WebhookEvent(event).Valid() {
+			return fmt.Errorf("events[%d] must be one of pr.created, pr.merged, reviewer.reassigned", i)
+		}
+	}
+	return nil
+}
+
+func (r webhookRequest) toDomain() domain.Webhook {
+	events := make([]domain.WebhookEvent, 0, len(r.Events))
+	for _, event := range r.Events {
+		events = append(events, domain.WebhookEvent(event))
+	}
+	return domain.Webhook{
+		ID:     r.ID,
+		URL:    r.URL,
+		Secret: r.Secret,
+		Events: events,
+	}
+}
+
+type teamTokenRequest struct {
+	TeamName string `json:"team_name"`
+	Name     string `json:"name"`
+	UserID   string `json:"user_id"`
+}
+
+func (r teamTokenRequest) validate() error {
+	if r.TeamName == "" {
+		return errors.New("team_name is required")
+	}
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+type maintenanceRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+func (r maintenanceRequest) validate() error {
+	if r.Enabled == nil {
+		return errors.New("enabled is required")
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package httptransport
+
+import (
+	"net/http"
+	"strings"
+
+	"Avito2025/internal/warnings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/gorilla/websocket"
+)
+
+type responseMeta struct {
+	RequestID  string             `json:"request_id,omitempty"`
+	Pagination *paginationPayload `json:"pagination,omitempty"`
+	Warnings   []warningPayload   `json:"warnings,omitempty"`
+}
+
+type warningPayload struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+type paginationPayload struct {
+	Limit      int    `json:"limit"`
+	NextCursor *int64 `json:"next_cursor,omitempty"`
+}
+
+// envelopePayload is the shape of every v2 response: the v1 payload under
+// data, or the error, next to the request metadata.
+type envelopePayload struct {
+	Data  any           `json:"data,omitempty"`
+	Error *errorPayload `json:"error,omitempty"`
+	Meta  responseMeta  `json:"meta"`
+}
+
+// envelopeWriter marks a response to be wrapped in the envelope. The respond
+// helpers find it through the Unwrap chain of writers added by later
+// middleware.
+type envelopeWriter struct {
+	http.ResponseWriter
+	requestID  string
+	pagination *paginationPayload
+	warnings   *warnings.List
+}
+
+func (w *envelopeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// envelope wraps responses under prefix, including errors written by the
+// middleware that runs after it. Websocket upgrades are left alone: streams
+// have no envelope and the upgrade needs the connection's own writer.
+func envelope(prefix string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != prefix && !strings.HasPrefix(r.URL.Path, prefix+"/") || websocket.IsWebSocketUpgrade(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, list := warnings.With(r.Context())
+			next.ServeHTTP(&envelopeWriter{
+				ResponseWriter: w,
+				requestID:      middleware.GetReqID(ctx),
+				warnings:       list,
+			}, r.WithContext(ctx))
+		})
+	}
+}
+
+func envelopeOf(w http.ResponseWriter) *envelopeWriter {
+	for {
+		switch v := w.(type) {
+		case *envelopeWriter:
+			return v
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// enveloped returns payload as it is sent on w: wrapped for v2, unchanged for
+// v1. Warnings are reported only next to data, as a failed request has
+// nothing to warn about.
+func enveloped(w http.ResponseWriter, payload any) any {
+	env := envelopeOf(w)
+	if env == nil {
+		return payload
+	}
+	meta := responseMeta{RequestID: env.requestID, Pagination: env.pagination}
+	if resp, ok := payload.(errorResponse); ok {
+		return envelopePayload{Error: &resp.Error, Meta: meta}
+	}
+	for _, warning := range env.warnings.Items() {
+		meta.Warnings = append(meta.Warnings, warningPayload(warning))
+	}
+	return envelopePayload{Data: payload, Meta: meta}
+}
+
+// respondPage writes a page of a listing. v2 reports the pagination in the
+// envelope's meta; v1 keeps the cursor in the payload itself.
+func respondPage(w http.ResponseWriter, status int, payload map[string]any, page paginationPayload) {
+	if env := envelopeOf(w); env != nil {
+		env.pagination = &page
+	} else if page.NextCursor != nil {
+		payload["next_cursor"] = *page.NextCursor
+	}
+	respondJSON(w, status, payload)
+}
@@ -1,58 +1,120 @@
 package httptransport
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"Avito2025/internal/config"
 	"Avito2025/internal/domain"
+	"Avito2025/internal/metrics"
+	"Avito2025/internal/seed"
 	"Avito2025/internal/service"
+	"Avito2025/internal/signature"
+	"Avito2025/internal/writequeue"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+	defaultTeamsLimit   = 50
+	maxTeamsLimit       = 500
+	maxBulkCreate       = 100
+	maxBulkUsers        = 100
+	maxBulkMerge        = 100
+	maxChangedFiles     = 1000
+	maxCoAuthors        = 10
+	maxOwnershipRules   = 100
+	defaultStatsPeriod  = 30 * 24 * time.Hour
+	maxStatsPeriod      = 365 * 24 * time.Hour
+	maxDailyStatsDays   = 366
+	defaultDeliveries   = 50
+	maxDeliveries       = 500
+	maintenancePath     = "/admin/maintenance"
+	exportPath          = "/admin/export"
+	callerHeader        = "X-User-ID"
+	waitReviewsPath     = "/users/getReview/wait"
+	defaultReviewWait   = 30 * time.Second
+	maxReviewWait       = 60 * time.Second
+	defaultErrorWindow  = 15 * time.Minute
+	defaultHotPRs       = 3
+)
+
 type Handler struct {
 	service service.Service
+	cfg     config.HTTPConfig
+	health  healthCache
+	writes  *writequeue.Queue
 }
 
-func NewHandler(svc service.Service) *Handler {
+func NewHandler(svc service.Service, cfg config.HTTPConfig) *Handler {
 	return &Handler{
 		service: svc,
+		cfg:     cfg,
 	}
 }
 
 func (h *Handler) Router() http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
+	r.Use(propagateRequestID)
+	r.Use(countRequests)
+	r.Use(envelope("/v2"))
+	if h.cfg.JSONNaming == config.JSONNamingSnake {
+		r.Use(snakeCase)
+	}
+	r.Use(limitInFlight(h.cfg.MaxInFlight))
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Logger)
+	if h.cfg.CompressionLevel > 0 {
+		r.Use(compressResponses(h.cfg.CompressionLevel))
+	}
+	r.Use(timeout(h.cfg.ReadTimeout, h.cfg.WriteTimeout))
+	// Behind the timeout, so that injected latency can run into it.
+	if h.cfg.Chaos.Enabled {
+		r.Use(injectFaults(h.cfg.Chaos))
+	}
+	r.Use(jsonBody(h.cfg.MaxBodyBytes))
+	if h.cfg.SigningSecret != "" {
+		r.Use(verifySignatures(signature.NewVerifier(h.cfg.SigningSecret, h.cfg.SignatureMaxAge), h.cfg.RequireSignature))
+	}
+	// Queued ahead of the maintenance check, which needs the storage.
+	if h.writes != nil {
+		r.Use(h.queueWrites)
+	}
+	r.Use(readOnly(h.service.Maintenance))
 
-	r.Route("/team", func(r chi.Router) {
-		r.Post("/add", h.CreateTeam)
-		r.Get("/get", h.GetTeam)
-	})
-
-	r.Route("/users", func(r chi.Router) {
-		r.Post("/setIsActive", h.SetUserActive)
-		r.Get("/getReview", h.GetUserReviews)
-	})
-
-	r.Route("/pullRequest", func(r chi.Router) {
-		r.Post("/create", h.CreatePullRequest)
-		r.Post("/merge", h.MergePullRequest)
-		r.Post("/reassign", h.ReassignReviewer)
-	})
+	// Legacy unversioned paths stay as aliases of /v1 for a deprecation window.
+	r.Mount("/v1", h.v1Routes())
+	r.Mount("/v2", h.v2Routes())
+	if !h.cfg.DisableLegacyRoutes {
+		r.Mount("/", deprecated("/v1")(h.v1Routes()))
+	}
 
+	r.Handle("/metrics", metrics.Handler())
+	r.Get("/ui", h.Dashboard)
 	r.Get("/health", h.Health)
+	r.Head("/health", h.Health)
+	r.Get("/health/ready", h.Ready)
+	r.Head("/health/ready", h.Ready)
 
 	return r
 }
 
 func (h *Handler) CreateTeam(w http.ResponseWriter, r *http.Request) {
 	var req teamRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+	if !h.decodeBody(w, r, &req) {
 		return
 	}
 
@@ -61,38 +123,77 @@ func (h *Handler) CreateTeam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	team := req.toDomain()
-	created, err := h.service.CreateTeam(r.Context(), team)
+	created, handovers, err := h.service.CreateTeam(r.Context(), req.toDomain(), req.conflict())
 	if err != nil {
 		h.handleDomainError(w, err)
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, map[string]any{
-		"team": mapTeam(created),
-	})
+	payload := map[string]any{
+		"team": mapTeam(created, h.linksFor(r)),
+	}
+	if req.Transfer {
+		payload["reassigned"] = mapReviewHandovers(handovers)
+	}
+	respondJSON(w, http.StatusCreated, payload)
 }
 
+// GetTeam answers with the bare team, unlike the other endpoints; v2 fixes
+// that in GetTeamV2.
 func (h *Handler) GetTeam(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.loadTeam(w, r)
+	if !ok {
+		return
+	}
+	respondJSONWithETag(w, r, http.StatusOK, mapTeam(team, h.linksFor(r)))
+}
+
+func (h *Handler) GetTeamV2(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.loadTeam(w, r)
+	if !ok {
+		return
+	}
+	respondJSONWithETag(w, r, http.StatusOK, map[string]any{
+		"team": mapTeam(team, h.linksFor(r)),
+	})
+}
+
+func (h *Handler) loadTeam(w http.ResponseWriter, r *http.Request) (domain.Team, bool) {
 	teamName := r.URL.Query().Get("team_name")
 	if teamName == "" {
 		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "team_name is required")
-		return
+		return domain.Team{}, false
 	}
 
 	team, err := h.service.GetTeam(r.Context(), teamName)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return domain.Team{}, false
+	}
+	return team, true
+}
+
+func (h *Handler) GetTeamSettings(w http.ResponseWriter, r *http.Request) {
+	teamName := r.URL.Query().Get("team_name")
+	if teamName == "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "team_name is required")
+		return
+	}
+
+	settings, err := h.service.GetTeamSettings(r.Context(), teamName)
 	if err != nil {
 		h.handleDomainError(w, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, mapTeam(team))
+	respondJSON(w, http.StatusOK, map[string]any{
+		"settings": mapTeamSettings(settings),
+	})
 }
 
-func (h *Handler) SetUserActive(w http.ResponseWriter, r *http.Request) {
-	var req setUserActiveRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+func (h *Handler) UpdateTeamSettings(w http.ResponseWriter, r *http.Request) {
+	var req teamSettingsRequest
+	if !h.decodeBody(w, r, &req) {
 		return
 	}
 
@@ -101,21 +202,32 @@ func (h *Handler) SetUserActive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.service.SetUserActive(r.Context(), req.UserID, req.IsActive)
+	if _, ok := h.authorizeLead(w, r, func(ctx context.Context, callerID string) error {
+		return h.service.AuthorizeTeamLead(ctx, callerID, req.TeamName)
+	}); !ok {
+		return
+	}
+
+	current, err := h.service.GetTeamSettings(r.Context(), req.TeamName)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	settings, err := h.service.UpdateTeamSettings(r.Context(), req.apply(current))
 	if err != nil {
 		h.handleDomainError(w, err)
 		return
 	}
 
 	respondJSON(w, http.StatusOK, map[string]any{
-		"user": mapUser(user),
+		"settings": mapTeamSettings(settings),
 	})
 }
 
-func (h *Handler) CreatePullRequest(w http.ResponseWriter, r *http.Request) {
-	var req createPRRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+func (h *Handler) SetRotation(w http.ResponseWriter, r *http.Request) {
+	var req setRotationRequest
+	if !h.decodeBody(w, r, &req) {
 		return
 	}
 
@@ -124,25 +236,47 @@ func (h *Handler) CreatePullRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pr, err := h.service.CreatePullRequest(r.Context(), domain.PullRequest{
-		ID:       req.ID,
-		Name:     req.Name,
-		AuthorID: req.AuthorID,
+	if _, ok := h.authorizeLead(w, r, func(ctx context.Context, callerID string) error {
+		return h.service.AuthorizeTeamLead(ctx, callerID, req.TeamName)
+	}); !ok {
+		return
+	}
+
+	rotation, err := h.service.SetRotation(r.Context(), domain.Rotation{
+		TeamName: req.TeamName,
+		UserIDs:  req.UserIDs,
 	})
 	if err != nil {
 		h.handleDomainError(w, err)
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, map[string]any{
-		"pr": mapPullRequest(pr),
+	respondJSON(w, http.StatusOK, map[string]any{
+		"rotation": mapRotation(rotation),
 	})
 }
 
-func (h *Handler) MergePullRequest(w http.ResponseWriter, r *http.Request) {
-	var req mergePRRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+func (h *Handler) GetRotation(w http.ResponseWriter, r *http.Request) {
+	teamName := r.URL.Query().Get("team_name")
+	if teamName == "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "team_name is required")
+		return
+	}
+
+	rotation, err := h.service.GetRotation(r.Context(), teamName)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"rotation": mapRotation(rotation),
+	})
+}
+
+func (h *Handler) SetOwnership(w http.ResponseWriter, r *http.Request) {
+	var req setOwnershipRequest
+	if !h.decodeBody(w, r, &req) {
 		return
 	}
 
@@ -151,21 +285,45 @@ func (h *Handler) MergePullRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pr, err := h.service.MergePullRequest(r.Context(), req.ID)
+	if _, ok := h.authorizeLead(w, r, func(ctx context.Context, callerID string) error {
+		return h.service.AuthorizeTeamLead(ctx, callerID, req.TeamName)
+	}); !ok {
+		return
+	}
+
+	ownership, err := h.service.SetOwnership(r.Context(), req.toDomain())
 	if err != nil {
 		h.handleDomainError(w, err)
 		return
 	}
 
 	respondJSON(w, http.StatusOK, map[string]any{
-		"pr": mapPullRequest(pr),
+		"ownership": mapOwnership(ownership),
 	})
 }
 
-func (h *Handler) ReassignReviewer(w http.ResponseWriter, r *http.Request) {
-	var req reassignRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+func (h *Handler) GetOwnership(w http.ResponseWriter, r *http.Request) {
+	teamName := r.URL.Query().Get("team_name")
+	if teamName == "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "team_name is required")
+		return
+	}
+
+	ownership, err := h.service.GetOwnership(r.Context(), teamName)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"ownership": mapOwnership(ownership),
+	})
+}
+
+// SetTeamLead grants or revokes the lead role; only admins may call it.
+func (h *Handler) SetTeamLead(w http.ResponseWriter, r *http.Request) {
+	var req setTeamLeadRequest
+	if !h.decodeBody(w, r, &req) {
 		return
 	}
 
@@ -174,67 +332,1863 @@ func (h *Handler) ReassignReviewer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pr, replacedBy, err := h.service.ReassignReviewer(r.Context(), req.PullRequestID, req.OldUserID)
+	leads, err := h.service.SetTeamLead(r.Context(), req.TeamName, req.UserID, req.IsLead)
 	if err != nil {
 		h.handleDomainError(w, err)
 		return
 	}
 
 	respondJSON(w, http.StatusOK, map[string]any{
-		"pr":          mapPullRequest(pr),
-		"replaced_by": replacedBy,
+		"team_name": req.TeamName,
+		"leads":     append([]string{}, leads...),
 	})
 }
 
-func (h *Handler) GetUserReviews(w http.ResponseWriter, r *http.Request) {
-	userID := r.URL.Query().Get("user_id")
-	if userID == "" {
+// ListTeams pages through the teams. The cursor is the number of teams
+// already returned; next_cursor is omitted on the last page.
+func (h *Handler) ListTeams(w http.ResponseWriter, r *http.Request) {
+	offset, limit, ok := parseOffsetPage(w, r)
+	if !ok {
+		return
+	}
+
+	// One extra row tells whether another page follows.
+	teams, err := h.service.ListTeams(r.Context(), r.URL.Query().Get("prefix"), int(offset), limit+1)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	page := paginationPayload{Limit: limit}
+	if len(teams) > limit {
+		teams = teams[:limit]
+		next := offset + int64(limit)
+		page.NextCursor = &next
+	}
+	result := make([]teamSummaryPayload, 0, len(teams))
+	for _, team := range teams {
+		result = append(result, mapTeamSummary(team))
+	}
+
+	respondPage(w, http.StatusOK, map[string]any{
+		"teams": result,
+	}, page)
+}
+
+// ListUsers pages through the users, optionally narrowed to the members of
+// team_name, by is_active and by a username_prefix, like ListTeams.
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := domain.UserFilter{
+		TeamName:       query.Get("team_name"),
+		UsernamePrefix: query.Get("username_prefix"),
+	}
+	if raw := query.Get("is_active"); raw != "" {
+		isActive, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "BAD_REQUEST", "is_active must be true or false")
+			return
+		}
+		filter.IsActive = &isActive
+	}
+	offset, limit, ok := parseOffsetPage(w, r)
+	if !ok {
+		return
+	}
+
+	users, err := h.service.ListUsers(r.Context(), filter, int(offset), limit+1)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	page := paginationPayload{Limit: limit}
+	if len(users) > limit {
+		users = users[:limit]
+		next := offset + int64(limit)
+		page.NextCursor = &next
+	}
+	l := h.linksFor(r)
+	result := make([]userSummaryPayload, 0, len(users))
+	for _, user := range users {
+		result = append(result, mapUserSummary(user, l))
+	}
+
+	respondPage(w, http.StatusOK, map[string]any{
+		"users": result,
+	}, page)
+}
+
+// GetAssignmentHistory pages through the assignments and removals of the
+// user as a reviewer, oldest first, optionally within from..to (RFC 3339,
+// to excluded), like ListTeams.
+func (h *Handler) GetAssignmentHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := domain.AssignmentHistoryFilter{ReviewerID: query.Get("user_id")}
+	if filter.ReviewerID == "" {
 		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "user_id is required")
 		return
 	}
+	bounds := []struct {
+		param string
+		value *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}}
+	for _, bound := range bounds {
+		raw := query.Get(bound.param)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "BAD_REQUEST", bound.param+" must be an RFC 3339 timestamp")
+			return
+		}
+		*bound.value = parsed
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "to must not precede from")
+		return
+	}
+	offset, limit, ok := parseOffsetPage(w, r)
+	if !ok {
+		return
+	}
 
-	prs, err := h.service.ListUserReviews(r.Context(), userID)
+	changes, err := h.service.AssignmentHistory(r.Context(), filter, int(offset), limit+1)
 	if err != nil {
 		h.handleDomainError(w, err)
 		return
 	}
 
-	result := make([]map[string]any, 0, len(prs))
-	for _, pr := range prs {
-		result = append(result, mapPullRequestShort(pr))
+	page := paginationPayload{Limit: limit}
+	if len(changes) > limit {
+		changes = changes[:limit]
+		next := offset + int64(limit)
+		page.NextCursor = &next
+	}
+
+	respondPage(w, http.StatusOK, map[string]any{
+		"user_id": filter.ReviewerID,
+		"history": mapReviewerHistory(changes),
+	}, page)
+}
+
+func (h *Handler) GetTeamLeads(w http.ResponseWriter, r *http.Request) {
+	teamName := r.URL.Query().Get("team_name")
+	if teamName == "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "team_name is required")
+		return
+	}
+
+	leads, err := h.service.ListTeamLeads(r.Context(), teamName)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
 	}
 
 	respondJSON(w, http.StatusOK, map[string]any{
-		"user_id":       userID,
-		"pull_requests": result,
+		"team_name": teamName,
+		"leads":     append([]string{}, leads...),
 	})
 }
 
-func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
-	if err := h.service.Health(r.Context()); err != nil {
-		respondError(w, http.StatusInternalServerError, "UNHEALTHY", err.Error())
+// authorizeLead lets a request changing a team through when team leads are
+// not enforced, when it carries the admin token, or when check accepts the
+// member its team token was issued to. It returns the caller: that member or,
+// for admins and while leads are not enforced, the user named by the
+// X-User-ID header, which is never trusted to authorize anything. Otherwise
+// it writes a 401 or 403 and returns false.
+func (h *Handler) authorizeLead(w http.ResponseWriter, r *http.Request, check func(ctx context.Context, callerID string) error) (string, bool) {
+	if !h.cfg.EnforceTeamLeads || hasAdminToken(r, h.cfg.AdminToken) {
+		return r.Header.Get(callerHeader), true
+	}
+	tokenHash, ok := teamTokenHash(r)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "UNAUTHORIZED", "team token of a lead required")
+		return "", false
+	}
+	callerID, err := h.service.TeamTokenUser(r.Context(), tokenHash)
+	if errors.Is(err, domain.ErrTeamTokenNotFound) {
+		respondError(w, http.StatusUnauthorized, "UNAUTHORIZED", "invalid team token")
+		return "", false
+	}
+	if err == nil {
+		err = check(r.Context(), callerID)
+	}
+	if err != nil {
+		h.handleDomainError(w, err)
+		return "", false
+	}
+	return callerID, true
+}
+
+// authorizeAuthors lets a request creating pull requests through when it
+// carries the admin token, or a team token of the team of every author. A
+// request without a bearer credential passes unless team tokens are
+// required; one with an unknown token gets a 401.
+func (h *Handler) authorizeAuthors(w http.ResponseWriter, r *http.Request, prs []domain.PullRequest) bool {
+	if hasAdminToken(r, h.cfg.AdminToken) {
+		return true
+	}
+	tokenHash, ok := teamTokenHash(r)
+	if !ok {
+		if h.cfg.RequireTeamTokens {
+			respondError(w, http.StatusUnauthorized, "UNAUTHORIZED", "team token required")
+			return false
+		}
+		return true
+	}
+
+	err := h.service.AuthorizeTeamToken(r.Context(), tokenHash, prs)
+	if errors.Is(err, domain.ErrTeamTokenNotFound) {
+		respondError(w, http.StatusUnauthorized, "UNAUTHORIZED", "invalid team token")
+		return false
+	}
+	if err != nil {
+		h.handleDomainError(w, err)
+		return false
+	}
+	return true
+}
+
+// GetTeamHistory lists the team's membership changes: joins and status
+// changes of its members, oldest first.
+func (h *Handler) GetTeamHistory(w http.ResponseWriter, r *http.Request) {
+	teamName := r.URL.Query().Get("team_name")
+	if teamName == "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "team_name is required")
+		return
+	}
+
+	history, err := h.service.TeamHistory(r.Context(), teamName)
+	if err != nil {
+		h.handleDomainError(w, err)
 		return
 	}
-	respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"team_name": teamName,
+		"history":   mapMembershipHistory(history),
+	})
 }
 
-func (h *Handler) handleDomainError(w http.ResponseWriter, err error) {
-	switch err {
-	case nil:
-		return
-	case domain.ErrTeamExists:
-		respondError(w, http.StatusBadRequest, "TEAM_EXISTS", "team_name already exists")
-	case domain.ErrPRExists:
-		respondError(w, http.StatusConflict, "PR_EXISTS", "pull request already exists")
-	case domain.ErrPRMerged:
-		respondError(w, http.StatusConflict, "PR_MERGED", "cannot modify merged pull request")
-	case domain.ErrReviewerNotFound:
-		respondError(w, http.StatusConflict, "NOT_ASSIGNED", "reviewer is not assigned to this pull request")
-	case domain.ErrNoReplacement:
-		respondError(w, http.StatusConflict, "NO_CANDIDATE", "no active replacement candidate in team")
-	case domain.ErrTeamNotFound, domain.ErrUserNotFound, domain.ErrPullRequestNotFound:
-		respondError(w, http.StatusNotFound, "NOT_FOUND", "resource not found")
-	default:
-		respondError(w, http.StatusInternalServerError, "INTERNAL", "internal server error")
+// MergeTeams folds the source team into the target team and answers with the
+// merged team. With leads enforced, the caller must lead both teams.
+func (h *Handler) MergeTeams(w http.ResponseWriter, r *http.Request) {
+	var req mergeTeamsRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	if _, ok := h.authorizeLead(w, r, func(ctx context.Context, callerID string) error {
+		if err := h.service.AuthorizeTeamLead(ctx, callerID, req.SourceTeam); err != nil {
+			return err
+		}
+		return h.service.AuthorizeTeamLead(ctx, callerID, req.TargetTeam)
+	}); !ok {
+		return
+	}
+
+	merged, err := h.service.MergeTeams(r.Context(), req.SourceTeam, req.TargetTeam)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"team": mapTeam(merged, h.linksFor(r)),
+	})
+}
+
+// SplitTeam moves selected members into a new team and answers with both the
+// remaining and the new team.
+func (h *Handler) SplitTeam(w http.ResponseWriter, r *http.Request) {
+	var req splitTeamRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	if _, ok := h.authorizeLead(w, r, func(ctx context.Context, callerID string) error {
+		return h.service.AuthorizeTeamLead(ctx, callerID, req.TeamName)
+	}); !ok {
+		return
+	}
+
+	remaining, created, err := h.service.SplitTeam(r.Context(), req.toDomain())
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	links := h.linksFor(r)
+	respondJSON(w, http.StatusCreated, map[string]any{
+		"team":     mapTeam(remaining, links),
+		"new_team": mapTeam(created, links),
+	})
+}
+
+func (h *Handler) SetUserActive(w http.ResponseWriter, r *http.Request) {
+	var req setUserActiveRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	user, err := h.service.SetUserActive(r.Context(), req.UserID, req.IsActive)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"user": mapUser(user, h.linksFor(r)),
+	})
+}
+
+// BulkSetUserActive changes the status of several users at once, optionally
+// handing the open reviews of deactivated users over to other members.
+func (h *Handler) BulkSetUserActive(w http.ResponseWriter, r *http.Request) {
+	var req bulkSetUserActiveRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	results, err := h.service.BulkSetUserActive(r.Context(), req.UserIDs, req.IsActive, req.ReassignOpenReviews)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	links := h.linksFor(r)
+	items := make([]userResultPayload, 0, len(results))
+	for _, result := range results {
+		items = append(items, mapUserResult(result, req.ReassignOpenReviews, links))
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"results": items,
+	})
+}
+
+// UpdateUser renames a user or transfers them to another team, optionally
+// handing their open reviews in the old team over to its members.
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	var req updateUserRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	user, handovers, err := h.service.UpdateUser(r.Context(), req.UserID, req.toDomain(), req.ReassignOpenReviews)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"user":       mapUser(user, h.linksFor(r)),
+		"reassigned": mapReviewHandovers(handovers),
+	})
+}
+
+func (h *Handler) SnoozeUser(w http.ResponseWriter, r *http.Request) {
+	var req snoozeUserRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	user, err := h.service.SnoozeUser(r.Context(), req.UserID, req.period())
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"user": mapUser(user, h.linksFor(r)),
+	})
+}
+
+func (h *Handler) SetNotifications(w http.ResponseWriter, r *http.Request) {
+	var req notificationRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	pref, err := h.service.SetNotificationPreference(r.Context(), req.toDomain())
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"notifications": mapNotification(pref),
+	})
+}
+
+func (h *Handler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "user_id is required")
+		return
+	}
+
+	pref, err := h.service.GetNotificationPreference(r.Context(), userID)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"notifications": mapNotification(pref),
+	})
+}
+
+func (h *Handler) AddIdentity(w http.ResponseWriter, r *http.Request) {
+	var req addIdentityRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	identity, err := h.service.AddIdentity(r.Context(), req.toDomain())
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]any{
+		"identity": mapIdentity(identity),
+	})
+}
+
+func (h *Handler) GetIdentities(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "user_id is required")
+		return
+	}
+
+	identities, err := h.service.ListIdentities(r.Context(), userID)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	result := make([]identityPayload, 0, len(identities))
+	for _, identity := range identities {
+		result = append(result, mapIdentity(identity))
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"user_id":    userID,
+		"identities": result,
+	})
+}
+
+func (h *Handler) ResolveIdentity(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	provider := domain.IdentityProvider(query.Get("provider"))
+	if !provider.Valid() {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "provider must be one of github, gitlab, email")
+		return
+	}
+	externalID := query.Get("external_id")
+	if strings.TrimSpace(externalID) == "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "external_id is required")
+		return
+	}
+
+	user, err := h.service.ResolveIdentity(r.Context(), provider, externalID)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"user": mapUser(user, h.linksFor(r)),
+	})
+}
+
+func (h *Handler) GetPullRequest(w http.ResponseWriter, r *http.Request) {
+	prID := r.URL.Query().Get("pull_request_id")
+	if prID == "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "pull_request_id is required")
+		return
+	}
+
+	pr, err := h.service.GetPullRequest(r.Context(), prID)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSONWithETag(w, r, http.StatusOK, map[string]any{
+		"pr": mapPullRequest(pr, h.linksFor(r)),
+	})
+}
+
+// GetAssignmentTrace explains how the pull request's reviewers were picked.
+func (h *Handler) GetAssignmentTrace(w http.ResponseWriter, r *http.Request) {
+	prID := r.URL.Query().Get("pull_request_id")
+	if prID == "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "pull_request_id is required")
+		return
+	}
+
+	decisions, err := h.service.AssignmentTrace(r.Context(), prID)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	result := make([]assignmentDecisionPayload, 0, len(decisions))
+	for _, decision := range decisions {
+		result = append(result, mapAssignmentDecision(decision))
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"pull_request_id": prID,
+		"decisions":       result,
+	})
+}
+
+func (h *Handler) CreatePullRequest(w http.ResponseWriter, r *http.Request) {
+	var req createPRRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	pr := req.toDomain()
+	if !h.authorizeAuthors(w, r, []domain.PullRequest{pr}) {
+		return
+	}
+
+	pr, err := h.service.CreatePullRequest(r.Context(), pr)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]any{
+		"pr": mapPullRequest(pr, h.linksFor(r)),
+	})
+}
+
+func (h *Handler) BulkCreatePullRequests(w http.ResponseWriter, r *http.Request) {
+	var req bulkCreatePRRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	prs := req.toDomain()
+	if !h.authorizeAuthors(w, r, prs) {
+		return
+	}
+
+	results, err := h.service.BulkCreatePullRequests(r.Context(), prs)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	links := h.linksFor(r)
+	items := make([]bulkResultPayload, 0, len(results))
+	for _, result := range results {
+		items = append(items, mapBulkResult(result, links))
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"results": items,
+	})
+}
+
+// UpdatePullRequest edits a pull request's descriptive fields. Requests
+// carrying the admin token may also edit merged pull requests.
+func (h *Handler) UpdatePullRequest(w http.ResponseWriter, r *http.Request) {
+	var req updatePRRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	isAdmin := hasAdminToken(r, h.cfg.AdminToken)
+	pr, err := h.service.UpdatePullRequest(r.Context(), req.PullRequestID, req.toDomain(), isAdmin)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"pr": mapPullRequest(pr, h.linksFor(r)),
+	})
+}
+
+func (h *Handler) MergePullRequest(w http.ResponseWriter, r *http.Request) {
+	var req mergePRRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	var pr domain.PullRequest
+	var err error
+	if req.Force {
+		actor, ok := h.authorizeForce(w, r, req.ID)
+		if !ok {
+			return
+		}
+		pr, err = h.service.ForceMergePullRequest(r.Context(), req.ID, actor, req.Reason)
+	} else {
+		pr, err = h.service.MergePullRequest(r.Context(), req.ID)
+	}
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"pr": mapPullRequest(pr, h.linksFor(r)),
+	})
+}
+
+// authorizeForce lets a forced merge through with the admin token, returning
+// the admin named by the X-User-ID header, or, with leads enforced, with the
+// team token of a lead of the author's team, returning the lead. The one
+// returned is recorded as having forced the merge. Otherwise it writes a 401
+// or 403 and returns false.
+func (h *Handler) authorizeForce(w http.ResponseWriter, r *http.Request, prID string) (string, bool) {
+	if _, ok := teamTokenHash(r); ok && h.cfg.EnforceTeamLeads && !hasAdminToken(r, h.cfg.AdminToken) {
+		return h.authorizeLead(w, r, func(ctx context.Context, callerID string) error {
+			return h.service.AuthorizePullRequestLead(ctx, callerID, prID)
+		})
+	}
+	if h.cfg.AdminToken == "" {
+		respondError(w, http.StatusForbidden, "FORBIDDEN", "force merge is disabled")
+		return "", false
+	}
+	if !hasAdminToken(r, h.cfg.AdminToken) {
+		respondError(w, http.StatusUnauthorized, "UNAUTHORIZED", "admin token required to force a merge")
+		return "", false
+	}
+	actor := r.Header.Get(callerHeader)
+	if actor == "" {
+		respondError(w, http.StatusUnauthorized, "UNAUTHORIZED", callerHeader+" header required")
+		return "", false
+	}
+	return actor, true
+}
+
+// BulkMergePullRequests merges several pull requests, e.g. after a release
+// train, and reports the outcome of each.
+func (h *Handler) BulkMergePullRequests(w http.ResponseWriter, r *http.Request) {
+	var req bulkMergePRRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	results, err := h.service.BulkMergePullRequests(r.Context(), req.IDs)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	links := h.linksFor(r)
+	items := make([]bulkResultPayload, 0, len(results))
+	for _, result := range results {
+		items = append(items, mapBulkResult(result, links))
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"results": items,
+	})
+}
+
+// MarkPullRequestReady moves a draft to OPEN and assigns its reviewers.
+func (h *Handler) MarkPullRequestReady(w http.ResponseWriter, r *http.Request) {
+	var req mergePRRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	pr, err := h.service.MarkPullRequestReady(r.Context(), req.ID)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"pr": mapPullRequest(pr, h.linksFor(r)),
+	})
+}
+
+func (h *Handler) ReassignReviewer(w http.ResponseWriter, r *http.Request) {
+	var req reassignRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	if _, ok := h.authorizeLead(w, r, func(ctx context.Context, callerID string) error {
+		return h.service.AuthorizePullRequestLead(ctx, callerID, req.PullRequestID)
+	}); !ok {
+		return
+	}
+
+	pr, replacedBy, err := h.service.ReassignReviewer(r.Context(), req.PullRequestID, req.OldUserID)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"pr":          mapPullRequest(pr, h.linksFor(r)),
+		"replaced_by": replacedBy,
+	})
+}
+
+// AddReviewer appends an extra reviewer, picked automatically unless user_id
+// names one.
+func (h *Handler) AddReviewer(w http.ResponseWriter, r *http.Request) {
+	var req addReviewerRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	pr, added, err := h.service.AddReviewer(r.Context(), req.PullRequestID, req.UserID)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"pr":             mapPullRequest(pr, h.linksFor(r)),
+		"added_reviewer": added,
+	})
+}
+
+// RecordReview registers a comment or approval by an assigned reviewer.
+func (h *Handler) RecordReview(w http.ResponseWriter, r *http.Request) {
+	var req reviewRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	first, err := h.service.RecordReview(r.Context(), req.PullRequestID, req.ReviewerID, domain.ReviewKind(req.Kind))
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"pull_request_id": req.PullRequestID,
+		"first_review":    first,
+	})
+}
+
+// AcceptReview records that a reviewer took on the review of a pull request.
+func (h *Handler) AcceptReview(w http.ResponseWriter, r *http.Request) {
+	var req acceptReviewRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	pr, err := h.service.AcceptReview(r.Context(), req.PullRequestID, req.UserID)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"pr": mapPullRequest(pr, h.linksFor(r)),
+	})
+}
+
+// CompleteReview marks a reviewer done with the review of a pull request.
+func (h *Handler) CompleteReview(w http.ResponseWriter, r *http.Request) {
+	var req completeReviewRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	pr, err := h.service.CompleteReview(r.Context(), req.PullRequestID, req.UserID)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"pr": mapPullRequest(pr, h.linksFor(r)),
+	})
+}
+
+// LinkPullRequests marks a pull request as blocked by another.
+func (h *Handler) LinkPullRequests(w http.ResponseWriter, r *http.Request) {
+	h.changeLink(w, r, h.service.LinkPullRequests)
+}
+
+// UnlinkPullRequests removes a link made by LinkPullRequests.
+func (h *Handler) UnlinkPullRequests(w http.ResponseWriter, r *http.Request) {
+	h.changeLink(w, r, h.service.UnlinkPullRequests)
+}
+
+func (h *Handler) changeLink(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, prID, blockedBy string) (domain.PullRequest, error)) {
+	var req linkRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	pr, err := change(r.Context(), req.PullRequestID, req.BlockedBy)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"pr": mapPullRequest(pr, h.linksFor(r)),
+	})
+}
+
+// ExtendDeadline asks for more time to review a pull request on behalf of one
+// of its reviewers. The extension is approved at once when the team's policy
+// allows it and waits for a lead otherwise.
+func (h *Handler) ExtendDeadline(w http.ResponseWriter, r *http.Request) {
+	var req extendDeadlineRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	extension, err := h.service.RequestDeadlineExtension(r.Context(), req.PullRequestID, req.UserID, req.Until, req.Reason)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]any{
+		"extension": mapExtension(extension),
+	})
+}
+
+// DecideExtension approves or rejects a pending deadline extension. With team
+// leads enforced only leads of the author's team and admins may decide.
+func (h *Handler) DecideExtension(w http.ResponseWriter, r *http.Request) {
+	var req decideExtensionRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	callerID, ok := h.authorizeLead(w, r, func(ctx context.Context, callerID string) error {
+		return h.service.AuthorizeExtensionLead(ctx, callerID, req.ExtensionID)
+	})
+	if !ok {
+		return
+	}
+
+	extension, err := h.service.DecideDeadlineExtension(r.Context(), req.ExtensionID, *req.Approve, callerID)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"extension": mapExtension(extension),
+	})
+}
+
+// GetExtensions lists the deadline extensions requested for a pull request.
+func (h *Handler) GetExtensions(w http.ResponseWriter, r *http.Request) {
+	prID := r.URL.Query().Get("pull_request_id")
+	if prID == "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "pull_request_id is required")
+		return
+	}
+
+	extensions, err := h.service.ListDeadlineExtensions(r.Context(), prID)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	result := make([]extensionPayload, 0, len(extensions))
+	for _, extension := range extensions {
+		result = append(result, mapExtension(extension))
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"pull_request_id": prID,
+		"extensions":      result,
+	})
+}
+
+func (h *Handler) AssignReviewers(w http.ResponseWriter, r *http.Request) {
+	var req assignReviewersRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	pr, err := h.service.AssignReviewers(r.Context(), req.PullRequestID, req.ReviewerIDs)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"pr": mapPullRequest(pr, h.linksFor(r)),
+	})
+}
+
+func (h *Handler) GetUserReviews(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "user_id is required")
+		return
+	}
+
+	filter, err := parseReviewFilter(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	prs, err := h.service.ListUserReviews(r.Context(), userID, filter)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSONWithETag(w, r, http.StatusOK, mapUserReviews(userID, prs, h.linksFor(r)))
+}
+
+// WaitUserReviews long-polls the user's open reviews. It answers as soon as
+// they differ from the representation named by If-None-Match, or from the
+// one current when the request arrived, and otherwise when timeout expires:
+// with 304 if the client sent If-None-Match and 200 if it did not. ETags are
+// shared with GET /users/getReview?status=OPEN.
+func (h *Handler) WaitUserReviews(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	userID := query.Get("user_id")
+	if userID == "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "user_id is required")
+		return
+	}
+
+	wait := defaultReviewWait
+	if raw := query.Get("timeout"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 || parsed > maxReviewWait {
+			respondError(w, http.StatusBadRequest, "BAD_REQUEST", "timeout must be a positive duration of at most 60s")
+			return
+		}
+		wait = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+
+	links := h.linksFor(r)
+	known := r.Header.Get("If-None-Match")
+	prs, err := h.service.WaitUserReviews(ctx, userID, func(prs []domain.PullRequest) bool {
+		_, etag, err := encodeWithETag(mapUserReviews(userID, prs, links))
+		if err != nil {
+			return false
+		}
+		if known == "" {
+			known = etag
+		}
+		return etagMatches(known, etag)
+	})
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSONWithETag(w, r, http.StatusOK, mapUserReviews(userID, prs, links))
+}
+
+// ListChanges pages through the change feed after since. With tail=true it
+// returns the last limit events instead, so that a reader can start from the
+// end of the feed and follow it with the returned cursor.
+func (h *Handler) ListChanges(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var tail bool
+	if raw := query.Get("tail"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "BAD_REQUEST", "tail must be a boolean")
+			return
+		}
+		tail = parsed
+	}
+	if tail && query.Get("since") != "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "since cannot be combined with tail")
+		return
+	}
+
+	var since int64
+	if raw := query.Get("since"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			respondError(w, http.StatusBadRequest, "BAD_REQUEST", "since must be a non-negative integer")
+			return
+		}
+		since = parsed
+	}
+
+	limit := defaultChangesLimit
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxChangesLimit {
+			respondError(w, http.StatusBadRequest, "BAD_REQUEST", "limit must be between 1 and 1000")
+			return
+		}
+		limit = parsed
+	}
+
+	var events []domain.Event
+	var err error
+	if tail {
+		events, err = h.service.ListLatestChanges(r.Context(), limit)
+	} else {
+		events, err = h.service.ListChanges(r.Context(), since, limit)
+	}
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	result := make([]eventPayload, 0, len(events))
+	nextCursor := since
+	for _, event := range events {
+		result = append(result, mapEvent(event))
+		nextCursor = event.Seq
+	}
+
+	respondPage(w, http.StatusOK, map[string]any{
+		"events": result,
+	}, paginationPayload{Limit: limit, NextCursor: &nextCursor})
+}
+
+func (h *Handler) GetFairness(w http.ResponseWriter, r *http.Request) {
+	teamName, period, ok := parseStatsQuery(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.service.FairnessReport(r.Context(), teamName, period)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapFairnessReport(report))
+}
+
+// GetTimeToReview reports weekly median and p90 time to first review for the
+// team's pull requests.
+func (h *Handler) GetTimeToReview(w http.ResponseWriter, r *http.Request) {
+	teamName, period, ok := parseStatsQuery(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.service.TimeToReviewReport(r.Context(), teamName, period)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapTimeToReviewReport(report))
+}
+
+// GetTeamStats serves the team's pull request statistics over the period.
+func (h *Handler) GetTeamStats(w http.ResponseWriter, r *http.Request) {
+	teamName, period, ok := parseStatsQuery(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := h.service.TeamStats(r.Context(), teamName, period)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapTeamStats(stats))
+}
+
+// GetAcceptance serves the acceptance statistics of the team's reviewers.
+func (h *Handler) GetAcceptance(w http.ResponseWriter, r *http.Request) {
+	teamName, period, ok := parseStatsQuery(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.service.AcceptanceReport(r.Context(), teamName, period)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapAcceptanceReport(report))
+}
+
+// GetForecast estimates the reviews each member of the team can expect over
+// the coming week from the pull requests created within period.
+func (h *Handler) GetForecast(w http.ResponseWriter, r *http.Request) {
+	teamName, period, ok := parseStatsQuery(w, r)
+	if !ok {
+		return
+	}
+
+	forecast, err := h.service.LoadForecast(r.Context(), teamName, period)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapLoadForecast(forecast))
+}
+
+// GetDailyStats serves the daily review aggregates built by the nightly job
+// for the days from..to (YYYY-MM-DD, both included), optionally narrowed to
+// one team.
+func (h *Handler) GetDailyStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, errFrom := time.Parse(time.DateOnly, query.Get("from"))
+	to, errTo := time.Parse(time.DateOnly, query.Get("to"))
+	if errFrom != nil || errTo != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "from and to must be dates in YYYY-MM-DD format")
+		return
+	}
+	if to.Before(from) || to.Sub(from) >= maxDailyStatsDays*24*time.Hour {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", fmt.Sprintf("to must not precede from and the range must not exceed %d days", maxDailyStatsDays))
+		return
+	}
+
+	stats, err := h.service.DailyStats(r.Context(), query.Get("team_name"), from, to)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"from": from.Format(time.DateOnly),
+		"to":   to.Format(time.DateOnly),
+		"days": mapDailyStats(stats),
+	})
+}
+
+// GetUnderassigned lists open pull requests with fewer reviewers than their
+// team requires, optionally narrowed to one team.
+func (h *Handler) GetUnderassigned(w http.ResponseWriter, r *http.Request) {
+	prs, err := h.service.ListUnderassigned(r.Context(), r.URL.Query().Get("team_name"))
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"pull_requests": mapUnderassigned(prs),
+	})
+}
+
+// GetHotPullRequests lists open pull requests reassigned at least
+// min_reassignments times, default 3, optionally narrowed to one team. A pull
+// request high on the list is one nobody wants to review.
+func (h *Handler) GetHotPullRequests(w http.ResponseWriter, r *http.Request) {
+	minReassignments := defaultHotPRs
+	if raw := r.URL.Query().Get("min_reassignments"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			respondError(w, http.StatusBadRequest, "BAD_REQUEST", "min_reassignments must be a positive integer")
+			return
+		}
+		minReassignments = parsed
+	}
+
+	prs, err := h.service.ListHotPullRequests(r.Context(), r.URL.Query().Get("team_name"), minReassignments)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"min_reassignments": minReassignments,
+		"pull_requests":     mapHotPullRequests(prs),
+	})
+}
+
+// GetErrorStats summarizes the error responses of the last window, default
+// 15m, by error code. It tells business-level rejections (4xx) from
+// infrastructure failures (5xx) and covers this instance only.
+func (h *Handler) GetErrorStats(w http.ResponseWriter, r *http.Request) {
+	window := defaultErrorWindow
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 || parsed > metrics.MaxErrorWindow {
+			respondError(w, http.StatusBadRequest, "BAD_REQUEST", "window must be a positive duration of at most 1h")
+			return
+		}
+		window = parsed
+	}
+
+	respondJSON(w, http.StatusOK, mapErrorStats(metrics.RecentErrors(window)))
+}
+
+func (h *Handler) DBStats(w http.ResponseWriter, r *http.Request) {
+	stats, ok := h.service.PoolStats()
+	if !ok {
+		respondError(w, http.StatusNotImplemented, "NOT_SUPPORTED", "storage has no connection pool")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"acquired_conns":         stats.AcquiredConns,
+		"idle_conns":             stats.IdleConns,
+		"total_conns":            stats.TotalConns,
+		"max_conns":              stats.MaxConns,
+		"acquire_count":          stats.AcquireCount,
+		"acquire_duration_ms":    stats.AcquireDuration.Milliseconds(),
+		"empty_acquire_count":    stats.EmptyAcquireCount,
+		"canceled_acquire_count": stats.CanceledAcquireCount,
+	})
+}
+
+// GetMigrations lists the applied and the pending schema migrations with the
+// checksums of their files, to confirm the schema state after a deploy.
+func (h *Handler) GetMigrations(w http.ResponseWriter, r *http.Request) {
+	migrations, ok, err := h.service.MigrationStatus(r.Context())
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+	if !ok {
+		respondError(w, http.StatusNotImplemented, "NOT_SUPPORTED", "storage has no schema migrations")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapMigrationStatus(migrations))
+}
+
+// ArchivePullRequests runs the retention job on demand, archiving pull
+// requests merged more than older_than_days days ago.
+func (h *Handler) ArchivePullRequests(w http.ResponseWriter, r *http.Request) {
+	var req archiveRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	mergedBefore := time.Now().UTC().Add(-time.Duration(req.OlderThanDays) * 24 * time.Hour)
+	archived, err := h.service.ArchivePullRequests(r.Context(), mergedBefore)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"archived": archived,
+	})
+}
+
+// CheckIntegrity looks for inconsistent data and, with fix set, repairs it.
+// The report lists every issue found, grouped by check.
+func (h *Handler) CheckIntegrity(w http.ResponseWriter, r *http.Request) {
+	var req integrityRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	report, err := h.service.CheckIntegrity(r.Context(), req.Fix)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapIntegrityReport(report))
+}
+
+// ExportSnapshot returns the teams, users, settings and pull requests as one
+// document that /admin/import accepts.
+func (h *Handler) ExportSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := h.service.ExportSnapshot(r.Context())
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapSnapshot(snapshot))
+}
+
+// ImportSnapshot restores an exported snapshot into an instance without
+// data. With dry_run=true the snapshot is only checked.
+func (h *Handler) ImportSnapshot(w http.ResponseWriter, r *http.Request) {
+	var dryRun bool
+	if raw := r.URL.Query().Get("dry_run"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "BAD_REQUEST", "dry_run must be a boolean")
+			return
+		}
+		dryRun = parsed
+	}
+	var req snapshotRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+	snapshot, err := req.toDomain()
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	result, err := h.service.ImportSnapshot(r.Context(), snapshot, dryRun)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if dryRun {
+		status = http.StatusOK
+	}
+	respondJSON(w, status, mapImportResult(result))
+}
+
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.service.ListWebhooks(r.Context())
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	result := make([]webhookPayload, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		result = append(result, mapWebhook(webhook, false))
+	}
+	respondJSON(w, http.StatusOK, map[string]any{
+		"webhooks": result,
+	})
+}
+
+// CreateWebhook registers a webhook. The response is the only one carrying
+// the signing secret.
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(false); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	webhook, err := h.service.CreateWebhook(r.Context(), req.toDomain())
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]any{
+		"webhook": mapWebhook(webhook, true),
+	})
+}
+
+func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(true); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	webhook, err := h.service.UpdateWebhook(r.Context(), req.toDomain())
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]any{
+		"webhook": mapWebhook(webhook, req.Secret != ""),
+	})
+}
+
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteWebhook(r.Context(), id); err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListTeamTokens lists the team tokens, of the team named by team_name or of
+// all teams. The tokens themselves are never shown again after creation.
+func (h *Handler) ListTeamTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.service.ListTeamTokens(r.Context(), r.URL.Query().Get("team_name"))
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	result := make([]teamTokenPayload, 0, len(tokens))
+	for _, token := range tokens {
+		result = append(result, mapTeamToken(token))
+	}
+	respondJSON(w, http.StatusOK, map[string]any{
+		"tokens": result,
+	})
+}
+
+// CreateTeamToken mints a team token. The response is the only one carrying
+// the token.
+func (h *Handler) CreateTeamToken(w http.ResponseWriter, r *http.Request) {
+	var req teamTokenRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	token, err := h.service.CreateTeamToken(r.Context(), req.TeamName, req.Name, req.UserID)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]any{
+		"token": mapTeamToken(token),
+	})
+}
+
+func (h *Handler) DeleteTeamToken(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteTeamToken(r.Context(), id); err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(w, r, "webhook_id")
+	if !ok {
+		return
+	}
+
+	limit := defaultDeliveries
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxDeliveries {
+			respondError(w, http.StatusBadRequest, "BAD_REQUEST", "limit must be between 1 and 500")
+			return
+		}
+		limit = parsed
+	}
+
+	deliveries, err := h.service.ListWebhookDeliveries(r.Context(), id, limit)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	result := make([]webhookDeliveryPayload, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		result = append(result, mapWebhookDelivery(delivery))
+	}
+	respondPage(w, http.StatusOK, map[string]any{
+		"webhook_id": id,
+		"deliveries": result,
+	}, paginationPayload{Limit: limit})
+}
+
+func (h *Handler) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	state, err := h.service.Maintenance(r.Context())
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapMaintenance(state))
+}
+
+// SetMaintenance switches the read-only mode on or off for every replica.
+func (h *Handler) SetMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req maintenanceRequest
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	state, err := h.service.SetMaintenance(r.Context(), *req.Enabled)
+	if err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapMaintenance(state))
+}
+
+// ResetData deletes all data so that test scenarios start from scratch.
+func (h *Handler) ResetData(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Reset(r.Context()); err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SeedData applies a fixture in the seed file format.
+func (h *Handler) SeedData(w http.ResponseWriter, r *http.Request) {
+	var fixture seed.Fixture
+	if !h.decodeBody(w, r, &fixture) {
+		return
+	}
+
+	if err := seed.Apply(r.Context(), h.service, fixture); err != nil {
+		h.handleDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleDomainError(w http.ResponseWriter, err error) {
+	if err == nil {
+		return
+	}
+	status, payload := describeError(err)
+	respondJSON(w, status, errorResponse{Error: payload})
+}
+
+func describeError(err error) (int, errorPayload) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout, errorPayload{Code: "TIMEOUT", Message: "request timed out"}
+	}
+
+	var domainErr *domain.Error
+	if !errors.As(err, &domainErr) {
+		return http.StatusInternalServerError, errorPayload{Code: "INTERNAL", Message: "internal server error"}
+	}
+	return statusForKind(domainErr.Kind), errorPayload{Code: domainErr.Code, Message: domainErr.Message, Details: domainErr.Details}
+}
+
+func statusForKind(kind domain.Kind) int {
+	switch kind {
+	case domain.KindInvalid:
+		return http.StatusBadRequest
+	case domain.KindNotFound:
+		return http.StatusNotFound
+	case domain.KindConflict:
+		return http.StatusConflict
+	case domain.KindUnavailable:
+		return http.StatusServiceUnavailable
+	case domain.KindForbidden:
+		return http.StatusForbidden
+	case domain.KindUnprocessable:
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// parseOffsetPage reads the cursor and limit of an offset-paged listing,
+// writing a 400 response when either is malformed.
+func parseOffsetPage(w http.ResponseWriter, r *http.Request) (int64, int, bool) {
+	query := r.URL.Query()
+
+	var offset int64
+	if raw := query.Get("cursor"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || parsed < 0 {
+			respondError(w, http.StatusBadRequest, "BAD_REQUEST", "cursor must be a non-negative integer")
+			return 0, 0, false
+		}
+		offset = parsed
+	}
+
+	limit := defaultTeamsLimit
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxTeamsLimit {
+			respondError(w, http.StatusBadRequest, "BAD_REQUEST", "limit must be between 1 and 500")
+			return 0, 0, false
+		}
+		limit = parsed
+	}
+	return offset, limit, true
+}
+
+// parseID reads an ID from the named query parameter, writing a
+// 400 response when it is missing or malformed.
+func parseID(w http.ResponseWriter, r *http.Request, param string) (int64, bool) {
+	id, err := strconv.ParseInt(r.URL.Query().Get(param), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", param+" must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeBody decodes the JSON request body into dst, writing a 413 when the
+// body exceeds the configured limit and a 400 for any other decoding failure.
+// In strict mode fields dst does not know are such a failure.
+func (h *Handler) decodeBody(w http.ResponseWriter, r *http.Request, dst any) bool {
+	decoder := json.NewDecoder(r.Body)
+	if h.cfg.StrictJSON {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(dst)
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondError(w, http.StatusRequestEntityTooLarge, "BODY_TOO_LARGE", fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+		return false
+	}
+	// encoding/json has no error type for unknown fields.
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "unknown field "+field)
+		return false
+	}
+	respondError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+	return false
+}
+
+// parseReviewFilter reads status, sort and order query parameters. Without
+// parameters reviews are returned newest first.
+func parseReviewFilter(query url.Values) (domain.ReviewFilter, error) {
+	filter := domain.ReviewFilter{
+		Status:     domain.PRStatus(query.Get("status")),
+		SortBy:     domain.SortByCreatedAt,
+		Descending: true,
+	}
+	if filter.Status != "" && !filter.Status.Valid() {
+		return domain.ReviewFilter{}, errors.New("status must be one of OPEN, MERGED, CLOSED")
+	}
+
+	// The snake_case spellings match the field names of snake_case payloads.
+	switch query.Get("sort") {
+	case "":
+	case string(domain.SortByCreatedAt), "created_at":
+		filter.SortBy = domain.SortByCreatedAt
+	case string(domain.SortByMergedAt), "merged_at":
+		filter.SortBy = domain.SortByMergedAt
+	default:
+		return domain.ReviewFilter{}, errors.New("sort must be one of createdAt, mergedAt")
+	}
+
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		filter.Descending = false
+	case "desc":
+		filter.Descending = true
+	default:
+		return domain.ReviewFilter{}, errors.New("order must be one of asc, desc")
+	}
+
+	if raw := query.Get("needs_more_reviewers"); raw != "" {
+		needsMore, err := strconv.ParseBool(raw)
+		if err != nil {
+			return domain.ReviewFilter{}, errors.New("needs_more_reviewers must be a boolean")
+		}
+		filter.NeedsMoreReviewers = needsMore
+	}
+
+	if raw := query.Get("exclude_completed"); raw != "" {
+		exclude, err := strconv.ParseBool(raw)
+		if err != nil {
+			return domain.ReviewFilter{}, errors.New("exclude_completed must be a boolean")
+		}
+		filter.ExcludeCompleted = exclude
+	}
+
+	if raw := query.Get("blocked"); raw != "" {
+		blocked, err := strconv.ParseBool(raw)
+		if err != nil {
+			return domain.ReviewFilter{}, errors.New("blocked must be a boolean")
+		}
+		filter.Blocked = &blocked
+	}
+
+	return filter, nil
+}
+
+// parseStatsQuery reads the team_name and period parameters shared by the
+// stats endpoints, writing a 400 response when they are invalid.
+func parseStatsQuery(w http.ResponseWriter, r *http.Request) (string, time.Duration, bool) {
+	query := r.URL.Query()
+	teamName := query.Get("team_name")
+	if teamName == "" {
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "team_name is required")
+		return "", 0, false
+	}
+
+	period := defaultStatsPeriod
+	if raw := query.Get("period"); raw != "" {
+		parsed, err := parsePeriod(raw)
+		if err != nil || parsed <= 0 || parsed > maxStatsPeriod {
+			respondError(w, http.StatusBadRequest, "BAD_REQUEST", "period must be a positive duration such as 30d, 2w or 12h, at most 365d")
+			return "", 0, false
+		}
+		period = parsed
+	}
+	return teamName, period, true
+}
+
+// parsePeriod extends time.ParseDuration with day (d) and week (w) units.
+// Like time.ParseDuration it fails for periods that do not fit a
+// time.Duration instead of letting them wrap around.
+func parsePeriod(raw string) (time.Duration, error) {
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(raw, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(raw, "w"):
+		unit = 7 * 24 * time.Hour
+	default:
+		return time.ParseDuration(raw)
+	}
+
+	n, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if limit := int64(math.MaxInt64 / unit); n > limit || n < -limit {
+		return 0, fmt.Errorf("period %q out of range", raw)
 	}
+	return time.Duration(n) * unit, nil
 }
@@ -0,0 +1,131 @@
+package httptransport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"Avito2025/internal/domain"
+)
+
+func TestParsePeriod(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		raw     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{raw: "12h", want: 12 * time.Hour},
+		{raw: "90m", want: 90 * time.Minute},
+		{raw: "30d", want: 30 * day},
+		{raw: "2w", want: 14 * day},
+		{raw: "0d", want: 0},
+		{raw: "-1d", want: -day},
+		{raw: "106751d", want: 106751 * day},
+		{raw: "15250w", want: 15250 * 7 * day},
+		{raw: "106752d", wantErr: true},
+		{raw: "15251w", wantErr: true},
+		{raw: "-106752d", wantErr: true},
+		{raw: "9223372036854775807d", wantErr: true},
+		{raw: "99999999999999999999w", wantErr: true},
+		{raw: "1.5d", wantErr: true},
+		{raw: "d", wantErr: true},
+		{raw: "w", wantErr: true},
+		{raw: "3x", wantErr: true},
+		{raw: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parsePeriod(tt.raw)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parsePeriod(%q) = %v, expected an error", tt.raw, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parsePeriod(%q) = %v, %v; expected %v", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseReviewFilter(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    domain.ReviewFilter
+		wantErr bool
+	}{
+		{raw: "", want: domain.ReviewFilter{SortBy: domain.SortByCreatedAt, Descending: true}},
+		{raw: "status=MERGED&sort=mergedAt&order=asc", want: domain.ReviewFilter{Status: domain.StatusMerged, SortBy: domain.SortByMergedAt}},
+		{raw: "status=CLOSED&sort=merged_at", want: domain.ReviewFilter{Status: domain.StatusClosed, SortBy: domain.SortByMergedAt, Descending: true}},
+		{raw: "sort=created_at&order=desc", want: domain.ReviewFilter{SortBy: domain.SortByCreatedAt, Descending: true}},
+		{raw: "status=merged", wantErr: true},
+		{raw: "sort=updatedAt", wantErr: true},
+		{raw: "order=up", wantErr: true},
+	}
+	for _, tt := range tests {
+		query, err := url.ParseQuery(tt.raw)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tt.raw, err)
+		}
+		got, err := parseReviewFilter(query)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseReviewFilter(%q) = %+v, expected an error", tt.raw, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseReviewFilter(%q) = %+v, %v; expected %+v", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestDescribeErrorUnwraps(t *testing.T) {
+	tests := []struct {
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{err: domain.ErrTeamNotFound, status: http.StatusNotFound, code: "NOT_FOUND", message: "team not found"},
+		{err: fmt.Errorf("load author: %w", domain.ErrUserNotFound), status: http.StatusNotFound, code: "NOT_FOUND", message: "user not found"},
+		{err: fmt.Errorf("merge: %w", fmt.Errorf("get: %w", domain.ErrPullRequestNotFound)), status: http.StatusNotFound, code: "NOT_FOUND", message: "pull request not found"},
+		{err: fmt.Errorf("create: %w", domain.ErrPRExists), status: http.StatusConflict, code: "PR_EXISTS", message: "pull request already exists"},
+		{err: fmt.Errorf("settings: %w", domain.ErrInvalidSettings), status: http.StatusBadRequest, code: "INVALID_SETTINGS", message: "invalid team settings"},
+		{err: fmt.Errorf("lead: %w", domain.ErrForbidden), status: http.StatusForbidden, code: "FORBIDDEN", message: domain.ErrForbidden.Message},
+		{err: fmt.Errorf("query: %w", domain.ErrStorageTimeout), status: http.StatusServiceUnavailable, code: "STORAGE_TIMEOUT", message: domain.ErrStorageTimeout.Message},
+		{err: errors.Join(errors.New("rollback failed"), domain.ErrWebhookNotFound), status: http.StatusNotFound, code: "NOT_FOUND", message: "webhook not found"},
+		{err: fmt.Errorf("query: %w", context.DeadlineExceeded), status: http.StatusGatewayTimeout, code: "TIMEOUT", message: "request timed out"},
+		{err: fmt.Errorf("query: %w", errors.New("connection reset")), status: http.StatusInternalServerError, code: "INTERNAL", message: "internal server error"},
+	}
+	for _, tt := range tests {
+		status, payload := describeError(tt.err)
+		if status != tt.status || payload.Code != tt.code || payload.Message != tt.message {
+			t.Errorf("describeError(%v) = %d %s %q; expected %d %s %q", tt.err, status, payload.Code, payload.Message, tt.status, tt.code, tt.message)
+		}
+	}
+}
+
+func TestNotFoundErrorsNameTheirResource(t *testing.T) {
+	seen := map[string]bool{}
+	for _, err := range []*domain.Error{
+		domain.ErrTeamNotFound,
+		domain.ErrUserNotFound,
+		domain.ErrPullRequestNotFound,
+		domain.ErrIdentityNotFound,
+		domain.ErrRotationNotFound,
+		domain.ErrWebhookNotFound,
+		domain.ErrExtensionNotFound,
+		domain.ErrTeamTokenNotFound,
+	} {
+		if err.Kind != domain.KindNotFound || seen[err.Message] {
+			t.Errorf("expected a distinct not found message, got %q", err.Message)
+		}
+		seen[err.Message] = true
+	}
+}
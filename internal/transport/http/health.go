@@ -0,0 +1,87 @@
+package httptransport
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"Avito2025/internal/writequeue"
+)
+
+// Health answers load balancer probes, GET or HEAD. By default it pings the
+// storage, reusing the last result for HealthCacheTTL so that many probes do
+// not hammer the database; ?deep=false skips the ping altogether. The body is
+// served with an ETag, so a probe sending If-None-Match gets a bodiless 304.
+func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
+	deep := true
+	if raw := r.URL.Query().Get("deep"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "BAD_REQUEST", "deep must be a boolean")
+			return
+		}
+		deep = parsed
+	}
+
+	if deep {
+		if err := h.health.check(r.Context(), h.cfg.HealthCacheTTL, h.service.Health); err != nil {
+			respondError(w, http.StatusInternalServerError, "UNHEALTHY", err.Error())
+			return
+		}
+	}
+	respondJSONWithETag(w, r, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Ready answers readiness probes, GET or HEAD, pinging the storage like
+// Health. With a write queue the service stays ready while the storage is
+// down, reporting status "degraded" and the number of queued writes until
+// they are replayed; it is unready once the queue is full.
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	err := h.health.check(r.Context(), h.cfg.HealthCacheTTL, h.service.Health)
+	if h.writes == nil {
+		if err != nil {
+			respondError(w, http.StatusServiceUnavailable, "UNHEALTHY", err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		return
+	}
+
+	queued := h.writes.Len()
+	switch {
+	case h.writes.Full():
+		respondError(w, http.StatusServiceUnavailable, "UNHEALTHY", writequeue.ErrFull.Error())
+	case err != nil || queued > 0:
+		respondJSON(w, http.StatusOK, map[string]any{"status": "degraded", "queued": queued})
+	default:
+		respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
+
+// healthCache remembers the outcome of the last storage ping.
+type healthCache struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+// check returns the last ping's outcome while it is younger than ttl and
+// pings otherwise. Concurrent probes arriving after expiry wait for a single
+// ping instead of issuing their own.
+func (c *healthCache) check(ctx context.Context, ttl time.Duration, ping func(context.Context) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ttl > 0 && !c.checkedAt.IsZero() && time.Since(c.checkedAt) < ttl {
+		return c.err
+	}
+	err := ping(ctx)
+	if ctx.Err() != nil {
+		// The probe gave up; its cancellation says nothing about the storage.
+		return err
+	}
+	c.checkedAt, c.err = time.Now(), err
+	return err
+}
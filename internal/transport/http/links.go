@@ -0,0 +1,55 @@
+package httptransport
+
+import (
+	"net/http"
+	"strings"
+
+	"Avito2025/internal/links"
+)
+
+// linksPayload points at the resources related to a payload with absolute
+// URLs. Links that do not apply are omitted.
+type linksPayload struct {
+	Self    string `json:"self,omitempty"`
+	Team    string `json:"team,omitempty"`
+	Reviews string `json:"reviews,omitempty"`
+}
+
+// linksFor returns the builder of links for the API version serving r.
+// Requests on legacy paths get links to /v1.
+func (h *Handler) linksFor(r *http.Request) links.Builder {
+	version := "/v1"
+	if r.URL.Path == "/v2" || strings.HasPrefix(r.URL.Path, "/v2/") {
+		version = "/v2"
+	}
+	return links.New(publicBaseURL(r, h.cfg.BaseURL) + version)
+}
+
+// publicBaseURL returns the root of the service as its clients see it: the
+// configured base URL or, behind a proxy, the scheme and host it forwarded,
+// falling back to those the request arrived with.
+func publicBaseURL(r *http.Request, configured string) string {
+	if configured != "" {
+		return strings.TrimRight(configured, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := strings.ToLower(forwardedValue(r.Header.Get("X-Forwarded-Proto"))); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	host := r.Host
+	if forwarded := forwardedValue(r.Header.Get("X-Forwarded-Host")); forwarded != "" && !strings.ContainsAny(forwarded, "/?#@\\ ") {
+		host = forwarded
+	}
+	return scheme + "://" + host
+}
+
+// forwardedValue returns the value a proxy chain set for the original client,
+// which the first proxy puts first.
+func forwardedValue(header string) string {
+	first, _, _ := strings.Cut(header, ",")
+	return strings.TrimSpace(first)
+}
@@ -0,0 +1,361 @@
+package httptransport
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"Avito2025/internal/config"
+	"Avito2025/internal/domain"
+	"Avito2025/internal/metrics"
+	"Avito2025/internal/requestid"
+	"Avito2025/internal/service"
+	"Avito2025/internal/signature"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/gorilla/websocket"
+	"github.com/klauspost/compress/zstd"
+)
+
+// propagateRequestID hands the ID assigned by chi's RequestID middleware down
+// to the service and storage layers, and returns it to the client so that a
+// failed request can be matched with the server logs.
+func propagateRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := middleware.GetReqID(r.Context())
+		if id == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set(middleware.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(requestid.With(r.Context(), id)))
+	})
+}
+
+// countRequests counts every request towards the error rates reported by
+// /stats/errors.
+func countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics.ObserveRequest()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitInFlight refuses requests with 503 while max others are being handled,
+// so that a flood fails fast instead of starving the database pool, and
+// reports the requests being handled in a gauge. Streaming connections and
+// long polls hold no slot, and neither do /metrics and the /health probes,
+// which must keep answering under load. A non-positive max only keeps the gauge.
+func limitInFlight(max int) func(http.Handler) http.Handler {
+	var slots chan struct{}
+	if max > 0 {
+		slots = make(chan struct{}, max)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if websocket.IsWebSocketUpgrade(r) || strings.HasSuffix(r.URL.Path, waitReviewsPath) ||
+				r.URL.Path == "/metrics" || strings.HasPrefix(r.URL.Path, "/health") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if slots != nil {
+				select {
+				case slots <- struct{}{}:
+					defer func() { <-slots }()
+				default:
+					metrics.ObserveRejected()
+					w.Header().Set("Retry-After", "1")
+					respondError(w, http.StatusServiceUnavailable, "OVERLOADED", "too many requests in flight")
+					return
+				}
+			}
+			metrics.RequestStarted()
+			defer metrics.RequestFinished()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// injectFaults delays requests and fails some of them with 503 as chaos
+// configures, for resilience drills. Failed requests are answered here and
+// never reach the handlers or the storage. /metrics and /health are spared
+// so that probes keep passing during a drill.
+func injectFaults(chaos config.ChaosConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/metrics" || strings.HasPrefix(r.URL.Path, "/health") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rule := config.ChaosRule{Latency: chaos.Latency, ErrorRate: chaos.ErrorRate}
+			path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/v1"), "/v2")
+			if override, ok := chaos.Routes[path]; ok {
+				rule = override
+			}
+			if rule.Latency > 0 {
+				select {
+				case <-time.After(rule.Latency):
+				case <-r.Context().Done():
+					return
+				}
+			}
+			if rule.ErrorRate > 0 && rand.Float64() < rule.ErrorRate {
+				w.Header().Set("Retry-After", "1")
+				respondError(w, http.StatusServiceUnavailable, "FAULT_INJECTED", "fault injected for a resilience drill")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// deprecated marks responses served on legacy paths and points clients at the
+// same path under the successor prefix.
+func deprecated(prefix string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Link", "<"+prefix+r.URL.Path+`>; rel="successor-version"`)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// readOnly refuses requests that change data with 503 while maintenance mode
+// is enabled. Safe methods, the maintenance switch itself and the export,
+// which only reads, pass through.
+func readOnly(maintenance func(context.Context) (domain.Maintenance, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if strings.HasSuffix(r.URL.Path, maintenancePath) || strings.HasSuffix(r.URL.Path, exportPath) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			state, err := maintenance(r.Context())
+			if err != nil {
+				status, payload := describeError(err)
+				respondJSON(w, status, errorResponse{Error: payload})
+				return
+			}
+			if state.Enabled {
+				status, payload := describeError(domain.ErrMaintenance)
+				respondJSON(w, status, errorResponse{Error: payload})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// timeout bounds every request with a deadline chosen by method: safe methods
+// get the read timeout, everything else the write timeout. If the deadline
+// expires before the handler has written anything, a 504 is returned.
+func timeout(read, write time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := write
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				limit = read
+			}
+			// Streaming connections live as long as the client stays and long
+			// polls carry a deadline of their own.
+			if limit <= 0 || websocket.IsWebSocketUpgrade(r) || strings.HasSuffix(r.URL.Path, waitReviewsPath) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), limit)
+			defer cancel()
+
+			tw := &trackingWriter{ResponseWriter: w}
+			next.ServeHTTP(tw, r.WithContext(ctx))
+
+			if !tw.wroteHeader && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				respondError(w, http.StatusGatewayTimeout, "TIMEOUT", "request timed out")
+			}
+		})
+	}
+}
+
+// compressResponses compresses JSON and CSV responses with the first of zstd,
+// gzip and deflate the client accepts. Websocket upgrades are left alone.
+func compressResponses(level int) func(http.Handler) http.Handler {
+	compressor := middleware.NewCompressor(level, "application/json", "text/csv")
+	compressor.SetEncoder("zstd", func(w io.Writer, level int) io.Writer {
+		encoder, err := zstd.NewWriter(w,
+			zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)),
+			zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil
+		}
+		return encoder
+	})
+	return func(next http.Handler) http.Handler {
+		compressed := compressor.Handler(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if websocket.IsWebSocketUpgrade(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			compressed.ServeHTTP(w, r)
+		})
+	}
+}
+
+// jsonBody rejects request bodies that are not declared as JSON with 415 and
+// caps their size at maxBytes. Requests without a body pass through.
+func jsonBody(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength == 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				respondError(w, http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", "request body must be application/json")
+				return
+			}
+			if maxBytes > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type signedKey struct{}
+
+// verifySignatures checks the HMAC signature of mutating requests. Signed
+// requests are verified whether or not signatures are required and refused
+// with 401 when the check fails; unsigned ones pass unless required is set.
+func verifySignatures(verifier *signature.Verifier, required bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			// Replayed writes were verified when they were queued.
+			if isReplay(r) || r.Header.Get(signature.HeaderSignature) == "" && !required {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, ok := readBody(w, r)
+			if !ok {
+				return
+			}
+			if err := verifier.Verify(r, body, time.Now()); err != nil {
+				respondError(w, http.StatusUnauthorized, "INVALID_SIGNATURE", err.Error())
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), signedKey{}, true)))
+		})
+	}
+}
+
+// readBody reads the whole body of r and replaces it with a copy so that
+// handlers can read it again. On failure it responds itself and returns false.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Body == nil {
+		return nil, true
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(w, http.StatusRequestEntityTooLarge, "BODY_TOO_LARGE", fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+			return nil, false
+		}
+		respondError(w, http.StatusBadRequest, "BAD_REQUEST", "cannot read request body")
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+// requireAdmin lets through only requests carrying the configured admin token
+// as a bearer credential. Without a configured token admin routes are disabled.
+func requireAdmin(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				respondError(w, http.StatusForbidden, "FORBIDDEN", "admin endpoints are disabled")
+				return
+			}
+			if !hasAdminToken(r, token) {
+				respondError(w, http.StatusUnauthorized, "UNAUTHORIZED", "admin token required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hasAdminToken reports whether r carries token as a bearer credential or a
+// verified signature. An empty token never matches.
+func hasAdminToken(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+	if signed, _ := r.Context().Value(signedKey{}).(bool); signed {
+		return true
+	}
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+type teamTokenKey struct{}
+
+// teamTokenHash returns the hash of the bearer credential of r, which is
+// taken for a team token when it is not the admin token. Replayed writes
+// carry the hash taken when they were queued.
+func teamTokenHash(r *http.Request) (string, bool) {
+	if hash, ok := r.Context().Value(teamTokenKey{}).(string); ok {
+		return hash, true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	return service.HashTeamToken(token), true
+}
+
+// trackingWriter records whether the wrapped handler has started a response.
+type trackingWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *trackingWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *trackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
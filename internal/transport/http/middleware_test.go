@@ -0,0 +1,66 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLimitInFlight(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	limited := limitInFlight(1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			entered <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		limited.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}()
+	<-entered
+
+	rec := httptest.NewRecorder()
+	limited.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/team/get", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while the slot is held, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After 1, got %q", got)
+	}
+	var body errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Error.Code != "OVERLOADED" {
+		t.Errorf("expected OVERLOADED, got %+v (%v)", body, err)
+	}
+
+	websocketUpgrade := httptest.NewRequest(http.MethodGet, "/v1/ws", nil)
+	websocketUpgrade.Header.Set("Connection", "Upgrade")
+	websocketUpgrade.Header.Set("Upgrade", "websocket")
+	exempt := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/metrics", nil),
+		httptest.NewRequest(http.MethodGet, "/health", nil),
+		httptest.NewRequest(http.MethodGet, "/health/ready", nil),
+		httptest.NewRequest(http.MethodGet, "/v1"+waitReviewsPath, nil),
+		websocketUpgrade,
+	}
+	for _, r := range exempt {
+		rec := httptest.NewRecorder()
+		limited.ServeHTTP(rec, r)
+		if rec.Code != http.StatusOK {
+			t.Errorf("expected %s to pass the limit, got %d", r.URL.Path, rec.Code)
+		}
+	}
+
+	close(release)
+	<-done
+	rec = httptest.NewRecorder()
+	limited.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/team/get", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the freed slot to be reused, got %d", rec.Code)
+	}
+}
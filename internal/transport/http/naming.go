@@ -0,0 +1,92 @@
+package httptransport
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// snakeCaseKeys maps the camelCase keys kept by some payloads since the first
+// API version to their snake_case names.
+var snakeCaseKeys = map[string]string{
+	"createdAt": "created_at",
+	"mergedAt":  "merged_at",
+	"updatedAt": "updated_at",
+}
+
+// snakeCaseWriter marks a response whose payload keys are renamed to
+// snake_case. The respond helpers find it through the Unwrap chain.
+type snakeCaseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *snakeCaseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// snakeCase renames the camelCase keys of every JSON response to snake_case.
+// Websocket upgrades are left alone: stream events are snake_case already and
+// the upgrade needs the connection's own writer.
+func snakeCase(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&snakeCaseWriter{ResponseWriter: w}, r)
+	})
+}
+
+func snakeCased(w http.ResponseWriter) bool {
+	for {
+		switch v := w.(type) {
+		case *snakeCaseWriter:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// named returns payload with the key naming configured for w. Renaming goes
+// through the payload's JSON form; should that fail, payload is left as is
+// for the encoder to report.
+func named(w http.ResponseWriter, payload any) any {
+	if !snakeCased(w) {
+		return payload
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return payload
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var tree any
+	if err := decoder.Decode(&tree); err != nil {
+		return payload
+	}
+	return renameKeys(tree)
+}
+
+func renameKeys(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		renamed := make(map[string]any, len(value))
+		for key, child := range value {
+			if snake, ok := snakeCaseKeys[key]; ok {
+				key = snake
+			}
+			renamed[key] = renameKeys(child)
+		}
+		return renamed
+	case []any:
+		for i, child := range value {
+			value[i] = renameKeys(child)
+		}
+	}
+	return value
+}
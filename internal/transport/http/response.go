@@ -1,11 +1,19 @@
 package httptransport
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"math"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"Avito2025/internal/domain"
+	"Avito2025/internal/links"
+	"Avito2025/internal/metrics"
+	"Avito2025/internal/storage"
 )
 
 type errorResponse struct {
@@ -13,36 +21,124 @@ type errorResponse struct {
 }
 
 type errorPayload struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
 }
 
 type teamPayload struct {
 	TeamName string              `json:"team_name"`
 	Members  []teamMemberPayload `json:"members"`
+	Links    linksPayload        `json:"links"`
 }
 
 type teamMemberPayload struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	IsActive bool   `json:"is_active"`
+	UserID   string       `json:"user_id"`
+	Username string       `json:"username"`
+	IsActive bool         `json:"is_active"`
+	Links    linksPayload `json:"links"`
+}
+
+type teamSummaryPayload struct {
+	TeamName         string `json:"team_name"`
+	Members          int    `json:"members"`
+	ActiveMembers    int    `json:"active_members"`
+	OpenPullRequests int    `json:"open_pull_requests"`
+}
+
+type teamSettingsPayload struct {
+	TeamName            string   `json:"team_name"`
+	RequiredReviewers   int      `json:"required_reviewers"`
+	AllowSingleReviewer bool     `json:"allow_single_reviewer"`
+	AllowAuthorReview   bool     `json:"allow_author_review"`
+	RemindAfterHours    int      `json:"remind_after_hours"`
+	EscalateAfterHours  int      `json:"escalate_after_hours"`
+	ReassignAfterHours  int      `json:"reassign_after_hours"`
+	Strategy            string   `json:"strategy"`
+	MaxOpenReviews      int      `json:"max_open_reviews"`
+	ReviewCooldownHours int      `json:"review_cooldown_hours"`
+	AcceptTimeoutHours  int      `json:"accept_timeout_hours"`
+	AutoApproveHours    int      `json:"auto_approve_extension_hours"`
+	AvoidPrevious       bool     `json:"avoid_previous_reviewers"`
+	MandatoryReviewer   string   `json:"mandatory_reviewer_id"`
+	TimeZone            string   `json:"time_zone"`
+	WorkStart           string   `json:"work_start"`
+	WorkEnd             string   `json:"work_end"`
+	WorkDays            []string `json:"work_days"`
+	ShadowPool          []string `json:"shadow_pool"`
+	BlockMergeOnDeps    bool     `json:"block_merge_on_dependencies"`
+}
+
+type ownershipPayload struct {
+	TeamName string                 `json:"team_name"`
+	Rules    []ownershipRulePayload `json:"rules"`
+}
+
+type ownershipRulePayload struct {
+	PathPrefix string   `json:"path_prefix"`
+	UserIDs    []string `json:"user_ids"`
+}
+
+type rotationPayload struct {
+	TeamName string   `json:"team_name"`
+	UserIDs  []string `json:"user_ids"`
+	Position int      `json:"position"`
 }
 
 type userPayload struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	TeamName string `json:"team_name"`
-	IsActive bool   `json:"is_active"`
+	UserID   string   `json:"user_id"`
+	Username string   `json:"username"`
+	TeamName string   `json:"team_name"`
+	Teams    []string `json:"teams"`
+	IsActive bool     `json:"is_active"`
+	// SnoozedUntil is set only while the snooze is in effect.
+	SnoozedUntil *time.Time   `json:"snoozed_until,omitempty"`
+	Links        linksPayload `json:"links"`
+}
+
+type userSummaryPayload struct {
+	userPayload
+	OpenReviews int `json:"open_reviews"`
 }
 
 type pullRequestPayload struct {
-	ID                string     `json:"pull_request_id"`
-	Name              string     `json:"pull_request_name"`
-	AuthorID          string     `json:"author_id"`
-	Status            string     `json:"status"`
-	AssignedReviewers []string   `json:"assigned_reviewers"`
-	CreatedAt         *time.Time `json:"createdAt,omitempty"`
-	MergedAt          *time.Time `json:"mergedAt,omitempty"`
+	ID                string   `json:"pull_request_id"`
+	Name              string   `json:"pull_request_name"`
+	AuthorID          string   `json:"author_id"`
+	Status            string   `json:"status"`
+	AssignedReviewers []string `json:"assigned_reviewers"`
+	Labels            []string `json:"labels"`
+	URL               string   `json:"url,omitempty"`
+	Priority          string   `json:"priority,omitempty"`
+	Description       string   `json:"description,omitempty"`
+	ReviewersCount    int      `json:"reviewers_count,omitempty"`
+	// Reviewers lists the primary reviewer, the secondary ones and the
+	// shadow reviewer in a stable order, each with its role.
+	// AssignedReviewers, sorted by ID, is kept for older clients.
+	Reviewers        []reviewerPayload `json:"reviewers"`
+	ShadowReviewerID string            `json:"shadow_reviewer_id,omitempty"`
+	CoAuthorIDs      []string          `json:"co_author_ids,omitempty"`
+	// Reassignments counts how many times a reviewer was replaced.
+	Reassignments int `json:"reassignments"`
+	// NeedsMoreReviewers flags an open pull request short of reviewers.
+	NeedsMoreReviewers bool `json:"needs_more_reviewers"`
+	// BlockedBy lists the unmerged pull requests this one waits for.
+	BlockedBy []string     `json:"blocked_by,omitempty"`
+	Deadline  *time.Time   `json:"deadline,omitempty"`
+	CreatedAt *time.Time   `json:"createdAt,omitempty"`
+	MergedAt  *time.Time   `json:"mergedAt,omitempty"`
+	Links     linksPayload `json:"links"`
+}
+
+type reviewerPayload struct {
+	UserID     string    `json:"user_id"`
+	Role       string    `json:"role"`
+	AssignedAt time.Time `json:"assigned_at"`
+	// Acceptance is PENDING until a required reviewer accepts the review
+	// when the team asks for it; shadow reviewers have none.
+	Acceptance string `json:"acceptance,omitempty"`
+	// Completed is set once a required reviewer marked the review done.
+	Completed bool `json:"completed,omitempty"`
 }
 
 type pullRequestShortPayload struct {
@@ -52,10 +148,360 @@ type pullRequestShortPayload struct {
 	Status   string `json:"status"`
 }
 
+type bulkResultPayload struct {
+	ID    string              `json:"pull_request_id"`
+	PR    *pullRequestPayload `json:"pr,omitempty"`
+	Error *errorPayload       `json:"error,omitempty"`
+}
+
+type userResultPayload struct {
+	UserID     string                  `json:"user_id"`
+	User       *userPayload            `json:"user,omitempty"`
+	Reassigned []reviewHandoverPayload `json:"reassigned,omitempty"`
+	Error      *errorPayload           `json:"error,omitempty"`
+}
+
+type fairnessPayload struct {
+	TeamName    string                    `json:"team_name"`
+	Since       time.Time                 `json:"since"`
+	Total       int                       `json:"total_assignments"`
+	Gini        float64                   `json:"gini"`
+	MaxMinRatio *float64                  `json:"max_min_ratio"`
+	Members     []assignmentCountPayload  `json:"members"`
+	Buckets     []assignmentBucketPayload `json:"buckets"`
+}
+
+type timeToReviewPayload struct {
+	TeamName string                    `json:"team_name"`
+	Since    time.Time                 `json:"since"`
+	Weeks    []timeToReviewWeekPayload `json:"weeks"`
+}
+
+type weekCountPayload struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+type busiestReviewerPayload struct {
+	UserID      string `json:"user_id"`
+	Assignments int    `json:"assignments"`
+}
+
+type teamStatsPayload struct {
+	TeamName         string                  `json:"team_name"`
+	Since            time.Time               `json:"since"`
+	OpenPullRequests int                     `json:"open_pull_requests"`
+	PullRequests     int                     `json:"pull_requests"`
+	AvgReviewers     float64                 `json:"avg_reviewers"`
+	ReassignmentRate float64                 `json:"reassignment_rate"`
+	BusiestReviewer  *busiestReviewerPayload `json:"busiest_reviewer"`
+	MergesPerWeek    []weekCountPayload      `json:"merges_per_week"`
+}
+
+type extensionPayload struct {
+	ID            int64      `json:"extension_id"`
+	PullRequestID string     `json:"pull_request_id"`
+	ReviewerID    string     `json:"reviewer_id"`
+	Until         time.Time  `json:"until"`
+	Reason        string     `json:"reason,omitempty"`
+	Status        string     `json:"status"`
+	RequestedAt   time.Time  `json:"requested_at"`
+	DecidedBy     string     `json:"decided_by,omitempty"`
+	DecidedAt     *time.Time `json:"decided_at,omitempty"`
+}
+
+type acceptancePayload struct {
+	TeamName      string    `json:"team_name"`
+	Since         time.Time `json:"since"`
+	Requested     int       `json:"requested"`
+	Accepted      int       `json:"accepted"`
+	Expired       int       `json:"expired"`
+	Pending       int       `json:"pending"`
+	MedianSeconds float64   `json:"median_seconds"`
+	P90Seconds    float64   `json:"p90_seconds"`
+}
+
+type forecastPayload struct {
+	TeamName     string                  `json:"team_name"`
+	Since        time.Time               `json:"since"`
+	From         time.Time               `json:"from"`
+	To           time.Time               `json:"to"`
+	PullRequests float64                 `json:"expected_pull_requests"`
+	Unfilled     float64                 `json:"unfilled_slots"`
+	Members      []memberForecastPayload `json:"members"`
+}
+
+type memberForecastPayload struct {
+	UserID       string  `json:"user_id"`
+	Username     string  `json:"username"`
+	Availability float64 `json:"availability"`
+	Assignments  float64 `json:"expected_assignments"`
+}
+
+type dailyStatsPayload struct {
+	Day             string   `json:"day"`
+	TeamName        string   `json:"team_name"`
+	UserID          string   `json:"user_id"`
+	PRsCreated      int      `json:"prs_created"`
+	PRsMerged       int      `json:"prs_merged"`
+	Reassignments   int      `json:"reassignments"`
+	AvgMergeSeconds *float64 `json:"avg_merge_seconds"`
+}
+
+type timeToReviewWeekPayload struct {
+	Start         time.Time `json:"start"`
+	Count         int       `json:"count"`
+	MedianSeconds float64   `json:"median_seconds"`
+	P90Seconds    float64   `json:"p90_seconds"`
+}
+
+type assignmentDecisionPayload struct {
+	Reason         string                     `json:"reason"`
+	TeamName       string                     `json:"team_name"`
+	Strategy       string                     `json:"strategy"`
+	Seed           int64                      `json:"seed,omitempty"`
+	Filters        []string                   `json:"filters"`
+	Candidates     []decisionCandidatePayload `json:"candidates"`
+	Selected       []string                   `json:"selected"`
+	Owners         []string                   `json:"owners"`
+	Mandatory      string                     `json:"mandatory_reviewer_id,omitempty"`
+	AuthorFallback bool                       `json:"author_fallback"`
+	CreatedAt      time.Time                  `json:"created_at"`
+}
+
+type decisionCandidatePayload struct {
+	UserID     string `json:"user_id"`
+	ExcludedBy string `json:"excluded_by,omitempty"`
+}
+
+type errorStatsPayload struct {
+	WindowSeconds        int64               `json:"window_seconds"`
+	Requests             int                 `json:"requests"`
+	Errors               int                 `json:"errors"`
+	ErrorRate            float64             `json:"error_rate"`
+	BusinessErrors       int                 `json:"business_errors"`
+	InfrastructureErrors int                 `json:"infrastructure_errors"`
+	Codes                []errorCountPayload `json:"codes"`
+}
+
+type errorCountPayload struct {
+	Code   string  `json:"code"`
+	Status int     `json:"status"`
+	Class  string  `json:"class"`
+	Count  int     `json:"count"`
+	Rate   float64 `json:"rate"`
+}
+
+type underassignedPayload struct {
+	PullRequestID     string    `json:"pull_request_id"`
+	TeamName          string    `json:"team_name"`
+	AssignedReviewers int       `json:"assigned_reviewers"`
+	RequiredReviewers int       `json:"required_reviewers"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+type hotPullRequestPayload struct {
+	PullRequestID     string    `json:"pull_request_id"`
+	Name              string    `json:"pull_request_name"`
+	AuthorID          string    `json:"author_id"`
+	TeamName          string    `json:"team_name"`
+	Reassignments     int       `json:"reassignments"`
+	AssignedReviewers []string  `json:"assigned_reviewers"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+type snapshotPayload struct {
+	ExportedAt   time.Time             `json:"exported_at"`
+	Teams        []snapshotTeamPayload `json:"teams"`
+	Users        []snapshotUser        `json:"users"`
+	PullRequests []snapshotPullRequest `json:"pull_requests"`
+}
+
+type snapshotTeamPayload struct {
+	TeamName string `json:"team_name"`
+	// Settings is omitted for a team that never saved any.
+	Settings *teamSettingsPayload `json:"settings,omitempty"`
+}
+
+type importResultPayload struct {
+	DryRun       bool `json:"dry_run"`
+	Teams        int  `json:"teams"`
+	Users        int  `json:"users"`
+	PullRequests int  `json:"pull_requests"`
+}
+
+// migrationPayload describes one schema migration. Phase and checksum are
+// omitted for a migration applied by a release that does not ship it, and
+// applied_checksum for one applied before checksums were recorded.
+type migrationPayload struct {
+	Name            string     `json:"name"`
+	Phase           string     `json:"phase,omitempty"`
+	Checksum        string     `json:"checksum,omitempty"`
+	AppliedAt       *time.Time `json:"applied_at,omitempty"`
+	AppliedChecksum string     `json:"applied_checksum,omitempty"`
+	// Modified is set when the shipped file differs from the one applied.
+	Modified bool `json:"modified"`
+}
+
+type migrationStatusPayload struct {
+	Applied []migrationPayload `json:"applied"`
+	Pending []migrationPayload `json:"pending"`
+}
+
+type integrityReportPayload struct {
+	CheckedAt time.Time               `json:"checked_at"`
+	Issues    int                     `json:"issues"`
+	Fixed     int                     `json:"fixed"`
+	Checks    map[string]int          `json:"checks"`
+	Details   []integrityIssuePayload `json:"details"`
+}
+
+type integrityIssuePayload struct {
+	Check         string `json:"check"`
+	PullRequestID string `json:"pull_request_id,omitempty"`
+	UserID        string `json:"user_id,omitempty"`
+	TeamName      string `json:"team_name,omitempty"`
+	Fixed         bool   `json:"fixed"`
+}
+
+type webhookPayload struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type teamTokenPayload struct {
+	ID        int64     `json:"id"`
+	TeamName  string    `json:"team_name"`
+	UserID    string    `json:"user_id,omitempty"`
+	Name      string    `json:"name"`
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type webhookDeliveryPayload struct {
+	ID         int64     `json:"id"`
+	EventID    int64     `json:"event_id"`
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Delivered  bool      `json:"delivered"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type maintenancePayload struct {
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type assignmentCountPayload struct {
+	UserID         string `json:"user_id"`
+	Username       string `json:"username"`
+	IsActive       bool   `json:"is_active"`
+	ActiveInPeriod bool   `json:"active_in_period"`
+	Count          int    `json:"count"`
+}
+
+type reviewHandoverPayload struct {
+	PullRequestID string `json:"pull_request_id"`
+	ReplacedBy    string `json:"replaced_by,omitempty"`
+}
+
+type membershipChangePayload struct {
+	UserID    string    `json:"user_id"`
+	Kind      string    `json:"kind"`
+	IsActive  bool      `json:"is_active"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type reviewerChangePayload struct {
+	PullRequestID string    `json:"pull_request_id"`
+	Action        string    `json:"action"`
+	Reason        string    `json:"reason"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type assignmentBucketPayload struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+type notificationPayload struct {
+	UserID      string `json:"user_id"`
+	Channel     string `json:"channel"`
+	Address     string `json:"address,omitempty"`
+	DigestHours int    `json:"digest_hours,omitempty"`
+}
+
+type identityPayload struct {
+	Provider   string    `json:"provider"`
+	ExternalID string    `json:"external_id"`
+	UserID     string    `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type eventPayload struct {
+	Seq       int64          `json:"seq"`
+	Type      string         `json:"type"`
+	TeamName  string         `json:"team_name"`
+	EntityID  string         `json:"entity_id"`
+	Payload   map[string]any `json:"payload"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
 func respondJSON(w http.ResponseWriter, status int, payload any) {
+	if resp, ok := payload.(errorResponse); ok {
+		metrics.ObserveError(status, resp.Error.Code)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(payload)
+	_ = json.NewEncoder(w).Encode(named(w, enveloped(w, payload)))
+}
+
+// respondJSONWithETag writes payload with a weak ETag derived from its encoded
+// form and answers 304 when the client already holds the same representation.
+// The ETag covers the payload only, not the per-request envelope metadata.
+func respondJSONWithETag(w http.ResponseWriter, r *http.Request, status int, payload any) {
+	_, etag, err := encodeWithETag(payload)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "INTERNAL", "internal server error")
+		return
+	}
+	w.Header().Set("ETag", etag)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	respondJSON(w, status, payload)
+}
+
+// encodeWithETag encodes payload and derives its weak ETag.
+func encodeWithETag(payload any) ([]byte, string, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(body)
+	return body, `W/"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// etagMatches implements the weak comparison used by If-None-Match.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
 }
 
 func respondError(w http.ResponseWriter, status int, code, message string) {
@@ -67,32 +513,112 @@ func respondError(w http.ResponseWriter, status int, code, message string) {
 	})
 }
 
-func mapTeam(team domain.Team) teamPayload {
+func mapTeam(team domain.Team, l links.Builder) teamPayload {
 	members := make([]teamMemberPayload, 0, len(team.Members))
 	for _, member := range team.Members {
 		members = append(members, teamMemberPayload{
 			UserID:   member.ID,
 			Username: member.Username,
 			IsActive: member.IsActive,
+			Links:    linksPayload{Reviews: l.Reviews(member.ID)},
 		})
 	}
 
 	return teamPayload{
 		TeamName: team.Name,
 		Members:  members,
+		Links:    linksPayload{Self: l.Team(team.Name)},
+	}
+}
+
+func mapTeamSettings(settings domain.TeamSettings) teamSettingsPayload {
+	days := make([]string, 0, len(settings.WorkDays))
+	for _, day := range settings.WorkDays {
+		days = append(days, strings.ToLower(day.String()[:3]))
+	}
+	return teamSettingsPayload{
+		TeamName:            settings.TeamName,
+		RequiredReviewers:   settings.RequiredReviewers,
+		AllowSingleReviewer: settings.AllowSingleReviewer,
+		AllowAuthorReview:   settings.AllowAuthorReview,
+		RemindAfterHours:    int(settings.RemindAfter.Hours()),
+		EscalateAfterHours:  int(settings.EscalateAfter.Hours()),
+		ReassignAfterHours:  int(settings.ReassignAfter.Hours()),
+		Strategy:            string(settings.Strategy),
+		MaxOpenReviews:      settings.MaxOpenReviews,
+		ReviewCooldownHours: int(settings.ReviewCooldown.Hours()),
+		AcceptTimeoutHours:  int(settings.AcceptTimeout.Hours()),
+		AutoApproveHours:    int(settings.AutoApproveExtension.Hours()),
+		AvoidPrevious:       settings.AvoidPreviousReviewers,
+		MandatoryReviewer:   settings.MandatoryReviewer,
+		TimeZone:            settings.TimeZone,
+		WorkStart:           formatClock(settings.WorkStart),
+		WorkEnd:             formatClock(settings.WorkEnd),
+		WorkDays:            days,
+		ShadowPool:          append([]string{}, settings.ShadowPool...),
+		BlockMergeOnDeps:    settings.BlockMergeOnDependencies,
+	}
+}
+
+func mapTeamSummary(team domain.TeamSummary) teamSummaryPayload {
+	return teamSummaryPayload{
+		TeamName:         team.Name,
+		Members:          team.Members,
+		ActiveMembers:    team.ActiveMembers,
+		OpenPullRequests: team.OpenPullRequests,
+	}
+}
+
+func mapRotation(rotation domain.Rotation) rotationPayload {
+	userIDs := append([]string{}, rotation.UserIDs...)
+	return rotationPayload{
+		TeamName: rotation.TeamName,
+		UserIDs:  userIDs,
+		Position: rotation.Position,
+	}
+}
+
+func mapOwnership(ownership domain.Ownership) ownershipPayload {
+	rules := make([]ownershipRulePayload, 0, len(ownership.Rules))
+	for _, rule := range ownership.Rules {
+		rules = append(rules, ownershipRulePayload{
+			PathPrefix: rule.PathPrefix,
+			UserIDs:    append([]string{}, rule.UserIDs...),
+		})
+	}
+	return ownershipPayload{
+		TeamName: ownership.TeamName,
+		Rules:    rules,
 	}
 }
 
-func mapUser(user domain.User) userPayload {
-	return userPayload{
+func mapUser(user domain.User, l links.Builder) userPayload {
+	payload := userPayload{
 		UserID:   user.ID,
 		Username: user.Username,
 		TeamName: user.TeamName,
+		Teams:    append([]string{}, user.Teams...),
 		IsActive: user.IsActive,
+		Links: linksPayload{
+			Team:    l.Team(user.TeamName),
+			Reviews: l.Reviews(user.ID),
+		},
+	}
+	if user.Snoozed(time.Now()) {
+		until := user.SnoozedUntil.UTC()
+		payload.SnoozedUntil = &until
+	}
+	return payload
+}
+
+func mapUserSummary(user domain.UserSummary, l links.Builder) userSummaryPayload {
+	return userSummaryPayload{
+		userPayload: mapUser(user.User, l),
+		OpenReviews: user.OpenReviews,
 	}
 }
 
-func mapPullRequest(pr domain.PullRequest) pullRequestPayload {
+func mapPullRequest(pr domain.PullRequest, l links.Builder) pullRequestPayload {
 	var createdAt *time.Time
 	if !pr.CreatedAt.IsZero() {
 		ts := pr.CreatedAt
@@ -100,21 +626,575 @@ func mapPullRequest(pr domain.PullRequest) pullRequestPayload {
 	}
 
 	return pullRequestPayload{
-		ID:                pr.ID,
-		Name:              pr.Name,
-		AuthorID:          pr.AuthorID,
-		Status:            string(pr.Status),
-		AssignedReviewers: append([]string(nil), pr.AssignedReviewers...),
-		CreatedAt:         createdAt,
-		MergedAt:          pr.MergedAt,
+		ID:                 pr.ID,
+		Name:               pr.Name,
+		AuthorID:           pr.AuthorID,
+		Status:             string(pr.Status),
+		AssignedReviewers:  append([]string{}, pr.AssignedReviewers...),
+		Labels:             append([]string{}, pr.Labels...),
+		URL:                pr.URL,
+		Priority:           string(pr.Priority),
+		Description:        pr.Description,
+		ReviewersCount:     pr.ReviewersCount,
+		Reviewers:          mapReviewers(pr),
+		ShadowReviewerID:   pr.ShadowReviewer,
+		CoAuthorIDs:        pr.CoAuthors,
+		Reassignments:      pr.Reassignments,
+		NeedsMoreReviewers: pr.NeedsMoreReviewers,
+		BlockedBy:          pr.BlockedBy,
+		Deadline:           pr.Deadline,
+		CreatedAt:          createdAt,
+		MergedAt:           pr.MergedAt,
+		Links:              linksPayload{Self: l.PullRequest(pr.ID)},
+	}
+}
+
+func mapReviewers(pr domain.PullRequest) []reviewerPayload {
+	ordered := pr.OrderedReviewers()
+	reviewers := make([]reviewerPayload, 0, len(ordered))
+	for _, reviewer := range ordered {
+		payload := reviewerPayload{
+			UserID:     reviewer.UserID,
+			Role:       string(reviewer.Role),
+			AssignedAt: reviewer.AssignedAt,
+		}
+		if reviewer.Role != domain.RoleShadow {
+			acceptance, ok := pr.Acceptance[reviewer.UserID]
+			if !ok {
+				acceptance = domain.AcceptanceAccepted
+			}
+			payload.Acceptance = string(acceptance)
+			payload.Completed = slices.Contains(pr.CompletedReviewers, reviewer.UserID)
+		}
+		reviewers = append(reviewers, payload)
+	}
+	return reviewers
+}
+
+func mapPullRequestShort(pr domain.PullRequest, l links.Builder) map[string]any {
+	return map[string]any{
+		"pull_request_id":      pr.ID,
+		"pull_request_name":    pr.Name,
+		"author_id":            pr.AuthorID,
+		"status":               string(pr.Status),
+		"needs_more_reviewers": pr.NeedsMoreReviewers,
+		"links":                linksPayload{Self: l.PullRequest(pr.ID)},
 	}
 }
 
-func mapPullRequestShort(pr domain.PullRequest) map[string]any {
+func mapUserReviews(userID string, prs []domain.PullRequest, l links.Builder) map[string]any {
+	result := make([]map[string]any, 0, len(prs))
+	for _, pr := range prs {
+		result = append(result, mapPullRequestShort(pr, l))
+	}
 	return map[string]any{
-		"pull_request_id":   pr.ID,
-		"pull_request_name": pr.Name,
-		"author_id":         pr.AuthorID,
-		"status":            string(pr.Status),
+		"user_id":       userID,
+		"pull_requests": result,
+		"links":         linksPayload{Self: l.Reviews(userID)},
+	}
+}
+
+func mapEvent(event domain.Event) eventPayload {
+	payload := event.Payload
+	if payload == nil {
+		payload = map[string]any{}
+	}
+
+	return eventPayload{
+		Seq:       event.Seq,
+		Type:      string(event.Type),
+		TeamName:  event.TeamName,
+		EntityID:  event.EntityID,
+		Payload:   payload,
+		CreatedAt: event.CreatedAt,
+	}
+}
+
+func mapNotification(pref domain.NotificationPreference) notificationPayload {
+	return notificationPayload{
+		UserID:      pref.UserID,
+		Channel:     string(pref.Channel),
+		Address:     pref.Address,
+		DigestHours: int(pref.Digest / time.Hour),
+	}
+}
+
+func mapIdentity(identity domain.Identity) identityPayload {
+	return identityPayload{
+		Provider:   string(identity.Provider),
+		ExternalID: identity.ExternalID,
+		UserID:     identity.UserID,
+		CreatedAt:  identity.CreatedAt,
+	}
+}
+
+func mapBulkResult(result domain.PullRequestResult, l links.Builder) bulkResultPayload {
+	if result.Err != nil {
+		_, payload := describeError(result.Err)
+		return bulkResultPayload{ID: result.PullRequest.ID, Error: &payload}
+	}
+
+	pr := mapPullRequest(result.PullRequest, l)
+	return bulkResultPayload{ID: result.PullRequest.ID, PR: &pr}
+}
+
+func mapUserResult(result domain.UserResult, reassigned bool, l links.Builder) userResultPayload {
+	if result.Err != nil {
+		_, payload := describeError(result.Err)
+		return userResultPayload{UserID: result.UserID, Error: &payload}
+	}
+
+	user := mapUser(result.User, l)
+	payload := userResultPayload{UserID: result.UserID, User: &user}
+	if reassigned {
+		payload.Reassigned = mapReviewHandovers(result.Reassigned)
+	}
+	return payload
+}
+
+func mapTimeToReviewReport(report domain.TimeToReviewReport) timeToReviewPayload {
+	weeks := make([]timeToReviewWeekPayload, 0, len(report.Weeks))
+	for _, week := range report.Weeks {
+		weeks = append(weeks, timeToReviewWeekPayload{
+			Start:         week.Start,
+			Count:         week.Count,
+			MedianSeconds: week.Median.Seconds(),
+			P90Seconds:    week.P90.Seconds(),
+		})
+	}
+
+	return timeToReviewPayload{
+		TeamName: report.TeamName,
+		Since:    report.Since,
+		Weeks:    weeks,
+	}
+}
+
+func mapTeamStats(stats domain.TeamStats) teamStatsPayload {
+	weeks := make([]weekCountPayload, 0, len(stats.MergesPerWeek))
+	for _, week := range stats.MergesPerWeek {
+		weeks = append(weeks, weekCountPayload{Start: week.Start, Count: week.Count})
+	}
+
+	payload := teamStatsPayload{
+		TeamName:         stats.TeamName,
+		Since:            stats.Since,
+		OpenPullRequests: stats.OpenPullRequests,
+		PullRequests:     stats.PullRequests,
+		AvgReviewers:     stats.AvgReviewers,
+		ReassignmentRate: stats.ReassignmentRate,
+		MergesPerWeek:    weeks,
+	}
+	if stats.BusiestReviewer != "" {
+		payload.BusiestReviewer = &busiestReviewerPayload{
+			UserID:      stats.BusiestReviewer,
+			Assignments: stats.BusiestReviewerAssignments,
+		}
+	}
+	return payload
+}
+
+func mapExtension(extension domain.DeadlineExtension) extensionPayload {
+	return extensionPayload{
+		ID:            extension.ID,
+		PullRequestID: extension.PullRequestID,
+		ReviewerID:    extension.ReviewerID,
+		Until:         extension.Until,
+		Reason:        extension.Reason,
+		Status:        string(extension.Status),
+		RequestedAt:   extension.RequestedAt,
+		DecidedBy:     extension.DecidedBy,
+		DecidedAt:     extension.DecidedAt,
+	}
+}
+
+func mapAcceptanceReport(report domain.AcceptanceReport) acceptancePayload {
+	return acceptancePayload{
+		TeamName:      report.TeamName,
+		Since:         report.Since,
+		Requested:     report.Requested,
+		Accepted:      report.Accepted,
+		Expired:       report.Expired,
+		Pending:       report.Pending,
+		MedianSeconds: report.Median.Seconds(),
+		P90Seconds:    report.P90.Seconds(),
+	}
+}
+
+func mapLoadForecast(forecast domain.LoadForecast) forecastPayload {
+	members := make([]memberForecastPayload, 0, len(forecast.Members))
+	for _, member := range forecast.Members {
+		members = append(members, memberForecastPayload{
+			UserID:       member.UserID,
+			Username:     member.Username,
+			Availability: roundForecast(member.Availability),
+			Assignments:  roundForecast(member.Assignments),
+		})
+	}
+	return forecastPayload{
+		TeamName:     forecast.TeamName,
+		Since:        forecast.Since,
+		From:         forecast.From,
+		To:           forecast.To,
+		PullRequests: roundForecast(forecast.PullRequests),
+		Unfilled:     roundForecast(forecast.Unfilled),
+		Members:      members,
+	}
+}
+
+// roundForecast keeps two decimals of an estimate; more would suggest a
+// precision it does not have.
+func roundForecast(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func mapDailyStats(stats []domain.DailyReviewStats) []dailyStatsPayload {
+	payload := make([]dailyStatsPayload, 0, len(stats))
+	for _, day := range stats {
+		item := dailyStatsPayload{
+			Day:           day.Day.Format(time.DateOnly),
+			TeamName:      day.TeamName,
+			UserID:        day.UserID,
+			PRsCreated:    day.Created,
+			PRsMerged:     day.Merged,
+			Reassignments: day.Reassignments,
+		}
+		if day.Merged > 0 {
+			seconds := day.AvgTimeToMerge.Seconds()
+			item.AvgMergeSeconds = &seconds
+		}
+		payload = append(payload, item)
+	}
+	return payload
+}
+
+func mapAssignmentDecision(decision domain.AssignmentDecision) assignmentDecisionPayload {
+	candidates := make([]decisionCandidatePayload, 0, len(decision.Candidates))
+	for _, candidate := range decision.Candidates {
+		candidates = append(candidates, decisionCandidatePayload{
+			UserID:     candidate.UserID,
+			ExcludedBy: candidate.ExcludedBy,
+		})
+	}
+
+	return assignmentDecisionPayload{
+		Reason:         decision.Reason,
+		TeamName:       decision.TeamName,
+		Strategy:       decision.Strategy,
+		Seed:           decision.Seed,
+		Filters:        append([]string{}, decision.Filters...),
+		Candidates:     candidates,
+		Selected:       append([]string{}, decision.Selected...),
+		Owners:         append([]string{}, decision.Owners...),
+		Mandatory:      decision.Mandatory,
+		AuthorFallback: decision.AuthorFallback,
+		CreatedAt:      decision.CreatedAt,
+	}
+}
+
+// mapWebhook renders a webhook; the secret is included only when withSecret
+// is set.
+func mapWebhook(webhook domain.Webhook, withSecret bool) webhookPayload {
+	events := make([]string, 0, len(webhook.Events))
+	for _, event := range webhook.Events {
+		events = append(events, string(event))
+	}
+	payload := webhookPayload{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Events:    events,
+		CreatedAt: webhook.CreatedAt,
+	}
+	if withSecret {
+		payload.Secret = webhook.Secret
+	}
+	return payload
+}
+
+func mapTeamToken(token domain.TeamToken) teamTokenPayload {
+	return teamTokenPayload{
+		ID:        token.ID,
+		TeamName:  token.TeamName,
+		UserID:    token.UserID,
+		Name:      token.Name,
+		Token:     token.Token,
+		CreatedAt: token.CreatedAt,
+	}
+}
+
+func mapWebhookDelivery(delivery domain.WebhookDelivery) webhookDeliveryPayload {
+	return webhookDeliveryPayload{
+		ID:         delivery.ID,
+		EventID:    delivery.EventSeq,
+		Event:      string(delivery.Event),
+		Attempt:    delivery.Attempt,
+		StatusCode: delivery.StatusCode,
+		Error:      delivery.Error,
+		Delivered:  delivery.Delivered,
+		CreatedAt:  delivery.CreatedAt,
+	}
+}
+
+func mapMaintenance(state domain.Maintenance) maintenancePayload {
+	return maintenancePayload{
+		Enabled:   state.Enabled,
+		UpdatedAt: state.UpdatedAt,
+	}
+}
+
+func mapUnderassigned(prs []domain.UnderassignedPullRequest) []underassignedPayload {
+	result := make([]underassignedPayload, 0, len(prs))
+	for _, pr := range prs {
+		result = append(result, underassignedPayload{
+			PullRequestID:     pr.PullRequestID,
+			TeamName:          pr.TeamName,
+			AssignedReviewers: pr.Assigned,
+			RequiredReviewers: pr.Required,
+			CreatedAt:         pr.CreatedAt,
+		})
+	}
+	return result
+}
+
+func mapHotPullRequests(prs []domain.HotPullRequest) []hotPullRequestPayload {
+	result := make([]hotPullRequestPayload, 0, len(prs))
+	for _, pr := range prs {
+		result = append(result, hotPullRequestPayload{
+			PullRequestID:     pr.PullRequestID,
+			Name:              pr.Name,
+			AuthorID:          pr.AuthorID,
+			TeamName:          pr.TeamName,
+			Reassignments:     pr.Reassignments,
+			AssignedReviewers: append([]string{}, pr.AssignedReviewers...),
+			CreatedAt:         pr.CreatedAt,
+		})
+	}
+	return result
+}
+
+// mapIntegrityReport counts the issues of every check, listing checks that
+// found nothing with zero.
+func mapSnapshot(snapshot domain.Snapshot) snapshotPayload {
+	settings := make(map[string]domain.TeamSettings, len(snapshot.Settings))
+	for _, team := range snapshot.Settings {
+		settings[team.TeamName] = team
+	}
+	payload := snapshotPayload{
+		ExportedAt:   snapshot.ExportedAt,
+		Teams:        make([]snapshotTeamPayload, 0, len(snapshot.Teams)),
+		Users:        make([]snapshotUser, 0, len(snapshot.Users)),
+		PullRequests: make([]snapshotPullRequest, 0, len(snapshot.PullRequests)),
+	}
+	for _, name := range snapshot.Teams {
+		team := snapshotTeamPayload{TeamName: name}
+		if saved, ok := settings[name]; ok {
+			mapped := mapTeamSettings(saved)
+			team.Settings = &mapped
+		}
+		payload.Teams = append(payload.Teams, team)
+	}
+	for _, user := range snapshot.Users {
+		payload.Users = append(payload.Users, snapshotUser{
+			UserID:       user.ID,
+			Username:     user.Username,
+			TeamName:     user.TeamName,
+			Teams:        append([]string{}, user.Teams...),
+			IsActive:     user.IsActive,
+			SnoozedUntil: user.SnoozedUntil,
+		})
+	}
+	for _, pr := range snapshot.PullRequests {
+		var reviewers []snapshotReviewer
+		for _, reviewer := range pr.Reviewers {
+			reviewers = append(reviewers, snapshotReviewer{
+				UserID:     reviewer.UserID,
+				Role:       string(reviewer.Role),
+				AssignedAt: reviewer.AssignedAt,
+			})
+		}
+		payload.PullRequests = append(payload.PullRequests, snapshotPullRequest{
+			ID:                pr.ID,
+			Name:              pr.Name,
+			AuthorID:          pr.AuthorID,
+			Status:            string(pr.Status),
+			AssignedReviewers: append([]string{}, pr.AssignedReviewers...),
+			ShadowReviewer:    pr.ShadowReviewer,
+			Reviewers:         reviewers,
+			CoAuthorIDs:       pr.CoAuthors,
+			ReviewersCount:    pr.ReviewersCount,
+			Reassignments:     pr.Reassignments,
+			Labels:            append([]string{}, pr.Labels...),
+			URL:               pr.URL,
+			Priority:          string(pr.Priority),
+			Description:       pr.Description,
+			Files:             append([]string{}, pr.Files...),
+			CreatedAt:         pr.CreatedAt,
+			MergedAt:          pr.MergedAt,
+		})
+	}
+	return payload
+}
+
+func mapImportResult(result domain.ImportResult) importResultPayload {
+	return importResultPayload{
+		DryRun:       result.DryRun,
+		Teams:        result.Teams,
+		Users:        result.Users,
+		PullRequests: result.PullRequests,
+	}
+}
+
+func mapMigrationStatus(migrations []storage.MigrationStatus) migrationStatusPayload {
+	payload := migrationStatusPayload{
+		Applied: []migrationPayload{},
+		Pending: []migrationPayload{},
+	}
+	for _, m := range migrations {
+		item := migrationPayload{
+			Name:            m.Name,
+			Phase:           m.Phase,
+			Checksum:        m.Checksum,
+			AppliedAt:       m.AppliedAt,
+			AppliedChecksum: m.AppliedChecksum,
+			Modified:        m.Checksum != "" && m.AppliedChecksum != "" && m.Checksum != m.AppliedChecksum,
+		}
+		if m.AppliedAt == nil {
+			payload.Pending = append(payload.Pending, item)
+		} else {
+			payload.Applied = append(payload.Applied, item)
+		}
+	}
+	return payload
+}
+
+func mapIntegrityReport(report domain.IntegrityReport) integrityReportPayload {
+	payload := integrityReportPayload{
+		CheckedAt: report.CheckedAt,
+		Issues:    len(report.Issues),
+		Checks: map[string]int{
+			domain.IntegrityUnknownReviewer:    0,
+			domain.IntegrityAuthorReviewer:     0,
+			domain.IntegrityFutureMerge:        0,
+			domain.IntegrityMissingTeam:        0,
+			domain.IntegrityOrphanedCompletion: 0,
+			domain.IntegrityOrphanedAcceptance: 0,
+		},
+		Details: make([]integrityIssuePayload, 0, len(report.Issues)),
+	}
+	for _, issue := range report.Issues {
+		payload.Checks[issue.Check]++
+		if issue.Fixed {
+			payload.Fixed++
+		}
+		payload.Details = append(payload.Details, integrityIssuePayload{
+			Check:         issue.Check,
+			PullRequestID: issue.PullRequestID,
+			UserID:        issue.UserID,
+			TeamName:      issue.TeamName,
+			Fixed:         issue.Fixed,
+		})
+	}
+	return payload
+}
+
+// mapErrorStats reports error rates as shares of all requests in the window;
+// rates are zero when no request was seen.
+func mapErrorStats(summary metrics.ErrorSummary) errorStatsPayload {
+	rate := func(count int) float64 {
+		if summary.Requests == 0 {
+			return 0
+		}
+		return float64(count) / float64(summary.Requests)
+	}
+
+	payload := errorStatsPayload{
+		WindowSeconds: int64(summary.Window.Seconds()),
+		Requests:      summary.Requests,
+		Codes:         make([]errorCountPayload, 0, len(summary.Codes)),
+	}
+	for _, count := range summary.Codes {
+		payload.Errors += count.Count
+		if count.Class == metrics.ClassInfrastructure {
+			payload.InfrastructureErrors += count.Count
+		} else {
+			payload.BusinessErrors += count.Count
+		}
+		payload.Codes = append(payload.Codes, errorCountPayload{
+			Code:   count.Code,
+			Status: count.Status,
+			Class:  count.Class,
+			Count:  count.Count,
+			Rate:   rate(count.Count),
+		})
+	}
+	payload.ErrorRate = rate(payload.Errors)
+	return payload
+}
+
+func mapReviewHandovers(handovers []domain.ReviewHandover) []reviewHandoverPayload {
+	result := make([]reviewHandoverPayload, 0, len(handovers))
+	for _, handover := range handovers {
+		result = append(result, reviewHandoverPayload{
+			PullRequestID: handover.PullRequestID,
+			ReplacedBy:    handover.ReplacedBy,
+		})
+	}
+	return result
+}
+
+func mapMembershipHistory(changes []domain.MembershipChange) []membershipChangePayload {
+	result := make([]membershipChangePayload, 0, len(changes))
+	for _, change := range changes {
+		result = append(result, membershipChangePayload{
+			UserID:    change.UserID,
+			Kind:      string(change.Kind),
+			IsActive:  change.IsActive,
+			ChangedAt: change.ChangedAt,
+		})
+	}
+	return result
+}
+
+func mapReviewerHistory(changes []domain.ReviewerChange) []reviewerChangePayload {
+	result := make([]reviewerChangePayload, 0, len(changes))
+	for _, change := range changes {
+		result = append(result, reviewerChangePayload{
+			PullRequestID: change.PullRequestID,
+			Action:        string(change.Action),
+			Reason:        change.Reason,
+			CreatedAt:     change.CreatedAt,
+		})
+	}
+	return result
+}
+
+func mapFairnessReport(report domain.FairnessReport) fairnessPayload {
+	members := make([]assignmentCountPayload, 0, len(report.Members))
+	for _, member := range report.Members {
+		members = append(members, assignmentCountPayload{
+			UserID:         member.UserID,
+			Username:       member.Username,
+			IsActive:       member.IsActive,
+			ActiveInPeriod: member.ActiveInPeriod,
+			Count:          member.Count,
+		})
+	}
+
+	buckets := make([]assignmentBucketPayload, 0, len(report.Buckets))
+	for _, bucket := range report.Buckets {
+		buckets = append(buckets, assignmentBucketPayload{
+			Start: bucket.Start,
+			Count: bucket.Count,
+		})
+	}
+
+	return fairnessPayload{
+		TeamName:    report.TeamName,
+		Since:       report.Since,
+		Total:       report.Total,
+		Gini:        report.Gini,
+		MaxMinRatio: report.MaxMinRatio,
+		Members:     members,
+		Buckets:     buckets,
 	}
 }
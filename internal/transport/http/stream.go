@@ -0,0 +1,84 @@
+package httptransport
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteTimeout = 5 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingPeriod   = wsPongTimeout * 9 / 10
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Dashboards are served from other origins; the stream is read-only.
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// StreamEvents upgrades to a WebSocket and pushes live events as JSON text
+// frames. An optional comma-separated team_name query parameter limits the
+// stream to the given teams.
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	var teams []string
+	for _, team := range strings.Split(r.URL.Query().Get("team_name"), ",") {
+		if team = strings.TrimSpace(team); team != "" {
+			teams = append(teams, team)
+		}
+	}
+
+	// Subscribe before completing the handshake so that nothing published
+	// after the client sees the connection open is missed.
+	events, cancel := h.service.SubscribeEvents(teams)
+	defer cancel()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error response.
+		return
+	}
+	defer conn.Close()
+
+	// The read loop handles control frames and notices when the client leaves.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		_ = conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(mapEvent(event)); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package httptransport
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// v1Routes registers the version 1 API. A later version gets its own routes
+// function and, where payloads differ, its own DTOs and mappers, while sharing
+// the service calls with this one.
+func (h *Handler) v1Routes() http.Handler {
+	r := chi.NewRouter()
+
+	r.Route("/team", func(r chi.Router) {
+		r.Post("/add", h.CreateTeam)
+		r.Get("/get", h.GetTeam)
+		r.Get("/list", h.ListTeams)
+		r.Get("/settings", h.GetTeamSettings)
+		r.Put("/settings", h.UpdateTeamSettings)
+		r.Post("/setRotation", h.SetRotation)
+		r.Get("/getRotation", h.GetRotation)
+		r.Post("/setOwnership", h.SetOwnership)
+		r.Get("/getOwnership", h.GetOwnership)
+		r.With(requireAdmin(h.cfg.AdminToken)).Post("/setLead", h.SetTeamLead)
+		r.Get("/getLeads", h.GetTeamLeads)
+		r.Get("/history", h.GetTeamHistory)
+		r.Post("/merge", h.MergeTeams)
+		r.Post("/split", h.SplitTeam)
+	})
+
+	r.Route("/users", func(r chi.Router) {
+		r.Get("/list", h.ListUsers)
+		r.Post("/setIsActive", h.SetUserActive)
+		r.Post("/bulkSetIsActive", h.BulkSetUserActive)
+		r.Post("/snooze", h.SnoozeUser)
+		r.Post("/update", h.UpdateUser)
+		r.Get("/getReview", h.GetUserReviews)
+		r.Get("/getReview/wait", h.WaitUserReviews)
+		r.Get("/assignmentHistory", h.GetAssignmentHistory)
+		r.Post("/setNotifications", h.SetNotifications)
+		r.Get("/getNotifications", h.GetNotifications)
+		r.Post("/addIdentity", h.AddIdentity)
+		r.Get("/getIdentities", h.GetIdentities)
+		r.Get("/resolveIdentity", h.ResolveIdentity)
+	})
+
+	r.Route("/pullRequest", func(r chi.Router) {
+		r.Get("/get", h.GetPullRequest)
+		r.Post("/create", h.CreatePullRequest)
+		r.Post("/bulkCreate", h.BulkCreatePullRequests)
+		r.Post("/bulkMerge", h.BulkMergePullRequests)
+		r.Patch("/update", h.UpdatePullRequest)
+		r.Post("/merge", h.MergePullRequest)
+		r.Post("/markReady", h.MarkPullRequestReady)
+		r.Post("/reassign", h.ReassignReviewer)
+		r.Post("/addReviewer", h.AddReviewer)
+		r.Post("/review", h.RecordReview)
+		r.Post("/acceptReview", h.AcceptReview)
+		r.Post("/completeReview", h.CompleteReview)
+		r.Post("/link", h.LinkPullRequests)
+		r.Post("/unlink", h.UnlinkPullRequests)
+		r.Post("/extendDeadline", h.ExtendDeadline)
+		r.Post("/decideExtension", h.DecideExtension)
+		r.Get("/extensions", h.GetExtensions)
+		r.Get("/assignmentTrace", h.GetAssignmentTrace)
+		r.With(requireAdmin(h.cfg.AdminToken)).Post("/assign", h.AssignReviewers)
+	})
+
+	r.Route("/stats", func(r chi.Router) {
+		r.Get("/fairness", h.GetFairness)
+		r.Get("/timeToReview", h.GetTimeToReview)
+		r.Get("/team", h.GetTeamStats)
+		r.Get("/acceptance", h.GetAcceptance)
+		r.Get("/forecast", h.GetForecast)
+		r.Get("/underassigned", h.GetUnderassigned)
+		r.Get("/hotPRs", h.GetHotPullRequests)
+		r.Get("/daily", h.GetDailyStats)
+		r.Get("/errors", h.GetErrorStats)
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(requireAdmin(h.cfg.AdminToken))
+		r.Get("/dbstats", h.DBStats)
+		r.Get("/migrations", h.GetMigrations)
+		r.Post("/archive", h.ArchivePullRequests)
+		r.Post("/integrity", h.CheckIntegrity)
+		r.Post("/export", h.ExportSnapshot)
+		r.Post("/import", h.ImportSnapshot)
+		r.Get("/maintenance", h.GetMaintenance)
+		r.Post("/maintenance", h.SetMaintenance)
+		r.Get("/webhooks", h.ListWebhooks)
+		r.Post("/webhooks", h.CreateWebhook)
+		r.Put("/webhooks", h.UpdateWebhook)
+		r.Delete("/webhooks", h.DeleteWebhook)
+		r.Get("/webhooks/deliveries", h.ListWebhookDeliveries)
+		r.Get("/tokens", h.ListTeamTokens)
+		r.Post("/tokens", h.CreateTeamToken)
+		r.Delete("/tokens", h.DeleteTeamToken)
+	})
+	if h.cfg.EnableTestEndpoints {
+		r.Route("/test", func(r chi.Router) {
+			r.Post("/reset", h.ResetData)
+			r.Post("/seed", h.SeedData)
+		})
+	}
+	r.Get("/changes", h.ListChanges)
+	r.Get("/ws", h.StreamEvents)
+
+	return r
+}
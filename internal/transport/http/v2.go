@@ -0,0 +1,18 @@
+package httptransport
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// v2Routes registers the version 2 API. It serves the v1 routes with every
+// response wrapped in the {"data", "meta"} envelope by the envelope
+// middleware; endpoints whose v1 payload breaks the conventions get handlers
+// of their own here.
+func (h *Handler) v2Routes() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/team/get", h.GetTeamV2)
+	r.Mount("/", h.v1Routes())
+	return r
+}
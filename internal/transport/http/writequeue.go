@@ -0,0 +1,181 @@
+package httptransport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"Avito2025/internal/metrics"
+	"Avito2025/internal/writequeue"
+)
+
+// journaledHeaders are the request headers kept with a queued write.
+// Credentials are left out; see writequeue.Entry.Trusted and TokenHash.
+var journaledHeaders = []string{"Content-Type", callerHeader, "X-Request-Id"}
+
+type replayKey struct{}
+
+// isReplay reports whether r is a queued write being replayed.
+func isReplay(r *http.Request) bool {
+	replay, _ := r.Context().Value(replayKey{}).(bool)
+	return replay
+}
+
+// SetWriteQueue enables queueing of writes while the storage is down. It
+// must be called before Router.
+func (h *Handler) SetWriteQueue(q *writequeue.Queue) {
+	h.writes = q
+}
+
+// queueWrites journals mutating requests to the write queue instead of
+// handling them while the storage does not answer its health ping, and while
+// earlier writes wait for replay so that writes apply in order. The caller
+// gets 202 with the sequence number of its write, or 503 when the queue is
+// full. Admin and test endpoints are never queued.
+func (h *Handler) queueWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if isReplay(r) || strings.Contains(r.URL.Path, "/admin/") || strings.Contains(r.URL.Path, "/test/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if h.writes.Len() == 0 && h.health.check(r.Context(), h.cfg.HealthCacheTTL, h.service.Health) == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+		header := make(http.Header)
+		for _, name := range journaledHeaders {
+			if value := r.Header.Get(name); value != "" {
+				header.Set(name, value)
+			}
+		}
+		signed, _ := r.Context().Value(signedKey{}).(bool)
+		trusted := signed || hasAdminToken(r, h.cfg.AdminToken)
+		var tokenHash string
+		if !trusted {
+			tokenHash, _ = teamTokenHash(r)
+		}
+
+		entry, err := h.writes.Append(writequeue.Entry{
+			Method:     r.Method,
+			RequestURI: r.URL.RequestURI(),
+			Header:     header,
+			Body:       body,
+			Trusted:    trusted,
+			TokenHash:  tokenHash,
+		})
+		if errors.Is(err, writequeue.ErrFull) {
+			respondError(w, http.StatusServiceUnavailable, "STORAGE_UNAVAILABLE", "storage is unavailable and the write queue is full")
+			return
+		}
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "INTERNAL", err.Error())
+			return
+		}
+		metrics.ObserveWriteQueue("queued", 1)
+		respondJSON(w, http.StatusAccepted, map[string]any{"queued": true, "seq": entry.Seq})
+	})
+}
+
+// WriteReplay counts the outcomes of a ReplayWrites run.
+type WriteReplay struct {
+	// Replayed writes were handled successfully.
+	Replayed int
+	// Rejected writes were refused with a 4xx status and are not retried.
+	Rejected int
+	// Expired writes waited longer than the queue's window and were dropped
+	// without being handled.
+	Expired int
+}
+
+// ReplayWrites handles the queued writes through router, oldest first, once
+// the storage answers its health ping. A write answered with a 5xx status
+// stays queued, together with those after it, for the next run; the error
+// names it.
+func (h *Handler) ReplayWrites(ctx context.Context, router http.Handler) (WriteReplay, error) {
+	var result WriteReplay
+	if h.writes == nil || h.writes.Len() == 0 {
+		return result, nil
+	}
+	if err := h.service.Health(ctx); err != nil {
+		return result, nil
+	}
+
+	var err error
+	result.Replayed, result.Expired, err = h.writes.Replay(ctx, time.Now().UTC(), func(ctx context.Context, entry writequeue.Entry) error {
+		status, err := replayWrite(ctx, router, entry)
+		if err != nil {
+			return err
+		}
+		switch {
+		case status >= http.StatusInternalServerError:
+			return fmt.Errorf("queued write %d (%s %s) answered %d", entry.Seq, entry.Method, entry.RequestURI, status)
+		case status >= http.StatusBadRequest:
+			result.Rejected++
+		}
+		return nil
+	})
+	result.Replayed -= result.Rejected
+	metrics.ObserveWriteQueue("replayed", result.Replayed)
+	metrics.ObserveWriteQueue("rejected", result.Rejected)
+	metrics.ObserveWriteQueue("expired", result.Expired)
+	return result, err
+}
+
+func replayWrite(ctx context.Context, router http.Handler, entry writequeue.Entry) (int, error) {
+	ctx = context.WithValue(ctx, replayKey{}, true)
+	if entry.Trusted {
+		ctx = context.WithValue(ctx, signedKey{}, true)
+	}
+	if entry.TokenHash != "" {
+		ctx = context.WithValue(ctx, teamTokenKey{}, entry.TokenHash)
+	}
+	req, err := http.NewRequestWithContext(ctx, entry.Method, entry.RequestURI, bytes.NewReader(entry.Body))
+	if err != nil {
+		return 0, fmt.Errorf("queued write %d: %w", entry.Seq, err)
+	}
+	req.RequestURI = entry.RequestURI
+	for name, values := range entry.Header {
+		req.Header[name] = values
+	}
+
+	w := &statusRecorder{header: make(http.Header)}
+	router.ServeHTTP(w, req)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.status, nil
+}
+
+// statusRecorder is the response writer of replayed writes, whose callers
+// have long been answered; it keeps only the status.
+type statusRecorder struct {
+	header http.Header
+	status int
+}
+
+func (s *statusRecorder) Header() http.Header { return s.header }
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	s.WriteHeader(http.StatusOK)
+	return len(p), nil
+}
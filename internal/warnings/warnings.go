@@ -0,0 +1,45 @@
+// Package warnings collects, through a context, the remarks the service makes
+// on a request that succeeds anyway, so that the transport can report them
+// next to the response without every service method returning them.
+package warnings
+
+import (
+	"context"
+	"sync"
+
+	"Avito2025/internal/domain"
+)
+
+type key struct{}
+
+// List is the warnings collected for one request. It is safe for concurrent
+// use.
+type List struct {
+	mu    sync.Mutex
+	items []domain.Warning
+}
+
+// With returns a copy of ctx collecting warnings into the returned list.
+func With(ctx context.Context) (context.Context, *List) {
+	list := &List{}
+	return context.WithValue(ctx, key{}, list), list
+}
+
+// Add records warning on the list carried by ctx. Outside a request, e.g. in
+// background jobs, it does nothing.
+func Add(ctx context.Context, warning domain.Warning) {
+	list, ok := ctx.Value(key{}).(*List)
+	if !ok {
+		return
+	}
+	list.mu.Lock()
+	defer list.mu.Unlock()
+	list.items = append(list.items, warning)
+}
+
+// Items returns the warnings recorded so far.
+func (l *List) Items() []domain.Warning {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]domain.Warning(nil), l.items...)
+}
@@ -0,0 +1,359 @@
+// Package webhook delivers domain events to subscribed webhooks.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Avito2025/internal/config"
+	"Avito2025/internal/domain"
+	"Avito2025/internal/links"
+)
+
+// Headers set on every delivery.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Store lists webhooks, keeps the delivery log and reads the change feed
+// from the dispatch cursor, which it keeps under a lease.
+type Store interface {
+	ListWebhooks(ctx context.Context) ([]domain.Webhook, error)
+	RecordWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) error
+	ListChanges(ctx context.Context, since int64, limit int) ([]domain.Event, error)
+	ClaimWebhookDispatch(ctx context.Context, owner string, now, until time.Time) (int64, bool, error)
+	AdvanceWebhookDispatch(ctx context.Context, owner string, seq int64) (bool, error)
+}
+
+// dispatchBatch is how many events of the change feed are dispatched before
+// the cursor is moved past them.
+const dispatchBatch = 100
+
+// errLeaseLost reports that another instance took over webhook dispatch
+// while a batch was being delivered.
+var errLeaseLost = errors.New("dispatch lease lost")
+
+// Dispatcher posts events to the webhooks subscribed to them. Each delivery is
+// retried with exponential backoff and every attempt is logged in the store.
+type Dispatcher struct {
+	store  Store
+	cfg    config.WebhookConfig
+	client *http.Client
+	links  links.Builder
+	// owner identifies the dispatcher in the lease on webhook dispatch.
+	owner string
+}
+
+func New(store Store, cfg config.WebhookConfig) *Dispatcher {
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 30 * time.Second
+	}
+	return &Dispatcher{
+		store:  store,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		links:  newLinks(cfg.BaseURL),
+		owner:  newOwner(),
+	}
+}
+
+// newOwner returns a name for the dispatcher unique across instances.
+func newOwner() string {
+	host, _ := os.Hostname()
+	var suffix [8]byte
+	_, _ = rand.Read(suffix[:])
+	return host + "-" + hex.EncodeToString(suffix[:])
+}
+
+// newLinks returns the builder of links to the /v1 API under baseURL, or the
+// zero builder when no base URL is configured.
+func newLinks(baseURL string) links.Builder {
+	if baseURL == "" {
+		return links.Builder{}
+	}
+	return links.New(strings.TrimRight(baseURL, "/") + "/v1")
+}
+
+type payload struct {
+	ID        int64          `json:"id"`
+	Event     string         `json:"event"`
+	TeamName  string         `json:"team_name"`
+	EntityID  string         `json:"entity_id"`
+	Payload   map[string]any `json:"payload,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	// Links are set when the service knows its public base URL.
+	Links *payloadLinks `json:"links,omitempty"`
+}
+
+// payloadLinks point at the pull request of the event and its author's team.
+type payloadLinks struct {
+	Self string `json:"self"`
+	Team string `json:"team,omitempty"`
+}
+
+// Run delivers the events of the change feed until ctx is done. Events are
+// read from the stored feed after the dispatch cursor, which moves past a
+// batch only once all its deliveries are over, so an event dropped by the
+// live stream or left behind by a restart is still delivered; the retries
+// abandoned on shutdown start over after the restart. An event on wake only
+// shortens the wait for the next read. Only the instance holding the lease
+// on dispatch delivers.
+func (d *Dispatcher) Run(ctx context.Context, wake <-chan domain.Event) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		for {
+			more, err := d.dispatch(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("webhook: %v", err)
+			}
+			if err != nil || !more {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case _, ok := <-wake:
+			if !ok {
+				wake = nil
+			}
+		}
+	}
+}
+
+// dispatch delivers the next batch of the change feed, if this dispatcher
+// holds the lease, and reports whether more events may be waiting.
+func (d *Dispatcher) dispatch(ctx context.Context) (bool, error) {
+	now := time.Now()
+	cursor, ok, err := d.store.ClaimWebhookDispatch(ctx, d.owner, now, now.Add(d.cfg.Lease))
+	if err != nil || !ok {
+		return false, err
+	}
+	events, err := d.store.ListChanges(ctx, cursor, dispatchBatch)
+	if err != nil || len(events) == 0 {
+		return false, err
+	}
+	webhooks, err := d.store.ListWebhooks(ctx)
+	if err != nil {
+		return false, fmt.Errorf("list webhooks: %w", err)
+	}
+
+	batchCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		d.keepLease(batchCtx, cancel)
+	}()
+
+	var (
+		wg        sync.WaitGroup
+		abandoned atomic.Bool
+	)
+	slots := make(chan struct{}, d.cfg.Workers)
+	for _, event := range events {
+		if !d.deliverEvent(batchCtx, &wg, slots, webhooks, event, &abandoned) {
+			abandoned.Store(true)
+			break
+		}
+	}
+	wg.Wait()
+	cancel(nil)
+	<-renewed
+
+	if err := context.Cause(batchCtx); errors.Is(err, errLeaseLost) {
+		return false, err
+	}
+	if abandoned.Load() {
+		return false, ctx.Err()
+	}
+	// Every delivery of the batch is over, possibly with its last attempt
+	// finished after ctx was done, so the batch is not delivered again.
+	last := events[len(events)-1].Seq
+	if ok, err := d.store.AdvanceWebhookDispatch(context.WithoutCancel(ctx), d.owner, last); err != nil || !ok {
+		if err == nil {
+			err = errLeaseLost
+		}
+		return false, fmt.Errorf("advance dispatch to %d: %w", last, err)
+	}
+	return len(events) == dispatchBatch && ctx.Err() == nil, ctx.Err()
+}
+
+// keepLease renews the lease on dispatch until ctx is done, cancelling ctx
+// with errLeaseLost when another instance has taken it over.
+func (d *Dispatcher) keepLease(ctx context.Context, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(d.cfg.Lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		_, ok, err := d.store.ClaimWebhookDispatch(ctx, d.owner, now, now.Add(d.cfg.Lease))
+		if err != nil {
+			// The lease outlives a few failed renewals.
+			log.Printf("webhook: renew dispatch lease: %v", err)
+			continue
+		}
+		if !ok {
+			cancel(errLeaseLost)
+			return
+		}
+	}
+}
+
+// deliverEvent starts the deliveries of event to the webhooks subscribed to
+// it, each taking one of slots. It reports false when ctx is done before all
+// of them started; deliveries given up on ctx are flagged in abandoned.
+func (d *Dispatcher) deliverEvent(ctx context.Context, wg *sync.WaitGroup, slots chan struct{}, webhooks []domain.Webhook, event domain.Event, abandoned *atomic.Bool) bool {
+	name, ok := domain.WebhookEventFor(event.Type)
+	if !ok {
+		return true
+	}
+	body, err := json.Marshal(payload{
+		ID:        event.Seq,
+		Event:     string(name),
+		TeamName:  event.TeamName,
+		EntityID:  event.EntityID,
+		Payload:   event.Payload,
+		CreatedAt: event.CreatedAt,
+		Links:     d.linksOf(event),
+	})
+	if err != nil {
+		log.Printf("webhook: encode event %d: %v", event.Seq, err)
+		return true
+	}
+
+	for _, hook := range webhooks {
+		if !hook.Subscribed(name) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case slots <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(hook domain.Webhook) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if !d.deliver(ctx, hook, event.Seq, name, body) {
+				abandoned.Store(true)
+			}
+		}(hook)
+	}
+	return true
+}
+
+// linksOf links to the pull request every webhook event is about.
+func (d *Dispatcher) linksOf(event domain.Event) *payloadLinks {
+	self := d.links.PullRequest(event.EntityID)
+	if self == "" {
+		return nil
+	}
+	return &payloadLinks{Self: self, Team: d.links.Team(event.TeamName)}
+}
+
+// deliver posts the event to the hook until it is delivered or the attempts
+// run out. It reports false when the retries were abandoned because ctx is
+// done.
+func (d *Dispatcher) deliver(ctx context.Context, hook domain.Webhook, seq int64, event domain.WebhookEvent, body []byte) bool {
+	// An attempt in flight is allowed to finish on shutdown, bounded by the
+	// client timeout; only the retries are abandoned.
+	attemptCtx := context.WithoutCancel(ctx)
+	backoff := d.cfg.RetryBackoff
+	for attempt := 1; attempt <= d.cfg.MaxAttempts; attempt++ {
+		status, err := d.post(attemptCtx, hook, seq, event, body)
+		delivery := domain.WebhookDelivery{
+			WebhookID:  hook.ID,
+			EventSeq:   seq,
+			Event:      event,
+			Attempt:    attempt,
+			StatusCode: status,
+			Delivered:  err == nil,
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if logErr := d.store.RecordWebhookDelivery(attemptCtx, delivery); logErr != nil {
+			log.Printf("webhook: record delivery to %d: %v", hook.ID, logErr)
+		}
+		if err == nil || attempt == d.cfg.MaxAttempts {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return true
+}
+
+func (d *Dispatcher) post(ctx context.Context, hook domain.Webhook, seq int64, event domain.WebhookEvent, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(event))
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(seq, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value of body: "sha256=" followed by the
+// hex HMAC-SHA256 of body keyed with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
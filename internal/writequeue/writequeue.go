@@ -0,0 +1,237 @@
+// Package writequeue journals mutating requests on local disk while the
+// storage is unreachable so that they can be replayed, in order, once it is
+// back. It is meant for callers such as webhooks that cannot retry on their
+// own: the queue is bounded both in entries and in how long an entry waits.
+package writequeue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrFull is returned by Append when the queue holds its maximum of entries.
+var ErrFull = errors.New("write queue is full")
+
+// Entry is a queued request.
+type Entry struct {
+	Seq        int64       `json:"seq"`
+	Method     string      `json:"method"`
+	RequestURI string      `json:"request_uri"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	// Trusted reports that the request carried the admin token or a valid
+	// signature. Credentials are not journaled; this and TokenHash are all
+	// that is kept of them.
+	Trusted bool `json:"trusted,omitempty"`
+	// TokenHash is the hash of a team token the request carried, checked
+	// on replay.
+	TokenHash string    `json:"token_hash,omitempty"`
+	QueuedAt  time.Time `json:"queued_at"`
+}
+
+// Queue is an append-only journal of entries, one JSON object per line,
+// mirrored in memory. Every append is synced before it is acknowledged;
+// replayed entries are dropped by rewriting the journal.
+type Queue struct {
+	path       string
+	maxEntries int
+	window     time.Duration
+
+	// replaying serialises Replay calls; mu guards the fields below and is
+	// not held while entries are applied.
+	replaying sync.Mutex
+	mu        sync.Mutex
+	file      *os.File
+	entries   []Entry
+	lastSeq   int64
+}
+
+// Open loads the journal at path, creating it when missing. maxEntries caps
+// the queue and window is how long an entry may wait for replay before it
+// is dropped; zero disables either limit. A torn last line, left by a crash
+// in the middle of an append, is discarded.
+func Open(path string, maxEntries int, window time.Duration) (*Queue, error) {
+	q := &Queue{path: path, maxEntries: maxEntries, window: window}
+
+	entries, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.rewrite(entries); err != nil {
+		return nil, err
+	}
+	if n := len(entries); n > 0 {
+		q.lastSeq = entries[n-1].Seq
+	}
+	return q, nil
+}
+
+func load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open write queue: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read write queue: %w", err)
+	}
+	return entries, nil
+}
+
+// Close closes the journal. Queued entries stay on disk for the next Open.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.file.Close()
+}
+
+// Len returns the number of queued entries.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// Full reports whether Append would fail with ErrFull.
+func (q *Queue) Full() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.maxEntries > 0 && len(q.entries) >= q.maxEntries
+}
+
+// Append assigns entry the next sequence number and journals it. The entry
+// is on disk once Append returns without an error.
+func (q *Queue) Append(entry Entry) (Entry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.maxEntries > 0 && len(q.entries) >= q.maxEntries {
+		return Entry{}, ErrFull
+	}
+	entry.Seq = q.lastSeq + 1
+	if entry.QueuedAt.IsZero() {
+		entry.QueuedAt = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, err
+	}
+	if _, err := q.file.Write(append(line, '\n')); err != nil {
+		return Entry{}, fmt.Errorf("append to write queue: %w", err)
+	}
+	if err := q.file.Sync(); err != nil {
+		return Entry{}, fmt.Errorf("sync write queue: %w", err)
+	}
+	q.lastSeq = entry.Seq
+	q.entries = append(q.entries, entry)
+	return entry, nil
+}
+
+// Replay passes queued entries to apply, oldest first, and drops those
+// applied. It stops at the first entry apply fails on, keeping it and the
+// ones after it for the next call. Entries that waited longer than the
+// window are dropped without being applied. Entries appended meanwhile are
+// left for the next call.
+func (q *Queue) Replay(ctx context.Context, now time.Time, apply func(context.Context, Entry) error) (replayed, expired int, err error) {
+	q.replaying.Lock()
+	defer q.replaying.Unlock()
+
+	q.mu.Lock()
+	pending := append([]Entry(nil), q.entries...)
+	q.mu.Unlock()
+
+	done := 0
+	for _, entry := range pending {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if q.window > 0 && now.Sub(entry.QueuedAt) > q.window {
+			expired++
+			done++
+			continue
+		}
+		if err = apply(ctx, entry); err != nil {
+			break
+		}
+		replayed++
+		done++
+	}
+	if done == 0 {
+		return replayed, expired, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if rewriteErr := q.rewrite(q.entries[done:]); rewriteErr != nil && err == nil {
+		err = rewriteErr
+	}
+	return replayed, expired, err
+}
+
+// rewrite replaces the journal with entries and reopens it for appending.
+// The new journal is written aside and renamed over the old one, so a crash
+// leaves either of them intact.
+func (q *Queue) rewrite(entries []Entry) error {
+	tmp, err := os.CreateTemp(filepath.Dir(q.path), filepath.Base(q.path)+".*")
+	if err != nil {
+		return fmt.Errorf("rewrite write queue: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			tmp.Close()
+			return fmt.Errorf("rewrite write queue: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("rewrite write queue: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("rewrite write queue: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("rewrite write queue: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), q.path); err != nil {
+		return fmt.Errorf("rewrite write queue: %w", err)
+	}
+
+	file, err := os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open write queue: %w", err)
+	}
+	if q.file != nil {
+		q.file.Close()
+	}
+	q.file = file
+	q.entries = append([]Entry(nil), entries...)
+	return nil
+}
@@ -2,22 +2,44 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os/signal"
 	"syscall"
 	"time"
+	// Team working hours use IANA zones, which the runtime image lacks.
+	_ "time/tzdata"
 
 	"Avito2025/internal/config"
+	"Avito2025/internal/directory"
+	"Avito2025/internal/directory/ldap"
+	"Avito2025/internal/domain"
+	"Avito2025/internal/export"
+	"Avito2025/internal/metrics"
+	"Avito2025/internal/notify"
+	"Avito2025/internal/scheduler"
+	"Avito2025/internal/seed"
 	"Avito2025/internal/service"
 	"Avito2025/internal/storage"
+	"Avito2025/internal/storage/cache"
+	"Avito2025/internal/storage/memory"
 	"Avito2025/internal/storage/postgres"
+	"Avito2025/internal/storage/retry"
 	httptransport "Avito2025/internal/transport/http"
+	"Avito2025/internal/webhook"
+	"Avito2025/internal/writequeue"
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+
+	flag.StringVar(&cfg.SeedFile, "seed", cfg.SeedFile, "path to a JSON fixture with teams and pull requests to load on startup")
+	flag.Parse()
 
 	repo, cleanup, err := buildRepository(context.Background(), cfg)
 	if err != nil {
@@ -25,8 +47,58 @@ func main() {
 	}
 	defer cleanup()
 
-	svc := service.New(repo)
-	handler := httptransport.NewHandler(svc)
+	if provider, ok := storage.AsStatsProvider(repo); ok {
+		metrics.RegisterPoolStats(provider)
+	}
+
+	var opts []service.Option
+	if cfg.RandomSeed != nil {
+		opts = append(opts, service.WithRand(*cfg.RandomSeed))
+		log.Printf("reviewer selection seeded with %d", *cfg.RandomSeed)
+	}
+	svc := service.New(repo, opts...)
+	dir, err := buildDirectory(cfg)
+	if err != nil {
+		log.Fatalf("init directory: %v", err)
+	}
+	svc.SetDirectory(dir)
+	svc.SetDeferOffHours(cfg.Scheduler.DeferOffHours)
+	svc.SetBotTeams(cfg.BotTeams)
+	svc.SetQuotas(domain.Quotas{
+		TeamMembers:      domain.Quota{Soft: cfg.Quotas.TeamMembersSoft, Hard: cfg.Quotas.TeamMembersHard},
+		OpenPullRequests: domain.Quota{Soft: cfg.Quotas.OpenPullRequestsSoft, Hard: cfg.Quotas.OpenPullRequestsHard},
+		Reviewers:        domain.Quota{Soft: cfg.Quotas.ReviewersSoft, Hard: cfg.Quotas.ReviewersHard},
+	})
+
+	reviewEvents, stopReviewEvents := svc.SubscribeEvents(nil)
+	defer stopReviewEvents()
+	go metrics.ObserveReviews(reviewEvents)
+
+	if cfg.SeedFile != "" {
+		if err := seed.LoadFile(context.Background(), svc, cfg.SeedFile); err != nil {
+			log.Fatalf("load seed: %v", err)
+		}
+		log.Printf("seed data loaded from %s", cfg.SeedFile)
+	}
+
+	handler := httptransport.NewHandler(svc, cfg.HTTP)
+	if chaos := cfg.HTTP.Chaos; chaos.Enabled {
+		log.Printf("fault injection enabled: latency %s, error rate %g, %d route overrides", chaos.Latency, chaos.ErrorRate, len(chaos.Routes))
+	}
+	replayInterval := time.Duration(0)
+	if cfg.WriteQueue.Path != "" {
+		writes, err := writequeue.Open(cfg.WriteQueue.Path, cfg.WriteQueue.MaxEntries, cfg.WriteQueue.Window)
+		if err != nil {
+			log.Fatalf("open write queue: %v", err)
+		}
+		defer writes.Close()
+		if queued := writes.Len(); queued > 0 {
+			log.Printf("%d queued writes await replay", queued)
+		}
+		metrics.RegisterWriteQueue(writes.Len)
+		handler.SetWriteQueue(writes)
+		replayInterval = cfg.WriteQueue.ReplayInterval
+	}
 
 	server := &http.Server{
 		Addr:    cfg.HTTP.Addr,
@@ -36,6 +108,152 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	archiveAfter := time.Duration(cfg.Scheduler.ArchiveAfterDays) * 24 * time.Hour
+	archiveInterval := cfg.Scheduler.ArchiveInterval
+	if archiveAfter <= 0 {
+		archiveInterval = 0
+	}
+	exportInterval := cfg.Export.Interval
+	if cfg.Export.Endpoint == "" || cfg.Export.Bucket == "" {
+		exportInterval = 0
+	}
+	exporter := export.New(svc, cfg.Export)
+	notifier := notify.New(svc, cfg.Notify)
+	jobs := scheduler.New(
+		scheduler.Job{
+			Name:     "reminders",
+			Interval: cfg.Scheduler.ReminderInterval,
+			Run: func(ctx context.Context) error {
+				return svc.ProcessReminders(ctx, time.Now().UTC())
+			},
+		},
+		scheduler.Job{
+			Name:     "archive",
+			Interval: archiveInterval,
+			Run: func(ctx context.Context) error {
+				archived, err := svc.ArchivePullRequests(ctx, time.Now().UTC().Add(-archiveAfter))
+				if archived > 0 {
+					log.Printf("archived %d merged pull requests", archived)
+				}
+				return err
+			},
+		},
+		// Runs even with deferral off so that a queue left behind by an
+		// earlier configuration is drained.
+		scheduler.Job{
+			Name:     "deferred assignments",
+			Interval: cfg.Scheduler.DeferredInterval,
+			Run: func(ctx context.Context) error {
+				return svc.ProcessDeferredAssignments(ctx, time.Now().UTC())
+			},
+		},
+		scheduler.Job{
+			Name:     "pending assignments",
+			Interval: cfg.Scheduler.PendingInterval,
+			Run: func(ctx context.Context) error {
+				return svc.ProcessPendingAssignments(ctx, time.Now().UTC())
+			},
+		},
+		scheduler.Job{
+			Name:     "expired acceptances",
+			Interval: cfg.Scheduler.AcceptanceInterval,
+			Run: func(ctx context.Context) error {
+				return svc.ProcessExpiredAcceptances(ctx, time.Now().UTC())
+			},
+		},
+		scheduler.Job{
+			Name:     "daily stats",
+			Interval: cfg.Scheduler.DailyStatsInterval,
+			Run: func(ctx context.Context) error {
+				return svc.BuildDailyStats(ctx, time.Now().UTC())
+			},
+		},
+		scheduler.Job{
+			Name:     "notification digests",
+			Interval: cfg.Scheduler.DigestInterval,
+			Run: func(ctx context.Context) error {
+				_, err := notifier.SendDigests(ctx, svc, time.Now().UTC())
+				return err
+			},
+		},
+		scheduler.Job{
+			Name:     "event export",
+			Interval: exportInterval,
+			Run: func(ctx context.Context) error {
+				exported, err := exporter.Export(ctx, time.Now().UTC())
+				if exported > 0 {
+					log.Printf("exported %d events", exported)
+				}
+				return err
+			},
+		},
+		scheduler.Job{
+			Name:     "write replay",
+			Interval: replayInterval,
+			Run: func(ctx context.Context) error {
+				result, err := handler.ReplayWrites(ctx, server.Handler)
+				if result.Replayed > 0 {
+					log.Printf("replayed %d queued writes", result.Replayed)
+				}
+				if result.Rejected > 0 {
+					log.Printf("dropped %d queued writes refused on replay", result.Rejected)
+				}
+				if result.Expired > 0 {
+					log.Printf("dropped %d queued writes older than %s", result.Expired, cfg.WriteQueue.Window)
+				}
+				return err
+			},
+		},
+		scheduler.Job{
+			Name:     "underassigned",
+			Interval: cfg.Scheduler.UnderassignedInterval,
+			Run: func(ctx context.Context) error {
+				prs, err := svc.ListUnderassigned(ctx, "")
+				if err != nil {
+					return err
+				}
+				metrics.SetUnderassigned(prs)
+				return nil
+			},
+		},
+		scheduler.Job{
+			Name:     "orphan cleanup",
+			Interval: cfg.Scheduler.OrphanInterval,
+			Run: func(ctx context.Context) error {
+				report, err := svc.CheckOrphans(ctx, cfg.Scheduler.OrphanRepair)
+				if err != nil {
+					return err
+				}
+				metrics.SetOrphans(report.Issues)
+				if len(report.Issues) > 0 && cfg.Scheduler.OrphanRepair {
+					log.Printf("deleted %d orphaned reviewer rows", len(report.Issues))
+				}
+				return nil
+			},
+		},
+	)
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		jobs.Run(ctx)
+	}()
+
+	webhookEvents, stopWebhookEvents := svc.SubscribeEvents(nil)
+	defer stopWebhookEvents()
+	webhooksDone := make(chan struct{})
+	go func() {
+		defer close(webhooksDone)
+		webhook.New(svc, cfg.Webhook).Run(ctx, webhookEvents)
+	}()
+
+	notifyEvents, stopNotifyEvents := svc.SubscribeEvents(nil)
+	defer stopNotifyEvents()
+	notifyDone := make(chan struct{})
+	go func() {
+		defer close(notifyDone)
+		notifier.Run(ctx, notifyEvents)
+	}()
+
 	go func() {
 		log.Printf("HTTP server listening on %s (storage=%s)", cfg.HTTP.Addr, cfg.Storage.Type)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -51,17 +269,58 @@ func main() {
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Printf("HTTP server shutdown error: %v", err)
 	}
+	<-jobsDone
+	<-webhooksDone
+	<-notifyDone
 }
 
 func buildRepository(ctx context.Context, cfg config.Config) (storage.Repository, func(), error) {
+	decorators, err := buildDecorators(cfg.Storage)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	switch cfg.Storage.Type {
 	case "postgres":
 		store, err := postgres.New(ctx, cfg.Storage.Postgres)
 		if err != nil {
 			return nil, nil, err
 		}
-		return store, store.Close, nil
+		return storage.Chain(store, decorators...), store.Close, nil
+	case "memory":
+		store := memory.New()
+		return storage.Chain(store, decorators...), store.Close, nil
 	default:
 		return nil, nil, fmt.Errorf("unsupported storage type: %s", cfg.Storage.Type)
 	}
 }
+
+// buildDecorators resolves the configured storage decorators, outermost first.
+func buildDecorators(cfg config.StorageConfig) ([]storage.Decorator, error) {
+	var decorators []storage.Decorator
+	for _, name := range cfg.Decorators {
+		switch name {
+		case "metrics":
+			decorators = append(decorators, metrics.InstrumentRepository)
+		case "cache":
+			decorators = append(decorators, cache.Decorator(cfg.Cache))
+		case "retry":
+			decorators = append(decorators, retry.Decorator(cfg.Retry))
+		case "none":
+		default:
+			return nil, fmt.Errorf("unsupported storage decorator: %s", name)
+		}
+	}
+	return decorators, nil
+}
+
+func buildDirectory(cfg config.Config) (directory.Directory, error) {
+	switch cfg.Directory.Type {
+	case "ldap":
+		return ldap.New(cfg.Directory.LDAP)
+	case "none", "":
+		return directory.Nop{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported directory type: %s", cfg.Directory.Type)
+	}
+}
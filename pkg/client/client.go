@@ -0,0 +1,300 @@
+// Package client is a typed Go client of the reviewer assignment service's v1
+// HTTP API for services that integrate with it.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"Avito2025/internal/signature"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultBackoff     = 100 * time.Millisecond
+	defaultTimeout     = 10 * time.Second
+)
+
+// Config configures a Client.
+type Config struct {
+	// BaseURL is the service root, e.g. http://reviewer:8080; the client adds
+	// the /v1 prefix itself.
+	BaseURL string
+	// HTTPClient sends the requests; nil means a client with a 10s timeout.
+	HTTPClient *http.Client
+	// MaxAttempts bounds the attempts of a retried request; zero means 3.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled after each one;
+	// zero means 100ms.
+	Backoff time.Duration
+	// SigningSecret, when set, signs every request with the secret shared
+	// with the service instead of sending a token.
+	SigningSecret string
+	// AdminToken, when set, is sent as a bearer token; the /admin endpoints
+	// accept it in place of a signature. A team token may be set instead,
+	// which lets CreatePR create pull requests of the team's members.
+	AdminToken string
+}
+
+// Client calls the service. Requests are retried with exponential backoff
+// when the service answers 502 or 503, which it does before applying a
+// change, and, for requests that can safely be repeated, on transport errors
+// and 504 as well. A Client is safe for concurrent use.
+type Client struct {
+	baseURL     string
+	http        *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	secret      string
+	adminToken  string
+}
+
+func New(cfg Config) *Client {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: defaultTimeout}
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultBackoff
+	}
+	return &Client{
+		baseURL:     strings.TrimRight(cfg.BaseURL, "/") + "/v1",
+		http:        cfg.HTTPClient,
+		maxAttempts: cfg.MaxAttempts,
+		backoff:     cfg.Backoff,
+		secret:      cfg.SigningSecret,
+		adminToken:  cfg.AdminToken,
+	}
+}
+
+// CreateTeam creates a team with its members. Members that already belong to
+// another team are refused with ErrUserInOtherTeam.
+func (c *Client) CreateTeam(ctx context.Context, team Team) (Team, error) {
+	var resp struct {
+		Team Team `json:"team"`
+	}
+	err := c.do(ctx, http.MethodPost, "/team/add", team, false, &resp)
+	return resp.Team, err
+}
+
+// CreatePR creates a pull request and returns it with the reviewers the
+// service assigned.
+func (c *Client) CreatePR(ctx context.Context, pr NewPullRequest) (PullRequest, error) {
+	var resp struct {
+		PR PullRequest `json:"pr"`
+	}
+	err := c.do(ctx, http.MethodPost, "/pullRequest/create", pr, false, &resp)
+	return resp.PR, err
+}
+
+// Merge marks a pull request as merged. Merging a merged pull request returns
+// it unchanged, so the call is retried like a read.
+func (c *Client) Merge(ctx context.Context, prID string) (PullRequest, error) {
+	var resp struct {
+		PR PullRequest `json:"pr"`
+	}
+	err := c.do(ctx, http.MethodPost, "/pullRequest/merge", map[string]string{
+		"pull_request_id": prID,
+	}, true, &resp)
+	return resp.PR, err
+}
+
+// MarkReady moves a draft to OPEN and returns it with its reviewers. Marking
+// an open pull request ready returns it unchanged, so the call is retried
+// like a read.
+func (c *Client) MarkReady(ctx context.Context, prID string) (PullRequest, error) {
+	var resp struct {
+		PR PullRequest `json:"pr"`
+	}
+	err := c.do(ctx, http.MethodPost, "/pullRequest/markReady", map[string]string{
+		"pull_request_id": prID,
+	}, true, &resp)
+	return resp.PR, err
+}
+
+// Reassign replaces a reviewer of a pull request and returns the updated
+// pull request with the ID of the new reviewer.
+func (c *Client) Reassign(ctx context.Context, prID, oldReviewerID string) (PullRequest, string, error) {
+	var resp struct {
+		PR         PullRequest `json:"pr"`
+		ReplacedBy string      `json:"replaced_by"`
+	}
+	err := c.do(ctx, http.MethodPost, "/pullRequest/reassign", map[string]string{
+		"pull_request_id": prID,
+		"old_user_id":     oldReviewerID,
+	}, false, &resp)
+	return resp.PR, resp.ReplacedBy, err
+}
+
+// AcceptReview accepts the review of a pull request on behalf of one of its
+// reviewers. Accepting twice returns the pull request unchanged, so the call
+// is retried like a read.
+func (c *Client) AcceptReview(ctx context.Context, prID, userID string) (PullRequest, error) {
+	var resp struct {
+		PR PullRequest `json:"pr"`
+	}
+	err := c.do(ctx, http.MethodPost, "/pullRequest/acceptReview", map[string]string{
+		"pull_request_id": prID,
+		"user_id":         userID,
+	}, true, &resp)
+	return resp.PR, err
+}
+
+// ExtendDeadline asks for time to review a pull request until the given
+// moment on behalf of one of its reviewers. The extension comes back
+// approved when the team's policy allows it and pending otherwise.
+func (c *Client) ExtendDeadline(ctx context.Context, prID, userID string, until time.Time, reason string) (Extension, error) {
+	var resp struct {
+		Extension Extension `json:"extension"`
+	}
+	err := c.do(ctx, http.MethodPost, "/pullRequest/extendDeadline", map[string]any{
+		"pull_request_id": prID,
+		"user_id":         userID,
+		"until":           until,
+		"reason":          reason,
+	}, false, &resp)
+	return resp.Extension, err
+}
+
+// DecideExtension approves or rejects a pending deadline extension.
+func (c *Client) DecideExtension(ctx context.Context, extensionID int64, approve bool) (Extension, error) {
+	var resp struct {
+		Extension Extension `json:"extension"`
+	}
+	err := c.do(ctx, http.MethodPost, "/pullRequest/decideExtension", map[string]any{
+		"extension_id": extensionID,
+		"approve":      approve,
+	}, false, &resp)
+	return resp.Extension, err
+}
+
+// ListExtensions lists the deadline extensions requested for a pull request,
+// oldest first.
+func (c *Client) ListExtensions(ctx context.Context, prID string) ([]Extension, error) {
+	var resp struct {
+		Extensions []Extension `json:"extensions"`
+	}
+	err := c.do(ctx, http.MethodGet, "/pullRequest/extensions?"+url.Values{"pull_request_id": {prID}}.Encode(), nil, true, &resp)
+	return resp.Extensions, err
+}
+
+// ListReviews lists the pull requests the user reviews.
+func (c *Client) ListReviews(ctx context.Context, userID string, filter ReviewFilter) ([]PullRequestShort, error) {
+	query := url.Values{"user_id": {userID}}
+	if filter.Status != "" {
+		query.Set("status", filter.Status)
+	}
+	if filter.Sort != "" {
+		query.Set("sort", filter.Sort)
+	}
+	if filter.Order != "" {
+		query.Set("order", filter.Order)
+	}
+	if filter.NeedsMoreReviewers {
+		query.Set("needs_more_reviewers", "true")
+	}
+
+	var resp struct {
+		PullRequests []PullRequestShort `json:"pull_requests"`
+	}
+	err := c.do(ctx, http.MethodGet, "/users/getReview?"+query.Encode(), nil, true, &resp)
+	return resp.PullRequests, err
+}
+
+// VerifyIntegrity checks the service's data for inconsistencies and, with fix
+// set, repairs them. It needs AdminToken or SigningSecret. Repairs can be
+// repeated, so the call is retried like a read.
+func (c *Client) VerifyIntegrity(ctx context.Context, fix bool) (IntegrityReport, error) {
+	var resp IntegrityReport
+	err := c.do(ctx, http.MethodPost, "/admin/integrity", map[string]bool{"fix": fix}, true, &resp)
+	return resp, err
+}
+
+// do sends the request, retrying it as described on Client, and decodes a
+// successful answer into out. idempotent marks requests that may be repeated
+// even when an earlier attempt might have been applied.
+func (c *Client) do(ctx context.Context, method, path string, body any, idempotent bool, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	backoff := c.backoff
+	for attempt := 1; ; attempt++ {
+		err := c.send(ctx, method, path, payload, out)
+		if err == nil || attempt >= c.maxAttempts || !retryable(err, idempotent) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, out any) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.adminToken)
+	}
+	if c.secret != "" {
+		if err := signature.Apply(req, c.secret, payload, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func retryable(err error, idempotent bool) bool {
+	apiErr, ok := err.(*Error)
+	if !ok {
+		return idempotent
+	}
+	switch apiErr.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	case http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Error is an error answered by the service. Code is the service's error code;
+// errors.Is matches an Error against the values below by code alone.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("reviewer service: %d %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("reviewer service: %s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code != "" && t.Code == e.Code
+}
+
+// Error codes of the operations the client covers.
+var (
+	ErrBadRequest         = &Error{Code: "BAD_REQUEST"}
+	ErrNotFound           = &Error{Code: "NOT_FOUND"}
+	ErrTeamExists         = &Error{Code: "TEAM_EXISTS"}
+	ErrUnknownUser        = &Error{Code: "UNKNOWN_USER"}
+	ErrUserInOtherTeam    = &Error{Code: "USER_IN_OTHER_TEAM"}
+	ErrPRExists           = &Error{Code: "PR_EXISTS"}
+	ErrPRMerged           = &Error{Code: "PR_MERGED"}
+	ErrPRDraft            = &Error{Code: "PR_DRAFT"}
+	ErrPRBlocked          = &Error{Code: "PR_BLOCKED"}
+	ErrInvalidPullRequest = &Error{Code: "INVALID_PULL_REQUEST"}
+	ErrInvalidAuthorTeam  = &Error{Code: "INVALID_AUTHOR_TEAM"}
+	ErrTooManyReviewers   = &Error{Code: "TOO_MANY_REVIEWERS"}
+	ErrNotEnoughReviewers = &Error{Code: "NOT_ENOUGH_REVIEWERS"}
+	ErrQuotaExceeded      = &Error{Code: "QUOTA_EXCEEDED"}
+	ErrNotAssigned        = &Error{Code: "NOT_ASSIGNED"}
+	ErrNoCandidate        = &Error{Code: "NO_CANDIDATE"}
+	ErrMaintenance        = &Error{Code: "MAINTENANCE"}
+	ErrStorageTimeout     = &Error{Code: "STORAGE_TIMEOUT"}
+	ErrTimeout            = &Error{Code: "TIMEOUT"}
+	ErrInternal           = &Error{Code: "INTERNAL"}
+)
+
+// maxErrorBody bounds how much of an error answer is read.
+const maxErrorBody = 64 << 10
+
+// decodeError reads the service's {"error": {"code", "message"}} answer. An
+// answer in another shape, e.g. from a proxy, keeps only the status.
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxErrorBody)).Decode(&body); err == nil && body.Error.Code != "" {
+		apiErr.Code = body.Error.Code
+		apiErr.Message = body.Error.Message
+	}
+	return apiErr
+}
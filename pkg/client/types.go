@@ -0,0 +1,189 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Pull request statuses.
+const (
+	StatusOpen   = "OPEN"
+	StatusMerged = "MERGED"
+)
+
+type Team struct {
+	Name    string   `json:"team_name"`
+	Members []Member `json:"members"`
+	// Links is set by the service and ignored when creating a team.
+	Links *Links `json:"links,omitempty"`
+}
+
+type Member struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	IsActive bool   `json:"is_active"`
+	// Links is set by the service and ignored when creating a team.
+	Links *Links `json:"links,omitempty"`
+}
+
+// Links are the absolute URLs of resources related to a payload, empty when
+// they do not apply. Reviews lists the pull requests a user reviews.
+type Links struct {
+	Self    string `json:"self,omitempty"`
+	Team    string `json:"team,omitempty"`
+	Reviews string `json:"reviews,omitempty"`
+}
+
+// NewPullRequest is a pull request to create. ReviewersCount, when set,
+// lowers the number of reviewers the author's team requires.
+type NewPullRequest struct {
+	ID             string   `json:"pull_request_id"`
+	Name           string   `json:"pull_request_name"`
+	AuthorID       string   `json:"author_id"`
+	Labels         []string `json:"labels,omitempty"`
+	ReviewersCount *int     `json:"reviewers_count,omitempty"`
+	// Draft creates the pull request without reviewers; see MarkReady.
+	Draft bool `json:"draft,omitempty"`
+	// Files are the changed paths; they route reviews through the team's
+	// ownership rules.
+	Files []string `json:"files,omitempty"`
+	// TeamName is the reviewing team of an author unknown to the service,
+	// such as a bot; for a known author it must be their team.
+	TeamName string `json:"team_name,omitempty"`
+	// Description is the optional body shown next to the title.
+	Description string `json:"description,omitempty"`
+}
+
+type PullRequest struct {
+	ID                string   `json:"pull_request_id"`
+	Name              string   `json:"pull_request_name"`
+	AuthorID          string   `json:"author_id"`
+	Status            string   `json:"status"`
+	AssignedReviewers []string `json:"assigned_reviewers"`
+	Labels            []string `json:"labels"`
+	URL               string   `json:"url,omitempty"`
+	Priority          string   `json:"priority,omitempty"`
+	Description       string   `json:"description,omitempty"`
+	ReviewersCount    int      `json:"reviewers_count,omitempty"`
+	// Reviewers lists AssignedReviewers with the role "required", followed
+	// by the non-blocking shadow reviewer, if any, with the role "shadow".
+	Reviewers        []Reviewer `json:"reviewers"`
+	ShadowReviewerID string     `json:"shadow_reviewer_id,omitempty"`
+	// Reassignments counts how many times a reviewer was replaced.
+	Reassignments int `json:"reassignments"`
+	// NeedsMoreReviewers flags an open pull request with fewer reviewers
+	// than requested or than its team requires.
+	NeedsMoreReviewers bool `json:"needs_more_reviewers"`
+	// BlockedBy lists the unmerged pull requests this one waits for.
+	BlockedBy []string   `json:"blocked_by,omitempty"`
+	Deadline  *time.Time `json:"deadline,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	MergedAt  *time.Time `json:"mergedAt,omitempty"`
+	Links     Links      `json:"links"`
+}
+
+// UnmarshalJSON reads the timestamps under their camelCase keys or, from a
+// service answering in snake_case, under created_at and merged_at.
+func (pr *PullRequest) UnmarshalJSON(data []byte) error {
+	type plain PullRequest
+	var aux struct {
+		plain
+		CreatedAtSnake *time.Time `json:"created_at"`
+		MergedAtSnake  *time.Time `json:"merged_at"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*pr = PullRequest(aux.plain)
+	if pr.CreatedAt == nil {
+		pr.CreatedAt = aux.CreatedAtSnake
+	}
+	if pr.MergedAt == nil {
+		pr.MergedAt = aux.MergedAtSnake
+	}
+	return nil
+}
+
+type Reviewer struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+	// Acceptance is one of the Acceptance constants for required reviewers
+	// and empty for the shadow reviewer.
+	Acceptance string `json:"acceptance,omitempty"`
+}
+
+// Reviewer acceptance states. Reviewers of teams that ask for acceptance are
+// AcceptancePending until they call AcceptReview; everyone else is
+// AcceptanceAccepted.
+const (
+	AcceptancePending  = "PENDING"
+	AcceptanceAccepted = "ACCEPTED"
+	AcceptanceExpired  = "EXPIRED"
+)
+
+// Extension is a reviewer's request for time to review a pull request until
+// Until. DecidedBy is empty for extensions approved by the team's policy.
+type Extension struct {
+	ID            int64      `json:"extension_id"`
+	PullRequestID string     `json:"pull_request_id"`
+	ReviewerID    string     `json:"reviewer_id"`
+	Until         time.Time  `json:"until"`
+	Reason        string     `json:"reason,omitempty"`
+	Status        string     `json:"status"`
+	RequestedAt   time.Time  `json:"requested_at"`
+	DecidedBy     string     `json:"decided_by,omitempty"`
+	DecidedAt     *time.Time `json:"decided_at,omitempty"`
+}
+
+// Extension statuses.
+const (
+	ExtensionPending  = "PENDING"
+	ExtensionApproved = "APPROVED"
+	ExtensionRejected = "REJECTED"
+)
+
+// PullRequestShort is a pull request as listed among a user's reviews.
+type PullRequestShort struct {
+	ID       string `json:"pull_request_id"`
+	Name     string `json:"pull_request_name"`
+	AuthorID string `json:"author_id"`
+	Status   string `json:"status"`
+	// NeedsMoreReviewers flags an open pull request short of reviewers.
+	NeedsMoreReviewers bool  `json:"needs_more_reviewers"`
+	Links              Links `json:"links"`
+}
+
+// ReviewFilter narrows ListReviews. Empty fields keep the service defaults:
+// all statuses, newest first.
+type ReviewFilter struct {
+	// Status is StatusOpen or StatusMerged.
+	Status string
+	// Sort is createdAt or mergedAt.
+	Sort string
+	// Order is asc or desc.
+	Order string
+	// NeedsMoreReviewers keeps only open pull requests short of reviewers.
+	NeedsMoreReviewers bool
+}
+
+// IntegrityReport lists the inconsistencies found by VerifyIntegrity.
+type IntegrityReport struct {
+	CheckedAt time.Time `json:"checked_at"`
+	// Issues and Fixed count the issues found and repaired.
+	Issues int `json:"issues"`
+	Fixed  int `json:"fixed"`
+	// Checks counts the issues of every check, including checks that found
+	// nothing.
+	Checks  map[string]int   `json:"checks"`
+	Details []IntegrityIssue `json:"details"`
+}
+
+// IntegrityIssue is one inconsistency. Only the fields naming the affected
+// records are set.
+type IntegrityIssue struct {
+	Check         string `json:"check"`
+	PullRequestID string `json:"pull_request_id,omitempty"`
+	UserID        string `json:"user_id,omitempty"`
+	TeamName      string `json:"team_name,omitempty"`
+	Fixed         bool   `json:"fixed"`
+}